@@ -0,0 +1,87 @@
+// Copyright 2021 Nitric Pty Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grpc
+
+import (
+	"context"
+
+	pb "github.com/nitrictech/nitric/interfaces/nitric/v1"
+	"github.com/nitrictech/nitric/pkg/plugins/config"
+	"github.com/nitrictech/nitric/pkg/plugins/document"
+	"github.com/nitrictech/nitric/pkg/plugins/events"
+	"github.com/nitrictech/nitric/pkg/plugins/queue"
+	"github.com/nitrictech/nitric/pkg/plugins/secret"
+	"github.com/nitrictech/nitric/pkg/plugins/storage"
+)
+
+// capablePlugin - The capabilities method shared by every plugin interface
+type capablePlugin interface {
+	Capabilities() []string
+}
+
+// GRPC Interface for discovering the capabilities of the registered Nitric Plugins
+type CapabilitiesServer struct {
+	pb.UnimplementedCapabilitiesServiceServer
+	// The registered plugins keyed by service name, services without a plugin are omitted
+	plugins map[string]capablePlugin
+}
+
+func (s *CapabilitiesServer) List(ctx context.Context, req *pb.CapabilitiesListRequest) (*pb.CapabilitiesListResponse, error) {
+	services := make(map[string]*pb.ServiceCapabilities, len(s.plugins))
+	for name, plugin := range s.plugins {
+		services[name] = &pb.ServiceCapabilities{
+			Capabilities: plugin.Capabilities(),
+		}
+	}
+
+	return &pb.CapabilitiesListResponse{
+		Services: services,
+	}, nil
+}
+
+func NewCapabilitiesServer(
+	documentPlugin document.DocumentService,
+	eventsPlugin events.EventService,
+	storagePlugin storage.StorageService,
+	queuePlugin queue.QueueService,
+	secretPlugin secret.SecretService,
+	configPlugin config.ConfigService,
+) pb.CapabilitiesServiceServer {
+	plugins := make(map[string]capablePlugin)
+
+	// Services without a registered plugin are omitted from the manifest
+	if documentPlugin != nil {
+		plugins["document"] = documentPlugin
+	}
+	if eventsPlugin != nil {
+		plugins["events"] = eventsPlugin
+	}
+	if storagePlugin != nil {
+		plugins["storage"] = storagePlugin
+	}
+	if queuePlugin != nil {
+		plugins["queue"] = queuePlugin
+	}
+	if secretPlugin != nil {
+		plugins["secret"] = secretPlugin
+	}
+	if configPlugin != nil {
+		plugins["config"] = configPlugin
+	}
+
+	return &CapabilitiesServer{
+		plugins: plugins,
+	}
+}
@@ -0,0 +1,69 @@
+// Copyright 2021 Nitric Pty Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grpc_test
+
+import (
+	"context"
+
+	v1 "github.com/nitrictech/nitric/interfaces/nitric/v1"
+	"github.com/nitrictech/nitric/pkg/adapters/grpc"
+	"github.com/nitrictech/nitric/pkg/plugins/events"
+	"github.com/nitrictech/nitric/pkg/plugins/storage"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// MockPresigningStorageService - A storage service that supports pre-signed URLs
+type MockPresigningStorageService struct {
+	storage.UnimplementedStoragePlugin
+}
+
+func (*MockPresigningStorageService) Capabilities() []string {
+	return append(storage.BaselineCapabilities(), storage.CapabilityPreSignUrl)
+}
+
+var _ = Describe("Capabilities Service", func() {
+	Context("List", func() {
+		When("Plugins are registered for some services", func() {
+			server := grpc.NewCapabilitiesServer(nil, &MockEventService{}, &MockPresigningStorageService{}, nil, nil, nil)
+
+			resp, err := server.List(context.TODO(), &v1.CapabilitiesListRequest{})
+
+			It("Should not return an error", func() {
+				Expect(err).ShouldNot(HaveOccurred())
+			})
+
+			It("Should only list the services with a registered plugin", func() {
+				Expect(resp.GetServices()).To(HaveLen(2))
+				Expect(resp.GetServices()).To(HaveKey("events"))
+				Expect(resp.GetServices()).To(HaveKey("storage"))
+			})
+
+			It("Should list the baseline capabilities of plugins that don't declare others", func() {
+				Expect(resp.GetServices()["events"].GetCapabilities()).To(ConsistOf(events.BaselineCapabilities()))
+				Expect(resp.GetServices()["events"].GetCapabilities()).ToNot(ContainElement(events.CapabilityPublishOrdered))
+			})
+
+			It("Should list the optional capabilities plugins declare", func() {
+				Expect(resp.GetServices()["storage"].GetCapabilities()).To(ConsistOf(
+					storage.CapabilityRead,
+					storage.CapabilityWrite,
+					storage.CapabilityDelete,
+					storage.CapabilityPreSignUrl,
+				))
+			})
+		})
+	})
+})
@@ -0,0 +1,89 @@
+// Copyright 2021 Nitric Pty Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grpc
+
+import (
+	"context"
+
+	pb "github.com/nitrictech/nitric/interfaces/nitric/v1"
+	"github.com/nitrictech/nitric/pkg/plugins/config"
+	"google.golang.org/grpc/codes"
+)
+
+// GRPC Interface for registered Nitric Config Plugins
+type ConfigServer struct {
+	pb.UnimplementedConfigServiceServer
+	configPlugin config.ConfigService
+}
+
+func (s *ConfigServer) checkPluginRegistered() error {
+	if s.configPlugin == nil {
+		return NewPluginNotRegisteredError("Config")
+	}
+
+	return nil
+}
+
+func toPbConfigValue(value *config.ConfigValue) *pb.ConfigValue {
+	return &pb.ConfigValue{
+		Key:     value.Key,
+		Value:   value.Value,
+		Version: value.Version,
+	}
+}
+
+func (s *ConfigServer) Get(ctx context.Context, req *pb.ConfigGetRequest) (*pb.ConfigGetResponse, error) {
+	if err := s.checkPluginRegistered(); err != nil {
+		return nil, err
+	}
+
+	if err := req.ValidateAll(); err != nil {
+		return nil, newGrpcErrorWithCode(codes.InvalidArgument, "ConfigService.Get", err)
+	}
+
+	if v, err := s.configPlugin.Get(req.GetKey()); err == nil {
+		return &pb.ConfigGetResponse{
+			Value: toPbConfigValue(v),
+		}, nil
+	} else {
+		return nil, NewGrpcError(ctx, "ConfigService.Get", err)
+	}
+}
+
+func (s *ConfigServer) List(ctx context.Context, req *pb.ConfigListRequest) (*pb.ConfigListResponse, error) {
+	if err := s.checkPluginRegistered(); err != nil {
+		return nil, err
+	}
+
+	if values, err := s.configPlugin.List(); err == nil {
+		pbValues := make(map[string]*pb.ConfigValue, len(values))
+		for key, v := range values {
+			value := v
+			pbValues[key] = toPbConfigValue(&value)
+		}
+
+		return &pb.ConfigListResponse{
+			Values: pbValues,
+		}, nil
+	} else {
+		return nil, NewGrpcError(ctx, "ConfigService.List", err)
+	}
+}
+
+func NewConfigServer(configPlugin config.ConfigService) pb.ConfigServiceServer {
+	return &ConfigServer{
+		configPlugin: configPlugin,
+	}
+}
@@ -16,10 +16,13 @@ package grpc
 
 import (
 	"context"
+	"fmt"
 	"io"
+	"time"
 
 	pb "github.com/nitrictech/nitric/interfaces/nitric/v1"
 	"github.com/nitrictech/nitric/pkg/plugins/document"
+	"go.opentelemetry.io/otel/attribute"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/protobuf/types/known/structpb"
 )
@@ -51,14 +54,16 @@ func (s *DocumentServiceServer) Get(ctx context.Context, req *pb.DocumentGetRequ
 
 	key := keyFromWire(req.Key)
 
+	_, span := startPluginSpan(ctx, "document.Get", documentAttributes(key.Collection)...)
 	doc, err := s.documentPlugin.Get(key)
+	endSpan(span, err)
 	if err != nil {
-		return nil, NewGrpcError("DocumentService.Get", err)
+		return nil, NewGrpcError(ctx, "DocumentService.Get", err)
 	}
 
 	pbDoc, err := documentToWire(doc)
 	if err != nil {
-		return nil, NewGrpcError("DocumentService.Get", err)
+		return nil, NewGrpcError(ctx, "DocumentService.Get", err)
 	}
 
 	return &pb.DocumentGetResponse{
@@ -77,14 +82,73 @@ func (s *DocumentServiceServer) Set(ctx context.Context, req *pb.DocumentSetRequ
 
 	key := keyFromWire(req.Key)
 
-	err := s.documentPlugin.Set(key, req.GetContent().AsMap())
+	// Expiry is only supported by some plugins, reject rather than silently storing without it
+	expiringPlugin, supportsTtl := s.documentPlugin.(document.ExpiringDocumentService)
+	if req.GetTtl() > 0 && !supportsTtl {
+		return nil, newGrpcErrorWithCode(codes.Unimplemented, "DocumentService.Set", fmt.Errorf("the configured document plugin does not support ttl"))
+	}
+
+	// Conditional sets are only supported by some plugins, reject rather than silently setting unconditionally
+	conditionalPlugin, supportsConditions := s.documentPlugin.(document.ConditionalDocumentService)
+	conditional := req.GetIfNotExists() || req.GetIfVersion() > 0
+	if conditional && !supportsConditions {
+		return nil, newGrpcErrorWithCode(codes.Unimplemented, "DocumentService.Set", fmt.Errorf("the configured document plugin does not support conditional sets"))
+	}
+	if conditional && req.GetTtl() > 0 {
+		return nil, newGrpcErrorWithCode(codes.InvalidArgument, "DocumentService.Set", fmt.Errorf("ttl can't be combined with a condition"))
+	}
+
+	var err error
+	_, span := startPluginSpan(ctx, "document.Set", documentAttributes(key.Collection)...)
+	if conditional {
+		err = conditionalPlugin.SetWithCondition(key, req.GetContent().AsMap(), &document.SetCondition{
+			IfNotExists: req.GetIfNotExists(),
+			IfVersion:   req.GetIfVersion(),
+		})
+	} else if req.GetTtl() > 0 {
+		err = expiringPlugin.SetWithTtl(key, req.GetContent().AsMap(), time.Duration(req.GetTtl())*time.Second)
+	} else {
+		err = s.documentPlugin.Set(key, req.GetContent().AsMap())
+	}
+	endSpan(span, err)
 	if err != nil {
-		return nil, NewGrpcError("DocumentService.Set", err)
+		return nil, NewGrpcError(ctx, "DocumentService.Set", err)
 	}
 
 	return &pb.DocumentSetResponse{}, nil
 }
 
+func (s *DocumentServiceServer) Merge(ctx context.Context, req *pb.DocumentMergeRequest) (*pb.DocumentMergeResponse, error) {
+	if err := s.checkPluginRegistered(); err != nil {
+		return nil, err
+	}
+
+	if err := req.ValidateAll(); err != nil {
+		return nil, newGrpcErrorWithCode(codes.InvalidArgument, "DocumentService.Merge", err)
+	}
+
+	// Merges are only supported by some plugins, reject rather than failing with an unclear error
+	if !supportsMerge(s.documentPlugin) {
+		return nil, newGrpcErrorWithCode(codes.Unimplemented, "DocumentService.Merge", fmt.Errorf("the configured document plugin does not support merge"))
+	}
+
+	key := keyFromWire(req.Key)
+
+	mode := document.MergeShallow
+	if req.GetDeep() {
+		mode = document.MergeDeep
+	}
+
+	_, span := startPluginSpan(ctx, "document.Merge", documentAttributes(key.Collection)...)
+	err := s.documentPlugin.Merge(key, req.GetContent().AsMap(), mode)
+	endSpan(span, err)
+	if err != nil {
+		return nil, NewGrpcError(ctx, "DocumentService.Merge", err)
+	}
+
+	return &pb.DocumentMergeResponse{}, nil
+}
+
 func (s *DocumentServiceServer) Delete(ctx context.Context, req *pb.DocumentDeleteRequest) (*pb.DocumentDeleteResponse, error) {
 	if err := s.checkPluginRegistered(); err != nil {
 		return nil, err
@@ -96,9 +160,11 @@ func (s *DocumentServiceServer) Delete(ctx context.Context, req *pb.DocumentDele
 
 	key := keyFromWire(req.Key)
 
+	_, span := startPluginSpan(ctx, "document.Delete", documentAttributes(key.Collection)...)
 	err := s.documentPlugin.Delete(key)
+	endSpan(span, err)
 	if err != nil {
-		return nil, NewGrpcError("DocumentService.Delete", err)
+		return nil, NewGrpcError(ctx, "DocumentService.Delete", err)
 	}
 
 	return &pb.DocumentDeleteResponse{}, nil
@@ -119,16 +185,18 @@ func (s *DocumentServiceServer) Query(ctx context.Context, req *pb.DocumentQuery
 	limit := int(req.GetLimit())
 	pagingMap := req.GetPagingToken()
 
+	_, span := startPluginSpan(ctx, "document.Query", documentAttributes(collection)...)
 	qr, err := s.documentPlugin.Query(collection, expressions, limit, pagingMap)
+	endSpan(span, err)
 	if err != nil {
-		return nil, NewGrpcError("DocumentService.Query", err)
+		return nil, NewGrpcError(ctx, "DocumentService.Query", err)
 	}
 
 	pbDocuments := make([]*pb.Document, 0, len(qr.Documents))
 	for _, doc := range qr.Documents {
 		pbDoc, err := documentToWire(&doc)
 		if err != nil {
-			return nil, NewGrpcError("DocumentService.Query", err)
+			return nil, NewGrpcError(ctx, "DocumentService.Query", err)
 		}
 
 		pbDocuments = append(pbDocuments, pbDoc)
@@ -152,15 +220,17 @@ func (s *DocumentServiceServer) QueryStream(req *pb.DocumentQueryStreamRequest,
 
 	for doc, err := next(); err != io.EOF; doc, err = next() {
 		if err != nil {
-			return NewGrpcError("DocumentService.QueryStream", err)
+			return NewGrpcError(srv.Context(), "DocumentService.QueryStream", err)
+		}
+
+		d, docErr := documentToWire(doc)
+		if docErr != nil {
+			return NewGrpcError(srv.Context(), "DocumentService.QueryStream", docErr)
 		}
 
-		if d, docErr := documentToWire(doc); docErr != nil {
-			return NewGrpcError("DocumentService.QueryStream", err)
-		} else {
-			srv.Send(&pb.DocumentQueryStreamResponse{
-				Document: d,
-			})
+		// Stop querying once the client has gone, rather than reading the rest of the collection
+		if err := srv.Send(&pb.DocumentQueryStreamResponse{Document: d}); err != nil {
+			return err
 		}
 	}
 
@@ -173,6 +243,28 @@ func NewDocumentServer(docPlugin document.DocumentService) pb.DocumentServiceSer
 	}
 }
 
+// documentAttributes - identifies the collection of a document operation on its span
+// supportsMerge - Returns true if the plugin declares the merge capability
+func supportsMerge(plugin document.DocumentService) bool {
+	for _, capability := range plugin.Capabilities() {
+		if capability == document.CapabilityMerge {
+			return true
+		}
+	}
+
+	return false
+}
+
+func documentAttributes(collection *document.Collection) []attribute.KeyValue {
+	if collection == nil {
+		return nil
+	}
+
+	return []attribute.KeyValue{
+		attribute.String("nitric.document.collection", collection.Name),
+	}
+}
+
 func documentToWire(doc *document.Document) (*pb.Document, error) {
 	valStruct, err := structpb.NewStruct(doc.Content)
 	if err != nil {
@@ -182,6 +274,7 @@ func documentToWire(doc *document.Document) (*pb.Document, error) {
 	return &pb.Document{
 		Content: valStruct,
 		Key:     keyToWire(doc.Key),
+		Version: doc.Version,
 	}, nil
 }
 
@@ -15,6 +15,7 @@
 package grpc
 
 import (
+	"context"
 	"fmt"
 	"reflect"
 
@@ -24,9 +25,9 @@ import (
 	"google.golang.org/grpc/status"
 )
 
-// Provides GRPC error reporting
-func NewGrpcError(operation string, err error) error {
-	if pe, ok := err.(*errors.PluginError); ok {
+// Provides GRPC error reporting, plugin errors include the ID of the request carried by the context
+func NewGrpcError(ctx context.Context, operation string, err error) error {
+	if pe, ok := errors.WithRequestId(ctx, err).(*errors.PluginError); ok {
 		code := codes.Code(errors.Code(pe))
 
 		ed := &v1.ErrorDetails{}
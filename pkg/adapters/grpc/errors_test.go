@@ -15,13 +15,16 @@
 package grpc_test
 
 import (
+	"context"
 	"fmt"
 
+	v1 "github.com/nitrictech/nitric/interfaces/nitric/v1"
 	"github.com/nitrictech/nitric/pkg/adapters/grpc"
 	"github.com/nitrictech/nitric/pkg/plugins/errors"
 	"github.com/nitrictech/nitric/pkg/plugins/errors/codes"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"google.golang.org/grpc/status"
 )
 
 type SecretValue struct {
@@ -46,7 +49,7 @@ var _ = Describe("GRPC Errors", func() {
 					"bad param",
 					nil,
 				)
-				grpcErr := grpc.NewGrpcError("BadServer.BadCall", err)
+				grpcErr := grpc.NewGrpcError(context.Background(), "BadServer.BadCall", err)
 				Expect(grpcErr.Error()).To(ContainSubstring("rpc error: code = InvalidArgument desc = bad param"))
 			})
 		})
@@ -59,14 +62,43 @@ var _ = Describe("GRPC Errors", func() {
 					"bad param",
 					nil,
 				)
-				grpcErr := grpc.NewGrpcError("BadServer.BadCall", err)
+				grpcErr := grpc.NewGrpcError(context.Background(), "BadServer.BadCall", err)
 				Expect(grpcErr.Error()).To(ContainSubstring("rpc error: code = InvalidArgument desc = bad param"))
 			})
 		})
+		When("The context carries a request ID", func() {
+			It("Should include the request ID in the error args", func() {
+				ctx := errors.ContextWithRequestId(context.Background(), "test-request")
+				newErr := errors.ErrorsWithScope("test", map[string]interface{}{"key": "value"})
+				err := newErr(
+					codes.Unavailable,
+					"publish failed",
+					nil,
+				)
+				grpcErr := grpc.NewGrpcError(ctx, "BadServer.BadCall", err)
+
+				s, _ := status.FromError(grpcErr)
+				Expect(s.Details()).To(HaveLen(1))
+				ed := s.Details()[0].(*v1.ErrorDetails)
+				Expect(ed.Scope.Args).To(Equal(map[string]string{
+					"key":       "value",
+					"requestId": "test-request",
+				}))
+			})
+
+			It("Should not modify the original error args", func() {
+				ctx := errors.ContextWithRequestId(context.Background(), "test-request")
+				args := map[string]interface{}{"key": "value"}
+				err := errors.ErrorsWithScope("test", args)(codes.Unavailable, "publish failed", nil)
+				grpc.NewGrpcError(ctx, "BadServer.BadCall", err)
+
+				Expect(args).ToNot(HaveKey("requestId"))
+			})
+		})
 		When("Standard Error", func() {
 			It("Should report GRPC Internal error", func() {
 				err := fmt.Errorf("internal error")
-				err = grpc.NewGrpcError("BadServer.BadCall", err)
+				err = grpc.NewGrpcError(context.Background(), "BadServer.BadCall", err)
 				Expect(err.Error()).To(ContainSubstring("rpc error: code = Internal desc = internal error"))
 			})
 		})
@@ -20,6 +20,7 @@ import (
 	"github.com/google/uuid"
 	pb "github.com/nitrictech/nitric/interfaces/nitric/v1"
 	"github.com/nitrictech/nitric/pkg/plugins/events"
+	"go.opentelemetry.io/otel/attribute"
 	"google.golang.org/grpc/codes"
 )
 
@@ -56,13 +57,29 @@ func (s *EventServiceServer) Publish(ctx context.Context, req *pb.EventPublishRe
 		ID:          ID,
 		PayloadType: req.GetEvent().GetPayloadType(),
 		Payload:     req.GetEvent().GetPayload().AsMap(),
+		Encoding:    events.PayloadEncoding(req.GetEvent().GetEncoding()),
+		Data:        req.GetEvent().GetData(),
 	}
-	if err := s.eventPlugin.Publish(req.GetTopic(), event); err == nil {
+	_, span := startPluginSpan(
+		ctx,
+		"events.Publish",
+		attribute.String("messaging.destination", req.GetTopic()),
+		attribute.Int("messaging.event_count", 1),
+	)
+	var err error
+	if orderingKey := req.GetEvent().GetOrderingKey(); orderingKey != "" {
+		err = events.PublishOrdered(s.eventPlugin, req.GetTopic(), orderingKey, event)
+	} else {
+		err = s.eventPlugin.Publish(req.GetTopic(), event)
+	}
+	endSpan(span, err)
+
+	if err == nil {
 		return &pb.EventPublishResponse{
 			Id: ID,
 		}, nil
 	} else {
-		return nil, NewGrpcError("EventService.Publish", err)
+		return nil, NewGrpcError(ctx, "EventService.Publish", err)
 	}
 }
 
@@ -94,7 +111,15 @@ func (s *TopicServiceServer) List(ctx context.Context, req *pb.TopicListRequest)
 		return nil, newGrpcErrorWithCode(codes.InvalidArgument, "TopicService.List", err)
 	}
 
-	if res, err := s.eventPlugin.ListTopics(); err == nil {
+	var res []string
+	var err error
+	if req.GetPrefix() != "" {
+		res, err = s.eventPlugin.ListTopicsWithPrefix(req.GetPrefix())
+	} else {
+		res, err = s.eventPlugin.ListTopics()
+	}
+
+	if err == nil {
 		topics := make([]*pb.NitricTopic, len(res))
 		for i, topicName := range res {
 			topics[i] = &pb.NitricTopic{
@@ -106,7 +131,7 @@ func (s *TopicServiceServer) List(ctx context.Context, req *pb.TopicListRequest)
 			Topics: topics,
 		}, nil
 	} else {
-		return nil, NewGrpcError("TopicService.List", err)
+		return nil, NewGrpcError(ctx, "TopicService.List", err)
 	}
 }
 
@@ -23,9 +23,12 @@ import (
 	"github.com/nitrictech/nitric/pkg/plugins/events"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 type MockEventService struct {
+	events.UnimplementedeventsPlugin
 	PublishError error
 	PublishTopic string
 	PublishEvent *events.NitricEvent
@@ -44,6 +47,17 @@ func (m *MockEventService) ListTopics() ([]string, error) {
 	return m.TopicList, m.TopicListError
 }
 
+// MockOrderedEventService - An event service that supports ordered publishing
+type MockOrderedEventService struct {
+	MockEventService
+	PublishOrderingKey string
+}
+
+func (m *MockOrderedEventService) PublishOrdered(topic string, orderingKey string, event *events.NitricEvent) error {
+	m.PublishOrderingKey = orderingKey
+	return m.Publish(topic, events.WithOrderingKey(event, orderingKey))
+}
+
 var _ = Describe("Event Service gRPC Adapter", func() {
 	Context("Publish", func() {
 		When("No request id is provided", func() {
@@ -103,5 +117,40 @@ var _ = Describe("Event Service gRPC Adapter", func() {
 				Expect(response.Id).To(Equal("test-id"))
 			})
 		})
+
+		When("An ordering key is provided", func() {
+			It("Should publish the event in order when the plugin supports it", func() {
+				mockService := &MockOrderedEventService{}
+
+				eventServer := grpc.NewEventServiceServer(mockService)
+				_, err := eventServer.Publish(context.Background(), &v1.EventPublishRequest{
+					Topic: "test-topic",
+					Event: &v1.NitricEvent{
+						Id:          "test-id",
+						OrderingKey: "user-1",
+					},
+				})
+
+				Expect(err).To(BeNil())
+				Expect(mockService.PublishOrderingKey).To(Equal("user-1"))
+				Expect(mockService.PublishEvent.OrderingKey).To(Equal("user-1"))
+			})
+
+			It("Should return unimplemented when the plugin doesn't support ordering", func() {
+				mockService := &MockEventService{}
+
+				eventServer := grpc.NewEventServiceServer(mockService)
+				_, err := eventServer.Publish(context.Background(), &v1.EventPublishRequest{
+					Topic: "test-topic",
+					Event: &v1.NitricEvent{
+						Id:          "test-id",
+						OrderingKey: "user-1",
+					},
+				})
+
+				Expect(status.Code(err)).To(Equal(codes.Unimplemented))
+				Expect(mockService.PublishEvent).To(BeNil())
+			})
+		})
 	})
 })
@@ -15,9 +15,9 @@
 package grpc
 
 import (
-	"fmt"
-
+	"github.com/nitrictech/nitric/pkg/logger"
 	"github.com/nitrictech/nitric/pkg/worker"
+	"google.golang.org/grpc/codes"
 
 	pb "github.com/nitrictech/nitric/interfaces/nitric/v1"
 )
@@ -25,7 +25,11 @@ import (
 type FaasServer struct {
 	pb.UnimplementedFaasServiceServer
 	// srv  pb.Faas_TriggerStreamServer
-	pool worker.WorkerPool
+	pool    worker.WorkerPool
+	log     logger.Logger
+	metrics *worker.Metrics
+	// Trigger data larger than this is split across multiple stream messages, 0 never splits it
+	chunkBytes int
 }
 
 // Starts a new stream
@@ -33,16 +37,19 @@ type FaasServer struct {
 // This represents a new server that is ready to begin processing
 func (s *FaasServer) TriggerStream(stream pb.FaasService_TriggerStreamServer) error {
 	// Create a new worker
-	wrkr := worker.NewFaasWorker(stream)
+	wrkr := worker.NewFaasWorker(stream, s.log, s.metrics, s.chunkBytes)
 
 	// Add it to our new pool
 	if err := s.pool.AddWorker(wrkr); err != nil {
 		// Worker could not be added
 		// Cancel the stream by returning an error
 		// This should cause the spawned child process to exit
-		return err
+		s.log.Warn("FaaS worker rejected by pool", "workerId", wrkr.ID(), "error", err)
+		return newAddWorkerError(err)
 	}
 
+	s.log.Info("FaaS stream opened, added worker", "workerId", wrkr.ID(), "workers", s.pool.GetWorkerCount())
+
 	// We're good to go
 	errchan := make(chan error)
 
@@ -51,16 +58,40 @@ func (s *FaasServer) TriggerStream(stream pb.FaasService_TriggerStreamServer) er
 
 	// block here on error returned from the worker
 	err := <-errchan
-	fmt.Println("FaaS stream closed, removing worker")
 
 	// Worker is done so we can remove it from the pool
 	s.pool.RemoveWorker(wrkr)
+	s.log.Info("FaaS stream closed, removed worker", "workerId", wrkr.ID(), "workers", s.pool.GetWorkerCount())
 
 	return err
 }
 
-func NewFaasServer(workerPool worker.WorkerPool) *FaasServer {
+// newAddWorkerError - classifies pool startup errors, so the function can tell whether it's worth reconnecting
+func newAddWorkerError(err error) error {
+	code := codes.Internal
+
+	if startupErr, ok := err.(*worker.PoolStartupError); ok {
+		switch startupErr.Kind {
+		case worker.PoolStartupError_PoolFull:
+			// The pool may have capacity later, so the function can retry
+			code = codes.ResourceExhausted
+		case worker.PoolStartupError_DuplicateWorker:
+			code = codes.AlreadyExists
+		case worker.PoolStartupError_WorkerRejected:
+			code = codes.FailedPrecondition
+		}
+	}
+
+	return newGrpcErrorWithCode(code, "FaasServer.TriggerStream", err)
+}
+
+// NewFaasServer - Creates a FaaS server adding workers to the pool, workers record to metrics when it is not nil
+// and split trigger data larger than chunkBytes across multiple stream messages
+func NewFaasServer(workerPool worker.WorkerPool, log logger.Logger, metrics *worker.Metrics, chunkBytes int) *FaasServer {
 	return &FaasServer{
-		pool: workerPool,
+		pool:       workerPool,
+		log:        log,
+		metrics:    metrics,
+		chunkBytes: chunkBytes,
 	}
 }
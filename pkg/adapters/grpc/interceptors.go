@@ -0,0 +1,85 @@
+// Copyright 2021 Nitric Pty Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grpc
+
+import (
+	"context"
+	"runtime/debug"
+	"time"
+
+	"github.com/nitrictech/nitric/pkg/logger"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// recoverPanic - Logs a recovered panic and converts it to an Internal error, so a panicking handler
+// fails its call rather than crashing the membrane
+func recoverPanic(log logger.Logger, method string, recovered interface{}) error {
+	log.Error("recovered from panic handling gRPC call", "method", method, "panic", recovered, "stack", string(debug.Stack()))
+	return status.Errorf(codes.Internal, "%s: internal error", method)
+}
+
+// RecoveryUnaryInterceptor - Recovers from panics in unary handlers, returning an Internal error to the caller
+func RecoveryUnaryInterceptor(log logger.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				resp, err = nil, recoverPanic(log, info.FullMethod, r)
+			}
+		}()
+
+		return handler(ctx, req)
+	}
+}
+
+// RecoveryStreamInterceptor - Recovers from panics in stream handlers, e.g. the FaaS TriggerStream,
+// ending the stream with an Internal error
+func RecoveryStreamInterceptor(log logger.Logger) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = recoverPanic(log, info.FullMethod, r)
+			}
+		}()
+
+		return handler(srv, ss)
+	}
+}
+
+// LoggingUnaryInterceptor - Logs each unary call with its status code and duration at the debug level
+func LoggingUnaryInterceptor(log logger.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		log.Debug("handled gRPC call", "method", info.FullMethod, "code", status.Code(err).String(), "duration", time.Since(start).String())
+
+		return resp, err
+	}
+}
+
+// LoggingStreamInterceptor - Logs each stream when it opens and once it ends with its status code and duration.
+// Streams are long lived, so they're logged at the info level
+func LoggingStreamInterceptor(log logger.Logger) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		log.Info("gRPC stream opened", "method", info.FullMethod)
+
+		err := handler(srv, ss)
+		log.Info("gRPC stream closed", "method", info.FullMethod, "code", status.Code(err).String(), "duration", time.Since(start).String())
+
+		return err
+	}
+}
@@ -0,0 +1,87 @@
+// Copyright 2021 Nitric Pty Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grpc_test
+
+import (
+	"context"
+
+	"github.com/nitrictech/nitric/pkg/adapters/grpc"
+	"github.com/nitrictech/nitric/pkg/logger"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	grpclib "google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var _ = Describe("Interceptors", func() {
+	log := logger.NewNoopLogger()
+
+	Context("RecoveryUnaryInterceptor", func() {
+		interceptor := grpc.RecoveryUnaryInterceptor(log)
+		info := &grpclib.UnaryServerInfo{FullMethod: "/nitric.test.v1.TestService/Call"}
+
+		When("The handler panics", func() {
+			It("Should return an Internal error", func() {
+				resp, err := interceptor(context.TODO(), nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+					panic("handler failure")
+				})
+
+				Expect(resp).To(BeNil())
+				Expect(status.Code(err)).To(Equal(codes.Internal))
+			})
+		})
+
+		When("The handler returns", func() {
+			It("Should return the handler's response", func() {
+				resp, err := interceptor(context.TODO(), nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+					return "response", nil
+				})
+
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(resp).To(Equal("response"))
+			})
+		})
+	})
+
+	Context("RecoveryStreamInterceptor", func() {
+		When("The handler panics", func() {
+			It("Should end the stream with an Internal error", func() {
+				interceptor := grpc.RecoveryStreamInterceptor(log)
+				info := &grpclib.StreamServerInfo{FullMethod: "/nitric.faas.v1.FaasService/TriggerStream"}
+
+				err := interceptor(nil, nil, info, func(srv interface{}, stream grpclib.ServerStream) error {
+					panic("handler failure")
+				})
+
+				Expect(status.Code(err)).To(Equal(codes.Internal))
+			})
+		})
+	})
+
+	Context("LoggingUnaryInterceptor", func() {
+		It("Should return the handler's error unchanged", func() {
+			interceptor := grpc.LoggingUnaryInterceptor(log)
+			info := &grpclib.UnaryServerInfo{FullMethod: "/nitric.test.v1.TestService/Call"}
+			handlerErr := status.Error(codes.NotFound, "not found")
+
+			_, err := interceptor(context.TODO(), nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+				return nil, handlerErr
+			})
+
+			Expect(err).To(Equal(handlerErr))
+		})
+	})
+})
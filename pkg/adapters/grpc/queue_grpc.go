@@ -16,9 +16,11 @@ package grpc
 
 import (
 	"context"
+	"time"
 
 	pb "github.com/nitrictech/nitric/interfaces/nitric/v1"
 	"github.com/nitrictech/nitric/pkg/plugins/queue"
+	"go.opentelemetry.io/otel/attribute"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/protobuf/types/known/structpb"
 )
@@ -52,9 +54,14 @@ func (s *QueueServiceServer) Send(ctx context.Context, req *pb.QueueSendRequest)
 		ID:          task.GetId(),
 		PayloadType: task.GetPayloadType(),
 		Payload:     task.GetPayload().AsMap(),
+		Attributes:  task.GetAttributes(),
 	}
 
-	if err := s.plugin.Send(req.GetQueue(), nitricTask); err != nil {
+	_, span := startPluginSpan(ctx, "queue.Send", attribute.String("messaging.destination", req.GetQueue()))
+	err := s.plugin.Send(req.GetQueue(), nitricTask)
+	endSpan(span, err)
+
+	if err != nil {
 		return nil, err
 	}
 
@@ -78,10 +85,20 @@ func (s *QueueServiceServer) SendBatch(ctx context.Context, req *pb.QueueSendBat
 			ID:          task.GetId(),
 			PayloadType: task.GetPayloadType(),
 			Payload:     task.GetPayload().AsMap(),
+			Attributes:  task.GetAttributes(),
 		}
 	}
 
-	if resp, err := s.plugin.SendBatch(req.GetQueue(), tasks); err == nil {
+	_, span := startPluginSpan(
+		ctx,
+		"queue.SendBatch",
+		attribute.String("messaging.destination", req.GetQueue()),
+		attribute.Int("messaging.task_count", len(tasks)),
+	)
+	resp, err := s.plugin.SendBatch(req.GetQueue(), tasks)
+	endSpan(span, err)
+
+	if err == nil {
 		failedTasks := make([]*pb.FailedTask, len(resp.FailedTasks))
 		for i, failedTask := range resp.FailedTasks {
 			st, _ := structpb.NewStruct(failedTask.Task.Payload)
@@ -91,6 +108,7 @@ func (s *QueueServiceServer) SendBatch(ctx context.Context, req *pb.QueueSendBat
 					Id:          failedTask.Task.ID,
 					PayloadType: failedTask.Task.PayloadType,
 					Payload:     st,
+					Attributes:  failedTask.Task.Attributes,
 				},
 			}
 		}
@@ -98,7 +116,7 @@ func (s *QueueServiceServer) SendBatch(ctx context.Context, req *pb.QueueSendBat
 			FailedTasks: failedTasks,
 		}, nil
 	} else {
-		return nil, NewGrpcError("QueueService.SendBatch", err)
+		return nil, NewGrpcError(ctx, "QueueService.SendBatch", err)
 	}
 }
 
@@ -114,14 +132,19 @@ func (s *QueueServiceServer) Receive(ctx context.Context, req *pb.QueueReceiveRe
 	// Convert gRPC request to plugin params
 	depth := uint32(req.GetDepth())
 	popOptions := queue.ReceiveOptions{
-		QueueName: req.GetQueue(),
-		Depth:     &depth,
+		QueueName:         req.GetQueue(),
+		Depth:             &depth,
+		VisibilityTimeout: time.Duration(req.GetVisibilityTimeout()) * time.Second,
+		WaitTime:          time.Duration(req.GetWaitTime()) * time.Second,
+		Filter:            req.GetFilter(),
 	}
 
 	// Perform the Queue Receive operation
+	_, span := startPluginSpan(ctx, "queue.Receive", attribute.String("messaging.destination", req.GetQueue()))
 	tasks, err := s.plugin.Receive(popOptions)
+	endSpan(span, err)
 	if err != nil {
-		return nil, NewGrpcError("QueueService.Receive", err)
+		return nil, NewGrpcError(ctx, "QueueService.Receive", err)
 	}
 
 	// Convert the NitricTasks to the gRPC type
@@ -133,6 +156,7 @@ func (s *QueueServiceServer) Receive(ctx context.Context, req *pb.QueueReceiveRe
 			Payload:     st,
 			LeaseId:     task.LeaseID,
 			PayloadType: task.PayloadType,
+			Attributes:  task.Attributes,
 		})
 	}
 
@@ -156,15 +180,91 @@ func (s *QueueServiceServer) Complete(ctx context.Context, req *pb.QueueComplete
 	leaseId := req.GetLeaseId()
 
 	// Perform the Queue Complete operation
+	_, span := startPluginSpan(ctx, "queue.Complete", attribute.String("messaging.destination", queueName))
 	err := s.plugin.Complete(queueName, leaseId)
+	endSpan(span, err)
 	if err != nil {
-		return nil, NewGrpcError("QueueService.Complete", err)
+		return nil, NewGrpcError(ctx, "QueueService.Complete", err)
 	}
 
 	// Return a successful response
 	return &pb.QueueCompleteResponse{}, nil
 }
 
+var completeFailureReasons = map[queue.CompleteFailure]pb.CompleteResult_Reason{
+	queue.CompleteFailure_Transient:        pb.CompleteResult_TRANSIENT,
+	queue.CompleteFailure_AlreadyCompleted: pb.CompleteResult_ALREADY_COMPLETED,
+	queue.CompleteFailure_LeaseExpired:     pb.CompleteResult_LEASE_EXPIRED,
+}
+
+func (s *QueueServiceServer) CompleteBatch(ctx context.Context, req *pb.QueueCompleteBatchRequest) (*pb.QueueCompleteBatchResponse, error) {
+	if err := s.checkPluginRegistered(); err != nil {
+		return nil, err
+	}
+
+	if err := req.ValidateAll(); err != nil {
+		return nil, newGrpcErrorWithCode(codes.InvalidArgument, "QueueService.CompleteBatch", err)
+	}
+
+	_, span := startPluginSpan(
+		ctx,
+		"queue.CompleteBatch",
+		attribute.String("messaging.destination", req.GetQueue()),
+		attribute.Int("messaging.task_count", len(req.GetLeaseIds())),
+	)
+	resp, err := s.plugin.CompleteBatch(req.GetQueue(), req.GetLeaseIds())
+	endSpan(span, err)
+	if err != nil {
+		return nil, NewGrpcError(ctx, "QueueService.CompleteBatch", err)
+	}
+
+	results := make([]*pb.CompleteResult, len(resp.Results))
+	for i, result := range resp.Results {
+		results[i] = &pb.CompleteResult{
+			LeaseId: result.LeaseID,
+			Success: result.Err == nil,
+		}
+
+		if result.Err != nil {
+			// Failures that weren't classified by the plugin are treated as transient
+			results[i].Reason = pb.CompleteResult_TRANSIENT
+			if completeErr, ok := queue.AsCompleteError(result.Err); ok {
+				results[i].Reason = completeFailureReasons[completeErr.Reason]
+			}
+			results[i].Message = result.Err.Error()
+		}
+	}
+
+	return &pb.QueueCompleteBatchResponse{
+		Results: results,
+	}, nil
+}
+
+func (s *QueueServiceServer) LeaseExtend(ctx context.Context, req *pb.QueueLeaseExtendRequest) (*pb.QueueLeaseExtendResponse, error) {
+	if err := s.checkPluginRegistered(); err != nil {
+		return nil, err
+	}
+
+	if err := req.ValidateAll(); err != nil {
+		return nil, newGrpcErrorWithCode(codes.InvalidArgument, "QueueService.LeaseExtend", err)
+	}
+	// Convert gRPC request to plugin params
+	queueName := req.GetQueue()
+	leaseId := req.GetLeaseId()
+	duration := time.Duration(req.GetDuration()) * time.Second
+
+	// Perform the Queue LeaseExtend operation
+	_, span := startPluginSpan(ctx, "queue.LeaseExtend", attribute.String("messaging.destination", queueName))
+	err := s.plugin.LeaseExtend(queueName, leaseId, duration)
+	endSpan(span, err)
+	if err != nil {
+		return nil, NewGrpcError(ctx, "QueueService.LeaseExtend", err)
+	}
+
+	// Return a successful response
+	return &pb.QueueLeaseExtendResponse{}, nil
+}
+
 func NewQueueServiceServer(plugin queue.QueueService) pb.QueueServiceServer {
 	return &QueueServiceServer{
 		plugin: plugin,
@@ -0,0 +1,64 @@
+// Copyright 2021 Nitric Pty Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grpc
+
+import (
+	"context"
+
+	"github.com/nitrictech/nitric/pkg/plugins/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// RequestIdMetadataKey - The metadata key functions use to pass the ID of the request they're handling
+const RequestIdMetadataKey = "x-nitric-request-id"
+
+// contextWithRequestId - Carries the request ID from the incoming call metadata in the context
+func contextWithRequestId(ctx context.Context) context.Context {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ctx
+	}
+
+	if values := md.Get(RequestIdMetadataKey); len(values) > 0 && values[0] != "" {
+		return errors.ContextWithRequestId(ctx, values[0])
+	}
+
+	return ctx
+}
+
+// RequestIdUnaryInterceptor - Makes the request ID passed by the function available to each unary call
+func RequestIdUnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		return handler(contextWithRequestId(ctx), req)
+	}
+}
+
+// requestIdServerStream - Replaces the stream context with one carrying the request ID
+type requestIdServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *requestIdServerStream) Context() context.Context {
+	return s.ctx
+}
+
+// RequestIdStreamInterceptor - Makes the request ID passed by the function available to each stream
+func RequestIdStreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return handler(srv, &requestIdServerStream{ss, contextWithRequestId(ss.Context())})
+	}
+}
@@ -57,7 +57,7 @@ func (s *SecretServer) Put(ctx context.Context, req *pb.SecretPutRequest) (*pb.S
 			},
 		}, nil
 	} else {
-		return nil, NewGrpcError("SecretService.Put", err)
+		return nil, NewGrpcError(ctx, "SecretService.Put", err)
 	}
 }
 
@@ -86,7 +86,7 @@ func (s *SecretServer) Access(ctx context.Context, req *pb.SecretAccessRequest)
 			Value: s.Value,
 		}, nil
 	} else {
-		return nil, NewGrpcError("SecretService.Access", err)
+		return nil, NewGrpcError(ctx, "SecretService.Access", err)
 	}
 }
 
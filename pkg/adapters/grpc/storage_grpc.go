@@ -17,9 +17,11 @@ package grpc
 import (
 	"context"
 	"fmt"
+	"io"
 
 	pb "github.com/nitrictech/nitric/interfaces/nitric/v1"
 	"github.com/nitrictech/nitric/pkg/plugins/storage"
+	"go.opentelemetry.io/otel/attribute"
 	"google.golang.org/grpc/codes"
 )
 
@@ -46,10 +48,19 @@ func (s *StorageServiceServer) Write(ctx context.Context, req *pb.StorageWriteRe
 		return nil, newGrpcErrorWithCode(codes.InvalidArgument, "StorageService.Write", err)
 	}
 
-	if err := s.storagePlugin.Write(req.GetBucketName(), req.GetKey(), req.GetBody()); err == nil {
+	_, span := startPluginSpan(ctx, "storage.Write", storageAttributes(req.GetBucketName(), req.GetKey())...)
+	var err error
+	if len(req.GetMetadata()) > 0 {
+		err = s.storagePlugin.WriteWithMetadata(req.GetBucketName(), req.GetKey(), req.GetBody(), req.GetMetadata())
+	} else {
+		err = s.storagePlugin.Write(req.GetBucketName(), req.GetKey(), req.GetBody())
+	}
+	endSpan(span, err)
+
+	if err == nil {
 		return &pb.StorageWriteResponse{}, nil
 	} else {
-		return nil, NewGrpcError("StorageService.Write", err)
+		return nil, NewGrpcError(ctx, "StorageService.Write", err)
 	}
 }
 
@@ -62,12 +73,38 @@ func (s *StorageServiceServer) Read(ctx context.Context, req *pb.StorageReadRequ
 		return nil, newGrpcErrorWithCode(codes.InvalidArgument, "StorageService.Read", err)
 	}
 
-	if object, err := s.storagePlugin.Read(req.GetBucketName(), req.GetKey()); err == nil {
+	_, span := startPluginSpan(ctx, "storage.Read", storageAttributes(req.GetBucketName(), req.GetKey())...)
+	object, err := s.storagePlugin.Read(req.GetBucketName(), req.GetKey())
+	endSpan(span, err)
+
+	if err == nil {
 		return &pb.StorageReadResponse{
 			Body: object,
 		}, nil
 	} else {
-		return nil, NewGrpcError("StorageService.Read", err)
+		return nil, NewGrpcError(ctx, "StorageService.Read", err)
+	}
+}
+
+func (s *StorageServiceServer) GetMetadata(ctx context.Context, req *pb.StorageGetMetadataRequest) (*pb.StorageGetMetadataResponse, error) {
+	if err := s.checkPluginRegistered(); err != nil {
+		return nil, err
+	}
+
+	if err := req.ValidateAll(); err != nil {
+		return nil, newGrpcErrorWithCode(codes.InvalidArgument, "StorageService.GetMetadata", err)
+	}
+
+	_, span := startPluginSpan(ctx, "storage.GetMetadata", storageAttributes(req.GetBucketName(), req.GetKey())...)
+	metadata, err := s.storagePlugin.GetMetadata(req.GetBucketName(), req.GetKey())
+	endSpan(span, err)
+
+	if err == nil {
+		return &pb.StorageGetMetadataResponse{
+			Metadata: metadata,
+		}, nil
+	} else {
+		return nil, NewGrpcError(ctx, "StorageService.GetMetadata", err)
 	}
 }
 
@@ -80,10 +117,21 @@ func (s *StorageServiceServer) Delete(ctx context.Context, req *pb.StorageDelete
 		return nil, newGrpcErrorWithCode(codes.InvalidArgument, "StorageService.Delete", err)
 	}
 
-	if err := s.storagePlugin.Delete(req.GetBucketName(), req.GetKey()); err == nil {
+	_, span := startPluginSpan(ctx, "storage.Delete", storageAttributes(req.GetBucketName(), req.GetKey())...)
+	err := s.storagePlugin.Delete(req.GetBucketName(), req.GetKey())
+	endSpan(span, err)
+
+	if err == nil {
 		return &pb.StorageDeleteResponse{}, nil
 	} else {
-		return nil, NewGrpcError("StorageService.Delete", err)
+		return nil, NewGrpcError(ctx, "StorageService.Delete", err)
+	}
+}
+
+func storageAttributes(bucket string, key string) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.String("nitric.storage.bucket", bucket),
+		attribute.String("nitric.storage.key", key),
 	}
 }
 
@@ -116,10 +164,35 @@ func (s *StorageServiceServer) PreSignUrl(ctx context.Context, req *pb.StoragePr
 			Url: url,
 		}, nil
 	} else {
-		return nil, NewGrpcError("StorageService.PreSignUrl", err)
+		return nil, NewGrpcError(ctx, "StorageService.PreSignUrl", err)
 	}
 }
 
+func (s *StorageServiceServer) ListFilesStream(req *pb.StorageListFilesStreamRequest, srv pb.StorageService_ListFilesStreamServer) error {
+	if err := s.checkPluginRegistered(); err != nil {
+		return err
+	}
+
+	if err := req.ValidateAll(); err != nil {
+		return newGrpcErrorWithCode(codes.InvalidArgument, "StorageService.ListFilesStream", err)
+	}
+
+	next := s.storagePlugin.ListFilesStream(req.GetBucketName(), req.GetPrefix())
+
+	for file, err := next(); err != io.EOF; file, err = next() {
+		if err != nil {
+			return NewGrpcError(srv.Context(), "StorageService.ListFilesStream", err)
+		}
+
+		// Stop listing once the client has gone, rather than reading the rest of the bucket
+		if err := srv.Send(&pb.StorageListFilesStreamResponse{Key: file.Key}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func NewStorageServiceServer(storagePlugin storage.StorageService) pb.StorageServiceServer {
 	return &StorageServiceServer{
 		storagePlugin: storagePlugin,
@@ -30,6 +30,7 @@ type (
 	bucketHandle   struct{ *storage.BucketHandle }
 	objectHandle   struct{ *storage.ObjectHandle }
 	bucketIterator struct{ *storage.BucketIterator }
+	objectIterator struct{ *storage.ObjectIterator }
 	writer         struct{ *storage.Writer }
 	reader         struct{ *storage.Reader }
 )
@@ -52,6 +53,10 @@ func (b bucketHandle) Object(name string) ObjectHandle {
 	return objectHandle{b.BucketHandle.Object(name)}
 }
 
+func (b bucketHandle) Objects(ctx context.Context, query *storage.Query) ObjectIterator {
+	return objectIterator{b.BucketHandle.Objects(ctx, query)}
+}
+
 func (o objectHandle) Key(encryptionKey []byte) ObjectHandle {
 	return objectHandle{o.ObjectHandle.Key(encryptionKey)}
 }
@@ -65,6 +70,21 @@ func (o objectHandle) NewReader(ctx context.Context) (Reader, error) {
 	return reader{newReader}, err
 }
 
+func (o objectHandle) NewRangeReader(ctx context.Context, offset int64, length int64) (Reader, error) {
+	newReader, err := o.ObjectHandle.NewRangeReader(ctx, offset, length)
+	return reader{newReader}, err
+}
+
 func (o objectHandle) Delete(ctx context.Context) error {
 	return o.ObjectHandle.Delete(ctx)
 }
+
+func (o objectHandle) Attrs(ctx context.Context) (*storage.ObjectAttrs, error) {
+	return o.ObjectHandle.Attrs(ctx)
+}
+
+func (w writer) SetMetadata(contentType string, cacheControl string, metadata map[string]string) {
+	w.Writer.ContentType = contentType
+	w.Writer.CacheControl = cacheControl
+	w.Writer.Metadata = metadata
+}
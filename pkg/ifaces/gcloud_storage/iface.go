@@ -23,6 +23,8 @@ import (
 
 type Writer interface {
 	io.WriteCloser
+	// SetMetadata - Sets the properties and custom metadata the object is written with, must be called before Write
+	SetMetadata(contentType string, cacheControl string, metadata map[string]string)
 	// embedToIncludeNewMethods()
 }
 
@@ -33,7 +35,9 @@ type Reader interface {
 type ObjectHandle interface {
 	NewWriter(context.Context) Writer
 	NewReader(context.Context) (Reader, error)
+	NewRangeReader(ctx context.Context, offset int64, length int64) (Reader, error)
 	Delete(ctx context.Context) error
+	Attrs(ctx context.Context) (*storage.ObjectAttrs, error)
 
 	// embedToIncludeNewMethods()
 }
@@ -44,8 +48,15 @@ type BucketIterator interface {
 	// embedToIncludeNewMethods()
 }
 
+type ObjectIterator interface {
+	Next() (*storage.ObjectAttrs, error)
+
+	// embedToIncludeNewMethods()
+}
+
 type BucketHandle interface {
 	Object(string) ObjectHandle
+	Objects(context.Context, *storage.Query) ObjectIterator
 
 	// embedToIncludeNewMethods()
 }
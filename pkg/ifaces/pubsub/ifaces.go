@@ -26,6 +26,7 @@ type PubsubClient interface {
 	// CreateTopic(ctx context.Context, topicID string) (Topic, error)
 	Topic(id string) Topic
 	Topics(ctx context.Context) TopicIterator
+	Close() error
 	// CreateSubscription(ctx context.Context, id string, cfg SubscriptionConfig) (Subscription, error)
 	// Subscription(id string) Subscription
 }
@@ -68,4 +69,5 @@ type SubscriberClient interface {
 	Close() error
 	Pull(ctx context.Context, req *pubsubpb.PullRequest, opts ...gax.CallOption) (*pubsubpb.PullResponse, error)
 	Acknowledge(ctx context.Context, req *pubsubpb.AcknowledgeRequest, opts ...gax.CallOption) error
+	ModifyAckDeadline(ctx context.Context, req *pubsubpb.ModifyAckDeadlineRequest, opts ...gax.CallOption) error
 }
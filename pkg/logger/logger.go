@@ -0,0 +1,143 @@
+// Copyright 2021 Nitric Pty Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logger
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Level enum
+type Level int
+
+const (
+	Level_Debug Level = iota
+	Level_Info
+	Level_Warn
+	Level_Error
+)
+
+var levels = [...]string{"DEBUG", "INFO", "WARN", "ERROR"}
+
+func (l Level) String() string {
+	return levels[l]
+}
+
+func LevelFromString(levelString string) (Level, error) {
+	for i, level := range levels {
+		if level == strings.ToUpper(levelString) {
+			return Level(i), nil
+		}
+	}
+	return -1, fmt.Errorf("Invalid log level %s, supported levels are: %s", levelString, strings.Join(levels[:], ", "))
+}
+
+// Logger - A leveled logger, accepting alternating keys and values as structured fields
+type Logger interface {
+	Debug(msg string, keysAndValues ...interface{})
+	Info(msg string, keysAndValues ...interface{})
+	Warn(msg string, keysAndValues ...interface{})
+	Error(msg string, keysAndValues ...interface{})
+}
+
+// jsonLogger - Writes each log event as a single line JSON object
+type jsonLogger struct {
+	lock  sync.Mutex
+	out   io.Writer
+	level Level
+}
+
+func (l *jsonLogger) log(level Level, msg string, keysAndValues []interface{}) {
+	if level < l.level {
+		return
+	}
+
+	event := map[string]interface{}{
+		"time":  time.Now().UTC().Format(time.RFC3339Nano),
+		"level": strings.ToLower(level.String()),
+		"msg":   msg,
+	}
+
+	for i := 0; i < len(keysAndValues); i += 2 {
+		key := fmt.Sprint(keysAndValues[i])
+
+		var value interface{} = nil
+		if i+1 < len(keysAndValues) {
+			value = keysAndValues[i+1]
+		}
+
+		// Errors don't marshal to anything useful
+		if err, ok := value.(error); ok {
+			value = err.Error()
+		}
+
+		event[key] = value
+	}
+
+	line, err := json.Marshal(event)
+	if err != nil {
+		line, _ = json.Marshal(map[string]interface{}{
+			"time":  event["time"],
+			"level": event["level"],
+			"msg":   msg,
+			"error": fmt.Sprintf("unable to marshal log fields: %v", err),
+		})
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.out.Write(append(line, '\n'))
+}
+
+func (l *jsonLogger) Debug(msg string, keysAndValues ...interface{}) {
+	l.log(Level_Debug, msg, keysAndValues)
+}
+
+func (l *jsonLogger) Info(msg string, keysAndValues ...interface{}) {
+	l.log(Level_Info, msg, keysAndValues)
+}
+
+func (l *jsonLogger) Warn(msg string, keysAndValues ...interface{}) {
+	l.log(Level_Warn, msg, keysAndValues)
+}
+
+func (l *jsonLogger) Error(msg string, keysAndValues ...interface{}) {
+	l.log(Level_Error, msg, keysAndValues)
+}
+
+// NewJSONLogger - Creates a logger writing events at or above the given level to out as JSON
+func NewJSONLogger(out io.Writer, level Level) Logger {
+	return &jsonLogger{
+		out:   out,
+		level: level,
+	}
+}
+
+type noopLogger struct{}
+
+func (noopLogger) Debug(msg string, keysAndValues ...interface{}) {}
+func (noopLogger) Info(msg string, keysAndValues ...interface{})  {}
+func (noopLogger) Warn(msg string, keysAndValues ...interface{})  {}
+func (noopLogger) Error(msg string, keysAndValues ...interface{}) {}
+
+// NewNoopLogger - Creates a logger that discards all events
+func NewNoopLogger() Logger {
+	return noopLogger{}
+}
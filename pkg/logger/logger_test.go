@@ -0,0 +1,74 @@
+// Copyright 2021 Nitric Pty Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logger_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/nitrictech/nitric/pkg/logger"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Logger", func() {
+	Context("JSON Logger", func() {
+		When("Logging at or above the configured level", func() {
+			It("Should write a JSON event with the given fields", func() {
+				out := &bytes.Buffer{}
+				log := logger.NewJSONLogger(out, logger.Level_Info)
+
+				log.Warn("worker removed", "workerId", "test-worker", "error", fmt.Errorf("stream closed"))
+
+				event := make(map[string]interface{})
+				Expect(json.Unmarshal(out.Bytes(), &event)).To(Succeed())
+				Expect(event["level"]).To(Equal("warn"))
+				Expect(event["msg"]).To(Equal("worker removed"))
+				Expect(event["workerId"]).To(Equal("test-worker"))
+				Expect(event["error"]).To(Equal("stream closed"))
+				Expect(event["time"]).ToNot(BeEmpty())
+			})
+		})
+
+		When("Logging below the configured level", func() {
+			It("Should not write anything", func() {
+				out := &bytes.Buffer{}
+				log := logger.NewJSONLogger(out, logger.Level_Info)
+
+				log.Debug("debug message")
+
+				Expect(out.Len()).To(Equal(0))
+			})
+		})
+	})
+
+	Context("LevelFromString", func() {
+		When("The level is valid", func() {
+			It("Should ignore case", func() {
+				level, err := logger.LevelFromString("debug")
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(level).To(Equal(logger.Level_Debug))
+			})
+		})
+
+		When("The level is invalid", func() {
+			It("Should return an error", func() {
+				_, err := logger.LevelFromString("verbose")
+				Expect(err).Should(HaveOccurred())
+			})
+		})
+	})
+})
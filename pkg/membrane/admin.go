@@ -0,0 +1,187 @@
+// Copyright 2021 Nitric Pty Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package membrane
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/nitrictech/nitric/pkg/worker"
+)
+
+// adminWorker - The state of a worker reported by the admin endpoint
+type adminWorker struct {
+	ID       string `json:"id"`
+	Ready    bool   `json:"ready"`
+	Busy     bool   `json:"busy"`
+	Draining bool   `json:"draining"`
+	InFlight int    `json:"inFlight"`
+	// Handled - the number of triggers the worker has completed
+	Handled     int64      `json:"handled"`
+	LastError   string     `json:"lastError,omitempty"`
+	LastErrorAt *time.Time `json:"lastErrorAt,omitempty"`
+}
+
+// adminWorkers - The state of the worker pool reported by the admin endpoint
+type adminWorkers struct {
+	Workers []*adminWorker `json:"workers"`
+	// Pending - the number of triggers waiting for a worker
+	Pending int `json:"pending"`
+}
+
+func toAdminWorker(info worker.WorkerInfo) *adminWorker {
+	w := &adminWorker{
+		ID:        info.ID,
+		Ready:     info.Ready,
+		Busy:      info.Busy,
+		Draining:  info.Draining,
+		InFlight:  info.InFlight,
+		Handled:   info.Handled,
+		LastError: info.LastError,
+	}
+
+	if !info.LastErrorAt.IsZero() {
+		lastErrorAt := info.LastErrorAt
+		w.LastErrorAt = &lastErrorAt
+	}
+
+	return w
+}
+
+// writeJson - Writes the value as a JSON response with the given status
+func writeJson(rw http.ResponseWriter, status int, value interface{}) {
+	body, _ := json.Marshal(value)
+
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(status)
+	rw.Write(body)
+}
+
+// adminAuth - Requires requests to present the admin token as a bearer token, when one is configured
+func (s *Membrane) adminAuth(next http.HandlerFunc) http.HandlerFunc {
+	if s.adminToken == "" {
+		return next
+	}
+
+	return func(rw http.ResponseWriter, req *http.Request) {
+		token := strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer ")
+
+		if subtle.ConstantTimeCompare([]byte(token), []byte(s.adminToken)) != 1 {
+			rw.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(rw, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		next(rw, req)
+	}
+}
+
+// workersHandler - Lists the workers in the pool at /workers, or inspects a single worker at /workers/{id}
+func (s *Membrane) workersHandler(rw http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		rw.Header().Set("Allow", http.MethodGet)
+		http.Error(rw, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id := strings.Trim(strings.TrimPrefix(req.URL.Path, "/workers"), "/")
+
+	infos := s.pool.ListWorkers()
+
+	if id == "" {
+		status := &adminWorkers{
+			Workers: make([]*adminWorker, 0, len(infos)),
+			Pending: s.pool.GetPendingCount(),
+		}
+		for _, info := range infos {
+			status.Workers = append(status.Workers, toAdminWorker(info))
+		}
+
+		writeJson(rw, http.StatusOK, status)
+		return
+	}
+
+	for _, info := range infos {
+		if info.ID == id {
+			writeJson(rw, http.StatusOK, toAdminWorker(info))
+			return
+		}
+	}
+
+	http.Error(rw, "worker not found", http.StatusNotFound)
+}
+
+// replayHandler - Replays every dead-lettered event at /deadletter/replay, or a single event at /deadletter/replay/{id}
+func (s *Membrane) replayHandler(replayer *worker.DeadLetterReplayer) http.HandlerFunc {
+	return func(rw http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPost {
+			rw.Header().Set("Allow", http.MethodPost)
+			http.Error(rw, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		id := strings.Trim(strings.TrimPrefix(req.URL.Path, "/deadletter/replay"), "/")
+
+		var result *worker.ReplayResult
+		var err error
+		if id == "" {
+			result, err = replayer.ReplayAll()
+		} else {
+			result, err = replayer.Replay(id)
+		}
+
+		if err == worker.ErrDeadLetterNotFound {
+			http.Error(rw, err.Error(), http.StatusNotFound)
+			return
+		} else if err != nil {
+			s.log.Error("error replaying dead-lettered events", "error", err)
+			http.Error(rw, "error replaying dead-lettered events", http.StatusInternalServerError)
+			return
+		}
+
+		writeJson(rw, http.StatusOK, result)
+	}
+}
+
+// startAdminServer - Starts the admin server for inspecting the membrane on the configured address
+func (s *Membrane) startAdminServer() error {
+	lis, err := net.Listen("tcp", s.adminAddress)
+	if err != nil {
+		return err
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/workers", s.adminAuth(s.workersHandler))
+	mux.HandleFunc("/workers/", s.adminAuth(s.workersHandler))
+
+	// Replayed events are dispatched through the same decorated pool as the gateway, so they're dead-lettered again if they fail
+	if s.deadLetterPlugin != nil {
+		replayer := worker.NewDeadLetterReplayer(s.createGatewayPool(), s.deadLetterPlugin, s.deadLetterQueue, s.deadLetterMaxReplays, s.log)
+		mux.HandleFunc("/deadletter/replay", s.adminAuth(s.replayHandler(replayer)))
+		mux.HandleFunc("/deadletter/replay/", s.adminAuth(s.replayHandler(replayer)))
+	}
+
+	s.adminServer = &http.Server{
+		Handler: mux,
+	}
+
+	go s.adminServer.Serve(lis)
+
+	return nil
+}
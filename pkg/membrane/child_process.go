@@ -0,0 +1,139 @@
+// Copyright 2021 Nitric Pty Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package membrane
+
+import (
+	"fmt"
+	"os/exec"
+	"time"
+)
+
+// The longest the membrane will wait between restarts of the child process
+const maxChildRestartBackoff = 30 * time.Second
+
+// childRestartBackoff - The time to wait before the given restart attempt, doubling with each attempt
+func (s *Membrane) childRestartBackoff(restarts int) time.Duration {
+	backoff := s.childRestartBaseBackoff
+	for i := 0; i < restarts && backoff < maxChildRestartBackoff; i++ {
+		backoff *= 2
+	}
+
+	if backoff > maxChildRestartBackoff {
+		return maxChildRestartBackoff
+	}
+
+	return backoff
+}
+
+// childExit - The exit of a child process, done is closed once it has exited and err is set
+type childExit struct {
+	done chan struct{}
+	err  error
+}
+
+// watchChildProcess - Waits for the child process to exit in the background, so its exit can be observed
+// by both the startup wait and the child supervisor
+func watchChildProcess(childProcess *exec.Cmd) *childExit {
+	exit := &childExit{
+		done: make(chan struct{}),
+	}
+
+	go func() {
+		exit.err = childProcess.Wait()
+		close(exit.done)
+	}()
+
+	return exit
+}
+
+// waitForChildWorkers - Waits for the minimum number of workers to be available, failing fast if the child
+// process exits before they connect rather than waiting for the timeout. A nil exit waits for the timeout
+func (s *Membrane) waitForChildWorkers(exit *childExit) error {
+	if exit == nil {
+		return s.pool.WaitForMinimumWorkers(s.childTimeoutSeconds)
+	}
+
+	waitErr := make(chan error, 1)
+	go func() {
+		waitErr <- s.pool.WaitForMinimumWorkers(s.childTimeoutSeconds)
+	}()
+
+	select {
+	case err := <-waitErr:
+		return err
+	case <-exit.done:
+		// Workers that connected before the child exited are left to the supervisor
+		if s.pool.WaitForMinimumWorkers(0) == nil {
+			return nil
+		}
+
+		if exit.err != nil {
+			return fmt.Errorf("child exited before connecting: %v", exit.err)
+		}
+		return fmt.Errorf("child exited before connecting")
+	}
+}
+
+// superviseChildProcess - Waits for the child process to exit, restarting it according to the restart policy.
+// Returns once the child process has exited and won't be restarted, with an error unless it exited successfully
+func (s *Membrane) superviseChildProcess(exit *childExit) error {
+	restarts := 0
+
+	for {
+		<-exit.done
+		exitErr := exit.err
+
+		select {
+		case <-s.stopped:
+			// The membrane is stopping, so the child is expected to exit
+			return nil
+		default:
+		}
+
+		if !s.childRestartPolicy.shouldRestart(exitErr) {
+			if exitErr != nil {
+				return fmt.Errorf("child process exited: %v", exitErr)
+			}
+			s.log.Info("child process exited")
+			return nil
+		}
+
+		if restarts >= s.childMaxRestarts {
+			return fmt.Errorf("child process exited after %d restarts, giving up: %v", restarts, exitErr)
+		}
+
+		backoff := s.childRestartBackoff(restarts)
+		restarts++
+		s.log.Warn("child process exited, restarting", "error", exitErr, "restart", restarts, "backoff", backoff.String())
+
+		select {
+		case <-s.stopped:
+			return nil
+		case <-time.After(backoff):
+		}
+
+		childProcess, err := s.startChildProcess()
+		if err != nil {
+			return err
+		}
+		exit = watchChildProcess(childProcess)
+
+		// The restarted child must reconnect its workers before it can be considered running
+		if err := s.waitForChildWorkers(exit); err != nil {
+			return fmt.Errorf("restarted child process did not reconnect: %v", err)
+		}
+		s.log.Info("child process restarted", "restart", restarts, "workers", s.pool.GetWorkerCount())
+	}
+}
@@ -0,0 +1,115 @@
+// Copyright 2021 Nitric Pty Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package membrane
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+)
+
+type readinessStatus struct {
+	Ready bool `json:"ready"`
+	// Workers - the number of workers ready to handle triggers
+	Workers int `json:"workers"`
+	// PendingWorkers - the number of connected workers that haven't signalled they are ready
+	PendingWorkers int      `json:"pendingWorkers,omitempty"`
+	MissingPlugins []string `json:"missingPlugins,omitempty"`
+}
+
+// missingPlugins - returns the names of any service plugins the membrane was started without
+func (s *Membrane) missingPlugins() []string {
+	missing := make([]string, 0)
+
+	if s.documentPlugin == nil {
+		missing = append(missing, "document")
+	}
+	if s.eventsPlugin == nil {
+		missing = append(missing, "events")
+	}
+	if s.storagePlugin == nil {
+		missing = append(missing, "storage")
+	}
+	if s.queuePlugin == nil {
+		missing = append(missing, "queue")
+	}
+	if s.secretPlugin == nil {
+		missing = append(missing, "secret")
+	}
+
+	return missing
+}
+
+// readiness - the membrane is ready once all plugins are available and at least one worker can handle triggers
+func (s *Membrane) readiness() *readinessStatus {
+	status := &readinessStatus{
+		MissingPlugins: s.missingPlugins(),
+	}
+
+	// Workers that are still warming up or being drained can't handle triggers
+	for _, w := range s.pool.ListWorkers() {
+		if w.Draining {
+			continue
+		}
+		if w.Ready {
+			status.Workers++
+		} else {
+			status.PendingWorkers++
+		}
+	}
+
+	status.Ready = status.Workers > 0 && len(status.MissingPlugins) == 0
+
+	return status
+}
+
+func (s *Membrane) healthzHandler(rw http.ResponseWriter, req *http.Request) {
+	rw.WriteHeader(http.StatusOK)
+	rw.Write([]byte("ok"))
+}
+
+func (s *Membrane) readyzHandler(rw http.ResponseWriter, req *http.Request) {
+	status := s.readiness()
+
+	body, _ := json.Marshal(status)
+
+	rw.Header().Set("Content-Type", "application/json")
+	if status.Ready {
+		rw.WriteHeader(http.StatusOK)
+	} else {
+		rw.WriteHeader(http.StatusServiceUnavailable)
+	}
+	rw.Write(body)
+}
+
+// startHealthCheckServer - Starts the liveness and readiness probe server on the configured address
+func (s *Membrane) startHealthCheckServer() error {
+	lis, err := net.Listen("tcp", s.healthCheckAddress)
+	if err != nil {
+		return err
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", s.healthzHandler)
+	mux.HandleFunc("/readyz", s.readyzHandler)
+
+	s.healthServer = &http.Server{
+		Handler: mux,
+	}
+
+	go s.healthServer.Serve(lis)
+
+	return nil
+}
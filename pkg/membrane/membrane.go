@@ -16,26 +16,37 @@ package membrane
 
 import (
 	"fmt"
+	"io"
 	"net"
+	"net/http"
 	"os"
 	"os/exec"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	grpc2 "github.com/nitrictech/nitric/pkg/adapters/grpc"
+	"github.com/nitrictech/nitric/pkg/logger"
 	"github.com/nitrictech/nitric/pkg/plugins/secret"
 	"github.com/nitrictech/nitric/pkg/utils"
 	"github.com/nitrictech/nitric/pkg/worker"
 
 	v1 "github.com/nitrictech/nitric/interfaces/nitric/v1"
+	"github.com/nitrictech/nitric/pkg/plugins/config"
 	"github.com/nitrictech/nitric/pkg/plugins/document"
 	"github.com/nitrictech/nitric/pkg/plugins/events"
 	"github.com/nitrictech/nitric/pkg/plugins/gateway"
+	"github.com/nitrictech/nitric/pkg/plugins/middleware"
 	"github.com/nitrictech/nitric/pkg/plugins/queue"
 	"github.com/nitrictech/nitric/pkg/plugins/storage"
+	"go.opentelemetry.io/otel/trace"
 	"google.golang.org/grpc"
 )
 
+// DefaultMaxMessageBytes - The default maximum size of gRPC messages, matching the gRPC default receive limit
+const DefaultMaxMessageBytes = 4 * 1024 * 1024
+
 type MembraneOptions struct {
 	ServiceAddress string
 	// The address the child will be listening on
@@ -44,6 +55,15 @@ type MembraneOptions struct {
 	ChildCommand []string
 	// The total time to wait for the child process to be available in seconds
 	ChildTimeoutSeconds int
+	// The total time to wait for in-flight triggers to complete when stopping in seconds
+	ShutdownTimeoutSeconds int
+	// Whether the child process is restarted when it exits, defaults to never
+	ChildRestartPolicy *ChildRestartPolicy
+	// The number of times the child process is restarted before the membrane gives up
+	ChildMaxRestarts int
+	// The total time to wait for a worker to handle a single trigger in seconds, 0 is unlimited.
+	// HTTP requests are also limited to the deadline set by their caller, e.g. with a grpc-timeout header
+	RequestTimeoutSeconds int
 
 	DocumentPlugin document.DocumentService
 	EventsPlugin   events.EventService
@@ -51,15 +71,162 @@ type MembraneOptions struct {
 	QueuePlugin    queue.QueueService
 	GatewayPlugin  gateway.GatewayService
 	SecretPlugin   secret.SecretService
+	// Optional, functions receive an unimplemented error when reading config if not provided
+	ConfigPlugin config.ConfigService
 
+	// Disables all membrane logging, overrides the Logger
 	SuppressLogs            bool
 	TolerateMissingServices bool
 
+	// The logger used by the membrane and its workers, defaults to a JSON logger at the LOG_LEVEL level
+	Logger logger.Logger
+
 	// The operating mode of the membrane
 	Mode *Mode
 
 	// Supply your own worker pool
 	Pool worker.WorkerPool
+
+	// Additional options for the gRPC server hosting the membrane services and the FaaS TriggerStream,
+	// applied after the built-in options, e.g. grpc.ChainUnaryInterceptor and grpc.ChainStreamInterceptor
+	// to add interceptors for auth or metrics. Chained interceptors run after the built-in recovery,
+	// tracing, request ID and logging interceptors
+	GrpcServerOptions []grpc.ServerOption
+
+	// The maximum size of gRPC messages received from functions, defaults to 4MB
+	MaxRecvMessageBytes int
+	// The maximum size of gRPC messages sent to functions, defaults to 4MB.
+	// Larger FaaS trigger data is split across multiple messages
+	MaxSendMessageBytes int
+
+	// The maximum size of HTTP request bodies dispatched to workers, 0 is unlimited
+	MaxRequestBodyBytes int
+	// The maximum size of HTTP response bodies returned from workers, 0 is unlimited
+	MaxResponseBodyBytes int
+
+	// Compress HTTP responses for clients that accept gzip or deflate encoding
+	EnableCompression bool
+	// The minimum size of HTTP response bodies compressed, defaults to 1024
+	CompressionMinBytes int
+
+	// The content type of HTTP responses returned without one, defaults to text/plain; charset=utf-8
+	DefaultResponseContentType string
+	// Detect the content type of HTTP responses returned without one from their first bytes,
+	// falling back to the default content type when it isn't recognized
+	SniffContentType bool
+
+	// Origins allowed to make cross-origin HTTP requests, CORS is disabled if empty.
+	// "*" allows any origin, origins may contain * wildcards and origins starting with ^ are regular expressions
+	CorsAllowedOrigins []string
+	// Methods allowed in cross-origin requests, defaults to GET, HEAD, POST, PUT, PATCH and DELETE
+	CorsAllowedMethods []string
+	// Request headers allowed in cross-origin requests, "*" allows any header
+	CorsAllowedHeaders []string
+	// Response headers exposed to cross-origin clients
+	CorsExposedHeaders []string
+	// Allows cross-origin requests to include credentials such as cookies
+	CorsAllowCredentials bool
+	// The time in seconds clients may cache preflight responses
+	CorsMaxAgeSeconds int
+
+	// The only HTTP request headers passed to workers, all headers are passed if empty
+	HeaderAllowList []string
+	// HTTP request headers removed before requests are passed to workers
+	HeaderDenyList []string
+
+	// Logs HTTP requests dispatched to workers and their responses, with sensitive headers and JSON body keys redacted.
+	// Requests aren't logged if nil
+	HttpLogging *worker.HttpLoggingOptions
+
+	// Path prefixes mapped to the storage bucket their GET and HEAD requests are served from, without invoking a worker.
+	// Requires the StoragePlugin, e.g. {"/assets": "site-assets"}
+	StaticRoutes map[string]string
+
+	// The time in seconds responses to HTTP requests with an Idempotency-Key header are replayed to retries of the
+	// request, instead of invoking a worker. 0 disables idempotency keys
+	IdempotencyTtlSeconds int
+	// The store responses are replayed from, defaults to the store selected by the IDEMPOTENCY_STORE env var
+	IdempotencyStore worker.IdempotencyStore
+
+	// Middleware HTTP requests and events pass through before reaching workers, in order, e.g. for auth or rate limiting
+	TriggerMiddleware []worker.TriggerMiddleware
+
+	// Authorizes HTTP requests before any trigger middleware, unauthorized requests receive a 401 response.
+	// Defaults to a JWT authorizer if the AUTH_JWKS_URL env var is set, otherwise every request is allowed
+	Authorizer worker.Authorizer
+
+	// The PEM encoded certificate chain and private key files the gateway serves HTTPS with, HTTPS is disabled if empty
+	TlsCertFile string
+	TlsKeyFile  string
+	// PEM encoded certificate chain and private key, used in place of the files
+	TlsCertPEM []byte
+	TlsKeyPEM  []byte
+	// The minimum TLS version the gateway accepts, defaults to 1.2
+	TlsMinVersion string
+	// A PEM encoded CA bundle the gateway verifies client certificates against, client certificates aren't required if empty
+	TlsClientCAFile string
+	// Accept HTTP/2 without TLS (h2c) at the gateway, HTTP/2 is always negotiated over TLS
+	GatewayH2c bool
+
+	// The address to serve the /healthz and /readyz probes on, the probes are disabled if empty
+	HealthCheckAddress string
+
+	// The address to serve Prometheus worker metrics on at /metrics, metrics are disabled if empty
+	MetricsAddress string
+
+	// The address to serve the admin endpoint listing and inspecting workers on at /workers, disabled if empty
+	AdminAddress string
+	// The bearer token required by the admin endpoint, no token is required if empty
+	AdminToken string
+
+	// The provider used to trace trigger dispatch and plugin calls, defaults to a no-op provider
+	TracerProvider trace.TracerProvider
+
+	// The number of times events, queue and storage plugin calls that fail with transient errors are retried, 0 disables retries
+	PluginRetries int
+	// The delay before the first plugin retry in milliseconds, doubled for each subsequent retry, defaults to 100
+	PluginRetryBackoffMs int
+
+	// The number of consecutive failed events and queue plugin calls that opens the plugin's circuit,
+	// failing calls fast until a probe call succeeds. 0 disables circuit breaking
+	PluginCircuitBreakerThreshold int
+	// The time in seconds a plugin's circuit stays open before a probe call is allowed, defaults to 30
+	PluginCircuitBreakerOpenSeconds int
+
+	// Event plugins calls fail over to in order when the EventsPlugin fails with Unavailable or Internal errors,
+	// e.g. plugins publishing to other regions. Each is retried and rate limited along with the EventsPlugin
+	EventsFallbacks []middleware.FailoverEndpoint
+
+	// The rate events may be published to each topic, keyed by topic name or middleware.DefaultRateLimitTopic for
+	// topics without their own limit. Publishing is unlimited if empty
+	EventRateLimits map[string]middleware.RateLimit
+	// Whether publishes over the rate limit wait, the default, or fail with a ResourceExhausted error
+	EventRateLimitMode string
+
+	// The number of times a failed event is retried before it is dead-lettered
+	EventRetries int
+	// The queue events that continue to fail are sent to, events are dropped if empty
+	DeadLetterQueue string
+	// The plugin used to dead-letter events, defaults to the QueuePlugin
+	DeadLetterPlugin queue.QueueService
+	// The number of times a dead-lettered event may be replayed from the admin endpoint, defaults to 3
+	DeadLetterMaxReplays int
+
+	// The time in seconds an event ID is remembered for, events re-published to the same topic
+	// with the same ID within this window are skipped. 0 disables deduplication
+	EventIdempotencyWindowSeconds int
+	// The maximum number of event IDs remembered for deduplication, defaults to 10000
+	EventIdempotencyCacheSize int
+
+	// Write published events to an outbox collection with the DocumentPlugin, relaying them to the EventsPlugin
+	// in the background, so events aren't lost if the membrane stops before they're published
+	EventOutbox bool
+	// The document collection events are written to, defaults to nitric-outbox
+	EventOutboxCollection string
+	// The time in milliseconds between polls of the outbox for pending events, defaults to 1000
+	EventOutboxPollIntervalMs int
+	// The number of times an event is relayed before it's marked as failed, defaults to 10. 0 is unlimited
+	EventOutboxMaxAttempts int
 }
 
 type Membrane struct {
@@ -79,6 +246,16 @@ type Membrane struct {
 
 	childTimeoutSeconds int
 
+	shutdownTimeoutSeconds int
+
+	childRestartPolicy      ChildRestartPolicy
+	childMaxRestarts        int
+	childRestartBaseBackoff time.Duration
+
+	// Closed when the membrane is stopped
+	stopped  chan struct{}
+	stopOnce sync.Once
+
 	// Configured plugins
 	documentPlugin document.DocumentService
 	eventsPlugin   events.EventService
@@ -86,13 +263,14 @@ type Membrane struct {
 	gatewayPlugin  gateway.GatewayService
 	queuePlugin    queue.QueueService
 	secretPlugin   secret.SecretService
+	configPlugin   config.ConfigService
 
 	// Tolerate if provider specific plugins aren't available for some services.
 	// Not this does not include the gateway service
 	tolerateMissingServices bool
 
-	// Suppress println statements in the membrane server
-	suppressLogs bool
+	// Structured logger for the membrane server
+	log logger.Logger
 
 	// Handler operating mode, e.g. FaaS or HTTP Proxy. Governs how incoming triggers are translated.
 	mode Mode
@@ -101,18 +279,131 @@ type Membrane struct {
 
 	// Worker pool
 	pool worker.WorkerPool
+
+	grpcServerOptions []grpc.ServerOption
+
+	maxRecvMessageBytes int
+	maxSendMessageBytes int
+
+	maxRequestBodyBytes  int
+	maxResponseBodyBytes int
+
+	enableCompression   bool
+	compressionMinBytes int
+
+	// The content type of responses returned without one, sniffed from their body first if sniffContentType is set
+	defaultContentType string
+	sniffContentType   bool
+
+	// The CORS policy applied to HTTP requests, CORS is disabled if nil
+	corsPolicy *worker.CorsPolicy
+
+	headerAllowList []string
+	headerDenyList  []string
+
+	triggerMiddleware []worker.TriggerMiddleware
+
+	// The policy HTTP requests are logged with, requests aren't logged if nil
+	httpLogPolicy *worker.HttpLogPolicy
+	staticRoutes  map[string]string
+
+	idempotencyTtlSeconds int
+	idempotencyStore      worker.IdempotencyStore
+
+	requestTimeoutSeconds int
+
+	healthCheckAddress string
+	healthServer       *http.Server
+
+	metricsAddress string
+	metricsServer  *http.Server
+	metrics        *worker.Metrics
+
+	adminAddress string
+	adminToken   string
+	adminServer  *http.Server
+
+	// Records plugin circuit state transitions, nil if circuit breaking is disabled
+	circuitBreakerMetrics *middleware.CircuitBreakerMetrics
+	// Records the endpoints event plugin calls are made to, nil if there are no fallbacks
+	failoverMetrics *middleware.FailoverMetrics
+
+	tracerProvider trace.TracerProvider
+
+	eventRetries         int
+	deadLetterQueue      string
+	deadLetterPlugin     queue.QueueService
+	deadLetterMaxReplays int
 }
 
-func (s *Membrane) log(log string) {
-	if !s.suppressLogs {
-		fmt.Println(log)
+// Create the worker pool provided to the gateway, applying the trigger options to the workers it provides
+func (s *Membrane) createGatewayPool() worker.WorkerPool {
+	// Headers are filtered first so the trace and request ID headers injected by the membrane always reach the worker,
+	// tracing is applied next so the dispatch span covers every other decorator
+	decorators := []worker.WorkerDecorator{
+		worker.WithHeaderFilter(s.headerAllowList, s.headerDenyList),
+		worker.WithRequestId(),
+		worker.WithTracing(s.tracerProvider),
+	}
+
+	// Logged after the request ID is assigned so entries can be correlated, and before body limits
+	// so the response is logged as the worker returned it
+	if s.httpLogPolicy != nil {
+		decorators = append(decorators, worker.WithHttpLogging(s.httpLogPolicy, s.log))
+	}
+
+	// Static requests are answered once logged and traced, but don't reach the body limits applied to workers
+	if len(s.staticRoutes) > 0 {
+		decorators = append(decorators, worker.WithStaticFiles(s.storagePlugin, s.staticRoutes))
 	}
+
+	// Replayed responses are logged and traced, and stored once their size has been limited
+	if s.idempotencyTtlSeconds > 0 {
+		decorators = append(decorators, worker.WithIdempotency(s.idempotencyStore, time.Duration(s.idempotencyTtlSeconds)*time.Second, s.log))
+	}
+
+	// Trigger middleware sees requests before their headers are filtered, so auth middleware can check headers
+	// that are denied to the worker
+	if len(s.triggerMiddleware) > 0 {
+		decorators = append([]worker.WorkerDecorator{worker.WithTriggerMiddleware(s.triggerMiddleware...)}, decorators...)
+	}
+
+	// Applied within compression, so content is sniffed before it's compressed and compression sees the content type
+	decorators = append([]worker.WorkerDecorator{worker.WithDefaultContentType(s.defaultContentType, s.sniffContentType)}, decorators...)
+
+	// Compression sees the request before its headers are filtered and compresses the response after its size is limited
+	if s.enableCompression {
+		decorators = append([]worker.WorkerDecorator{worker.WithCompression(s.compressionMinBytes)}, decorators...)
+	}
+
+	// Preflight requests are answered before any other decorator handles them
+	if s.corsPolicy != nil {
+		decorators = append([]worker.WorkerDecorator{worker.WithCors(s.corsPolicy)}, decorators...)
+	}
+
+	if s.maxRequestBodyBytes > 0 || s.maxResponseBodyBytes > 0 {
+		decorators = append(decorators, worker.WithBodyLimits(s.maxRequestBodyBytes, s.maxResponseBodyBytes))
+	}
+
+	if s.eventRetries > 0 || s.deadLetterPlugin != nil {
+		decorators = append(decorators, worker.WithDeadLetterQueue(s.deadLetterPlugin, s.deadLetterQueue, s.eventRetries, s.log))
+	}
+
+	// Applied last so in-flight FaaS triggers can be cancelled when the timeout fires, and the deadlines of HTTP requests
+	// reach the worker. Always applied, as requests may carry a deadline without a timeout being configured
+	decorators = append(decorators, worker.WithRequestTimeout(time.Duration(s.requestTimeoutSeconds)*time.Second))
+
+	return worker.NewDecoratedPool(s.pool, decorators...)
 }
 
 func (s *Membrane) createSecretServer() v1.SecretServiceServer {
 	return grpc2.NewSecretServer(s.secretPlugin)
 }
 
+func (s *Membrane) createConfigServer() v1.ConfigServiceServer {
+	return grpc2.NewConfigServer(s.configPlugin)
+}
+
 // Create a new Nitric Document Server
 func (s *Membrane) createDocumentServer() v1.DocumentServiceServer {
 	return grpc2.NewDocumentServer(s.documentPlugin)
@@ -137,11 +428,16 @@ func (s *Membrane) createQueueServer() v1.QueueServiceServer {
 	return grpc2.NewQueueServiceServer(s.queuePlugin)
 }
 
-func (s *Membrane) startChildProcess() error {
+// Create a new Nitric Capabilities Server, for discovering the operations the registered plugins support
+func (s *Membrane) createCapabilitiesServer() v1.CapabilitiesServiceServer {
+	return grpc2.NewCapabilitiesServer(s.documentPlugin, s.eventsPlugin, s.storagePlugin, s.queuePlugin, s.secretPlugin, s.configPlugin)
+}
+
+func (s *Membrane) startChildProcess() (*exec.Cmd, error) {
 	// TODO: This is a detached process
 	// so it will continue to run until even after the membrane dies
 
-	fmt.Println(fmt.Sprintf("Starting Child Process"))
+	s.log.Info("starting child process", "command", strings.Join(s.childCommand, " "))
 	childProcess := exec.Command(s.childCommand[0], s.childCommand[1:]...)
 	childProcess.Stdout = os.Stdout
 	childProcess.Stderr = os.Stderr
@@ -149,17 +445,33 @@ func (s *Membrane) startChildProcess() error {
 
 	// Actual panic here, we don't want to start if our userland code cannot successfully start
 	if applicationError != nil {
-		return fmt.Errorf("There was an error starting the child process: %v", applicationError)
+		return nil, fmt.Errorf("There was an error starting the child process: %v", applicationError)
 	}
 
-	return nil
+	return childProcess, nil
 }
 
 // Start the membrane
 func (s *Membrane) Start() error {
 	// Search for known plugins
 
-	var opts []grpc.ServerOption
+	opts := []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(
+			grpc2.RecoveryUnaryInterceptor(s.log),
+			grpc2.TracingUnaryInterceptor(s.tracerProvider),
+			grpc2.RequestIdUnaryInterceptor(),
+			grpc2.LoggingUnaryInterceptor(s.log),
+		),
+		grpc.ChainStreamInterceptor(
+			grpc2.RecoveryStreamInterceptor(s.log),
+			grpc2.TracingStreamInterceptor(s.tracerProvider),
+			grpc2.RequestIdStreamInterceptor(),
+			grpc2.LoggingStreamInterceptor(s.log),
+		),
+		grpc.MaxRecvMsgSize(s.maxRecvMessageBytes),
+		grpc.MaxSendMsgSize(s.maxSendMessageBytes),
+	}
+	opts = append(opts, s.grpcServerOptions...)
 	s.grpcServer = grpc.NewServer(opts...)
 
 	// Load & Register the GRPC service plugins
@@ -181,9 +493,24 @@ func (s *Membrane) Start() error {
 	secretServer := s.createSecretServer()
 	v1.RegisterSecretServiceServer(s.grpcServer, secretServer)
 
+	configServer := s.createConfigServer()
+	v1.RegisterConfigServiceServer(s.grpcServer, configServer)
+
+	capabilitiesServer := s.createCapabilitiesServer()
+	v1.RegisterCapabilitiesServiceServer(s.grpcServer, capabilitiesServer)
+
+	// Metrics MUST be created before the FaaS server so workers can record to them
+	if s.metricsAddress != "" {
+		if err := s.startMetricsServer(); err != nil {
+			return fmt.Errorf("Could not listen on configured metrics address: %v", err)
+		}
+		s.log.Info("metrics listening", "address", s.metricsAddress)
+	}
+
 	// FaaS server MUST start before the child process
 	if s.mode == Mode_Faas {
-		faasServer := grpc2.NewFaasServer(s.pool)
+		// Trigger data is chunked at half the max message size, leaving room for the trigger context, e.g. headers
+		faasServer := grpc2.NewFaasServer(s.pool, s.log, s.metrics, s.maxSendMessageBytes/2)
 		v1.RegisterFaasServiceServer(s.grpcServer, faasServer)
 	}
 	lis, err := net.Listen("tcp", s.serviceAddress)
@@ -191,23 +518,40 @@ func (s *Membrane) Start() error {
 		return fmt.Errorf("Could not listen on configured service address: %v", err)
 	}
 
-	s.log("Registered Gateway Plugin")
+	s.log.Debug("registered gateway plugin")
 
 	// Start the gRPC server
 	go (func() {
-		s.log(fmt.Sprintf("Services listening on: %s", s.serviceAddress))
+		s.log.Info("services listening", "address", s.serviceAddress)
 		s.grpcServer.Serve(lis)
 	})()
 
+	if s.healthCheckAddress != "" {
+		if err := s.startHealthCheckServer(); err != nil {
+			return fmt.Errorf("Could not listen on configured health check address: %v", err)
+		}
+		s.log.Info("health checks listening", "address", s.healthCheckAddress)
+	}
+
+	if s.adminAddress != "" {
+		if err := s.startAdminServer(); err != nil {
+			return fmt.Errorf("Could not listen on configured admin address: %v", err)
+		}
+		s.log.Info("admin listening", "address", s.adminAddress, "authenticated", s.adminToken != "")
+	}
+
 	// Start our child process
 	// This will block until our child process is ready to accept incoming connections
+	var childExited *childExit
 	if len(s.childCommand) > 0 {
-		if err := s.startChildProcess(); err != nil {
+		childProcess, err := s.startChildProcess()
+		if err != nil {
 			// Return the error
 			return err
 		}
+		childExited = watchChildProcess(childProcess)
 	} else {
-		s.log("No Child Command Specified, Skipping...")
+		s.log.Info("no child command specified, skipping child process")
 	}
 
 	// If we aren't in FaaS mode
@@ -232,8 +576,8 @@ func (s *Membrane) Start() error {
 
 	// Wait for the minimum number of active workers to be available before beginning the gateway
 	// This ensures workers have registered and can handle triggers as soon the gateway is ready, if a minimum > 1 has been set
-	s.log("Waiting for active workers")
-	err = s.pool.WaitForMinimumWorkers(s.childTimeoutSeconds)
+	s.log.Info("waiting for active workers", "timeoutSeconds", s.childTimeoutSeconds)
+	err = s.waitForChildWorkers(childExited)
 	if err != nil {
 		return err
 	}
@@ -243,42 +587,158 @@ func (s *Membrane) Start() error {
 
 	// Start the gateway
 	go func(errch chan error) {
-		s.log(fmt.Sprintf("Starting Gateway, %d workers currently available", s.pool.GetWorkerCount()))
-		errch <- s.gatewayPlugin.Start(s.pool)
+		s.log.Info("starting gateway", "workers", s.pool.GetWorkerCount())
+		errch <- s.gatewayPlugin.Start(s.createGatewayPool())
 	}(gatewayErrchan)
 
 	// Start the worker pool monitor
 	go func(errch chan error) {
-		s.log("Starting Worker Supervisor")
-		errch <- s.pool.Monitor()
+		s.log.Info("starting worker supervisor")
+		for {
+			errch <- s.pool.Monitor()
+		}
 	}(poolErrchan)
 
+	// Restart the child process if it exits, when a restart policy is configured
+	superviseChild := childExited != nil && s.childRestartPolicy != ChildRestartPolicy_Never
+	childErrchan := make(chan error)
+	if superviseChild {
+		go func(errch chan error) {
+			s.log.Info("starting child process supervisor", "policy", s.childRestartPolicy.String(), "maxRestarts", s.childMaxRestarts)
+			errch <- s.superviseChildProcess(childExited)
+		}(childErrchan)
+	}
+
 	var exitErr error
 
 	// Wait and fail on either
-	select {
-	case gatewayErr := <-gatewayErrchan:
-		if err == nil {
-			// Normal Gateway shutdown
-			// Allowing the membrane to exit
-			return nil
+	for exitErr == nil {
+		select {
+		case gatewayErr := <-gatewayErrchan:
+			if err == nil {
+				// Normal Gateway shutdown
+				// Allowing the membrane to exit
+				return nil
+			}
+			exitErr = fmt.Errorf(fmt.Sprintf("Gateway Error: %v, exiting", gatewayErr))
+		case poolErr := <-poolErrchan:
+			if superviseChild {
+				// Workers are expected to disconnect while the child process restarts,
+				// the child supervisor fails if they don't reconnect
+				s.log.Warn("worker pool below minimum, waiting for child process", "error", poolErr)
+				continue
+			}
+			exitErr = fmt.Errorf(fmt.Sprintf("Supervisor error: %v, exiting", poolErr))
+		case childErr := <-childErrchan:
+			if childErr == nil {
+				// The child process has finished, allowing the membrane to exit
+				return nil
+			}
+			exitErr = fmt.Errorf(fmt.Sprintf("Child process error: %v, exiting", childErr))
 		}
-		exitErr = fmt.Errorf(fmt.Sprintf("Gateway Error: %v, exiting", gatewayErr))
-	case poolErr := <-poolErrchan:
-		exitErr = fmt.Errorf(fmt.Sprintf("Supervisor error: %v, exiting", poolErr))
 	}
 
 	return exitErr
 }
 
-func (s *Membrane) Stop() {
-	s.gatewayPlugin.Stop()
-	s.grpcServer.Stop()
+// Stop - Stops the gateway, then drains in-flight triggers from the worker pool before stopping the membrane services
+func (s *Membrane) Stop() error {
+	stopErrors := make([]error, 0)
+
+	s.stopOnce.Do(func() {
+		close(s.stopped)
+	})
+
+	if err := s.gatewayPlugin.Stop(); err != nil {
+		stopErrors = append(stopErrors, fmt.Errorf("gateway: %v", err))
+	}
+
+	if err := s.pool.Shutdown(s.shutdownTimeoutSeconds); err != nil {
+		stopErrors = append(stopErrors, fmt.Errorf("worker pool: %v", err))
+	}
+
+	if s.grpcServer != nil {
+		s.grpcServer.Stop()
+	}
+
+	// Plugins are only closed once nothing can call them, after intake has stopped and the workers have drained
+	stopErrors = append(stopErrors, s.closePlugins()...)
+
+	if s.healthServer != nil {
+		if err := s.healthServer.Close(); err != nil {
+			stopErrors = append(stopErrors, fmt.Errorf("health check server: %v", err))
+		}
+	}
+
+	if s.metricsServer != nil {
+		if err := s.metricsServer.Close(); err != nil {
+			stopErrors = append(stopErrors, fmt.Errorf("metrics server: %v", err))
+		}
+	}
+
+	if s.adminServer != nil {
+		if err := s.adminServer.Close(); err != nil {
+			stopErrors = append(stopErrors, fmt.Errorf("admin server: %v", err))
+		}
+	}
+
+	if len(stopErrors) > 0 {
+		return fmt.Errorf("errors occurred stopping the membrane: %v", stopErrors)
+	}
+
+	return nil
+}
+
+// namedCloser - A plugin to close on shutdown, named for its errors
+type namedCloser struct {
+	name   string
+	closer io.Closer
+}
+
+// closePlugins - Closes the configured plugins in a fixed order, returning the errors of any that failed to close
+func (s *Membrane) closePlugins() []error {
+	// Events are closed first, as the event outbox is relayed using the document plugin
+	plugins := []namedCloser{
+		{"events plugin", s.eventsPlugin},
+		{"document plugin", s.documentPlugin},
+		{"queue plugin", s.queuePlugin},
+		{"storage plugin", s.storagePlugin},
+		{"secret plugin", s.secretPlugin},
+	}
+
+	// The dead letter plugin is the queue plugin unless one was provided separately
+	if s.deadLetterPlugin != nil && s.deadLetterPlugin != s.queuePlugin {
+		plugins = append(plugins, namedCloser{"dead letter plugin", s.deadLetterPlugin})
+	}
+
+	closeErrors := make([]error, 0)
+	for _, p := range plugins {
+		if p.closer == nil {
+			continue
+		}
+
+		if err := p.closer.Close(); err != nil {
+			closeErrors = append(closeErrors, fmt.Errorf("%s: %v", p.name, err))
+		}
+	}
+
+	return closeErrors
 }
 
 // Create a new Membrane server
 func New(options *MembraneOptions) (*Membrane, error) {
 
+	if options.SuppressLogs {
+		options.Logger = logger.NewNoopLogger()
+	} else if options.Logger == nil {
+		logLevelEnv := utils.GetEnv("LOG_LEVEL", "INFO")
+		logLevel, err := logger.LevelFromString(logLevelEnv)
+		if err != nil {
+			return nil, fmt.Errorf("invalid LOG_LEVEL env var: %v", err)
+		}
+		options.Logger = logger.NewJSONLogger(os.Stdout, logLevel)
+	}
+
 	// Get unset options from env or defaults
 	if options.ServiceAddress == "" {
 		options.ServiceAddress = utils.GetEnv("SERVICE_ADDRESS", "127.0.0.1:50051")
@@ -296,7 +756,7 @@ func New(options *MembraneOptions) (*Membrane, error) {
 		} else {
 			options.ChildCommand = strings.Fields(utils.GetEnv("INVOKE", ""))
 			if len(options.ChildCommand) > 0 {
-				fmt.Println("Warning: use of INVOKE environment variable is deprecated and may be removed in a future version")
+				options.Logger.Warn("use of INVOKE environment variable is deprecated and may be removed in a future version")
 			}
 		}
 	}
@@ -317,16 +777,565 @@ func New(options *MembraneOptions) (*Membrane, error) {
 		options.Mode = &mode
 	}
 
+	if options.ChildRestartPolicy == nil {
+		policy, err := ChildRestartPolicyFromString(utils.GetEnv("CHILD_RESTART_POLICY", "NEVER"))
+		if err != nil {
+			return nil, err
+		}
+		options.ChildRestartPolicy = &policy
+	}
+
+	if options.ChildMaxRestarts < 1 {
+		childMaxRestartsEnv := utils.GetEnv("CHILD_MAX_RESTARTS", "5")
+		childMaxRestarts, err := strconv.Atoi(childMaxRestartsEnv)
+		if err != nil || childMaxRestarts < 0 {
+			return nil, fmt.Errorf("invalid CHILD_MAX_RESTARTS env var, expected non-negative integer value, got %v", childMaxRestartsEnv)
+		}
+		options.ChildMaxRestarts = childMaxRestarts
+	}
+
 	if options.ChildTimeoutSeconds < 1 {
 		options.ChildTimeoutSeconds = 10
 	}
 
+	if options.ShutdownTimeoutSeconds < 1 {
+		options.ShutdownTimeoutSeconds = 10
+	}
+
+	if options.RequestTimeoutSeconds < 1 {
+		requestTimeoutEnv := utils.GetEnv("REQUEST_TIMEOUT_SECONDS", "0")
+		requestTimeoutSeconds, err := strconv.Atoi(requestTimeoutEnv)
+		if err != nil || requestTimeoutSeconds < 0 {
+			return nil, fmt.Errorf("invalid REQUEST_TIMEOUT_SECONDS env var, expected non-negative integer value, got %v", requestTimeoutEnv)
+		}
+		options.RequestTimeoutSeconds = requestTimeoutSeconds
+	}
+
+	if options.MaxRecvMessageBytes < 1 {
+		maxRecvMessageBytesEnv := utils.GetEnv("MAX_RECV_MESSAGE_BYTES", strconv.Itoa(DefaultMaxMessageBytes))
+		maxRecvMessageBytes, err := strconv.Atoi(maxRecvMessageBytesEnv)
+		if err != nil || maxRecvMessageBytes < 1 {
+			return nil, fmt.Errorf("invalid MAX_RECV_MESSAGE_BYTES env var, expected positive integer value, got %v", maxRecvMessageBytesEnv)
+		}
+		options.MaxRecvMessageBytes = maxRecvMessageBytes
+	}
+
+	if options.MaxSendMessageBytes < 1 {
+		maxSendMessageBytesEnv := utils.GetEnv("MAX_SEND_MESSAGE_BYTES", strconv.Itoa(DefaultMaxMessageBytes))
+		maxSendMessageBytes, err := strconv.Atoi(maxSendMessageBytesEnv)
+		if err != nil || maxSendMessageBytes < 1 {
+			return nil, fmt.Errorf("invalid MAX_SEND_MESSAGE_BYTES env var, expected positive integer value, got %v", maxSendMessageBytesEnv)
+		}
+		options.MaxSendMessageBytes = maxSendMessageBytes
+	}
+
+	if options.MaxRequestBodyBytes < 1 {
+		maxRequestBodyBytesEnv := utils.GetEnv("MAX_REQUEST_BODY_BYTES", "0")
+		maxRequestBodyBytes, err := strconv.Atoi(maxRequestBodyBytesEnv)
+		if err != nil || maxRequestBodyBytes < 0 {
+			return nil, fmt.Errorf("invalid MAX_REQUEST_BODY_BYTES env var, expected non-negative integer value, got %v", maxRequestBodyBytesEnv)
+		}
+		options.MaxRequestBodyBytes = maxRequestBodyBytes
+	}
+
+	if options.MaxResponseBodyBytes < 1 {
+		maxResponseBodyBytesEnv := utils.GetEnv("MAX_RESPONSE_BODY_BYTES", "0")
+		maxResponseBodyBytes, err := strconv.Atoi(maxResponseBodyBytesEnv)
+		if err != nil || maxResponseBodyBytes < 0 {
+			return nil, fmt.Errorf("invalid MAX_RESPONSE_BODY_BYTES env var, expected non-negative integer value, got %v", maxResponseBodyBytesEnv)
+		}
+		options.MaxResponseBodyBytes = maxResponseBodyBytes
+	}
+
+	if !options.EnableCompression {
+		enableCompression, err := strconv.ParseBool(utils.GetEnv("ENABLE_COMPRESSION", "false"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid ENABLE_COMPRESSION env var, expected boolean value: %v", err)
+		}
+		options.EnableCompression = enableCompression
+	}
+
+	if options.CompressionMinBytes < 1 {
+		compressionMinBytesEnv := utils.GetEnv("COMPRESSION_MIN_BYTES", strconv.Itoa(worker.DefaultCompressionMinBytes))
+		compressionMinBytes, err := strconv.Atoi(compressionMinBytesEnv)
+		if err != nil || compressionMinBytes < 0 {
+			return nil, fmt.Errorf("invalid COMPRESSION_MIN_BYTES env var, expected non-negative integer value, got %v", compressionMinBytesEnv)
+		}
+		options.CompressionMinBytes = compressionMinBytes
+	}
+
+	if options.DefaultResponseContentType == "" {
+		options.DefaultResponseContentType = utils.GetEnv("DEFAULT_RESPONSE_CONTENT_TYPE", worker.DefaultResponseContentType)
+	}
+
+	if !options.SniffContentType {
+		sniffContentType, err := strconv.ParseBool(utils.GetEnv("SNIFF_CONTENT_TYPE", "false"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid SNIFF_CONTENT_TYPE env var, expected boolean value: %v", err)
+		}
+		options.SniffContentType = sniffContentType
+	}
+
+	if len(options.CorsAllowedOrigins) == 0 {
+		options.CorsAllowedOrigins = utils.GetEnvList("CORS_ALLOWED_ORIGINS")
+	}
+
+	if len(options.CorsAllowedMethods) == 0 {
+		options.CorsAllowedMethods = utils.GetEnvList("CORS_ALLOWED_METHODS")
+	}
+
+	if len(options.CorsAllowedHeaders) == 0 {
+		options.CorsAllowedHeaders = utils.GetEnvList("CORS_ALLOWED_HEADERS")
+	}
+
+	if len(options.CorsExposedHeaders) == 0 {
+		options.CorsExposedHeaders = utils.GetEnvList("CORS_EXPOSED_HEADERS")
+	}
+
+	if !options.CorsAllowCredentials {
+		allowCredentials, err := strconv.ParseBool(utils.GetEnv("CORS_ALLOW_CREDENTIALS", "false"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid CORS_ALLOW_CREDENTIALS env var, expected boolean value: %v", err)
+		}
+		options.CorsAllowCredentials = allowCredentials
+	}
+
+	if options.CorsMaxAgeSeconds < 1 {
+		corsMaxAgeEnv := utils.GetEnv("CORS_MAX_AGE_SECONDS", "0")
+		corsMaxAge, err := strconv.Atoi(corsMaxAgeEnv)
+		if err != nil || corsMaxAge < 0 {
+			return nil, fmt.Errorf("invalid CORS_MAX_AGE_SECONDS env var, expected non-negative integer value, got %v", corsMaxAgeEnv)
+		}
+		options.CorsMaxAgeSeconds = corsMaxAge
+	}
+
+	var corsPolicy *worker.CorsPolicy
+	if len(options.CorsAllowedOrigins) > 0 {
+		policy, err := worker.NewCorsPolicy(&worker.CorsOptions{
+			AllowedOrigins:   options.CorsAllowedOrigins,
+			AllowedMethods:   options.CorsAllowedMethods,
+			AllowedHeaders:   options.CorsAllowedHeaders,
+			ExposedHeaders:   options.CorsExposedHeaders,
+			AllowCredentials: options.CorsAllowCredentials,
+			MaxAgeSeconds:    options.CorsMaxAgeSeconds,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("invalid CORS configuration: %v", err)
+		}
+		corsPolicy = policy
+	}
+
+	if len(options.HeaderAllowList) == 0 {
+		options.HeaderAllowList = utils.GetEnvList("HEADER_ALLOW_LIST")
+	}
+
+	if len(options.HeaderDenyList) == 0 {
+		options.HeaderDenyList = utils.GetEnvList("HEADER_DENY_LIST")
+	}
+
+	if options.HttpLogging == nil {
+		httpLogging, err := strconv.ParseBool(utils.GetEnv("HTTP_LOGGING", "false"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid HTTP_LOGGING env var, expected boolean value: %v", err)
+		}
+
+		if httpLogging {
+			httpLogBodyBytesEnv := utils.GetEnv("HTTP_LOG_BODY_BYTES", strconv.Itoa(worker.DefaultHttpLogBodyBytes))
+			httpLogBodyBytes, err := strconv.Atoi(httpLogBodyBytesEnv)
+			if err != nil {
+				return nil, fmt.Errorf("invalid HTTP_LOG_BODY_BYTES env var, expected integer value, got %v", httpLogBodyBytesEnv)
+			}
+
+			options.HttpLogging = &worker.HttpLoggingOptions{
+				Headers:        utils.GetEnvList("HTTP_LOG_HEADERS"),
+				RedactHeaders:  utils.GetEnvList("HTTP_LOG_REDACT_HEADERS"),
+				RedactBodyKeys: utils.GetEnv("HTTP_LOG_REDACT_BODY_KEYS", ""),
+				BodyBytes:      httpLogBodyBytes,
+			}
+		}
+	}
+
+	var httpLogPolicy *worker.HttpLogPolicy
+	if options.HttpLogging != nil {
+		policy, err := worker.NewHttpLogPolicy(options.HttpLogging)
+		if err != nil {
+			return nil, fmt.Errorf("invalid HTTP logging configuration: %v", err)
+		}
+		httpLogPolicy = policy
+	}
+
+	if options.StaticRoutes == nil {
+		staticRoutes, err := worker.ParseStaticRoutes(utils.GetEnv("STATIC_ROUTES", ""))
+		if err != nil {
+			return nil, fmt.Errorf("invalid STATIC_ROUTES env var: %v", err)
+		}
+		options.StaticRoutes = staticRoutes
+	}
+
+	if len(options.StaticRoutes) > 0 && options.StoragePlugin == nil {
+		return nil, fmt.Errorf("static routes require a storage plugin")
+	}
+
+	if options.Authorizer == nil {
+		options.Authorizer = &worker.NoopAuthorizer{}
+
+		if jwksUrl := utils.GetEnv("AUTH_JWKS_URL", ""); jwksUrl != "" {
+			leewayEnv := utils.GetEnv("AUTH_JWT_LEEWAY_SECONDS", "0")
+			leeway, err := strconv.Atoi(leewayEnv)
+			if err != nil || leeway < 0 {
+				return nil, fmt.Errorf("invalid AUTH_JWT_LEEWAY_SECONDS env var, expected non-negative integer value, got %v", leewayEnv)
+			}
+
+			authorizer, err := worker.NewJwtAuthorizer(&worker.JwtOptions{
+				JwksUrl:   jwksUrl,
+				Issuer:    utils.GetEnv("AUTH_JWT_ISSUER", ""),
+				Audiences: utils.GetEnvList("AUTH_JWT_AUDIENCES"),
+				Leeway:    time.Duration(leeway) * time.Second,
+			})
+			if err != nil {
+				return nil, fmt.Errorf("invalid JWT authorizer configuration: %v", err)
+			}
+			options.Authorizer = authorizer
+		}
+	}
+
+	// Requests are authorized before any other middleware handles them
+	if _, noop := options.Authorizer.(*worker.NoopAuthorizer); !noop {
+		options.TriggerMiddleware = append([]worker.TriggerMiddleware{worker.Authorize(options.Authorizer)}, options.TriggerMiddleware...)
+	}
+
+	if options.IdempotencyTtlSeconds < 1 {
+		idempotencyTtlEnv := utils.GetEnv("IDEMPOTENCY_TTL_SECONDS", "0")
+		idempotencyTtl, err := strconv.Atoi(idempotencyTtlEnv)
+		if err != nil || idempotencyTtl < 0 {
+			return nil, fmt.Errorf("invalid IDEMPOTENCY_TTL_SECONDS env var, expected non-negative integer value, got %v", idempotencyTtlEnv)
+		}
+		options.IdempotencyTtlSeconds = idempotencyTtl
+	}
+
+	if options.IdempotencyTtlSeconds > 0 && options.IdempotencyStore == nil {
+		switch store := utils.GetEnv("IDEMPOTENCY_STORE", "memory"); store {
+		case "memory":
+			cacheSizeEnv := utils.GetEnv("IDEMPOTENCY_CACHE_SIZE", "10000")
+			cacheSize, err := strconv.Atoi(cacheSizeEnv)
+			if err != nil || cacheSize < 1 {
+				return nil, fmt.Errorf("invalid IDEMPOTENCY_CACHE_SIZE env var, expected positive integer value, got %v", cacheSizeEnv)
+			}
+			options.IdempotencyStore = worker.NewMemoryIdempotencyStore(cacheSize)
+		case "document":
+			if options.DocumentPlugin == nil {
+				return nil, fmt.Errorf("Missing document plugin, a document plugin is required to store idempotency keys")
+			}
+			options.IdempotencyStore = worker.NewDocumentIdempotencyStore(options.DocumentPlugin)
+		default:
+			return nil, fmt.Errorf("invalid IDEMPOTENCY_STORE env var, expected memory or document, got %v", store)
+		}
+	}
+
+	tlsOptions := &gateway.TlsOptions{
+		CertFile:     options.TlsCertFile,
+		KeyFile:      options.TlsKeyFile,
+		CertPEM:      options.TlsCertPEM,
+		KeyPEM:       options.TlsKeyPEM,
+		MinVersion:   options.TlsMinVersion,
+		ClientCAFile: options.TlsClientCAFile,
+	}
+
+	if tlsOptions.CertFile == "" && len(tlsOptions.CertPEM) == 0 {
+		tlsOptions.CertFile = utils.GetEnv("TLS_CERT_FILE", "")
+		tlsOptions.KeyFile = utils.GetEnv("TLS_KEY_FILE", "")
+	}
+
+	if tlsOptions.MinVersion == "" {
+		tlsOptions.MinVersion = utils.GetEnv("TLS_MIN_VERSION", "1.2")
+	}
+
+	if tlsOptions.ClientCAFile == "" {
+		tlsOptions.ClientCAFile = utils.GetEnv("TLS_CLIENT_CA_FILE", "")
+	}
+
+	if tlsOptions.Enabled() {
+		tlsGateway, ok := options.GatewayPlugin.(gateway.TlsGateway)
+		if !ok {
+			return nil, fmt.Errorf("TLS is configured, but the gateway plugin does not support TLS")
+		}
+
+		tlsConfig, err := gateway.NewTlsConfig(tlsOptions)
+		if err != nil {
+			return nil, err
+		}
+
+		tlsGateway.SetTlsConfig(tlsConfig)
+	}
+
+	if !options.GatewayH2c {
+		h2cEnv := utils.GetEnv("GATEWAY_H2C", "false")
+		gatewayH2c, err := strconv.ParseBool(h2cEnv)
+		if err != nil {
+			return nil, fmt.Errorf("invalid GATEWAY_H2C env var, expected boolean value, got %v", h2cEnv)
+		}
+		options.GatewayH2c = gatewayH2c
+	}
+
+	if options.GatewayH2c {
+		h2cGateway, ok := options.GatewayPlugin.(gateway.H2cGateway)
+		if !ok {
+			return nil, fmt.Errorf("h2c is enabled, but the gateway plugin does not support HTTP/2")
+		}
+
+		h2cGateway.SetH2c(true)
+	}
+
+	if options.HealthCheckAddress == "" {
+		options.HealthCheckAddress = utils.GetEnv("HEALTH_CHECK_ADDRESS", "")
+	}
+
+	if options.MetricsAddress == "" {
+		options.MetricsAddress = utils.GetEnv("METRICS_ADDRESS", "")
+	}
+
+	if options.AdminAddress == "" {
+		options.AdminAddress = utils.GetEnv("ADMIN_ADDRESS", "")
+	}
+
+	if options.AdminToken == "" {
+		options.AdminToken = utils.GetEnv("ADMIN_TOKEN", "")
+	}
+
+	if options.PluginRetries < 1 {
+		pluginRetriesEnv := utils.GetEnv("PLUGIN_RETRIES", "0")
+		pluginRetries, err := strconv.Atoi(pluginRetriesEnv)
+		if err != nil || pluginRetries < 0 {
+			return nil, fmt.Errorf("invalid PLUGIN_RETRIES env var, expected non-negative integer value, got %v", pluginRetriesEnv)
+		}
+		options.PluginRetries = pluginRetries
+	}
+
+	if options.PluginRetryBackoffMs < 1 {
+		pluginRetryBackoffEnv := utils.GetEnv("PLUGIN_RETRY_BACKOFF_MS", "100")
+		pluginRetryBackoff, err := strconv.Atoi(pluginRetryBackoffEnv)
+		if err != nil || pluginRetryBackoff < 0 {
+			return nil, fmt.Errorf("invalid PLUGIN_RETRY_BACKOFF_MS env var, expected non-negative integer value, got %v", pluginRetryBackoffEnv)
+		}
+		options.PluginRetryBackoffMs = pluginRetryBackoff
+	}
+
+	// Retries wrap the plugins first, so the dead-letter queue and event deduplication use the retrying plugins
+	if options.PluginRetries > 0 {
+		retryPolicy := middleware.DefaultRetryPolicy()
+		retryPolicy.MaxRetries = options.PluginRetries
+		retryPolicy.BaseBackoff = time.Duration(options.PluginRetryBackoffMs) * time.Millisecond
+
+		if options.EventsPlugin != nil {
+			options.EventsPlugin = middleware.EventsWithRetry(options.EventsPlugin, retryPolicy)
+		}
+
+		for i, fallback := range options.EventsFallbacks {
+			options.EventsFallbacks[i].Plugin = middleware.EventsWithRetry(fallback.Plugin, retryPolicy)
+		}
+
+		if options.QueuePlugin != nil {
+			options.QueuePlugin = middleware.QueueWithRetry(options.QueuePlugin, retryPolicy)
+		}
+
+		if options.StoragePlugin != nil {
+			options.StoragePlugin = middleware.StorageWithRetry(options.StoragePlugin, retryPolicy)
+		}
+	}
+
+	if options.PluginCircuitBreakerThreshold < 1 {
+		thresholdEnv := utils.GetEnv("PLUGIN_CIRCUIT_BREAKER_THRESHOLD", "0")
+		threshold, err := strconv.Atoi(thresholdEnv)
+		if err != nil || threshold < 0 {
+			return nil, fmt.Errorf("invalid PLUGIN_CIRCUIT_BREAKER_THRESHOLD env var, expected non-negative integer value, got %v", thresholdEnv)
+		}
+		options.PluginCircuitBreakerThreshold = threshold
+	}
+
+	if options.PluginCircuitBreakerOpenSeconds < 1 {
+		openSecondsEnv := utils.GetEnv("PLUGIN_CIRCUIT_BREAKER_OPEN_SECONDS", "30")
+		openSeconds, err := strconv.Atoi(openSecondsEnv)
+		if err != nil || openSeconds < 1 {
+			return nil, fmt.Errorf("invalid PLUGIN_CIRCUIT_BREAKER_OPEN_SECONDS env var, expected positive integer value, got %v", openSecondsEnv)
+		}
+		options.PluginCircuitBreakerOpenSeconds = openSeconds
+	}
+
+	// Circuit breakers wrap the retrying plugins, so a call counts as a single failure once its retries are exhausted
+	var circuitBreakerMetrics *middleware.CircuitBreakerMetrics
+	if options.PluginCircuitBreakerThreshold > 0 {
+		circuitBreakerMetrics = middleware.NewCircuitBreakerMetrics()
+		policy := middleware.DefaultCircuitBreakerPolicy()
+		policy.FailureThreshold = options.PluginCircuitBreakerThreshold
+		policy.OpenDuration = time.Duration(options.PluginCircuitBreakerOpenSeconds) * time.Second
+
+		if options.EventsPlugin != nil {
+			options.EventsPlugin = middleware.EventsWithCircuitBreaker(options.EventsPlugin, policy, circuitBreakerMetrics)
+		}
+
+		if options.QueuePlugin != nil {
+			options.QueuePlugin = middleware.QueueWithCircuitBreaker(options.QueuePlugin, policy, circuitBreakerMetrics)
+		}
+	}
+
+	// Failover wraps the circuit breaker, so calls fail over immediately while the primary's circuit is open
+	var failoverMetrics *middleware.FailoverMetrics
+	if len(options.EventsFallbacks) > 0 && options.EventsPlugin != nil {
+		for _, fallback := range options.EventsFallbacks {
+			if fallback.Name == "" || fallback.Plugin == nil {
+				return nil, fmt.Errorf("events fallbacks require a name and plugin")
+			}
+		}
+
+		failoverMetrics = middleware.NewFailoverMetrics()
+		options.EventsPlugin = middleware.EventsWithFailover(options.EventsPlugin, options.EventsFallbacks, failoverMetrics)
+	}
+
+	if options.EventRateLimits == nil {
+		rateLimits, err := middleware.ParseRateLimits(utils.GetEnv("EVENT_RATE_LIMITS", ""))
+		if err != nil {
+			return nil, fmt.Errorf("invalid EVENT_RATE_LIMITS env var: %v", err)
+		}
+		options.EventRateLimits = rateLimits
+	}
+
+	if options.EventRateLimitMode == "" {
+		options.EventRateLimitMode = utils.GetEnv("EVENT_RATE_LIMIT_MODE", middleware.RateLimitMode_Block.String())
+	}
+
+	// Rate limits wrap the other middleware, so publishes rejected by the limit aren't retried or counted as plugin failures
+	if len(options.EventRateLimits) > 0 && options.EventsPlugin != nil {
+		mode, err := middleware.RateLimitModeFromString(options.EventRateLimitMode)
+		if err != nil {
+			return nil, fmt.Errorf("invalid EVENT_RATE_LIMIT_MODE env var: %v", err)
+		}
+
+		options.EventsPlugin = middleware.EventsWithRateLimit(options.EventsPlugin, &middleware.RateLimitPolicy{
+			Limits: options.EventRateLimits,
+			Mode:   mode,
+		})
+	}
+
+	if options.EventRetries < 1 {
+		eventRetriesEnv := utils.GetEnv("EVENT_RETRIES", "0")
+		eventRetries, err := strconv.Atoi(eventRetriesEnv)
+		if err != nil || eventRetries < 0 {
+			return nil, fmt.Errorf("invalid EVENT_RETRIES env var, expected non-negative integer value, got %v", eventRetriesEnv)
+		}
+		options.EventRetries = eventRetries
+	}
+
+	if options.DeadLetterQueue == "" {
+		options.DeadLetterQueue = utils.GetEnv("DEAD_LETTER_QUEUE", "")
+	}
+
+	if options.DeadLetterQueue != "" && options.DeadLetterPlugin == nil {
+		options.DeadLetterPlugin = options.QueuePlugin
+	}
+
+	if options.DeadLetterPlugin != nil && options.DeadLetterQueue == "" {
+		return nil, fmt.Errorf("A dead-letter queue name must be provided with a dead-letter plugin")
+	}
+
+	if options.DeadLetterQueue != "" && options.DeadLetterPlugin == nil {
+		return nil, fmt.Errorf("Missing queue plugin, a queue plugin is required to dead-letter events to %s", options.DeadLetterQueue)
+	}
+
+	if options.DeadLetterMaxReplays < 1 {
+		maxReplaysEnv := utils.GetEnv("DEAD_LETTER_MAX_REPLAYS", "3")
+		maxReplays, err := strconv.Atoi(maxReplaysEnv)
+		if err != nil || maxReplays < 0 {
+			return nil, fmt.Errorf("invalid DEAD_LETTER_MAX_REPLAYS env var, expected non-negative integer value, got %v", maxReplaysEnv)
+		}
+		options.DeadLetterMaxReplays = maxReplays
+	}
+
+	// Config is optional, so it isn't required when missing services aren't tolerated
+	if options.ConfigPlugin == nil {
+		options.ConfigPlugin = &config.UnimplementedConfigPlugin{}
+	}
+
+	if options.EventIdempotencyWindowSeconds < 1 {
+		eventIdempotencyWindowEnv := utils.GetEnv("EVENT_IDEMPOTENCY_WINDOW_SECONDS", "0")
+		eventIdempotencyWindow, err := strconv.Atoi(eventIdempotencyWindowEnv)
+		if err != nil || eventIdempotencyWindow < 0 {
+			return nil, fmt.Errorf("invalid EVENT_IDEMPOTENCY_WINDOW_SECONDS env var, expected non-negative integer value, got %v", eventIdempotencyWindowEnv)
+		}
+		options.EventIdempotencyWindowSeconds = eventIdempotencyWindow
+	}
+
+	if options.EventIdempotencyCacheSize < 1 {
+		eventIdempotencyCacheSizeEnv := utils.GetEnv("EVENT_IDEMPOTENCY_CACHE_SIZE", "10000")
+		eventIdempotencyCacheSize, err := strconv.Atoi(eventIdempotencyCacheSizeEnv)
+		if err != nil || eventIdempotencyCacheSize < 1 {
+			return nil, fmt.Errorf("invalid EVENT_IDEMPOTENCY_CACHE_SIZE env var, expected positive integer value, got %v", eventIdempotencyCacheSizeEnv)
+		}
+		options.EventIdempotencyCacheSize = eventIdempotencyCacheSize
+	}
+
+	if !options.EventOutbox {
+		eventOutbox, err := strconv.ParseBool(utils.GetEnv("EVENT_OUTBOX", "false"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid EVENT_OUTBOX env var, expected boolean value: %v", err)
+		}
+		options.EventOutbox = eventOutbox
+	}
+
+	if options.EventOutboxCollection == "" {
+		options.EventOutboxCollection = utils.GetEnv("EVENT_OUTBOX_COLLECTION", middleware.DefaultOutboxPolicy().Collection)
+	}
+
+	if options.EventOutboxPollIntervalMs < 1 {
+		pollIntervalEnv := utils.GetEnv("EVENT_OUTBOX_POLL_INTERVAL_MS", "1000")
+		pollInterval, err := strconv.Atoi(pollIntervalEnv)
+		if err != nil || pollInterval < 1 {
+			return nil, fmt.Errorf("invalid EVENT_OUTBOX_POLL_INTERVAL_MS env var, expected positive integer value, got %v", pollIntervalEnv)
+		}
+		options.EventOutboxPollIntervalMs = pollInterval
+	}
+
+	if options.EventOutboxMaxAttempts < 1 {
+		maxAttemptsEnv := utils.GetEnv("EVENT_OUTBOX_MAX_ATTEMPTS", strconv.Itoa(middleware.DefaultOutboxPolicy().MaxAttempts))
+		maxAttempts, err := strconv.Atoi(maxAttemptsEnv)
+		if err != nil || maxAttempts < 0 {
+			return nil, fmt.Errorf("invalid EVENT_OUTBOX_MAX_ATTEMPTS env var, expected non-negative integer value, got %v", maxAttemptsEnv)
+		}
+		options.EventOutboxMaxAttempts = maxAttempts
+	}
+
+	// The outbox wraps the other middleware, so they apply as events are relayed, not as they're written
+	if options.EventOutbox && options.EventsPlugin != nil {
+		if options.DocumentPlugin == nil {
+			return nil, fmt.Errorf("Missing document plugin, a document plugin is required to write events to the outbox")
+		}
+
+		policy := middleware.DefaultOutboxPolicy()
+		policy.Collection = options.EventOutboxCollection
+		policy.PollInterval = time.Duration(options.EventOutboxPollIntervalMs) * time.Millisecond
+		policy.MaxAttempts = options.EventOutboxMaxAttempts
+
+		options.EventsPlugin = middleware.EventsWithOutbox(options.EventsPlugin, options.DocumentPlugin, policy)
+	}
+
+	// Deduplication wraps the outbox, so duplicate events aren't written to it
+	if options.EventsPlugin != nil && options.EventIdempotencyWindowSeconds > 0 {
+		options.EventsPlugin = events.NewIdempotentEventService(
+			options.EventsPlugin,
+			time.Duration(options.EventIdempotencyWindowSeconds)*time.Second,
+			options.EventIdempotencyCacheSize,
+		)
+	}
+
+	if options.TracerProvider == nil {
+		options.TracerProvider = trace.NewNoopTracerProvider()
+	}
+
 	if options.GatewayPlugin == nil {
 		return nil, fmt.Errorf("Missing gateway plugin, Gateway plugin must not be nil")
 	}
 
 	if !options.TolerateMissingServices {
-		if options.EventsPlugin == nil || options.StoragePlugin == nil || options.DocumentPlugin == nil || options.QueuePlugin == nil {
+		if options.EventsPlugin == nil || options.StoragePlugin == nil || options.DocumentPlugin == nil || options.QueuePlugin == nil || options.SecretPlugin == nil {
 			return nil, fmt.Errorf("Missing membrane plugins, if you meant to load with missing plugins set options.TolerateMissingServices to true")
 		}
 	}
@@ -345,9 +1354,33 @@ func New(options *MembraneOptions) (*Membrane, error) {
 			return nil, fmt.Errorf("invalid MAX_WORKERS env var, expected non-negative integer value, got %v", maxWorkersEnv)
 		}
 
+		maxConcurrencyEnv := utils.GetEnv("MAX_CONCURRENCY", "0")
+		maxConcurrency, err := strconv.Atoi(maxConcurrencyEnv)
+		if err != nil || maxConcurrency < 0 {
+			return nil, fmt.Errorf("invalid MAX_CONCURRENCY env var, expected non-negative integer value, got %v", maxConcurrencyEnv)
+		}
+
+		maxPendingEnv := utils.GetEnv("MAX_PENDING_TRIGGERS", "0")
+		maxPending, err := strconv.Atoi(maxPendingEnv)
+		if err != nil || maxPending < 0 {
+			return nil, fmt.Errorf("invalid MAX_PENDING_TRIGGERS env var, expected non-negative integer value, got %v", maxPendingEnv)
+		}
+
+		requireWorkerReadyEnv := utils.GetEnv("REQUIRE_WORKER_READY", "false")
+		requireWorkerReady, err := strconv.ParseBool(requireWorkerReadyEnv)
+		if err != nil {
+			return nil, fmt.Errorf("invalid REQUIRE_WORKER_READY env var, expected boolean value, got %v", requireWorkerReadyEnv)
+		}
+
 		options.Pool = worker.NewProcessPool(&worker.ProcessPoolOptions{
-			MinWorkers: minWorkers,
-			MaxWorkers: maxWorkers,
+			MinWorkers:     minWorkers,
+			MaxWorkers:     maxWorkers,
+			MaxConcurrency: maxConcurrency,
+			// Hold triggers until a worker is free, rather than failing them
+			Blocking:     true,
+			Logger:       options.Logger,
+			RequireReady: requireWorkerReady,
+			MaxPending:   maxPending,
 		})
 	}
 
@@ -357,15 +1390,50 @@ func New(options *MembraneOptions) (*Membrane, error) {
 		childUrl:                fmt.Sprintf("http://%s", options.ChildAddress),
 		childCommand:            options.ChildCommand,
 		childTimeoutSeconds:     options.ChildTimeoutSeconds,
+		shutdownTimeoutSeconds:  options.ShutdownTimeoutSeconds,
+		childRestartPolicy:      *options.ChildRestartPolicy,
+		childMaxRestarts:        options.ChildMaxRestarts,
+		childRestartBaseBackoff: time.Second,
+		stopped:                 make(chan struct{}),
 		documentPlugin:          options.DocumentPlugin,
 		eventsPlugin:            options.EventsPlugin,
 		storagePlugin:           options.StoragePlugin,
 		queuePlugin:             options.QueuePlugin,
 		gatewayPlugin:           options.GatewayPlugin,
 		secretPlugin:            options.SecretPlugin,
-		suppressLogs:            options.SuppressLogs,
+		configPlugin:            options.ConfigPlugin,
+		log:                     options.Logger,
 		tolerateMissingServices: options.TolerateMissingServices,
 		mode:                    *options.Mode,
 		pool:                    options.Pool,
+		grpcServerOptions:       options.GrpcServerOptions,
+		maxRecvMessageBytes:     options.MaxRecvMessageBytes,
+		maxSendMessageBytes:     options.MaxSendMessageBytes,
+		maxRequestBodyBytes:     options.MaxRequestBodyBytes,
+		maxResponseBodyBytes:    options.MaxResponseBodyBytes,
+		enableCompression:       options.EnableCompression,
+		compressionMinBytes:     options.CompressionMinBytes,
+		defaultContentType:      options.DefaultResponseContentType,
+		sniffContentType:        options.SniffContentType,
+		corsPolicy:              corsPolicy,
+		headerAllowList:         options.HeaderAllowList,
+		headerDenyList:          options.HeaderDenyList,
+		triggerMiddleware:       options.TriggerMiddleware,
+		httpLogPolicy:           httpLogPolicy,
+		staticRoutes:            options.StaticRoutes,
+		idempotencyTtlSeconds:   options.IdempotencyTtlSeconds,
+		idempotencyStore:        options.IdempotencyStore,
+		requestTimeoutSeconds:   options.RequestTimeoutSeconds,
+		healthCheckAddress:      options.HealthCheckAddress,
+		metricsAddress:          options.MetricsAddress,
+		adminAddress:            options.AdminAddress,
+		adminToken:              options.AdminToken,
+		circuitBreakerMetrics:   circuitBreakerMetrics,
+		failoverMetrics:         failoverMetrics,
+		tracerProvider:          options.TracerProvider,
+		eventRetries:            options.EventRetries,
+		deadLetterQueue:         options.DeadLetterQueue,
+		deadLetterPlugin:        options.DeadLetterPlugin,
+		deadLetterMaxReplays:    options.DeadLetterMaxReplays,
 	}, nil
 }
@@ -15,12 +15,14 @@
 package membrane_test
 
 import (
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net"
 	"net/http"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/nitrictech/nitric/pkg/membrane"
 	"github.com/nitrictech/nitric/pkg/triggers"
@@ -31,6 +33,7 @@ import (
 	"github.com/nitrictech/nitric/pkg/plugins/events"
 	"github.com/nitrictech/nitric/pkg/plugins/gateway"
 	"github.com/nitrictech/nitric/pkg/plugins/queue"
+	"github.com/nitrictech/nitric/pkg/plugins/secret"
 	"github.com/nitrictech/nitric/pkg/plugins/storage"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -52,6 +55,57 @@ type MockQueueServiceServer struct {
 	queue.UnimplementedQueuePlugin
 }
 
+type MockSecretServiceServer struct {
+	secret.UnimplementedSecretPlugin
+}
+
+// closeRecorder - Records the order plugins are closed in
+type closeRecorder struct {
+	closed []string
+}
+
+func (r *closeRecorder) record(name string, err error) error {
+	r.closed = append(r.closed, name)
+	return err
+}
+
+type ClosingDocumentPlugin struct {
+	document.UnimplementedDocumentPlugin
+	recorder *closeRecorder
+}
+
+func (p *ClosingDocumentPlugin) Close() error {
+	return p.recorder.record("document", nil)
+}
+
+type ClosingEventsPlugin struct {
+	events.UnimplementedeventsPlugin
+	recorder *closeRecorder
+}
+
+func (p *ClosingEventsPlugin) Close() error {
+	return p.recorder.record("events", nil)
+}
+
+type ClosingStoragePlugin struct {
+	storage.UnimplementedStoragePlugin
+	recorder *closeRecorder
+	err      error
+}
+
+func (p *ClosingStoragePlugin) Close() error {
+	return p.recorder.record("storage", p.err)
+}
+
+type ClosingQueuePlugin struct {
+	queue.UnimplementedQueuePlugin
+	recorder *closeRecorder
+}
+
+func (p *ClosingQueuePlugin) Close() error {
+	return p.recorder.record("queue", nil)
+}
+
 type MockFunction struct {
 	// Records the requests that its received for later inspection
 	requests []*http.Request
@@ -110,6 +164,12 @@ func (gw *MockGateway) Start(pool worker.WorkerPool) error {
 				}
 			} else if s, ok := trigger.(*triggers.Event); ok {
 				wrkr.HandleEvent(s)
+			} else if s, ok := trigger.(*triggers.WebsocketMessage); ok {
+				wrkr.HandleWebsocketMessage(s)
+			} else if s, ok := trigger.(*triggers.BucketNotification); ok {
+				wrkr.HandleBucketNotification(s)
+			} else if s, ok := trigger.(*triggers.Schedule); ok {
+				wrkr.HandleSchedule(s)
 			}
 		}
 	}
@@ -118,6 +178,22 @@ func (gw *MockGateway) Start(pool worker.WorkerPool) error {
 	return nil
 }
 
+// BlockingGateway - A gateway that runs until it is stopped
+type BlockingGateway struct {
+	gateway.UnimplementedGatewayPlugin
+	stop chan bool
+}
+
+func (gw *BlockingGateway) Start(pool worker.WorkerPool) error {
+	<-gw.stop
+	return nil
+}
+
+func (gw *BlockingGateway) Stop() error {
+	close(gw.stop)
+	return nil
+}
+
 var _ = Describe("Membrane", func() {
 	pool := worker.NewProcessPool(&worker.ProcessPoolOptions{})
 	pool.AddWorker(mock_worker.NewMockWorker(&mock_worker.MockWorkerOptions{}))
@@ -176,6 +252,7 @@ var _ = Describe("Membrane", func() {
 				mockeventsServer := &MockeventsServer{}
 				mockStorageServiceServer := &MockStorageServiceServer{}
 				mockQueueServiceServer := &MockQueueServiceServer{}
+				mockSecretServiceServer := &MockSecretServiceServer{}
 
 				mockGateway := &MockGateway{}
 				mbraneOpts := membrane.MembraneOptions{
@@ -186,6 +263,7 @@ var _ = Describe("Membrane", func() {
 					EventsPlugin:            mockeventsServer,
 					StoragePlugin:           mockStorageServiceServer,
 					QueuePlugin:             mockQueueServiceServer,
+					SecretPlugin:            mockSecretServiceServer,
 					Pool:                    pool,
 				}
 
@@ -250,6 +328,299 @@ var _ = Describe("Membrane", func() {
 		})
 	})
 
+	Context("Limiting HTTP request bodies", func() {
+		When("A request body exceeds the configured limit", func() {
+			var mockGateway *MockGateway
+			var mockWorker *mock_worker.MockWorker
+			var mb *membrane.Membrane
+
+			BeforeEach(func() {
+				mockWorker = mock_worker.NewMockWorker(&mock_worker.MockWorkerOptions{
+					ReturnHttp: &triggers.HttpResponse{
+						StatusCode: 200,
+					},
+				})
+				limitPool := worker.NewProcessPool(&worker.ProcessPoolOptions{})
+				limitPool.AddWorker(mockWorker)
+
+				mockGateway = &MockGateway{
+					triggers: []triggers.Trigger{
+						&triggers.HttpRequest{
+							Method: "POST",
+							Path:   "/",
+							Body:   []byte("this body is too large"),
+						},
+					},
+				}
+				mb, _ = membrane.New(&membrane.MembraneOptions{
+					GatewayPlugin:           mockGateway,
+					ServiceAddress:          "localhost:9006",
+					TolerateMissingServices: true,
+					SuppressLogs:            true,
+					Pool:                    limitPool,
+					MaxRequestBodyBytes:     10,
+				})
+			})
+
+			AfterEach(func() {
+				mb.Stop()
+			})
+
+			It("Should respond with a 413 without calling the worker", func() {
+				err := mb.Start()
+				Expect(err).ShouldNot(HaveOccurred())
+
+				Expect(mockGateway.responses).To(HaveLen(1))
+				Expect(mockGateway.responses[0].StatusCode).To(Equal(413))
+				Expect(mockWorker.ReceivedRequests).To(BeEmpty())
+			})
+		})
+	})
+
+	Context("Recovering from worker panics", func() {
+		When("The worker panics handling triggers", func() {
+			var mockGateway *MockGateway
+			var mockWorker *mock_worker.MockWorker
+			var mb *membrane.Membrane
+
+			BeforeEach(func() {
+				mockWorker = mock_worker.NewMockWorker(&mock_worker.MockWorkerOptions{
+					Panic: "handler failure",
+				})
+				panicPool := worker.NewProcessPool(&worker.ProcessPoolOptions{})
+				panicPool.AddWorker(mockWorker)
+
+				mockGateway = &MockGateway{
+					triggers: []triggers.Trigger{
+						&triggers.HttpRequest{
+							Method: "GET",
+							Path:   "/",
+						},
+						&triggers.Event{
+							ID:    "1234",
+							Topic: "test",
+						},
+						&triggers.HttpRequest{
+							Method: "GET",
+							Path:   "/",
+						},
+					},
+				}
+				mb, _ = membrane.New(&membrane.MembraneOptions{
+					GatewayPlugin:           mockGateway,
+					ServiceAddress:          "localhost:9010",
+					TolerateMissingServices: true,
+					SuppressLogs:            true,
+					Pool:                    panicPool,
+				})
+			})
+
+			AfterEach(func() {
+				mb.Stop()
+			})
+
+			It("Should respond with a 500 and continue handling triggers", func() {
+				err := mb.Start()
+				Expect(err).ShouldNot(HaveOccurred())
+
+				Expect(mockGateway.responses).To(HaveLen(2))
+				Expect(mockGateway.responses[0].StatusCode).To(Equal(500))
+				Expect(mockGateway.responses[1].StatusCode).To(Equal(500))
+
+				By("Dispatching every trigger to the worker")
+				Expect(mockWorker.ReceivedRequests).To(HaveLen(2))
+				Expect(mockWorker.ReceivedEvents).To(HaveLen(1))
+			})
+		})
+	})
+
+	Context("Filtering HTTP request headers", func() {
+		When("A request contains denied and hop-by-hop headers", func() {
+			var mockGateway *MockGateway
+			var mockWorker *mock_worker.MockWorker
+			var mb *membrane.Membrane
+
+			BeforeEach(func() {
+				mockWorker = mock_worker.NewMockWorker(&mock_worker.MockWorkerOptions{
+					ReturnHttp: &triggers.HttpResponse{
+						StatusCode: 200,
+					},
+				})
+				filterPool := worker.NewProcessPool(&worker.ProcessPoolOptions{})
+				filterPool.AddWorker(mockWorker)
+
+				mockGateway = &MockGateway{
+					triggers: []triggers.Trigger{
+						&triggers.HttpRequest{
+							Method: "GET",
+							Path:   "/",
+							Header: map[string][]string{
+								"x-internal-token": {"secret"},
+								"Connection":       {"keep-alive"},
+								"Content-Type":     {"text/plain"},
+							},
+						},
+					},
+				}
+				mb, _ = membrane.New(&membrane.MembraneOptions{
+					GatewayPlugin:           mockGateway,
+					ServiceAddress:          "localhost:9009",
+					TolerateMissingServices: true,
+					SuppressLogs:            true,
+					Pool:                    filterPool,
+					HeaderDenyList:          []string{"X-Internal-Token"},
+				})
+			})
+
+			AfterEach(func() {
+				mb.Stop()
+			})
+
+			It("Should pass the request to the worker without those headers", func() {
+				err := mb.Start()
+				Expect(err).ShouldNot(HaveOccurred())
+
+				Expect(mockWorker.ReceivedRequests).To(HaveLen(1))
+				header := mockWorker.ReceivedRequests[0].Header
+				Expect(header).ToNot(HaveKey("x-internal-token"))
+				Expect(header).ToNot(HaveKey("Connection"))
+				Expect(header).To(HaveKeyWithValue("Content-Type", []string{"text/plain"}))
+			})
+		})
+	})
+
+	Context("Health checks", func() {
+		When("The membrane is missing plugins", func() {
+			var mb *membrane.Membrane
+
+			BeforeEach(func() {
+				healthPool := worker.NewProcessPool(&worker.ProcessPoolOptions{})
+				healthPool.AddWorker(mock_worker.NewMockWorker(&mock_worker.MockWorkerOptions{}))
+
+				mb, _ = membrane.New(&membrane.MembraneOptions{
+					GatewayPlugin:           &MockGateway{},
+					ServiceAddress:          "localhost:9007",
+					HealthCheckAddress:      "localhost:9008",
+					TolerateMissingServices: true,
+					SuppressLogs:            true,
+					Pool:                    healthPool,
+				})
+				Expect(mb.Start()).ShouldNot(HaveOccurred())
+			})
+
+			AfterEach(func() {
+				mb.Stop()
+			})
+
+			It("Should report as alive", func() {
+				resp, err := http.Get("http://localhost:9008/healthz")
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(resp.StatusCode).To(Equal(200))
+			})
+
+			It("Should report as not ready, listing the missing plugins", func() {
+				resp, err := http.Get("http://localhost:9008/readyz")
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(resp.StatusCode).To(Equal(503))
+
+				body, _ := ioutil.ReadAll(resp.Body)
+				Expect(string(body)).To(ContainSubstring("document"))
+			})
+		})
+	})
+
+	Context("Admin endpoint", func() {
+		When("An admin token is configured", func() {
+			var mb *membrane.Membrane
+
+			BeforeEach(func() {
+				adminPool := worker.NewProcessPool(&worker.ProcessPoolOptions{})
+				adminPool.AddWorker(mock_worker.NewMockWorker(&mock_worker.MockWorkerOptions{}))
+
+				mb, _ = membrane.New(&membrane.MembraneOptions{
+					GatewayPlugin:           &MockGateway{},
+					ServiceAddress:          "localhost:9011",
+					AdminAddress:            "localhost:9012",
+					AdminToken:              "admin-token",
+					TolerateMissingServices: true,
+					SuppressLogs:            true,
+					Pool:                    adminPool,
+				})
+				Expect(mb.Start()).ShouldNot(HaveOccurred())
+			})
+
+			AfterEach(func() {
+				mb.Stop()
+			})
+
+			It("Should reject requests without the token", func() {
+				resp, err := http.Get("http://localhost:9012/workers")
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(resp.StatusCode).To(Equal(401))
+			})
+
+			It("Should list the workers for requests with the token", func() {
+				req, _ := http.NewRequest("GET", "http://localhost:9012/workers", nil)
+				req.Header.Set("Authorization", "Bearer admin-token")
+
+				resp, err := http.DefaultClient.Do(req)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(resp.StatusCode).To(Equal(200))
+
+				var status struct {
+					Workers []struct {
+						ID      string `json:"id"`
+						Ready   bool   `json:"ready"`
+						Handled int64  `json:"handled"`
+					} `json:"workers"`
+				}
+				body, _ := ioutil.ReadAll(resp.Body)
+				Expect(json.Unmarshal(body, &status)).To(Succeed())
+				Expect(status.Workers).To(HaveLen(1))
+				Expect(status.Workers[0].Ready).To(BeTrue())
+
+				By("Inspecting a single worker by its ID")
+				req, _ = http.NewRequest("GET", "http://localhost:9012/workers/"+status.Workers[0].ID, nil)
+				req.Header.Set("Authorization", "Bearer admin-token")
+
+				resp, err = http.DefaultClient.Do(req)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(resp.StatusCode).To(Equal(200))
+
+				By("Returning not found for unknown workers")
+				req, _ = http.NewRequest("GET", "http://localhost:9012/workers/unknown", nil)
+				req.Header.Set("Authorization", "Bearer admin-token")
+
+				resp, err = http.DefaultClient.Do(req)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(resp.StatusCode).To(Equal(404))
+			})
+		})
+	})
+
+	Context("Stopping the membrane", func() {
+		It("Should close the plugins in order, reporting those that fail to close", func() {
+			recorder := &closeRecorder{}
+			stopPool := worker.NewProcessPool(&worker.ProcessPoolOptions{})
+			mb, err := membrane.New(&membrane.MembraneOptions{
+				DocumentPlugin:          &ClosingDocumentPlugin{recorder: recorder},
+				EventsPlugin:            &ClosingEventsPlugin{recorder: recorder},
+				StoragePlugin:           &ClosingStoragePlugin{recorder: recorder, err: fmt.Errorf("mock error")},
+				QueuePlugin:             &ClosingQueuePlugin{recorder: recorder},
+				GatewayPlugin:           &BlockingGateway{stop: make(chan bool)},
+				TolerateMissingServices: true,
+				SuppressLogs:            true,
+				Pool:                    stopPool,
+			})
+			Expect(err).ShouldNot(HaveOccurred())
+
+			err = mb.Stop()
+			Expect(err).Should(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("storage plugin: mock error"))
+			Expect(recorder.closed).To(Equal([]string{"events", "document", "queue", "storage"}))
+		})
+	})
+
 	Context("Starting the child process", func() {
 		BeforeEach(func() {
 			os.Args = []string{}
@@ -261,6 +632,9 @@ var _ = Describe("Membrane", func() {
 
 			BeforeEach(func() {
 				mockGateway = &MockGateway{}
+				// Stopping the membrane shuts down its pool, so don't share it with other tests
+				childPool := worker.NewProcessPool(&worker.ProcessPoolOptions{})
+				childPool.AddWorker(mock_worker.NewMockWorker(&mock_worker.MockWorkerOptions{}))
 				mb, _ = membrane.New(&membrane.MembraneOptions{
 					ChildCommand:            []string{"echo"},
 					GatewayPlugin:           mockGateway,
@@ -268,7 +642,7 @@ var _ = Describe("Membrane", func() {
 					ChildTimeoutSeconds:     1,
 					TolerateMissingServices: true,
 					SuppressLogs:            true,
-					Pool:                    pool,
+					Pool:                    childPool,
 				})
 			})
 
@@ -290,6 +664,62 @@ var _ = Describe("Membrane", func() {
 			})
 		})
 
+		When("The child process keeps failing with a restart policy", func() {
+			BeforeEach(func() {
+				childPool := worker.NewProcessPool(&worker.ProcessPoolOptions{})
+				childPool.AddWorker(mock_worker.NewMockWorker(&mock_worker.MockWorkerOptions{}))
+				policy := membrane.ChildRestartPolicy_OnFailure
+
+				mb, _ = membrane.New(&membrane.MembraneOptions{
+					ChildCommand:            []string{"false"},
+					ChildRestartPolicy:      &policy,
+					ChildMaxRestarts:        1,
+					GatewayPlugin:           &BlockingGateway{stop: make(chan bool)},
+					ServiceAddress:          fmt.Sprintf(":%d", 9002),
+					ChildTimeoutSeconds:     1,
+					TolerateMissingServices: true,
+					SuppressLogs:            true,
+					Pool:                    childPool,
+				})
+			})
+
+			AfterEach(func() {
+				mb.Stop()
+			})
+
+			It("Should restart the child, then return an error after the maximum restarts", func() {
+				err := mb.Start()
+				Expect(err).Should(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("child process exited after 1 restarts"))
+			})
+		})
+
+		When("The child process exits successfully without connecting", func() {
+			BeforeEach(func() {
+				mb, _ = membrane.New(&membrane.MembraneOptions{
+					ChildCommand:            []string{"true"},
+					GatewayPlugin:           &BlockingGateway{stop: make(chan bool)},
+					ServiceAddress:          fmt.Sprintf(":%d", 9003),
+					ChildTimeoutSeconds:     10,
+					TolerateMissingServices: true,
+					SuppressLogs:            true,
+					Pool:                    worker.NewProcessPool(&worker.ProcessPoolOptions{MinWorkers: 1}),
+				})
+			})
+
+			AfterEach(func() {
+				mb.Stop()
+			})
+
+			It("Should return an error without waiting for the timeout", func() {
+				started := time.Now()
+				err := mb.Start()
+				Expect(err).Should(HaveOccurred())
+				Expect(err.Error()).To(Equal("child exited before connecting"))
+				Expect(time.Since(started)).To(BeNumerically("<", 5*time.Second))
+			})
+		})
+
 		When("The configured command does not exist", func() {
 			BeforeEach(func() {
 				mockGateway = &MockGateway{}
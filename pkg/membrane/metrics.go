@@ -0,0 +1,63 @@
+// Copyright 2021 Nitric Pty Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package membrane
+
+import (
+	"net"
+	"net/http"
+
+	"github.com/nitrictech/nitric/pkg/worker"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// startMetricsServer - Creates the worker metrics and serves them, along with any plugin circuit and failover metrics, for Prometheus on the configured address
+func (s *Membrane) startMetricsServer() error {
+	registry := prometheus.NewRegistry()
+
+	metrics, err := worker.NewMetrics(registry, s.pool)
+	if err != nil {
+		return err
+	}
+
+	if s.circuitBreakerMetrics != nil {
+		if err := s.circuitBreakerMetrics.Register(registry); err != nil {
+			return err
+		}
+	}
+
+	if s.failoverMetrics != nil {
+		if err := s.failoverMetrics.Register(registry); err != nil {
+			return err
+		}
+	}
+
+	lis, err := net.Listen("tcp", s.metricsAddress)
+	if err != nil {
+		return err
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+
+	s.metrics = metrics
+	s.metricsServer = &http.Server{
+		Handler: mux,
+	}
+
+	go s.metricsServer.Serve(lis)
+
+	return nil
+}
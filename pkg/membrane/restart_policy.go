@@ -0,0 +1,59 @@
+// Copyright 2021 Nitric Pty Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package membrane
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ChildRestartPolicy enum
+type ChildRestartPolicy int
+
+const (
+	// ChildRestartPolicy_Never leaves the child process stopped when it exits
+	ChildRestartPolicy_Never ChildRestartPolicy = iota
+	// ChildRestartPolicy_OnFailure restarts the child process when it exits with an error
+	ChildRestartPolicy_OnFailure
+	// ChildRestartPolicy_Always restarts the child process whenever it exits
+	ChildRestartPolicy_Always
+)
+
+var childRestartPolicies = [...]string{"NEVER", "ON_FAILURE", "ALWAYS"}
+
+func (p ChildRestartPolicy) String() string {
+	return childRestartPolicies[p]
+}
+
+// shouldRestart - returns true if a child process that exited with the given error should be restarted
+func (p ChildRestartPolicy) shouldRestart(exitErr error) bool {
+	switch p {
+	case ChildRestartPolicy_Always:
+		return true
+	case ChildRestartPolicy_OnFailure:
+		return exitErr != nil
+	default:
+		return false
+	}
+}
+
+func ChildRestartPolicyFromString(policyString string) (ChildRestartPolicy, error) {
+	for i, policy := range childRestartPolicies {
+		if policy == policyString {
+			return ChildRestartPolicy(i), nil
+		}
+	}
+	return -1, fmt.Errorf("Invalid child restart policy %s, supported policies are: %s", policyString, strings.Join(childRestartPolicies[:], ", "))
+}
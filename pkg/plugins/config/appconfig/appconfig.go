@@ -0,0 +1,225 @@
+// Copyright 2021 Nitric Pty Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package appconfig_config_service
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/appconfig"
+	"github.com/aws/aws-sdk-go/service/appconfig/appconfigiface"
+	"github.com/google/uuid"
+	"github.com/nitrictech/nitric/pkg/plugins/config"
+	"github.com/nitrictech/nitric/pkg/plugins/errors"
+	"github.com/nitrictech/nitric/pkg/plugins/errors/codes"
+	"github.com/nitrictech/nitric/pkg/utils"
+)
+
+const (
+	APPCONFIG_APPLICATION_ENV   = "APPCONFIG_APPLICATION"
+	APPCONFIG_ENVIRONMENT_ENV   = "APPCONFIG_ENVIRONMENT"
+	APPCONFIG_CONFIGURATION_ENV = "APPCONFIG_CONFIGURATION"
+)
+
+// AppConfigProfile - Identifies the AppConfig configuration profile to read
+type AppConfigProfile struct {
+	Application   string
+	Environment   string
+	Configuration string
+}
+
+type appConfigConfigService struct {
+	config.UnimplementedConfigPlugin
+	client   appconfigiface.AppConfigAPI
+	profile  *AppConfigProfile
+	clientId string
+
+	// AppConfig only returns content when the configuration has changed since the version the client last received
+	lock    sync.Mutex
+	version string
+	values  map[string]config.ConfigValue
+}
+
+// decode - Decodes the configuration content based on its content type
+func decode(contentType string, content []byte, version string) (map[string]config.ConfigValue, error) {
+	switch {
+	case strings.Contains(contentType, "yaml"):
+		return config.DecodeYAML(content, version)
+	case strings.Contains(contentType, "json"):
+		return config.DecodeJSON(content, version)
+	default:
+		return nil, fmt.Errorf("unsupported configuration content type %s, expected JSON or YAML", contentType)
+	}
+}
+
+// getConfiguration - Retrieves the latest configuration, reusing the previous values if it hasn't changed
+func (s *appConfigConfigService) getConfiguration() (map[string]config.ConfigValue, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	input := &appconfig.GetConfigurationInput{
+		Application:   aws.String(s.profile.Application),
+		Environment:   aws.String(s.profile.Environment),
+		Configuration: aws.String(s.profile.Configuration),
+		ClientId:      aws.String(s.clientId),
+	}
+
+	if s.version != "" {
+		input.ClientConfigurationVersion = aws.String(s.version)
+	}
+
+	output, err := s.client.GetConfiguration(input)
+	if err != nil {
+		return nil, err
+	}
+
+	version := aws.StringValue(output.ConfigurationVersion)
+
+	if s.values != nil && (len(output.Content) == 0 || version == s.version) {
+		return s.values, nil
+	}
+
+	values, err := decode(aws.StringValue(output.ContentType), output.Content, version)
+	if err != nil {
+		return nil, err
+	}
+
+	s.version = version
+	s.values = values
+
+	return values, nil
+}
+
+func (s *appConfigConfigService) Get(key string) (*config.ConfigValue, error) {
+	newErr := errors.ErrorsWithScope(
+		"AppConfigConfigService.Get",
+		map[string]interface{}{
+			"key": key,
+		},
+	)
+
+	if key == "" {
+		return nil, newErr(
+			codes.InvalidArgument,
+			"provide non-blank key",
+			nil,
+		)
+	}
+
+	values, err := s.getConfiguration()
+	if err != nil {
+		return nil, newErr(
+			codes.Internal,
+			"failed to retrieve configuration",
+			err,
+		)
+	}
+
+	value, ok := values[key]
+	if !ok {
+		return nil, newErr(
+			codes.NotFound,
+			"config key not found",
+			nil,
+		)
+	}
+
+	return &value, nil
+}
+
+func (s *appConfigConfigService) List() (map[string]config.ConfigValue, error) {
+	newErr := errors.ErrorsWithScope(
+		"AppConfigConfigService.List",
+		map[string]interface{}{},
+	)
+
+	values, err := s.getConfiguration()
+	if err != nil {
+		return nil, newErr(
+			codes.Internal,
+			"failed to retrieve configuration",
+			err,
+		)
+	}
+
+	// Copy the cached values so callers can't modify them
+	result := make(map[string]config.ConfigValue, len(values))
+	for k, v := range values {
+		result[k] = v
+	}
+
+	return result, nil
+}
+
+// profileFromEnv - Reads the configuration profile from the environment
+func profileFromEnv() (*AppConfigProfile, error) {
+	profile := &AppConfigProfile{
+		Application:   utils.GetEnv(APPCONFIG_APPLICATION_ENV, ""),
+		Environment:   utils.GetEnv(APPCONFIG_ENVIRONMENT_ENV, ""),
+		Configuration: utils.GetEnv(APPCONFIG_CONFIGURATION_ENV, ""),
+	}
+
+	configErrors := make([]error, 0)
+
+	if profile.Application == "" {
+		configErrors = append(configErrors, fmt.Errorf("%s not configured", APPCONFIG_APPLICATION_ENV))
+	}
+
+	if profile.Environment == "" {
+		configErrors = append(configErrors, fmt.Errorf("%s not configured", APPCONFIG_ENVIRONMENT_ENV))
+	}
+
+	if profile.Configuration == "" {
+		configErrors = append(configErrors, fmt.Errorf("%s not configured", APPCONFIG_CONFIGURATION_ENV))
+	}
+
+	if len(configErrors) > 0 {
+		return nil, fmt.Errorf("configuration errors: %v", configErrors)
+	}
+
+	return profile, nil
+}
+
+// New - Creates a new AWS AppConfig config plugin, using the configuration profile from the environment
+func New() (config.ConfigService, error) {
+	awsRegion := utils.GetEnv("AWS_REGION", "us-east-1")
+
+	profile, err := profileFromEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	sess, sessionError := session.NewSession(&aws.Config{
+		Region: aws.String(awsRegion),
+	})
+
+	if sessionError != nil {
+		return nil, fmt.Errorf("error creating new AWS session %v", sessionError)
+	}
+
+	return NewWithClient(appconfig.New(sess), profile)
+}
+
+// NewWithClient - Creates a new AWS AppConfig config plugin using the provided client
+func NewWithClient(client appconfigiface.AppConfigAPI, profile *AppConfigProfile) (config.ConfigService, error) {
+	return &appConfigConfigService{
+		client:   client,
+		profile:  profile,
+		clientId: uuid.New().String(),
+	}, nil
+}
@@ -0,0 +1,108 @@
+// Copyright 2021 Nitric Pty Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package appconfig_config_service
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/appconfig"
+	"github.com/golang/mock/gomock"
+	mocks "github.com/nitrictech/nitric/mocks/appconfig"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("AppConfig Plugin", func() {
+	profile := &AppConfigProfile{
+		Application:   "app",
+		Environment:   "prod",
+		Configuration: "flags",
+	}
+
+	When("Get", func() {
+		When("The key exists in the configuration", func() {
+			ctrl := gomock.NewController(GinkgoT())
+			mockClient := mocks.NewMockAppConfigAPI(ctrl)
+			configPlugin, _ := NewWithClient(mockClient, profile)
+
+			It("Should return the value", func() {
+				defer ctrl.Finish()
+
+				mockClient.EXPECT().GetConfiguration(gomock.Any()).Return(&appconfig.GetConfigurationOutput{
+					ConfigurationVersion: aws.String("1"),
+					ContentType:          aws.String("application/json"),
+					Content:              []byte(`{"newCheckout": true}`),
+				}, nil).Times(1)
+
+				value, err := configPlugin.Get("newCheckout")
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(value.Value).To(Equal("true"))
+				Expect(value.Version).To(Equal("1"))
+			})
+		})
+
+		When("The configuration hasn't changed since the last request", func() {
+			ctrl := gomock.NewController(GinkgoT())
+			mockClient := mocks.NewMockAppConfigAPI(ctrl)
+			configPlugin, _ := NewWithClient(mockClient, profile)
+
+			It("Should return the previously received values", func() {
+				defer ctrl.Finish()
+
+				mockClient.EXPECT().GetConfiguration(gomock.Any()).Return(&appconfig.GetConfigurationOutput{
+					ConfigurationVersion: aws.String("1"),
+					ContentType:          aws.String("application/json"),
+					Content:              []byte(`{"newCheckout": true}`),
+				}, nil).Times(1)
+
+				mockClient.EXPECT().GetConfiguration(gomock.Any()).DoAndReturn(func(input *appconfig.GetConfigurationInput) (*appconfig.GetConfigurationOutput, error) {
+					By("Sending the version that was last received")
+					Expect(aws.StringValue(input.ClientConfigurationVersion)).To(Equal("1"))
+
+					return &appconfig.GetConfigurationOutput{
+						ConfigurationVersion: aws.String("1"),
+						ContentType:          aws.String("application/json"),
+					}, nil
+				}).Times(1)
+
+				_, err := configPlugin.Get("newCheckout")
+				Expect(err).ShouldNot(HaveOccurred())
+
+				value, err := configPlugin.Get("newCheckout")
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(value.Value).To(Equal("true"))
+			})
+		})
+
+		When("The key doesn't exist in the configuration", func() {
+			ctrl := gomock.NewController(GinkgoT())
+			mockClient := mocks.NewMockAppConfigAPI(ctrl)
+			configPlugin, _ := NewWithClient(mockClient, profile)
+
+			It("Should return an error", func() {
+				defer ctrl.Finish()
+
+				mockClient.EXPECT().GetConfiguration(gomock.Any()).Return(&appconfig.GetConfigurationOutput{
+					ConfigurationVersion: aws.String("1"),
+					ContentType:          aws.String("application/json"),
+					Content:              []byte(`{}`),
+				}, nil).Times(1)
+
+				_, err := configPlugin.Get("newCheckout")
+				Expect(err).Should(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("config key not found"))
+			})
+		})
+	})
+})
@@ -0,0 +1,123 @@
+// Copyright 2021 Nitric Pty Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config_service
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/nitrictech/nitric/pkg/plugins/config"
+	"github.com/nitrictech/nitric/pkg/plugins/errors"
+	"github.com/nitrictech/nitric/pkg/plugins/errors/codes"
+	"github.com/nitrictech/nitric/pkg/utils"
+)
+
+const DEV_CONFIG_FILE = "./config/config.json"
+
+type DevConfigService struct {
+	config.UnimplementedConfigPlugin
+	configFile string
+}
+
+// readConfigFile - Reads the config file on every call so changes apply without a restart,
+// a missing file is treated as empty configuration
+func (s *DevConfigService) readConfigFile() (map[string]config.ConfigValue, error) {
+	content, err := ioutil.ReadFile(s.configFile)
+	if os.IsNotExist(err) {
+		return make(map[string]config.ConfigValue), nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	switch strings.ToLower(filepath.Ext(s.configFile)) {
+	case ".yaml", ".yml":
+		return config.DecodeYAML(content, "")
+	default:
+		return config.DecodeJSON(content, "")
+	}
+}
+
+func (s *DevConfigService) Get(key string) (*config.ConfigValue, error) {
+	newErr := errors.ErrorsWithScope(
+		"DevConfigService.Get",
+		map[string]interface{}{
+			"key": key,
+		},
+	)
+
+	if key == "" {
+		return nil, newErr(
+			codes.InvalidArgument,
+			"provide non-blank key",
+			nil,
+		)
+	}
+
+	values, err := s.readConfigFile()
+	if err != nil {
+		return nil, newErr(
+			codes.Internal,
+			"error reading config file",
+			err,
+		)
+	}
+
+	value, ok := values[key]
+	if !ok {
+		return nil, newErr(
+			codes.NotFound,
+			"config key not found",
+			nil,
+		)
+	}
+
+	return &value, nil
+}
+
+func (s *DevConfigService) List() (map[string]config.ConfigValue, error) {
+	newErr := errors.ErrorsWithScope(
+		"DevConfigService.List",
+		map[string]interface{}{},
+	)
+
+	values, err := s.readConfigFile()
+	if err != nil {
+		return nil, newErr(
+			codes.Internal,
+			"error reading config file",
+			err,
+		)
+	}
+
+	return values, nil
+}
+
+// New - Creates a new dev config plugin, reading a JSON or YAML file based on its extension
+func New() (config.ConfigService, error) {
+	configFile := utils.GetEnv("LOCAL_CONFIG_FILE", utils.GetRelativeDevPath(DEV_CONFIG_FILE))
+
+	return &DevConfigService{
+		configFile: configFile,
+	}, nil
+}
+
+// NewWithFile - Creates a new dev config plugin reading the given file
+func NewWithFile(configFile string) (config.ConfigService, error) {
+	return &DevConfigService{
+		configFile: configFile,
+	}, nil
+}
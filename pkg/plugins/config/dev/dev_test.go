@@ -0,0 +1,102 @@
+// Copyright 2021 Nitric Pty Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config_service_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	config_service "github.com/nitrictech/nitric/pkg/plugins/config/dev"
+	"github.com/nitrictech/nitric/pkg/plugins/errors"
+	"github.com/nitrictech/nitric/pkg/plugins/errors/codes"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Config", func() {
+	var dir string
+
+	BeforeEach(func() {
+		dir, _ = ioutil.TempDir("", "nitric-config")
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	Context("Get", func() {
+		When("Reading a JSON config file", func() {
+			It("Should return string values as is and encode other values as JSON", func() {
+				file := filepath.Join(dir, "config.json")
+				ioutil.WriteFile(file, []byte(`{"greeting": "hello", "newCheckout": true, "limits": {"max": 10}}`), 0600)
+				configPlugin, _ := config_service.NewWithFile(file)
+
+				greeting, err := configPlugin.Get("greeting")
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(greeting.Value).To(Equal("hello"))
+
+				flag, err := configPlugin.Get("newCheckout")
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(flag.Value).To(Equal("true"))
+
+				limits, err := configPlugin.Get("limits")
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(limits.Value).To(MatchJSON(`{"max": 10}`))
+			})
+		})
+
+		When("Reading a YAML config file", func() {
+			It("Should return the values", func() {
+				file := filepath.Join(dir, "config.yaml")
+				ioutil.WriteFile(file, []byte("greeting: hello\nlimits:\n  max: 10\n"), 0600)
+				configPlugin, _ := config_service.NewWithFile(file)
+
+				greeting, err := configPlugin.Get("greeting")
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(greeting.Value).To(Equal("hello"))
+
+				limits, err := configPlugin.Get("limits")
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(limits.Value).To(MatchJSON(`{"max": 10}`))
+			})
+		})
+
+		When("The key doesn't exist", func() {
+			It("Should return a not found error", func() {
+				configPlugin, _ := config_service.NewWithFile(filepath.Join(dir, "missing.json"))
+
+				_, err := configPlugin.Get("greeting")
+				Expect(err).Should(HaveOccurred())
+				Expect(errors.Code(err)).To(Equal(codes.NotFound))
+			})
+		})
+	})
+
+	Context("List", func() {
+		When("Reading a JSON config file", func() {
+			It("Should return all values", func() {
+				file := filepath.Join(dir, "config.json")
+				ioutil.WriteFile(file, []byte(`{"greeting": "hello", "newCheckout": false}`), 0600)
+				configPlugin, _ := config_service.NewWithFile(file)
+
+				values, err := configPlugin.List()
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(values).To(HaveLen(2))
+				Expect(values["newCheckout"].Value).To(Equal("false"))
+			})
+		})
+	})
+})
@@ -0,0 +1,56 @@
+// Copyright 2021 Nitric Pty Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import "fmt"
+
+// The capabilities a config plugin may support, named after the operations of the config service
+const (
+	CapabilityGet  = "get"
+	CapabilityList = "list"
+)
+
+// BaselineCapabilities - Returns the capabilities supported by every config plugin
+func BaselineCapabilities() []string {
+	return []string{CapabilityGet, CapabilityList}
+}
+
+type ConfigService interface {
+	// Get - Retrieves the value of a single configuration key
+	Get(key string) (*ConfigValue, error)
+	// List - Retrieves all configuration values, keyed by name
+	List() (map[string]ConfigValue, error)
+	// Capabilities - Returns the operations supported by the plugin, so clients can degrade gracefully without them
+	Capabilities() []string
+}
+
+type UnimplementedConfigPlugin struct {
+	ConfigService
+}
+
+var _ ConfigService = (*UnimplementedConfigPlugin)(nil)
+
+func (*UnimplementedConfigPlugin) Get(key string) (*ConfigValue, error) {
+	return nil, fmt.Errorf("UNIMPLEMENTED")
+}
+
+func (*UnimplementedConfigPlugin) List() (map[string]ConfigValue, error) {
+	return nil, fmt.Errorf("UNIMPLEMENTED")
+}
+
+// Capabilities - Plugins support the baseline capabilities unless they declare others
+func (*UnimplementedConfigPlugin) Capabilities() []string {
+	return BaselineCapabilities()
+}
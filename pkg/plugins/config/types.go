@@ -0,0 +1,102 @@
+// Copyright 2021 Nitric Pty Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"gopkg.in/yaml.v2"
+)
+
+// ConfigValue - A single runtime configuration value or feature flag
+type ConfigValue struct {
+	Key string `log:"Key"`
+	// Value - The value as a string, structured values are JSON encoded
+	Value string
+	// Version - The version of the configuration the value was read from, if the provider versions configuration
+	Version string `log:"Version"`
+}
+
+// valuesFromMap - Converts a decoded configuration document to config values,
+// strings are kept as is and all other values are JSON encoded
+func valuesFromMap(values map[string]interface{}, version string) (map[string]ConfigValue, error) {
+	configValues := make(map[string]ConfigValue, len(values))
+
+	for key, val := range values {
+		var str string
+		switch v := val.(type) {
+		case string:
+			str = v
+		default:
+			b, err := json.Marshal(v)
+			if err != nil {
+				return nil, fmt.Errorf("unable to encode value for key %s: %v", key, err)
+			}
+			str = string(b)
+		}
+
+		configValues[key] = ConfigValue{
+			Key:     key,
+			Value:   str,
+			Version: version,
+		}
+	}
+
+	return configValues, nil
+}
+
+// normalizeYaml - Converts the map[interface{}]interface{} values decoded from YAML into JSON compatible maps
+func normalizeYaml(val interface{}) interface{} {
+	switch v := val.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, mv := range v {
+			m[fmt.Sprintf("%v", key)] = normalizeYaml(mv)
+		}
+		return m
+	case []interface{}:
+		for i, lv := range v {
+			v[i] = normalizeYaml(lv)
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+// DecodeJSON - Decodes config values from a JSON object
+func DecodeJSON(content []byte, version string) (map[string]ConfigValue, error) {
+	values := make(map[string]interface{})
+	if err := json.Unmarshal(content, &values); err != nil {
+		return nil, err
+	}
+
+	return valuesFromMap(values, version)
+}
+
+// DecodeYAML - Decodes config values from a YAML mapping
+func DecodeYAML(content []byte, version string) (map[string]ConfigValue, error) {
+	values := make(map[string]interface{})
+	if err := yaml.Unmarshal(content, &values); err != nil {
+		return nil, err
+	}
+
+	for key, val := range values {
+		values[key] = normalizeYaml(val)
+	}
+
+	return valuesFromMap(values, version)
+}
@@ -0,0 +1,78 @@
+// Copyright 2021 Nitric Pty Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package document
+
+import (
+	"fmt"
+)
+
+// MaxBatchSize - maximum number of documents in a single batch operation
+const MaxBatchSize int = 500
+
+// ValidateBatchSize - validates the number of documents in a batch operation
+func ValidateBatchSize(size int) error {
+	if size == 0 {
+		return fmt.Errorf("provide at least one document")
+	}
+	if size > MaxBatchSize {
+		return fmt.Errorf("provide at most %d documents, got %d", MaxBatchSize, size)
+	}
+	return nil
+}
+
+// GetBatchSequentially - gets each document in turn, for plugins without native batch support
+func GetBatchSequentially(s DocumentService, keys []*Key) []*BatchGetResult {
+	results := make([]*BatchGetResult, len(keys))
+	for i, key := range keys {
+		doc, err := s.Get(key)
+		results[i] = &BatchGetResult{
+			Key:      key,
+			Document: doc,
+			Err:      err,
+		}
+	}
+	return results
+}
+
+// SetBatchSequentially - sets each document in turn, for plugins without native batch support
+func SetBatchSequentially(s DocumentService, items []*BatchSetItem) []*BatchResult {
+	results := make([]*BatchResult, len(items))
+	for i, item := range items {
+		if item == nil {
+			results[i] = &BatchResult{
+				Err: fmt.Errorf("provide non-nil batch item"),
+			}
+			continue
+		}
+
+		results[i] = &BatchResult{
+			Key: item.Key,
+			Err: s.Set(item.Key, item.Content),
+		}
+	}
+	return results
+}
+
+// DeleteBatchSequentially - deletes each document in turn, for plugins without native batch support
+func DeleteBatchSequentially(s DocumentService, keys []*Key) []*BatchResult {
+	results := make([]*BatchResult, len(keys))
+	for i, key := range keys {
+		results[i] = &BatchResult{
+			Key: key,
+			Err: s.Delete(key),
+		}
+	}
+	return results
+}
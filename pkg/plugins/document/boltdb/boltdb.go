@@ -16,13 +16,13 @@ package boltdb_service
 
 import (
 	"fmt"
-	"io"
 	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/nitrictech/nitric/pkg/logger"
 	"github.com/nitrictech/nitric/pkg/plugins/document"
 	"github.com/nitrictech/nitric/pkg/plugins/errors"
 	"github.com/nitrictech/nitric/pkg/plugins/errors/codes"
@@ -41,9 +41,16 @@ const idName = "Id"
 const partitionKeyName = "PartitionKey"
 const sortKeyName = "SortKey"
 
+// BoltDocService - A document plugin for local development storing each top level collection in its own BoltDB file,
+// which is opened for the duration of a single operation
 type BoltDocService struct {
 	document.UnimplementedDocumentPlugin
-	dbDir string
+	dbDir         string
+	watch         bool
+	watchInterval time.Duration
+	log           logger.Logger
+	// Reloads collection files modified by other tools, nil unless watching is enabled
+	watcher *utils.FileWatcher
 }
 
 type BoltDoc struct {
@@ -51,6 +58,8 @@ type BoltDoc struct {
 	PartitionKey string `storm:"index"`
 	SortKey      string `storm:"index"`
 	Value        map[string]interface{}
+	// Incremented each time the document is set, documents stored before versioning start at 0
+	Version int64
 }
 
 func (d BoltDoc) String() string {
@@ -81,7 +90,7 @@ func (s *BoltDocService) Get(key *document.Key) (*document.Document, error) {
 			err,
 		)
 	}
-	defer db.Close()
+	defer s.closeDb(db)
 
 	doc := createDoc(key)
 
@@ -113,6 +122,52 @@ func (s *BoltDocService) Set(key *document.Key, content map[string]interface{})
 		},
 	)
 
+	return s.set(key, content, nil, nil, newErr)
+}
+
+// SetWithCondition - sets the document only if the condition holds, the version is checked and incremented in a single transaction
+func (s *BoltDocService) SetWithCondition(key *document.Key, content map[string]interface{}, condition *document.SetCondition) error {
+	newErr := errors.ErrorsWithScope(
+		"BoltDocService.SetWithCondition",
+		map[string]interface{}{
+			"key": key,
+		},
+	)
+
+	if err := document.ValidateSetCondition(condition); err != nil {
+		return newErr(
+			codes.InvalidArgument,
+			"Invalid condition",
+			err,
+		)
+	}
+
+	return s.set(key, content, condition, nil, newErr)
+}
+
+// Merge - reads the existing document and saves it with the partial content merged into it, in a single transaction
+func (s *BoltDocService) Merge(key *document.Key, partial map[string]interface{}, mode document.MergeMode) error {
+	newErr := errors.ErrorsWithScope(
+		"BoltDocService.Merge",
+		map[string]interface{}{
+			"key": key,
+		},
+	)
+
+	if err := document.ValidateMergeMode(mode); err != nil {
+		return newErr(
+			codes.InvalidArgument,
+			"Invalid merge mode",
+			err,
+		)
+	}
+
+	return s.set(key, partial, nil, &mode, newErr)
+}
+
+// set - sets the document, checking the condition against the stored document when one is provided.
+// The content is merged into the stored document when a merge mode is provided, rather than replacing it
+func (s *BoltDocService) set(key *document.Key, content map[string]interface{}, condition *document.SetCondition, merge *document.MergeMode, newErr errors.ErrorFactory) error {
 	if err := document.ValidateKey(key); err != nil {
 		return newErr(
 			codes.InvalidArgument,
@@ -137,12 +192,64 @@ func (s *BoltDocService) Set(key *document.Key, content map[string]interface{})
 			err,
 		)
 	}
-	defer db.Close()
+	defer s.closeDb(db)
+
+	tx, err := db.Begin(true)
+	if err != nil {
+		return newErr(
+			codes.Internal,
+			"DB transaction error",
+			err,
+		)
+	}
+	defer tx.Rollback()
 
 	doc := createDoc(key)
+
+	existing := BoltDoc{}
+	err = tx.One(idName, doc.Id, &existing)
+	if err != nil && err != storm.ErrNotFound {
+		return newErr(
+			codes.Internal,
+			"DB Fetch error",
+			err,
+		)
+	}
+	exists := err == nil
+
+	if condition != nil {
+		if condition.IfNotExists && exists {
+			return newErr(
+				codes.FailedPrecondition,
+				"document already exists",
+				nil,
+			)
+		}
+
+		if condition.IfVersion > 0 && (!exists || existing.Version != condition.IfVersion) {
+			return newErr(
+				codes.FailedPrecondition,
+				"document version does not match",
+				nil,
+			)
+		}
+	}
+
 	doc.Value = content
+	if merge != nil && exists {
+		doc.Value = document.MergeContent(existing.Value, content, *merge)
+	}
+	doc.Version = existing.Version + 1
 
-	if err := db.Save(&doc); err != nil {
+	if err := tx.Save(&doc); err != nil {
+		return newErr(
+			codes.Internal,
+			"Document save error",
+			err,
+		)
+	}
+
+	if err := tx.Commit(); err != nil {
 		return newErr(
 			codes.Internal,
 			"Document save error",
@@ -177,7 +284,7 @@ func (s *BoltDocService) Delete(key *document.Key) error {
 			err,
 		)
 	}
-	defer db.Close()
+	defer s.closeDb(db)
 
 	doc := createDoc(key)
 
@@ -241,7 +348,7 @@ func (s *BoltDocService) query(collection *document.Collection, expressions []do
 			err,
 		)
 	}
-	defer db.Close()
+	defer s.closeDb(db)
 
 	// Build up chain of expression matchers
 	matchers := []q.Matcher{}
@@ -364,6 +471,66 @@ func (s *BoltDocService) Query(collection *document.Collection, expressions []do
 	return s.query(collection, expressions, limit, pagingToken, newErr)
 }
 
+// GetBatch - gets each document in turn, BoltDocService has no native batch reads
+func (s *BoltDocService) GetBatch(keys []*document.Key) ([]*document.BatchGetResult, error) {
+	newErr := errors.ErrorsWithScope(
+		"BoltDocService.GetBatch",
+		map[string]interface{}{
+			"keys": len(keys),
+		},
+	)
+
+	if err := document.ValidateBatchSize(len(keys)); err != nil {
+		return nil, newErr(
+			codes.InvalidArgument,
+			"invalid batch",
+			err,
+		)
+	}
+
+	return document.GetBatchSequentially(s, keys), nil
+}
+
+// SetBatch - sets each document in turn, BoltDocService has no native batch writes
+func (s *BoltDocService) SetBatch(items []*document.BatchSetItem) ([]*document.BatchResult, error) {
+	newErr := errors.ErrorsWithScope(
+		"BoltDocService.SetBatch",
+		map[string]interface{}{
+			"items": len(items),
+		},
+	)
+
+	if err := document.ValidateBatchSize(len(items)); err != nil {
+		return nil, newErr(
+			codes.InvalidArgument,
+			"invalid batch",
+			err,
+		)
+	}
+
+	return document.SetBatchSequentially(s, items), nil
+}
+
+// DeleteBatch - deletes each document in turn, BoltDocService has no native batch writes
+func (s *BoltDocService) DeleteBatch(keys []*document.Key) ([]*document.BatchResult, error) {
+	newErr := errors.ErrorsWithScope(
+		"BoltDocService.DeleteBatch",
+		map[string]interface{}{
+			"keys": len(keys),
+		},
+	)
+
+	if err := document.ValidateBatchSize(len(keys)); err != nil {
+		return nil, newErr(
+			codes.InvalidArgument,
+			"invalid batch",
+			err,
+		)
+	}
+
+	return document.DeleteBatchSequentially(s, keys), nil
+}
+
 func (s *BoltDocService) QueryStream(collection *document.Collection, expressions []document.QueryExpression, limit int) document.DocumentIterator {
 	newErr := errors.ErrorsWithScope(
 		"BoltDocService.QueryStream",
@@ -372,69 +539,97 @@ func (s *BoltDocService) QueryStream(collection *document.Collection, expression
 		},
 	)
 
-	var tmpLimit = limit
-	var documents []document.Document
-	var pagingToken map[string]string
+	return document.NewPagedIterator(limit, document.StreamPageSize, func(pageLimit int, pagingToken map[string]string) (*document.QueryResult, error) {
+		return s.query(collection, expressions, pageLimit, pagingToken, newErr)
+	})
+}
 
-	// Initial fetch
-	res, fetchErr := s.query(collection, expressions, limit, nil, newErr)
+// Capabilities - Returns the baseline capabilities along with the optional operations the plugin supports
+func (s *BoltDocService) Capabilities() []string {
+	return append(document.BaselineCapabilities(),
+		document.CapabilityConditionalSet,
+		document.CapabilityMerge,
+	)
+}
 
-	if fetchErr != nil {
-		// Return an error only iterator if the initial fetch failed
-		return func() (*document.Document, error) {
-			return nil, fetchErr
-		}
-	}
+// Close - Stops watching the collection files, if enabled
+func (s *BoltDocService) Close() error {
+	s.watcher.Stop()
+	return nil
+}
 
-	documents = res.Documents
-	pagingToken = res.PagingToken
-
-	return func() (*document.Document, error) {
-		// check the iteration state
-		if tmpLimit == 0 && limit > 0 {
-			// we've reached the limit of reading
-			return nil, io.EOF
-		} else if pagingToken != nil && len(documents) == 0 {
-			// we've run out of documents and have more pages to read
-			res, fetchErr = s.query(collection, expressions, tmpLimit, pagingToken, newErr)
-			documents = res.Documents
-			pagingToken = res.PagingToken
-		} else if pagingToken == nil && len(documents) == 0 {
-			// we're all out of documents and pages before hitting the limit
-			return nil, io.EOF
-		}
+// New - Create a new dev KV plugin, watching the collection files for changes by other tools when LOCAL_DB_WATCH is true
+func New() (*BoltDocService, error) {
+	dbDir := utils.GetEnv("LOCAL_DB_DIR", utils.GetRelativeDevPath(DEV_SUB_DIRECTORY))
 
-		// We received an error fetching the docs
-		if fetchErr != nil {
-			return nil, fetchErr
-		}
+	opts := []BoltDocServiceOption{WithDbDir(dbDir)}
 
-		// pop the first element
-		var doc document.Document
-		doc, documents = documents[0], documents[1:]
-		tmpLimit = tmpLimit - 1
+	watchEnv := utils.GetEnv("LOCAL_DB_WATCH", "false")
+	watch, err := strconv.ParseBool(watchEnv)
+	if err != nil {
+		return nil, fmt.Errorf("invalid LOCAL_DB_WATCH env var, expected true or false, got %v", watchEnv)
+	}
 
-		return &doc, nil
+	if watch {
+		opts = append(opts,
+			WithWatch(utils.DefaultWatchInterval),
+			WithLogger(logger.NewJSONLogger(os.Stdout, logger.Level_Info)),
+		)
 	}
+
+	return NewWithOptions(opts...)
 }
 
-// New - Create a new dev KV plugin
-func New() (*BoltDocService, error) {
-	dbDir := utils.GetEnv("LOCAL_DB_DIR", utils.GetRelativeDevPath(DEV_SUB_DIRECTORY))
+// NewWithOptions - Create a new dev KV plugin, storing the collection files in the dev volume unless configured
+func NewWithOptions(opts ...BoltDocServiceOption) (*BoltDocService, error) {
+	s := &BoltDocService{
+		dbDir: utils.GetRelativeDevPath(DEV_SUB_DIRECTORY),
+		log:   logger.NewNoopLogger(),
+	}
+
+	for _, o := range opts {
+		o.Apply(s)
+	}
 
 	// Check whether file exists
-	_, err := os.Stat(dbDir)
+	_, err := os.Stat(s.dbDir)
 	if os.IsNotExist(err) {
 		// Make directory if not present
-		err := os.MkdirAll(dbDir, 0777)
+		err := os.MkdirAll(s.dbDir, 0777)
 		if err != nil {
 			return nil, err
 		}
 	}
 
-	return &BoltDocService{dbDir: dbDir}, nil
+	if s.watch {
+		// Nothing is cached between operations, so reopening the file is enough to check it's readable
+		s.watcher, err = utils.NewFileWatcher(filepath.Join(s.dbDir, "*.db"), s.watchInterval, reopenDb, s.log)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
+// openDb - Opens a collection file, waiting for any other process holding it to close it
+func openDb(dbPath string) (*storm.DB, error) {
+	options := storm.BoltOptions(0600, &bbolt.Options{Timeout: 1 * time.Second})
+	return storm.Open(dbPath, options)
 }
 
+// reopenDb - Opens and closes a collection file, checking a file changed by another tool can be read
+func reopenDb(dbPath string) error {
+	db, err := openDb(dbPath)
+	if err != nil {
+		return err
+	}
+
+	return db.Close()
+}
+
+// createdDb - Opens the file of the collection's top level collection. Reloads of watched files are held off
+// until the file is closed with closeDb
 func (s *BoltDocService) createdDb(coll document.Collection) (*storm.DB, error) {
 	for coll.Parent != nil {
 		coll = *coll.Parent.Collection
@@ -442,15 +637,23 @@ func (s *BoltDocService) createdDb(coll document.Collection) (*storm.DB, error)
 
 	dbPath := filepath.Join(s.dbDir, strings.ToLower(coll.Name)+".db")
 
-	options := storm.BoltOptions(0600, &bbolt.Options{Timeout: 1 * time.Second})
-	db, err := storm.Open(dbPath, options)
+	s.watcher.Acquire()
+	db, err := openDb(dbPath)
 	if err != nil {
+		s.watcher.Release(dbPath)
 		return nil, err
 	}
 
 	return db, nil
 }
 
+// closeDb - Closes a file opened with createdDb
+func (s *BoltDocService) closeDb(db *storm.DB) {
+	dbPath := db.Bolt.Path()
+	db.Close()
+	s.watcher.Release(dbPath)
+}
+
 func createDoc(key *document.Key) BoltDoc {
 
 	parentKey := key.Collection.Parent
@@ -499,6 +702,7 @@ func toSdkDoc(col *document.Collection, doc BoltDoc) *document.Document {
 			Collection: c,
 			Id:         id,
 		},
+		Version: doc.Version,
 	}
 }
 
@@ -0,0 +1,72 @@
+// Copyright 2021 Nitric Pty Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boltdb_service
+
+import (
+	"time"
+
+	"github.com/nitrictech/nitric/pkg/logger"
+)
+
+type BoltDocServiceOption interface {
+	Apply(*BoltDocService)
+}
+
+type withDbDir struct {
+	dir string
+}
+
+func (w *withDbDir) Apply(service *BoltDocService) {
+	service.dbDir = w.dir
+}
+
+// WithDbDir - sets the directory each collection's BoltDB file is stored in, it's created if not present
+func WithDbDir(dir string) BoltDocServiceOption {
+	return &withDbDir{
+		dir: dir,
+	}
+}
+
+type withWatch struct {
+	interval time.Duration
+}
+
+func (w *withWatch) Apply(service *BoltDocService) {
+	service.watch = true
+	service.watchInterval = w.interval
+}
+
+// WithWatch - reloads collection files modified by other tools, checking them at the interval.
+// For local development only, 0 checks at the default interval
+func WithWatch(interval time.Duration) BoltDocServiceOption {
+	return &withWatch{
+		interval: interval,
+	}
+}
+
+type withLogger struct {
+	log logger.Logger
+}
+
+func (w *withLogger) Apply(service *BoltDocService) {
+	service.log = w.log
+}
+
+// WithLogger - sets the logger the reloads of watched collection files are logged to
+func WithLogger(log logger.Logger) BoltDocServiceOption {
+	return &withLogger{
+		log: log,
+	}
+}
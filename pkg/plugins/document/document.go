@@ -48,6 +48,54 @@ func ValidateKey(key *Key) error {
 	return nil
 }
 
+// ValidateSetCondition - validates the condition of a conditional set, exactly one condition must be provided
+func ValidateSetCondition(condition *SetCondition) error {
+	if condition == nil {
+		return fmt.Errorf("provide non-nil condition")
+	}
+	if condition.IfVersion < 0 {
+		return fmt.Errorf("provide non-negative condition.IfVersion")
+	}
+	if condition.IfNotExists && condition.IfVersion > 0 {
+		return fmt.Errorf("provide either condition.IfNotExists or condition.IfVersion, not both")
+	}
+	if !condition.IfNotExists && condition.IfVersion == 0 {
+		return fmt.Errorf("provide condition.IfNotExists or condition.IfVersion")
+	}
+	return nil
+}
+
+// ValidateMergeMode - validates the mode of a merge
+func ValidateMergeMode(mode MergeMode) error {
+	if mode != MergeShallow && mode != MergeDeep {
+		return fmt.Errorf("unknown merge mode %d", mode)
+	}
+	return nil
+}
+
+// MergeContent - returns the existing content with the partial content merged into it, neither map is modified.
+// Used by plugins without a native merge, which read the existing document and set the merged content
+func MergeContent(existing map[string]interface{}, partial map[string]interface{}, mode MergeMode) map[string]interface{} {
+	merged := make(map[string]interface{}, len(existing)+len(partial))
+	for key, value := range existing {
+		merged[key] = value
+	}
+
+	for key, value := range partial {
+		if mode == MergeDeep {
+			existingMap, existingIsMap := merged[key].(map[string]interface{})
+			partialMap, partialIsMap := value.(map[string]interface{})
+			if existingIsMap && partialIsMap {
+				merged[key] = MergeContent(existingMap, partialMap, mode)
+				continue
+			}
+		}
+		merged[key] = value
+	}
+
+	return merged
+}
+
 // ValidateCollection - validates a collection key, used for operations on a single document/collection e.g. Get, Set, Delete
 func ValidateCollection(collection *Collection) error {
 	if collection == nil {
@@ -14,6 +14,8 @@
 package document_test
 
 import (
+	"fmt"
+	"io"
 	"sort"
 
 	"github.com/nitrictech/nitric/pkg/plugins/document"
@@ -283,4 +285,161 @@ var _ = Describe("Document Plugin", func() {
 			})
 		})
 	})
+
+	When("ValidateBatchSize", func() {
+		When("empty batch", func() {
+			It("should return error", func() {
+				err := document.ValidateBatchSize(0)
+				Expect(err).ToNot(BeNil())
+			})
+		})
+		When("batch over the maximum size", func() {
+			It("should return error", func() {
+				err := document.ValidateBatchSize(document.MaxBatchSize + 1)
+				Expect(err).ToNot(BeNil())
+			})
+		})
+		When("batch within the maximum size", func() {
+			It("batch is valid", func() {
+				err := document.ValidateBatchSize(document.MaxBatchSize)
+				Expect(err).To(BeNil())
+			})
+		})
+	})
+
+	When("MergeContent", func() {
+		existing := map[string]interface{}{
+			"name": "John",
+			"address": map[string]interface{}{
+				"city":     "Sydney",
+				"postcode": "2000",
+			},
+		}
+		partial := map[string]interface{}{
+			"email": "john@example.com",
+			"address": map[string]interface{}{
+				"city": "Melbourne",
+			},
+		}
+
+		When("shallow", func() {
+			It("should replace top-level fields", func() {
+				merged := document.MergeContent(existing, partial, document.MergeShallow)
+				Expect(merged).To(Equal(map[string]interface{}{
+					"name":    "John",
+					"email":   "john@example.com",
+					"address": map[string]interface{}{"city": "Melbourne"},
+				}))
+			})
+		})
+		When("deep", func() {
+			It("should merge nested maps", func() {
+				merged := document.MergeContent(existing, partial, document.MergeDeep)
+				Expect(merged).To(Equal(map[string]interface{}{
+					"name":  "John",
+					"email": "john@example.com",
+					"address": map[string]interface{}{
+						"city":     "Melbourne",
+						"postcode": "2000",
+					},
+				}))
+			})
+			It("should not modify the existing content", func() {
+				document.MergeContent(existing, partial, document.MergeDeep)
+				Expect(existing["address"]).To(Equal(map[string]interface{}{
+					"city":     "Sydney",
+					"postcode": "2000",
+				}))
+			})
+		})
+	})
+
+	When("NewPagedIterator", func() {
+		// pagedFetcher - serves count documents in pages, recording the limit of each page requested
+		pagedFetcher := func(count int, limits *[]int) document.PageFetcher {
+			return func(limit int, pagingToken map[string]string) (*document.QueryResult, error) {
+				*limits = append(*limits, limit)
+
+				start := 0
+				if pagingToken != nil {
+					fmt.Sscanf(pagingToken["next"], "%d", &start)
+				}
+
+				end := start + limit
+				if end > count {
+					end = count
+				}
+
+				result := &document.QueryResult{}
+				for i := start; i < end; i++ {
+					result.Documents = append(result.Documents, document.Document{
+						Key: &document.Key{Id: fmt.Sprintf("%d", i)},
+					})
+				}
+				if end < count {
+					result.PagingToken = map[string]string{"next": fmt.Sprintf("%d", end)}
+				}
+
+				return result, nil
+			}
+		}
+
+		drain := func(iter document.DocumentIterator) ([]string, error) {
+			ids := make([]string, 0)
+			for {
+				doc, err := iter()
+				if err == io.EOF {
+					return ids, nil
+				} else if err != nil {
+					return ids, err
+				}
+				ids = append(ids, doc.Key.Id)
+			}
+		}
+
+		When("no limit is provided", func() {
+			It("should fetch every page lazily", func() {
+				limits := make([]int, 0)
+				iter := document.NewPagedIterator(0, 2, pagedFetcher(5, &limits))
+				Expect(limits).To(BeEmpty())
+
+				ids, err := drain(iter)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(ids).To(Equal([]string{"0", "1", "2", "3", "4"}))
+				Expect(limits).To(Equal([]int{2, 2, 2}))
+			})
+		})
+		When("a limit is provided", func() {
+			It("should stop after the limit", func() {
+				limits := make([]int, 0)
+				ids, err := drain(document.NewPagedIterator(3, 2, pagedFetcher(5, &limits)))
+				Expect(err).ToNot(HaveOccurred())
+				Expect(ids).To(Equal([]string{"0", "1", "2"}))
+				Expect(limits).To(Equal([]int{2, 1}))
+			})
+		})
+		When("a page is empty but more pages remain", func() {
+			It("should continue to the next page", func() {
+				pages := []*document.QueryResult{
+					{PagingToken: map[string]string{"next": "1"}},
+					{Documents: []document.Document{{Key: &document.Key{Id: "a"}}}},
+				}
+				ids, err := drain(document.NewPagedIterator(0, 2, func(limit int, pagingToken map[string]string) (*document.QueryResult, error) {
+					page := pages[0]
+					pages = pages[1:]
+					return page, nil
+				}))
+				Expect(err).ToNot(HaveOccurred())
+				Expect(ids).To(Equal([]string{"a"}))
+			})
+		})
+		When("fetching a page fails", func() {
+			It("should return the error", func() {
+				_, err := drain(document.NewPagedIterator(0, 2, func(limit int, pagingToken map[string]string) (*document.QueryResult, error) {
+					return nil, fmt.Errorf("mock-error")
+				}))
+				Expect(err).To(MatchError("mock-error"))
+			})
+		})
+	})
 })
@@ -0,0 +1,500 @@
+// Copyright 2021 Nitric Pty Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dynamodb_service
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/nitrictech/nitric/pkg/plugins/document"
+	"github.com/nitrictech/nitric/pkg/plugins/errors"
+	"github.com/nitrictech/nitric/pkg/plugins/errors/codes"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+)
+
+// maxBatchGet - maximum number of keys DynamoDB will accept in a single BatchGetItem request
+const maxBatchGet = 100
+
+// maxBatchAttempts - number of requests made for a batch before items DynamoDB leaves unprocessed are failed
+const maxBatchAttempts = 5
+
+// batchRetryDelay - delay before the first request for unprocessed items, doubled for each subsequent request
+var batchRetryDelay = 50 * time.Millisecond
+
+var errUnprocessed = fmt.Errorf("item was left unprocessed by DynamoDB, the table's throughput may be exceeded")
+
+// batchItemId - identifies an item across the tables of a batch request
+type batchItemId struct {
+	table string
+	pk    string
+	sk    string
+}
+
+func newBatchItemId(table string, item map[string]*dynamodb.AttributeValue) batchItemId {
+	return batchItemId{
+		table: table,
+		pk:    aws.StringValue(item[AttribPk].S),
+		sk:    aws.StringValue(item[AttribSk].S),
+	}
+}
+
+// batchItem - an item in a batch, along with the indexes of the keys in the batch it was requested for
+type batchItem struct {
+	id      batchItemId
+	key     map[string]*dynamodb.AttributeValue
+	write   *dynamodb.WriteRequest
+	indexes []int
+}
+
+// batchErrorCode - unprocessed items may succeed if tried again later, other errors are internal
+func batchErrorCode(err error) codes.Code {
+	if err == errUnprocessed {
+		return codes.Unavailable
+	}
+	return codes.Internal
+}
+
+func newKeyErr(scope string, key *document.Key) errors.ErrorFactory {
+	return errors.ErrorsWithScope(
+		scope,
+		map[string]interface{}{
+			"key": key,
+		},
+	)
+}
+
+// batchKey - resolves the table and key attributes of a key in a batch
+func (s *DynamoDocService) batchKey(key *document.Key) (batchItemId, map[string]*dynamodb.AttributeValue, error) {
+	tableName, err := s.getTableName(*key.Collection)
+	if err != nil {
+		return batchItemId{}, nil, err
+	}
+
+	attributeMap, err := dynamodbattribute.MarshalMap(createKeyMap(key))
+	if err != nil {
+		return batchItemId{}, nil, err
+	}
+
+	return newBatchItemId(*tableName, attributeMap), attributeMap, nil
+}
+
+// addBatchItem - adds an item to a batch, items requested more than once share a single request
+func addBatchItem(items []*batchItem, itemIndexes map[batchItemId]int, item *batchItem, index int) []*batchItem {
+	if i, ok := itemIndexes[item.id]; ok {
+		// The last write of an item wins
+		if item.write != nil {
+			items[i].write = item.write
+		}
+		items[i].indexes = append(items[i].indexes, index)
+		return items
+	}
+
+	item.indexes = []int{index}
+	itemIndexes[item.id] = len(items)
+	return append(items, item)
+}
+
+// batchGetItems - gets items in a single request, returning the content of the items found
+// and the items that couldn't be retrieved, along with why
+func (s *DynamoDocService) batchGetItems(items []*batchItem) (map[batchItemId]map[string]interface{}, map[batchItemId]bool, error) {
+	found := make(map[batchItemId]map[string]interface{})
+
+	requestItems := make(map[string]*dynamodb.KeysAndAttributes)
+	for _, item := range items {
+		if _, ok := requestItems[item.id.table]; !ok {
+			requestItems[item.id.table] = &dynamodb.KeysAndAttributes{}
+		}
+		requestItems[item.id.table].Keys = append(requestItems[item.id.table].Keys, item.key)
+	}
+
+	for attempt := 0; len(requestItems) > 0; attempt++ {
+		if attempt >= maxBatchAttempts {
+			return found, unprocessedKeys(requestItems), errUnprocessed
+		}
+		if attempt > 0 {
+			time.Sleep(batchRetryDelay << uint(attempt-1))
+		}
+
+		resp, err := s.client.BatchGetItem(&dynamodb.BatchGetItemInput{
+			RequestItems: requestItems,
+		})
+		if err != nil {
+			return found, unprocessedKeys(requestItems), err
+		}
+
+		for table, tableItems := range resp.Responses {
+			for _, tableItem := range tableItems {
+				popVersion(tableItem)
+
+				var itemMap map[string]interface{}
+				if err := dynamodbattribute.UnmarshalMap(tableItem, &itemMap); err != nil {
+					return found, unprocessedKeys(requestItems), fmt.Errorf("error unmarshalling item: %v", err)
+				}
+
+				delete(itemMap, AttribPk)
+				delete(itemMap, AttribSk)
+
+				found[newBatchItemId(table, tableItem)] = itemMap
+			}
+		}
+
+		requestItems = resp.UnprocessedKeys
+	}
+
+	return found, nil, nil
+}
+
+// unprocessedKeys - identifies the items of the keys in a get request
+func unprocessedKeys(requestItems map[string]*dynamodb.KeysAndAttributes) map[batchItemId]bool {
+	ids := make(map[batchItemId]bool)
+	for table, keysAndAttributes := range requestItems {
+		for _, key := range keysAndAttributes.Keys {
+			ids[newBatchItemId(table, key)] = true
+		}
+	}
+	return ids
+}
+
+// batchWriteItems - writes items in a single request, returning the items that weren't written and why
+func (s *DynamoDocService) batchWriteItems(items []*batchItem) ([]*batchItem, error) {
+	pending := make(map[batchItemId]*batchItem)
+	requestItems := make(map[string][]*dynamodb.WriteRequest)
+	for _, item := range items {
+		pending[item.id] = item
+		requestItems[item.id.table] = append(requestItems[item.id.table], item.write)
+	}
+
+	for attempt := 0; len(requestItems) > 0; attempt++ {
+		if attempt >= maxBatchAttempts {
+			return unprocessedItems(pending, requestItems), errUnprocessed
+		}
+		if attempt > 0 {
+			time.Sleep(batchRetryDelay << uint(attempt-1))
+		}
+
+		resp, err := s.client.BatchWriteItem(&dynamodb.BatchWriteItemInput{
+			RequestItems: requestItems,
+		})
+		if err != nil {
+			return unprocessedItems(pending, requestItems), err
+		}
+
+		requestItems = resp.UnprocessedItems
+	}
+
+	return nil, nil
+}
+
+// unprocessedItems - maps write requests back to the batch items they were made for
+func unprocessedItems(pending map[batchItemId]*batchItem, requestItems map[string][]*dynamodb.WriteRequest) []*batchItem {
+	items := make([]*batchItem, 0)
+	for table, writes := range requestItems {
+		for _, write := range writes {
+			var attributes map[string]*dynamodb.AttributeValue
+			if write.PutRequest != nil {
+				attributes = write.PutRequest.Item
+			} else if write.DeleteRequest != nil {
+				attributes = write.DeleteRequest.Key
+			}
+
+			if item, ok := pending[newBatchItemId(table, attributes)]; ok {
+				items = append(items, item)
+			}
+		}
+	}
+	return items
+}
+
+// writeBatch - writes items in requests of up to maxBatchWrite items, setting the error of each item that wasn't written
+func (s *DynamoDocService) writeBatch(items []*batchItem, setErr func(item *batchItem, err error)) {
+	for start := 0; start < len(items); start += maxBatchWrite {
+		end := start + maxBatchWrite
+		if end > len(items) {
+			end = len(items)
+		}
+
+		failed, err := s.batchWriteItems(items[start:end])
+		for _, item := range failed {
+			setErr(item, err)
+		}
+	}
+}
+
+// GetBatch - gets documents using BatchGetItem, missing documents are returned as NotFound errors
+func (s *DynamoDocService) GetBatch(keys []*document.Key) ([]*document.BatchGetResult, error) {
+	newErr := errors.ErrorsWithScope(
+		"DynamoDocService.GetBatch",
+		map[string]interface{}{
+			"keys": len(keys),
+		},
+	)
+
+	if err := document.ValidateBatchSize(len(keys)); err != nil {
+		return nil, newErr(
+			codes.InvalidArgument,
+			"invalid batch",
+			err,
+		)
+	}
+
+	results := make([]*document.BatchGetResult, len(keys))
+	items := make([]*batchItem, 0, len(keys))
+	itemIndexes := make(map[batchItemId]int)
+
+	for i, key := range keys {
+		results[i] = &document.BatchGetResult{Key: key}
+		keyErr := newKeyErr("DynamoDocService.GetBatch", key)
+
+		if err := document.ValidateKey(key); err != nil {
+			results[i].Err = keyErr(
+				codes.InvalidArgument,
+				"invalid key",
+				err,
+			)
+			continue
+		}
+
+		id, keyAttributes, err := s.batchKey(key)
+		if err != nil {
+			results[i].Err = keyErr(
+				codes.NotFound,
+				"unable to find table",
+				err,
+			)
+			continue
+		}
+
+		items = addBatchItem(items, itemIndexes, &batchItem{id: id, key: keyAttributes}, i)
+	}
+
+	for start := 0; start < len(items); start += maxBatchGet {
+		end := start + maxBatchGet
+		if end > len(items) {
+			end = len(items)
+		}
+
+		found, failed, err := s.batchGetItems(items[start:end])
+		for _, item := range items[start:end] {
+			content, ok := found[item.id]
+
+			for _, i := range item.indexes {
+				keyErr := newKeyErr("DynamoDocService.GetBatch", keys[i])
+
+				if ok {
+					results[i].Document = &document.Document{
+						Key:     keys[i],
+						Content: content,
+					}
+				} else if failed[item.id] {
+					results[i].Err = keyErr(
+						batchErrorCode(err),
+						fmt.Sprintf("error retrieving key %v", keys[i]),
+						err,
+					)
+				} else {
+					results[i].Err = keyErr(
+						codes.NotFound,
+						fmt.Sprintf("%v not found", keys[i]),
+						nil,
+					)
+				}
+			}
+		}
+	}
+
+	return results, nil
+}
+
+// SetBatch - sets documents using BatchWriteItem, when a key is set more than once the last content is stored
+func (s *DynamoDocService) SetBatch(batchItems []*document.BatchSetItem) ([]*document.BatchResult, error) {
+	newErr := errors.ErrorsWithScope(
+		"DynamoDocService.SetBatch",
+		map[string]interface{}{
+			"items": len(batchItems),
+		},
+	)
+
+	if err := document.ValidateBatchSize(len(batchItems)); err != nil {
+		return nil, newErr(
+			codes.InvalidArgument,
+			"invalid batch",
+			err,
+		)
+	}
+
+	results := make([]*document.BatchResult, len(batchItems))
+	items := make([]*batchItem, 0, len(batchItems))
+	itemIndexes := make(map[batchItemId]int)
+
+	for i, batchSetItem := range batchItems {
+		results[i] = &document.BatchResult{}
+
+		if batchSetItem == nil {
+			results[i].Err = newErr(
+				codes.InvalidArgument,
+				"invalid batch item",
+				fmt.Errorf("provide non-nil batch item"),
+			)
+			continue
+		}
+
+		key := batchSetItem.Key
+		results[i].Key = key
+		keyErr := newKeyErr("DynamoDocService.SetBatch", key)
+
+		if err := document.ValidateKey(key); err != nil {
+			results[i].Err = keyErr(
+				codes.InvalidArgument,
+				"invalid key",
+				err,
+			)
+			continue
+		}
+
+		if batchSetItem.Content == nil {
+			results[i].Err = keyErr(
+				codes.InvalidArgument,
+				"provide non-nil value",
+				nil,
+			)
+			continue
+		}
+
+		tableName, err := s.getTableName(*key.Collection)
+		if err != nil {
+			results[i].Err = keyErr(
+				codes.NotFound,
+				"unable to find table",
+				err,
+			)
+			continue
+		}
+
+		itemAttributeMap, err := dynamodbattribute.MarshalMap(createItemMap(batchSetItem.Content, key))
+		if err != nil {
+			results[i].Err = keyErr(
+				codes.InvalidArgument,
+				"failed to marshal value",
+				err,
+			)
+			continue
+		}
+
+		items = addBatchItem(items, itemIndexes, &batchItem{
+			id: newBatchItemId(*tableName, itemAttributeMap),
+			write: &dynamodb.WriteRequest{
+				PutRequest: &dynamodb.PutRequest{Item: itemAttributeMap},
+			},
+		}, i)
+	}
+
+	s.writeBatch(items, func(item *batchItem, err error) {
+		for _, i := range item.indexes {
+			results[i].Err = newKeyErr("DynamoDocService.SetBatch", batchItems[i].Key)(
+				batchErrorCode(err),
+				"error putting item",
+				err,
+			)
+		}
+	})
+
+	return results, nil
+}
+
+// DeleteBatch - deletes documents using BatchWriteItem, followed by the sub collection items of deleted root documents
+func (s *DynamoDocService) DeleteBatch(keys []*document.Key) ([]*document.BatchResult, error) {
+	newErr := errors.ErrorsWithScope(
+		"DynamoDocService.DeleteBatch",
+		map[string]interface{}{
+			"keys": len(keys),
+		},
+	)
+
+	if err := document.ValidateBatchSize(len(keys)); err != nil {
+		return nil, newErr(
+			codes.InvalidArgument,
+			"invalid batch",
+			err,
+		)
+	}
+
+	results := make([]*document.BatchResult, len(keys))
+	items := make([]*batchItem, 0, len(keys))
+	itemIndexes := make(map[batchItemId]int)
+
+	for i, key := range keys {
+		results[i] = &document.BatchResult{Key: key}
+		keyErr := newKeyErr("DynamoDocService.DeleteBatch", key)
+
+		if err := document.ValidateKey(key); err != nil {
+			results[i].Err = keyErr(
+				codes.InvalidArgument,
+				"invalid key",
+				err,
+			)
+			continue
+		}
+
+		id, keyAttributes, err := s.batchKey(key)
+		if err != nil {
+			results[i].Err = keyErr(
+				codes.NotFound,
+				"unable to find table",
+				err,
+			)
+			continue
+		}
+
+		items = addBatchItem(items, itemIndexes, &batchItem{
+			id: id,
+			write: &dynamodb.WriteRequest{
+				DeleteRequest: &dynamodb.DeleteRequest{Key: keyAttributes},
+			},
+		}, i)
+	}
+
+	s.writeBatch(items, func(item *batchItem, err error) {
+		for _, i := range item.indexes {
+			results[i].Err = newKeyErr("DynamoDocService.DeleteBatch", keys[i])(
+				batchErrorCode(err),
+				fmt.Sprintf("error deleting %v item %v", keys[i].Collection, keys[i].Id),
+				err,
+			)
+		}
+	})
+
+	// Delete sub collection items of the root documents that were deleted
+	for _, item := range items {
+		i := item.indexes[0]
+		if results[i].Err != nil || keys[i].Collection.Parent != nil {
+			continue
+		}
+
+		if err := s.deleteSubCollectionItems(aws.String(item.id.table), keys[i]); err != nil {
+			for _, i := range item.indexes {
+				results[i].Err = newKeyErr("DynamoDocService.DeleteBatch", keys[i])(
+					codes.Internal,
+					"error performing delete",
+					err,
+				)
+			}
+		}
+	}
+
+	return results, nil
+}
@@ -15,11 +15,14 @@
 package dynamodb_service
 
 import (
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
-	"io"
 	"regexp"
 	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/nitrictech/nitric/pkg/plugins/document"
 	"github.com/nitrictech/nitric/pkg/plugins/errors"
@@ -27,6 +30,7 @@ import (
 	"github.com/nitrictech/nitric/pkg/utils"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
 	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
@@ -35,9 +39,18 @@ import (
 
 const AttribPk = "_pk"
 const AttribSk = "_sk"
+
+// AttribVersion - The attribute holding the document version, changed each time the document is set
+const AttribVersion = "_v"
 const deleteQueryLimit = int64(1000)
 const maxBatchWrite = 25
 
+// maxMergeAttempts - The number of times a deep merge is attempted when the item changes between reading and updating it
+const maxMergeAttempts = 5
+
+// pagingTokenName - the paging token key holding the encoded DynamoDB LastEvaluatedKey
+const pagingTokenName = "token"
+
 // DynamoDocService - AWS DynamoDB AWS Nitric Document service
 type DynamoDocService struct {
 	document.UnimplementedDocumentPlugin
@@ -100,6 +113,8 @@ func (s *DynamoDocService) Get(key *document.Key) (*document.Document, error) {
 		)
 	}
 
+	version := popVersion(result.Item)
+
 	var itemMap map[string]interface{}
 	err = dynamodbattribute.UnmarshalMap(result.Item, &itemMap)
 	if err != nil {
@@ -116,6 +131,7 @@ func (s *DynamoDocService) Get(key *document.Key) (*document.Document, error) {
 	return &document.Document{
 		Key:     key,
 		Content: itemMap,
+		Version: version,
 	}, nil
 }
 
@@ -127,6 +143,31 @@ func (s *DynamoDocService) Set(key *document.Key, value map[string]interface{})
 		},
 	)
 
+	return s.set(key, value, nil, newErr)
+}
+
+// SetWithCondition - puts the item with a condition expression on its existence or version attribute
+func (s *DynamoDocService) SetWithCondition(key *document.Key, value map[string]interface{}, condition *document.SetCondition) error {
+	newErr := errors.ErrorsWithScope(
+		"DynamoDocService.SetWithCondition",
+		map[string]interface{}{
+			"key": key,
+		},
+	)
+
+	if err := document.ValidateSetCondition(condition); err != nil {
+		return newErr(
+			codes.InvalidArgument,
+			"invalid condition",
+			err,
+		)
+	}
+
+	return s.set(key, value, condition, newErr)
+}
+
+// set - puts the item, only if the condition holds when one is provided
+func (s *DynamoDocService) set(key *document.Key, value map[string]interface{}, condition *document.SetCondition, newErr errors.ErrorFactory) error {
 	if err := document.ValidateKey(key); err != nil {
 		return newErr(
 			codes.InvalidArgument,
@@ -145,6 +186,9 @@ func (s *DynamoDocService) Set(key *document.Key, value map[string]interface{})
 
 	// Construct DynamoDB attribute value object
 	itemMap := createItemMap(value, key)
+	if condition != nil {
+		itemMap[AttribVersion] = nextVersion(condition.IfVersion)
+	}
 	itemAttributeMap, err := dynamodbattribute.MarshalMap(itemMap)
 	if err != nil {
 		return fmt.Errorf("failed to marshal value")
@@ -165,8 +209,29 @@ func (s *DynamoDocService) Set(key *document.Key, value map[string]interface{})
 		TableName: tableName,
 	}
 
+	if condition != nil {
+		if condition.IfNotExists {
+			input.ConditionExpression = aws.String("attribute_not_exists(#pk)")
+			input.ExpressionAttributeNames = map[string]*string{"#pk": aws.String(AttribPk)}
+		} else {
+			input.ConditionExpression = aws.String("#v = :v")
+			input.ExpressionAttributeNames = map[string]*string{"#v": aws.String(AttribVersion)}
+			input.ExpressionAttributeValues = map[string]*dynamodb.AttributeValue{
+				":v": {N: aws.String(strconv.FormatInt(condition.IfVersion, 10))},
+			}
+		}
+	}
+
 	_, err = s.client.PutItem(input)
 	if err != nil {
+		if awsErr, ok := err.(awserr.Error); ok && awsErr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+			return newErr(
+				codes.FailedPrecondition,
+				"condition failed",
+				err,
+			)
+		}
+
 		return newErr(
 			codes.Internal,
 			"error putting item",
@@ -177,6 +242,183 @@ func (s *DynamoDocService) Set(key *document.Key, value map[string]interface{})
 	return nil
 }
 
+// Merge - sets the partial content's top-level fields with UpdateItem, creating the item if it doesn't exist.
+// DynamoDB can't update fields of nested maps that don't exist yet, so deep merges read the item and update the
+// merged top-level fields only if the item hasn't changed since it was read, retrying if it has
+func (s *DynamoDocService) Merge(key *document.Key, partial map[string]interface{}, mode document.MergeMode) error {
+	newErr := errors.ErrorsWithScope(
+		"DynamoDocService.Merge",
+		map[string]interface{}{
+			"key": key,
+		},
+	)
+
+	if err := document.ValidateKey(key); err != nil {
+		return newErr(
+			codes.InvalidArgument,
+			"invalid key",
+			err,
+		)
+	}
+
+	if partial == nil {
+		return newErr(
+			codes.InvalidArgument,
+			"provide non-nil value",
+			nil,
+		)
+	}
+
+	if err := document.ValidateMergeMode(mode); err != nil {
+		return newErr(
+			codes.InvalidArgument,
+			"invalid merge mode",
+			err,
+		)
+	}
+
+	tableName, err := s.getTableName(*key.Collection)
+	if err != nil {
+		return newErr(
+			codes.NotFound,
+			"unable to find table",
+			err,
+		)
+	}
+
+	if mode == document.MergeShallow {
+		return s.update(tableName, key, partial, nil, newErr)
+	}
+
+	for attempt := 0; attempt < maxMergeAttempts; attempt++ {
+		existing, err := s.Get(key)
+		if err != nil && errors.Code(err) != codes.NotFound {
+			return newErr(
+				errors.Code(err),
+				"error retrieving item",
+				err,
+			)
+		}
+
+		fields := partial
+		condition := &updateCondition{}
+		if existing != nil {
+			merged := document.MergeContent(existing.Content, partial, document.MergeDeep)
+			fields = make(map[string]interface{}, len(partial))
+			for field := range partial {
+				fields[field] = merged[field]
+			}
+			condition.exists = true
+			condition.version = existing.Version
+		}
+
+		err = s.update(tableName, key, fields, condition, newErr)
+		if err == nil || errors.Code(err) != codes.FailedPrecondition {
+			return err
+		}
+	}
+
+	return newErr(
+		codes.Aborted,
+		"item was changed by concurrent writes while merging",
+		nil,
+	)
+}
+
+// updateCondition - The state an item was read in, updates with a condition are only applied if the item is unchanged
+type updateCondition struct {
+	exists  bool
+	version int64
+}
+
+// update - sets the top-level fields of the item with UpdateItem, creating the item if it doesn't exist.
+// Fields named after the key and version attributes are ignored, as they are when putting items
+func (s *DynamoDocService) update(tableName *string, key *document.Key, fields map[string]interface{}, condition *updateCondition, newErr errors.ErrorFactory) error {
+	keyAttributeMap, err := dynamodbattribute.MarshalMap(createKeyMap(key))
+	if err != nil {
+		return newErr(
+			codes.InvalidArgument,
+			"failed to marshal key",
+			err,
+		)
+	}
+
+	previousVersion := int64(0)
+	if condition != nil {
+		previousVersion = condition.version
+	}
+
+	names := map[string]*string{"#v": aws.String(AttribVersion)}
+	values := map[string]*dynamodb.AttributeValue{
+		":v": {N: aws.String(strconv.FormatInt(nextVersion(previousVersion), 10))},
+	}
+	setExpressions := []string{"#v = :v"}
+
+	// Sorted so the update expression is deterministic
+	sortedFields := make([]string, 0, len(fields))
+	for field := range fields {
+		if field == AttribPk || field == AttribSk || field == AttribVersion {
+			continue
+		}
+		sortedFields = append(sortedFields, field)
+	}
+	sort.Strings(sortedFields)
+
+	for i, field := range sortedFields {
+		value, err := dynamodbattribute.Marshal(fields[field])
+		if err != nil {
+			return newErr(
+				codes.InvalidArgument,
+				fmt.Sprintf("failed to marshal field %s", field),
+				err,
+			)
+		}
+
+		names[fmt.Sprintf("#f%d", i)] = aws.String(field)
+		values[fmt.Sprintf(":f%d", i)] = value
+		setExpressions = append(setExpressions, fmt.Sprintf("#f%d = :f%d", i, i))
+	}
+
+	input := &dynamodb.UpdateItemInput{
+		TableName:                 tableName,
+		Key:                       keyAttributeMap,
+		UpdateExpression:          aws.String("SET " + strings.Join(setExpressions, ", ")),
+		ExpressionAttributeNames:  names,
+		ExpressionAttributeValues: values,
+	}
+
+	if condition != nil {
+		if !condition.exists {
+			input.ConditionExpression = aws.String("attribute_not_exists(#pk)")
+			names["#pk"] = aws.String(AttribPk)
+		} else if condition.version == 0 {
+			// Items put before versioning have no version attribute
+			input.ConditionExpression = aws.String("attribute_not_exists(#v)")
+		} else {
+			input.ConditionExpression = aws.String("#v = :pv")
+			values[":pv"] = &dynamodb.AttributeValue{N: aws.String(strconv.FormatInt(condition.version, 10))}
+		}
+	}
+
+	if _, err := s.client.UpdateItem(input); err != nil {
+		if awsErr, ok := err.(awserr.Error); ok && awsErr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+			return newErr(
+				codes.FailedPrecondition,
+				"item changed while merging",
+				err,
+			)
+		}
+
+		return newErr(
+			codes.Internal,
+			"error updating item",
+			err,
+		)
+	}
+
+	return nil
+}
+
 func (s *DynamoDocService) Delete(key *document.Key) error {
 	newErr := errors.ErrorsWithScope(
 		"DynamoDocService.Delete",
@@ -229,33 +471,12 @@ func (s *DynamoDocService) Delete(key *document.Key) error {
 
 	// Delete sub collection items
 	if key.Collection.Parent == nil {
-
-		var lastEvaluatedKey map[string]*dynamodb.AttributeValue
-		for {
-			queryInput := createDeleteQuery(tableName, key, lastEvaluatedKey)
-			resp, err := s.client.Query(queryInput)
-			if err != nil {
-				return newErr(
-					codes.Internal,
-					"error performing delete in table",
-					err,
-				)
-			}
-
-			lastEvaluatedKey = resp.LastEvaluatedKey
-
-			err = s.processDeleteQuery(*tableName, resp)
-			if err != nil {
-				return newErr(
-					codes.Internal,
-					"error performing delete",
-					err,
-				)
-			}
-
-			if len(lastEvaluatedKey) == 0 {
-				break
-			}
+		if err := s.deleteSubCollectionItems(tableName, key); err != nil {
+			return newErr(
+				codes.Internal,
+				"error performing delete",
+				err,
+			)
 		}
 	}
 
@@ -306,6 +527,14 @@ func (s *DynamoDocService) Query(collection *document.Collection, expressions []
 		)
 	}
 
+	if _, err := decodePagingToken(pagingToken); err != nil {
+		return nil, newErr(
+			codes.InvalidArgument,
+			"invalid paging token",
+			err,
+		)
+	}
+
 	queryResult, err := s.query(collection, expressions, limit, pagingToken)
 	if err != nil {
 		return nil, newErr(
@@ -360,62 +589,27 @@ func (s *DynamoDocService) QueryStream(collection *document.Collection, expressi
 		}
 	}
 
-	var tmpLimit = limit
-	var documents []document.Document
-	var pagingToken map[string]string
-
-	// Initial fetch
-	res, fetchErr := s.query(collection, expressions, tmpLimit, nil)
-
-	if fetchErr != nil {
-		// Return an error only iterator if the initial fetch failed
-		return func() (*document.Document, error) {
-			return nil, newErr(
-				codes.Internal,
-				"query error",
-				fetchErr,
-			)
-		}
-	}
-
-	documents = res.Documents
-	pagingToken = res.PagingToken
-
-	return func() (*document.Document, error) {
-		// check the iteration state
-		if tmpLimit <= 0 && limit > 0 {
-			// we've reached the limit of reading
-			return nil, io.EOF
-		} else if pagingToken != nil && len(documents) == 0 {
-			// we've run out of documents and have more pages to read
-			res, fetchErr = s.query(collection, expressions, tmpLimit, pagingToken)
-			documents = res.Documents
-			pagingToken = res.PagingToken
-		} else if pagingToken == nil && len(documents) == 0 {
-			// we're all out of documents and pages before hitting the limit
-			return nil, io.EOF
-		}
-
-		// We received an error fetching the docs
-		if fetchErr != nil {
+	// Pages are queried as they're read, so streams over large tables don't read the whole table up front
+	return document.NewPagedIterator(limit, document.StreamPageSize, func(pageLimit int, pagingToken map[string]string) (*document.QueryResult, error) {
+		res, err := s.query(collection, expressions, pageLimit, pagingToken)
+		if err != nil {
 			return nil, newErr(
 				codes.Internal,
 				"query error",
-				fetchErr,
+				err,
 			)
 		}
 
-		if len(documents) == 0 {
-			return nil, io.EOF
-		}
-
-		// pop the first element
-		var doc document.Document
-		doc, documents = documents[0], documents[1:]
-		tmpLimit = tmpLimit - 1
+		return res, nil
+	})
+}
 
-		return &doc, nil
-	}
+// Capabilities - Returns the baseline capabilities along with the optional operations the plugin supports
+func (s *DynamoDocService) Capabilities() []string {
+	return append(document.BaselineCapabilities(),
+		document.CapabilityConditionalSet,
+		document.CapabilityMerge,
+	)
 }
 
 // New - Create a new DynamoDB key value plugin implementation
@@ -441,7 +635,7 @@ func New() (document.DocumentService, error) {
 }
 
 // NewWithClient - Mainly used for testing
-func NewWithClient(client *dynamodb.DynamoDB) (document.DocumentService, error) {
+func NewWithClient(client dynamodbiface.DynamoDBAPI) (document.DocumentService, error) {
 	return &DynamoDocService{
 		client:         client,
 		tableNameCache: map[string]*string{},
@@ -479,10 +673,35 @@ func createItemMap(source map[string]interface{}, key *document.Key) map[string]
 	// Add key attributes
 	newMap[AttribPk] = keyMap[AttribPk]
 	newMap[AttribSk] = keyMap[AttribSk]
+	newMap[AttribVersion] = nextVersion(0)
 
 	return newMap
 }
 
+// nextVersion - Returns a new document version, always different from the previous version.
+// Versions are timestamps so unconditional sets don't need to read the previous version
+func nextVersion(previous int64) int64 {
+	version := time.Now().UnixNano()
+	if version <= previous {
+		version = previous + 1
+	}
+
+	return version
+}
+
+// popVersion - Removes the version attribute from an item, returning the version.
+// The version is parsed from the attribute directly, as unmarshalling it to a float64 loses precision
+func popVersion(item map[string]*dynamodb.AttributeValue) int64 {
+	attr, ok := item[AttribVersion]
+	if !ok {
+		return 0
+	}
+	delete(item, AttribVersion)
+
+	version, _ := strconv.ParseInt(aws.StringValue(attr.N), 10, 64)
+	return version
+}
+
 type resultRetriever = func(
 	collection *document.Collection,
 	expressions []document.QueryExpression,
@@ -555,11 +774,11 @@ func (s *DynamoDocService) performQuery(
 		limit64 := int64(limit)
 		input.Limit = &(limit64)
 
-		if len(pagingToken) > 0 {
-			startKey, err := dynamodbattribute.MarshalMap(pagingToken)
-			if err != nil {
-				return nil, fmt.Errorf("error performing query %v: %v", input, err)
-			}
+		startKey, err := decodePagingToken(pagingToken)
+		if err != nil {
+			return nil, fmt.Errorf("error performing query %v: %v", input, err)
+		}
+		if len(startKey) > 0 {
 			input.SetExclusiveStartKey(startKey)
 		}
 	}
@@ -635,11 +854,11 @@ func (s *DynamoDocService) performScan(
 		limit64 := int64(limit)
 		input.Limit = &(limit64)
 
-		if len(pagingToken) > 0 {
-			startKey, err := dynamodbattribute.MarshalMap(pagingToken)
-			if err != nil {
-				return nil, fmt.Errorf("error performing scan %v: %v", input, err)
-			}
+		startKey, err := decodePagingToken(pagingToken)
+		if err != nil {
+			return nil, fmt.Errorf("error performing scan %v: %v", input, err)
+		}
+		if len(startKey) > 0 {
 			input.SetExclusiveStartKey(startKey)
 		}
 	}
@@ -654,6 +873,11 @@ func (s *DynamoDocService) performScan(
 }
 
 func marshalQueryResult(collection *document.Collection, items []map[string]*dynamodb.AttributeValue, lastEvaluatedKey map[string]*dynamodb.AttributeValue) (*document.QueryResult, error) {
+	versions := make([]int64, len(items))
+	for i, item := range items {
+		versions[i] = popVersion(item)
+	}
+
 	// Unmarshal Dynamo response items
 	var pTkn map[string]string = nil
 	var valueMaps []map[string]interface{}
@@ -664,7 +888,7 @@ func marshalQueryResult(collection *document.Collection, items []map[string]*dyn
 	docs := make([]document.Document, 0, len(valueMaps))
 
 	// Strip keys & append results
-	for _, m := range valueMaps {
+	for i, m := range valueMaps {
 		// Retrieve the original ID on the result
 		var id string
 		var c *document.Collection
@@ -700,14 +924,15 @@ func marshalQueryResult(collection *document.Collection, items []map[string]*dyn
 				Id:         id,
 			},
 			Content: m,
+			Version: versions[i],
 		}
 		docs = append(docs, sdkDoc)
 	}
 
-	// Unmarshal lastEvalutedKey
-	var resultPagingToken map[string]string
+	// Encode lastEvalutedKey
 	if len(lastEvaluatedKey) > 0 {
-		if err := dynamodbattribute.UnmarshalMap(lastEvaluatedKey, &resultPagingToken); err != nil {
+		resultPagingToken, err := encodePagingToken(lastEvaluatedKey)
+		if err != nil {
 			return nil, fmt.Errorf("error unmarshalling query lastEvaluatedKey: %v", err)
 		}
 		pTkn = resultPagingToken
@@ -719,6 +944,47 @@ func marshalQueryResult(collection *document.Collection, items []map[string]*dyn
 	}, nil
 }
 
+// encodePagingToken - encodes a DynamoDB LastEvaluatedKey as an opaque, URL safe paging token
+func encodePagingToken(lastEvaluatedKey map[string]*dynamodb.AttributeValue) (map[string]string, error) {
+	var keyMap map[string]string
+	if err := dynamodbattribute.UnmarshalMap(lastEvaluatedKey, &keyMap); err != nil {
+		return nil, err
+	}
+
+	keyBytes, err := json.Marshal(keyMap)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]string{
+		pagingTokenName: base64.RawURLEncoding.EncodeToString(keyBytes),
+	}, nil
+}
+
+// decodePagingToken - decodes a paging token produced by encodePagingToken back into a DynamoDB ExclusiveStartKey
+func decodePagingToken(pagingToken map[string]string) (map[string]*dynamodb.AttributeValue, error) {
+	if len(pagingToken) == 0 {
+		return nil, nil
+	}
+
+	token, ok := pagingToken[pagingTokenName]
+	if !ok || len(pagingToken) > 1 {
+		return nil, fmt.Errorf("paging token must only contain a %s value", pagingTokenName)
+	}
+
+	keyBytes, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, fmt.Errorf("malformed paging token: %v", err)
+	}
+
+	var keyMap map[string]string
+	if err := json.Unmarshal(keyBytes, &keyMap); err != nil {
+		return nil, fmt.Errorf("malformed paging token: %v", err)
+	}
+
+	return dynamodbattribute.MarshalMap(keyMap)
+}
+
 func createFilterExpression(expressions []document.QueryExpression) string {
 
 	keyExp := ""
@@ -829,6 +1095,28 @@ func createDeleteQuery(table *string, key *document.Key, startKey map[string]*dy
 	}
 }
 
+// deleteSubCollectionItems - deletes the items in the sub collections of a root document
+func (s *DynamoDocService) deleteSubCollectionItems(tableName *string, key *document.Key) error {
+	var lastEvaluatedKey map[string]*dynamodb.AttributeValue
+	for {
+		queryInput := createDeleteQuery(tableName, key, lastEvaluatedKey)
+		resp, err := s.client.Query(queryInput)
+		if err != nil {
+			return fmt.Errorf("error performing delete in table: %v", err)
+		}
+
+		lastEvaluatedKey = resp.LastEvaluatedKey
+
+		if err := s.processDeleteQuery(*tableName, resp); err != nil {
+			return err
+		}
+
+		if len(lastEvaluatedKey) == 0 {
+			return nil
+		}
+	}
+}
+
 func (s *DynamoDocService) processDeleteQuery(table string, resp *dynamodb.QueryOutput) error {
 	itemIndex := 0
 	for itemIndex < len(resp.Items) {
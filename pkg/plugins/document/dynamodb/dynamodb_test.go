@@ -0,0 +1,357 @@
+// Copyright 2021 Nitric Pty Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dynamodb_service_test
+
+import (
+	"fmt"
+	"net/url"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/golang/mock/gomock"
+	mocks "github.com/nitrictech/nitric/mocks/dynamodb"
+	"github.com/nitrictech/nitric/pkg/plugins/document"
+	dynamodb_service "github.com/nitrictech/nitric/pkg/plugins/document/dynamodb"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("DynamoDB Document Plugin", func() {
+	collection := &document.Collection{
+		Name: "orders",
+		Parent: &document.Key{
+			Collection: &document.Collection{
+				Name: "customers",
+			},
+			Id: "customer-1",
+		},
+	}
+
+	lastEvaluatedKey := map[string]*dynamodb.AttributeValue{
+		"_pk": {S: aws.String("customer-1")},
+		"_sk": {S: aws.String("orders#order-1")},
+	}
+
+	When("Querying a page of results", func() {
+		When("More results are available", func() {
+			ctrl := gomock.NewController(GinkgoT())
+			mockClient := mocks.NewMockDynamoDBAPI(ctrl)
+			docPlugin, _ := dynamodb_service.NewWithClient(mockClient)
+
+			It("Should return an opaque URL safe paging token", func() {
+				defer ctrl.Finish()
+
+				mockClient.EXPECT().ListTables(gomock.Any()).Return(&dynamodb.ListTablesOutput{
+					TableNames: []*string{aws.String("customers-1111111")},
+				}, nil).Times(1)
+				mockClient.EXPECT().Query(gomock.Any()).DoAndReturn(func(input *dynamodb.QueryInput) (*dynamodb.QueryOutput, error) {
+					By("Translating the collection into a key condition")
+					Expect(*input.KeyConditionExpression).To(Equal("#pk = :pk AND begins_with(#sk, :sk)"))
+					Expect(*input.ExpressionAttributeValues[":pk"].S).To(Equal("customer-1"))
+					Expect(*input.ExpressionAttributeValues[":sk"].S).To(Equal("orders#"))
+					Expect(*input.Limit).To(Equal(int64(1)))
+
+					return &dynamodb.QueryOutput{
+						Items: []map[string]*dynamodb.AttributeValue{
+							lastEvaluatedKey,
+						},
+						LastEvaluatedKey: lastEvaluatedKey,
+					}, nil
+				}).Times(1)
+
+				result, err := docPlugin.Query(collection, []document.QueryExpression{}, 1, nil)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(result.Documents).To(HaveLen(1))
+				Expect(result.PagingToken).To(HaveLen(1))
+
+				By("Not exposing the underlying table keys")
+				Expect(result.PagingToken).ToNot(HaveKey("_pk"))
+				Expect(result.PagingToken).ToNot(HaveKey("_sk"))
+
+				for _, token := range result.PagingToken {
+					Expect(url.QueryEscape(token)).To(Equal(token))
+				}
+			})
+		})
+
+		When("A paging token is provided", func() {
+			ctrl := gomock.NewController(GinkgoT())
+			mockClient := mocks.NewMockDynamoDBAPI(ctrl)
+			docPlugin, _ := dynamodb_service.NewWithClient(mockClient)
+
+			It("Should resume from the last evaluated key", func() {
+				defer ctrl.Finish()
+
+				mockClient.EXPECT().ListTables(gomock.Any()).Return(&dynamodb.ListTablesOutput{
+					TableNames: []*string{aws.String("customers-1111111")},
+				}, nil).Times(1)
+
+				var startKey map[string]*dynamodb.AttributeValue
+				mockClient.EXPECT().Query(gomock.Any()).DoAndReturn(func(input *dynamodb.QueryInput) (*dynamodb.QueryOutput, error) {
+					if startKey == nil {
+						return &dynamodb.QueryOutput{
+							Items:            []map[string]*dynamodb.AttributeValue{lastEvaluatedKey},
+							LastEvaluatedKey: lastEvaluatedKey,
+						}, nil
+					}
+					Expect(input.ExclusiveStartKey).To(Equal(startKey))
+					return &dynamodb.QueryOutput{}, nil
+				}).Times(2)
+
+				firstPage, err := docPlugin.Query(collection, []document.QueryExpression{}, 1, nil)
+				Expect(err).ShouldNot(HaveOccurred())
+
+				startKey = lastEvaluatedKey
+				secondPage, err := docPlugin.Query(collection, []document.QueryExpression{}, 1, firstPage.PagingToken)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(secondPage.Documents).To(BeEmpty())
+				Expect(secondPage.PagingToken).To(BeEmpty())
+			})
+		})
+
+		When("A malformed paging token is provided", func() {
+			ctrl := gomock.NewController(GinkgoT())
+			mockClient := mocks.NewMockDynamoDBAPI(ctrl)
+			docPlugin, _ := dynamodb_service.NewWithClient(mockClient)
+
+			It("Should return an invalid argument error", func() {
+				_, err := docPlugin.Query(collection, []document.QueryExpression{}, 1, map[string]string{
+					"_pk": "customer-1",
+				})
+				Expect(err).Should(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("invalid paging token"))
+			})
+		})
+	})
+
+	When("Getting a batch of documents", func() {
+		orderKey := func(id string) *document.Key {
+			return &document.Key{Collection: collection, Id: id}
+		}
+
+		When("DynamoDB leaves keys unprocessed", func() {
+			ctrl := gomock.NewController(GinkgoT())
+			mockClient := mocks.NewMockDynamoDBAPI(ctrl)
+			docPlugin, _ := dynamodb_service.NewWithClient(mockClient)
+
+			It("Should request the unprocessed keys again", func() {
+				defer ctrl.Finish()
+
+				mockClient.EXPECT().ListTables(gomock.Any()).Return(&dynamodb.ListTablesOutput{
+					TableNames: []*string{aws.String("customers-1111111")},
+				}, nil).Times(1)
+
+				order2Key := map[string]*dynamodb.AttributeValue{
+					"_pk": {S: aws.String("customer-1")},
+					"_sk": {S: aws.String("orders#order-2")},
+				}
+
+				gomock.InOrder(
+					mockClient.EXPECT().BatchGetItem(gomock.Any()).DoAndReturn(func(input *dynamodb.BatchGetItemInput) (*dynamodb.BatchGetItemOutput, error) {
+						Expect(input.RequestItems["customers-1111111"].Keys).To(HaveLen(3))
+
+						return &dynamodb.BatchGetItemOutput{
+							Responses: map[string][]map[string]*dynamodb.AttributeValue{
+								"customers-1111111": {lastEvaluatedKey},
+							},
+							UnprocessedKeys: map[string]*dynamodb.KeysAndAttributes{
+								"customers-1111111": {Keys: []map[string]*dynamodb.AttributeValue{order2Key}},
+							},
+						}, nil
+					}).Times(1),
+					mockClient.EXPECT().BatchGetItem(gomock.Any()).DoAndReturn(func(input *dynamodb.BatchGetItemInput) (*dynamodb.BatchGetItemOutput, error) {
+						Expect(input.RequestItems["customers-1111111"].Keys).To(Equal([]map[string]*dynamodb.AttributeValue{order2Key}))
+
+						return &dynamodb.BatchGetItemOutput{
+							Responses: map[string][]map[string]*dynamodb.AttributeValue{
+								"customers-1111111": {order2Key},
+							},
+						}, nil
+					}).Times(1),
+				)
+
+				results, err := docPlugin.GetBatch([]*document.Key{
+					orderKey("order-1"),
+					orderKey("order-2"),
+					orderKey("order-3"),
+					{Collection: collection},
+				})
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(results).To(HaveLen(4))
+
+				By("Returning the documents found")
+				Expect(results[0].Err).ShouldNot(HaveOccurred())
+				Expect(results[0].Document.Key.Id).To(Equal("order-1"))
+				Expect(results[1].Err).ShouldNot(HaveOccurred())
+				Expect(results[1].Document.Key.Id).To(Equal("order-2"))
+
+				By("Returning an error for the missing document")
+				Expect(results[2].Document).To(BeNil())
+				Expect(results[2].Err.Error()).To(ContainSubstring("not found"))
+
+				By("Returning an error for the invalid key")
+				Expect(results[3].Err.Error()).To(ContainSubstring("invalid key"))
+			})
+		})
+
+		When("No keys are provided", func() {
+			ctrl := gomock.NewController(GinkgoT())
+			mockClient := mocks.NewMockDynamoDBAPI(ctrl)
+			docPlugin, _ := dynamodb_service.NewWithClient(mockClient)
+
+			It("Should return an invalid argument error", func() {
+				_, err := docPlugin.GetBatch([]*document.Key{})
+				Expect(err).Should(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("invalid batch"))
+			})
+		})
+	})
+
+	When("Setting a batch of documents", func() {
+		When("The same key is set more than once", func() {
+			ctrl := gomock.NewController(GinkgoT())
+			mockClient := mocks.NewMockDynamoDBAPI(ctrl)
+			docPlugin, _ := dynamodb_service.NewWithClient(mockClient)
+
+			It("Should only write the last content", func() {
+				defer ctrl.Finish()
+
+				mockClient.EXPECT().ListTables(gomock.Any()).Return(&dynamodb.ListTablesOutput{
+					TableNames: []*string{aws.String("customers-1111111")},
+				}, nil).Times(1)
+
+				mockClient.EXPECT().BatchWriteItem(gomock.Any()).DoAndReturn(func(input *dynamodb.BatchWriteItemInput) (*dynamodb.BatchWriteItemOutput, error) {
+					writes := input.RequestItems["customers-1111111"]
+					Expect(writes).To(HaveLen(1))
+					Expect(*writes[0].PutRequest.Item["status"].S).To(Equal("shipped"))
+
+					return &dynamodb.BatchWriteItemOutput{}, nil
+				}).Times(1)
+
+				key := &document.Key{Collection: collection, Id: "order-1"}
+				results, err := docPlugin.SetBatch([]*document.BatchSetItem{
+					{Key: key, Content: map[string]interface{}{"status": "pending"}},
+					{Key: key, Content: map[string]interface{}{"status": "shipped"}},
+				})
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(results).To(HaveLen(2))
+				Expect(results[0].Err).ShouldNot(HaveOccurred())
+				Expect(results[1].Err).ShouldNot(HaveOccurred())
+			})
+		})
+
+		When("The write fails", func() {
+			ctrl := gomock.NewController(GinkgoT())
+			mockClient := mocks.NewMockDynamoDBAPI(ctrl)
+			docPlugin, _ := dynamodb_service.NewWithClient(mockClient)
+
+			It("Should return an error for each document", func() {
+				defer ctrl.Finish()
+
+				mockClient.EXPECT().ListTables(gomock.Any()).Return(&dynamodb.ListTablesOutput{
+					TableNames: []*string{aws.String("customers-1111111")},
+				}, nil).Times(1)
+
+				mockClient.EXPECT().BatchWriteItem(gomock.Any()).Return(nil, fmt.Errorf("mock-error")).Times(1)
+
+				results, err := docPlugin.SetBatch([]*document.BatchSetItem{
+					{Key: &document.Key{Collection: collection, Id: "order-1"}, Content: map[string]interface{}{}},
+					{Key: &document.Key{Collection: collection, Id: "order-2"}, Content: map[string]interface{}{}},
+					{Key: &document.Key{Collection: collection, Id: "order-3"}},
+				})
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(results[0].Err.Error()).To(ContainSubstring("mock-error"))
+				Expect(results[1].Err.Error()).To(ContainSubstring("mock-error"))
+				Expect(results[2].Err.Error()).To(ContainSubstring("provide non-nil value"))
+			})
+		})
+	})
+
+	When("Merging into a document", func() {
+		When("The merge is shallow", func() {
+			ctrl := gomock.NewController(GinkgoT())
+			mockClient := mocks.NewMockDynamoDBAPI(ctrl)
+			docPlugin, _ := dynamodb_service.NewWithClient(mockClient)
+
+			It("Should set the top-level fields with a single update", func() {
+				defer ctrl.Finish()
+
+				mockClient.EXPECT().ListTables(gomock.Any()).Return(&dynamodb.ListTablesOutput{
+					TableNames: []*string{aws.String("customers-1111111")},
+				}, nil).Times(1)
+
+				mockClient.EXPECT().UpdateItem(gomock.Any()).DoAndReturn(func(input *dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error) {
+					Expect(*input.TableName).To(Equal("customers-1111111"))
+					Expect(*input.Key["_sk"].S).To(Equal("orders#order-1"))
+					Expect(*input.UpdateExpression).To(Equal("SET #v = :v, #f0 = :f0, #f1 = :f1"))
+					Expect(*input.ExpressionAttributeNames["#f0"]).To(Equal("address"))
+					Expect(*input.ExpressionAttributeNames["#f1"]).To(Equal("status"))
+					Expect(*input.ExpressionAttributeValues[":f1"].S).To(Equal("shipped"))
+					Expect(input.ConditionExpression).To(BeNil())
+
+					return &dynamodb.UpdateItemOutput{}, nil
+				}).Times(1)
+
+				err := docPlugin.Merge(&document.Key{Collection: collection, Id: "order-1"}, map[string]interface{}{
+					"status":  "shipped",
+					"address": map[string]interface{}{"city": "Sydney"},
+				}, document.MergeShallow)
+				Expect(err).ShouldNot(HaveOccurred())
+			})
+		})
+
+		When("The merge is deep", func() {
+			ctrl := gomock.NewController(GinkgoT())
+			mockClient := mocks.NewMockDynamoDBAPI(ctrl)
+			docPlugin, _ := dynamodb_service.NewWithClient(mockClient)
+
+			It("Should update the merged fields if the item is unchanged", func() {
+				defer ctrl.Finish()
+
+				mockClient.EXPECT().ListTables(gomock.Any()).Return(&dynamodb.ListTablesOutput{
+					TableNames: []*string{aws.String("customers-1111111")},
+				}, nil).Times(1)
+
+				mockClient.EXPECT().GetItem(gomock.Any()).Return(&dynamodb.GetItemOutput{
+					Item: map[string]*dynamodb.AttributeValue{
+						"_pk": {S: aws.String("customer-1")},
+						"_sk": {S: aws.String("orders#order-1")},
+						"_v":  {N: aws.String("42")},
+						"address": {M: map[string]*dynamodb.AttributeValue{
+							"city":     {S: aws.String("Sydney")},
+							"postcode": {S: aws.String("2000")},
+						}},
+					},
+				}, nil).Times(1)
+
+				mockClient.EXPECT().UpdateItem(gomock.Any()).DoAndReturn(func(input *dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error) {
+					Expect(*input.ConditionExpression).To(Equal("#v = :pv"))
+					Expect(*input.ExpressionAttributeValues[":pv"].N).To(Equal("42"))
+
+					address := input.ExpressionAttributeValues[":f0"].M
+					Expect(*address["city"].S).To(Equal("Melbourne"))
+					Expect(*address["postcode"].S).To(Equal("2000"))
+
+					return &dynamodb.UpdateItemOutput{}, nil
+				}).Times(1)
+
+				err := docPlugin.Merge(&document.Key{Collection: collection, Id: "order-1"}, map[string]interface{}{
+					"address": map[string]interface{}{"city": "Melbourne"},
+				}, document.MergeDeep)
+				Expect(err).ShouldNot(HaveOccurred())
+			})
+		})
+	})
+})
@@ -41,6 +41,42 @@ type FirestoreDocService struct {
 	document.UnimplementedDocumentPlugin
 }
 
+// Close - Closes the Firestore client and its connections
+func (s *FirestoreDocService) Close() error {
+	return s.client.Close()
+}
+
+// firestoreErrorCode - Translates the gRPC status of a Firestore error to the equivalent nitric code
+func firestoreErrorCode(err error) codes.Code {
+	switch status.Code(err) {
+	case grpcCodes.NotFound:
+		return codes.NotFound
+	case grpcCodes.AlreadyExists:
+		return codes.AlreadyExists
+	case grpcCodes.InvalidArgument:
+		return codes.InvalidArgument
+	case grpcCodes.FailedPrecondition:
+		// e.g. a query requires an index that hasn't been created
+		return codes.FailedPrecondition
+	case grpcCodes.PermissionDenied:
+		return codes.PermissionDenied
+	case grpcCodes.Unauthenticated:
+		return codes.Unauthenticated
+	case grpcCodes.ResourceExhausted:
+		return codes.ResourceExhausted
+	case grpcCodes.DeadlineExceeded:
+		return codes.DeadlineExceeded
+	case grpcCodes.Aborted:
+		return codes.Aborted
+	case grpcCodes.Unavailable:
+		return codes.Unavailable
+	case grpcCodes.Canceled:
+		return codes.Cancelled
+	}
+
+	return codes.Internal
+}
+
 func (s *FirestoreDocService) Get(key *document.Key) (*document.Document, error) {
 	newErr := errors.ErrorsWithScope(
 		"FirestoreDocService.Get",
@@ -61,13 +97,8 @@ func (s *FirestoreDocService) Get(key *document.Key) (*document.Document, error)
 
 	value, err := doc.Get(s.context)
 	if err != nil {
-		var code = codes.Internal
-		if status.Code(err) == grpcCodes.NotFound {
-			code = codes.NotFound
-		}
-
 		return nil, newErr(
-			code,
+			firestoreErrorCode(err),
 			"unable to retrieve value",
 			err,
 		)
@@ -76,9 +107,18 @@ func (s *FirestoreDocService) Get(key *document.Key) (*document.Document, error)
 	return &document.Document{
 		Key:     key,
 		Content: value.Data(),
+		Version: snapshotVersion(value),
 	}, nil
 }
 
+// snapshotVersion - The version of a document is the time it was last updated
+func snapshotVersion(snp *firestore.DocumentSnapshot) int64 {
+	return snp.UpdateTime.UnixNano()
+}
+
+// errConditionFailed - Returned from set transactions when the stored document doesn't match the condition
+var errConditionFailed = fmt.Errorf("condition failed")
+
 func (s *FirestoreDocService) Set(key *document.Key, value map[string]interface{}) error {
 	newErr := errors.ErrorsWithScope(
 		"FirestoreDocService.Set",
@@ -107,7 +147,151 @@ func (s *FirestoreDocService) Set(key *document.Key, value map[string]interface{
 
 	if _, err := doc.Set(s.context, value); err != nil {
 		return newErr(
-			codes.Internal,
+			firestoreErrorCode(err),
+			"error updating value",
+			err,
+		)
+	}
+
+	return nil
+}
+
+// Merge - sets the document with a merge option, so fields that aren't in the partial content are left untouched.
+// Shallow merges replace the partial content's top-level fields, deep merges replace only its leaf fields
+func (s *FirestoreDocService) Merge(key *document.Key, partial map[string]interface{}, mode document.MergeMode) error {
+	newErr := errors.ErrorsWithScope(
+		"FirestoreDocService.Merge",
+		map[string]interface{}{
+			"key": key,
+		},
+	)
+
+	if err := document.ValidateKey(key); err != nil {
+		return newErr(
+			codes.InvalidArgument,
+			"invalid key",
+			err,
+		)
+	}
+
+	if partial == nil {
+		return newErr(
+			codes.InvalidArgument,
+			"provide non-nil value",
+			nil,
+		)
+	}
+
+	if err := document.ValidateMergeMode(mode); err != nil {
+		return newErr(
+			codes.InvalidArgument,
+			"invalid merge mode",
+			err,
+		)
+	}
+
+	// An empty partial has no field paths to merge, MergeAll special cases it to create the document if it doesn't exist
+	option := firestore.MergeAll
+	if mode == document.MergeShallow && len(partial) > 0 {
+		paths := make([]firestore.FieldPath, 0, len(partial))
+		for field := range partial {
+			paths = append(paths, firestore.FieldPath{field})
+		}
+		option = firestore.Merge(paths...)
+	}
+
+	if _, err := s.getDocRef(key).Set(s.context, partial, option); err != nil {
+		return newErr(
+			firestoreErrorCode(err),
+			"error merging value",
+			err,
+		)
+	}
+
+	return nil
+}
+
+// SetWithCondition - creates the document when it must not exist, otherwise sets it in a transaction
+// that checks the document's update time matches the expected version
+func (s *FirestoreDocService) SetWithCondition(key *document.Key, value map[string]interface{}, condition *document.SetCondition) error {
+	newErr := errors.ErrorsWithScope(
+		"FirestoreDocService.SetWithCondition",
+		map[string]interface{}{
+			"key": key,
+		},
+	)
+
+	if err := document.ValidateKey(key); err != nil {
+		return newErr(
+			codes.InvalidArgument,
+			"invalid key",
+			err,
+		)
+	}
+
+	if value == nil {
+		return newErr(
+			codes.InvalidArgument,
+			"provide non-nil value",
+			nil,
+		)
+	}
+
+	if err := document.ValidateSetCondition(condition); err != nil {
+		return newErr(
+			codes.InvalidArgument,
+			"invalid condition",
+			err,
+		)
+	}
+
+	doc := s.getDocRef(key)
+
+	if condition.IfNotExists {
+		if _, err := doc.Create(s.context, value); err != nil {
+			if status.Code(err) == grpcCodes.AlreadyExists {
+				return newErr(
+					codes.FailedPrecondition,
+					"document already exists",
+					err,
+				)
+			}
+
+			return newErr(
+				firestoreErrorCode(err),
+				"error creating value",
+				err,
+			)
+		}
+
+		return nil
+	}
+
+	err := s.client.RunTransaction(s.context, func(ctx context.Context, tx *firestore.Transaction) error {
+		snp, err := tx.Get(doc)
+		if err != nil {
+			if status.Code(err) == grpcCodes.NotFound {
+				return errConditionFailed
+			}
+			return err
+		}
+
+		if snapshotVersion(snp) != condition.IfVersion {
+			return errConditionFailed
+		}
+
+		return tx.Set(doc, value)
+	})
+
+	if err == errConditionFailed {
+		return newErr(
+			codes.FailedPrecondition,
+			"document version does not match",
+			err,
+		)
+	} else if err != nil {
+		return newErr(
+			firestoreErrorCode(err),
 			"error updating value",
 			err,
 		)
@@ -134,15 +318,32 @@ func (s *FirestoreDocService) Delete(key *document.Key) error {
 
 	doc := s.getDocRef(key)
 
-	// Delete any sub collection documents
+	if err := s.deleteSubCollections(doc); err != nil {
+		return newErr(
+			firestoreErrorCode(err),
+			"error deleting sub collection values",
+			err,
+		)
+	}
+
+	// Delete document
+	if _, err := doc.Delete(s.context); err != nil {
+		return newErr(
+			firestoreErrorCode(err),
+			"error deleting value",
+			err,
+		)
+	}
+
+	return nil
+}
+
+// deleteSubCollections - deletes every document in the sub collections of a document
+func (s *FirestoreDocService) deleteSubCollections(doc *firestore.DocumentRef) error {
 	collsIter := doc.Collections(s.context)
 	for subCol, err := collsIter.Next(); err != iterator.Done; subCol, err = collsIter.Next() {
 		if err != nil {
-			return newErr(
-				codes.Internal,
-				"error deleting value",
-				err,
-			)
+			return err
 		}
 
 		// Loop over sub collection documents, performing batch deletes
@@ -167,23 +368,240 @@ func (s *FirestoreDocService) Delete(key *document.Key) error {
 				break
 			}
 
-			_, err := batch.Commit(s.context)
-			if err != nil {
+			if _, err := batch.Commit(s.context); err != nil {
 				return err
 			}
 		}
 	}
 
-	// Delete document
-	if _, err := doc.Delete(s.context); err != nil {
-		return newErr(
-			codes.Internal,
-			"error deleting value",
+	return nil
+}
+
+// GetBatch - gets the documents in a single request, missing documents are returned as NotFound errors
+func (s *FirestoreDocService) GetBatch(keys []*document.Key) ([]*document.BatchGetResult, error) {
+	newErr := errors.ErrorsWithScope(
+		"FirestoreDocService.GetBatch",
+		map[string]interface{}{
+			"keys": len(keys),
+		},
+	)
+
+	if err := document.ValidateBatchSize(len(keys)); err != nil {
+		return nil, newErr(
+			codes.InvalidArgument,
+			"invalid batch",
 			err,
 		)
 	}
 
-	return nil
+	results := make([]*document.BatchGetResult, len(keys))
+	refs := make([]*firestore.DocumentRef, 0, len(keys))
+	// The index of the key each ref was created for
+	refIndexes := make([]int, 0, len(keys))
+
+	for i, key := range keys {
+		results[i] = &document.BatchGetResult{Key: key}
+
+		if err := document.ValidateKey(key); err != nil {
+			results[i].Err = newKeyErr("FirestoreDocService.GetBatch", key)(
+				codes.InvalidArgument,
+				"invalid key",
+				err,
+			)
+			continue
+		}
+
+		refs = append(refs, s.getDocRef(key))
+		refIndexes = append(refIndexes, i)
+	}
+
+	if len(refs) == 0 {
+		return results, nil
+	}
+
+	snapshots, err := s.client.GetAll(s.context, refs)
+	if err != nil {
+		for _, i := range refIndexes {
+			results[i].Err = newKeyErr("FirestoreDocService.GetBatch", keys[i])(
+				firestoreErrorCode(err),
+				"unable to retrieve value",
+				err,
+			)
+		}
+		return results, nil
+	}
+
+	for n, snapshot := range snapshots {
+		i := refIndexes[n]
+
+		if !snapshot.Exists() {
+			results[i].Err = newKeyErr("FirestoreDocService.GetBatch", keys[i])(
+				codes.NotFound,
+				"unable to retrieve value",
+				fmt.Errorf("document not found"),
+			)
+			continue
+		}
+
+		results[i].Document = &document.Document{
+			Key:     keys[i],
+			Content: snapshot.Data(),
+			Version: snapshotVersion(snapshot),
+		}
+	}
+
+	return results, nil
+}
+
+// SetBatch - sets the documents in a single batched write, a failed write fails every document in the batch
+func (s *FirestoreDocService) SetBatch(items []*document.BatchSetItem) ([]*document.BatchResult, error) {
+	newErr := errors.ErrorsWithScope(
+		"FirestoreDocService.SetBatch",
+		map[string]interface{}{
+			"items": len(items),
+		},
+	)
+
+	if err := document.ValidateBatchSize(len(items)); err != nil {
+		return nil, newErr(
+			codes.InvalidArgument,
+			"invalid batch",
+			err,
+		)
+	}
+
+	results := make([]*document.BatchResult, len(items))
+	batch := s.client.Batch()
+	batched := make([]int, 0, len(items))
+
+	for i, item := range items {
+		results[i] = &document.BatchResult{}
+
+		if item == nil {
+			results[i].Err = newErr(
+				codes.InvalidArgument,
+				"invalid batch item",
+				fmt.Errorf("provide non-nil batch item"),
+			)
+			continue
+		}
+
+		results[i].Key = item.Key
+		keyErr := newKeyErr("FirestoreDocService.SetBatch", item.Key)
+
+		if err := document.ValidateKey(item.Key); err != nil {
+			results[i].Err = keyErr(
+				codes.InvalidArgument,
+				"invalid key",
+				err,
+			)
+			continue
+		}
+
+		if item.Content == nil {
+			results[i].Err = keyErr(
+				codes.InvalidArgument,
+				"provide non-nil value",
+				nil,
+			)
+			continue
+		}
+
+		batch.Set(s.getDocRef(item.Key), item.Content)
+		batched = append(batched, i)
+	}
+
+	if len(batched) == 0 {
+		return results, nil
+	}
+
+	if _, err := batch.Commit(s.context); err != nil {
+		for _, i := range batched {
+			results[i].Err = newKeyErr("FirestoreDocService.SetBatch", items[i].Key)(
+				firestoreErrorCode(err),
+				"error updating value",
+				err,
+			)
+		}
+	}
+
+	return results, nil
+}
+
+// DeleteBatch - deletes the documents in a single batched write, after deleting each document's sub collections
+func (s *FirestoreDocService) DeleteBatch(keys []*document.Key) ([]*document.BatchResult, error) {
+	newErr := errors.ErrorsWithScope(
+		"FirestoreDocService.DeleteBatch",
+		map[string]interface{}{
+			"keys": len(keys),
+		},
+	)
+
+	if err := document.ValidateBatchSize(len(keys)); err != nil {
+		return nil, newErr(
+			codes.InvalidArgument,
+			"invalid batch",
+			err,
+		)
+	}
+
+	results := make([]*document.BatchResult, len(keys))
+	batch := s.client.Batch()
+	batched := make([]int, 0, len(keys))
+
+	for i, key := range keys {
+		results[i] = &document.BatchResult{Key: key}
+		keyErr := newKeyErr("FirestoreDocService.DeleteBatch", key)
+
+		if err := document.ValidateKey(key); err != nil {
+			results[i].Err = keyErr(
+				codes.InvalidArgument,
+				"invalid key",
+				err,
+			)
+			continue
+		}
+
+		doc := s.getDocRef(key)
+
+		if err := s.deleteSubCollections(doc); err != nil {
+			results[i].Err = keyErr(
+				firestoreErrorCode(err),
+				"error deleting sub collection values",
+				err,
+			)
+			continue
+		}
+
+		batch.Delete(doc)
+		batched = append(batched, i)
+	}
+
+	if len(batched) == 0 {
+		return results, nil
+	}
+
+	if _, err := batch.Commit(s.context); err != nil {
+		for _, i := range batched {
+			results[i].Err = newKeyErr("FirestoreDocService.DeleteBatch", keys[i])(
+				firestoreErrorCode(err),
+				"error deleting value",
+				err,
+			)
+		}
+	}
+
+	return results, nil
+}
+
+// newKeyErr - scopes the errors of a single document in a batch to its key
+func newKeyErr(scope string, key *document.Key) errors.ErrorFactory {
+	return errors.ErrorsWithScope(
+		scope,
+		map[string]interface{}{
+			"key": key,
+		},
+	)
 }
 
 //
@@ -262,7 +680,7 @@ func (s *FirestoreDocService) Query(collection *document.Collection, expressions
 	for docSnp, err := itr.Next(); err != iterator.Done; docSnp, err = itr.Next() {
 		if err != nil {
 			return nil, newErr(
-				codes.Internal,
+				firestoreErrorCode(err),
 				"error querying value",
 				err,
 			)
@@ -323,7 +741,7 @@ func (s *FirestoreDocService) QueryStream(collection *document.Collection, expre
 			}
 
 			return nil, newErr(
-				codes.Internal,
+				firestoreErrorCode(err),
 				"error querying value",
 				err,
 			)
@@ -336,25 +754,37 @@ func (s *FirestoreDocService) QueryStream(collection *document.Collection, expre
 }
 
 func docSnpToDocument(col *document.Collection, snp *firestore.DocumentSnapshot) document.Document {
-	sdkDoc := document.Document{
+	return document.Document{
 		Content: snp.Data(),
-		Key: &document.Key{
-			Collection: col,
-			Id:         snp.Ref.ID,
-		},
+		Key:     docRefToKey(col, snp.Ref),
+		Version: snapshotVersion(snp),
+	}
+}
+
+// docRefToKey - Builds the key of a document from its reference, filling in the parent ids
+// of the queried collection, which are blank for collection group queries
+func docRefToKey(col *document.Collection, ref *firestore.DocumentRef) *document.Key {
+	key := &document.Key{
+		Collection: col,
+		Id:         ref.ID,
 	}
 
-	if p := snp.Ref.Parent.Parent; p != nil {
-		sdkDoc.Key.Collection = &document.Collection{
-			Name: col.Name,
-			Parent: &document.Key{
-				Collection: col.Parent.Collection,
-				Id:         p.ID,
-			},
+	if p := ref.Parent.Parent; p != nil && col.Parent != nil {
+		key.Collection = &document.Collection{
+			Name:   col.Name,
+			Parent: docRefToKey(col.Parent.Collection, p),
 		}
 	}
 
-	return sdkDoc
+	return key
+}
+
+// Capabilities - Returns the baseline capabilities along with the optional operations the plugin supports
+func (s *FirestoreDocService) Capabilities() []string {
+	return append(document.BaselineCapabilities(),
+		document.CapabilityConditionalSet,
+		document.CapabilityMerge,
+	)
 }
 
 func New() (document.DocumentService, error) {
@@ -384,17 +814,16 @@ func NewWithClient(client *firestore.Client, ctx context.Context) (document.Docu
 }
 
 func (s *FirestoreDocService) getDocRef(key *document.Key) *firestore.DocumentRef {
-	parentKey := key.Collection.Parent
-
-	if parentKey == nil {
-		return s.client.Collection(key.Collection.Name).Doc(key.Id)
+	return s.getCollectionRef(key.Collection).Doc(key.Id)
+}
 
-	} else {
-		return s.client.Collection(parentKey.Collection.Name).
-			Doc(parentKey.Id).
-			Collection(key.Collection.Name).
-			Doc(key.Id)
+// getCollectionRef - Returns the Firestore collection for a nitric collection, nesting sub collections under their parent documents
+func (s *FirestoreDocService) getCollectionRef(collection *document.Collection) *firestore.CollectionRef {
+	if collection.Parent == nil {
+		return s.client.Collection(collection.Name)
 	}
+
+	return s.getDocRef(collection.Parent).Collection(collection.Name)
 }
 
 func (s *FirestoreDocService) getQueryRoot(collection *document.Collection) firestore.Query {
@@ -404,10 +833,7 @@ func (s *FirestoreDocService) getQueryRoot(collection *document.Collection) fire
 		return s.client.Collection(collection.Name).Offset(0)
 	} else {
 		if parentKey.Id != "" {
-			return s.client.Collection(parentKey.Collection.Name).
-				Doc(parentKey.Id).
-				Collection(collection.Name).
-				Offset(0)
+			return s.getCollectionRef(collection).Offset(0)
 		} else {
 			// Note there is a risk of subcollection name collison
 			// TODO: future YAML validation could help mitigate this
@@ -0,0 +1,44 @@
+// Copyright 2021 Nitric Pty Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package firestore_service
+
+import (
+	"fmt"
+
+	"github.com/nitrictech/nitric/pkg/plugins/errors/codes"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	grpcCodes "google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var _ = Describe("Firestore", func() {
+	Context("firestoreErrorCode", func() {
+		When("The error has a gRPC status", func() {
+			It("Should translate the status code", func() {
+				Expect(firestoreErrorCode(status.Error(grpcCodes.NotFound, "missing"))).To(Equal(codes.NotFound))
+				Expect(firestoreErrorCode(status.Error(grpcCodes.FailedPrecondition, "index required"))).To(Equal(codes.FailedPrecondition))
+				Expect(firestoreErrorCode(status.Error(grpcCodes.PermissionDenied, "denied"))).To(Equal(codes.PermissionDenied))
+				Expect(firestoreErrorCode(status.Error(grpcCodes.Unavailable, "unavailable"))).To(Equal(codes.Unavailable))
+			})
+		})
+
+		When("The error has no gRPC status", func() {
+			It("Should return Internal", func() {
+				Expect(firestoreErrorCode(fmt.Errorf("mock-error"))).To(Equal(codes.Internal))
+			})
+		})
+	})
+})
@@ -40,6 +40,9 @@ const (
 	primaryKeyAttr = "_id"
 	parentKeyAttr  = "_parent_id"
 	childrenAttr   = "_child_colls"
+
+	// how long to wait for in use connections when closing the client
+	disconnectTimeout = 10 * time.Second
 )
 
 // Mapping to mongo operators, startsWith will be handled within the function
@@ -59,6 +62,14 @@ type MongoDocService struct {
 	document.UnimplementedDocumentPlugin
 }
 
+// Close - Disconnects the MongoDB client, waiting for in use connections to be returned to the pool
+func (s *MongoDocService) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), disconnectTimeout)
+	defer cancel()
+
+	return s.client.Disconnect(ctx)
+}
+
 func (s *MongoDocService) Get(key *document.Key) (*document.Document, error) {
 	newErr := errors.ErrorsWithScope(
 		"MongoDocService.Get",
@@ -359,6 +370,66 @@ func (s *MongoDocService) Query(collection *document.Collection, expressions []d
 	return queryResult, nil
 }
 
+// GetBatch - gets each document in turn, MongoDocService has no native batch reads
+func (s *MongoDocService) GetBatch(keys []*document.Key) ([]*document.BatchGetResult, error) {
+	newErr := errors.ErrorsWithScope(
+		"MongoDocService.GetBatch",
+		map[string]interface{}{
+			"keys": len(keys),
+		},
+	)
+
+	if err := document.ValidateBatchSize(len(keys)); err != nil {
+		return nil, newErr(
+			codes.InvalidArgument,
+			"invalid batch",
+			err,
+		)
+	}
+
+	return document.GetBatchSequentially(s, keys), nil
+}
+
+// SetBatch - sets each document in turn, MongoDocService has no native batch writes
+func (s *MongoDocService) SetBatch(items []*document.BatchSetItem) ([]*document.BatchResult, error) {
+	newErr := errors.ErrorsWithScope(
+		"MongoDocService.SetBatch",
+		map[string]interface{}{
+			"items": len(items),
+		},
+	)
+
+	if err := document.ValidateBatchSize(len(items)); err != nil {
+		return nil, newErr(
+			codes.InvalidArgument,
+			"invalid batch",
+			err,
+		)
+	}
+
+	return document.SetBatchSequentially(s, items), nil
+}
+
+// DeleteBatch - deletes each document in turn, MongoDocService has no native batch writes
+func (s *MongoDocService) DeleteBatch(keys []*document.Key) ([]*document.BatchResult, error) {
+	newErr := errors.ErrorsWithScope(
+		"MongoDocService.DeleteBatch",
+		map[string]interface{}{
+			"keys": len(keys),
+		},
+	)
+
+	if err := document.ValidateBatchSize(len(keys)); err != nil {
+		return nil, newErr(
+			codes.InvalidArgument,
+			"invalid batch",
+			err,
+		)
+	}
+
+	return document.DeleteBatchSequentially(s, keys), nil
+}
+
 func (s *MongoDocService) QueryStream(collection *document.Collection, expressions []document.QueryExpression, limit int) document.DocumentIterator {
 	newErr := errors.ErrorsWithScope(
 		"MongoDocService.QueryStream",
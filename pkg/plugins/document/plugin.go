@@ -14,7 +14,10 @@
 
 package document
 
-import "fmt"
+import (
+	"fmt"
+	"time"
+)
 
 // MaxSubCollectionDepth - maximum number of parents a collection can support.
 // Depth is a count of the number of parents for a collection.
@@ -22,6 +25,24 @@ import "fmt"
 // a collection with a parent has a depth of 1
 const MaxSubCollectionDepth int = 1
 
+// The capabilities a document plugin may support, named after the operations of the document service
+const (
+	CapabilityGet            = "get"
+	CapabilitySet            = "set"
+	CapabilityDelete         = "delete"
+	CapabilityQuery          = "query"
+	CapabilityQueryStream    = "query_stream"
+	CapabilityBatch          = "batch"
+	CapabilitySetWithTtl     = "set_with_ttl"
+	CapabilityConditionalSet = "conditional_set"
+	CapabilityMerge          = "merge"
+)
+
+// BaselineCapabilities - Returns the capabilities supported by every document plugin
+func BaselineCapabilities() []string {
+	return []string{CapabilityGet, CapabilitySet, CapabilityDelete, CapabilityQuery, CapabilityQueryStream, CapabilityBatch}
+}
+
 type Collection struct {
 	Name   string `log:"Name"`
 	Parent *Key   `log:"Parent"`
@@ -35,6 +56,9 @@ type Key struct {
 type Document struct {
 	Key     *Key
 	Content map[string]interface{}
+	// An opaque version that changes each time the document is set, used as the expected version of conditional sets.
+	// 0 when the plugin doesn't track versions
+	Version int64
 }
 
 type QueryExpression struct {
@@ -50,15 +74,77 @@ type QueryResult struct {
 
 type DocumentIterator = func() (*Document, error)
 
+// MergeMode - How a partial document is merged into an existing document
+type MergeMode int
+
+const (
+	// MergeShallow - Replaces the existing top-level fields present in the partial document
+	MergeShallow MergeMode = iota
+	// MergeDeep - Merges nested maps field by field, replacing only the leaf fields present in the partial document
+	MergeDeep
+)
+
+// BatchSetItem - A document to be set as part of a batch
+type BatchSetItem struct {
+	Key     *Key
+	Content map[string]interface{}
+}
+
+// BatchGetResult - The result of getting a single document in a batch, Err is set when the document couldn't be retrieved
+type BatchGetResult struct {
+	Key      *Key
+	Document *Document
+	Err      error
+}
+
+// BatchResult - The result of setting or deleting a single document in a batch, Err is set when the operation failed
+type BatchResult struct {
+	Key *Key
+	Err error
+}
+
 // The base Document Plugin interface
 // Use this over proto definitions to remove dependency on protobuf in the plugin internally
 // and open options to adding additional non-grpc interfaces
 type DocumentService interface {
 	Get(*Key) (*Document, error)
 	Set(*Key, map[string]interface{}) error
+	// Merge - Merges the partial content into the existing document, leaving fields not in the partial content untouched.
+	// The document is created from the partial content if it doesn't exist
+	Merge(*Key, map[string]interface{}, MergeMode) error
 	Delete(*Key) error
 	Query(*Collection, []QueryExpression, int, map[string]string) (*QueryResult, error)
 	QueryStream(*Collection, []QueryExpression, int) DocumentIterator
+	// Batch operations return a result for each key, in the order provided, so one bad key doesn't fail the whole batch.
+	// An error is only returned when the batch itself is invalid.
+	GetBatch([]*Key) ([]*BatchGetResult, error)
+	SetBatch([]*BatchSetItem) ([]*BatchResult, error)
+	DeleteBatch([]*Key) ([]*BatchResult, error)
+	// Capabilities - Returns the operations supported by the plugin, so clients can degrade gracefully without them
+	Capabilities() []string
+	// Close - Releases the clients and background resources held by the plugin, called once on shutdown
+	Close() error
+}
+
+// ExpiringDocumentService - optional interface for document plugins that support
+// setting documents that are removed once their time to live has elapsed
+type ExpiringDocumentService interface {
+	SetWithTtl(*Key, map[string]interface{}, time.Duration) error
+}
+
+// SetCondition - A condition that must hold for a conditional set to be applied,
+// sets whose condition fails return a FailedPrecondition error
+type SetCondition struct {
+	// Only set the document if it doesn't already exist
+	IfNotExists bool
+	// Only set the document if it exists at this version, as returned with the document by Get
+	IfVersion int64
+}
+
+// ConditionalDocumentService - optional interface for document plugins that support
+// compare-and-set of documents, for optimistic concurrency
+type ConditionalDocumentService interface {
+	SetWithCondition(*Key, map[string]interface{}, *SetCondition) error
 }
 
 type UnimplementedDocumentPlugin struct {
@@ -73,6 +159,10 @@ func (p *UnimplementedDocumentPlugin) Set(key *Key, content map[string]interface
 	return fmt.Errorf("UNIMPLEMENTED")
 }
 
+func (p *UnimplementedDocumentPlugin) Merge(key *Key, partial map[string]interface{}, mode MergeMode) error {
+	return fmt.Errorf("UNIMPLEMENTED")
+}
+
 func (p *UnimplementedDocumentPlugin) Delete(key *Key) error {
 	return fmt.Errorf("UNIMPLEMENTED")
 }
@@ -86,3 +176,25 @@ func (p *UnimplementedDocumentPlugin) QueryStream(collection *Collection, expres
 		return nil, fmt.Errorf("UNIMPLEMENTED")
 	}
 }
+
+func (p *UnimplementedDocumentPlugin) GetBatch(keys []*Key) ([]*BatchGetResult, error) {
+	return nil, fmt.Errorf("UNIMPLEMENTED")
+}
+
+func (p *UnimplementedDocumentPlugin) SetBatch(items []*BatchSetItem) ([]*BatchResult, error) {
+	return nil, fmt.Errorf("UNIMPLEMENTED")
+}
+
+func (p *UnimplementedDocumentPlugin) DeleteBatch(keys []*Key) ([]*BatchResult, error) {
+	return nil, fmt.Errorf("UNIMPLEMENTED")
+}
+
+// Capabilities - Plugins support the baseline capabilities unless they declare others
+func (p *UnimplementedDocumentPlugin) Capabilities() []string {
+	return BaselineCapabilities()
+}
+
+// Close - Plugins without resources to release have nothing to close
+func (p *UnimplementedDocumentPlugin) Close() error {
+	return nil
+}
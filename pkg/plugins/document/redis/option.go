@@ -0,0 +1,34 @@
+// Copyright 2021 Nitric Pty Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis_service
+
+type RedisDocServiceOption interface {
+	Apply(*RedisDocService)
+}
+
+type withKeyPrefix struct {
+	prefix string
+}
+
+func (w *withKeyPrefix) Apply(service *RedisDocService) {
+	service.keyPrefix = w.prefix
+}
+
+// WithKeyPrefix - sets the prefix used to namespace document keys, allowing multiple applications to share a Redis instance
+func WithKeyPrefix(prefix string) RedisDocServiceOption {
+	return &withKeyPrefix{
+		prefix: prefix,
+	}
+}
@@ -0,0 +1,725 @@
+// Copyright 2021 Nitric Pty Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis_service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/nitrictech/nitric/pkg/plugins/document"
+	"github.com/nitrictech/nitric/pkg/plugins/errors"
+	"github.com/nitrictech/nitric/pkg/plugins/errors/codes"
+	"github.com/nitrictech/nitric/pkg/utils"
+)
+
+const (
+	defaultKeyPrefix = "nitric"
+	// scanBatchSize - number of keys requested per SCAN call and fetched per pipeline when querying
+	scanBatchSize       = 1000
+	startAfterTokenName = "startAfter"
+)
+
+// RedisDocService - stores documents as JSON, with keys namespaced by their collection
+// e.g. {prefix}:{collection}#{id} or {prefix}:{parentCollection}/{parentId}/{collection}#{id}
+// Names and ids are path escaped, so they can't be confused with separators or SCAN match patterns.
+type RedisDocService struct {
+	document.UnimplementedDocumentPlugin
+	client    redis.UniversalClient
+	keyPrefix string
+}
+
+// Close - Closes the Redis client and its connection pool
+func (s *RedisDocService) Close() error {
+	return s.client.Close()
+}
+
+func escape(value string) string {
+	return url.PathEscape(value)
+}
+
+// collectionPrefix - returns the key prefix shared by all documents in a collection
+func (s *RedisDocService) collectionPrefix(collection *document.Collection) string {
+	if collection.Parent == nil {
+		return s.keyPrefix + ":" + escape(collection.Name) + "#"
+	}
+
+	return s.keyPrefix + ":" + escape(collection.Parent.Collection.Name) + "/" + escape(collection.Parent.Id) + "/" + escape(collection.Name) + "#"
+}
+
+// collectionPattern - returns the SCAN match pattern for documents in a collection,
+// a blank parent id matches the sub-collection documents of every parent
+func (s *RedisDocService) collectionPattern(collection *document.Collection) string {
+	if collection.Parent != nil && collection.Parent.Id == "" {
+		return s.keyPrefix + ":" + escape(collection.Parent.Collection.Name) + "/*/" + escape(collection.Name) + "#*"
+	}
+
+	return s.collectionPrefix(collection) + "*"
+}
+
+func (s *RedisDocService) redisKey(key *document.Key) string {
+	return s.collectionPrefix(key.Collection) + escape(key.Id)
+}
+
+// documentKey - translates a redis key into a document key in the given collection
+func (s *RedisDocService) documentKey(collection *document.Collection, redisKey string) (*document.Key, error) {
+	path := strings.TrimPrefix(redisKey, s.keyPrefix+":")
+
+	// '#' is escaped within names and ids, so this will always be the id separator
+	sepIdx := strings.LastIndex(path, "#")
+	if sepIdx < 0 {
+		return nil, fmt.Errorf("invalid document key %s", redisKey)
+	}
+
+	id, err := url.PathUnescape(path[sepIdx+1:])
+	if err != nil {
+		return nil, err
+	}
+
+	if collection.Parent == nil {
+		return &document.Key{
+			Collection: collection,
+			Id:         id,
+		}, nil
+	}
+
+	segments := strings.Split(path[:sepIdx], "/")
+	if len(segments) != 3 {
+		return nil, fmt.Errorf("invalid sub-collection document key %s", redisKey)
+	}
+
+	parentId, err := url.PathUnescape(segments[1])
+	if err != nil {
+		return nil, err
+	}
+
+	return &document.Key{
+		Collection: &document.Collection{
+			Name: collection.Name,
+			Parent: &document.Key{
+				Collection: collection.Parent.Collection,
+				Id:         parentId,
+			},
+		},
+		Id: id,
+	}, nil
+}
+
+func (s *RedisDocService) Get(key *document.Key) (*document.Document, error) {
+	newErr := errors.ErrorsWithScope(
+		"RedisDocService.Get",
+		map[string]interface{}{
+			"key": key,
+		},
+	)
+
+	if err := document.ValidateKey(key); err != nil {
+		return nil, newErr(
+			codes.InvalidArgument,
+			"invalid key",
+			err,
+		)
+	}
+
+	value, err := s.client.Get(context.Background(), s.redisKey(key)).Bytes()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, newErr(
+				codes.NotFound,
+				"document not found",
+				err,
+			)
+		}
+		return nil, newErr(
+			codes.Internal,
+			"error getting document",
+			err,
+		)
+	}
+
+	content := make(map[string]interface{})
+	if err := json.Unmarshal(value, &content); err != nil {
+		return nil, newErr(
+			codes.Internal,
+			"error unmarshalling document",
+			err,
+		)
+	}
+
+	return &document.Document{
+		Key:     key,
+		Content: content,
+	}, nil
+}
+
+func (s *RedisDocService) set(key *document.Key, content map[string]interface{}, ttl time.Duration, newErr errors.ErrorFactory) error {
+	if err := document.ValidateKey(key); err != nil {
+		return newErr(
+			codes.InvalidArgument,
+			"invalid key",
+			err,
+		)
+	}
+
+	if content == nil {
+		return newErr(
+			codes.InvalidArgument,
+			"invalid content",
+			fmt.Errorf("provide non-nil content"),
+		)
+	}
+
+	if ttl < 0 {
+		return newErr(
+			codes.InvalidArgument,
+			"invalid ttl",
+			fmt.Errorf("provide non-negative ttl"),
+		)
+	}
+
+	value, err := json.Marshal(content)
+	if err != nil {
+		return newErr(
+			codes.InvalidArgument,
+			"error marshalling document",
+			err,
+		)
+	}
+
+	// A zero expiration stores the document without a ttl
+	if err := s.client.Set(context.Background(), s.redisKey(key), value, ttl).Err(); err != nil {
+		return newErr(
+			codes.Internal,
+			"error setting document",
+			err,
+		)
+	}
+
+	return nil
+}
+
+func (s *RedisDocService) Set(key *document.Key, content map[string]interface{}) error {
+	newErr := errors.ErrorsWithScope(
+		"RedisDocService.Set",
+		map[string]interface{}{
+			"key": key,
+		},
+	)
+
+	return s.set(key, content, 0, newErr)
+}
+
+// SetWithTtl - sets a document that will be removed once the ttl has elapsed
+func (s *RedisDocService) SetWithTtl(key *document.Key, content map[string]interface{}, ttl time.Duration) error {
+	newErr := errors.ErrorsWithScope(
+		"RedisDocService.SetWithTtl",
+		map[string]interface{}{
+			"key": key,
+			"ttl": ttl.String(),
+		},
+	)
+
+	return s.set(key, content, ttl, newErr)
+}
+
+func (s *RedisDocService) Delete(key *document.Key) error {
+	newErr := errors.ErrorsWithScope(
+		"RedisDocService.Delete",
+		map[string]interface{}{
+			"key": key,
+		},
+	)
+
+	if err := document.ValidateKey(key); err != nil {
+		return newErr(
+			codes.InvalidArgument,
+			"invalid key",
+			err,
+		)
+	}
+
+	ctx := context.Background()
+	keys := []string{s.redisKey(key)}
+
+	// Delete sub collection documents
+	if key.Collection.Parent == nil {
+		childKeys, err := s.scanKeys(ctx, s.keyPrefix+":"+escape(key.Collection.Name)+"/"+escape(key.Id)+"/*")
+		if err != nil {
+			return newErr(
+				codes.Internal,
+				"error finding sub collection documents",
+				err,
+			)
+		}
+		keys = append(keys, childKeys...)
+	}
+
+	// Keys are deleted individually as they may belong to different cluster slots
+	_, err := s.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, k := range keys {
+			pipe.Del(ctx, k)
+		}
+		return nil
+	})
+	if err != nil {
+		return newErr(
+			codes.Internal,
+			"error deleting document",
+			err,
+		)
+	}
+
+	return nil
+}
+
+type scanner interface {
+	Scan(ctx context.Context, cursor uint64, match string, count int64) *redis.ScanCmd
+}
+
+// scanKeys - returns the keys matching the given pattern, from every master node when clustered
+func (s *RedisDocService) scanKeys(ctx context.Context, pattern string) ([]string, error) {
+	found := make(map[string]bool)
+	lock := sync.Mutex{}
+
+	scanNode := func(ctx context.Context, node scanner) error {
+		var cursor uint64
+		for {
+			keys, next, err := node.Scan(ctx, cursor, pattern, scanBatchSize).Result()
+			if err != nil {
+				return err
+			}
+
+			lock.Lock()
+			// SCAN may return a key more than once
+			for _, k := range keys {
+				found[k] = true
+			}
+			lock.Unlock()
+
+			if next == 0 {
+				return nil
+			}
+			cursor = next
+		}
+	}
+
+	var err error
+	if cluster, ok := s.client.(*redis.ClusterClient); ok {
+		err = cluster.ForEachMaster(ctx, func(ctx context.Context, client *redis.Client) error {
+			return scanNode(ctx, client)
+		})
+	} else {
+		err = scanNode(ctx, s.client)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]string, 0, len(found))
+	for k := range found {
+		keys = append(keys, k)
+	}
+
+	return keys, nil
+}
+
+// getValues - returns the values of the given keys, with nil for keys that no longer exist
+func (s *RedisDocService) getValues(ctx context.Context, keys []string) ([]*redis.StringCmd, error) {
+	cmds := make([]*redis.StringCmd, len(keys))
+
+	_, err := s.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, k := range keys {
+			cmds[i] = pipe.Get(ctx, k)
+		}
+		return nil
+	})
+	// Missing keys are reported per command
+	if err != nil && err != redis.Nil {
+		return nil, err
+	}
+
+	return cmds, nil
+}
+
+func (s *RedisDocService) query(collection *document.Collection, expressions []document.QueryExpression, limit int, pagingToken map[string]string, newErr errors.ErrorFactory) (*document.QueryResult, error) {
+	if err := document.ValidateQueryCollection(collection); err != nil {
+		return nil, newErr(
+			codes.InvalidArgument,
+			"invalid collection",
+			err,
+		)
+	}
+
+	if err := document.ValidateExpressions(expressions); err != nil {
+		return nil, newErr(
+			codes.InvalidArgument,
+			"invalid query expressions",
+			err,
+		)
+	}
+
+	ctx := context.Background()
+
+	keys, err := s.scanKeys(ctx, s.collectionPattern(collection))
+	if err != nil {
+		return nil, newErr(
+			codes.Internal,
+			"error scanning collection",
+			err,
+		)
+	}
+
+	// SCAN order isn't stable, so keys are sorted to provide consistent results and paging
+	sort.Strings(keys)
+
+	if limit > 0 && len(pagingToken) > 0 {
+		startAfter, ok := pagingToken[startAfterTokenName]
+		if !ok {
+			return nil, newErr(
+				codes.InvalidArgument,
+				"invalid paging token",
+				fmt.Errorf("paging token is missing %s", startAfterTokenName),
+			)
+		}
+
+		start := sort.SearchStrings(keys, startAfter)
+		if start < len(keys) && keys[start] == startAfter {
+			start++
+		}
+		keys = keys[start:]
+	}
+
+	documents := make([]document.Document, 0)
+	lastKey := ""
+
+	for batchStart := 0; batchStart < len(keys); batchStart += scanBatchSize {
+		batchEnd := batchStart + scanBatchSize
+		if batchEnd > len(keys) {
+			batchEnd = len(keys)
+		}
+		batch := keys[batchStart:batchEnd]
+
+		values, err := s.getValues(ctx, batch)
+		if err != nil {
+			return nil, newErr(
+				codes.Internal,
+				"error getting documents",
+				err,
+			)
+		}
+
+		for i, value := range values {
+			bytes, err := value.Bytes()
+			if err == redis.Nil {
+				// The document expired or was deleted since the scan
+				continue
+			} else if err != nil {
+				return nil, newErr(
+					codes.Internal,
+					"error getting document",
+					err,
+				)
+			}
+
+			content := make(map[string]interface{})
+			if err := json.Unmarshal(bytes, &content); err != nil {
+				return nil, newErr(
+					codes.Internal,
+					"error unmarshalling document",
+					err,
+				)
+			}
+
+			if !matchesExpressions(content, expressions) {
+				continue
+			}
+
+			key, err := s.documentKey(collection, batch[i])
+			if err != nil {
+				return nil, newErr(
+					codes.Internal,
+					"error reading document key",
+					err,
+				)
+			}
+
+			documents = append(documents, document.Document{
+				Key:     key,
+				Content: content,
+			})
+			lastKey = batch[i]
+
+			if limit > 0 && len(documents) == limit {
+				break
+			}
+		}
+
+		if limit > 0 && len(documents) == limit {
+			break
+		}
+	}
+
+	// Provide paging token to continue after the last returned document
+	var resultPagingToken map[string]string
+	if limit > 0 && len(documents) == limit {
+		resultPagingToken = map[string]string{
+			startAfterTokenName: lastKey,
+		}
+	}
+
+	return &document.QueryResult{
+		Documents:   documents,
+		PagingToken: resultPagingToken,
+	}, nil
+}
+
+func (s *RedisDocService) Query(collection *document.Collection, expressions []document.QueryExpression, limit int, pagingToken map[string]string) (*document.QueryResult, error) {
+	newErr := errors.ErrorsWithScope(
+		"RedisDocService.Query",
+		map[string]interface{}{
+			"collection": collection,
+		},
+	)
+
+	return s.query(collection, expressions, limit, pagingToken, newErr)
+}
+
+// GetBatch - gets each document in turn, RedisDocService has no native batch reads
+func (s *RedisDocService) GetBatch(keys []*document.Key) ([]*document.BatchGetResult, error) {
+	newErr := errors.ErrorsWithScope(
+		"RedisDocService.GetBatch",
+		map[string]interface{}{
+			"keys": len(keys),
+		},
+	)
+
+	if err := document.ValidateBatchSize(len(keys)); err != nil {
+		return nil, newErr(
+			codes.InvalidArgument,
+			"invalid batch",
+			err,
+		)
+	}
+
+	return document.GetBatchSequentially(s, keys), nil
+}
+
+// SetBatch - sets each document in turn, RedisDocService has no native batch writes
+func (s *RedisDocService) SetBatch(items []*document.BatchSetItem) ([]*document.BatchResult, error) {
+	newErr := errors.ErrorsWithScope(
+		"RedisDocService.SetBatch",
+		map[string]interface{}{
+			"items": len(items),
+		},
+	)
+
+	if err := document.ValidateBatchSize(len(items)); err != nil {
+		return nil, newErr(
+			codes.InvalidArgument,
+			"invalid batch",
+			err,
+		)
+	}
+
+	return document.SetBatchSequentially(s, items), nil
+}
+
+// DeleteBatch - deletes each document in turn, RedisDocService has no native batch writes
+func (s *RedisDocService) DeleteBatch(keys []*document.Key) ([]*document.BatchResult, error) {
+	newErr := errors.ErrorsWithScope(
+		"RedisDocService.DeleteBatch",
+		map[string]interface{}{
+			"keys": len(keys),
+		},
+	)
+
+	if err := document.ValidateBatchSize(len(keys)); err != nil {
+		return nil, newErr(
+			codes.InvalidArgument,
+			"invalid batch",
+			err,
+		)
+	}
+
+	return document.DeleteBatchSequentially(s, keys), nil
+}
+
+func (s *RedisDocService) QueryStream(collection *document.Collection, expressions []document.QueryExpression, limit int) document.DocumentIterator {
+	newErr := errors.ErrorsWithScope(
+		"RedisDocService.QueryStream",
+		map[string]interface{}{
+			"collection": collection,
+		},
+	)
+
+	// Each page scans the collection's keys, so pages are as large as a scan batch to limit the number of scans
+	return document.NewPagedIterator(limit, scanBatchSize, func(pageLimit int, pagingToken map[string]string) (*document.QueryResult, error) {
+		return s.query(collection, expressions, pageLimit, pagingToken, newErr)
+	})
+}
+
+// matchesExpressions - returns true if the document content satisfies every query expression
+func matchesExpressions(content map[string]interface{}, expressions []document.QueryExpression) bool {
+	for _, exp := range expressions {
+		value, ok := content[exp.Operand]
+		if !ok || !matchesExpression(value, exp) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func matchesExpression(value interface{}, exp document.QueryExpression) bool {
+	if exp.Operator == "startsWith" {
+		str, ok := value.(string)
+		prefix, prefixOk := exp.Value.(string)
+		return ok && prefixOk && strings.HasPrefix(str, prefix)
+	}
+
+	cmp, ok := compareValues(value, exp.Value)
+	if !ok {
+		// Values of different types never match
+		return false
+	}
+
+	switch exp.Operator {
+	case "==":
+		return cmp == 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	}
+
+	return false
+}
+
+// compareValues - compares two document values, returning false if they aren't comparable
+func compareValues(a interface{}, b interface{}) (int, bool) {
+	if af, ok := toFloat(a); ok {
+		bf, ok := toFloat(b)
+		if !ok {
+			return 0, false
+		}
+		switch {
+		case af < bf:
+			return -1, true
+		case af > bf:
+			return 1, true
+		}
+		return 0, true
+	}
+
+	switch av := a.(type) {
+	case string:
+		if bv, ok := b.(string); ok {
+			return strings.Compare(av, bv), true
+		}
+	case bool:
+		if bv, ok := b.(bool); ok {
+			if av == bv {
+				return 0, true
+			} else if bv {
+				return -1, true
+			}
+			return 1, true
+		}
+	}
+
+	return 0, false
+}
+
+func toFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint32:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	}
+
+	return 0, false
+}
+
+// Capabilities - Returns the baseline capabilities along with the optional operations the plugin supports
+func (s *RedisDocService) Capabilities() []string {
+	return append(document.BaselineCapabilities(),
+		document.CapabilitySetWithTtl,
+	)
+}
+
+// New - Create a new Redis document plugin
+func New() (document.DocumentService, error) {
+	addresses := utils.GetEnvList("REDIS_ADDRESSES")
+	if len(addresses) == 0 {
+		addresses = []string{"localhost:6379"}
+	}
+
+	db, err := strconv.Atoi(utils.GetEnv("REDIS_DB", "0"))
+	if err != nil {
+		return nil, fmt.Errorf("REDIS_DB must be a database number: %v", err)
+	}
+
+	// Multiple addresses will create a cluster client
+	client := redis.NewUniversalClient(&redis.UniversalOptions{
+		Addrs:    addresses,
+		Password: utils.GetEnv("REDIS_PASSWORD", ""),
+		DB:       db,
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := client.Ping(ctx).Err(); err != nil {
+		return nil, fmt.Errorf("unable to connect to redis: %v", err)
+	}
+
+	return NewWithClient(client, WithKeyPrefix(utils.GetEnv("REDIS_KEY_PREFIX", defaultKeyPrefix)))
+}
+
+// NewWithClient - Create a new Redis document plugin using the given client
+func NewWithClient(client redis.UniversalClient, opts ...RedisDocServiceOption) (document.DocumentService, error) {
+	s := &RedisDocService{
+		client:    client,
+		keyPrefix: defaultKeyPrefix,
+	}
+
+	for _, o := range opts {
+		o.Apply(s)
+	}
+
+	return s, nil
+}
@@ -0,0 +1,69 @@
+// Copyright 2021 Nitric Pty Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package document
+
+import "io"
+
+// StreamPageSize - The default number of documents fetched at a time by query streams over paged queries,
+// so streaming a large collection only holds a page of documents in memory
+const StreamPageSize = 100
+
+// PageFetcher - Fetches a page of query results of at most limit documents, continuing from the paging token
+type PageFetcher = func(limit int, pagingToken map[string]string) (*QueryResult, error)
+
+// NewPagedIterator - Returns an iterator over a paged query, fetching pages of pageSize documents only once the previous
+// page has been read. A limit of 0 iterates over every document, otherwise iteration stops after limit documents
+func NewPagedIterator(limit int, pageSize int, fetch PageFetcher) DocumentIterator {
+	remaining := limit
+	var documents []Document
+	var pagingToken map[string]string
+	fetched := false
+
+	return func() (*Document, error) {
+		if limit > 0 && remaining <= 0 {
+			return nil, io.EOF
+		}
+
+		// Pages may be empty when the query filters documents after reading them, so keep reading until a page has documents
+		for len(documents) == 0 {
+			if fetched && pagingToken == nil {
+				return nil, io.EOF
+			}
+
+			pageLimit := pageSize
+			if limit > 0 && remaining < pageLimit {
+				pageLimit = remaining
+			}
+
+			res, err := fetch(pageLimit, pagingToken)
+			if err != nil {
+				return nil, err
+			}
+
+			fetched = true
+			documents = res.Documents
+			pagingToken = res.PagingToken
+			if len(pagingToken) == 0 {
+				pagingToken = nil
+			}
+		}
+
+		doc := documents[0]
+		documents = documents[1:]
+		remaining--
+
+		return &doc, nil
+	}
+}
@@ -0,0 +1,78 @@
+// Copyright 2021 Nitric Pty Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package errors
+
+import (
+	"context"
+	"fmt"
+)
+
+// RequestIdArg - The error arg used to report the ID of the request that triggered a plugin call
+const RequestIdArg = "requestId"
+
+type requestIdKey struct{}
+
+// ContextWithRequestId - Returns a copy of the context carrying the given request ID
+func ContextWithRequestId(ctx context.Context, requestId string) context.Context {
+	return context.WithValue(ctx, requestIdKey{}, requestId)
+}
+
+// RequestIdFromContext - Returns the request ID carried by the context or an empty string if there isn't one
+func RequestIdFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+
+	if requestId, ok := ctx.Value(requestIdKey{}).(string); ok {
+		return requestId
+	}
+
+	return ""
+}
+
+// withRequestIdArg - Returns a copy of the args including the request ID, the args are returned as is without one
+func withRequestIdArg(args map[string]interface{}, requestId string) map[string]interface{} {
+	if requestId == "" {
+		return args
+	}
+
+	scoped := make(map[string]interface{}, len(args)+1)
+	for k, v := range args {
+		scoped[k] = v
+	}
+	scoped[RequestIdArg] = requestId
+
+	return scoped
+}
+
+// WithRequestId - Includes the request ID carried by the context in the args of a plugin error,
+// other errors are returned unchanged
+func WithRequestId(ctx context.Context, err error) error {
+	requestId := RequestIdFromContext(ctx)
+
+	pe, ok := err.(*PluginError)
+	if !ok || requestId == "" {
+		return err
+	}
+
+	if existing, ok := pe.Args[RequestIdArg]; ok && fmt.Sprintf("%v", existing) != "" {
+		return err
+	}
+
+	scoped := *pe
+	scoped.Args = withRequestIdArg(pe.Args, requestId)
+
+	return &scoped
+}
@@ -41,6 +41,12 @@ type LocalHttpeventsClient interface {
 	Do(req *http.Request) (*http.Response, error)
 }
 
+// PublishOrdered - Publishes the event with the ordering key, events are delivered to subscribers
+// before Publish returns so are always received in the order they were published
+func (s *LocalEventService) PublishOrdered(topic string, orderingKey string, event *events.NitricEvent) error {
+	return s.Publish(topic, events.WithOrderingKey(event, orderingKey))
+}
+
 // Publish a message to a given topic
 func (s *LocalEventService) Publish(topic string, event *events.NitricEvent) error {
 	newErr := errors.ErrorsWithScope(
@@ -53,9 +59,9 @@ func (s *LocalEventService) Publish(topic string, event *events.NitricEvent) err
 
 	requestId := event.ID
 	payloadType := event.PayloadType
-	payload := event.Payload
 
-	marshaledPayload, err := json.Marshal(payload)
+	// Subscribers are called directly, so binary payloads are sent as raw bytes
+	marshaledPayload, encoding, err := event.EncodePayload(true)
 	contentType := http.DetectContentType(marshaledPayload)
 
 	if err != nil {
@@ -76,6 +82,10 @@ func (s *LocalEventService) Publish(topic string, event *events.NitricEvent) err
 			httpRequest.Header.Add("x-nitric-source", topic)
 			httpRequest.Header.Add("x-nitric-source-type", triggers.TriggerType_Subscription.String())
 			httpRequest.Header.Add("x-nitric-payload-type", payloadType)
+			httpRequest.Header.Add(events.PayloadEncodingAttribute, encoding.String())
+			if event.OrderingKey != "" {
+				httpRequest.Header.Add("x-nitric-ordering-key", event.OrderingKey)
+			}
 
 			// Call the target
 			res, err := s.client.Do(httpRequest)
@@ -118,6 +128,23 @@ func (s *LocalEventService) ListTopics() ([]string, error) {
 	return keys, nil
 }
 
+// ListTopicsWithPrefix - Returns the topics whose names start with the prefix
+func (s *LocalEventService) ListTopicsWithPrefix(prefix string) ([]string, error) {
+	topics, err := s.ListTopics()
+	if err != nil {
+		return nil, err
+	}
+
+	return events.FilterTopicsByPrefix(topics, prefix), nil
+}
+
+// Capabilities - Returns the baseline capabilities along with the optional operations the plugin supports
+func (s *LocalEventService) Capabilities() []string {
+	return append(events.BaselineCapabilities(),
+		events.CapabilityPublishOrdered,
+	)
+}
+
 // Create new Dev EventService
 func New() (events.EventService, error) {
 	localSubscriptions := utils.GetEnv("LOCAL_SUBSCRIPTIONS", "{}")
@@ -0,0 +1,71 @@
+// Copyright 2021 Nitric Pty Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package events
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// PayloadEncoding - How an event's payload is carried
+type PayloadEncoding int
+
+const (
+	// PayloadEncoding_Json carries the event's Payload as a JSON object
+	PayloadEncoding_Json PayloadEncoding = iota
+	// PayloadEncoding_Base64 carries the event's Data as base64 text
+	PayloadEncoding_Base64
+	// PayloadEncoding_Raw carries the event's Data as raw bytes, or as base64 text by providers that only support text
+	PayloadEncoding_Raw
+)
+
+var payloadEncodings = [...]string{"JSON", "BASE64", "RAW"}
+
+// PayloadEncodingAttribute - The attribute/header used to tell subscribers how a payload is encoded
+const PayloadEncodingAttribute = "x-nitric-payload-encoding"
+
+func (e PayloadEncoding) String() string {
+	return payloadEncodings[e]
+}
+
+func PayloadEncodingFromString(encodingString string) (PayloadEncoding, error) {
+	for i, encoding := range payloadEncodings {
+		if encoding == strings.ToUpper(encodingString) {
+			return PayloadEncoding(i), nil
+		}
+	}
+	return -1, fmt.Errorf("Invalid payload encoding %s, supported encodings are: %s", encodingString, strings.Join(payloadEncodings[:], ", "))
+}
+
+// EncodePayload - Returns the event's payload encoded for a provider, along with the encoding used.
+// Providers that can't carry raw bytes receive RAW payloads as base64 text.
+func (e *NitricEvent) EncodePayload(rawSupported bool) ([]byte, PayloadEncoding, error) {
+	switch e.Encoding {
+	case PayloadEncoding_Json:
+		payload, err := json.Marshal(e.Payload)
+		return payload, PayloadEncoding_Json, err
+	case PayloadEncoding_Raw:
+		if rawSupported {
+			return e.Data, PayloadEncoding_Raw, nil
+		}
+		fallthrough
+	case PayloadEncoding_Base64:
+		return []byte(base64.StdEncoding.EncodeToString(e.Data)), PayloadEncoding_Base64, nil
+	default:
+		return nil, e.Encoding, fmt.Errorf("Invalid payload encoding %d", e.Encoding)
+	}
+}
@@ -0,0 +1,89 @@
+// Copyright 2021 Nitric Pty Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package events
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Payload Encoding", func() {
+	Context("PayloadEncodingFromString", func() {
+		It("Should parse encodings regardless of case", func() {
+			encoding, err := PayloadEncodingFromString("base64")
+
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(encoding).To(Equal(PayloadEncoding_Base64))
+		})
+
+		It("Should reject unknown encodings", func() {
+			_, err := PayloadEncodingFromString("xml")
+
+			Expect(err).Should(HaveOccurred())
+		})
+	})
+
+	Context("EncodePayload", func() {
+		binary := []byte{0x00, 0xff, 0x10}
+
+		When("The payload is JSON", func() {
+			It("Should marshal the payload", func() {
+				event := &NitricEvent{Payload: map[string]interface{}{"Test": "Test"}}
+
+				payload, encoding, err := event.EncodePayload(true)
+
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(encoding).To(Equal(PayloadEncoding_Json))
+				Expect(payload).To(MatchJSON(`{"Test":"Test"}`))
+			})
+		})
+
+		When("The payload is raw and the provider supports raw bytes", func() {
+			It("Should return the bytes unchanged", func() {
+				event := &NitricEvent{Encoding: PayloadEncoding_Raw, Data: binary}
+
+				payload, encoding, err := event.EncodePayload(true)
+
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(encoding).To(Equal(PayloadEncoding_Raw))
+				Expect(payload).To(Equal(binary))
+			})
+		})
+
+		When("The payload is raw and the provider only supports text", func() {
+			It("Should base64 encode the bytes", func() {
+				event := &NitricEvent{Encoding: PayloadEncoding_Raw, Data: binary}
+
+				payload, encoding, err := event.EncodePayload(false)
+
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(encoding).To(Equal(PayloadEncoding_Base64))
+				Expect(string(payload)).To(Equal("AP8Q"))
+			})
+		})
+
+		When("The payload is base64", func() {
+			It("Should base64 encode the bytes", func() {
+				event := &NitricEvent{Encoding: PayloadEncoding_Base64, Data: binary}
+
+				payload, encoding, err := event.EncodePayload(true)
+
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(encoding).To(Equal(PayloadEncoding_Base64))
+				Expect(string(payload)).To(Equal("AP8Q"))
+			})
+		})
+	})
+})
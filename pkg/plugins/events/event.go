@@ -18,4 +18,10 @@ type NitricEvent struct {
 	ID          string                 `json:"id,omitempty" log:"ID"`
 	PayloadType string                 `json:"payloadType,omitempty" log:"PayloadType"`
 	Payload     map[string]interface{} `json:"payload,omitempty"`
+	// Encoding - How the payload is carried, binary payloads are held in Data
+	Encoding PayloadEncoding `json:"encoding,omitempty" log:"Encoding"`
+	Data     []byte          `json:"data,omitempty"`
+	// OrderingKey - Events with the same key are delivered in the order they were published,
+	// by plugins that implement OrderedEventService. Other plugins ignore it
+	OrderingKey string `json:"orderingKey,omitempty" log:"OrderingKey"`
 }
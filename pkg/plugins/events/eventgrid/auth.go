@@ -0,0 +1,89 @@
+// Copyright 2021 Nitric Pty Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package eventgrid_service
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/services/eventgrid/2018-01-01/eventgrid"
+	"github.com/Azure/go-autorest/autorest"
+	"github.com/Azure/go-autorest/autorest/adal"
+)
+
+const (
+	// tokenRefreshWithin - how long before expiry service principal tokens are refreshed
+	tokenRefreshWithin = 5 * time.Minute
+	// maxIdleConnsPerHost - idle connections kept open to each topic endpoint for reuse across publishes
+	maxIdleConnsPerHost = 16
+)
+
+const (
+	// AuthMode_AAD - publishes with a service principal token for the EventGrid resource, the default
+	AuthMode_AAD = "aad"
+	// AuthMode_SAS - publishes with a topic access key, for topics that don't accept AAD tokens
+	AuthMode_SAS = "sas"
+)
+
+// TokenRefresher - forces a refresh of the token used to authorize EventGrid requests
+type TokenRefresher interface {
+	RefreshWithContext(ctx context.Context) error
+}
+
+// newHTTPClient - creates the HTTP client shared by the EventGrid clients and their token refreshes,
+// so connections are pooled and reused across publishes rather than opened per request
+func newHTTPClient() *http.Client {
+	return &http.Client{
+		Transport: &http.Transport{
+			Proxy: http.ProxyFromEnvironment,
+			DialContext: (&net.Dialer{
+				Timeout:   30 * time.Second,
+				KeepAlive: 30 * time.Second,
+			}).DialContext,
+			ForceAttemptHTTP2:     true,
+			MaxIdleConns:          100,
+			MaxIdleConnsPerHost:   maxIdleConnsPerHost,
+			IdleConnTimeout:       90 * time.Second,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ExpectContinueTimeout: 1 * time.Second,
+		},
+	}
+}
+
+// configureToken - ensures the token is refreshed before it expires, using the shared HTTP client
+func configureToken(spt *adal.ServicePrincipalToken, httpClient *http.Client) {
+	spt.SetAutoRefresh(true)
+	spt.SetRefreshWithin(tokenRefreshWithin)
+	spt.SetSender(httpClient)
+}
+
+// NewTopicKeyClient - creates an EventGrid client that authorizes publishes with a topic access key,
+// the key is sent as the aeg-sas-key header in place of a service principal token
+func NewTopicKeyClient(topicKey string, httpClient *http.Client) (eventgrid.BaseClient, error) {
+	if topicKey == "" {
+		return eventgrid.BaseClient{}, fmt.Errorf("an EventGrid topic access key is required")
+	}
+
+	client := eventgrid.New()
+	client.Authorizer = autorest.NewEventGridKeyAuthorizer(topicKey)
+	if httpClient != nil {
+		client.Sender = httpClient
+	}
+
+	return client, nil
+}
@@ -18,7 +18,10 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"net/http"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/Azure/azure-sdk-for-go/services/eventgrid/2018-01-01/eventgrid"
@@ -28,6 +31,7 @@ import (
 	"github.com/Azure/go-autorest/autorest"
 	"github.com/Azure/go-autorest/autorest/azure"
 	"github.com/Azure/go-autorest/autorest/date"
+	"github.com/Azure/go-autorest/autorest/to"
 	"github.com/nitrictech/nitric/pkg/plugins/errors"
 	"github.com/nitrictech/nitric/pkg/plugins/errors/codes"
 	"github.com/nitrictech/nitric/pkg/plugins/events"
@@ -35,24 +39,73 @@ import (
 	"github.com/nitrictech/nitric/pkg/utils"
 )
 
+const (
+	// maxBatchEvents - maximum number of events EventGrid will accept in a single publish request
+	maxBatchEvents = 1000
+	// maxBatchBytes - maximum payload size EventGrid will accept in a single publish request
+	maxBatchBytes = 1024 * 1024
+	// defaultTopicCacheTTL - default duration that resolved topic endpoints are cached for
+	defaultTopicCacheTTL = 5 * time.Minute
+	// topicProvisioningTimeout - maximum duration to wait for a created topic to finish provisioning
+	topicProvisioningTimeout = 2 * time.Minute
+	// defaultTopicPollInterval - default interval between checks of a created topic's provisioning state
+	defaultTopicPollInterval = 2 * time.Second
+)
+
+type topicCacheEntry struct {
+	endpoint string
+	expires  time.Time
+}
+
+// EventGridEventService - Publishes events to EventGrid topics.
+// EventGrid doesn't guarantee the order events are delivered in, so ordered publishing isn't supported
+// and the ordering keys of events are ignored
 type EventGridEventService struct {
 	events.UnimplementedeventsPlugin
 	client      eventgridapi.BaseClientAPI
 	topicClient eventgridmgmtapi.TopicsClientAPI
+
+	topicCacheLock sync.RWMutex
+	topicCache     map[string]topicCacheEntry
+	topicCacheTTL  time.Duration
+
+	// The schema events are published with
+	format events.Format
+
+	// Missing topics are only created when explicitly enabled, in the configured resource group and location
+	createTopics       bool
+	topicResourceGroup string
+	topicLocation      string
+	topicPollInterval  time.Duration
+	createTopicLock    sync.Mutex
+
+	// Refreshes the publishing token when a publish is rejected as unauthorized, e.g. after the token was revoked
+	tokenRefresher TokenRefresher
+
+	// The HTTP client shared by the clients and token refreshes, nil if the clients were injected without one
+	httpClient *http.Client
 }
 
-func (s *EventGridEventService) ListTopics() ([]string, error) {
-	newErr := errors.ErrorsWithScope(
-		"EventGrid.ListTopics",
-		map[string]interface{}{
-			"list": "topics",
-		},
-	)
+// Close - Releases the pooled connections of the shared HTTP client and the cached topic endpoints
+func (s *EventGridEventService) Close() error {
+	if s.httpClient != nil {
+		s.httpClient.CloseIdleConnections()
+	}
+
+	s.topicCacheLock.Lock()
+	s.topicCache = make(map[string]topicCacheEntry)
+	s.topicCacheLock.Unlock()
+
+	return nil
+}
+
+// listTopics - Pages through the subscription's topics matching the OData filter, keeping those that start with the prefix
+func (s *EventGridEventService) listTopics(newErr errors.ErrorFactory, filter string, prefix string) ([]string, error) {
 	//Set the topic page length
 	pageLength := int32(10)
 
 	ctx := context.Background()
-	results, err := s.topicClient.ListBySubscription(ctx, "", &pageLength)
+	results, err := s.topicClient.ListBySubscription(ctx, filter, &pageLength)
 
 	if err != nil {
 		return nil, newErr(
@@ -68,45 +121,243 @@ func (s *EventGridEventService) ListTopics() ([]string, error) {
 	for results.NotDone() {
 		topicsList := results.Values()
 		for _, topic := range topicsList {
-			topics = append(topics, *topic.Name)
+			if strings.HasPrefix(*topic.Name, prefix) {
+				topics = append(topics, *topic.Name)
+			}
+		}
+		if err := results.NextWithContext(ctx); err != nil {
+			return nil, newErr(
+				codes.Internal,
+				"error listing by subscription",
+				err,
+			)
 		}
-		results.NextWithContext(ctx)
 	}
 
 	return topics, nil
 }
 
+func (s *EventGridEventService) ListTopics() ([]string, error) {
+	newErr := errors.ErrorsWithScope(
+		"EventGrid.ListTopics",
+		map[string]interface{}{
+			"list": "topics",
+		},
+	)
+
+	return s.listTopics(newErr, "", "")
+}
+
+// ListTopicsWithPrefix - Lists the topics whose names start with the prefix. EventGrid filters on
+// names containing the prefix, so only those topics are paged back before the prefix is checked
+func (s *EventGridEventService) ListTopicsWithPrefix(prefix string) ([]string, error) {
+	newErr := errors.ErrorsWithScope(
+		"EventGrid.ListTopicsWithPrefix",
+		map[string]interface{}{
+			"prefix": prefix,
+		},
+	)
+
+	filter := ""
+	if prefix != "" {
+		// OData string literals escape quotes by doubling them
+		filter = fmt.Sprintf("contains(name, '%s')", strings.ReplaceAll(prefix, "'", "''"))
+	}
+
+	return s.listTopics(newErr, filter, prefix)
+}
+
+// getTopicEndpoint - resolves the host name of a topic, using the topic cache where possible
 func (s *EventGridEventService) getTopicEndpoint(topicName string) (string, error) {
+	s.topicCacheLock.RLock()
+	entry, ok := s.topicCache[topicName]
+	s.topicCacheLock.RUnlock()
+
+	if ok && time.Now().Before(entry.expires) {
+		return entry.endpoint, nil
+	}
+
+	endpoint, err := s.findTopicEndpoint(topicName)
+	if errors.Code(err) == codes.NotFound && s.createTopics {
+		endpoint, err = s.createTopic(topicName)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if s.topicCacheTTL > 0 {
+		s.topicCacheLock.Lock()
+		s.topicCache[topicName] = topicCacheEntry{
+			endpoint: endpoint,
+			expires:  time.Now().Add(s.topicCacheTTL),
+		}
+		s.topicCacheLock.Unlock()
+	}
+
+	return endpoint, nil
+}
+
+// invalidateTopicEndpoint - removes a topic from the topic cache, so it will be resolved again on next use
+func (s *EventGridEventService) invalidateTopicEndpoint(topicName string) {
+	s.topicCacheLock.Lock()
+	defer s.topicCacheLock.Unlock()
+
+	delete(s.topicCache, topicName)
+}
+
+// findTopicEndpoint - lists the topics available in the subscription to find the host name of the named topic
+func (s *EventGridEventService) findTopicEndpoint(topicName string) (string, error) {
+	newErr := errors.ErrorsWithScope(
+		"EventGrid.findTopicEndpoint",
+		map[string]interface{}{
+			"topic": topicName,
+		},
+	)
+
 	ctx := context.Background()
 	pageLength := int32(10)
 	results, err := s.topicClient.ListBySubscription(ctx, "", &pageLength)
 	if err != nil {
-		return "", fmt.Errorf(err.Error())
+		return "", newErr(
+			codes.Internal,
+			"error listing by subscription",
+			err,
+		)
 	}
 
 	for results.NotDone() {
 		topicsList := results.Values()
 		for _, topic := range topicsList {
 			if *topic.Name == topicName {
-				return strings.TrimSuffix(strings.TrimPrefix(*topic.Endpoint, "https://"), "/api/events"), nil
+				return topicHostName(*topic.Endpoint), nil
 			}
 		}
-		results.Next()
+		if err := results.NextWithContext(ctx); err != nil {
+			return "", newErr(
+				codes.Internal,
+				"error listing by subscription",
+				err,
+			)
+		}
+	}
+
+	return "", newErr(
+		codes.NotFound,
+		"topic with provided name could not be found",
+		nil,
+	)
+}
+
+// createTopic - creates a topic in the configured resource group and location, waiting for it to be provisioned
+func (s *EventGridEventService) createTopic(topicName string) (string, error) {
+	newErr := errors.ErrorsWithScope(
+		"EventGrid.createTopic",
+		map[string]interface{}{
+			"topic":         topicName,
+			"resourceGroup": s.topicResourceGroup,
+			"location":      s.topicLocation,
+		},
+	)
+
+	// Avoid concurrent publishes racing to create the same topic
+	s.createTopicLock.Lock()
+	defer s.createTopicLock.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), topicProvisioningTimeout)
+	defer cancel()
+
+	inputSchema := eventgridmgmt.InputSchemaEventGridSchema
+	if s.format == events.Format_CloudEvents {
+		inputSchema = eventgridmgmt.InputSchemaCloudEventSchemaV10
+	}
+
+	_, err := s.topicClient.CreateOrUpdate(ctx, s.topicResourceGroup, topicName, eventgridmgmt.Topic{
+		Location: to.StringPtr(s.topicLocation),
+		TopicProperties: &eventgridmgmt.TopicProperties{
+			InputSchema: inputSchema,
+		},
+	})
+	if err != nil {
+		if isPermissionDenied(err) {
+			return "", newErr(
+				codes.PermissionDenied,
+				"insufficient permissions to create topic, create it ahead of time or grant the EventGrid Contributor role",
+				err,
+			)
+		}
+		return "", newErr(
+			codes.Internal,
+			"error creating topic",
+			err,
+		)
+	}
+
+	// Creation is a long running operation, so the endpoint isn't available until provisioning completes
+	for {
+		topic, err := s.topicClient.Get(ctx, s.topicResourceGroup, topicName)
+		if err != nil {
+			if isPermissionDenied(err) {
+				return "", newErr(
+					codes.PermissionDenied,
+					"insufficient permissions to read created topic",
+					err,
+				)
+			}
+			return "", newErr(
+				codes.Internal,
+				"error retrieving created topic",
+				err,
+			)
+		}
+
+		if topic.TopicProperties != nil {
+			switch topic.ProvisioningState {
+			case eventgridmgmt.TopicProvisioningStateSucceeded:
+				if topic.Endpoint != nil {
+					return topicHostName(*topic.Endpoint), nil
+				}
+			case eventgridmgmt.TopicProvisioningStateFailed, eventgridmgmt.TopicProvisioningStateCanceled:
+				return "", newErr(
+					codes.Internal,
+					fmt.Sprintf("topic provisioning %s", strings.ToLower(string(topic.ProvisioningState))),
+					nil,
+				)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return "", newErr(
+				codes.DeadlineExceeded,
+				"timed out waiting for topic to be provisioned",
+				ctx.Err(),
+			)
+		case <-time.After(s.topicPollInterval):
+		}
 	}
-	return "", fmt.Errorf("topic with provided name could not be found")
 }
 
-func (s *EventGridEventService) nitricEventsToAzureEvents(topic string, events []*events.NitricEvent) ([]eventgrid.Event, error) {
+// topicHostName - returns the host name events are published to from a topic endpoint
+func topicHostName(endpoint string) string {
+	return strings.TrimSuffix(strings.TrimPrefix(endpoint, "https://"), "/api/events")
+}
+
+func (s *EventGridEventService) nitricEventsToAzureEvents(topic string, nitricEvents []*events.NitricEvent) ([]eventgrid.Event, error) {
 	var azureEvents []eventgrid.Event
-	for _, event := range events {
-		payload, err := json.Marshal(event.Payload)
+	for _, event := range nitricEvents {
+		// EventGrid data is JSON, so binary payloads are carried as base64 strings
+		payload, encoding, err := event.EncodePayload(false)
 		if err != nil {
 			return nil, err
 		}
+		var data interface{} = json.RawMessage(payload)
+		if encoding != events.PayloadEncoding_Json {
+			data = string(payload)
+		}
 		dataVersion := "1.0"
 		azureEvents = append(azureEvents, eventgrid.Event{
 			ID:          &event.ID,
-			Data:        &payload,
+			Data:        data,
 			EventType:   &event.PayloadType,
 			Subject:     &topic,
 			EventTime:   &date.Time{time.Now()},
@@ -117,6 +368,63 @@ func (s *EventGridEventService) nitricEventsToAzureEvents(topic string, events [
 	return azureEvents, nil
 }
 
+// nitricEventsToCloudEvents - converts nitric events to CloudEvents 1.0 envelopes, sourced from the given topic
+func (s *EventGridEventService) nitricEventsToCloudEvents(topic string, nitricEvents []*events.NitricEvent) ([]eventgrid.CloudEventEvent, error) {
+	var cloudEvents []eventgrid.CloudEventEvent
+	for _, event := range nitricEvents {
+		cloudEvent := eventgrid.CloudEventEvent{
+			ID:          to.StringPtr(event.ID),
+			Source:      to.StringPtr(topic),
+			Type:        to.StringPtr(event.PayloadType),
+			Specversion: to.StringPtr("1.0"),
+			Time:        &date.Time{time.Now()},
+		}
+
+		payload, encoding, err := event.EncodePayload(false)
+		if err != nil {
+			return nil, err
+		}
+
+		if encoding == events.PayloadEncoding_Json {
+			cloudEvent.Datacontenttype = to.StringPtr("application/json")
+			cloudEvent.Data = json.RawMessage(payload)
+		} else {
+			// CloudEvents carry binary payloads natively as data_base64
+			data := event.Data
+			cloudEvent.Datacontenttype = to.StringPtr("application/octet-stream")
+			cloudEvent.DataBase64 = &data
+		}
+
+		cloudEvents = append(cloudEvents, cloudEvent)
+	}
+
+	return cloudEvents, nil
+}
+
+// encodedSize - returns the size of an event once encoded in the configured format
+func (s *EventGridEventService) encodedSize(topic string, topicHostName string, event *events.NitricEvent) (int, error) {
+	var encoded interface{}
+	var err error
+
+	if s.format == events.Format_CloudEvents {
+		encoded, err = s.nitricEventsToCloudEvents(topic, []*events.NitricEvent{event})
+	} else {
+		encoded, err = s.nitricEventsToAzureEvents(topicHostName, []*events.NitricEvent{event})
+	}
+
+	if err != nil {
+		return 0, err
+	}
+
+	eventBytes, err := json.Marshal(encoded)
+	if err != nil {
+		return 0, err
+	}
+
+	// Remove the enclosing JSON array brackets
+	return len(eventBytes) - 2, nil
+}
+
 func (s *EventGridEventService) Publish(topic string, event *events.NitricEvent) error {
 	newErr := errors.ErrorsWithScope(
 		"EventGrid.Publish",
@@ -145,65 +453,358 @@ func (s *EventGridEventService) Publish(topic string, event *events.NitricEvent)
 	if err != nil {
 		return err
 	}
-	eventToPublish, err := s.nitricEventsToAzureEvents(topicHostName, []*events.NitricEvent{event})
-	if err != nil {
+
+	if status, err := s.publishEvents(ctx, topic, topicHostName, []*events.NitricEvent{event}); err != nil {
 		return newErr(
-			codes.Internal,
-			"error marshalling event",
+			codeForStatus(status),
+			"error publishing event",
 			err,
 		)
 	}
 
-	result, err := s.client.PublishEvents(ctx, topicHostName, eventToPublish)
+	return nil
+}
+
+// publishEvents - publishes a set of events to the given topic host in a single request, using the configured format.
+// The HTTP status of the request is returned, or 0 if no response was received.
+func (s *EventGridEventService) publishEvents(ctx context.Context, topic string, topicHostName string, evts []*events.NitricEvent) (int, error) {
+	result, err := s.sendEvents(ctx, topic, topicHostName, evts)
+
+	// The token may have expired or been revoked before its expiry time, so refresh it and try again once
+	if isUnauthorized(result, err) && s.tokenRefresher != nil {
+		if refreshErr := s.tokenRefresher.RefreshWithContext(ctx); refreshErr != nil {
+			return statusCode(result, err), fmt.Errorf("error refreshing token: %v", refreshErr)
+		}
+
+		result, err = s.sendEvents(ctx, topic, topicHostName, evts)
+	}
+
+	// The topic may have been deleted or recreated, so it will need to be resolved again
+	if isNotFound(result, err) {
+		s.invalidateTopicEndpoint(topic)
+	}
+
+	status := statusCode(result, err)
+	if err != nil {
+		return status, err
+	}
+
+	if status < 200 || status >= 300 {
+		return status, fmt.Errorf("returned non 200 status code: %s", result.Status)
+	}
+
+	return status, nil
+}
+
+// sendEvents - sends a single publish request for the events
+func (s *EventGridEventService) sendEvents(ctx context.Context, topic string, topicHostName string, evts []*events.NitricEvent) (autorest.Response, error) {
+	var result autorest.Response
+	var err error
+
+	if s.format == events.Format_CloudEvents {
+		cloudEvents, convErr := s.nitricEventsToCloudEvents(topic, evts)
+		if convErr != nil {
+			return result, fmt.Errorf("error marshalling events: %v", convErr)
+		}
+
+		result, err = s.client.PublishCloudEventEvents(ctx, topicHostName, cloudEvents)
+	} else {
+		azureEvents, convErr := s.nitricEventsToAzureEvents(topicHostName, evts)
+		if convErr != nil {
+			return result, fmt.Errorf("error marshalling events: %v", convErr)
+		}
+
+		result, err = s.client.PublishEvents(ctx, topicHostName, azureEvents)
+	}
+
+	return result, err
+}
+
+// statusCode - returns the HTTP status of a publish request, or 0 if no response was received
+func statusCode(result autorest.Response, err error) int {
+	if dErr, ok := err.(autorest.DetailedError); ok {
+		if code, ok := dErr.StatusCode.(int); ok {
+			return code
+		}
+	}
+
+	if result.Response != nil {
+		return result.StatusCode
+	}
+
+	return 0
+}
+
+// codeForStatus - maps the HTTP status of a failed publish request to an error code,
+// so throttling can be distinguished from malformed events
+func codeForStatus(status int) codes.Code {
+	switch {
+	case status == http.StatusTooManyRequests:
+		return codes.ResourceExhausted
+	case status == http.StatusBadRequest || status == http.StatusRequestEntityTooLarge:
+		return codes.InvalidArgument
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		return codes.PermissionDenied
+	case status == http.StatusNotFound:
+		return codes.NotFound
+	case status >= 500:
+		return codes.Unavailable
+	default:
+		return codes.Internal
+	}
+}
+
+func isNotFound(result autorest.Response, err error) bool {
+	if dErr, ok := err.(autorest.DetailedError); ok {
+		return dErr.StatusCode == http.StatusNotFound
+	}
+
+	return result.Response != nil && result.StatusCode == http.StatusNotFound
+}
+
+func isUnauthorized(result autorest.Response, err error) bool {
+	if dErr, ok := err.(autorest.DetailedError); ok {
+		return dErr.StatusCode == http.StatusUnauthorized
+	}
+
+	return result.Response != nil && result.StatusCode == http.StatusUnauthorized
+}
+
+func isPermissionDenied(err error) bool {
+	if dErr, ok := err.(autorest.DetailedError); ok {
+		return dErr.StatusCode == http.StatusForbidden || dErr.StatusCode == http.StatusUnauthorized
+	}
+
+	return false
+}
+
+// chunkEvents - splits events into chunks that fit within the EventGrid request limits once encoded
+func (s *EventGridEventService) chunkEvents(topic string, topicHostName string, evts []*events.NitricEvent) ([][]*events.NitricEvent, error) {
+	chunks := make([][]*events.NitricEvent, 0)
+	chunk := make([]*events.NitricEvent, 0)
+	// Account for the enclosing JSON array brackets
+	chunkBytes := 2
+
+	for _, evt := range evts {
+		evtSize, err := s.encodedSize(topic, topicHostName, evt)
+		if err != nil {
+			return nil, err
+		}
+
+		// Account for the separating comma
+		size := evtSize + 1
+		if size+2 > maxBatchBytes {
+			return nil, fmt.Errorf("event %s exceeds the maximum request size of %d bytes", evt.ID, maxBatchBytes)
+		}
+
+		if len(chunk) == maxBatchEvents || chunkBytes+size > maxBatchBytes {
+			chunks = append(chunks, chunk)
+			chunk = make([]*events.NitricEvent, 0)
+			chunkBytes = 2
+		}
+
+		chunk = append(chunk, evt)
+		chunkBytes += size
+	}
+
+	if len(chunk) > 0 {
+		chunks = append(chunks, chunk)
+	}
+
+	return chunks, nil
+}
+
+// PublishBatch - publishes a set of events to a topic, splitting them into multiple requests when required
+func (s *EventGridEventService) PublishBatch(topic string, evts []*events.NitricEvent) error {
+	newErr := errors.ErrorsWithScope(
+		"EventGrid.PublishBatch",
+		map[string]interface{}{
+			"topic":  topic,
+			"events": len(evts),
+		},
+	)
+	ctx := context.Background()
+
+	if len(topic) == 0 {
+		return newErr(
+			codes.InvalidArgument,
+			"provided invalid topic",
+			fmt.Errorf("non-blank topic is required"),
+		)
+	}
+	if len(evts) == 0 {
+		return newErr(
+			codes.InvalidArgument,
+			"provided invalid events",
+			fmt.Errorf("at least one event is required"),
+		)
+	}
+	for i, evt := range evts {
+		if evt == nil {
+			return newErr(
+				codes.InvalidArgument,
+				"provided invalid events",
+				fmt.Errorf("event at index %d is nil", i),
+			)
+		}
+	}
+
+	topicHostName, err := s.getTopicEndpoint(topic)
+	if err != nil {
+		return err
+	}
+
+	chunks, err := s.chunkEvents(topic, topicHostName, evts)
 	if err != nil {
 		return newErr(
-			codes.Internal,
-			"error publishing event",
+			codes.InvalidArgument,
+			"provided invalid events",
 			err,
 		)
 	}
 
-	if result.StatusCode < 200 || result.StatusCode >= 300 {
+	// Each chunk is accepted or rejected as a whole, so keep publishing the remaining chunks
+	// and report exactly which events failed, allowing callers to retry only those
+	partialErr := &events.PartialPublishError{
+		Failed: make([]*events.FailedEvent, 0),
+	}
+	offset := 0
+	failedChunks := 0
+	firstStatus := 0
+	for _, chunk := range chunks {
+		status, err := s.publishEvents(ctx, topic, topicHostName, chunk)
+		if err != nil {
+			if failedChunks == 0 {
+				firstStatus = status
+			}
+			failedChunks++
+
+			for i, evt := range chunk {
+				partialErr.Failed = append(partialErr.Failed, &events.FailedEvent{
+					Index:      offset + i,
+					ID:         evt.ID,
+					StatusCode: status,
+					Err:        err,
+				})
+			}
+		} else {
+			partialErr.Published += len(chunk)
+		}
+		offset += len(chunk)
+	}
+
+	if failedChunks > 0 {
 		return newErr(
-			codes.Internal,
-			"returned non 200 status code",
-			fmt.Errorf(result.Status),
+			codeForStatus(firstStatus),
+			fmt.Sprintf("error publishing %d of %d chunks, %d events were published", failedChunks, len(chunks), partialErr.Published),
+			partialErr,
 		)
 	}
+
 	return nil
 }
 
+// Capabilities - Returns the baseline capabilities along with the optional operations the plugin supports
+func (s *EventGridEventService) Capabilities() []string {
+	return append(events.BaselineCapabilities(),
+		events.CapabilityPublishBatch,
+	)
+}
+
+// New - Creates an EventGrid events plugin, publishing with AAD or, when NITRIC_EVENTGRID_AUTH is sas, a topic access key
 func New() (events.EventService, error) {
 	subscriptionID := utils.GetEnv("AZURE_SUBSCRIPTION_ID", "")
 	if len(subscriptionID) == 0 {
 		return nil, fmt.Errorf("AZURE_SUBSCRIPTION_ID not configured")
 	}
 
-	//Get the event grid token, using the event grid resource endpoint
-	spt, err := azureutils.GetServicePrincipalToken("https://eventgrid.azure.net")
-	if err != nil {
-		return nil, fmt.Errorf("error authenticating event grid client: %v", err.Error())
-	}
-	//Get the event grid management token using the resource management endpoint
+	//Get the event grid management token using the resource management endpoint, topics are always resolved with AAD
 	mgmtspt, err := azureutils.GetServicePrincipalToken(azure.PublicCloud.ResourceManagerEndpoint)
 	if err != nil {
 		return nil, fmt.Errorf("error authenticating event grid management client: %v", err.Error())
 	}
-	client := eventgrid.New()
-	client.Authorizer = autorest.NewBearerAuthorizer(spt)
+	// A single transport is shared by both clients and their token refreshes for connection reuse
+	httpClient := newHTTPClient()
+	configureToken(mgmtspt, httpClient)
+
+	var opts []EventGridEventServiceOption
+
+	var client eventgrid.BaseClient
+	switch authMode := strings.ToLower(utils.GetEnv("NITRIC_EVENTGRID_AUTH", AuthMode_AAD)); authMode {
+	case AuthMode_AAD:
+		//Get the event grid token, using the event grid resource endpoint
+		spt, err := azureutils.GetServicePrincipalToken("https://eventgrid.azure.net")
+		if err != nil {
+			return nil, fmt.Errorf("error authenticating event grid client: %v", err.Error())
+		}
+		configureToken(spt, httpClient)
+
+		client = eventgrid.New()
+		client.Authorizer = autorest.NewBearerAuthorizer(spt)
+		client.Sender = httpClient
+		opts = append(opts, WithTokenRefresher(spt))
+	case AuthMode_SAS:
+		client, err = NewTopicKeyClient(utils.GetEnv("EVENTGRID_TOPIC_KEY", ""), httpClient)
+		if err != nil {
+			return nil, fmt.Errorf("EVENTGRID_TOPIC_KEY must be configured when NITRIC_EVENTGRID_AUTH is sas")
+		}
+	default:
+		return nil, fmt.Errorf("NITRIC_EVENTGRID_AUTH must be %s or %s, got %s", AuthMode_AAD, AuthMode_SAS, authMode)
+	}
 
 	topicClient := eventgridmgmt.NewTopicsClient(subscriptionID)
 	topicClient.Authorizer = autorest.NewBearerAuthorizer(mgmtspt)
+	topicClient.Sender = httpClient
+
+	cacheTTL, err := strconv.Atoi(utils.GetEnv("EVENTGRID_TOPIC_CACHE_TTL", "300"))
+	if err != nil {
+		return nil, fmt.Errorf("EVENTGRID_TOPIC_CACHE_TTL must be a number of seconds: %v", err)
+	}
+
+	format, err := events.FormatFromString(utils.GetEnv("EVENTGRID_EVENT_FORMAT", "EVENTGRID"))
+	if err != nil {
+		return nil, err
+	}
+
+	opts = append(opts,
+		WithTopicCacheTTL(time.Duration(cacheTTL)*time.Second),
+		WithFormat(format),
+		WithHTTPClient(httpClient),
+	)
+
+	// Topic creation is opt-in to avoid accidentally provisioning resources in production
+	createTopics, err := strconv.ParseBool(utils.GetEnv("EVENTGRID_CREATE_TOPICS", "false"))
+	if err != nil {
+		return nil, fmt.Errorf("EVENTGRID_CREATE_TOPICS must be a boolean: %v", err)
+	}
+	if createTopics {
+		resourceGroup := utils.GetEnv("EVENTGRID_TOPIC_RESOURCE_GROUP", utils.GetEnv("AZURE_RESOURCE_GROUP", ""))
+		if len(resourceGroup) == 0 {
+			return nil, fmt.Errorf("EVENTGRID_TOPIC_RESOURCE_GROUP or AZURE_RESOURCE_GROUP must be configured when EVENTGRID_CREATE_TOPICS is enabled")
+		}
+		location := utils.GetEnv("EVENTGRID_TOPIC_LOCATION", "")
+		if len(location) == 0 {
+			return nil, fmt.Errorf("EVENTGRID_TOPIC_LOCATION must be configured when EVENTGRID_CREATE_TOPICS is enabled")
+		}
+		opts = append(opts, WithCreateTopicIfMissing(resourceGroup, location))
+	}
 
-	return &EventGridEventService{
-		client:      client,
-		topicClient: topicClient,
-	}, nil
+	return NewWithClient(client, topicClient, opts...)
 }
 
-func NewWithClient(client eventgridapi.BaseClientAPI, topicClient eventgridmgmtapi.TopicsClientAPI) (events.EventService, error) {
-	return &EventGridEventService{
-		client:      client,
-		topicClient: topicClient,
-	}, nil
+// NewWithClient creates a new EventGrid events plugin and injects the given clients
+func NewWithClient(client eventgridapi.BaseClientAPI, topicClient eventgridmgmtapi.TopicsClientAPI, opts ...EventGridEventServiceOption) (events.EventService, error) {
+	eventGridClient := &EventGridEventService{
+		client:            client,
+		topicClient:       topicClient,
+		topicCache:        make(map[string]topicCacheEntry),
+		topicCacheTTL:     defaultTopicCacheTTL,
+		topicPollInterval: defaultTopicPollInterval,
+	}
+
+	for _, o := range opts {
+		o.Apply(eventGridClient)
+	}
+
+	return eventGridClient, nil
 }
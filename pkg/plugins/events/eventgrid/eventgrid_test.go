@@ -16,18 +16,33 @@ package eventgrid_service_test
 
 import (
 	"context"
+	"fmt"
 	"net/http"
 
+	"github.com/Azure/azure-sdk-for-go/services/eventgrid/2018-01-01/eventgrid"
 	eventgridmgmt "github.com/Azure/azure-sdk-for-go/services/eventgrid/mgmt/2020-06-01/eventgrid"
 	"github.com/Azure/go-autorest/autorest"
 	"github.com/golang/mock/gomock"
 	mock_eventgrid "github.com/nitrictech/nitric/mocks/mock_event_grid"
+	"github.com/nitrictech/nitric/pkg/plugins/errors"
+	"github.com/nitrictech/nitric/pkg/plugins/errors/codes"
 	"github.com/nitrictech/nitric/pkg/plugins/events"
 	eventgrid_service "github.com/nitrictech/nitric/pkg/plugins/events/eventgrid"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 )
 
+// mockTokenRefresher - counts forced token refreshes, failing them when err is set
+type mockTokenRefresher struct {
+	refreshes int
+	err       error
+}
+
+func (m *mockTokenRefresher) RefreshWithContext(ctx context.Context) error {
+	m.refreshes++
+	return m.err
+}
+
 var _ = Describe("Event Grid Plugin", func() {
 	topicName := "Test"
 	topicEndpoint := "https://Test.local1-test.eventgrid.azure.net/api/events"
@@ -84,6 +99,44 @@ var _ = Describe("Event Grid Plugin", func() {
 				Expect(topics).To(ContainElement("Test"))
 			})
 		})
+
+		When("Listing topics with a prefix", func() {
+			ctrl := gomock.NewController(GinkgoT())
+			eventgridClient := mock_eventgrid.NewMockBaseClientAPI(ctrl)
+			topicClient := mock_eventgrid.NewMockTopicsClientAPI(ctrl)
+			eventgridPlugin, _ := eventgrid_service.NewWithClient(eventgridClient, topicClient)
+
+			topicClient.EXPECT().ListBySubscription(
+				gomock.Any(),
+				"contains(name, 'Te')",
+				gomock.Any(),
+			).Return(topicListResponsePage, nil).Times(1)
+
+			It("Should filter the topics by name", func() {
+				topics, err := eventgridPlugin.ListTopicsWithPrefix("Te")
+				Expect(err).To(BeNil())
+				Expect(topics).To(Equal([]string{"Test"}))
+			})
+		})
+
+		When("Listing topics with a prefix that only appears within names", func() {
+			ctrl := gomock.NewController(GinkgoT())
+			eventgridClient := mock_eventgrid.NewMockBaseClientAPI(ctrl)
+			topicClient := mock_eventgrid.NewMockTopicsClientAPI(ctrl)
+			eventgridPlugin, _ := eventgrid_service.NewWithClient(eventgridClient, topicClient)
+
+			topicClient.EXPECT().ListBySubscription(
+				gomock.Any(),
+				"contains(name, 'est')",
+				gomock.Any(),
+			).Return(topicListResponsePage, nil).Times(1)
+
+			It("Should not return topics that don't start with the prefix", func() {
+				topics, err := eventgridPlugin.ListTopicsWithPrefix("est")
+				Expect(err).To(BeNil())
+				Expect(topics).To(BeEmpty())
+			})
+		})
 	})
 
 	When("Publishing Messages", func() {
@@ -107,9 +160,44 @@ var _ = Describe("Event Grid Plugin", func() {
 				gomock.Any(),
 			).Return(eventgridmgmt.TopicsListResultPage{}, nil).Times(1)
 
-			It("should return an error", func() {
+			It("should return a not found error", func() {
+				err := eventgridPlugin.Publish("Test", event)
+				Expect(err).Should(HaveOccurred())
+				Expect(errors.Code(err)).To(Equal(codes.NotFound))
+			})
+		})
+
+		When("A page of topics can't be listed", func() {
+			ctrl := gomock.NewController(GinkgoT())
+			eventgridClient := mock_eventgrid.NewMockBaseClientAPI(ctrl)
+			topicClient := mock_eventgrid.NewMockTopicsClientAPI(ctrl)
+			eventgridPlugin, _ := eventgrid_service.NewWithClient(eventgridClient, topicClient)
+
+			otherTopicName := "Other"
+			topicClient.EXPECT().ListBySubscription(
+				gomock.Any(),
+				"",
+				gomock.Any(),
+			).Return(eventgridmgmt.NewTopicsListResultPage(
+				eventgridmgmt.TopicsListResult{
+					Value: &[]eventgridmgmt.Topic{
+						{
+							Name: &otherTopicName,
+							TopicProperties: &eventgridmgmt.TopicProperties{
+								Endpoint: &topicEndpoint,
+							},
+						},
+					},
+				},
+				func(context.Context, eventgridmgmt.TopicsListResult) (eventgridmgmt.TopicsListResult, error) {
+					return eventgridmgmt.TopicsListResult{}, fmt.Errorf("page unavailable")
+				},
+			), nil).Times(1)
+
+			It("should return an internal error", func() {
 				err := eventgridPlugin.Publish("Test", event)
 				Expect(err).Should(HaveOccurred())
+				Expect(errors.Code(err)).To(Equal(codes.Internal))
 			})
 		})
 
@@ -140,6 +228,64 @@ var _ = Describe("Event Grid Plugin", func() {
 			})
 		})
 
+		When("The topic is throttling requests", func() {
+			ctrl := gomock.NewController(GinkgoT())
+			eventgridClient := mock_eventgrid.NewMockBaseClientAPI(ctrl)
+			topicClient := mock_eventgrid.NewMockTopicsClientAPI(ctrl)
+			eventgridPlugin, _ := eventgrid_service.NewWithClient(eventgridClient, topicClient)
+
+			eventgridClient.EXPECT().PublishEvents(
+				gomock.Any(),
+				"Test.local1-test.eventgrid.azure.net",
+				gomock.Any(),
+			).Return(autorest.Response{
+				&http.Response{
+					StatusCode: 429,
+					Status:     "429 Too Many Requests",
+				},
+			}, nil).Times(1)
+			topicClient.EXPECT().ListBySubscription(
+				gomock.Any(),
+				"",
+				gomock.Any(),
+			).Return(topicListResponsePage, nil).Times(1)
+
+			It("should return a resource exhausted error", func() {
+				err := eventgridPlugin.Publish("Test", event)
+				Expect(err).Should(HaveOccurred())
+				Expect(errors.Code(err)).To(Equal(codes.ResourceExhausted))
+			})
+		})
+
+		When("The event is rejected as malformed", func() {
+			ctrl := gomock.NewController(GinkgoT())
+			eventgridClient := mock_eventgrid.NewMockBaseClientAPI(ctrl)
+			topicClient := mock_eventgrid.NewMockTopicsClientAPI(ctrl)
+			eventgridPlugin, _ := eventgrid_service.NewWithClient(eventgridClient, topicClient)
+
+			eventgridClient.EXPECT().PublishEvents(
+				gomock.Any(),
+				"Test.local1-test.eventgrid.azure.net",
+				gomock.Any(),
+			).Return(autorest.Response{
+				&http.Response{
+					StatusCode: 400,
+					Status:     "400 Bad Request",
+				},
+			}, nil).Times(1)
+			topicClient.EXPECT().ListBySubscription(
+				gomock.Any(),
+				"",
+				gomock.Any(),
+			).Return(topicListResponsePage, nil).Times(1)
+
+			It("should return an invalid argument error", func() {
+				err := eventgridPlugin.Publish("Test", event)
+				Expect(err).Should(HaveOccurred())
+				Expect(errors.Code(err)).To(Equal(codes.InvalidArgument))
+			})
+		})
+
 		When("To a topic that does exist", func() {
 			ctrl := gomock.NewController(GinkgoT())
 			eventgridClient := mock_eventgrid.NewMockBaseClientAPI(ctrl)
@@ -167,6 +313,272 @@ var _ = Describe("Event Grid Plugin", func() {
 			})
 		})
 
+		When("Publishing with an expired token", func() {
+			ctrl := gomock.NewController(GinkgoT())
+			eventgridClient := mock_eventgrid.NewMockBaseClientAPI(ctrl)
+			topicClient := mock_eventgrid.NewMockTopicsClientAPI(ctrl)
+			refresher := &mockTokenRefresher{}
+			eventgridPlugin, _ := eventgrid_service.NewWithClient(eventgridClient, topicClient, eventgrid_service.WithTokenRefresher(refresher))
+
+			gomock.InOrder(
+				eventgridClient.EXPECT().PublishEvents(
+					gomock.Any(),
+					"Test.local1-test.eventgrid.azure.net",
+					gomock.Any(),
+				).Return(autorest.Response{
+					&http.Response{
+						StatusCode: 401,
+					},
+				}, nil).Times(1),
+				eventgridClient.EXPECT().PublishEvents(
+					gomock.Any(),
+					"Test.local1-test.eventgrid.azure.net",
+					gomock.Any(),
+				).Return(autorest.Response{
+					&http.Response{
+						StatusCode: 202,
+					},
+				}, nil).Times(1),
+			)
+			topicClient.EXPECT().ListBySubscription(
+				gomock.Any(),
+				"",
+				gomock.Any(),
+			).Return(topicListResponsePage, nil).Times(1)
+
+			It("should refresh the token and publish the message", func() {
+				err := eventgridPlugin.Publish("Test", event)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(refresher.refreshes).To(Equal(1))
+			})
+		})
+
+		When("The token can't be refreshed", func() {
+			ctrl := gomock.NewController(GinkgoT())
+			eventgridClient := mock_eventgrid.NewMockBaseClientAPI(ctrl)
+			topicClient := mock_eventgrid.NewMockTopicsClientAPI(ctrl)
+			refresher := &mockTokenRefresher{err: fmt.Errorf("mock error")}
+			eventgridPlugin, _ := eventgrid_service.NewWithClient(eventgridClient, topicClient, eventgrid_service.WithTokenRefresher(refresher))
+
+			eventgridClient.EXPECT().PublishEvents(
+				gomock.Any(),
+				"Test.local1-test.eventgrid.azure.net",
+				gomock.Any(),
+			).Return(autorest.Response{
+				&http.Response{
+					StatusCode: 401,
+				},
+			}, nil).Times(1)
+			topicClient.EXPECT().ListBySubscription(
+				gomock.Any(),
+				"",
+				gomock.Any(),
+			).Return(topicListResponsePage, nil).Times(1)
+
+			It("should return an error without retrying", func() {
+				err := eventgridPlugin.Publish("Test", event)
+				Expect(err).Should(HaveOccurred())
+				Expect(refresher.refreshes).To(Equal(1))
+			})
+		})
+
+		When("Publishing with the CloudEvents format", func() {
+			ctrl := gomock.NewController(GinkgoT())
+			eventgridClient := mock_eventgrid.NewMockBaseClientAPI(ctrl)
+			topicClient := mock_eventgrid.NewMockTopicsClientAPI(ctrl)
+			eventgridPlugin, _ := eventgrid_service.NewWithClient(
+				eventgridClient,
+				topicClient,
+				eventgrid_service.WithFormat(events.Format_CloudEvents),
+			)
+
+			It("should publish a CloudEvents envelope sourced from the topic", func() {
+				var published []eventgrid.CloudEventEvent
+				eventgridClient.EXPECT().PublishCloudEventEvents(
+					gomock.Any(),
+					"Test.local1-test.eventgrid.azure.net",
+					gomock.Any(),
+				).DoAndReturn(func(ctx context.Context, topicHostname string, evts []eventgrid.CloudEventEvent) (autorest.Response, error) {
+					published = evts
+					return autorest.Response{
+						&http.Response{
+							StatusCode: 202,
+						},
+					}, nil
+				}).Times(1)
+				topicClient.EXPECT().ListBySubscription(
+					gomock.Any(),
+					"",
+					gomock.Any(),
+				).Return(topicListResponsePage, nil).Times(1)
+
+				err := eventgridPlugin.Publish("Test", event)
+				Expect(err).ShouldNot(HaveOccurred())
+
+				Expect(published).To(HaveLen(1))
+				Expect(*published[0].ID).To(Equal(event.ID))
+				Expect(*published[0].Type).To(Equal(event.PayloadType))
+				Expect(*published[0].Source).To(Equal("Test"))
+				Expect(*published[0].Specversion).To(Equal("1.0"))
+			})
+		})
+
+		When("Publishing to the same topic more than once", func() {
+			ctrl := gomock.NewController(GinkgoT())
+			eventgridClient := mock_eventgrid.NewMockBaseClientAPI(ctrl)
+			topicClient := mock_eventgrid.NewMockTopicsClientAPI(ctrl)
+			eventgridPlugin, _ := eventgrid_service.NewWithClient(eventgridClient, topicClient)
+
+			It("should only resolve the topic endpoint once", func() {
+				eventgridClient.EXPECT().PublishEvents(
+					gomock.Any(),
+					"Test.local1-test.eventgrid.azure.net",
+					gomock.Any(),
+				).Return(autorest.Response{
+					&http.Response{
+						StatusCode: 202,
+					},
+				}, nil).Times(2)
+				topicClient.EXPECT().ListBySubscription(
+					gomock.Any(),
+					"",
+					gomock.Any(),
+				).Return(topicListResponsePage, nil).Times(1)
+
+				Expect(eventgridPlugin.Publish("Test", event)).ShouldNot(HaveOccurred())
+				Expect(eventgridPlugin.Publish("Test", event)).ShouldNot(HaveOccurred())
+			})
+		})
+
+		When("Publishing to a cached topic that no longer exists", func() {
+			ctrl := gomock.NewController(GinkgoT())
+			eventgridClient := mock_eventgrid.NewMockBaseClientAPI(ctrl)
+			topicClient := mock_eventgrid.NewMockTopicsClientAPI(ctrl)
+			eventgridPlugin, _ := eventgrid_service.NewWithClient(eventgridClient, topicClient)
+
+			It("should resolve the topic endpoint again", func() {
+				gomock.InOrder(
+					eventgridClient.EXPECT().PublishEvents(
+						gomock.Any(),
+						"Test.local1-test.eventgrid.azure.net",
+						gomock.Any(),
+					).Return(autorest.Response{
+						&http.Response{
+							StatusCode: 404,
+						},
+					}, nil),
+					eventgridClient.EXPECT().PublishEvents(
+						gomock.Any(),
+						"Test.local1-test.eventgrid.azure.net",
+						gomock.Any(),
+					).Return(autorest.Response{
+						&http.Response{
+							StatusCode: 202,
+						},
+					}, nil),
+				)
+				topicClient.EXPECT().ListBySubscription(
+					gomock.Any(),
+					"",
+					gomock.Any(),
+				).Return(topicListResponsePage, nil).Times(2)
+
+				Expect(eventgridPlugin.Publish("Test", event)).Should(HaveOccurred())
+				Expect(eventgridPlugin.Publish("Test", event)).ShouldNot(HaveOccurred())
+			})
+		})
+
+		When("Creating missing topics is enabled", func() {
+			When("the topic does not exist", func() {
+				ctrl := gomock.NewController(GinkgoT())
+				eventgridClient := mock_eventgrid.NewMockBaseClientAPI(ctrl)
+				topicClient := mock_eventgrid.NewMockTopicsClientAPI(ctrl)
+				eventgridPlugin, _ := eventgrid_service.NewWithClient(
+					eventgridClient,
+					topicClient,
+					eventgrid_service.WithCreateTopicIfMissing("test-rg", "australiaeast"),
+				)
+
+				It("should create the topic and publish to it", func() {
+					topicClient.EXPECT().ListBySubscription(
+						gomock.Any(),
+						"",
+						gomock.Any(),
+					).Return(eventgridmgmt.TopicsListResultPage{}, nil).Times(1)
+
+					var created eventgridmgmt.Topic
+					topicClient.EXPECT().CreateOrUpdate(
+						gomock.Any(),
+						"test-rg",
+						"Test",
+						gomock.Any(),
+					).DoAndReturn(func(ctx context.Context, resourceGroupName string, topicName string, topicInfo eventgridmgmt.Topic) (eventgridmgmt.TopicsCreateOrUpdateFuture, error) {
+						created = topicInfo
+						return eventgridmgmt.TopicsCreateOrUpdateFuture{}, nil
+					}).Times(1)
+					topicClient.EXPECT().Get(
+						gomock.Any(),
+						"test-rg",
+						"Test",
+					).Return(eventgridmgmt.Topic{
+						Name: &topicName,
+						TopicProperties: &eventgridmgmt.TopicProperties{
+							ProvisioningState: eventgridmgmt.TopicProvisioningStateSucceeded,
+							Endpoint:          &topicEndpoint,
+						},
+					}, nil).Times(1)
+					eventgridClient.EXPECT().PublishEvents(
+						gomock.Any(),
+						"Test.local1-test.eventgrid.azure.net",
+						gomock.Any(),
+					).Return(autorest.Response{
+						&http.Response{
+							StatusCode: 202,
+						},
+					}, nil).Times(2)
+
+					By("creating the topic on first publish")
+					Expect(eventgridPlugin.Publish("Test", event)).ShouldNot(HaveOccurred())
+					Expect(*created.Location).To(Equal("australiaeast"))
+
+					By("using the cached endpoint on subsequent publishes")
+					Expect(eventgridPlugin.Publish("Test", event)).ShouldNot(HaveOccurred())
+				})
+			})
+
+			When("the credentials are not permitted to create topics", func() {
+				ctrl := gomock.NewController(GinkgoT())
+				eventgridClient := mock_eventgrid.NewMockBaseClientAPI(ctrl)
+				topicClient := mock_eventgrid.NewMockTopicsClientAPI(ctrl)
+				eventgridPlugin, _ := eventgrid_service.NewWithClient(
+					eventgridClient,
+					topicClient,
+					eventgrid_service.WithCreateTopicIfMissing("test-rg", "australiaeast"),
+				)
+
+				It("should return a permission denied error", func() {
+					topicClient.EXPECT().ListBySubscription(
+						gomock.Any(),
+						"",
+						gomock.Any(),
+					).Return(eventgridmgmt.TopicsListResultPage{}, nil).Times(1)
+					topicClient.EXPECT().CreateOrUpdate(
+						gomock.Any(),
+						"test-rg",
+						"Test",
+						gomock.Any(),
+					).Return(eventgridmgmt.TopicsCreateOrUpdateFuture{}, autorest.DetailedError{
+						StatusCode: http.StatusForbidden,
+					}).Times(1)
+
+					err := eventgridPlugin.Publish("Test", event)
+					Expect(err).Should(HaveOccurred())
+					Expect(errors.Code(err)).To(Equal(codes.PermissionDenied))
+					Expect(err.Error()).Should(ContainSubstring("insufficient permissions to create topic"))
+				})
+			})
+		})
+
 		When("Providing an empty topic", func() {
 			ctrl := gomock.NewController(GinkgoT())
 			eventgridClient := mock_eventgrid.NewMockBaseClientAPI(ctrl)
@@ -193,4 +605,188 @@ var _ = Describe("Event Grid Plugin", func() {
 			})
 		})
 	})
+	When("Publishing a batch of messages", func() {
+		When("The batch exceeds the maximum events per request", func() {
+			ctrl := gomock.NewController(GinkgoT())
+			eventgridClient := mock_eventgrid.NewMockBaseClientAPI(ctrl)
+			topicClient := mock_eventgrid.NewMockTopicsClientAPI(ctrl)
+			eventgridPlugin, _ := eventgrid_service.NewWithClient(eventgridClient, topicClient)
+
+			batch := make([]*events.NitricEvent, 0)
+			for i := 0; i < 1001; i++ {
+				batch = append(batch, &events.NitricEvent{
+					ID:          fmt.Sprintf("Test%d", i),
+					PayloadType: "Test",
+					Payload: map[string]interface{}{
+						"Test": "Test",
+					},
+				})
+			}
+
+			topicClient.EXPECT().ListBySubscription(
+				gomock.Any(),
+				"",
+				gomock.Any(),
+			).Return(topicListResponsePage, nil).Times(1)
+
+			It("should publish the events in multiple chunks", func() {
+				By("Publishing the first 1000 events in a single request")
+				eventgridClient.EXPECT().PublishEvents(
+					gomock.Any(),
+					"Test.local1-test.eventgrid.azure.net",
+					gomock.Len(1000),
+				).Return(autorest.Response{
+					&http.Response{
+						StatusCode: 202,
+					},
+				}, nil).Times(1)
+
+				By("Publishing the remaining event in a second request")
+				eventgridClient.EXPECT().PublishEvents(
+					gomock.Any(),
+					"Test.local1-test.eventgrid.azure.net",
+					gomock.Len(1),
+				).Return(autorest.Response{
+					&http.Response{
+						StatusCode: 202,
+					},
+				}, nil).Times(1)
+
+				err := eventgridPlugin.PublishBatch("Test", batch)
+				Expect(err).ShouldNot(HaveOccurred())
+			})
+		})
+
+		When("A chunk fails to publish", func() {
+			ctrl := gomock.NewController(GinkgoT())
+			eventgridClient := mock_eventgrid.NewMockBaseClientAPI(ctrl)
+			topicClient := mock_eventgrid.NewMockTopicsClientAPI(ctrl)
+			eventgridPlugin, _ := eventgrid_service.NewWithClient(eventgridClient, topicClient)
+
+			eventgridClient.EXPECT().PublishEvents(
+				gomock.Any(),
+				"Test.local1-test.eventgrid.azure.net",
+				gomock.Any(),
+			).Return(autorest.Response{
+				&http.Response{
+					StatusCode: 500,
+				},
+			}, nil).Times(1)
+			topicClient.EXPECT().ListBySubscription(
+				gomock.Any(),
+				"",
+				gomock.Any(),
+			).Return(topicListResponsePage, nil).Times(1)
+
+			It("should return an error identifying the failed chunk", func() {
+				err := eventgridPlugin.PublishBatch("Test", []*events.NitricEvent{
+					{ID: "Test", PayloadType: "Test"},
+				})
+				Expect(err).Should(HaveOccurred())
+				Expect(err.Error()).Should(ContainSubstring("error publishing 1 of 1 chunks"))
+				Expect(errors.Code(err)).To(Equal(codes.Unavailable))
+
+				partialErr, ok := events.AsPartialPublishError(err)
+				Expect(ok).To(BeTrue())
+				Expect(partialErr.Published).To(Equal(0))
+				Expect(partialErr.FailedIndices()).To(Equal([]int{0}))
+				Expect(partialErr.Failed[0].StatusCode).To(Equal(500))
+			})
+		})
+
+		When("Only some chunks are accepted", func() {
+			ctrl := gomock.NewController(GinkgoT())
+			eventgridClient := mock_eventgrid.NewMockBaseClientAPI(ctrl)
+			topicClient := mock_eventgrid.NewMockTopicsClientAPI(ctrl)
+			eventgridPlugin, _ := eventgrid_service.NewWithClient(eventgridClient, topicClient)
+
+			batch := make([]*events.NitricEvent, 0)
+			for i := 0; i < 1002; i++ {
+				batch = append(batch, &events.NitricEvent{
+					ID:          fmt.Sprintf("Test%d", i),
+					PayloadType: "Test",
+				})
+			}
+
+			topicClient.EXPECT().ListBySubscription(
+				gomock.Any(),
+				"",
+				gomock.Any(),
+			).Return(topicListResponsePage, nil).Times(1)
+
+			It("should return a partial publish error listing only the rejected events", func() {
+				By("Accepting the first chunk")
+				eventgridClient.EXPECT().PublishEvents(
+					gomock.Any(),
+					"Test.local1-test.eventgrid.azure.net",
+					gomock.Len(1000),
+				).Return(autorest.Response{
+					&http.Response{
+						StatusCode: 202,
+					},
+				}, nil).Times(1)
+
+				By("Throttling the second chunk")
+				eventgridClient.EXPECT().PublishEvents(
+					gomock.Any(),
+					"Test.local1-test.eventgrid.azure.net",
+					gomock.Len(2),
+				).Return(autorest.Response{
+					&http.Response{
+						StatusCode: 429,
+						Status:     "429 Too Many Requests",
+					},
+				}, nil).Times(1)
+
+				err := eventgridPlugin.PublishBatch("Test", batch)
+				Expect(err).Should(HaveOccurred())
+				Expect(errors.Code(err)).To(Equal(codes.ResourceExhausted))
+
+				partialErr, ok := events.AsPartialPublishError(err)
+				Expect(ok).To(BeTrue())
+				Expect(partialErr.Published).To(Equal(1000))
+				Expect(partialErr.FailedIndices()).To(Equal([]int{1000, 1001}))
+				Expect(partialErr.Failed[0].ID).To(Equal("Test1000"))
+				Expect(partialErr.Failed[0].StatusCode).To(Equal(http.StatusTooManyRequests))
+
+				By("Returning the failed subset for retry")
+				failed := partialErr.FailedEvents(batch)
+				Expect(failed).To(HaveLen(2))
+				Expect(failed[1].ID).To(Equal("Test1001"))
+			})
+		})
+
+		When("Providing an empty batch", func() {
+			ctrl := gomock.NewController(GinkgoT())
+			eventgridClient := mock_eventgrid.NewMockBaseClientAPI(ctrl)
+			topicClient := mock_eventgrid.NewMockTopicsClientAPI(ctrl)
+			eventgridPlugin, _ := eventgrid_service.NewWithClient(eventgridClient, topicClient)
+
+			It("should return an error", func() {
+				err := eventgridPlugin.PublishBatch("Test", nil)
+				Expect(err).Should(HaveOccurred())
+				Expect(err.Error()).Should(ContainSubstring("provided invalid events"))
+			})
+		})
+	})
+
+	When("Authorizing with a topic access key", func() {
+		It("Should send the key as the aeg-sas-key header", func() {
+			client, err := eventgrid_service.NewTopicKeyClient("topic-key", nil)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			req, err := client.PublishEventsPreparer(context.TODO(), "test.local1-test.eventgrid.azure.net", []eventgrid.Event{})
+			Expect(err).ShouldNot(HaveOccurred())
+			req, err = autorest.Prepare(req, client.Authorizer.WithAuthorization())
+			Expect(err).ShouldNot(HaveOccurred())
+
+			Expect(req.Header.Get("aeg-sas-key")).To(Equal("topic-key"))
+			Expect(req.Header.Get("Authorization")).To(BeEmpty())
+		})
+
+		It("Should require a key", func() {
+			_, err := eventgrid_service.NewTopicKeyClient("", nil)
+			Expect(err).Should(HaveOccurred())
+		})
+	})
 })
@@ -0,0 +1,105 @@
+// Copyright 2021 Nitric Pty Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package eventgrid_service
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/nitrictech/nitric/pkg/plugins/events"
+)
+
+type EventGridEventServiceOption interface {
+	Apply(*EventGridEventService)
+}
+
+type withTopicCacheTTL struct {
+	ttl time.Duration
+}
+
+func (w *withTopicCacheTTL) Apply(service *EventGridEventService) {
+	service.topicCacheTTL = w.ttl
+}
+
+// WithTopicCacheTTL - sets how long resolved topic endpoints are cached for, a TTL of zero disables caching
+func WithTopicCacheTTL(ttl time.Duration) EventGridEventServiceOption {
+	return &withTopicCacheTTL{
+		ttl: ttl,
+	}
+}
+
+type withFormat struct {
+	format events.Format
+}
+
+func (w *withFormat) Apply(service *EventGridEventService) {
+	service.format = w.format
+}
+
+// WithFormat - sets the schema events are published with
+func WithFormat(format events.Format) EventGridEventServiceOption {
+	return &withFormat{
+		format: format,
+	}
+}
+
+type withCreateTopicIfMissing struct {
+	resourceGroup string
+	location      string
+}
+
+func (w *withCreateTopicIfMissing) Apply(service *EventGridEventService) {
+	service.createTopics = true
+	service.topicResourceGroup = w.resourceGroup
+	service.topicLocation = w.location
+}
+
+// WithCreateTopicIfMissing - creates topics in the given resource group and location when publishing to a topic that doesn't exist
+func WithCreateTopicIfMissing(resourceGroup string, location string) EventGridEventServiceOption {
+	return &withCreateTopicIfMissing{
+		resourceGroup: resourceGroup,
+		location:      location,
+	}
+}
+
+type withTokenRefresher struct {
+	refresher TokenRefresher
+}
+
+func (w *withTokenRefresher) Apply(service *EventGridEventService) {
+	service.tokenRefresher = w.refresher
+}
+
+// WithTokenRefresher - refreshes the publishing token and retries once when a publish is rejected as unauthorized
+func WithTokenRefresher(refresher TokenRefresher) EventGridEventServiceOption {
+	return &withTokenRefresher{
+		refresher: refresher,
+	}
+}
+
+type withHTTPClient struct {
+	client *http.Client
+}
+
+func (w *withHTTPClient) Apply(service *EventGridEventService) {
+	service.httpClient = w.client
+}
+
+// WithHTTPClient - sets the HTTP client shared by the EventGrid clients, its idle connections are closed when the service is closed
+func WithHTTPClient(client *http.Client) EventGridEventServiceOption {
+	return &withHTTPClient{
+		client: client,
+	}
+}
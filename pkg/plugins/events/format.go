@@ -0,0 +1,45 @@
+// Copyright 2021 Nitric Pty Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package events
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Format - the envelope schema events are published with
+type Format int
+
+const (
+	// Format_EventGridNative publishes events using the native EventGrid schema
+	Format_EventGridNative Format = iota
+	// Format_CloudEvents publishes events as CloudEvents 1.0 envelopes
+	Format_CloudEvents
+)
+
+var formats = [...]string{"EVENTGRID", "CLOUDEVENTS"}
+
+func (f Format) String() string {
+	return formats[f]
+}
+
+func FormatFromString(formatString string) (Format, error) {
+	for i, format := range formats {
+		if format == strings.ToUpper(formatString) {
+			return Format(i), nil
+		}
+	}
+	return -1, fmt.Errorf("Invalid format %s, supported formats are: %s", formatString, strings.Join(formats[:], ", "))
+}
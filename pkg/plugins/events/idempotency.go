@@ -0,0 +1,171 @@
+// Copyright 2021 Nitric Pty Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package events
+
+import (
+	"container/list"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// idempotencyEntry - A published event, keyed by its topic and ID
+type idempotencyEntry struct {
+	key       string
+	published time.Time
+}
+
+// idempotencyCache - An LRU cache of recently published events, entries expire after the window
+type idempotencyCache struct {
+	lock    sync.Mutex
+	window  time.Duration
+	size    int
+	entries map[string]*list.Element
+	// Most recently published first
+	order *list.List
+}
+
+func idempotencyKey(topic string, event *NitricEvent) string {
+	return fmt.Sprintf("%s/%s", topic, event.ID)
+}
+
+// contains - Returns true if the key was published within the window
+func (c *idempotencyCache) contains(key string) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	el, ok := c.entries[key]
+	if !ok {
+		return false
+	}
+
+	if time.Since(el.Value.(*idempotencyEntry).published) > c.window {
+		c.order.Remove(el)
+		delete(c.entries, key)
+		return false
+	}
+
+	return true
+}
+
+// add - Records the key as published, evicting the least recently published key if the cache is full
+func (c *idempotencyCache) add(key string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if el, ok := c.entries[key]; ok {
+		el.Value.(*idempotencyEntry).published = time.Now()
+		c.order.MoveToFront(el)
+		return
+	}
+
+	c.entries[key] = c.order.PushFront(&idempotencyEntry{
+		key:       key,
+		published: time.Now(),
+	})
+
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*idempotencyEntry).key)
+	}
+}
+
+// idempotentEventService - Skips publishing events that were already published to the same topic within the window
+type idempotentEventService struct {
+	EventService
+	cache *idempotencyCache
+}
+
+// Publish - Publishes the event, unless an event with the same ID was recently published to the topic
+func (s *idempotentEventService) Publish(topic string, event *NitricEvent) error {
+	key := idempotencyKey(topic, event)
+	if s.cache.contains(key) {
+		return nil
+	}
+
+	if err := s.EventService.Publish(topic, event); err != nil {
+		return err
+	}
+
+	// Only successful publishes are recorded, so failed events can be retried
+	s.cache.add(key)
+	return nil
+}
+
+// PublishOrdered - Publishes the ordered event, unless an event with the same ID was recently published to the topic
+func (s *idempotentEventService) PublishOrdered(topic string, orderingKey string, event *NitricEvent) error {
+	key := idempotencyKey(topic, event)
+	if s.cache.contains(key) {
+		return nil
+	}
+
+	if err := PublishOrdered(s.EventService, topic, orderingKey, event); err != nil {
+		return err
+	}
+
+	s.cache.add(key)
+	return nil
+}
+
+// PublishBatch - Publishes the events that weren't recently published to the topic
+func (s *idempotentEventService) PublishBatch(topic string, events []*NitricEvent) error {
+	unpublished := make([]*NitricEvent, 0, len(events))
+	for _, event := range events {
+		if !s.cache.contains(idempotencyKey(topic, event)) {
+			unpublished = append(unpublished, event)
+		}
+	}
+
+	if len(unpublished) == 0 {
+		return nil
+	}
+
+	if err := s.EventService.PublishBatch(topic, unpublished); err != nil {
+		// Record the events that were accepted, so retrying the batch only republishes the failures
+		if partialErr, ok := AsPartialPublishError(err); ok {
+			failed := make(map[int]bool, len(partialErr.Failed))
+			for _, index := range partialErr.FailedIndices() {
+				failed[index] = true
+			}
+			for i, event := range unpublished {
+				if !failed[i] {
+					s.cache.add(idempotencyKey(topic, event))
+				}
+			}
+		}
+		return err
+	}
+
+	for _, event := range unpublished {
+		s.cache.add(idempotencyKey(topic, event))
+	}
+	return nil
+}
+
+// NewIdempotentEventService - Wraps an event plugin to deduplicate events by topic and ID,
+// events re-published within the window are skipped and reported as successful.
+// The size limits the number of events remembered, the least recently published are forgotten first.
+func NewIdempotentEventService(plugin EventService, window time.Duration, size int) EventService {
+	return &idempotentEventService{
+		EventService: plugin,
+		cache: &idempotencyCache{
+			window:  window,
+			size:    size,
+			entries: make(map[string]*list.Element),
+			order:   list.New(),
+		},
+	}
+}
@@ -0,0 +1,146 @@
+// Copyright 2021 Nitric Pty Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package events
+
+import (
+	"fmt"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// recordingEventService - An event plugin that records published events
+type recordingEventService struct {
+	UnimplementedeventsPlugin
+	published []*NitricEvent
+	err       error
+}
+
+func (r *recordingEventService) Publish(topic string, event *NitricEvent) error {
+	if r.err != nil {
+		return r.err
+	}
+	r.published = append(r.published, event)
+	return nil
+}
+
+func (r *recordingEventService) PublishBatch(topic string, events []*NitricEvent) error {
+	if r.err != nil {
+		return r.err
+	}
+	r.published = append(r.published, events...)
+	return nil
+}
+
+var _ = Describe("Idempotent Event Service", func() {
+	var plugin *recordingEventService
+
+	BeforeEach(func() {
+		plugin = &recordingEventService{}
+	})
+
+	When("An event is re-published within the window", func() {
+		It("Should only publish the event once", func() {
+			eventService := NewIdempotentEventService(plugin, time.Minute, 10)
+
+			Expect(eventService.Publish("test", &NitricEvent{ID: "1"})).To(Succeed())
+			Expect(eventService.Publish("test", &NitricEvent{ID: "1"})).To(Succeed())
+
+			Expect(plugin.published).To(HaveLen(1))
+		})
+	})
+
+	When("An event with the same ID is published to a different topic", func() {
+		It("Should publish both events", func() {
+			eventService := NewIdempotentEventService(plugin, time.Minute, 10)
+
+			Expect(eventService.Publish("test", &NitricEvent{ID: "1"})).To(Succeed())
+			Expect(eventService.Publish("other", &NitricEvent{ID: "1"})).To(Succeed())
+
+			Expect(plugin.published).To(HaveLen(2))
+		})
+	})
+
+	When("An event is re-published after the window", func() {
+		It("Should publish the event again", func() {
+			eventService := NewIdempotentEventService(plugin, 10*time.Millisecond, 10)
+
+			Expect(eventService.Publish("test", &NitricEvent{ID: "1"})).To(Succeed())
+			time.Sleep(20 * time.Millisecond)
+			Expect(eventService.Publish("test", &NitricEvent{ID: "1"})).To(Succeed())
+
+			Expect(plugin.published).To(HaveLen(2))
+		})
+	})
+
+	When("Publishing fails", func() {
+		It("Should publish the event when it is retried", func() {
+			eventService := NewIdempotentEventService(plugin, time.Minute, 10)
+
+			plugin.err = fmt.Errorf("mock error")
+			Expect(eventService.Publish("test", &NitricEvent{ID: "1"})).ToNot(Succeed())
+
+			plugin.err = nil
+			Expect(eventService.Publish("test", &NitricEvent{ID: "1"})).To(Succeed())
+
+			Expect(plugin.published).To(HaveLen(1))
+		})
+	})
+
+	When("The cache is full", func() {
+		It("Should forget the least recently published event", func() {
+			eventService := NewIdempotentEventService(plugin, time.Minute, 2)
+
+			Expect(eventService.Publish("test", &NitricEvent{ID: "1"})).To(Succeed())
+			Expect(eventService.Publish("test", &NitricEvent{ID: "2"})).To(Succeed())
+			Expect(eventService.Publish("test", &NitricEvent{ID: "3"})).To(Succeed())
+			Expect(eventService.Publish("test", &NitricEvent{ID: "1"})).To(Succeed())
+
+			Expect(plugin.published).To(HaveLen(4))
+		})
+	})
+
+	When("A batch contains recently published events", func() {
+		It("Should only publish the new events", func() {
+			eventService := NewIdempotentEventService(plugin, time.Minute, 10)
+
+			Expect(eventService.Publish("test", &NitricEvent{ID: "1"})).To(Succeed())
+			Expect(eventService.PublishBatch("test", []*NitricEvent{{ID: "1"}, {ID: "2"}})).To(Succeed())
+
+			Expect(plugin.published).To(HaveLen(2))
+			Expect(plugin.published[1].ID).To(Equal("2"))
+		})
+	})
+
+	When("A batch is partially published", func() {
+		It("Should only republish the failed events when the batch is retried", func() {
+			eventService := NewIdempotentEventService(plugin, time.Minute, 10)
+			batch := []*NitricEvent{{ID: "1"}, {ID: "2"}, {ID: "3"}}
+
+			plugin.err = &PartialPublishError{
+				Published: 2,
+				Failed:    []*FailedEvent{{Index: 1, ID: "2", StatusCode: 429, Err: fmt.Errorf("throttled")}},
+			}
+			Expect(eventService.PublishBatch("test", batch)).ToNot(Succeed())
+
+			plugin.err = nil
+			Expect(eventService.PublishBatch("test", batch)).To(Succeed())
+
+			Expect(plugin.published).To(HaveLen(1))
+			Expect(plugin.published[0].ID).To(Equal("2"))
+		})
+	})
+})
@@ -0,0 +1,160 @@
+// Copyright 2021 Nitric Pty Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// An in-memory events plugin that records published events, for asserting on publishes in tests
+package mock_events_service
+
+import (
+	"sync"
+
+	"github.com/nitrictech/nitric/pkg/plugins/events"
+)
+
+// PublishedEvent - An event published to a topic
+type PublishedEvent struct {
+	Topic string
+	Event *events.NitricEvent
+}
+
+// MockEventService - An events plugin that records every published event rather than delivering it
+type MockEventService struct {
+	events.UnimplementedeventsPlugin
+	lock      sync.RWMutex
+	topics    []string
+	published []PublishedEvent
+}
+
+var _ events.EventService = (*MockEventService)(nil)
+
+// Publish - Records the event as published to the topic
+func (s *MockEventService) Publish(topic string, event *events.NitricEvent) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.published = append(s.published, PublishedEvent{
+		Topic: topic,
+		Event: event,
+	})
+
+	return nil
+}
+
+// PublishBatch - Records each event as published to the topic, in order
+func (s *MockEventService) PublishBatch(topic string, evts []*events.NitricEvent) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for _, evt := range evts {
+		s.published = append(s.published, PublishedEvent{
+			Topic: topic,
+			Event: evt,
+		})
+	}
+
+	return nil
+}
+
+// ListTopics - Returns the registered topics
+func (s *MockEventService) ListTopics() ([]string, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	topics := make([]string, len(s.topics))
+	copy(topics, s.topics)
+
+	return topics, nil
+}
+
+// ListTopicsWithPrefix - Returns the topics whose names start with the prefix
+func (s *MockEventService) ListTopicsWithPrefix(prefix string) ([]string, error) {
+	topics, err := s.ListTopics()
+	if err != nil {
+		return nil, err
+	}
+
+	return events.FilterTopicsByPrefix(topics, prefix), nil
+}
+
+// RegisterTopics - Adds topics to those returned by ListTopics
+func (s *MockEventService) RegisterTopics(topics ...string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for _, topic := range topics {
+		registered := false
+		for _, t := range s.topics {
+			if t == topic {
+				registered = true
+				break
+			}
+		}
+
+		if !registered {
+			s.topics = append(s.topics, topic)
+		}
+	}
+}
+
+// Published - Returns a copy of the events published so far, in the order they were published
+func (s *MockEventService) Published() []PublishedEvent {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	published := make([]PublishedEvent, len(s.published))
+	copy(published, s.published)
+
+	return published
+}
+
+// PublishedTo - Returns the events published to a topic, in the order they were published
+func (s *MockEventService) PublishedTo(topic string) []*events.NitricEvent {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	evts := make([]*events.NitricEvent, 0)
+	for _, p := range s.published {
+		if p.Topic == topic {
+			evts = append(evts, p.Event)
+		}
+	}
+
+	return evts
+}
+
+// Reset - Clears the recorded events, registered topics are kept
+func (s *MockEventService) Reset() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.published = make([]PublishedEvent, 0)
+}
+
+// Capabilities - Returns the baseline capabilities along with the optional operations the plugin supports
+func (s *MockEventService) Capabilities() []string {
+	return append(events.BaselineCapabilities(),
+		events.CapabilityPublishBatch,
+	)
+}
+
+// New - Creates a new mock events plugin with the given topics registered,
+// which is returned as its concrete type so published events can be inspected
+func New(topics ...string) (*MockEventService, error) {
+	s := &MockEventService{
+		topics:    make([]string, 0),
+		published: make([]PublishedEvent, 0),
+	}
+	s.RegisterTopics(topics...)
+
+	return s, nil
+}
@@ -0,0 +1,103 @@
+// Copyright 2021 Nitric Pty Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mock_events_service_test
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/nitrictech/nitric/pkg/plugins/events"
+	mock_events_service "github.com/nitrictech/nitric/pkg/plugins/events/mock"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("MockEventService", func() {
+	Context("Publish", func() {
+		It("Should record the published event", func() {
+			eventsPlugin, _ := mock_events_service.New()
+
+			evt := &events.NitricEvent{
+				ID:          "1234",
+				PayloadType: "test-payload",
+				Payload: map[string]interface{}{
+					"Test": "Test",
+				},
+			}
+			err := eventsPlugin.Publish("test", evt)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			Expect(eventsPlugin.Published()).To(Equal([]mock_events_service.PublishedEvent{
+				{Topic: "test", Event: evt},
+			}))
+		})
+
+		When("Events are published concurrently", func() {
+			It("Should record every event", func() {
+				eventsPlugin, _ := mock_events_service.New()
+
+				wg := sync.WaitGroup{}
+				for i := 0; i < 50; i++ {
+					wg.Add(1)
+					go func(i int) {
+						defer wg.Done()
+						eventsPlugin.Publish("test", &events.NitricEvent{ID: fmt.Sprintf("%d", i)})
+					}(i)
+				}
+				wg.Wait()
+
+				Expect(eventsPlugin.PublishedTo("test")).To(HaveLen(50))
+			})
+		})
+	})
+
+	Context("PublishBatch", func() {
+		It("Should record each event in order", func() {
+			eventsPlugin, _ := mock_events_service.New()
+
+			err := eventsPlugin.PublishBatch("test", []*events.NitricEvent{{ID: "1"}, {ID: "2"}})
+			Expect(err).ShouldNot(HaveOccurred())
+			eventsPlugin.Publish("other", &events.NitricEvent{ID: "3"})
+
+			published := eventsPlugin.PublishedTo("test")
+			Expect(published).To(HaveLen(2))
+			Expect(published[0].ID).To(Equal("1"))
+			Expect(published[1].ID).To(Equal("2"))
+		})
+	})
+
+	Context("ListTopics", func() {
+		It("Should return the registered topics", func() {
+			eventsPlugin, _ := mock_events_service.New("test")
+			eventsPlugin.RegisterTopics("other", "test")
+
+			topics, err := eventsPlugin.ListTopics()
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(topics).To(Equal([]string{"test", "other"}))
+		})
+	})
+
+	Context("Reset", func() {
+		It("Should clear the published events", func() {
+			eventsPlugin, _ := mock_events_service.New()
+			eventsPlugin.Publish("test", &events.NitricEvent{ID: "1"})
+
+			eventsPlugin.Reset()
+
+			Expect(eventsPlugin.Published()).To(BeEmpty())
+		})
+	})
+})
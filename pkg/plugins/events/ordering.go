@@ -0,0 +1,66 @@
+// Copyright 2021 Nitric Pty Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package events
+
+import (
+	"fmt"
+
+	"github.com/nitrictech/nitric/pkg/plugins/errors"
+	"github.com/nitrictech/nitric/pkg/plugins/errors/codes"
+)
+
+// OrderedEventService - Implemented by event plugins that deliver events with the same ordering key
+// in the order they were published, e.g. to process state changes for a single entity in sequence
+type OrderedEventService interface {
+	PublishOrdered(topic string, orderingKey string, event *NitricEvent) error
+}
+
+// WithOrderingKey - Returns a copy of the event with the given ordering key
+func WithOrderingKey(event *NitricEvent, orderingKey string) *NitricEvent {
+	ordered := *event
+	ordered.OrderingKey = orderingKey
+	return &ordered
+}
+
+// PublishOrdered - Publishes the event with the ordering key, failing with Unimplemented
+// if the plugin can't preserve the order of events
+func PublishOrdered(plugin EventService, topic string, orderingKey string, event *NitricEvent) error {
+	newErr := errors.ErrorsWithScope(
+		"events.PublishOrdered",
+		map[string]interface{}{
+			"topic":       topic,
+			"orderingKey": orderingKey,
+		},
+	)
+
+	if orderingKey == "" {
+		return newErr(
+			codes.InvalidArgument,
+			"provided invalid ordering key",
+			fmt.Errorf("non-blank ordering key is required"),
+		)
+	}
+
+	ordered, ok := plugin.(OrderedEventService)
+	if !ok {
+		return newErr(
+			codes.Unimplemented,
+			"ordered publishing is not supported by this provider",
+			nil,
+		)
+	}
+
+	return ordered.PublishOrdered(topic, orderingKey, event)
+}
@@ -0,0 +1,74 @@
+// Copyright 2021 Nitric Pty Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package events
+
+import (
+	"time"
+
+	"github.com/nitrictech/nitric/pkg/plugins/errors"
+	"github.com/nitrictech/nitric/pkg/plugins/errors/codes"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// orderedEventService - An event plugin that records ordered publishes
+type orderedEventService struct {
+	recordingEventService
+}
+
+func (o *orderedEventService) PublishOrdered(topic string, orderingKey string, event *NitricEvent) error {
+	return o.Publish(topic, WithOrderingKey(event, orderingKey))
+}
+
+var _ = Describe("Ordering", func() {
+	When("The plugin supports ordered publishing", func() {
+		It("Should publish the event with the ordering key", func() {
+			plugin := &orderedEventService{}
+			event := &NitricEvent{ID: "1"}
+
+			Expect(PublishOrdered(plugin, "test", "user-1", event)).To(Succeed())
+			Expect(plugin.published).To(HaveLen(1))
+			Expect(plugin.published[0].OrderingKey).To(Equal("user-1"))
+
+			By("Leaving the caller's event unchanged")
+			Expect(event.OrderingKey).To(BeEmpty())
+		})
+
+		It("Should preserve ordering through the idempotent wrapper", func() {
+			plugin := &orderedEventService{}
+			eventService := NewIdempotentEventService(plugin, time.Minute, 10)
+
+			Expect(PublishOrdered(eventService, "test", "user-1", &NitricEvent{ID: "1"})).To(Succeed())
+			Expect(plugin.published[0].OrderingKey).To(Equal("user-1"))
+		})
+	})
+
+	When("The plugin doesn't support ordered publishing", func() {
+		It("Should return an unimplemented error", func() {
+			plugin := &recordingEventService{}
+
+			err := PublishOrdered(plugin, "test", "user-1", &NitricEvent{ID: "1"})
+			Expect(errors.Code(err)).To(Equal(codes.Unimplemented))
+			Expect(plugin.published).To(BeEmpty())
+		})
+	})
+
+	When("No ordering key is provided", func() {
+		It("Should return an invalid argument error", func() {
+			err := PublishOrdered(&orderedEventService{}, "test", "", &NitricEvent{ID: "1"})
+			Expect(errors.Code(err)).To(Equal(codes.InvalidArgument))
+		})
+	})
+})
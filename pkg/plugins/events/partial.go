@@ -0,0 +1,81 @@
+// Copyright 2021 Nitric Pty Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package events
+
+import (
+	"errors"
+	"fmt"
+)
+
+// FailedEvent - An event from a batch that the provider didn't accept
+type FailedEvent struct {
+	// Index - The position of the event in the batch passed to PublishBatch
+	Index int
+	ID    string
+	// StatusCode - The HTTP status returned for the request the event was sent in, 0 if no response was received
+	StatusCode int
+	Err        error
+}
+
+// PartialPublishError - Returned from PublishBatch when only some of the events in the batch were published,
+// so callers can retry the failed events without republishing the rest
+type PartialPublishError struct {
+	Published int
+	Failed    []*FailedEvent
+}
+
+func (e *PartialPublishError) Error() string {
+	if len(e.Failed) == 0 {
+		return fmt.Sprintf("%d events were published", e.Published)
+	}
+
+	first := e.Failed[0]
+	return fmt.Sprintf(
+		"%d of %d events failed to publish, first failure at index %d (event %s, status %d): %v",
+		len(e.Failed), len(e.Failed)+e.Published, first.Index, first.ID, first.StatusCode, first.Err,
+	)
+}
+
+// FailedIndices - Returns the positions of the failed events in the published batch
+func (e *PartialPublishError) FailedIndices() []int {
+	indices := make([]int, 0, len(e.Failed))
+	for _, f := range e.Failed {
+		indices = append(indices, f.Index)
+	}
+
+	return indices
+}
+
+// FailedEvents - Returns the subset of the published batch that failed, ready to be retried
+func (e *PartialPublishError) FailedEvents(batch []*NitricEvent) []*NitricEvent {
+	failed := make([]*NitricEvent, 0, len(e.Failed))
+	for _, f := range e.Failed {
+		if f.Index >= 0 && f.Index < len(batch) {
+			failed = append(failed, batch[f.Index])
+		}
+	}
+
+	return failed
+}
+
+// AsPartialPublishError - Finds a PartialPublishError in the error chain, if there is one
+func AsPartialPublishError(err error) (*PartialPublishError, bool) {
+	var partialErr *PartialPublishError
+	if errors.As(err, &partialErr) {
+		return partialErr, true
+	}
+
+	return nil, false
+}
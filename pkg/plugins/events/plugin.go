@@ -14,11 +14,34 @@
 
 package events
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+)
+
+// The capabilities an event plugin may support, named after the operations of the event and topic services
+const (
+	CapabilityPublish        = "publish"
+	CapabilityPublishBatch   = "publish_batch"
+	CapabilityPublishOrdered = "publish_ordered"
+	CapabilityListTopics     = "list_topics"
+)
+
+// BaselineCapabilities - Returns the capabilities supported by every event plugin
+func BaselineCapabilities() []string {
+	return []string{CapabilityPublish, CapabilityListTopics}
+}
 
 type EventService interface {
 	Publish(topic string, event *NitricEvent) error
+	PublishBatch(topic string, events []*NitricEvent) error
 	ListTopics() ([]string, error)
+	// ListTopicsWithPrefix - Lists the topics whose names start with the prefix, filtered by the provider where supported
+	ListTopicsWithPrefix(prefix string) ([]string, error)
+	// Capabilities - Returns the operations supported by the plugin, so clients can degrade gracefully without them
+	Capabilities() []string
+	// Close - Releases the clients and background resources held by the plugin, called once on shutdown
+	Close() error
 }
 
 type UnimplementedeventsPlugin struct {
@@ -29,6 +52,37 @@ func (*UnimplementedeventsPlugin) Publish(topic string, event *NitricEvent) erro
 	return fmt.Errorf("UNIMPLEMENTED")
 }
 
+func (*UnimplementedeventsPlugin) PublishBatch(topic string, events []*NitricEvent) error {
+	return fmt.Errorf("UNIMPLEMENTED")
+}
+
 func (*UnimplementedeventsPlugin) ListTopics() ([]string, error) {
 	return nil, fmt.Errorf("UNIMPLEMENTED")
 }
+
+func (*UnimplementedeventsPlugin) ListTopicsWithPrefix(prefix string) ([]string, error) {
+	return nil, fmt.Errorf("UNIMPLEMENTED")
+}
+
+// Capabilities - Plugins support the baseline capabilities unless they declare others
+func (*UnimplementedeventsPlugin) Capabilities() []string {
+	return BaselineCapabilities()
+}
+
+// Close - Plugins without resources to release have nothing to close
+func (*UnimplementedeventsPlugin) Close() error {
+	return nil
+}
+
+// FilterTopicsByPrefix - Returns the topics whose names start with the prefix,
+// for providers that can't filter topics themselves
+func FilterTopicsByPrefix(topics []string, prefix string) []string {
+	filtered := make([]string, 0, len(topics))
+	for _, topic := range topics {
+		if strings.HasPrefix(topic, prefix) {
+			filtered = append(filtered, topic)
+		}
+	}
+
+	return filtered
+}
@@ -16,8 +16,9 @@ package pubsub_service
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
+	"hash/fnv"
+	"sync"
 
 	ifaces_pubsub "github.com/nitrictech/nitric/pkg/ifaces/pubsub"
 
@@ -29,9 +30,29 @@ import (
 	"google.golang.org/api/iterator"
 )
 
+// orderingLockStripes - The number of locks publishes with ordering keys are spread over
+const orderingLockStripes = 64
+
+// orderingKeyAttribute - Carries the ordering key of an event to subscribers
+const orderingKeyAttribute = "x-nitric-ordering-key"
+
 type PubsubEventService struct {
 	events.UnimplementedeventsPlugin
 	client ifaces_pubsub.PubsubClient
+	// Serializes publishes of events with the same ordering key, keys are hashed to a lock
+	orderingLocks [orderingLockStripes]sync.Mutex
+}
+
+// orderingLock - Returns the lock publishes with the ordering key are serialized by
+func (s *PubsubEventService) orderingLock(orderingKey string) *sync.Mutex {
+	h := fnv.New32a()
+	h.Write([]byte(orderingKey))
+	return &s.orderingLocks[h.Sum32()%orderingLockStripes]
+}
+
+// Close - Closes the Pub/Sub client and its connections
+func (s *PubsubEventService) Close() error {
+	return s.client.Close()
 }
 
 func (s *PubsubEventService) ListTopics() ([]string, error) {
@@ -54,6 +75,16 @@ func (s *PubsubEventService) ListTopics() ([]string, error) {
 	return topics, nil
 }
 
+// ListTopicsWithPrefix - Returns the topics whose names start with the prefix, Pub/Sub has no prefix filter so topics are filtered once listed
+func (s *PubsubEventService) ListTopicsWithPrefix(prefix string) ([]string, error) {
+	topics, err := s.ListTopics()
+	if err != nil {
+		return nil, err
+	}
+
+	return events.FilterTopicsByPrefix(topics, prefix), nil
+}
+
 func (s *PubsubEventService) Publish(topic string, event *events.NitricEvent) error {
 	newErr := errors.ErrorsWithScope(
 		"PubsubEventService.Publish",
@@ -65,7 +96,8 @@ func (s *PubsubEventService) Publish(topic string, event *events.NitricEvent) er
 
 	ctx := context.TODO()
 
-	eventBytes, err := json.Marshal(event)
+	// Pubsub carries raw bytes
+	payloadBytes, encoding, err := event.EncodePayload(true)
 
 	if err != nil {
 		return newErr(
@@ -77,11 +109,28 @@ func (s *PubsubEventService) Publish(topic string, event *events.NitricEvent) er
 
 	pubsubTopic := s.client.Topic(topic)
 
+	attributes := map[string]string{
+		"x-nitric-topic": topic,
+		// Allows subscribers to dedupe redelivered events
+		"x-nitric-event-id":     event.ID,
+		"x-nitric-payload-type": event.PayloadType,
+		// Allows subscribers to decode binary payloads
+		events.PayloadEncodingAttribute: encoding.String(),
+	}
+
+	if event.OrderingKey != "" {
+		attributes[orderingKeyAttribute] = event.OrderingKey
+
+		// Wait for each publish to be acknowledged before the next with the same key is sent,
+		// so they reach Pub/Sub in order
+		lock := s.orderingLock(event.OrderingKey)
+		lock.Lock()
+		defer lock.Unlock()
+	}
+
 	msg := ifaces_pubsub.AdaptPubsubMessage(&pubsub.Message{
-		Attributes: map[string]string{
-			"x-nitric-topic": topic,
-		},
-		Data: eventBytes,
+		Attributes: attributes,
+		Data:       payloadBytes,
 	})
 
 	if _, err := pubsubTopic.Publish(ctx, msg).Get(ctx); err != nil {
@@ -95,6 +144,20 @@ func (s *PubsubEventService) Publish(topic string, event *events.NitricEvent) er
 	return nil
 }
 
+// PublishOrdered - Publishes the event with the ordering key, events with the same key are published in sequence.
+// The Pub/Sub client this plugin is built with predates message ordering keys, so the key is carried as the
+// x-nitric-ordering-key attribute and ordering is best effort until the client is upgraded
+func (s *PubsubEventService) PublishOrdered(topic string, orderingKey string, event *events.NitricEvent) error {
+	return s.Publish(topic, events.WithOrderingKey(event, orderingKey))
+}
+
+// Capabilities - Returns the baseline capabilities along with the optional operations the plugin supports
+func (s *PubsubEventService) Capabilities() []string {
+	return append(events.BaselineCapabilities(),
+		events.CapabilityPublishOrdered,
+	)
+}
+
 func New() (events.EventService, error) {
 	ctx := context.Background()
 
@@ -15,6 +15,8 @@
 package pubsub_service_test
 
 import (
+	"fmt"
+
 	"github.com/nitrictech/nitric/pkg/plugins/events"
 	pubsub_service "github.com/nitrictech/nitric/pkg/plugins/events/pubsub"
 	mock_pubsub "github.com/nitrictech/nitric/tests/mocks/pubsub"
@@ -49,6 +51,19 @@ var _ = Describe("Pubsub Plugin", func() {
 				Expect(topics).To(ContainElement("Test"))
 			})
 		})
+
+		When("Listing topics with a prefix", func() {
+			pubsubClient := mock_pubsub.NewMockPubsubClient(mock_pubsub.MockPubsubOptions{
+				Topics: []string{"tenant-a-orders", "tenant-b-orders"},
+			})
+			pubsubPlugin, _ := pubsub_service.NewWithClient(pubsubClient)
+
+			It("Should only return topics starting with the prefix", func() {
+				topics, err := pubsubPlugin.ListTopicsWithPrefix("tenant-a-")
+				Expect(err).To(BeNil())
+				Expect(topics).To(Equal([]string{"tenant-a-orders"}))
+			})
+		})
 	})
 
 	When("Publishing Messages", func() {
@@ -82,6 +97,54 @@ var _ = Describe("Pubsub Plugin", func() {
 				err := pubsubPlugin.Publish("Test", event)
 				Expect(err).ShouldNot(HaveOccurred())
 				Expect(pubsubClient.PublishedMessages["Test"]).To(HaveLen(1))
+
+				By("Publishing the payload as the message data")
+				msg := pubsubClient.PublishedMessages["Test"][0]
+				Expect(msg.Data()).To(MatchJSON(`{"Test":"Test"}`))
+
+				By("Setting the event ID as a message attribute")
+				Expect(msg.Attributes()["x-nitric-event-id"]).To(Equal("Test"))
+			})
+		})
+
+		When("With a raw binary payload", func() {
+			pubsubClient := mock_pubsub.NewMockPubsubClient(mock_pubsub.MockPubsubOptions{
+				Topics: []string{"Test"},
+			})
+			pubsubPlugin, _ := pubsub_service.NewWithClient(pubsubClient)
+
+			It("should publish the bytes unchanged", func() {
+				err := pubsubPlugin.Publish("Test", &events.NitricEvent{
+					ID:       "Test",
+					Encoding: events.PayloadEncoding_Raw,
+					Data:     []byte{0x00, 0xff, 0x10},
+				})
+				Expect(err).ShouldNot(HaveOccurred())
+
+				msg := pubsubClient.PublishedMessages["Test"][0]
+				Expect(msg.Data()).To(Equal([]byte{0x00, 0xff, 0x10}))
+				Expect(msg.Attributes()["x-nitric-payload-encoding"]).To(Equal("RAW"))
+			})
+		})
+
+		When("With an ordering key", func() {
+			pubsubClient := mock_pubsub.NewMockPubsubClient(mock_pubsub.MockPubsubOptions{
+				Topics: []string{"Test"},
+			})
+			pubsubPlugin, _ := pubsub_service.NewWithClient(pubsubClient)
+
+			It("should publish the events in order, carrying the key as an attribute", func() {
+				for _, id := range []string{"1", "2", "3"} {
+					err := events.PublishOrdered(pubsubPlugin, "Test", "user-1", &events.NitricEvent{ID: id})
+					Expect(err).ShouldNot(HaveOccurred())
+				}
+
+				msgs := pubsubClient.PublishedMessages["Test"]
+				Expect(msgs).To(HaveLen(3))
+				for i, msg := range msgs {
+					Expect(msg.Attributes()["x-nitric-event-id"]).To(Equal(fmt.Sprintf("%d", i+1)))
+					Expect(msg.Attributes()["x-nitric-ordering-key"]).To(Equal("user-1"))
+				}
 			})
 		})
 	})
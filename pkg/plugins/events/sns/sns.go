@@ -17,11 +17,12 @@ package sns_service
 import (
 	"encoding/json"
 	"fmt"
-	"strings"
+	"sync"
 
 	utils2 "github.com/nitrictech/nitric/pkg/utils"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/sns"
 	"github.com/aws/aws-sdk-go/service/sns/snsiface"
@@ -33,23 +34,94 @@ import (
 type SnsEventService struct {
 	events.UnimplementedeventsPlugin
 	client snsiface.SNSAPI
+	// Cache of nitric topic names to SNS topic ARNs
+	topicArnLock sync.RWMutex
+	topicArns    map[string]string
 }
 
-// Retrieve the topicArn for a given named nitric topic
-func (s *SnsEventService) getTopicArnFromName(name *string) (*string, error) {
-	topicsOutput, error := s.client.ListTopics(&sns.ListTopicsInput{})
+// snsErrorCode - Maps an error returned by the SNS API to the closest nitric error code
+func snsErrorCode(err error) codes.Code {
+	if awsErr, ok := err.(awserr.Error); ok {
+		switch awsErr.Code() {
+		case sns.ErrCodeNotFoundException:
+			return codes.NotFound
+		case sns.ErrCodeAuthorizationErrorException:
+			return codes.PermissionDenied
+		case sns.ErrCodeInvalidParameterException, sns.ErrCodeInvalidParameterValueException:
+			return codes.InvalidArgument
+		case sns.ErrCodeThrottledException:
+			return codes.ResourceExhausted
+		}
+	}
 
-	if error != nil {
-		return nil, fmt.Errorf("There was an error retrieving SNS topics: %v", error)
+	if reqErr, ok := err.(awserr.RequestFailure); ok {
+		if reqErr.StatusCode() >= 500 {
+			return codes.Unavailable
+		} else if reqErr.StatusCode() >= 400 {
+			return codes.FailedPrecondition
+		}
 	}
 
-	for _, t := range topicsOutput.Topics {
-		if strings.Contains(*t.TopicArn, *name) {
-			return t.TopicArn, nil
+	return codes.Internal
+}
+
+// getNitricTopics - Retrieves all SNS topics tagged as nitric topics, keyed by their nitric name
+func (s *SnsEventService) getNitricTopics() (map[string]string, error) {
+	topics := make(map[string]string)
+
+	err := s.client.ListTopicsPages(&sns.ListTopicsInput{}, func(page *sns.ListTopicsOutput, lastPage bool) bool {
+		for _, t := range page.Topics {
+			tagsOutput, err := s.client.ListTagsForResource(&sns.ListTagsForResourceInput{
+				ResourceArn: t.TopicArn,
+			})
+
+			if err != nil {
+				// Topics we can't inspect are not considered nitric topics
+				continue
+			}
+
+			for _, tag := range tagsOutput.Tags {
+				if aws.StringValue(tag.Key) == "x-nitric-name" {
+					topics[aws.StringValue(tag.Value)] = aws.StringValue(t.TopicArn)
+				}
+			}
 		}
+
+		return true
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return topics, nil
+}
+
+// Retrieve the topicArn for a given named nitric topic
+func (s *SnsEventService) getTopicArnFromName(name string) (string, error) {
+	s.topicArnLock.RLock()
+	arn, ok := s.topicArns[name]
+	s.topicArnLock.RUnlock()
+
+	if ok {
+		return arn, nil
+	}
+
+	topics, err := s.getNitricTopics()
+
+	if err != nil {
+		return "", fmt.Errorf("There was an error retrieving SNS topics: %v", err)
 	}
 
-	return nil, fmt.Errorf("Unable to find topic with name: %s", *name)
+	s.topicArnLock.Lock()
+	s.topicArns = topics
+	s.topicArnLock.Unlock()
+
+	if arn, ok := topics[name]; ok {
+		return arn, nil
+	}
+
+	return "", fmt.Errorf("Unable to find topic with name: %s", name)
 }
 
 // Publish to a given topic
@@ -62,6 +134,7 @@ func (s *SnsEventService) Publish(topic string, event *events.NitricEvent) error
 		},
 	)
 
+	// SNS messages are text, binary payloads are carried as base64 in the marshalled event's data
 	data, err := json.Marshal(event)
 
 	if err != nil {
@@ -72,7 +145,7 @@ func (s *SnsEventService) Publish(topic string, event *events.NitricEvent) error
 		)
 	}
 
-	topicArn, err := s.getTopicArnFromName(&topic)
+	topicArn, err := s.getTopicArnFromName(topic)
 
 	if err != nil {
 		return newErr(
@@ -85,18 +158,41 @@ func (s *SnsEventService) Publish(topic string, event *events.NitricEvent) error
 	message := string(data)
 
 	publishInput := &sns.PublishInput{
-		TopicArn: topicArn,
+		TopicArn: aws.String(topicArn),
 		Message:  &message,
 		// MessageStructure: json is for an AWS specific JSON format,
 		// which sends different messages to different subscription types. Don't use it.
 		// MessageStructure: aws.String("json"),
 	}
 
+	// Allow subscribers to filter on the type of payload
+	if event.PayloadType != "" {
+		publishInput.MessageAttributes = map[string]*sns.MessageAttributeValue{
+			"x-nitric-payload-type": {
+				DataType:    aws.String("String"),
+				StringValue: aws.String(event.PayloadType),
+			},
+		}
+	}
+
+	// FIFO topics deliver events in the same message group in order, the event ID dedupes retried publishes
+	if event.OrderingKey != "" {
+		publishInput.MessageGroupId = aws.String(event.OrderingKey)
+		publishInput.MessageDeduplicationId = aws.String(event.ID)
+	}
+
 	_, err = s.client.Publish(publishInput)
 
 	if err != nil {
+		if snsErrorCode(err) == codes.NotFound {
+			// The topic may have been removed, resolve it again next time
+			s.topicArnLock.Lock()
+			delete(s.topicArns, topic)
+			s.topicArnLock.Unlock()
+		}
+
 		return newErr(
-			codes.Internal,
+			snsErrorCode(err),
 			"unable to publish message",
 			err,
 		)
@@ -105,28 +201,49 @@ func (s *SnsEventService) Publish(topic string, event *events.NitricEvent) error
 	return nil
 }
 
+// PublishOrdered - Publishes the event to a FIFO topic, using the ordering key as its message group
+func (s *SnsEventService) PublishOrdered(topic string, orderingKey string, event *events.NitricEvent) error {
+	return s.Publish(topic, events.WithOrderingKey(event, orderingKey))
+}
+
 func (s *SnsEventService) ListTopics() ([]string, error) {
 	newErr := errors.ErrorsWithScope("SnsEventService.ListTopics", nil)
 
-	topicsOutput, err := s.client.ListTopics(&sns.ListTopicsInput{})
+	nitricTopics, err := s.getNitricTopics()
 
 	if err != nil {
 		return nil, newErr(
-			codes.Internal,
+			snsErrorCode(err),
 			"error retrieving topics",
 			err,
 		)
 	}
 
 	var topics []string
-	for _, t := range topicsOutput.Topics {
-		// TODO: Extract topic name from ARN
-		topics = append(topics, *t.TopicArn)
+	for name := range nitricTopics {
+		topics = append(topics, name)
 	}
 
 	return topics, nil
 }
 
+// ListTopicsWithPrefix - Returns the topics whose names start with the prefix, SNS has no prefix filter so topics are filtered once listed
+func (s *SnsEventService) ListTopicsWithPrefix(prefix string) ([]string, error) {
+	topics, err := s.ListTopics()
+	if err != nil {
+		return nil, err
+	}
+
+	return events.FilterTopicsByPrefix(topics, prefix), nil
+}
+
+// Capabilities - Returns the baseline capabilities along with the optional operations the plugin supports
+func (s *SnsEventService) Capabilities() []string {
+	return append(events.BaselineCapabilities(),
+		events.CapabilityPublishOrdered,
+	)
+}
+
 // Create new SNS event service plugin
 func New() (events.EventService, error) {
 	awsRegion := utils2.GetEnv("AWS_REGION", "us-east-1")
@@ -141,13 +258,13 @@ func New() (events.EventService, error) {
 
 	snsClient := sns.New(sess)
 
-	return &SnsEventService{
-		client: snsClient,
-	}, nil
+	return NewWithClient(snsClient)
 }
 
+// NewWithClient - Create a new SNS event service plugin using the provided client
 func NewWithClient(client snsiface.SNSAPI) (events.EventService, error) {
 	return &SnsEventService{
-		client: client,
+		client:    client,
+		topicArns: make(map[string]string),
 	}, nil
 }
@@ -33,20 +33,42 @@ type MockSNSClient struct {
 	snsiface.SNSAPI
 	// Available topics
 	availableTopics []*sns.Topic
+	// Nitric name tags of the available topics, keyed by topic ARN
+	topicNames map[string]string
+	// Input of the last publish request
+	lastPublish *sns.PublishInput
 }
 
-func (m *MockSNSClient) ListTopics(input *sns.ListTopicsInput) (*sns.ListTopicsOutput, error) {
-	return &sns.ListTopicsOutput{
+func (m *MockSNSClient) ListTopicsPages(input *sns.ListTopicsInput, fn func(*sns.ListTopicsOutput, bool) bool) error {
+	fn(&sns.ListTopicsOutput{
 		Topics: m.availableTopics,
+	}, true)
+
+	return nil
+}
+
+func (m *MockSNSClient) ListTagsForResource(input *sns.ListTagsForResourceInput) (*sns.ListTagsForResourceOutput, error) {
+	tags := make([]*sns.Tag, 0)
+
+	if name, ok := m.topicNames[*input.ResourceArn]; ok {
+		tags = append(tags, &sns.Tag{
+			Key:   aws.String("x-nitric-name"),
+			Value: aws.String(name),
+		})
+	}
+
+	return &sns.ListTagsForResourceOutput{
+		Tags: tags,
 	}, nil
 }
 
 func (m *MockSNSClient) Publish(input *sns.PublishInput) (*sns.PublishOutput, error) {
+	m.lastPublish = input
 	topicArn := input.TopicArn
 
 	var topic *sns.Topic
 	for _, t := range m.availableTopics {
-		if topicArn == t.TopicArn {
+		if *topicArn == *t.TopicArn {
 			topic = t
 			break
 		}
@@ -64,23 +86,33 @@ var _ = Describe("Sns", func() {
 	Context("Get Topics", func() {
 		When("There are available topics", func() {
 			eventsClient, _ := sns_service.NewWithClient(&MockSNSClient{
-				availableTopics: []*sns.Topic{{TopicArn: aws.String("test")}},
+				availableTopics: []*sns.Topic{
+					{TopicArn: aws.String("arn:aws:sns:us-east-1:000000000000:test")},
+					{TopicArn: aws.String("arn:aws:sns:us-east-1:000000000000:other")},
+				},
+				topicNames: map[string]string{
+					"arn:aws:sns:us-east-1:000000000000:test": "test",
+				},
 			})
 
-			It("Should return the available topics", func() {
+			It("Should return the names of the nitric topics", func() {
 				topics, err := eventsClient.ListTopics()
 
 				Expect(err).To(BeNil())
-				Expect(topics).To(ContainElements("test"))
+				Expect(topics).To(ConsistOf("test"))
 			})
 		})
 	})
 
 	Context("Publish", func() {
 		When("Publishing to an available topic", func() {
-			eventsClient, _ := sns_service.NewWithClient(&MockSNSClient{
-				availableTopics: []*sns.Topic{{TopicArn: aws.String("test")}},
-			})
+			mockClient := &MockSNSClient{
+				availableTopics: []*sns.Topic{{TopicArn: aws.String("arn:aws:sns:us-east-1:000000000000:test")}},
+				topicNames: map[string]string{
+					"arn:aws:sns:us-east-1:000000000000:test": "test",
+				},
+			}
+			eventsClient, _ := sns_service.NewWithClient(mockClient)
 			payload := map[string]interface{}{"Test": "test"}
 
 			It("Should publish without error", func() {
@@ -91,6 +123,36 @@ var _ = Describe("Sns", func() {
 				})
 
 				Expect(err).To(BeNil())
+
+				By("Publishing to the topic ARN")
+				Expect(*mockClient.lastPublish.TopicArn).To(Equal("arn:aws:sns:us-east-1:000000000000:test"))
+
+				By("Setting the payload type message attribute")
+				Expect(*mockClient.lastPublish.MessageAttributes["x-nitric-payload-type"].StringValue).To(Equal("Test Payload"))
+
+				By("Not setting a message group")
+				Expect(mockClient.lastPublish.MessageGroupId).To(BeNil())
+			})
+		})
+
+		When("Publishing an ordered event to a FIFO topic", func() {
+			mockClient := &MockSNSClient{
+				availableTopics: []*sns.Topic{{TopicArn: aws.String("arn:aws:sns:us-east-1:000000000000:test.fifo")}},
+				topicNames: map[string]string{
+					"arn:aws:sns:us-east-1:000000000000:test.fifo": "test",
+				},
+			}
+			eventsClient, _ := sns_service.NewWithClient(mockClient)
+
+			It("Should use the ordering key as the message group", func() {
+				err := events.PublishOrdered(eventsClient, "test", "user-1", &events.NitricEvent{
+					ID:          "testing",
+					PayloadType: "Test Payload",
+				})
+
+				Expect(err).To(BeNil())
+				Expect(*mockClient.lastPublish.MessageGroupId).To(Equal("user-1"))
+				Expect(*mockClient.lastPublish.MessageDeduplicationId).To(Equal("testing"))
 			})
 		})
 
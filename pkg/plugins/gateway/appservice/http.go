@@ -16,6 +16,7 @@ package http_service
 
 import (
 	"encoding/json"
+	"strings"
 
 	"github.com/nitrictech/nitric/pkg/triggers"
 	"github.com/nitrictech/nitric/pkg/worker"
@@ -44,10 +45,64 @@ func handleSubscriptionValidation(ctx *fasthttp.RequestCtx, events []eventgrid.E
 	ctx.Success("application/json", responseBody)
 }
 
+const (
+	blobCreatedEventType = "Microsoft.Storage.BlobCreated"
+	blobDeletedEventType = "Microsoft.Storage.BlobDeleted"
+)
+
+// blobEventData - The fields of storage blob event data included in bucket notifications
+type blobEventData struct {
+	ContentLength int64  `json:"contentLength"`
+	ETag          string `json:"eTag"`
+}
+
+// bucketNotificationFromBlobEvent - Translates an EventGrid blob event into a bucket notification,
+// returns false for events that aren't blob created or deleted events
+func bucketNotificationFromBlobEvent(event eventgrid.Event) (*triggers.BucketNotification, bool) {
+	if event.EventType == nil || event.Subject == nil {
+		return nil, false
+	}
+
+	var eventType triggers.BucketNotificationType
+	switch *event.EventType {
+	case blobCreatedEventType:
+		eventType = triggers.BucketNotificationType_Created
+	case blobDeletedEventType:
+		eventType = triggers.BucketNotificationType_Deleted
+	default:
+		return nil, false
+	}
+
+	// The subject identifies the blob as /blobServices/default/containers/<container>/blobs/<name>
+	parts := strings.SplitN(strings.TrimPrefix(*event.Subject, "/blobServices/default/containers/"), "/blobs/", 2)
+	if len(parts) != 2 {
+		return nil, false
+	}
+
+	data := blobEventData{}
+	if dataBytes, err := json.Marshal(event.Data); err == nil {
+		json.Unmarshal(dataBytes, &data)
+	}
+
+	return &triggers.BucketNotification{
+		Bucket:    parts[0],
+		Key:       parts[1],
+		EventType: eventType,
+		Size:      data.ContentLength,
+		ETag:      data.ETag,
+	}, true
+}
+
 func handleNotifications(ctx *fasthttp.RequestCtx, events []eventgrid.Event, wrkr worker.Worker) {
 	// FIXME: As we are batch handling events in azure
 	// how do we notify of failed event handling?
 	for _, event := range events {
+		if notification, ok := bucketNotificationFromBlobEvent(event); ok {
+			// FIXME: Handle error
+			wrkr.HandleBucketNotification(notification)
+			continue
+		}
+
 		// XXX: Assume we have a nitric event for now
 		// We have a valid nitric event
 		// Decode and pass to our function
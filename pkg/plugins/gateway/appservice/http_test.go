@@ -152,5 +152,42 @@ var _ = Describe("Http", func() {
 				Expect(event.Payload).To(BeEquivalentTo(payloadBytes))
 			})
 		})
+
+		When("With a blob created Notification event", func() {
+			It("Should handle the event as a bucket notification", func() {
+				testID := "1234"
+				eventType := "Microsoft.Storage.BlobCreated"
+				subject := "/blobServices/default/containers/my-container/blobs/images/my-image.png"
+				evt := []eventgrid.Event{
+					{
+						ID:        &testID,
+						EventType: &eventType,
+						Subject:   &subject,
+						Data: map[string]interface{}{
+							"contentLength": 1024,
+							"eTag":          "0x8D4BCC2E4835CD0",
+						},
+					},
+				}
+
+				requestBody, _ := json.Marshal(evt)
+				request, _ := http.NewRequest("POST", gatewayUrl, bytes.NewReader([]byte(requestBody)))
+				request.Header.Add("aeg-event-type", "Notification")
+				_, _ = http.DefaultClient.Do(request)
+
+				By("Not passing the notification as an event")
+				Expect(mockHandler.ReceivedEvents).To(BeEmpty())
+
+				By("Passing the bucket notification to the Nitric Application")
+				Expect(mockHandler.ReceivedNotifications).To(HaveLen(1))
+				Expect(*mockHandler.ReceivedNotifications[0]).To(Equal(triggers.BucketNotification{
+					Bucket:    "my-container",
+					Key:       "images/my-image.png",
+					EventType: triggers.BucketNotificationType_Created,
+					Size:      1024,
+					ETag:      "0x8D4BCC2E4835CD0",
+				}))
+			})
+		})
 	})
 })
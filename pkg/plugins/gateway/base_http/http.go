@@ -16,22 +16,49 @@
 package base_http
 
 import (
+	"context"
+	"crypto/tls"
 	"fmt"
+	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/nitrictech/nitric/pkg/triggers"
 	"github.com/nitrictech/nitric/pkg/utils"
 	"github.com/nitrictech/nitric/pkg/worker"
 
+	"github.com/nitrictech/nitric/pkg/plugins/errors"
+	"github.com/nitrictech/nitric/pkg/plugins/errors/codes"
 	"github.com/nitrictech/nitric/pkg/plugins/gateway"
 	"github.com/valyala/fasthttp"
 )
 
+// DefaultWorkerWaitTimeoutSeconds - How long requests received before the function connects wait for it by default
+const DefaultWorkerWaitTimeoutSeconds = 10
+
+// saturatedRetryAfterSeconds - How long clients are asked to wait before retrying requests shed by a saturated or busy pool
+const saturatedRetryAfterSeconds = "1"
+
 type HttpMiddleware func(*fasthttp.RequestCtx, worker.Worker) bool
 
 type BaseHttpGateway struct {
 	address string
-	server  *fasthttp.Server
+	// How long requests wait for a worker when none have registered, 0 fails requests immediately
+	workerWaitTimeout time.Duration
+	server            *fasthttp.Server
+	// Request bodies larger than this are buffered to a temp file in spillDir instead of memory, 0 disables spilling
+	spillBodyBytes int
+	spillDir       string
+	// Serves HTTPS when set, negotiating HTTP/2 with clients that support it
+	tlsConfig *tls.Config
+	// Accepts HTTP/2 without TLS (h2c) on plaintext connections
+	h2c bool
+	// Serves requests in place of server when HTTP/2 is enabled
+	httpServer *http.Server
+	// Populates the route and route params of requests matching a route, optional
+	router Router
+	// Proxies whose forwarding headers are trusted to resolve the client IP, nil trusts none
+	trustedProxies *triggers.TrustedProxies
 	gateway.UnimplementedGatewayPlugin
 
 	// Middleware for handling events
@@ -40,14 +67,44 @@ type BaseHttpGateway struct {
 	mw HttpMiddleware
 }
 
+// getWorker - Retrieves a worker from the pool, waiting for the function to connect if no workers have registered
+func (s *BaseHttpGateway) getWorker(pool worker.WorkerPool) (worker.Worker, error) {
+	if s.workerWaitTimeout <= 0 {
+		return pool.GetWorker()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.workerWaitTimeout)
+	defer cancel()
+
+	return pool.GetWorkerWait(ctx)
+}
+
+// newHttpTrigger - Creates the trigger for a request, spilling large bodies to disk when enabled
+func (s *BaseHttpGateway) newHttpTrigger(ctx *fasthttp.RequestCtx) (*triggers.HttpRequest, error) {
+	if s.spillBodyBytes <= 0 {
+		return triggers.FromHttpRequest(ctx), nil
+	}
+
+	return triggers.FromHttpRequestSpillingBody(ctx, s.spillBodyBytes, s.spillDir)
+}
+
 func (s *BaseHttpGateway) httpHandler(pool worker.WorkerPool) func(ctx *fasthttp.RequestCtx) {
 	return func(ctx *fasthttp.RequestCtx) {
-		wrkr, err := pool.GetWorker()
+		wrkr, err := s.getWorker(pool)
 
 		if err != nil {
+			if errors.Code(err) == codes.ResourceExhausted {
+				// The pool is shedding load, so the client should back off and retry
+				ctx.Response.Header.Set("Retry-After", saturatedRetryAfterSeconds)
+				ctx.Error("Too many requests are waiting to be handled", 503)
+				return
+			}
+
 			ctx.Error("Unable to get worker to handle request", 500)
 			return
 		}
+		// The middleware may handle the request itself, or it may fail before reaching the worker
+		defer worker.ReleaseWorker(wrkr)
 
 		if s.mw != nil {
 			if !s.mw(ctx, wrkr) {
@@ -57,7 +114,23 @@ func (s *BaseHttpGateway) httpHandler(pool worker.WorkerPool) func(ctx *fasthttp
 			}
 		}
 
-		httpTrigger := triggers.FromHttpRequest(ctx)
+		httpTrigger, err := s.newHttpTrigger(ctx)
+		if err != nil {
+			ctx.Error(fmt.Sprintf("Error reading HTTP Request body: %v", err), 500)
+			return
+		}
+		// Removes any temp file the body was buffered to, whether or not the worker handled the request
+		defer httpTrigger.Close()
+
+		if s.trustedProxies != nil {
+			httpTrigger.ClientIP = triggers.ClientIPFromRequest(ctx, s.trustedProxies)
+		}
+
+		if route, params, ok := s.router.Match(httpTrigger.Method, httpTrigger.Path); ok {
+			httpTrigger.Route = route.Template
+			httpTrigger.RouteParams = params
+		}
+
 		response, err := wrkr.HandleHttpRequest(httpTrigger)
 
 		if err != nil {
@@ -72,6 +145,13 @@ func (s *BaseHttpGateway) httpHandler(pool worker.WorkerPool) func(ctx *fasthttp
 		// Avoid content length header duplication
 		ctx.Response.Header.Del("Content-Length")
 		ctx.Response.SetStatusCode(response.StatusCode)
+
+		if response.IsStreamed() {
+			// The body is written to the client as chunks are received
+			ctx.Response.SetBodyStream(response.BodyStream, -1)
+			return
+		}
+
 		ctx.Response.SetBody(response.Body)
 	}
 }
@@ -83,10 +163,36 @@ func (s *BaseHttpGateway) Start(pool worker.WorkerPool) error {
 		Handler:         s.httpHandler(pool),
 	}
 
+	if s.spillBodyBytes > 0 {
+		// Bodies over the limit are streamed to the handler rather than read into memory,
+		// multipart forms included, as they would otherwise be parsed in memory
+		s.server.StreamRequestBody = true
+		s.server.MaxRequestBodySize = s.spillBodyBytes
+		s.server.DisablePreParseMultipartForm = true
+	}
+
+	// fasthttp only speaks HTTP/1.1, so HTTP/2 is served with net/http and the same handler
+	if s.tlsConfig != nil || s.h2c {
+		return s.serveNetHttp(s.server.Handler)
+	}
+
 	return s.server.ListenAndServe(s.address)
 }
 
+// SetTlsConfig - Serves HTTPS using the given config, must be called before Start
+func (s *BaseHttpGateway) SetTlsConfig(config *tls.Config) {
+	s.tlsConfig = config
+}
+
+// SetH2c - Accepts HTTP/2 without TLS on plaintext connections, must be called before Start
+func (s *BaseHttpGateway) SetH2c(enabled bool) {
+	s.h2c = enabled
+}
+
 func (s *BaseHttpGateway) Stop() error {
+	if s.httpServer != nil {
+		return s.httpServer.Close()
+	}
 	if s.server != nil {
 		return s.server.Shutdown()
 	}
@@ -96,10 +202,40 @@ func (s *BaseHttpGateway) Stop() error {
 // Create new HTTP gateway
 // XXX: No External Args for function atm (currently the plugin loader does not pass any argument information)
 func New(mw HttpMiddleware) (gateway.GatewayService, error) {
+	return NewWithRouter(mw, nil)
+}
+
+// NewWithRouter - Create new HTTP gateway that matches requests against the given routes
+func NewWithRouter(mw HttpMiddleware, router Router) (gateway.GatewayService, error) {
 	address := utils.GetEnv("GATEWAY_ADDRESS", ":9001")
 
+	workerWaitTimeoutEnv := utils.GetEnv("WORKER_WAIT_TIMEOUT_SECONDS", strconv.Itoa(DefaultWorkerWaitTimeoutSeconds))
+	workerWaitTimeoutSeconds, err := strconv.Atoi(workerWaitTimeoutEnv)
+	if err != nil || workerWaitTimeoutSeconds < 0 {
+		return nil, fmt.Errorf("invalid WORKER_WAIT_TIMEOUT_SECONDS env var, expected non-negative integer value, got %v", workerWaitTimeoutEnv)
+	}
+
+	spillBodyEnv := utils.GetEnv("REQUEST_BODY_SPILL_BYTES", "0")
+	spillBodyBytes, err := strconv.Atoi(spillBodyEnv)
+	if err != nil || spillBodyBytes < 0 {
+		return nil, fmt.Errorf("invalid REQUEST_BODY_SPILL_BYTES env var, expected non-negative integer value, got %v", spillBodyEnv)
+	}
+
+	var trustedProxies *triggers.TrustedProxies
+	if cidrs := utils.GetEnvList("TRUSTED_PROXIES"); len(cidrs) > 0 {
+		trustedProxies, err = triggers.ParseTrustedProxies(cidrs)
+		if err != nil {
+			return nil, fmt.Errorf("invalid TRUSTED_PROXIES env var: %v", err)
+		}
+	}
+
 	return &BaseHttpGateway{
-		address: address,
-		mw:      mw,
+		address:           address,
+		workerWaitTimeout: time.Duration(workerWaitTimeoutSeconds) * time.Second,
+		spillBodyBytes:    spillBodyBytes,
+		spillDir:          utils.GetEnv("REQUEST_BODY_SPILL_DIR", ""),
+		router:            router,
+		trustedProxies:    trustedProxies,
+		mw:                mw,
 	}, nil
 }
@@ -0,0 +1,127 @@
+// Copyright 2021 Nitric Pty Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package base_http
+
+import (
+	"io"
+	"net"
+	"net/http"
+
+	"github.com/valyala/fasthttp"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+// hopHeaders - Connection specific headers that HTTP/2 doesn't allow in responses
+var hopHeaders = map[string]bool{
+	"Connection":        true,
+	"Content-Length":    true,
+	"Keep-Alive":        true,
+	"Transfer-Encoding": true,
+	"Upgrade":           true,
+}
+
+// flushWriter - Flushes each write to the client, so streamed response bodies aren't held in the server's buffers
+type flushWriter struct {
+	w io.Writer
+	f http.Flusher
+}
+
+func (fw *flushWriter) Write(p []byte) (int, error) {
+	n, err := fw.w.Write(p)
+	if fw.f != nil {
+		fw.f.Flush()
+	}
+
+	return n, err
+}
+
+// adaptHandler - Serves a fasthttp handler over net/http, so requests are dispatched to workers
+// the same way whichever protocol they were received over
+func adaptHandler(handler fasthttp.RequestHandler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		var request fasthttp.Request
+		request.Header.SetMethod(req.Method)
+		request.SetRequestURI(req.URL.RequestURI())
+		request.Header.SetHost(req.Host)
+		for key, values := range req.Header {
+			for _, value := range values {
+				request.Header.Add(key, value)
+			}
+		}
+
+		var remoteAddr net.Addr
+		if addr, err := net.ResolveTCPAddr("tcp", req.RemoteAddr); err == nil {
+			remoteAddr = addr
+		}
+
+		var ctx fasthttp.RequestCtx
+		ctx.Init(&request, remoteAddr, nil)
+
+		// The body is streamed rather than read up front, so large bodies can be spilled to disk,
+		// a negative length is unknown as for chunked requests
+		if req.Body != nil && req.ContentLength != 0 {
+			ctx.Request.SetBodyStream(req.Body, int(req.ContentLength))
+		}
+
+		handler(&ctx)
+
+		ctx.Response.Header.VisitAll(func(key []byte, value []byte) {
+			if !hopHeaders[http.CanonicalHeaderKey(string(key))] {
+				rw.Header().Add(string(key), string(value))
+			}
+		})
+		rw.WriteHeader(ctx.Response.StatusCode())
+
+		flusher, _ := rw.(http.Flusher)
+		ctx.Response.BodyWriteTo(&flushWriter{w: rw, f: flusher})
+	})
+}
+
+// serveNetHttp - Serves the handler with net/http, negotiating HTTP/2 over TLS or accepting h2c on plaintext
+// connections when enabled. HTTP/1.1 clients are served as before
+func (s *BaseHttpGateway) serveNetHttp(handler fasthttp.RequestHandler) error {
+	ln, err := net.Listen("tcp4", s.address)
+	if err != nil {
+		return err
+	}
+
+	h2Server := &http2.Server{}
+	s.httpServer = &http.Server{
+		Handler:   adaptHandler(handler),
+		TLSConfig: s.tlsConfig.Clone(),
+	}
+
+	if s.tlsConfig == nil {
+		s.httpServer.Handler = h2c.NewHandler(s.httpServer.Handler, h2Server)
+		return ignoreServerClosed(s.httpServer.Serve(ln))
+	}
+
+	if err := http2.ConfigureServer(s.httpServer, h2Server); err != nil {
+		return err
+	}
+
+	// The certificates are provided by the TLS config
+	return ignoreServerClosed(s.httpServer.ServeTLS(ln, "", ""))
+}
+
+// ignoreServerClosed - Treats the server being stopped as a normal shutdown
+func ignoreServerClosed(err error) error {
+	if err == http.ErrServerClosed {
+		return nil
+	}
+
+	return err
+}
@@ -0,0 +1,316 @@
+// Copyright 2021 Nitric Pty Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package base_http_test
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/nitrictech/nitric/pkg/plugins/gateway"
+	"github.com/nitrictech/nitric/pkg/plugins/gateway/base_http"
+	"github.com/nitrictech/nitric/pkg/triggers"
+	"github.com/nitrictech/nitric/pkg/worker"
+	mock_worker "github.com/nitrictech/nitric/tests/mocks/worker"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"golang.org/x/net/http2"
+)
+
+// generateCert - Creates a PEM encoded certificate and key for 127.0.0.1, signed by the parent or self-signed if nil
+func generateCert(parent *x509.Certificate, parentKey *ecdsa.PrivateKey, isCA bool) (*x509.Certificate, *ecdsa.PrivateKey, []byte, []byte) {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: "127.0.0.1"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  isCA,
+	}
+
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+
+	der, _ := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	cert, _ := x509.ParseCertificate(der)
+	keyDer, _ := x509.MarshalECPrivateKey(key)
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})
+
+	return cert, key, certPEM, keyPEM
+}
+
+var _ = Describe("BaseHttpGateway", func() {
+	Context("TLS", func() {
+		var pool worker.WorkerPool
+		var serverCert *x509.Certificate
+		var certPEM, keyPEM []byte
+
+		BeforeEach(func() {
+			pool = worker.NewProcessPool(&worker.ProcessPoolOptions{})
+			pool.AddWorker(mock_worker.NewMockWorker(&mock_worker.MockWorkerOptions{
+				ReturnHttp: &triggers.HttpResponse{
+					Body:       []byte("success"),
+					StatusCode: 200,
+				},
+			}))
+
+			serverCert, _, certPEM, keyPEM = generateCert(nil, nil, true)
+		})
+
+		When("A certificate is configured", func() {
+			var gw gateway.GatewayService
+
+			BeforeEach(func() {
+				os.Setenv("GATEWAY_ADDRESS", "127.0.0.1:9101")
+				gw, _ = base_http.New(nil)
+
+				tlsConfig, err := gateway.NewTlsConfig(&gateway.TlsOptions{
+					CertPEM: certPEM,
+					KeyPEM:  keyPEM,
+				})
+				Expect(err).ShouldNot(HaveOccurred())
+				gw.(gateway.TlsGateway).SetTlsConfig(tlsConfig)
+
+				go gw.Start(pool)
+				time.Sleep(200 * time.Millisecond)
+			})
+
+			AfterEach(func() {
+				gw.Stop()
+			})
+
+			It("Should serve requests over TLS", func() {
+				roots := x509.NewCertPool()
+				roots.AddCert(serverCert)
+				client := &http.Client{
+					Transport: &http.Transport{
+						TLSClientConfig: &tls.Config{RootCAs: roots},
+					},
+				}
+
+				resp, err := client.Get("https://127.0.0.1:9101/test")
+				Expect(err).ShouldNot(HaveOccurred())
+				defer resp.Body.Close()
+
+				body, _ := ioutil.ReadAll(resp.Body)
+				Expect(resp.StatusCode).To(Equal(200))
+				Expect(string(body)).To(Equal("success"))
+				Expect(resp.TLS).ToNot(BeNil())
+			})
+		})
+
+		When("A client CA is configured", func() {
+			var gw gateway.GatewayService
+			var dir string
+			var caCert *x509.Certificate
+			var caKey *ecdsa.PrivateKey
+
+			BeforeEach(func() {
+				dir, _ = ioutil.TempDir("", "nitric-tls")
+
+				var caPEM []byte
+				caCert, caKey, caPEM, _ = generateCert(nil, nil, true)
+				caFile := filepath.Join(dir, "ca.pem")
+				ioutil.WriteFile(caFile, caPEM, 0600)
+
+				os.Setenv("GATEWAY_ADDRESS", "127.0.0.1:9102")
+				gw, _ = base_http.New(nil)
+
+				tlsConfig, err := gateway.NewTlsConfig(&gateway.TlsOptions{
+					CertPEM:      certPEM,
+					KeyPEM:       keyPEM,
+					MinVersion:   "1.3",
+					ClientCAFile: caFile,
+				})
+				Expect(err).ShouldNot(HaveOccurred())
+				gw.(gateway.TlsGateway).SetTlsConfig(tlsConfig)
+
+				go gw.Start(pool)
+				time.Sleep(200 * time.Millisecond)
+			})
+
+			AfterEach(func() {
+				gw.Stop()
+				os.RemoveAll(dir)
+			})
+
+			It("Should only accept clients with a certificate signed by the CA", func() {
+				roots := x509.NewCertPool()
+				roots.AddCert(serverCert)
+
+				By("Rejecting clients without a certificate")
+				client := &http.Client{
+					Transport: &http.Transport{
+						TLSClientConfig: &tls.Config{RootCAs: roots},
+					},
+				}
+				_, err := client.Get("https://127.0.0.1:9102/test")
+				Expect(err).Should(HaveOccurred())
+
+				By("Accepting clients with a signed certificate")
+				_, _, clientCertPEM, clientKeyPEM := generateCert(caCert, caKey, false)
+				clientCert, err := tls.X509KeyPair(clientCertPEM, clientKeyPEM)
+				Expect(err).ShouldNot(HaveOccurred())
+
+				client = &http.Client{
+					Transport: &http.Transport{
+						TLSClientConfig: &tls.Config{
+							RootCAs:      roots,
+							Certificates: []tls.Certificate{clientCert},
+						},
+					},
+				}
+				resp, err := client.Get("https://127.0.0.1:9102/test")
+				Expect(err).ShouldNot(HaveOccurred())
+				resp.Body.Close()
+				Expect(resp.StatusCode).To(Equal(200))
+			})
+		})
+
+		When("An unsupported minimum version is configured", func() {
+			It("Should return an error", func() {
+				_, err := gateway.NewTlsConfig(&gateway.TlsOptions{
+					CertPEM:    certPEM,
+					KeyPEM:     keyPEM,
+					MinVersion: "2.0",
+				})
+				Expect(err).Should(HaveOccurred())
+				Expect(err.Error()).To(Equal("Invalid TLS version 2.0, supported versions are: 1.0, 1.1, 1.2, 1.3"))
+			})
+		})
+	})
+
+	Context("Load shedding", func() {
+		When("Every worker in a non-blocking pool is busy", func() {
+			var gw gateway.GatewayService
+			var busyWorker worker.Worker
+
+			BeforeEach(func() {
+				pool := worker.NewProcessPool(&worker.ProcessPoolOptions{
+					MaxConcurrency: 1,
+				})
+				pool.AddWorker(mock_worker.NewMockWorker(&mock_worker.MockWorkerOptions{
+					ReturnHttp: &triggers.HttpResponse{
+						Body:       []byte("success"),
+						StatusCode: 200,
+					},
+				}))
+
+				// Holds the worker's only concurrency slot
+				var err error
+				busyWorker, err = pool.GetWorker()
+				Expect(err).ShouldNot(HaveOccurred())
+
+				os.Setenv("GATEWAY_ADDRESS", "127.0.0.1:9104")
+				gw, _ = base_http.New(nil)
+
+				go gw.Start(pool)
+				time.Sleep(200 * time.Millisecond)
+			})
+
+			AfterEach(func() {
+				worker.ReleaseWorker(busyWorker)
+				gw.Stop()
+			})
+
+			It("Should ask the client to retry with a 503 response", func() {
+				resp, err := http.Get("http://127.0.0.1:9104/test")
+				Expect(err).ShouldNot(HaveOccurred())
+				defer resp.Body.Close()
+
+				Expect(resp.StatusCode).To(Equal(503))
+				Expect(resp.Header.Get("Retry-After")).To(Equal("1"))
+			})
+		})
+	})
+
+	Context("HTTP/2", func() {
+		When("h2c is enabled", func() {
+			var gw gateway.GatewayService
+			var mockWorker *mock_worker.MockWorker
+
+			BeforeEach(func() {
+				pool := worker.NewProcessPool(&worker.ProcessPoolOptions{})
+				mockWorker = mock_worker.NewMockWorker(&mock_worker.MockWorkerOptions{
+					ReturnHttp: &triggers.HttpResponse{
+						Body:       []byte("success"),
+						StatusCode: 200,
+					},
+				})
+				pool.AddWorker(mockWorker)
+
+				os.Setenv("GATEWAY_ADDRESS", "127.0.0.1:9103")
+				gw, _ = base_http.New(nil)
+				gw.(gateway.H2cGateway).SetH2c(true)
+
+				go gw.Start(pool)
+				time.Sleep(200 * time.Millisecond)
+			})
+
+			AfterEach(func() {
+				gw.Stop()
+			})
+
+			It("Should serve HTTP/2 clients without TLS", func() {
+				// Connect with prior knowledge of HTTP/2, without upgrading from HTTP/1.1
+				client := &http.Client{
+					Transport: &http2.Transport{
+						AllowHTTP: true,
+						DialTLS: func(network string, addr string, cfg *tls.Config) (net.Conn, error) {
+							return net.Dial(network, addr)
+						},
+					},
+				}
+
+				body := bytes.Repeat([]byte("a"), 8*1024*1024)
+				resp, err := client.Post("http://127.0.0.1:9103/test", "text/plain", bytes.NewReader(body))
+				Expect(err).ShouldNot(HaveOccurred())
+				defer resp.Body.Close()
+
+				respBody, _ := ioutil.ReadAll(resp.Body)
+				Expect(resp.ProtoMajor).To(Equal(2))
+				Expect(resp.StatusCode).To(Equal(200))
+				Expect(string(respBody)).To(Equal("success"))
+
+				By("Dispatching the full request body to the worker")
+				Expect(mockWorker.ReceivedRequests).To(HaveLen(1))
+				Expect(mockWorker.ReceivedRequests[0].Method).To(Equal("POST"))
+				Expect(mockWorker.ReceivedRequests[0].Path).To(Equal("/test"))
+				Expect(mockWorker.ReceivedRequests[0].Body).To(HaveLen(len(body)))
+			})
+		})
+	})
+})
@@ -0,0 +1,151 @@
+// Copyright 2021 Nitric Pty Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package base_http
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Route - A path template requests are matched against, e.g. /users/:id or /files/*path
+// A :param segment matches a single path segment and a final *wildcard segment matches the rest of the path
+type Route struct {
+	// The method the route matches, any method if empty
+	Method string
+	// The path template of the route
+	Template string
+	segments []string
+}
+
+// splitPath - Splits a path into its segments, ignoring leading and trailing slashes
+func splitPath(path string) []string {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return []string{}
+	}
+
+	return strings.Split(path, "/")
+}
+
+// ParseRoute - Parses a route template, optionally preceded by the method it matches, e.g. GET /users/:id
+func ParseRoute(route string) (*Route, error) {
+	fields := strings.Fields(route)
+
+	r := &Route{}
+	switch len(fields) {
+	case 1:
+		r.Template = fields[0]
+	case 2:
+		r.Method = strings.ToUpper(fields[0])
+		r.Template = fields[1]
+	default:
+		return nil, fmt.Errorf("invalid route %q, expected [METHOD] /path", route)
+	}
+
+	if !strings.HasPrefix(r.Template, "/") {
+		return nil, fmt.Errorf("invalid route %q, path must start with /", route)
+	}
+
+	r.segments = splitPath(r.Template)
+	names := make(map[string]bool)
+	for i, segment := range r.segments {
+		if !strings.HasPrefix(segment, ":") && !strings.HasPrefix(segment, "*") {
+			continue
+		}
+
+		name := segment[1:]
+		if name == "" {
+			return nil, fmt.Errorf("invalid route %q, parameters must be named", route)
+		}
+
+		if strings.HasPrefix(segment, "*") && i != len(r.segments)-1 {
+			return nil, fmt.Errorf("invalid route %q, wildcards must be the last segment", route)
+		}
+
+		if names[name] {
+			return nil, fmt.Errorf("invalid route %q, parameter %s is repeated", route, name)
+		}
+		names[name] = true
+	}
+
+	return r, nil
+}
+
+// Match - Returns the route parameters of a request if it matches the route
+func (r *Route) Match(method string, path string) (map[string]string, bool) {
+	if r.Method != "" && !strings.EqualFold(r.Method, method) {
+		return nil, false
+	}
+
+	segments := splitPath(path)
+	params := make(map[string]string)
+
+	for i, segment := range r.segments {
+		if strings.HasPrefix(segment, "*") {
+			params[segment[1:]] = strings.Join(segments[i:], "/")
+			return params, true
+		}
+
+		if i >= len(segments) {
+			return nil, false
+		}
+
+		if strings.HasPrefix(segment, ":") {
+			params[segment[1:]] = segments[i]
+		} else if segment != segments[i] {
+			return nil, false
+		}
+	}
+
+	if len(segments) != len(r.segments) {
+		return nil, false
+	}
+
+	return params, true
+}
+
+// Router - Matches requests against routes in the order they're configured
+type Router []*Route
+
+// ParseRoutes - Parses semicolon separated routes, e.g. GET /users/:id;/files/*path
+func ParseRoutes(value string) (Router, error) {
+	router := make(Router, 0)
+
+	for _, entry := range strings.Split(value, ";") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+
+		route, err := ParseRoute(entry)
+		if err != nil {
+			return nil, err
+		}
+
+		router = append(router, route)
+	}
+
+	return router, nil
+}
+
+// Match - Returns the first route matching the request, along with its parameters
+func (r Router) Match(method string, path string) (*Route, map[string]string, bool) {
+	for _, route := range r {
+		if params, ok := route.Match(method, path); ok {
+			return route, params, true
+		}
+	}
+
+	return nil, nil, false
+}
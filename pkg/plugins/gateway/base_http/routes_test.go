@@ -0,0 +1,113 @@
+// Copyright 2021 Nitric Pty Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package base_http_test
+
+import (
+	"github.com/nitrictech/nitric/pkg/plugins/gateway/base_http"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Routes", func() {
+	Context("ParseRoutes", func() {
+		When("Parsing valid routes", func() {
+			router, err := base_http.ParseRoutes("GET /users/:id; /files/*path;")
+
+			It("should parse each route", func() {
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(router).To(HaveLen(2))
+				Expect(router[0].Method).To(Equal("GET"))
+				Expect(router[0].Template).To(Equal("/users/:id"))
+				Expect(router[1].Method).To(Equal(""))
+				Expect(router[1].Template).To(Equal("/files/*path"))
+			})
+		})
+
+		When("A wildcard isn't the last segment", func() {
+			_, err := base_http.ParseRoutes("/files/*path/meta")
+
+			It("should return an error", func() {
+				Expect(err).Should(HaveOccurred())
+			})
+		})
+
+		When("A parameter is unnamed", func() {
+			_, err := base_http.ParseRoutes("/users/:")
+
+			It("should return an error", func() {
+				Expect(err).Should(HaveOccurred())
+			})
+		})
+
+		When("A path doesn't start with /", func() {
+			_, err := base_http.ParseRoutes("users/:id")
+
+			It("should return an error", func() {
+				Expect(err).Should(HaveOccurred())
+			})
+		})
+	})
+
+	Context("Router.Match", func() {
+		router, _ := base_http.ParseRoutes("GET /users/:id;/users/:id/posts/:post;/files/*path")
+
+		When("Matching a parameterized route", func() {
+			route, params, ok := router.Match("GET", "/users/123/")
+
+			It("should return the route and its parameters", func() {
+				Expect(ok).To(BeTrue())
+				Expect(route.Template).To(Equal("/users/:id"))
+				Expect(params).To(Equal(map[string]string{"id": "123"}))
+			})
+		})
+
+		When("Matching a route with multiple parameters", func() {
+			route, params, ok := router.Match("POST", "/users/123/posts/456")
+
+			It("should return every parameter", func() {
+				Expect(ok).To(BeTrue())
+				Expect(route.Template).To(Equal("/users/:id/posts/:post"))
+				Expect(params).To(Equal(map[string]string{"id": "123", "post": "456"}))
+			})
+		})
+
+		When("Matching a wildcard route", func() {
+			route, params, ok := router.Match("GET", "/files/images/logo.png")
+
+			It("should capture the rest of the path", func() {
+				Expect(ok).To(BeTrue())
+				Expect(route.Template).To(Equal("/files/*path"))
+				Expect(params).To(Equal(map[string]string{"path": "images/logo.png"}))
+			})
+		})
+
+		When("The method doesn't match", func() {
+			_, _, ok := router.Match("DELETE", "/users/123")
+
+			It("should not match", func() {
+				Expect(ok).To(BeFalse())
+			})
+		})
+
+		When("The path has extra segments", func() {
+			_, _, ok := router.Match("GET", "/users/123/comments")
+
+			It("should not match", func() {
+				Expect(ok).To(BeFalse())
+			})
+		})
+	})
+})
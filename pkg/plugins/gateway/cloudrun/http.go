@@ -16,12 +16,14 @@
 package cloudrun_plugin
 
 import (
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 
 	"github.com/nitrictech/nitric/pkg/triggers"
 	"github.com/nitrictech/nitric/pkg/worker"
 
+	"github.com/nitrictech/nitric/pkg/plugins/events"
 	"github.com/nitrictech/nitric/pkg/plugins/gateway"
 	"github.com/nitrictech/nitric/pkg/plugins/gateway/base_http"
 	"github.com/valyala/fasthttp"
@@ -36,7 +38,42 @@ type PubSubMessage struct {
 	Subscription string `json:"subscription"`
 }
 
+// The header Cloud Tasks sets on tasks it pushes to the service
+const cloudTasksQueueHeader = "X-CloudTasks-QueueName"
+
+// handleCloudTask - Dispatches a task pushed by Cloud Tasks to the worker as an event on the queue
+// a non-successful response causes Cloud Tasks to retry the task
+func handleCloudTask(ctx *fasthttp.RequestCtx, wrkr worker.Worker, queueName string) {
+	bodyBytes := ctx.Request.Body()
+
+	// Prefer the nitric task ID, falling back to the Cloud Tasks task name
+	var task struct {
+		ID string `json:"id"`
+	}
+	taskID := string(ctx.Request.Header.Peek("X-CloudTasks-TaskName"))
+	if err := json.Unmarshal(bodyBytes, &task); err == nil && task.ID != "" {
+		taskID = task.ID
+	}
+
+	event := &triggers.Event{
+		ID:      taskID,
+		Topic:   queueName,
+		Payload: bodyBytes,
+	}
+
+	if err := wrkr.HandleEvent(event); err == nil {
+		ctx.SuccessString("text/plain", "success")
+	} else {
+		ctx.Error(fmt.Sprintf("Error handling task %v", err), 500)
+	}
+}
+
 func middleware(ctx *fasthttp.RequestCtx, wrkr worker.Worker) bool {
+	if queueName := ctx.Request.Header.Peek(cloudTasksQueueHeader); len(queueName) > 0 {
+		handleCloudTask(ctx, wrkr, string(queueName))
+		return false
+	}
+
 	bodyBytes := ctx.Request.Body()
 
 	// Check if the payload contains a pubsub event
@@ -45,12 +82,29 @@ func middleware(ctx *fasthttp.RequestCtx, wrkr worker.Worker) bool {
 	var pubsubEvent PubSubMessage
 	if err := json.Unmarshal(bodyBytes, &pubsubEvent); err == nil && pubsubEvent.Subscription != "" {
 		// We have an event from pubsub here...
+		// Prefer the nitric event ID, so redelivered events can be deduped
+		eventID := pubsubEvent.Message.Attributes["x-nitric-event-id"]
+		if eventID == "" {
+			eventID = pubsubEvent.Message.ID
+		}
+
+		payload := pubsubEvent.Message.Data
+		// Restore binary payloads that were published as base64 text
+		if pubsubEvent.Message.Attributes[events.PayloadEncodingAttribute] == events.PayloadEncoding_Base64.String() {
+			decoded, err := base64.StdEncoding.DecodeString(string(payload))
+			if err != nil {
+				ctx.Error(fmt.Sprintf("Error decoding event payload %v", err), 400)
+				return false
+			}
+			payload = decoded
+		}
+
 		event := &triggers.Event{
-			ID: pubsubEvent.Message.ID,
+			ID: eventID,
 			// Set the topic
 			Topic: pubsubEvent.Message.Attributes["x-nitric-topic"],
 			// Set the payload
-			Payload: pubsubEvent.Message.Data,
+			Payload: payload,
 		}
 
 		if err := wrkr.HandleEvent(event); err == nil {
@@ -167,5 +167,42 @@ var _ = Describe("Http", func() {
 				Expect(string(responseBody)).To(Equal("success"))
 			})
 		})
+
+		When("From a Cloud Tasks queue", func() {
+			taskBytes, _ := json.Marshal(map[string]interface{}{
+				"id": "1234",
+				"payload": map[string]interface{}{
+					"Test": "Test",
+				},
+			})
+
+			It("Should handle the task as an event on the queue", func() {
+				request, err := http.NewRequest("POST", gatewayUrl, bytes.NewReader(taskBytes))
+				request.Header.Add("Content-Type", "application/json")
+				request.Header.Add("X-CloudTasks-QueueName", "test-queue")
+				request.Header.Add("X-CloudTasks-TaskName", "task-name")
+				resp, err := http.DefaultClient.Do(request)
+
+				By("Not returning an error")
+				Expect(err).To(BeNil())
+
+				By("Handling exactly 1 event")
+				Expect(mockHandler.ReceivedEvents).To(HaveLen(1))
+
+				handledEvent := mockHandler.ReceivedEvents[0]
+
+				By("Using the nitric task ID")
+				Expect(handledEvent.ID).To(Equal("1234"))
+
+				By("Using the queue name as the topic")
+				Expect(handledEvent.Topic).To(Equal("test-queue"))
+
+				By("Passing through the task")
+				Expect(handledEvent.Payload).To(BeEquivalentTo(taskBytes))
+
+				By("The request returns a successful status")
+				Expect(resp.StatusCode).To(Equal(200))
+			})
+		})
 	})
 })
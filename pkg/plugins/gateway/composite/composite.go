@@ -0,0 +1,188 @@
+// Copyright 2021 Nitric Pty Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// The composite gateway plugin, serving triggers from multiple gateways at once
+package composite_gateway
+
+import (
+	"crypto/tls"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nitrictech/nitric/pkg/plugins/gateway"
+	"github.com/nitrictech/nitric/pkg/worker"
+)
+
+// stopRetryInterval - How often children still running after being stopped are stopped again,
+// a child stopped before it began serving may otherwise miss the stop
+const stopRetryInterval = 100 * time.Millisecond
+
+type CompositeGateway struct {
+	gateways []gateway.GatewayService
+
+	lock sync.Mutex
+	// Set once the composite gateway has been stopped, or is tearing down after a child failed
+	stopping bool
+	// The children that haven't returned from Start
+	running map[int]bool
+}
+
+// childResult - The result of a child gateway's Start
+type childResult struct {
+	index int
+	err   error
+}
+
+// aggregateErrors - Combines the errors of the child gateways, nil if there are none
+func aggregateErrors(errs []error) error {
+	messages := make([]string, 0, len(errs))
+	for _, err := range errs {
+		if err != nil {
+			messages = append(messages, err.Error())
+		}
+	}
+
+	if len(messages) == 0 {
+		return nil
+	}
+
+	return fmt.Errorf("%d of %d gateways failed: %s", len(messages), len(errs), strings.Join(messages, "; "))
+}
+
+// Start - Starts every child gateway concurrently, returning once all of them have returned.
+// If a child fails the others are stopped and the errors of every child are returned
+func (g *CompositeGateway) Start(pool worker.WorkerPool) error {
+	g.lock.Lock()
+	if g.running != nil {
+		g.lock.Unlock()
+		return fmt.Errorf("composite gateway already started")
+	}
+	if g.stopping {
+		g.lock.Unlock()
+		return nil
+	}
+	g.running = make(map[int]bool)
+	for i := range g.gateways {
+		g.running[i] = true
+	}
+	g.lock.Unlock()
+
+	results := make(chan childResult, len(g.gateways))
+	for i, gw := range g.gateways {
+		go func(i int, gw gateway.GatewayService) {
+			results <- childResult{index: i, err: gw.Start(pool)}
+		}(i, gw)
+	}
+
+	errs := make([]error, len(g.gateways))
+	ticker := time.NewTicker(stopRetryInterval)
+	defer ticker.Stop()
+
+	for remaining := len(g.gateways); remaining > 0; {
+		select {
+		case result := <-results:
+			remaining--
+			errs[result.index] = result.err
+
+			g.lock.Lock()
+			delete(g.running, result.index)
+			g.lock.Unlock()
+
+			// A failed child tears down the rest, so the membrane doesn't run with a partial set of trigger sources
+			if result.err != nil {
+				g.teardown()
+			}
+		case <-ticker.C:
+			g.restopRunning()
+		}
+	}
+
+	return aggregateErrors(errs)
+}
+
+// teardown - Stops every running child, once
+func (g *CompositeGateway) teardown() {
+	g.lock.Lock()
+	if g.stopping {
+		g.lock.Unlock()
+		return
+	}
+	g.stopping = true
+	g.lock.Unlock()
+
+	g.stopChildren()
+}
+
+// stopChildren - Stops the children that are still running, returning their errors
+func (g *CompositeGateway) stopChildren() []error {
+	g.lock.Lock()
+	running := make([]int, 0, len(g.running))
+	for i := range g.running {
+		running = append(running, i)
+	}
+	g.lock.Unlock()
+
+	errs := make([]error, 0, len(running))
+	for _, i := range running {
+		errs = append(errs, g.gateways[i].Stop())
+	}
+	return errs
+}
+
+// restopRunning - Stops children again if they're still running after the composite gateway was stopped
+func (g *CompositeGateway) restopRunning() {
+	g.lock.Lock()
+	stopping := g.stopping
+	g.lock.Unlock()
+
+	if stopping {
+		g.stopChildren()
+	}
+}
+
+// Stop - Stops every child gateway, returning their errors
+func (g *CompositeGateway) Stop() error {
+	g.lock.Lock()
+	if g.stopping {
+		g.lock.Unlock()
+		return nil
+	}
+	g.stopping = true
+	started := g.running != nil
+	g.lock.Unlock()
+
+	if !started {
+		return nil
+	}
+
+	return aggregateErrors(g.stopChildren())
+}
+
+// SetTlsConfig - Serves the children that support TLS using the given config
+func (g *CompositeGateway) SetTlsConfig(config *tls.Config) {
+	for _, gw := range g.gateways {
+		if tlsGateway, ok := gw.(gateway.TlsGateway); ok {
+			tlsGateway.SetTlsConfig(config)
+		}
+	}
+}
+
+// New - Creates a gateway serving the triggers of each of the given gateways
+func New(gateways ...gateway.GatewayService) gateway.GatewayService {
+	return &CompositeGateway{
+		gateways: gateways,
+	}
+}
@@ -0,0 +1,161 @@
+// Copyright 2021 Nitric Pty Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package composite_gateway_test
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	composite_gateway "github.com/nitrictech/nitric/pkg/plugins/gateway/composite"
+	"github.com/nitrictech/nitric/pkg/worker"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// mockGateway - Serves until stopped, or fails with startErr once started
+type mockGateway struct {
+	startErr error
+	started  chan bool
+	stop     chan bool
+	stopOnce sync.Once
+}
+
+func newMockGateway(startErr error) *mockGateway {
+	return &mockGateway{
+		startErr: startErr,
+		started:  make(chan bool, 1),
+		stop:     make(chan bool),
+	}
+}
+
+func (m *mockGateway) Start(pool worker.WorkerPool) error {
+	m.started <- true
+	if m.startErr != nil {
+		return m.startErr
+	}
+
+	<-m.stop
+	return nil
+}
+
+func (m *mockGateway) Stop() error {
+	m.stopOnce.Do(func() {
+		close(m.stop)
+	})
+	return nil
+}
+
+// isStopped - Returns true once the gateway has been stopped
+func (m *mockGateway) isStopped() bool {
+	select {
+	case <-m.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+var _ = Describe("Composite Gateway", func() {
+	pool := worker.NewProcessPool(&worker.ProcessPoolOptions{})
+
+	When("The gateway is stopped", func() {
+		It("Should start and stop every child", func() {
+			first, second := newMockGateway(nil), newMockGateway(nil)
+			gw := composite_gateway.New(first, second)
+
+			errch := make(chan error)
+			go func() {
+				errch <- gw.Start(pool)
+			}()
+
+			Eventually(first.started).Should(Receive())
+			Eventually(second.started).Should(Receive())
+
+			Expect(gw.Stop()).To(Succeed())
+
+			var err error
+			Eventually(errch).Should(Receive(&err))
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(first.isStopped()).To(BeTrue())
+			Expect(second.isStopped()).To(BeTrue())
+		})
+	})
+
+	When("A child fails to start", func() {
+		It("Should stop the other children and return the error", func() {
+			healthy, failing := newMockGateway(nil), newMockGateway(fmt.Errorf("address in use"))
+			gw := composite_gateway.New(healthy, failing)
+
+			errch := make(chan error)
+			go func() {
+				errch <- gw.Start(pool)
+			}()
+
+			var err error
+			Eventually(errch).Should(Receive(&err))
+			Expect(err).Should(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("1 of 2 gateways failed: address in use"))
+			Expect(healthy.isStopped()).To(BeTrue())
+		})
+	})
+
+	When("A child is stopped before it starts serving", func() {
+		It("Should stop it again once it's serving", func() {
+			slow := &slowGateway{delay: 150 * time.Millisecond, mockGateway: newMockGateway(nil)}
+			failing := newMockGateway(fmt.Errorf("mock error"))
+			gw := composite_gateway.New(slow, failing)
+
+			errch := make(chan error)
+			go func() {
+				errch <- gw.Start(pool)
+			}()
+
+			var err error
+			Eventually(errch, "2s").Should(Receive(&err))
+			Expect(err).Should(HaveOccurred())
+		})
+	})
+})
+
+// slowGateway - A gateway that ignores stops received before it begins serving after its delay
+type slowGateway struct {
+	*mockGateway
+	delay   time.Duration
+	lock    sync.Mutex
+	serving bool
+}
+
+func (s *slowGateway) Start(pool worker.WorkerPool) error {
+	time.Sleep(s.delay)
+
+	s.lock.Lock()
+	s.serving = true
+	s.lock.Unlock()
+
+	return s.mockGateway.Start(pool)
+}
+
+func (s *slowGateway) Stop() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if !s.serving {
+		return nil
+	}
+
+	return s.mockGateway.Stop()
+}
@@ -16,14 +16,20 @@
 package gateway_plugin
 
 import (
+	"encoding/base64"
 	"fmt"
 	"strings"
 
 	"github.com/nitrictech/nitric/pkg/triggers"
+	"github.com/nitrictech/nitric/pkg/utils"
 	"github.com/nitrictech/nitric/pkg/worker"
 
+	"github.com/nitrictech/nitric/pkg/plugins/events"
 	"github.com/nitrictech/nitric/pkg/plugins/gateway"
 	"github.com/nitrictech/nitric/pkg/plugins/gateway/base_http"
+	composite_gateway "github.com/nitrictech/nitric/pkg/plugins/gateway/composite"
+	loadtest_gateway "github.com/nitrictech/nitric/pkg/plugins/gateway/loadtest"
+	schedule_gateway "github.com/nitrictech/nitric/pkg/plugins/gateway/schedule"
 	"github.com/valyala/fasthttp"
 )
 
@@ -36,6 +42,16 @@ func middleware(ctx *fasthttp.RequestCtx, wrkr worker.Worker) bool {
 		requestId := string(ctx.Request.Header.Peek("x-nitric-request-id"))
 		payload := ctx.Request.Body()
 
+		// Restore binary payloads that were published as base64 text
+		if string(ctx.Request.Header.Peek(events.PayloadEncodingAttribute)) == events.PayloadEncoding_Base64.String() {
+			decoded, err := base64.StdEncoding.DecodeString(string(payload))
+			if err != nil {
+				ctx.Error(fmt.Sprintf("Error decoding event payload. Details: %s", err), 400)
+				return false
+			}
+			payload = decoded
+		}
+
 		err := wrkr.HandleEvent(&triggers.Event{
 			ID:      requestId,
 			Topic:   trigger,
@@ -56,8 +72,27 @@ func middleware(ctx *fasthttp.RequestCtx, wrkr worker.Worker) bool {
 	return true
 }
 
-// Create new HTTP gateway
-// XXX: No External Args for function atm (currently the plugin loader does not pass any argument information)
+// New - Creates the dev gateway, serving HTTP triggers and firing any configured schedules alongside.
+// When GATEWAY_ENVIRONMENT is loadtest, synthetic load is fired at the workers instead
 func New() (gateway.GatewayService, error) {
-	return base_http.New(middleware)
+	if utils.GetEnv("GATEWAY_ENVIRONMENT", "") == "loadtest" {
+		return loadtest_gateway.New()
+	}
+
+	router, err := base_http.ParseRoutes(utils.GetEnv("ROUTES", ""))
+	if err != nil {
+		return nil, fmt.Errorf("invalid ROUTES env var: %v", err)
+	}
+
+	httpGateway, err := base_http.NewWithRouter(middleware, router)
+	if err != nil {
+		return nil, err
+	}
+
+	scheduler, err := schedule_gateway.New()
+	if err != nil {
+		return nil, err
+	}
+
+	return composite_gateway.New(httpGateway, scheduler), nil
 }
@@ -0,0 +1,46 @@
+// Copyright 2021 Nitric Pty Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gatewaytest
+
+type RecordingGatewayOption interface {
+	Apply(*RecordingGateway)
+}
+
+type withServeUntilStopped struct{}
+
+func (w *withServeUntilStopped) Apply(g *RecordingGateway) {
+	g.serveUntilStopped = true
+}
+
+// WithServeUntilStopped - Keeps Start running after the pre-loaded triggers are dispatched until the gateway is stopped,
+// as a real gateway would. By default Start returns once every pre-loaded trigger has been handled
+func WithServeUntilStopped() RecordingGatewayOption {
+	return &withServeUntilStopped{}
+}
+
+type withConcurrency struct {
+	concurrency int
+}
+
+func (w *withConcurrency) Apply(g *RecordingGateway) {
+	g.concurrency = w.concurrency
+}
+
+// WithConcurrency - The maximum number of pre-loaded triggers dispatched at once, 0 dispatches them all at once
+func WithConcurrency(concurrency int) RecordingGatewayOption {
+	return &withConcurrency{
+		concurrency: concurrency,
+	}
+}
@@ -0,0 +1,261 @@
+// Copyright 2021 Nitric Pty Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Helpers for testing gateways and the membrane with gateways
+package gatewaytest
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/nitrictech/nitric/pkg/plugins/gateway"
+	"github.com/nitrictech/nitric/pkg/triggers"
+	"github.com/nitrictech/nitric/pkg/worker"
+)
+
+// Record - A trigger dispatched by the gateway and its outcome
+type Record struct {
+	Trigger triggers.Trigger
+	// The worker's response to a HTTP request, nil for other triggers or if the worker failed
+	Response *triggers.HttpResponse
+	// The error returned by the worker or the pool
+	Err error
+}
+
+// HttpResponse - The response a gateway would send for the record, failed HTTP requests respond with a 500.
+// Nil for triggers that aren't HTTP requests
+func (r *Record) HttpResponse() *triggers.HttpResponse {
+	if _, ok := r.Trigger.(*triggers.HttpRequest); !ok {
+		return nil
+	}
+
+	if r.Err != nil {
+		return &triggers.HttpResponse{
+			StatusCode: 500,
+			Body:       []byte(r.Err.Error()),
+		}
+	}
+
+	return r.Response
+}
+
+// RecordingGateway - A gateway that dispatches pre-loaded triggers to the pool when started, and triggers from Dispatch
+// once started, recording each outcome. Triggers may be dispatched concurrently, so it's safe for use with real pools
+type RecordingGateway struct {
+	lock     sync.Mutex
+	pool     worker.WorkerPool
+	triggers []triggers.Trigger
+	// Records of pre-loaded triggers are in the order they were loaded, followed by records of dispatched triggers
+	records []*Record
+
+	serveUntilStopped bool
+	concurrency       int
+
+	started    chan struct{}
+	dispatched chan struct{}
+	stopped    chan struct{}
+	stopOnce   sync.Once
+}
+
+var _ gateway.GatewayService = (*RecordingGateway)(nil)
+
+// handle - Dispatches a trigger to a worker from the pool
+func handle(pool worker.WorkerPool, trigger triggers.Trigger) *Record {
+	record := &Record{
+		Trigger: trigger,
+	}
+
+	wrkr, err := pool.GetWorker()
+	if err != nil {
+		record.Err = fmt.Errorf("unable to get worker to handle trigger: %v", err)
+		return record
+	}
+	defer worker.ReleaseWorker(wrkr)
+
+	switch t := trigger.(type) {
+	case *triggers.HttpRequest:
+		record.Response, record.Err = wrkr.HandleHttpRequest(t)
+	case *triggers.Event:
+		record.Err = wrkr.HandleEvent(t)
+	case *triggers.WebsocketMessage:
+		record.Err = wrkr.HandleWebsocketMessage(t)
+	case *triggers.BucketNotification:
+		record.Err = wrkr.HandleBucketNotification(t)
+	case *triggers.Schedule:
+		record.Err = wrkr.HandleSchedule(t)
+	default:
+		record.Err = fmt.Errorf("unsupported trigger type %s", trigger.GetTriggerType())
+	}
+
+	return record
+}
+
+// Add - Pre-loads triggers, dispatched when the gateway is started. Triggers added once started aren't dispatched
+func (g *RecordingGateway) Add(trigs ...triggers.Trigger) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	g.triggers = append(g.triggers, trigs...)
+}
+
+// dispatchLoaded - Dispatches the pre-loaded triggers, recording each in the position it was loaded
+func (g *RecordingGateway) dispatchLoaded(pool worker.WorkerPool, loaded []triggers.Trigger) {
+	concurrency := g.concurrency
+	if concurrency < 1 || concurrency > len(loaded) {
+		concurrency = len(loaded)
+	}
+
+	records := make([]*Record, len(loaded))
+	sem := make(chan struct{}, concurrency)
+	wg := sync.WaitGroup{}
+
+	for i, trigger := range loaded {
+		sem <- struct{}{}
+		wg.Add(1)
+
+		go func(i int, trigger triggers.Trigger) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			records[i] = handle(pool, trigger)
+		}(i, trigger)
+	}
+
+	wg.Wait()
+
+	g.lock.Lock()
+	g.records = append(records, g.records...)
+	g.lock.Unlock()
+}
+
+// Start - Dispatches the pre-loaded triggers to the pool, returning once they've been handled
+// unless the gateway serves until stopped
+func (g *RecordingGateway) Start(pool worker.WorkerPool) error {
+	g.lock.Lock()
+	select {
+	case <-g.started:
+		g.lock.Unlock()
+		return fmt.Errorf("gateway has already been started")
+	default:
+	}
+
+	g.pool = pool
+	loaded := append([]triggers.Trigger{}, g.triggers...)
+	close(g.started)
+	g.lock.Unlock()
+
+	g.dispatchLoaded(pool, loaded)
+	close(g.dispatched)
+
+	if g.serveUntilStopped {
+		<-g.stopped
+	}
+
+	return nil
+}
+
+// Stop - Stops the gateway, triggers can no longer be dispatched
+func (g *RecordingGateway) Stop() error {
+	g.stopOnce.Do(func() {
+		close(g.stopped)
+	})
+
+	return nil
+}
+
+// Dispatch - Dispatches a trigger to a worker once the gateway has started, recording and returning the outcome
+func (g *RecordingGateway) Dispatch(trigger triggers.Trigger) (*Record, error) {
+	select {
+	case <-g.stopped:
+		return nil, fmt.Errorf("gateway has been stopped")
+	default:
+	}
+
+	select {
+	case <-g.started:
+	default:
+		return nil, fmt.Errorf("gateway has not been started")
+	}
+
+	g.lock.Lock()
+	pool := g.pool
+	g.lock.Unlock()
+
+	record := handle(pool, trigger)
+
+	g.lock.Lock()
+	g.records = append(g.records, record)
+	g.lock.Unlock()
+
+	return record, nil
+}
+
+// Started - Returns true once the gateway has been started
+func (g *RecordingGateway) Started() bool {
+	select {
+	case <-g.started:
+		return true
+	default:
+		return false
+	}
+}
+
+// Dispatched - Closed once every pre-loaded trigger has been handled
+func (g *RecordingGateway) Dispatched() <-chan struct{} {
+	return g.dispatched
+}
+
+// Records - Returns a copy of the records of the triggers handled so far
+func (g *RecordingGateway) Records() []Record {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	records := make([]Record, len(g.records))
+	for i, r := range g.records {
+		records[i] = *r
+	}
+
+	return records
+}
+
+// Responses - Returns the responses the gateway would send for the HTTP requests handled so far, in record order
+func (g *RecordingGateway) Responses() []*triggers.HttpResponse {
+	responses := make([]*triggers.HttpResponse, 0)
+	for _, r := range g.Records() {
+		if resp := r.HttpResponse(); resp != nil {
+			responses = append(responses, resp)
+		}
+	}
+
+	return responses
+}
+
+// New - Creates a recording gateway, dispatching the pre-loaded triggers when started
+func New(trigs []triggers.Trigger, opts ...RecordingGatewayOption) *RecordingGateway {
+	g := &RecordingGateway{
+		triggers:   append([]triggers.Trigger{}, trigs...),
+		records:    make([]*Record, 0),
+		started:    make(chan struct{}),
+		dispatched: make(chan struct{}),
+		stopped:    make(chan struct{}),
+	}
+
+	for _, o := range opts {
+		o.Apply(g)
+	}
+
+	return g
+}
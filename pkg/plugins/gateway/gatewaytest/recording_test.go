@@ -0,0 +1,148 @@
+// Copyright 2021 Nitric Pty Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gatewaytest_test
+
+import (
+	"fmt"
+	"sync/atomic"
+
+	"github.com/nitrictech/nitric/pkg/plugins/gateway/gatewaytest"
+	"github.com/nitrictech/nitric/pkg/triggers"
+	"github.com/nitrictech/nitric/pkg/worker"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// pathWorker - Responds to HTTP requests with their path and fails events
+type pathWorker struct {
+	worker.UnimplementedWorker
+	handled int64
+}
+
+func (w *pathWorker) HandleHttpRequest(trigger *triggers.HttpRequest) (*triggers.HttpResponse, error) {
+	atomic.AddInt64(&w.handled, 1)
+
+	return &triggers.HttpResponse{
+		StatusCode: 200,
+		Body:       []byte(trigger.Path),
+	}, nil
+}
+
+func (w *pathWorker) HandleEvent(trigger *triggers.Event) error {
+	atomic.AddInt64(&w.handled, 1)
+
+	return fmt.Errorf("event %s failed", trigger.ID)
+}
+
+func requests(count int) []triggers.Trigger {
+	trigs := make([]triggers.Trigger, count)
+	for i := range trigs {
+		trigs[i] = &triggers.HttpRequest{
+			Method: "GET",
+			Path:   fmt.Sprintf("/%d", i),
+		}
+	}
+
+	return trigs
+}
+
+var _ = Describe("RecordingGateway", func() {
+	var wrkr *pathWorker
+	var pool worker.WorkerPool
+
+	BeforeEach(func() {
+		wrkr = &pathWorker{}
+		pool = worker.NewProcessPool(&worker.ProcessPoolOptions{})
+		Expect(pool.AddWorker(wrkr)).To(Succeed())
+	})
+
+	When("Started with pre-loaded triggers", func() {
+		It("Should record every trigger in the order it was loaded", func() {
+			gw := gatewaytest.New(requests(50))
+
+			Expect(gw.Start(pool)).To(Succeed())
+			Expect(gw.Started()).To(BeTrue())
+			Expect(atomic.LoadInt64(&wrkr.handled)).To(Equal(int64(50)))
+
+			responses := gw.Responses()
+			Expect(responses).To(HaveLen(50))
+			for i, resp := range responses {
+				Expect(string(resp.Body)).To(Equal(fmt.Sprintf("/%d", i)))
+			}
+		})
+
+		It("Should record worker errors, responding to failed HTTP requests with a 500", func() {
+			gw := gatewaytest.New([]triggers.Trigger{
+				&triggers.Event{ID: "1234", Topic: "test"},
+			})
+			gw.Add(&triggers.HttpRequest{Method: "GET", Path: "/"})
+
+			emptyPool := worker.NewProcessPool(&worker.ProcessPoolOptions{})
+			Expect(gw.Start(emptyPool)).To(Succeed())
+
+			records := gw.Records()
+			Expect(records).To(HaveLen(2))
+			Expect(records[0].Err).Should(HaveOccurred())
+			Expect(records[0].HttpResponse()).To(BeNil())
+			Expect(records[1].HttpResponse().StatusCode).To(Equal(500))
+		})
+	})
+
+	When("Serving until stopped", func() {
+		It("Should record triggers dispatched concurrently", func() {
+			gw := gatewaytest.New(requests(10), gatewaytest.WithServeUntilStopped(), gatewaytest.WithConcurrency(2))
+
+			errch := make(chan error)
+			go func() {
+				errch <- gw.Start(pool)
+			}()
+
+			Eventually(gw.Dispatched()).Should(BeClosed())
+
+			done := make(chan bool)
+			for i := 0; i < 10; i++ {
+				go func() {
+					defer GinkgoRecover()
+
+					_, err := gw.Dispatch(&triggers.Event{ID: "1234", Topic: "test"})
+					Expect(err).ShouldNot(HaveOccurred())
+					done <- true
+				}()
+			}
+
+			for i := 0; i < 10; i++ {
+				Eventually(done).Should(Receive())
+			}
+
+			Expect(gw.Records()).To(HaveLen(20))
+			Expect(gw.Responses()).To(HaveLen(10))
+
+			Expect(gw.Stop()).To(Succeed())
+			Eventually(errch).Should(Receive(BeNil()))
+
+			_, err := gw.Dispatch(&triggers.Event{ID: "1234", Topic: "test"})
+			Expect(err).Should(HaveOccurred())
+		})
+	})
+
+	When("Dispatching before the gateway has started", func() {
+		It("Should return an error", func() {
+			gw := gatewaytest.New(nil)
+
+			_, err := gw.Dispatch(&triggers.HttpRequest{Method: "GET", Path: "/"})
+			Expect(err).Should(HaveOccurred())
+		})
+	})
+})
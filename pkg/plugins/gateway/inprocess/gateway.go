@@ -0,0 +1,120 @@
+// Copyright 2021 Nitric Pty Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// An in-process gateway plugin, for embedding the membrane and testing without a network listener
+package inprocess_gateway
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/nitrictech/nitric/pkg/plugins/gateway"
+	"github.com/nitrictech/nitric/pkg/triggers"
+	"github.com/nitrictech/nitric/pkg/worker"
+)
+
+// InProcessGateway - A gateway that receives triggers from method calls rather than a network listener
+type InProcessGateway struct {
+	gateway.UnimplementedGatewayPlugin
+	pool     worker.WorkerPool
+	lock     sync.RWMutex
+	started  chan struct{}
+	stopped  chan struct{}
+	stopOnce sync.Once
+}
+
+var _ gateway.GatewayService = (*InProcessGateway)(nil)
+
+// getWorker - Waits for the gateway to start then returns a worker from its pool
+func (s *InProcessGateway) getWorker() (worker.Worker, error) {
+	select {
+	case <-s.started:
+	case <-s.stopped:
+		return nil, fmt.Errorf("gateway has been stopped")
+	}
+
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	select {
+	case <-s.stopped:
+		return nil, fmt.Errorf("gateway has been stopped")
+	default:
+	}
+
+	wrkr, err := s.pool.GetWorker()
+	if err != nil {
+		return nil, fmt.Errorf("unable to get worker to handle trigger: %v", err)
+	}
+
+	return wrkr, nil
+}
+
+// SubmitHttp - Dispatches a HTTP request to a worker and returns its response,
+// blocking until the gateway is started if it hasn't been yet
+func (s *InProcessGateway) SubmitHttp(req *triggers.HttpRequest) (*triggers.HttpResponse, error) {
+	wrkr, err := s.getWorker()
+	if err != nil {
+		return nil, err
+	}
+	// Pool decorators may answer the request without dispatching it to the worker
+	defer worker.ReleaseWorker(wrkr)
+
+	return wrkr.HandleHttpRequest(req)
+}
+
+// SubmitEvent - Dispatches an event to a worker,
+// blocking until the gateway is started if it hasn't been yet
+func (s *InProcessGateway) SubmitEvent(evt *triggers.Event) error {
+	wrkr, err := s.getWorker()
+	if err != nil {
+		return err
+	}
+	defer worker.ReleaseWorker(wrkr)
+
+	return wrkr.HandleEvent(evt)
+}
+
+// Start - Accepts submitted triggers until the gateway is stopped
+func (s *InProcessGateway) Start(pool worker.WorkerPool) error {
+	s.lock.Lock()
+	select {
+	case <-s.started:
+		s.lock.Unlock()
+		return fmt.Errorf("gateway has already been started")
+	default:
+	}
+	s.pool = pool
+	close(s.started)
+	s.lock.Unlock()
+
+	<-s.stopped
+	return nil
+}
+
+// Stop - Stops accepting triggers, triggers already dispatched are unaffected
+func (s *InProcessGateway) Stop() error {
+	s.stopOnce.Do(func() {
+		close(s.stopped)
+	})
+	return nil
+}
+
+// New - Creates a new in-process gateway, which is returned as its concrete type so triggers can be submitted to it
+func New() (*InProcessGateway, error) {
+	return &InProcessGateway{
+		started: make(chan struct{}),
+		stopped: make(chan struct{}),
+	}, nil
+}
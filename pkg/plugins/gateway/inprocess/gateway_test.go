@@ -0,0 +1,145 @@
+// Copyright 2021 Nitric Pty Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package inprocess_gateway_test
+
+import (
+	inprocess_gateway "github.com/nitrictech/nitric/pkg/plugins/gateway/inprocess"
+	"github.com/nitrictech/nitric/pkg/triggers"
+	"github.com/nitrictech/nitric/pkg/worker"
+	mock_worker "github.com/nitrictech/nitric/tests/mocks/worker"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("InProcessGateway", func() {
+	var gateway *inprocess_gateway.InProcessGateway
+	var mockHandler *mock_worker.MockWorker
+	var startErr chan error
+
+	BeforeEach(func() {
+		pool := worker.NewProcessPool(&worker.ProcessPoolOptions{})
+		mockHandler = mock_worker.NewMockWorker(&mock_worker.MockWorkerOptions{
+			ReturnHttp: &triggers.HttpResponse{
+				Body:       []byte("success"),
+				StatusCode: 200,
+			},
+		})
+		pool.AddWorker(mockHandler)
+
+		gateway, _ = inprocess_gateway.New()
+		startErr = make(chan error, 1)
+		go func() {
+			startErr <- gateway.Start(pool)
+		}()
+	})
+
+	AfterEach(func() {
+		gateway.Stop()
+	})
+
+	Context("SubmitHttp", func() {
+		When("The gateway is started", func() {
+			It("Should dispatch the request to a worker and return its response", func() {
+				resp, err := gateway.SubmitHttp(&triggers.HttpRequest{
+					Method: "GET",
+					Path:   "/test",
+				})
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(resp.StatusCode).To(Equal(200))
+				Expect(resp.Body).To(Equal([]byte("success")))
+
+				Expect(mockHandler.ReceivedRequests).To(HaveLen(1))
+				Expect(mockHandler.ReceivedRequests[0].Path).To(Equal("/test"))
+			})
+		})
+	})
+
+	Context("Pool decorators answering requests", func() {
+		When("CORS preflights are answered without reaching the worker", func() {
+			It("Should return the worker's concurrency slot to the pool", func() {
+				pool := worker.NewProcessPool(&worker.ProcessPoolOptions{
+					MaxConcurrency: 1,
+				})
+				corsHandler := mock_worker.NewMockWorker(&mock_worker.MockWorkerOptions{
+					ReturnHttp: &triggers.HttpResponse{
+						Body:       []byte("success"),
+						StatusCode: 200,
+					},
+				})
+				pool.AddWorker(corsHandler)
+
+				policy, err := worker.NewCorsPolicy(&worker.CorsOptions{
+					AllowedOrigins: []string{"https://app.example.com"},
+				})
+				Expect(err).ShouldNot(HaveOccurred())
+
+				corsGateway, _ := inprocess_gateway.New()
+				defer corsGateway.Stop()
+				go corsGateway.Start(worker.NewDecoratedPool(pool, worker.WithCors(policy)))
+
+				for i := 0; i < 3; i++ {
+					resp, err := corsGateway.SubmitHttp(&triggers.HttpRequest{
+						Method: "OPTIONS",
+						Path:   "/test",
+						Header: map[string][]string{
+							"Origin":                        {"https://app.example.com"},
+							"Access-Control-Request-Method": {"POST"},
+						},
+					})
+					Expect(err).ShouldNot(HaveOccurred())
+					Expect(resp.StatusCode).To(Equal(204))
+				}
+				Expect(corsHandler.ReceivedRequests).To(BeEmpty())
+
+				By("Dispatching a request once the preflights are answered")
+				resp, err := corsGateway.SubmitHttp(&triggers.HttpRequest{
+					Method: "POST",
+					Path:   "/test",
+				})
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(resp.StatusCode).To(Equal(200))
+				Expect(corsHandler.ReceivedRequests).To(HaveLen(1))
+			})
+		})
+	})
+
+	Context("SubmitEvent", func() {
+		When("The gateway is started", func() {
+			It("Should dispatch the event to a worker", func() {
+				err := gateway.SubmitEvent(&triggers.Event{
+					ID:    "1234",
+					Topic: "test",
+				})
+				Expect(err).ShouldNot(HaveOccurred())
+
+				Expect(mockHandler.ReceivedEvents).To(HaveLen(1))
+				Expect(mockHandler.ReceivedEvents[0].Topic).To(Equal("test"))
+			})
+		})
+	})
+
+	Context("Stop", func() {
+		When("The gateway is stopped", func() {
+			It("Should return from Start and reject triggers", func() {
+				Expect(gateway.Stop()).To(Succeed())
+				Eventually(startErr).Should(Receive(BeNil()))
+
+				err := gateway.SubmitEvent(&triggers.Event{ID: "1234", Topic: "test"})
+				Expect(err).Should(HaveOccurred())
+			})
+		})
+	})
+})
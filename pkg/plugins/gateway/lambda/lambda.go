@@ -36,6 +36,7 @@ type eventType int
 const (
 	unknown eventType = iota
 	sns
+	s3
 	httpEvent
 	xforwardHeader string = "x-forwarded-for"
 )
@@ -70,6 +71,8 @@ func (event *Event) getEventType(data []byte) eventType {
 		switch eventSource {
 		case "aws:sns":
 			return sns
+		case "aws:s3":
+			return s3
 		}
 	}
 
@@ -108,6 +111,11 @@ func (event *Event) UnmarshalJSON(data []byte) error {
 					payloadMap := messageJson.Payload
 					payloadBytes, err := json.Marshal(&payloadMap)
 
+					// Binary payloads are carried in the message's data
+					if messageJson.Encoding != ep.PayloadEncoding_Json {
+						payloadBytes = messageJson.Data
+					}
+
 					if err == nil {
 						event.Requests = append(event.Requests, &triggers.Event{
 							ID:      messageJson.ID,
@@ -119,6 +127,21 @@ func (event *Event) UnmarshalJSON(data []byte) error {
 			}
 		}
 		break
+	case s3:
+		s3Event := &events.S3Event{}
+		err = json.Unmarshal(data, s3Event)
+
+		if err == nil {
+			for _, s3Record := range s3Event.Records {
+				notification, ok := bucketNotificationFromS3(s3Record)
+				if !ok {
+					continue
+				}
+
+				event.Requests = append(event.Requests, notification)
+			}
+		}
+		break
 	case httpEvent:
 		evt := &events.APIGatewayV2HTTPRequest{}
 		err = json.Unmarshal(data, evt)
@@ -149,6 +172,8 @@ func (event *Event) UnmarshalJSON(data []byte) error {
 					Method: evt.RequestContext.HTTP.Method,
 					Path:   evt.RawPath,
 					Query:  qVals,
+					// API Gateway resolves the client address itself
+					ClientIP: evt.RequestContext.HTTP.SourceIP,
 				})
 			}
 		}
@@ -169,6 +194,33 @@ func (event *Event) UnmarshalJSON(data []byte) error {
 	return err
 }
 
+// bucketNotificationFromS3 - Translates an S3 event record, only object created and removed events are supported
+func bucketNotificationFromS3(record events.S3EventRecord) (*triggers.BucketNotification, bool) {
+	var eventType triggers.BucketNotificationType
+	switch {
+	case strings.HasPrefix(record.EventName, "ObjectCreated:"):
+		eventType = triggers.BucketNotificationType_Created
+	case strings.HasPrefix(record.EventName, "ObjectRemoved:"):
+		eventType = triggers.BucketNotificationType_Deleted
+	default:
+		return nil, false
+	}
+
+	// S3 URL encodes object keys in event notifications
+	key, err := url.QueryUnescape(record.S3.Object.Key)
+	if err != nil {
+		key = record.S3.Object.Key
+	}
+
+	return &triggers.BucketNotification{
+		Bucket:    record.S3.Bucket.Name,
+		Key:       key,
+		EventType: eventType,
+		Size:      record.S3.Object.Size,
+		ETag:      record.S3.Object.ETag,
+	}, true
+}
+
 type LambdaGateway struct {
 	pool    worker.WorkerPool
 	runtime LambdaRuntimeHandler
@@ -176,12 +228,39 @@ type LambdaGateway struct {
 	finished chan int
 }
 
+// lambdaResponseHeaders - Translates the headers of a response for API Gateway, cookies are returned separately
+// as each must be sent as its own Set-Cookie header, other headers with several values are joined
+func lambdaResponseHeaders(response *triggers.HttpResponse) (map[string]string, []string) {
+	headers := make(map[string]string)
+	var cookies []string
+
+	if response.Header == nil {
+		return headers, cookies
+	}
+
+	response.Header.VisitAll(func(key []byte, val []byte) {
+		k := string(key)
+		if strings.EqualFold(k, "Set-Cookie") {
+			cookies = append(cookies, string(val))
+		} else if existing, ok := headers[k]; ok {
+			headers[k] = existing + "," + string(val)
+		} else {
+			headers[k] = string(val)
+		}
+	})
+
+	return headers, cookies
+}
+
 func (s *LambdaGateway) handle(ctx context.Context, event Event) (interface{}, error) {
-	wrkr, err := s.pool.GetWorker()
+	// Hold the invocation until the function has connected, bounded by the invocation deadline
+	wrkr, err := s.pool.GetWorkerWait(ctx)
 
 	if err != nil {
 		return nil, fmt.Errorf("Unable to get worker to handle events")
 	}
+	// Events may not contain a request the worker handles
+	defer worker.ReleaseWorker(wrkr)
 
 	for _, request := range event.Requests {
 		switch request.GetTriggerType() {
@@ -189,8 +268,13 @@ func (s *LambdaGateway) handle(ctx context.Context, event Event) (interface{}, e
 			if httpEvent, ok := request.(*triggers.HttpRequest); ok {
 				response, err := wrkr.HandleHttpRequest(httpEvent)
 
+				if err == nil {
+					// Lambda responses can't be streamed, so the full body is collected first
+					err = response.BufferBody()
+				}
+
 				if err != nil {
-					return events.APIGatewayProxyResponse{
+					return events.APIGatewayV2HTTPResponse{
 						StatusCode: 500,
 						Body:       "Error processing lambda request",
 						// TODO: Need to determine best case when to use this...
@@ -198,20 +282,15 @@ func (s *LambdaGateway) handle(ctx context.Context, event Event) (interface{}, e
 					}, nil
 				}
 
-				lambdaHTTPHeaders := make(map[string]string)
-
-				if response.Header != nil {
-					response.Header.VisitAll(func(key []byte, val []byte) {
-						lambdaHTTPHeaders[string(key)] = string(val)
-					})
-				}
+				lambdaHTTPHeaders, cookies := lambdaResponseHeaders(response)
 
 				responseString := base64.StdEncoding.EncodeToString(response.Body)
 
 				// We want to sniff the content type of the body that we have here as lambda cannot gzip it...
-				return events.APIGatewayProxyResponse{
+				return events.APIGatewayV2HTTPResponse{
 					StatusCode: response.StatusCode,
 					Headers:    lambdaHTTPHeaders,
+					Cookies:    cookies,
 					Body:       responseString,
 					// TODO: Need to determine best case when to use this...
 					IsBase64Encoded: true,
@@ -229,6 +308,15 @@ func (s *LambdaGateway) handle(ctx context.Context, event Event) (interface{}, e
 				return nil, fmt.Errorf("Error!: Found non Event in event with trigger type: %s", triggers.TriggerType_Subscription.String())
 			}
 			break
+		case triggers.TriggerType_Bucket:
+			if notification, ok := request.(*triggers.BucketNotification); ok {
+				if err := wrkr.HandleBucketNotification(notification); err != nil {
+					return nil, err
+				}
+			} else {
+				return nil, fmt.Errorf("Error!: Found non BucketNotification in event with trigger type: %s", triggers.TriggerType_Bucket.String())
+			}
+			break
 		}
 	}
 	return nil, nil
@@ -28,12 +28,15 @@ import (
 	ep "github.com/nitrictech/nitric/pkg/plugins/events"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"github.com/valyala/fasthttp"
 )
 
 type MockLambdaRuntime struct {
 	lambda_service.LambdaRuntimeHandler
 	// FIXME: Make this a union array of stuff to send....
 	eventQueue []interface{}
+	// The responses returned by the handler for each event
+	responses []interface{}
 }
 
 func (m *MockLambdaRuntime) Start(handler interface{}) {
@@ -47,7 +50,8 @@ func (m *MockLambdaRuntime) Start(handler interface{}) {
 		json.Unmarshal(bytes, &evt)
 		// Unmarshal the thing into the event type we expect...
 		// TODO: Do something with out results here...
-		_, err := typedFunc(context.TODO(), evt)
+		response, err := typedFunc(context.TODO(), evt)
+		m.responses = append(m.responses, response)
 
 		if err != nil {
 			// Print the error?
@@ -126,6 +130,48 @@ var _ = Describe("Lambda", func() {
 		})
 	})
 
+	Context("Http Responses", func() {
+		When("The function sets multiple cookies", func() {
+			cookiePool := worker.NewProcessPool(&worker.ProcessPoolOptions{})
+			header := &fasthttp.ResponseHeader{}
+			header.Add("Set-Cookie", "session=abc; HttpOnly")
+			header.Add("Set-Cookie", "csrf=def")
+			header.Add("X-Test", "test")
+			cookiePool.AddWorker(mock_worker.NewMockWorker(&mock_worker.MockWorkerOptions{
+				ReturnHttp: &triggers.HttpResponse{
+					Header:     header,
+					Body:       []byte("success"),
+					StatusCode: 200,
+				},
+			}))
+
+			runtime := MockLambdaRuntime{
+				eventQueue: []interface{}{&events.APIGatewayV2HTTPRequest{
+					RawPath: "/test",
+					RequestContext: events.APIGatewayV2HTTPRequestContext{
+						HTTP: events.APIGatewayV2HTTPRequestContextHTTPDescription{
+							Method: "GET",
+						},
+					},
+				}},
+			}
+
+			client, _ := lambda_service.NewWithRuntime(runtime.Start)
+
+			It("Should return each cookie separately", func() {
+				client.Start(cookiePool)
+
+				Expect(runtime.responses).To(HaveLen(1))
+				response, ok := runtime.responses[0].(events.APIGatewayV2HTTPResponse)
+				Expect(ok).To(BeTrue())
+
+				Expect(response.Cookies).To(Equal([]string{"session=abc; HttpOnly", "csrf=def"}))
+				Expect(response.Headers["X-Test"]).To(Equal("test"))
+				Expect(response.Headers).ToNot(HaveKey("Set-Cookie"))
+			})
+		})
+	})
+
 	Context("SNS Events", func() {
 		When("The Lambda Gateway receives SNS events", func() {
 			topicName := "MyTopic"
@@ -177,4 +223,70 @@ var _ = Describe("Lambda", func() {
 			})
 		})
 	})
+
+	Context("S3 Events", func() {
+		When("The Lambda Gateway receives S3 events", func() {
+			runtime := MockLambdaRuntime{
+				// Setup mock events for our runtime to process...
+				eventQueue: []interface{}{&events.S3Event{
+					Records: []events.S3EventRecord{
+						{
+							EventSource: "aws:s3",
+							EventName:   "ObjectCreated:Put",
+							S3: events.S3Entity{
+								Bucket: events.S3Bucket{Name: "my-bucket"},
+								Object: events.S3Object{
+									Key:  "images/my+image.png",
+									Size: 1024,
+									ETag: "d41d8cd98f00b204e9800998ecf8427e",
+								},
+							},
+						},
+						{
+							EventSource: "aws:s3",
+							EventName:   "ObjectRemoved:Delete",
+							S3: events.S3Entity{
+								Bucket: events.S3Bucket{Name: "my-bucket"},
+								Object: events.S3Object{
+									Key: "old.txt",
+								},
+							},
+						},
+						{
+							EventSource: "aws:s3",
+							EventName:   "ObjectRestore:Completed",
+							S3: events.S3Entity{
+								Bucket: events.S3Bucket{Name: "my-bucket"},
+								Object: events.S3Object{
+									Key: "archived.txt",
+								},
+							},
+						},
+					},
+				}},
+			}
+
+			client, _ := lambda_service.NewWithRuntime(runtime.Start)
+
+			It("The gateway should translate into bucket notifications", func() {
+				client.Start(pool)
+
+				By("Handling the created and removed events")
+				Expect(mockHandler.ReceivedNotifications).To(HaveLen(2))
+
+				By("Translating the created event")
+				Expect(*mockHandler.ReceivedNotifications[0]).To(Equal(triggers.BucketNotification{
+					Bucket:    "my-bucket",
+					Key:       "images/my image.png",
+					EventType: triggers.BucketNotificationType_Created,
+					Size:      1024,
+					ETag:      "d41d8cd98f00b204e9800998ecf8427e",
+				}))
+
+				By("Translating the removed event")
+				Expect(mockHandler.ReceivedNotifications[1].Key).To(Equal("old.txt"))
+				Expect(mockHandler.ReceivedNotifications[1].EventType).To(Equal(triggers.BucketNotificationType_Deleted))
+			})
+		})
+	})
 })
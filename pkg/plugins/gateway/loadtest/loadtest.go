@@ -0,0 +1,391 @@
+// Copyright 2021 Nitric Pty Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Fires synthetic triggers at the worker pool at a fixed rate and reports throughput and latency,
+// used to benchmark worker concurrency settings without an external load tool
+package loadtest_gateway
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nitrictech/nitric/pkg/plugins/gateway"
+	"github.com/nitrictech/nitric/pkg/triggers"
+	"github.com/nitrictech/nitric/pkg/utils"
+	"github.com/nitrictech/nitric/pkg/worker"
+)
+
+type TriggerKind int
+
+const (
+	TriggerKind_Http TriggerKind = iota
+	TriggerKind_Event
+)
+
+var triggerKindNames = map[TriggerKind]string{
+	TriggerKind_Http:  "http",
+	TriggerKind_Event: "event",
+}
+
+func (k TriggerKind) String() string {
+	return triggerKindNames[k]
+}
+
+const (
+	// DefaultRate - The triggers fired per second by default
+	DefaultRate = 100
+	// DefaultDuration - How long triggers are fired for by default
+	DefaultDuration = 10 * time.Second
+	// DefaultMaxInFlight - The most triggers waiting on workers at once by default
+	DefaultMaxInFlight = 1000
+)
+
+// LatencyPercentiles - The distribution of trigger latencies in milliseconds
+type LatencyPercentiles struct {
+	Min  float64 `json:"min"`
+	Mean float64 `json:"mean"`
+	P50  float64 `json:"p50"`
+	P90  float64 `json:"p90"`
+	P95  float64 `json:"p95"`
+	P99  float64 `json:"p99"`
+	Max  float64 `json:"max"`
+}
+
+// LoadTestResult - The results of a load test, written as JSON once it ends
+type LoadTestResult struct {
+	Trigger string `json:"trigger"`
+	// The target rate in triggers per second
+	Rate            int     `json:"rate"`
+	DurationSeconds float64 `json:"durationSeconds"`
+	// Triggers dispatched to the pool
+	Sent int `json:"sent"`
+	// Triggers handled successfully, HTTP responses with a 5xx status are errors
+	Completed int `json:"completed"`
+	Errors    int `json:"errors"`
+	// Triggers that weren't sent because the in-flight limit was reached
+	Dropped int `json:"dropped"`
+	// Triggers completed per second
+	ThroughputPerSecond float64            `json:"throughputPerSecond"`
+	LatencyMs           LatencyPercentiles `json:"latencyMs"`
+}
+
+type LoadTestGateway struct {
+	gateway.UnimplementedGatewayPlugin
+	rate        int
+	duration    time.Duration
+	maxInFlight int
+	output      io.Writer
+
+	trigger TriggerKind
+	method  string
+	path    string
+	topic   string
+	body    []byte
+
+	lock      sync.Mutex
+	latencies []time.Duration
+	sent      int
+	errors    int
+	dropped   int
+	result    *LoadTestResult
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// record - Records the outcome of a single trigger
+func (g *LoadTestGateway) record(latency time.Duration, err error) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	if err != nil {
+		g.errors++
+		return
+	}
+
+	g.latencies = append(g.latencies, latency)
+}
+
+// fire - Dispatches a single synthetic trigger through the pool, as the other gateways do
+func (g *LoadTestGateway) fire(pool worker.WorkerPool, n int) {
+	start := time.Now()
+
+	wrkr, err := pool.GetWorker()
+	if err != nil {
+		g.record(0, err)
+		return
+	}
+	defer worker.ReleaseWorker(wrkr)
+
+	if g.trigger == TriggerKind_Event {
+		err = wrkr.HandleEvent(&triggers.Event{
+			ID:      fmt.Sprintf("loadtest-%d", n),
+			Topic:   g.topic,
+			Payload: g.body,
+		})
+		g.record(time.Since(start), err)
+		return
+	}
+
+	resp, err := wrkr.HandleHttpRequest(&triggers.HttpRequest{
+		Method: g.method,
+		Path:   g.path,
+		Body:   g.body,
+		Header: map[string][]string{
+			"User-Agent": {"nitric-load-test"},
+		},
+		Query: map[string][]string{},
+	})
+	if err == nil {
+		if resp.BodyStream != nil {
+			resp.BodyStream.Close()
+		}
+		if resp.StatusCode >= 500 {
+			err = fmt.Errorf("status %d", resp.StatusCode)
+		}
+	}
+	g.record(time.Since(start), err)
+}
+
+// minTickInterval - The shortest interval triggers are fired at, higher rates fire several triggers each tick
+// as tickers can't reliably tick faster than this
+const minTickInterval = time.Millisecond
+
+// Start - Fires triggers at the configured rate until the duration elapses or the gateway is stopped,
+// then waits for in-flight triggers and writes the results
+func (g *LoadTestGateway) Start(pool worker.WorkerPool) error {
+	inFlight := make(chan struct{}, g.maxInFlight)
+	wg := sync.WaitGroup{}
+
+	interval := time.Second / time.Duration(g.rate)
+	if interval < minTickInterval {
+		interval = minTickInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	deadline := time.NewTimer(g.duration)
+	defer deadline.Stop()
+
+	started := time.Now()
+	// The triggers due so far, whether they were sent or dropped
+	issued := 0
+
+fire:
+	for {
+		select {
+		case <-g.stop:
+			break fire
+		case <-deadline.C:
+			break fire
+		case now := <-ticker.C:
+			due := int(now.Sub(started).Seconds() * float64(g.rate))
+			for ; issued < due; issued++ {
+				select {
+				case inFlight <- struct{}{}:
+				default:
+					g.lock.Lock()
+					g.dropped++
+					g.lock.Unlock()
+					continue
+				}
+
+				g.lock.Lock()
+				g.sent++
+				g.lock.Unlock()
+
+				wg.Add(1)
+				go func(n int) {
+					defer wg.Done()
+					defer func() { <-inFlight }()
+					g.fire(pool, n)
+				}(issued)
+			}
+		}
+	}
+
+	wg.Wait()
+
+	result := g.summarize(time.Since(started))
+	g.lock.Lock()
+	g.result = result
+	g.lock.Unlock()
+
+	encoder := json.NewEncoder(g.output)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(result)
+}
+
+// summarize - Calculates the results from the recorded triggers
+func (g *LoadTestGateway) summarize(elapsed time.Duration) *LoadTestResult {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	result := &LoadTestResult{
+		Trigger:         g.trigger.String(),
+		Rate:            g.rate,
+		DurationSeconds: elapsed.Seconds(),
+		Sent:            g.sent,
+		Completed:       len(g.latencies),
+		Errors:          g.errors,
+		Dropped:         g.dropped,
+	}
+
+	if elapsed > 0 {
+		result.ThroughputPerSecond = float64(result.Completed) / elapsed.Seconds()
+	}
+
+	if len(g.latencies) == 0 {
+		return result
+	}
+
+	sorted := make([]time.Duration, len(g.latencies))
+	copy(sorted, g.latencies)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	total := time.Duration(0)
+	for _, latency := range sorted {
+		total += latency
+	}
+
+	result.LatencyMs = LatencyPercentiles{
+		Min:  milliseconds(sorted[0]),
+		Mean: milliseconds(total / time.Duration(len(sorted))),
+		P50:  milliseconds(percentile(sorted, 50)),
+		P90:  milliseconds(percentile(sorted, 90)),
+		P95:  milliseconds(percentile(sorted, 95)),
+		P99:  milliseconds(percentile(sorted, 99)),
+		Max:  milliseconds(sorted[len(sorted)-1]),
+	}
+
+	return result
+}
+
+// percentile - Returns the nearest-rank percentile of sorted latencies
+func percentile(sorted []time.Duration, p int) time.Duration {
+	rank := (p*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+
+	return sorted[rank-1]
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// Result - Returns the results of the load test, nil until it has ended
+func (g *LoadTestGateway) Result() *LoadTestResult {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	return g.result
+}
+
+// Stop - Ends the load test early, results are still written for the triggers fired
+func (g *LoadTestGateway) Stop() error {
+	g.stopOnce.Do(func() {
+		close(g.stop)
+	})
+	return nil
+}
+
+// positiveIntEnv - Reads a positive integer environment variable
+func positiveIntEnv(name string, defaultValue int) (int, error) {
+	value := utils.GetEnv(name, strconv.Itoa(defaultValue))
+	parsed, err := strconv.Atoi(value)
+	if err != nil || parsed < 1 {
+		return 0, fmt.Errorf("invalid %s env var, expected positive integer value, got %v", name, value)
+	}
+
+	return parsed, nil
+}
+
+// New - Creates a load test gateway configured by the LOAD_TEST_* environment variables
+func New() (gateway.GatewayService, error) {
+	rate, err := positiveIntEnv("LOAD_TEST_RATE", DefaultRate)
+	if err != nil {
+		return nil, err
+	}
+
+	durationSeconds, err := positiveIntEnv("LOAD_TEST_DURATION_SECONDS", int(DefaultDuration/time.Second))
+	if err != nil {
+		return nil, err
+	}
+
+	maxInFlight, err := positiveIntEnv("LOAD_TEST_MAX_IN_FLIGHT", DefaultMaxInFlight)
+	if err != nil {
+		return nil, err
+	}
+
+	body := []byte(utils.GetEnv("LOAD_TEST_BODY", ""))
+
+	var trigger LoadTestGatewayOption
+	switch kind := strings.ToLower(utils.GetEnv("LOAD_TEST_TRIGGER", "http")); kind {
+	case "http":
+		trigger = WithHttpRequest(strings.ToUpper(utils.GetEnv("LOAD_TEST_METHOD", "GET")), utils.GetEnv("LOAD_TEST_PATH", "/"), body)
+	case "event":
+		trigger = WithEvent(utils.GetEnv("LOAD_TEST_TOPIC", "load-test"), body)
+	default:
+		return nil, fmt.Errorf("invalid LOAD_TEST_TRIGGER env var, expected http or event, got %v", kind)
+	}
+
+	return NewWithOptions(
+		WithRate(rate),
+		WithDuration(time.Duration(durationSeconds)*time.Second),
+		WithMaxInFlight(maxInFlight),
+		trigger,
+	)
+}
+
+// NewWithOptions - Creates a load test gateway, firing GET / HTTP requests at the default rate and duration unless configured
+func NewWithOptions(opts ...LoadTestGatewayOption) (gateway.GatewayService, error) {
+	g := &LoadTestGateway{
+		rate:        DefaultRate,
+		duration:    DefaultDuration,
+		maxInFlight: DefaultMaxInFlight,
+		output:      os.Stdout,
+		trigger:     TriggerKind_Http,
+		method:      "GET",
+		path:        "/",
+		latencies:   make([]time.Duration, 0),
+		stop:        make(chan struct{}),
+	}
+
+	for _, o := range opts {
+		o.Apply(g)
+	}
+
+	if g.rate < 1 {
+		return nil, fmt.Errorf("load test rate must be positive, got %d", g.rate)
+	}
+
+	if g.duration <= 0 {
+		return nil, fmt.Errorf("load test duration must be positive, got %v", g.duration)
+	}
+
+	if g.maxInFlight < 1 {
+		return nil, fmt.Errorf("load test max in-flight triggers must be positive, got %d", g.maxInFlight)
+	}
+
+	return g, nil
+}
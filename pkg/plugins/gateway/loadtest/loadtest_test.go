@@ -0,0 +1,124 @@
+// Copyright 2021 Nitric Pty Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package loadtest_gateway_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"time"
+
+	loadtest_gateway "github.com/nitrictech/nitric/pkg/plugins/gateway/loadtest"
+	"github.com/nitrictech/nitric/pkg/triggers"
+	"github.com/nitrictech/nitric/pkg/worker"
+	mock_worker "github.com/nitrictech/nitric/tests/mocks/worker"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("LoadTestGateway", func() {
+	Context("Firing events", func() {
+		When("The workers handle every event", func() {
+			It("Should report every event as completed in the JSON results", func() {
+				pool := worker.NewProcessPool(&worker.ProcessPoolOptions{})
+				pool.AddWorker(mock_worker.NewMockWorker(&mock_worker.MockWorkerOptions{}))
+
+				output := &bytes.Buffer{}
+				gw, err := loadtest_gateway.NewWithOptions(
+					loadtest_gateway.WithEvent("orders", []byte("payload")),
+					loadtest_gateway.WithRate(200),
+					loadtest_gateway.WithDuration(250*time.Millisecond),
+					loadtest_gateway.WithOutput(output),
+				)
+				Expect(err).ShouldNot(HaveOccurred())
+
+				Expect(gw.Start(pool)).To(Succeed())
+
+				var result loadtest_gateway.LoadTestResult
+				Expect(json.Unmarshal(output.Bytes(), &result)).To(Succeed())
+				Expect(result.Trigger).To(Equal("event"))
+				Expect(result.Sent).To(BeNumerically(">", 0))
+				Expect(result.Completed).To(Equal(result.Sent))
+				Expect(result.Errors).To(Equal(0))
+				Expect(result.LatencyMs.P50).To(BeNumerically("<=", result.LatencyMs.P99))
+				Expect(result.LatencyMs.P99).To(BeNumerically("<=", result.LatencyMs.Max))
+				Expect(gw.(*loadtest_gateway.LoadTestGateway).Result()).To(Equal(&result))
+			})
+		})
+	})
+
+	Context("Firing HTTP requests", func() {
+		When("The workers respond with server errors", func() {
+			It("Should report the requests as errors", func() {
+				pool := worker.NewProcessPool(&worker.ProcessPoolOptions{})
+				pool.AddWorker(mock_worker.NewMockWorker(&mock_worker.MockWorkerOptions{
+					ReturnHttp: &triggers.HttpResponse{
+						StatusCode: 503,
+					},
+				}))
+
+				gw, _ := loadtest_gateway.NewWithOptions(
+					loadtest_gateway.WithHttpRequest("POST", "/orders", []byte("{}")),
+					loadtest_gateway.WithRate(100),
+					loadtest_gateway.WithDuration(200*time.Millisecond),
+					loadtest_gateway.WithOutput(&bytes.Buffer{}),
+				)
+
+				Expect(gw.Start(pool)).To(Succeed())
+
+				result := gw.(*loadtest_gateway.LoadTestGateway).Result()
+				Expect(result.Trigger).To(Equal("http"))
+				Expect(result.Sent).To(BeNumerically(">", 0))
+				Expect(result.Errors).To(Equal(result.Sent))
+				Expect(result.Completed).To(Equal(0))
+			})
+		})
+	})
+
+	Context("Stopping the gateway", func() {
+		It("Should end the load test early and still write the results", func() {
+			pool := worker.NewProcessPool(&worker.ProcessPoolOptions{})
+			pool.AddWorker(mock_worker.NewMockWorker(&mock_worker.MockWorkerOptions{}))
+
+			output := &bytes.Buffer{}
+			gw, _ := loadtest_gateway.NewWithOptions(
+				loadtest_gateway.WithEvent("orders", nil),
+				loadtest_gateway.WithDuration(time.Minute),
+				loadtest_gateway.WithOutput(output),
+			)
+
+			go func() {
+				time.Sleep(100 * time.Millisecond)
+				gw.Stop()
+			}()
+
+			started := time.Now()
+			Expect(gw.Start(pool)).To(Succeed())
+			Expect(time.Since(started)).To(BeNumerically("<", 10*time.Second))
+			Expect(output.Len()).To(BeNumerically(">", 0))
+		})
+	})
+
+	Context("Invalid options", func() {
+		It("Should reject a non-positive rate", func() {
+			_, err := loadtest_gateway.NewWithOptions(loadtest_gateway.WithRate(0))
+			Expect(err).Should(HaveOccurred())
+		})
+
+		It("Should reject a non-positive duration", func() {
+			_, err := loadtest_gateway.NewWithOptions(loadtest_gateway.WithDuration(0))
+			Expect(err).Should(HaveOccurred())
+		})
+	})
+})
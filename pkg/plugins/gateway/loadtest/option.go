@@ -0,0 +1,126 @@
+// Copyright 2021 Nitric Pty Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package loadtest_gateway
+
+import (
+	"io"
+	"time"
+)
+
+type LoadTestGatewayOption interface {
+	Apply(*LoadTestGateway)
+}
+
+type withRate struct {
+	rate int
+}
+
+func (w *withRate) Apply(g *LoadTestGateway) {
+	g.rate = w.rate
+}
+
+// WithRate - Fires the given number of triggers per second
+func WithRate(rate int) LoadTestGatewayOption {
+	return &withRate{
+		rate: rate,
+	}
+}
+
+type withDuration struct {
+	duration time.Duration
+}
+
+func (w *withDuration) Apply(g *LoadTestGateway) {
+	g.duration = w.duration
+}
+
+// WithDuration - Fires triggers for the given duration, the load test ends early if the gateway is stopped
+func WithDuration(duration time.Duration) LoadTestGatewayOption {
+	return &withDuration{
+		duration: duration,
+	}
+}
+
+type withHttpRequest struct {
+	method string
+	path   string
+	body   []byte
+}
+
+func (w *withHttpRequest) Apply(g *LoadTestGateway) {
+	g.trigger = TriggerKind_Http
+	g.method = w.method
+	g.path = w.path
+	g.body = w.body
+}
+
+// WithHttpRequest - Fires HTTP requests with the given method, path and body
+func WithHttpRequest(method string, path string, body []byte) LoadTestGatewayOption {
+	return &withHttpRequest{
+		method: method,
+		path:   path,
+		body:   body,
+	}
+}
+
+type withEvent struct {
+	topic   string
+	payload []byte
+}
+
+func (w *withEvent) Apply(g *LoadTestGateway) {
+	g.trigger = TriggerKind_Event
+	g.topic = w.topic
+	g.body = w.payload
+}
+
+// WithEvent - Fires events with the given topic and payload
+func WithEvent(topic string, payload []byte) LoadTestGatewayOption {
+	return &withEvent{
+		topic:   topic,
+		payload: payload,
+	}
+}
+
+type withMaxInFlight struct {
+	maxInFlight int
+}
+
+func (w *withMaxInFlight) Apply(g *LoadTestGateway) {
+	g.maxInFlight = w.maxInFlight
+}
+
+// WithMaxInFlight - Limits the triggers waiting on workers at once, triggers due while the limit is reached are dropped
+// and reported, rather than slowing the rate
+func WithMaxInFlight(maxInFlight int) LoadTestGatewayOption {
+	return &withMaxInFlight{
+		maxInFlight: maxInFlight,
+	}
+}
+
+type withOutput struct {
+	output io.Writer
+}
+
+func (w *withOutput) Apply(g *LoadTestGateway) {
+	g.output = w.output
+}
+
+// WithOutput - Writes the JSON results to the given writer instead of stdout
+func WithOutput(output io.Writer) LoadTestGatewayOption {
+	return &withOutput{
+		output: output,
+	}
+}
@@ -36,9 +36,28 @@ type NitricRequest struct {
 }
 
 type NitricResponse struct {
+	// Single value headers, kept for backwards compatibility
 	Headers map[string]string
-	Status  int
-	Body    []byte
+	// Headers with all of their values, e.g. several Set-Cookie headers, these take precedence over Headers
+	MultiHeaders map[string][]string
+	Status       int
+	Body         []byte
+}
+
+// HeaderValues - Returns all values of each response header, preferring MultiHeaders over the single value Headers
+func (r *NitricResponse) HeaderValues() map[string][]string {
+	values := make(map[string][]string, len(r.Headers)+len(r.MultiHeaders))
+	for key, val := range r.Headers {
+		values[key] = []string{val}
+	}
+
+	for key, vals := range r.MultiHeaders {
+		if len(vals) > 0 {
+			values[key] = vals
+		}
+	}
+
+	return values
 }
 
 type GatewayService interface {
@@ -48,6 +67,13 @@ type GatewayService interface {
 	Stop() error
 }
 
+// H2cGateway - A gateway that is able to accept HTTP/2 without TLS
+type H2cGateway interface {
+	GatewayService
+	// SetH2c - Accepts HTTP/2 without TLS on plaintext connections, must be called before Start
+	SetH2c(enabled bool)
+}
+
 type UnimplementedGatewayPlugin struct {
 	GatewayService
 }
@@ -0,0 +1,253 @@
+// Copyright 2021 Nitric Pty Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package schedule_gateway
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// How far ahead Next searches before deciding an expression never matches, e.g. 0 0 30 2 *
+const maxSearchYears = 5
+
+// Predefined schedules that may be used in place of an expression
+var cronDescriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+var monthNames = map[string]int{
+	"JAN": 1, "FEB": 2, "MAR": 3, "APR": 4, "MAY": 5, "JUN": 6,
+	"JUL": 7, "AUG": 8, "SEP": 9, "OCT": 10, "NOV": 11, "DEC": 12,
+}
+
+var dayNames = map[string]int{
+	"SUN": 0, "MON": 1, "TUE": 2, "WED": 3, "THU": 4, "FRI": 5, "SAT": 6,
+}
+
+type cronField struct {
+	name  string
+	min   int
+	max   int
+	names map[string]int
+}
+
+var (
+	secondField = cronField{name: "second", min: 0, max: 59}
+	minuteField = cronField{name: "minute", min: 0, max: 59}
+	hourField   = cronField{name: "hour", min: 0, max: 23}
+	domField    = cronField{name: "day of month", min: 1, max: 31}
+	monthField  = cronField{name: "month", min: 1, max: 12, names: monthNames}
+	// 7 is accepted as Sunday and folded into 0 once parsed
+	dowField = cronField{name: "day of week", min: 0, max: 7, names: dayNames}
+)
+
+// CronSchedule - A parsed cron expression
+type CronSchedule struct {
+	expression string
+	// Bitsets of the values each field matches
+	second uint64
+	minute uint64
+	hour   uint64
+	dom    uint64
+	month  uint64
+	dow    uint64
+	// When both day fields are restricted a day matching either of them matches, as in standard cron
+	domWildcard bool
+	dowWildcard bool
+}
+
+// String - Returns the expression the schedule was parsed from
+func (c *CronSchedule) String() string {
+	return c.expression
+}
+
+// parseValue - Parses a single field value, which may be a name, e.g. MON
+func (f cronField) parseValue(value string) (int, error) {
+	if n, ok := f.names[strings.ToUpper(value)]; ok {
+		return n, nil
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s value: %s", f.name, value)
+	}
+
+	if n < f.min || n > f.max {
+		return 0, fmt.Errorf("%s value %d out of range %d-%d", f.name, n, f.min, f.max)
+	}
+
+	return n, nil
+}
+
+// parse - Parses a field into a bitset of matching values, returning true if the field is a wildcard
+func (f cronField) parse(field string) (uint64, bool, error) {
+	var bits uint64
+	wildcard := field == "*" || field == "?"
+
+	for _, part := range strings.Split(field, ",") {
+		rangePart, step := part, 1
+
+		if i := strings.Index(part, "/"); i >= 0 {
+			s, err := strconv.Atoi(part[i+1:])
+			if err != nil || s <= 0 {
+				return 0, false, fmt.Errorf("invalid %s step: %s", f.name, part)
+			}
+			rangePart, step = part[:i], s
+		}
+
+		var start, end int
+		switch {
+		case rangePart == "*" || rangePart == "?":
+			start, end = f.min, f.max
+		case strings.Contains(rangePart, "-"):
+			bounds := strings.SplitN(rangePart, "-", 2)
+			var err error
+			if start, err = f.parseValue(bounds[0]); err != nil {
+				return 0, false, err
+			}
+			if end, err = f.parseValue(bounds[1]); err != nil {
+				return 0, false, err
+			}
+			if start > end {
+				return 0, false, fmt.Errorf("invalid %s range: %s", f.name, rangePart)
+			}
+		default:
+			var err error
+			if start, err = f.parseValue(rangePart); err != nil {
+				return 0, false, err
+			}
+			end = start
+			// A stepped single value, e.g. 5/15, runs to the end of the field
+			if step > 1 {
+				end = f.max
+			}
+		}
+
+		for v := start; v <= end; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+
+	return bits, wildcard, nil
+}
+
+// ParseCron - Parses a standard 5 field cron expression (minute hour day-of-month month day-of-week),
+// an expression with a leading seconds field or one of the predefined schedules, e.g. @hourly
+func ParseCron(expression string) (*CronSchedule, error) {
+	expr := strings.TrimSpace(expression)
+	if descriptor, ok := cronDescriptors[strings.ToLower(expr)]; ok {
+		expr = descriptor
+	}
+
+	fields := strings.Fields(expr)
+	switch len(fields) {
+	case 5:
+		fields = append([]string{"0"}, fields...)
+	case 6:
+	default:
+		return nil, fmt.Errorf("invalid cron expression %q: expected 5 or 6 fields, found %d", expression, len(fields))
+	}
+
+	c := &CronSchedule{expression: expression}
+	var err error
+
+	if c.second, _, err = secondField.parse(fields[0]); err != nil {
+		return nil, fmt.Errorf("invalid cron expression %q: %v", expression, err)
+	}
+	if c.minute, _, err = minuteField.parse(fields[1]); err != nil {
+		return nil, fmt.Errorf("invalid cron expression %q: %v", expression, err)
+	}
+	if c.hour, _, err = hourField.parse(fields[2]); err != nil {
+		return nil, fmt.Errorf("invalid cron expression %q: %v", expression, err)
+	}
+	if c.dom, c.domWildcard, err = domField.parse(fields[3]); err != nil {
+		return nil, fmt.Errorf("invalid cron expression %q: %v", expression, err)
+	}
+	if c.month, _, err = monthField.parse(fields[4]); err != nil {
+		return nil, fmt.Errorf("invalid cron expression %q: %v", expression, err)
+	}
+	if c.dow, c.dowWildcard, err = dowField.parse(fields[5]); err != nil {
+		return nil, fmt.Errorf("invalid cron expression %q: %v", expression, err)
+	}
+
+	// Sunday may be given as 7
+	if c.dow&(1<<7) != 0 {
+		c.dow = (c.dow | 1) &^ (1 << 7)
+	}
+
+	return c, nil
+}
+
+func matches(bits uint64, value int) bool {
+	return bits&(1<<uint(value)) != 0
+}
+
+func (c *CronSchedule) dayMatches(t time.Time) bool {
+	domMatch := matches(c.dom, t.Day())
+	dowMatch := matches(c.dow, int(t.Weekday()))
+
+	if c.domWildcard || c.dowWildcard {
+		return domMatch && dowMatch
+	}
+
+	return domMatch || dowMatch
+}
+
+// Next - Returns the first time after the given time matched by the schedule, in the same location,
+// or the zero time if nothing matches within the next few years
+func (c *CronSchedule) Next(after time.Time) time.Time {
+	loc := after.Location()
+	t := after.Truncate(time.Second).Add(time.Second)
+	yearLimit := t.Year() + maxSearchYears
+
+	for t.Year() <= yearLimit {
+		if !matches(c.month, int(t.Month())) {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+			continue
+		}
+
+		if !c.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+			continue
+		}
+
+		if !matches(c.hour, t.Hour()) {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+			continue
+		}
+
+		if !matches(c.minute, t.Minute()) {
+			t = t.Truncate(time.Minute).Add(time.Minute)
+			continue
+		}
+
+		if !matches(c.second, t.Second()) {
+			t = t.Add(time.Second)
+			continue
+		}
+
+		return t
+	}
+
+	return time.Time{}
+}
@@ -0,0 +1,156 @@
+// Copyright 2021 Nitric Pty Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Fires schedule triggers at the times matched by cron expressions,
+// used to run scheduled functions where no cloud scheduler is available
+package schedule_gateway
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nitrictech/nitric/pkg/plugins/gateway"
+	"github.com/nitrictech/nitric/pkg/triggers"
+	"github.com/nitrictech/nitric/pkg/utils"
+	"github.com/nitrictech/nitric/pkg/worker"
+)
+
+// SCHEDULES_ENV - Semicolon separated schedules in the form name=expression,
+// e.g. nightly=0 0 * * *;reports=*/15 * * * *
+const SCHEDULES_ENV = "SCHEDULES"
+
+type schedule struct {
+	name string
+	cron *CronSchedule
+}
+
+type ScheduleGateway struct {
+	gateway.UnimplementedGatewayPlugin
+	schedules []*schedule
+	stop      chan struct{}
+	stopOnce  sync.Once
+}
+
+// fire - Dispatches a schedule trigger to a worker from the pool
+func (s *ScheduleGateway) fire(pool worker.WorkerPool, sch *schedule, at time.Time) {
+	wrkr, err := pool.GetWorker()
+	if err != nil {
+		fmt.Println(fmt.Sprintf("unable to get worker to handle schedule %s: %v", sch.name, err))
+		return
+	}
+	defer worker.ReleaseWorker(wrkr)
+
+	if err := wrkr.HandleSchedule(&triggers.Schedule{
+		Name:       sch.name,
+		Expression: sch.cron.String(),
+		Time:       at,
+	}); err != nil {
+		fmt.Println(fmt.Sprintf("error handling schedule %s: %v", sch.name, err))
+	}
+}
+
+// run - Fires the schedule at each matching time until the gateway is stopped,
+// triggers are handled in the background so a slow function doesn't delay the next one
+func (s *ScheduleGateway) run(pool worker.WorkerPool, sch *schedule) {
+	for next := sch.cron.Next(time.Now()); !next.IsZero(); next = sch.cron.Next(next) {
+		timer := time.NewTimer(time.Until(next))
+
+		select {
+		case <-s.stop:
+			timer.Stop()
+			return
+		case <-timer.C:
+			go s.fire(pool, sch, next)
+		}
+	}
+}
+
+// Start - Fires schedule triggers into the pool, blocking until the gateway is stopped
+func (s *ScheduleGateway) Start(pool worker.WorkerPool) error {
+	wg := sync.WaitGroup{}
+
+	for _, sch := range s.schedules {
+		wg.Add(1)
+		go func(sch *schedule) {
+			defer wg.Done()
+			s.run(pool, sch)
+		}(sch)
+	}
+
+	<-s.stop
+	wg.Wait()
+
+	return nil
+}
+
+func (s *ScheduleGateway) Stop() error {
+	s.stopOnce.Do(func() {
+		close(s.stop)
+	})
+	return nil
+}
+
+// parseSchedules - Parses schedules in the SCHEDULES_ENV format
+func parseSchedules(value string) (map[string]string, error) {
+	schedules := make(map[string]string)
+
+	for _, entry := range strings.Split(value, ";") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+			return nil, fmt.Errorf("invalid schedule %q, expected name=expression", entry)
+		}
+
+		schedules[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+	}
+
+	return schedules, nil
+}
+
+// New - Creates a schedule gateway for the schedules configured in the SCHEDULES environment variable
+func New() (gateway.GatewayService, error) {
+	schedules, err := parseSchedules(utils.GetEnv(SCHEDULES_ENV, ""))
+	if err != nil {
+		return nil, err
+	}
+
+	return NewWithSchedules(schedules)
+}
+
+// NewWithSchedules - Creates a schedule gateway for the given cron expressions, keyed by schedule name
+func NewWithSchedules(schedules map[string]string) (gateway.GatewayService, error) {
+	s := &ScheduleGateway{
+		schedules: make([]*schedule, 0, len(schedules)),
+		stop:      make(chan struct{}),
+	}
+
+	for name, expression := range schedules {
+		cron, err := ParseCron(expression)
+		if err != nil {
+			return nil, fmt.Errorf("schedule %s: %v", name, err)
+		}
+
+		s.schedules = append(s.schedules, &schedule{
+			name: name,
+			cron: cron,
+		})
+	}
+
+	return s, nil
+}
@@ -0,0 +1,131 @@
+// Copyright 2021 Nitric Pty Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package schedule_gateway_test
+
+import (
+	"time"
+
+	schedule_gateway "github.com/nitrictech/nitric/pkg/plugins/gateway/schedule"
+	"github.com/nitrictech/nitric/pkg/worker"
+	mock_worker "github.com/nitrictech/nitric/tests/mocks/worker"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Schedule", func() {
+	// Monday 15 March 2021, 10:30:15 UTC
+	from := time.Date(2021, time.March, 15, 10, 30, 15, 0, time.UTC)
+
+	Context("ParseCron", func() {
+		When("Parsing valid expressions", func() {
+			It("Should return the next matching time", func() {
+				cases := map[string]time.Time{
+					"* * * * *":          time.Date(2021, time.March, 15, 10, 31, 0, 0, time.UTC),
+					"*/15 * * * *":       time.Date(2021, time.March, 15, 10, 45, 0, 0, time.UTC),
+					"0 9-17 * * MON-FRI": time.Date(2021, time.March, 15, 11, 0, 0, 0, time.UTC),
+					"30 2 * * 0":         time.Date(2021, time.March, 21, 2, 30, 0, 0, time.UTC),
+					"30 2 * * 7":         time.Date(2021, time.March, 21, 2, 30, 0, 0, time.UTC),
+					"0 0 1 jan *":        time.Date(2022, time.January, 1, 0, 0, 0, 0, time.UTC),
+					"0 0 29 2 *":         time.Date(2024, time.February, 29, 0, 0, 0, 0, time.UTC),
+					"0 0 1,20 * *":       time.Date(2021, time.March, 20, 0, 0, 0, 0, time.UTC),
+					"*/10 * * * * *":     time.Date(2021, time.March, 15, 10, 30, 20, 0, time.UTC),
+					"@hourly":            time.Date(2021, time.March, 15, 11, 0, 0, 0, time.UTC),
+					"@daily":             time.Date(2021, time.March, 16, 0, 0, 0, 0, time.UTC),
+				}
+
+				for expression, expected := range cases {
+					cron, err := schedule_gateway.ParseCron(expression)
+					Expect(err).ShouldNot(HaveOccurred(), expression)
+					Expect(cron.Next(from)).To(Equal(expected), expression)
+				}
+			})
+
+			It("Should match either day when both day fields are restricted", func() {
+				// The 20th of the month or any Wednesday
+				cron, err := schedule_gateway.ParseCron("0 0 20 * WED")
+				Expect(err).ShouldNot(HaveOccurred())
+
+				Expect(cron.Next(from)).To(Equal(time.Date(2021, time.March, 17, 0, 0, 0, 0, time.UTC)))
+			})
+
+			It("Should return the zero time for expressions that never match", func() {
+				cron, err := schedule_gateway.ParseCron("0 0 30 2 *")
+				Expect(err).ShouldNot(HaveOccurred())
+
+				Expect(cron.Next(from).IsZero()).To(BeTrue())
+			})
+		})
+
+		When("Parsing invalid expressions", func() {
+			It("Should return an error", func() {
+				for _, expression := range []string{
+					"",
+					"* * * *",
+					"60 * * * *",
+					"* 24 * * *",
+					"* * 0 * *",
+					"* * * 13 *",
+					"* * * * 8",
+					"*/0 * * * *",
+					"5-1 * * * *",
+					"* * * * FUNDAY",
+				} {
+					_, err := schedule_gateway.ParseCron(expression)
+					Expect(err).Should(HaveOccurred(), expression)
+				}
+			})
+		})
+	})
+
+	Context("NewWithSchedules", func() {
+		When("A schedule has an invalid expression", func() {
+			It("Should return an error", func() {
+				_, err := schedule_gateway.NewWithSchedules(map[string]string{
+					"broken": "not a cron",
+				})
+				Expect(err).Should(HaveOccurred())
+			})
+		})
+	})
+
+	Context("Start", func() {
+		When("A schedule is due", func() {
+			It("Should fire a schedule trigger into the pool", func() {
+				pool := worker.NewProcessPool(&worker.ProcessPoolOptions{})
+				mw := mock_worker.NewMockWorker(&mock_worker.MockWorkerOptions{})
+				pool.AddWorker(mw)
+
+				gw, err := schedule_gateway.NewWithSchedules(map[string]string{
+					"every-second": "* * * * * *",
+				})
+				Expect(err).ShouldNot(HaveOccurred())
+
+				stopped := make(chan error)
+				go func() {
+					stopped <- gw.Start(pool)
+				}()
+
+				Eventually(mw.ReceivedSchedules, "3s").ShouldNot(BeEmpty())
+				Expect(gw.Stop()).To(Succeed())
+				Eventually(stopped).Should(Receive(BeNil()))
+
+				schedule := mw.ReceivedSchedules()[0]
+				Expect(schedule.Name).To(Equal("every-second"))
+				Expect(schedule.Expression).To(Equal("* * * * * *"))
+				Expect(schedule.Time.Nanosecond()).To(Equal(0))
+			})
+		})
+	})
+})
@@ -0,0 +1,105 @@
+// Copyright 2021 Nitric Pty Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gateway
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+var tlsVersions = [...]string{"1.0", "1.1", "1.2", "1.3"}
+
+var tlsVersionValues = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// TlsGateway - A gateway that is able to serve its triggers over TLS
+type TlsGateway interface {
+	GatewayService
+	// SetTlsConfig - Serves the gateway over TLS using the given config, must be called before Start
+	SetTlsConfig(config *tls.Config)
+}
+
+// TlsOptions - The certificate and client verification options for a gateway serving TLS
+type TlsOptions struct {
+	// Paths to the PEM encoded certificate chain and private key
+	CertFile string
+	KeyFile  string
+	// PEM encoded certificate chain and private key, used in place of the files
+	CertPEM []byte
+	KeyPEM  []byte
+	// The minimum TLS version accepted, one of 1.0, 1.1, 1.2 or 1.3, defaults to 1.2
+	MinVersion string
+	// Path to a PEM encoded CA bundle, when set clients must present a certificate signed by one of its CAs
+	ClientCAFile string
+}
+
+// Enabled - Returns true if a certificate has been provided
+func (o *TlsOptions) Enabled() bool {
+	return o.CertFile != "" || len(o.CertPEM) > 0
+}
+
+// NewTlsConfig - Creates the TLS config for a gateway from the given options
+func NewTlsConfig(o *TlsOptions) (*tls.Config, error) {
+	var cert tls.Certificate
+	var err error
+
+	if len(o.CertPEM) > 0 {
+		cert, err = tls.X509KeyPair(o.CertPEM, o.KeyPEM)
+	} else {
+		cert, err = tls.LoadX509KeyPair(o.CertFile, o.KeyFile)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("unable to load TLS certificate: %v", err)
+	}
+
+	var minVersion uint16 = tls.VersionTLS12
+	if o.MinVersion != "" {
+		version, ok := tlsVersionValues[o.MinVersion]
+		if !ok {
+			return nil, fmt.Errorf("Invalid TLS version %s, supported versions are: %s", o.MinVersion, strings.Join(tlsVersions[:], ", "))
+		}
+		minVersion = version
+	}
+
+	config := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   minVersion,
+	}
+
+	if o.ClientCAFile != "" {
+		caPEM, err := ioutil.ReadFile(o.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read client CA bundle: %v", err)
+		}
+
+		clientCAs := x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(caPEM) {
+			return nil, fmt.Errorf("client CA bundle %s contains no PEM encoded certificates", o.ClientCAFile)
+		}
+
+		config.ClientCAs = clientCAs
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return config, nil
+}
@@ -0,0 +1,247 @@
+// Copyright 2021 Nitric Pty Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package middleware
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/nitrictech/nitric/pkg/plugins/errors"
+	"github.com/nitrictech/nitric/pkg/plugins/errors/codes"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// The error codes counted as failures by default, each indicates the provider itself is failing
+var DefaultCircuitBreakerFailureCodes = []codes.Code{
+	codes.Unavailable,
+	codes.ResourceExhausted,
+	codes.DeadlineExceeded,
+	codes.Internal,
+	codes.Unknown,
+}
+
+type CircuitState int
+
+const (
+	// CircuitState_Closed passes calls through to the plugin
+	CircuitState_Closed CircuitState = iota
+	// CircuitState_Open fails calls without calling the plugin
+	CircuitState_Open
+	// CircuitState_HalfOpen passes a single probe call through to the plugin to test whether it has recovered
+	CircuitState_HalfOpen
+)
+
+var circuitStates = [...]string{"CLOSED", "OPEN", "HALF_OPEN"}
+
+func (s CircuitState) String() string {
+	return circuitStates[s]
+}
+
+// CircuitBreakerPolicy - Governs when calls to a persistently failing plugin are failed fast
+type CircuitBreakerPolicy struct {
+	// The number of consecutive failed calls that opens the circuit
+	FailureThreshold int
+	// The time the circuit stays open before a probe call is allowed through
+	OpenDuration time.Duration
+	// The error codes counted as failures, defaults to DefaultCircuitBreakerFailureCodes
+	FailureCodes []codes.Code
+}
+
+// DefaultCircuitBreakerPolicy - Returns a policy opening the circuit after 5 consecutive failures, for 30 seconds
+func DefaultCircuitBreakerPolicy() *CircuitBreakerPolicy {
+	return &CircuitBreakerPolicy{
+		FailureThreshold: 5,
+		OpenDuration:     30 * time.Second,
+	}
+}
+
+// isFailure - Returns true if the error's code indicates the plugin's provider is failing
+func (p *CircuitBreakerPolicy) isFailure(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	failureCodes := p.FailureCodes
+	if len(failureCodes) == 0 {
+		failureCodes = DefaultCircuitBreakerFailureCodes
+	}
+
+	code := errors.Code(err)
+	for _, c := range failureCodes {
+		if code == c {
+			return true
+		}
+	}
+
+	return false
+}
+
+// CircuitBreakerMetrics - Prometheus collectors for circuit state transitions, a nil *CircuitBreakerMetrics records nothing
+type CircuitBreakerMetrics struct {
+	transitions *prometheus.CounterVec
+	state       *prometheus.GaugeVec
+}
+
+// observe - Records the circuit of the named plugin entering the given state
+func (m *CircuitBreakerMetrics) observe(plugin string, state CircuitState) {
+	if m == nil {
+		return
+	}
+
+	m.transitions.WithLabelValues(plugin, state.String()).Inc()
+	m.state.WithLabelValues(plugin).Set(float64(state))
+}
+
+// Register - Registers the collectors with the registerer
+func (m *CircuitBreakerMetrics) Register(registerer prometheus.Registerer) error {
+	for _, c := range []prometheus.Collector{m.transitions, m.state} {
+		if err := registerer.Register(c); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// NewCircuitBreakerMetrics - Creates the circuit breaker collectors, they're registered separately
+// as plugins are wrapped before the metrics registry is created
+func NewCircuitBreakerMetrics() *CircuitBreakerMetrics {
+	return &CircuitBreakerMetrics{
+		transitions: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "nitric",
+			Subsystem: "plugin",
+			Name:      "circuit_transitions_total",
+			Help:      "The number of times plugin circuits have entered each state.",
+		}, []string{"plugin", "state"}),
+		state: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "nitric",
+			Subsystem: "plugin",
+			Name:      "circuit_state",
+			Help:      "The current state of plugin circuits, 0 closed, 1 open and 2 half open.",
+		}, []string{"plugin"}),
+	}
+}
+
+// circuitBreaker - Fails calls fast while the plugin it guards is persistently failing
+type circuitBreaker struct {
+	plugin  string
+	policy  *CircuitBreakerPolicy
+	metrics *CircuitBreakerMetrics
+
+	lock     sync.Mutex
+	state    CircuitState
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+// transition - Moves the circuit to the given state, the lock must be held
+func (b *circuitBreaker) transition(state CircuitState) {
+	b.state = state
+	b.failures = 0
+	b.probing = false
+	if state == CircuitState_Open {
+		b.openedAt = time.Now()
+	}
+
+	b.metrics.observe(b.plugin, state)
+}
+
+// allow - Returns true if a call may be made, and whether it is the probe of a half open circuit
+func (b *circuitBreaker) allow() (bool, bool) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.state == CircuitState_Open && time.Since(b.openedAt) >= b.policy.OpenDuration {
+		b.transition(CircuitState_HalfOpen)
+	}
+
+	switch b.state {
+	case CircuitState_Closed:
+		return true, false
+	case CircuitState_HalfOpen:
+		// Only one probe is made at a time, other calls fail fast until it completes
+		if b.probing {
+			return false, false
+		}
+		b.probing = true
+		return true, true
+	default:
+		return false, false
+	}
+}
+
+// record - Records the result of a call, only the probe's result closes or reopens a half open circuit
+func (b *circuitBreaker) record(probe bool, err error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	failed := b.policy.isFailure(err)
+
+	switch b.state {
+	case CircuitState_Closed:
+		if !failed {
+			b.failures = 0
+			return
+		}
+
+		b.failures++
+		if b.failures >= b.policy.FailureThreshold {
+			b.transition(CircuitState_Open)
+		}
+	case CircuitState_HalfOpen:
+		if !probe {
+			return
+		}
+
+		if failed {
+			b.transition(CircuitState_Open)
+		} else {
+			b.transition(CircuitState_Closed)
+		}
+	}
+}
+
+// do - Calls fn unless the circuit is open, in which case an Unavailable error scoped to the operation is returned
+func (b *circuitBreaker) do(operation string, fn func() error) error {
+	allowed, probe := b.allow()
+	if !allowed {
+		return errors.ErrorsWithScope(
+			operation,
+			map[string]interface{}{
+				"plugin": b.plugin,
+			},
+		)(
+			codes.Unavailable,
+			fmt.Sprintf("%s plugin circuit is open after repeated failures, failing fast", b.plugin),
+			nil,
+		)
+	}
+
+	err := fn()
+	b.record(probe, err)
+
+	return err
+}
+
+func newCircuitBreaker(plugin string, policy *CircuitBreakerPolicy, metrics *CircuitBreakerMetrics) *circuitBreaker {
+	return &circuitBreaker{
+		plugin:  plugin,
+		policy:  policy,
+		metrics: metrics,
+		state:   CircuitState_Closed,
+	}
+}
@@ -0,0 +1,132 @@
+// Copyright 2021 Nitric Pty Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package middleware_test
+
+import (
+	"time"
+
+	"github.com/nitrictech/nitric/pkg/plugins/errors"
+	"github.com/nitrictech/nitric/pkg/plugins/errors/codes"
+	"github.com/nitrictech/nitric/pkg/plugins/events"
+	"github.com/nitrictech/nitric/pkg/plugins/middleware"
+	"github.com/nitrictech/nitric/pkg/plugins/queue"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// failingQueueService - Fails sends with the given code while failing is set
+type failingQueueService struct {
+	queue.UnimplementedQueuePlugin
+	failing  bool
+	code     codes.Code
+	attempts int
+}
+
+func (s *failingQueueService) Send(queueName string, task queue.NitricTask) error {
+	s.attempts++
+	if s.failing {
+		return errors.ErrorsWithScope("failingQueueService.Send", nil)(s.code, "send failed", nil)
+	}
+
+	return nil
+}
+
+var _ = Describe("Circuit Breaker", func() {
+	var policy *middleware.CircuitBreakerPolicy
+	var failing *failingQueueService
+	var plugin queue.QueueService
+
+	BeforeEach(func() {
+		policy = &middleware.CircuitBreakerPolicy{
+			FailureThreshold: 3,
+			OpenDuration:     50 * time.Millisecond,
+		}
+		failing = &failingQueueService{failing: true, code: codes.Unavailable}
+		plugin = middleware.QueueWithCircuitBreaker(failing, policy, middleware.NewCircuitBreakerMetrics())
+	})
+
+	When("Calls fail fewer times than the threshold", func() {
+		It("Should keep calling the plugin", func() {
+			for i := 0; i < 2; i++ {
+				Expect(plugin.Send("test", queue.NitricTask{})).Should(HaveOccurred())
+			}
+			failing.failing = false
+
+			Expect(plugin.Send("test", queue.NitricTask{})).ShouldNot(HaveOccurred())
+			Expect(failing.attempts).To(Equal(3))
+		})
+	})
+
+	When("Calls fail consecutively up to the threshold", func() {
+		BeforeEach(func() {
+			for i := 0; i < 3; i++ {
+				_ = plugin.Send("test", queue.NitricTask{})
+			}
+		})
+
+		It("Should fail fast with an Unavailable error", func() {
+			err := plugin.Send("test", queue.NitricTask{})
+
+			Expect(errors.Code(err)).To(Equal(codes.Unavailable))
+			Expect(err.Error()).To(ContainSubstring("circuit is open"))
+			Expect(failing.attempts).To(Equal(3))
+		})
+
+		It("Should close the circuit when the probe succeeds", func() {
+			time.Sleep(policy.OpenDuration)
+			failing.failing = false
+
+			Expect(plugin.Send("test", queue.NitricTask{})).ShouldNot(HaveOccurred())
+			Expect(plugin.Send("test", queue.NitricTask{})).ShouldNot(HaveOccurred())
+			Expect(failing.attempts).To(Equal(5))
+		})
+
+		It("Should reopen the circuit when the probe fails", func() {
+			time.Sleep(policy.OpenDuration)
+
+			Expect(errors.Code(plugin.Send("test", queue.NitricTask{}))).To(Equal(codes.Unavailable))
+			Expect(failing.attempts).To(Equal(4))
+
+			err := plugin.Send("test", queue.NitricTask{})
+			Expect(err.Error()).To(ContainSubstring("circuit is open"))
+			Expect(failing.attempts).To(Equal(4))
+		})
+	})
+
+	When("Calls fail with errors that aren't provider failures", func() {
+		It("Should not open the circuit", func() {
+			failing.code = codes.NotFound
+			for i := 0; i < 5; i++ {
+				Expect(errors.Code(plugin.Send("test", queue.NitricTask{}))).To(Equal(codes.NotFound))
+			}
+
+			Expect(failing.attempts).To(Equal(5))
+		})
+	})
+
+	When("Wrapping an event plugin", func() {
+		It("Should fail publishes fast once the circuit opens", func() {
+			flaky := &flakyEventService{failures: 10, code: codes.Internal}
+			eventPlugin := middleware.EventsWithCircuitBreaker(flaky, policy, nil)
+
+			for i := 0; i < 5; i++ {
+				_ = eventPlugin.Publish("test", &events.NitricEvent{ID: "1234"})
+			}
+
+			Expect(flaky.attempts).To(Equal(3))
+		})
+	})
+})
@@ -0,0 +1,196 @@
+// Copyright 2021 Nitric Pty Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package middleware
+
+import "github.com/nitrictech/nitric/pkg/plugins/events"
+
+// retryingEventService - Retries event plugin calls that fail with transient errors
+type retryingEventService struct {
+	events.EventService
+	policy *RetryPolicy
+}
+
+func (s *retryingEventService) Publish(topic string, event *events.NitricEvent) error {
+	return s.policy.do(func() error {
+		return s.EventService.Publish(topic, event)
+	})
+}
+
+func (s *retryingEventService) PublishOrdered(topic string, orderingKey string, event *events.NitricEvent) error {
+	return s.policy.do(func() error {
+		return events.PublishOrdered(s.EventService, topic, orderingKey, event)
+	})
+}
+
+// PublishBatch - Publishes the batch, retrying only the events that failed so accepted events aren't published twice
+func (s *retryingEventService) PublishBatch(topic string, evts []*events.NitricEvent) error {
+	pending := evts
+	// indices maps the pending events back to their position in the caller's batch
+	indices := make([]int, len(evts))
+	for i := range indices {
+		indices[i] = i
+	}
+	published := 0
+
+	return s.policy.do(func() error {
+		err := s.EventService.PublishBatch(topic, pending)
+
+		partialErr, ok := events.AsPartialPublishError(err)
+		if !ok {
+			return err
+		}
+
+		failed := make([]*events.NitricEvent, 0, len(partialErr.Failed))
+		failedIndices := make([]int, 0, len(partialErr.Failed))
+		for _, f := range partialErr.Failed {
+			if f.Index < 0 || f.Index >= len(pending) {
+				continue
+			}
+			failed = append(failed, pending[f.Index])
+			failedIndices = append(failedIndices, indices[f.Index])
+			// Report the failure against the caller's batch rather than the retried subset
+			f.Index = indices[f.Index]
+		}
+
+		published += partialErr.Published
+		partialErr.Published = published
+		pending, indices = failed, failedIndices
+
+		return err
+	})
+}
+
+func (s *retryingEventService) ListTopics() ([]string, error) {
+	var topics []string
+	err := s.policy.do(func() error {
+		var err error
+		topics, err = s.EventService.ListTopics()
+		return err
+	})
+
+	return topics, err
+}
+
+func (s *retryingEventService) ListTopicsWithPrefix(prefix string) ([]string, error) {
+	var topics []string
+	err := s.policy.do(func() error {
+		var err error
+		topics, err = s.EventService.ListTopicsWithPrefix(prefix)
+		return err
+	})
+
+	return topics, err
+}
+
+// EventsWithRetry - Wraps an event plugin, retrying calls that fail with transient errors
+func EventsWithRetry(plugin events.EventService, policy *RetryPolicy) events.EventService {
+	return &retryingEventService{
+		EventService: plugin,
+		policy:       policy,
+	}
+}
+
+// circuitBreakingEventService - Fails event plugin calls fast while the plugin is persistently failing
+type circuitBreakingEventService struct {
+	events.EventService
+	breaker *circuitBreaker
+}
+
+func (s *circuitBreakingEventService) Publish(topic string, event *events.NitricEvent) error {
+	return s.breaker.do("CircuitBreakingEventService.Publish", func() error {
+		return s.EventService.Publish(topic, event)
+	})
+}
+
+func (s *circuitBreakingEventService) PublishOrdered(topic string, orderingKey string, event *events.NitricEvent) error {
+	return s.breaker.do("CircuitBreakingEventService.PublishOrdered", func() error {
+		return events.PublishOrdered(s.EventService, topic, orderingKey, event)
+	})
+}
+
+func (s *circuitBreakingEventService) PublishBatch(topic string, evts []*events.NitricEvent) error {
+	return s.breaker.do("CircuitBreakingEventService.PublishBatch", func() error {
+		return s.EventService.PublishBatch(topic, evts)
+	})
+}
+
+func (s *circuitBreakingEventService) ListTopics() ([]string, error) {
+	var topics []string
+	err := s.breaker.do("CircuitBreakingEventService.ListTopics", func() error {
+		var err error
+		topics, err = s.EventService.ListTopics()
+		return err
+	})
+
+	return topics, err
+}
+
+func (s *circuitBreakingEventService) ListTopicsWithPrefix(prefix string) ([]string, error) {
+	var topics []string
+	err := s.breaker.do("CircuitBreakingEventService.ListTopicsWithPrefix", func() error {
+		var err error
+		topics, err = s.EventService.ListTopicsWithPrefix(prefix)
+		return err
+	})
+
+	return topics, err
+}
+
+// EventsWithCircuitBreaker - Wraps an event plugin, failing calls fast while it is persistently failing
+func EventsWithCircuitBreaker(plugin events.EventService, policy *CircuitBreakerPolicy, metrics *CircuitBreakerMetrics) events.EventService {
+	return &circuitBreakingEventService{
+		EventService: plugin,
+		breaker:      newCircuitBreaker("events", policy, metrics),
+	}
+}
+
+// rateLimitedEventService - Limits the rate events are published to each topic
+type rateLimitedEventService struct {
+	events.EventService
+	limiter *rateLimiter
+}
+
+func (s *rateLimitedEventService) Publish(topic string, event *events.NitricEvent) error {
+	if err := s.limiter.wait("RateLimitedEventService.Publish", topic, 1); err != nil {
+		return err
+	}
+
+	return s.EventService.Publish(topic, event)
+}
+
+func (s *rateLimitedEventService) PublishOrdered(topic string, orderingKey string, event *events.NitricEvent) error {
+	if err := s.limiter.wait("RateLimitedEventService.PublishOrdered", topic, 1); err != nil {
+		return err
+	}
+
+	return events.PublishOrdered(s.EventService, topic, orderingKey, event)
+}
+
+// PublishBatch - Counts each event in the batch against the topic's limit
+func (s *rateLimitedEventService) PublishBatch(topic string, evts []*events.NitricEvent) error {
+	if err := s.limiter.wait("RateLimitedEventService.PublishBatch", topic, len(evts)); err != nil {
+		return err
+	}
+
+	return s.EventService.PublishBatch(topic, evts)
+}
+
+// EventsWithRateLimit - Wraps an event plugin, limiting the rate events are published to each topic
+func EventsWithRateLimit(plugin events.EventService, policy *RateLimitPolicy) events.EventService {
+	return &rateLimitedEventService{
+		EventService: plugin,
+		limiter:      newRateLimiter(policy),
+	}
+}
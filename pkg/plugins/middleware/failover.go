@@ -0,0 +1,256 @@
+// Copyright 2021 Nitric Pty Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package middleware
+
+import (
+	"fmt"
+
+	"github.com/nitrictech/nitric/pkg/plugins/errors"
+	"github.com/nitrictech/nitric/pkg/plugins/errors/codes"
+	"github.com/nitrictech/nitric/pkg/plugins/events"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// The error codes failed over by default, each indicates the endpoint can't serve the call rather than a bad request
+var DefaultFailoverCodes = []codes.Code{
+	codes.Unavailable,
+	codes.Internal,
+}
+
+// FailoverPrimaryEndpoint - The endpoint name the primary plugin is recorded under
+const FailoverPrimaryEndpoint = "primary"
+
+// FailoverEndpoint - An event plugin calls fail over to, e.g. one publishing to another region
+type FailoverEndpoint struct {
+	// The name the endpoint is recorded under in metrics, e.g. its region
+	Name   string
+	Plugin events.EventService
+}
+
+// FailoverMetrics - Prometheus collectors for the endpoints calls are made to, a nil *FailoverMetrics records nothing
+type FailoverMetrics struct {
+	calls *prometheus.CounterVec
+}
+
+// observe - Records the outcome of a call to the named endpoint of the plugin
+func (m *FailoverMetrics) observe(plugin string, endpoint string, err error) {
+	if m == nil {
+		return
+	}
+
+	result := "success"
+	if err != nil {
+		result = "failure"
+	}
+
+	m.calls.WithLabelValues(plugin, endpoint, result).Inc()
+}
+
+// Register - Registers the collectors with the registerer
+func (m *FailoverMetrics) Register(registerer prometheus.Registerer) error {
+	return registerer.Register(m.calls)
+}
+
+// NewFailoverMetrics - Creates the failover collectors, they're registered separately
+// as plugins are wrapped before the metrics registry is created
+func NewFailoverMetrics() *FailoverMetrics {
+	return &FailoverMetrics{
+		calls: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "nitric",
+			Subsystem: "plugin",
+			Name:      "failover_calls_total",
+			Help:      "The number of plugin calls made to each primary and fallback endpoint, by result.",
+		}, []string{"plugin", "endpoint", "result"}),
+	}
+}
+
+// failoverEventService - Publishes to fallback event plugins when the primary can't be reached
+type failoverEventService struct {
+	// The primary endpoint, followed by the fallbacks in the order they're tried
+	endpoints []FailoverEndpoint
+	codes     []codes.Code
+	metrics   *FailoverMetrics
+}
+
+// shouldFailover - Returns true if the error's code indicates the call may succeed at another endpoint
+func (s *failoverEventService) shouldFailover(err error) bool {
+	code := errors.Code(err)
+	for _, c := range s.codes {
+		if code == c {
+			return true
+		}
+	}
+
+	return false
+}
+
+// do - Makes the call to each endpoint in turn, until one succeeds or fails with an error that isn't failed over
+func (s *failoverEventService) do(call func(plugin events.EventService) error) error {
+	var err error
+	for _, endpoint := range s.endpoints {
+		err = call(endpoint.Plugin)
+		s.metrics.observe("events", endpoint.Name, err)
+
+		if err == nil || !s.shouldFailover(err) {
+			return err
+		}
+	}
+
+	return err
+}
+
+func (s *failoverEventService) Publish(topic string, event *events.NitricEvent) error {
+	return s.do(func(plugin events.EventService) error {
+		return plugin.Publish(topic, event)
+	})
+}
+
+func (s *failoverEventService) PublishOrdered(topic string, orderingKey string, event *events.NitricEvent) error {
+	return s.do(func(plugin events.EventService) error {
+		return events.PublishOrdered(plugin, topic, orderingKey, event)
+	})
+}
+
+// PublishBatch - Publishes the batch, failing over only the events the endpoint didn't accept, so accepted events
+// aren't published twice. Events that fail at every endpoint are returned in a PartialPublishError if any were published
+func (s *failoverEventService) PublishBatch(topic string, evts []*events.NitricEvent) error {
+	pending := evts
+	// indices maps the pending events back to their position in the caller's batch
+	indices := make([]int, len(evts))
+	for i := range indices {
+		indices[i] = i
+	}
+	published := 0
+
+	var err error
+	for _, endpoint := range s.endpoints {
+		err = endpoint.Plugin.PublishBatch(topic, pending)
+		s.metrics.observe("events", endpoint.Name, err)
+
+		if err == nil {
+			return nil
+		}
+
+		partialErr, ok := events.AsPartialPublishError(err)
+		if !ok {
+			if !s.shouldFailover(err) {
+				break
+			}
+			continue
+		}
+
+		failed := make([]*events.NitricEvent, 0, len(partialErr.Failed))
+		failedIndices := make([]int, 0, len(partialErr.Failed))
+		for _, f := range partialErr.Failed {
+			if f.Index < 0 || f.Index >= len(pending) {
+				continue
+			}
+			failed = append(failed, pending[f.Index])
+			failedIndices = append(failedIndices, indices[f.Index])
+			// Report the failure against the caller's batch rather than the failed over subset
+			f.Index = indices[f.Index]
+		}
+
+		published += partialErr.Published
+		partialErr.Published = published
+		pending, indices = failed, failedIndices
+	}
+
+	if _, ok := events.AsPartialPublishError(err); ok || published == 0 {
+		return err
+	}
+
+	// Events were published before an endpoint failed the rest outright
+	failed := make([]*events.FailedEvent, 0, len(pending))
+	for i, evt := range pending {
+		failed = append(failed, &events.FailedEvent{
+			Index: indices[i],
+			ID:    evt.ID,
+			Err:   err,
+		})
+	}
+
+	return &events.PartialPublishError{
+		Published: published,
+		Failed:    failed,
+	}
+}
+
+func (s *failoverEventService) ListTopics() ([]string, error) {
+	var topics []string
+	err := s.do(func(plugin events.EventService) error {
+		var err error
+		topics, err = plugin.ListTopics()
+		return err
+	})
+
+	return topics, err
+}
+
+func (s *failoverEventService) ListTopicsWithPrefix(prefix string) ([]string, error) {
+	var topics []string
+	err := s.do(func(plugin events.EventService) error {
+		var err error
+		topics, err = plugin.ListTopicsWithPrefix(prefix)
+		return err
+	})
+
+	return topics, err
+}
+
+// Capabilities - Returns the capabilities supported by every endpoint, as any of them may handle a call
+func (s *failoverEventService) Capabilities() []string {
+	supported := s.endpoints[0].Plugin.Capabilities()
+	for _, endpoint := range s.endpoints[1:] {
+		other := make(map[string]bool)
+		for _, capability := range endpoint.Plugin.Capabilities() {
+			other[capability] = true
+		}
+
+		common := make([]string, 0, len(supported))
+		for _, capability := range supported {
+			if other[capability] {
+				common = append(common, capability)
+			}
+		}
+		supported = common
+	}
+
+	return supported
+}
+
+// Close - Closes every endpoint, returning the first error
+func (s *failoverEventService) Close() error {
+	var closeErr error
+	for _, endpoint := range s.endpoints {
+		if err := endpoint.Plugin.Close(); err != nil && closeErr == nil {
+			closeErr = fmt.Errorf("failed to close %s events endpoint: %v", endpoint.Name, err)
+		}
+	}
+
+	return closeErr
+}
+
+// EventsWithFailover - Wraps an event plugin, making calls that fail with Unavailable or Internal errors
+// to each fallback in turn. The endpoint each call was made to is recorded in the metrics
+func EventsWithFailover(primary events.EventService, fallbacks []FailoverEndpoint, metrics *FailoverMetrics) events.EventService {
+	endpoints := append([]FailoverEndpoint{{Name: FailoverPrimaryEndpoint, Plugin: primary}}, fallbacks...)
+
+	return &failoverEventService{
+		endpoints: endpoints,
+		codes:     DefaultFailoverCodes,
+		metrics:   metrics,
+	}
+}
@@ -0,0 +1,134 @@
+// Copyright 2021 Nitric Pty Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package middleware_test
+
+import (
+	"strings"
+
+	"github.com/nitrictech/nitric/pkg/plugins/errors/codes"
+	"github.com/nitrictech/nitric/pkg/plugins/events"
+	mock "github.com/nitrictech/nitric/pkg/plugins/events/mock"
+	"github.com/nitrictech/nitric/pkg/plugins/middleware"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Failover", func() {
+	var metrics *middleware.FailoverMetrics
+
+	BeforeEach(func() {
+		metrics = middleware.NewFailoverMetrics()
+	})
+
+	When("The primary fails with an unavailable error", func() {
+		It("Should publish to the fallback and record it", func() {
+			primary := &flakyEventService{failures: 1, code: codes.Unavailable}
+			fallback := &flakyEventService{}
+			plugin := middleware.EventsWithFailover(primary, []middleware.FailoverEndpoint{
+				{Name: "us-west-2", Plugin: fallback},
+			}, metrics)
+
+			err := plugin.Publish("test", &events.NitricEvent{ID: "1"})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(primary.published).To(BeEmpty())
+			Expect(fallback.published).To(Equal([]string{"1"}))
+
+			registry := prometheus.NewRegistry()
+			Expect(metrics.Register(registry)).To(Succeed())
+			Expect(testutil.GatherAndCompare(registry, strings.NewReader(`
+# HELP nitric_plugin_failover_calls_total The number of plugin calls made to each primary and fallback endpoint, by result.
+# TYPE nitric_plugin_failover_calls_total counter
+nitric_plugin_failover_calls_total{endpoint="primary",plugin="events",result="failure"} 1
+nitric_plugin_failover_calls_total{endpoint="us-west-2",plugin="events",result="success"} 1
+`))).To(Succeed())
+		})
+	})
+
+	When("Every endpoint fails with an internal error", func() {
+		It("Should cascade through the fallbacks in order and return the last error", func() {
+			primary := &flakyEventService{failures: 1, code: codes.Internal}
+			first := &flakyEventService{failures: 1, code: codes.Internal}
+			second := &flakyEventService{failures: 1, code: codes.Unavailable}
+			plugin := middleware.EventsWithFailover(primary, []middleware.FailoverEndpoint{
+				{Name: "first", Plugin: first},
+				{Name: "second", Plugin: second},
+			}, metrics)
+
+			err := plugin.Publish("test", &events.NitricEvent{ID: "1"})
+			Expect(err).Should(HaveOccurred())
+			Expect([]int{primary.attempts, first.attempts, second.attempts}).To(Equal([]int{1, 1, 1}))
+		})
+	})
+
+	When("The primary fails with an error that isn't failed over", func() {
+		It("Should return the error without calling the fallback", func() {
+			primary := &flakyEventService{failures: 1, code: codes.InvalidArgument}
+			fallback := &flakyEventService{}
+			plugin := middleware.EventsWithFailover(primary, []middleware.FailoverEndpoint{
+				{Name: "fallback", Plugin: fallback},
+			}, nil)
+
+			err := plugin.Publish("test", &events.NitricEvent{ID: "1"})
+			Expect(err).Should(HaveOccurred())
+			Expect(fallback.attempts).To(Equal(0))
+		})
+	})
+
+	When("A batch is partially published by the primary", func() {
+		It("Should only publish the failed events to the fallback", func() {
+			primary := &partialEventService{failures: 1, rejected: map[string]bool{"2": true}}
+			fallback := &partialEventService{}
+			plugin := middleware.EventsWithFailover(primary, []middleware.FailoverEndpoint{
+				{Name: "fallback", Plugin: fallback},
+			}, nil)
+
+			err := plugin.PublishBatch("test", []*events.NitricEvent{{ID: "1"}, {ID: "2"}, {ID: "3"}})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(primary.published).To(Equal([]string{"1", "3"}))
+			Expect(fallback.published).To(Equal([]string{"2"}))
+		})
+
+		It("Should report events that failed everywhere against the original batch", func() {
+			primary := &partialEventService{failures: 1, rejected: map[string]bool{"2": true}}
+			fallback := &partialEventService{failures: 1, rejected: map[string]bool{"2": true}}
+			plugin := middleware.EventsWithFailover(primary, []middleware.FailoverEndpoint{
+				{Name: "fallback", Plugin: fallback},
+			}, nil)
+
+			err := plugin.PublishBatch("test", []*events.NitricEvent{{ID: "1"}, {ID: "2"}, {ID: "3"}})
+			Expect(err).Should(HaveOccurred())
+
+			partialErr, ok := events.AsPartialPublishError(err)
+			Expect(ok).To(BeTrue())
+			Expect(partialErr.Published).To(Equal(2))
+			Expect(partialErr.FailedIndices()).To(Equal([]int{1}))
+		})
+	})
+
+	When("The endpoints support different capabilities", func() {
+		It("Should only report the capabilities supported by every endpoint", func() {
+			primary, _ := mock.New("test")
+			plugin := middleware.EventsWithFailover(primary, []middleware.FailoverEndpoint{
+				{Name: "fallback", Plugin: &flakyEventService{}},
+			}, nil)
+
+			Expect(primary.Capabilities()).To(ContainElement(events.CapabilityPublishBatch))
+			Expect(plugin.Capabilities()).To(Equal(events.BaselineCapabilities()))
+		})
+	})
+})
@@ -0,0 +1,374 @@
+// Copyright 2021 Nitric Pty Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package middleware
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/nitrictech/nitric/pkg/plugins/document"
+	"github.com/nitrictech/nitric/pkg/plugins/errors"
+	"github.com/nitrictech/nitric/pkg/plugins/errors/codes"
+	"github.com/nitrictech/nitric/pkg/plugins/events"
+)
+
+// The status of an outbox entry
+const (
+	OutboxStatusPending   = "pending"
+	OutboxStatusPublished = "published"
+	// Failed entries exhausted their attempts and aren't relayed again
+	OutboxStatusFailed = "failed"
+)
+
+// outboxTimeFormat - A fixed width timestamp, so entries sort in the order they were created
+const outboxTimeFormat = "2006-01-02T15:04:05.000000000Z"
+
+// OutboxPolicy - Governs how events written to the outbox are relayed to the event plugin
+type OutboxPolicy struct {
+	// The document collection events are written to
+	Collection string
+	// The time between polls of the outbox for pending events, events are also relayed as soon as they're written
+	PollInterval time.Duration
+	// The maximum number of pending events relayed each poll
+	BatchSize int
+	// The number of times an event is relayed before it's marked as failed, unlimited if 0
+	MaxAttempts int
+}
+
+// DefaultOutboxPolicy - Returns a policy relaying up to 100 events from the nitric-outbox collection every second
+func DefaultOutboxPolicy() *OutboxPolicy {
+	return &OutboxPolicy{
+		Collection:   "nitric-outbox",
+		PollInterval: time.Second,
+		BatchSize:    100,
+		MaxAttempts:  10,
+	}
+}
+
+// outboxEntry - An event written to the outbox, stored as a document
+type outboxEntry struct {
+	key         *document.Key
+	topic       string
+	orderingKey string
+	event       *events.NitricEvent
+	created     string
+	attempts    int
+}
+
+func (e *outboxEntry) content(status string, lastErr error) (map[string]interface{}, error) {
+	event, err := json.Marshal(e.event)
+	if err != nil {
+		return nil, err
+	}
+
+	content := map[string]interface{}{
+		"topic":       e.topic,
+		"orderingKey": e.orderingKey,
+		// Encoded, so the payload is stored as is by every document plugin
+		"event":    string(event),
+		"status":   status,
+		"created":  e.created,
+		"attempts": e.attempts,
+	}
+
+	if lastErr != nil {
+		content["error"] = lastErr.Error()
+	}
+
+	return content, nil
+}
+
+// outboxEntryFromDocument - Decodes an entry read from the outbox collection
+func outboxEntryFromDocument(doc *document.Document) (*outboxEntry, error) {
+	encoded, ok := doc.Content["event"].(string)
+	if !ok {
+		return nil, fmt.Errorf("outbox entry %s has no event", doc.Key.Id)
+	}
+
+	event := &events.NitricEvent{}
+	if err := json.Unmarshal([]byte(encoded), event); err != nil {
+		return nil, fmt.Errorf("outbox entry %s has an invalid event: %v", doc.Key.Id, err)
+	}
+
+	entry := &outboxEntry{
+		key:   doc.Key,
+		event: event,
+	}
+	entry.topic, _ = doc.Content["topic"].(string)
+	entry.orderingKey, _ = doc.Content["orderingKey"].(string)
+	entry.created, _ = doc.Content["created"].(string)
+
+	// Numbers are decoded as different types by different document plugins
+	switch attempts := doc.Content["attempts"].(type) {
+	case int:
+		entry.attempts = attempts
+	case int64:
+		entry.attempts = int(attempts)
+	case float64:
+		entry.attempts = int(attempts)
+	}
+
+	return entry, nil
+}
+
+// outboxEventService - Writes published events to an outbox collection, which a background relay
+// publishes to the event plugin. Events are published at least once, an event relayed just before
+// the process stops may be published again if it wasn't yet marked as published
+type outboxEventService struct {
+	events.EventService
+	documents document.DocumentService
+	policy    *OutboxPolicy
+
+	// Wakes the relay when events are written, so they're relayed without waiting for the next poll
+	wake     chan struct{}
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+}
+
+func (s *outboxEventService) collection() *document.Collection {
+	return &document.Collection{Name: s.policy.Collection}
+}
+
+// newEntry - Returns a new pending entry for the event
+func (s *outboxEventService) newEntry(topic string, orderingKey string, event *events.NitricEvent) *outboxEntry {
+	return &outboxEntry{
+		key: &document.Key{
+			Collection: s.collection(),
+			Id:         uuid.New().String(),
+		},
+		topic:       topic,
+		orderingKey: orderingKey,
+		event:       event,
+		created:     time.Now().UTC().Format(outboxTimeFormat),
+	}
+}
+
+func (s *outboxEventService) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (s *outboxEventService) write(topic string, orderingKey string, event *events.NitricEvent) error {
+	newErr := errors.ErrorsWithScope(
+		"OutboxEventService.Publish",
+		map[string]interface{}{
+			"topic": topic,
+		},
+	)
+
+	if event == nil {
+		return newErr(codes.InvalidArgument, "provide non-nil event", nil)
+	}
+
+	entry := s.newEntry(topic, orderingKey, event)
+	content, err := entry.content(OutboxStatusPending, nil)
+	if err != nil {
+		return newErr(codes.InvalidArgument, "error encoding event", err)
+	}
+
+	if err := s.documents.Set(entry.key, content); err != nil {
+		return newErr(errors.Code(err), "error writing event to the outbox", err)
+	}
+
+	s.notify()
+	return nil
+}
+
+// Publish - Writes the event to the outbox, it's published once relayed
+func (s *outboxEventService) Publish(topic string, event *events.NitricEvent) error {
+	return s.write(topic, "", event)
+}
+
+// PublishOrdered - Writes the ordered event to the outbox, events with the same ordering key are relayed in order
+func (s *outboxEventService) PublishOrdered(topic string, orderingKey string, event *events.NitricEvent) error {
+	if orderingKey == "" {
+		return events.PublishOrdered(s.EventService, topic, orderingKey, event)
+	}
+
+	return s.write(topic, orderingKey, event)
+}
+
+// PublishBatch - Writes the events to the outbox in a single batch,
+// events that couldn't be written are returned in a PartialPublishError
+func (s *outboxEventService) PublishBatch(topic string, evts []*events.NitricEvent) error {
+	newErr := errors.ErrorsWithScope(
+		"OutboxEventService.PublishBatch",
+		map[string]interface{}{
+			"topic": topic,
+		},
+	)
+
+	items := make([]*document.BatchSetItem, 0, len(evts))
+	for _, event := range evts {
+		if event == nil {
+			return newErr(codes.InvalidArgument, "provide non-nil events", nil)
+		}
+
+		entry := s.newEntry(topic, "", event)
+		content, err := entry.content(OutboxStatusPending, nil)
+		if err != nil {
+			return newErr(codes.InvalidArgument, "error encoding event", err)
+		}
+
+		items = append(items, &document.BatchSetItem{Key: entry.key, Content: content})
+	}
+
+	results, err := s.documents.SetBatch(items)
+	if err != nil {
+		return newErr(errors.Code(err), "error writing events to the outbox", err)
+	}
+
+	failed := make([]*events.FailedEvent, 0)
+	for i, result := range results {
+		if result.Err != nil {
+			failed = append(failed, &events.FailedEvent{Index: i, ID: evts[i].ID, Err: result.Err})
+		}
+	}
+
+	if len(failed) < len(evts) {
+		s.notify()
+	}
+
+	if len(failed) > 0 {
+		return &events.PartialPublishError{
+			Published: len(evts) - len(failed),
+			Failed:    failed,
+		}
+	}
+
+	return nil
+}
+
+// mark - Updates the status of a relayed entry
+func (s *outboxEventService) mark(entry *outboxEntry, status string, lastErr error) {
+	content, err := entry.content(status, lastErr)
+	if err == nil {
+		err = s.documents.Set(entry.key, content)
+	}
+
+	if err != nil {
+		fmt.Printf("error marking outbox entry %s as %s: %v\n", entry.key.Id, status, err)
+	}
+}
+
+// relay - Publishes the pending entries in the order they were created, marking each once published.
+// Entries after a failed entry with the same ordering key are left until the next poll, so they stay in order
+func (s *outboxEventService) relay() {
+	result, err := s.documents.Query(s.collection(), []document.QueryExpression{
+		{Operand: "status", Operator: "==", Value: OutboxStatusPending},
+	}, s.policy.BatchSize, nil)
+	if err != nil {
+		fmt.Printf("error reading pending events from the outbox: %v\n", err)
+		return
+	}
+
+	entries := make([]*outboxEntry, 0, len(result.Documents))
+	for i := range result.Documents {
+		entry, err := outboxEntryFromDocument(&result.Documents[i])
+		if err != nil {
+			fmt.Printf("error reading outbox entry: %v\n", err)
+			continue
+		}
+		entries = append(entries, entry)
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].created < entries[j].created
+	})
+
+	blocked := make(map[string]bool)
+	for _, entry := range entries {
+		if entry.orderingKey != "" && blocked[entry.orderingKey] {
+			continue
+		}
+
+		if entry.orderingKey != "" {
+			err = events.PublishOrdered(s.EventService, entry.topic, entry.orderingKey, entry.event)
+		} else {
+			err = s.EventService.Publish(entry.topic, entry.event)
+		}
+
+		if err == nil {
+			s.mark(entry, OutboxStatusPublished, nil)
+			continue
+		}
+
+		entry.attempts++
+		if s.policy.MaxAttempts > 0 && entry.attempts >= s.policy.MaxAttempts {
+			fmt.Printf("error relaying outbox entry %s to topic %s, giving up after %d attempts: %v\n", entry.key.Id, entry.topic, entry.attempts, err)
+			s.mark(entry, OutboxStatusFailed, err)
+			continue
+		}
+
+		if entry.orderingKey != "" {
+			blocked[entry.orderingKey] = true
+		}
+		s.mark(entry, OutboxStatusPending, err)
+	}
+}
+
+// run - Relays pending entries each poll, or as soon as entries are written, until the service is closed
+func (s *outboxEventService) run() {
+	defer close(s.done)
+
+	ticker := time.NewTicker(s.policy.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			s.relay()
+		case <-s.wake:
+			s.relay()
+		}
+	}
+}
+
+// Close - Stops the relay, then closes the event plugin. Entries still pending are relayed after the next start
+func (s *outboxEventService) Close() error {
+	s.stopOnce.Do(func() {
+		close(s.stop)
+	})
+	<-s.done
+
+	return s.EventService.Close()
+}
+
+// EventsWithOutbox - Wraps an event plugin, so published events are written to an outbox collection with the
+// document plugin and relayed to the event plugin in the background. Events written to the outbox aren't lost
+// if the process stops before they're published, at the cost of delivering them at least once
+func EventsWithOutbox(plugin events.EventService, documents document.DocumentService, policy *OutboxPolicy) events.EventService {
+	s := &outboxEventService{
+		EventService: plugin,
+		documents:    documents,
+		policy:       policy,
+		wake:         make(chan struct{}, 1),
+		stop:         make(chan struct{}),
+		done:         make(chan struct{}),
+	}
+
+	go s.run()
+
+	return s
+}
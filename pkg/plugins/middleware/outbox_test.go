@@ -0,0 +1,166 @@
+// Copyright 2021 Nitric Pty Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package middleware_test
+
+import (
+	"sync"
+	"time"
+
+	"github.com/nitrictech/nitric/pkg/plugins/document"
+	"github.com/nitrictech/nitric/pkg/plugins/errors"
+	"github.com/nitrictech/nitric/pkg/plugins/errors/codes"
+	"github.com/nitrictech/nitric/pkg/plugins/events"
+	mock "github.com/nitrictech/nitric/pkg/plugins/events/mock"
+	"github.com/nitrictech/nitric/pkg/plugins/middleware"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// outboxDocuments - A document plugin keeping a single collection in memory, supporting equality queries
+type outboxDocuments struct {
+	document.UnimplementedDocumentPlugin
+	lock sync.Mutex
+	docs map[string]map[string]interface{}
+}
+
+func (s *outboxDocuments) Set(key *document.Key, content map[string]interface{}) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.docs[key.Id] = content
+	return nil
+}
+
+func (s *outboxDocuments) SetBatch(items []*document.BatchSetItem) ([]*document.BatchResult, error) {
+	results := make([]*document.BatchResult, 0, len(items))
+	for _, item := range items {
+		results = append(results, &document.BatchResult{Key: item.Key, Err: s.Set(item.Key, item.Content)})
+	}
+	return results, nil
+}
+
+func (s *outboxDocuments) Query(collection *document.Collection, expressions []document.QueryExpression, limit int, pagingToken map[string]string) (*document.QueryResult, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	result := &document.QueryResult{}
+	for id, content := range s.docs {
+		matches := true
+		for _, exp := range expressions {
+			matches = matches && content[exp.Operand] == exp.Value
+		}
+
+		if matches && (limit == 0 || len(result.Documents) < limit) {
+			result.Documents = append(result.Documents, document.Document{
+				Key:     &document.Key{Collection: collection, Id: id},
+				Content: content,
+			})
+		}
+	}
+
+	return result, nil
+}
+
+// statuses - Returns the number of entries with each status
+func (s *outboxDocuments) statuses() map[string]int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	statuses := make(map[string]int)
+	for _, content := range s.docs {
+		statuses[content["status"].(string)]++
+	}
+	return statuses
+}
+
+// unavailableEventService - Fails every publish with an Unavailable error
+type unavailableEventService struct {
+	events.UnimplementedeventsPlugin
+}
+
+func (s *unavailableEventService) Publish(topic string, event *events.NitricEvent) error {
+	return errors.ErrorsWithScope("unavailableEventService.Publish", nil)(codes.Unavailable, "publish failed", nil)
+}
+
+var _ = Describe("Outbox", func() {
+	var documents *outboxDocuments
+	var policy *middleware.OutboxPolicy
+
+	BeforeEach(func() {
+		documents = &outboxDocuments{docs: make(map[string]map[string]interface{})}
+		policy = middleware.DefaultOutboxPolicy()
+		policy.PollInterval = 10 * time.Millisecond
+	})
+
+	When("Events are published", func() {
+		It("Should relay them to the event plugin and mark them as published", func() {
+			published, _ := mock.New()
+			plugin := middleware.EventsWithOutbox(published, documents, policy)
+			defer plugin.Close()
+
+			Expect(plugin.Publish("test", &events.NitricEvent{ID: "1", Payload: map[string]interface{}{"a": "b"}})).To(Succeed())
+			Expect(plugin.PublishBatch("test", []*events.NitricEvent{{ID: "2"}, {ID: "3"}})).To(Succeed())
+
+			Eventually(func() map[string]int {
+				return documents.statuses()
+			}).Should(Equal(map[string]int{middleware.OutboxStatusPublished: 3}))
+
+			ids := make([]string, 0)
+			for _, evt := range published.PublishedTo("test") {
+				ids = append(ids, evt.ID)
+			}
+			Expect(ids).To(ConsistOf("1", "2", "3"))
+		})
+	})
+
+	When("The process stops before the event plugin can be reached", func() {
+		It("Should relay the written events once it's restarted", func() {
+			policy.MaxAttempts = 0
+			plugin := middleware.EventsWithOutbox(&unavailableEventService{}, documents, policy)
+			Expect(plugin.Publish("test", &events.NitricEvent{ID: "1"})).To(Succeed())
+
+			Eventually(func() map[string]int {
+				return documents.statuses()
+			}).Should(Equal(map[string]int{middleware.OutboxStatusPending: 1}))
+			Expect(plugin.Close()).To(Succeed())
+
+			published, _ := mock.New()
+			restarted := middleware.EventsWithOutbox(published, documents, policy)
+			defer restarted.Close()
+
+			Eventually(func() int {
+				return len(published.PublishedTo("test"))
+			}).Should(Equal(1))
+			Eventually(func() map[string]int {
+				return documents.statuses()
+			}).Should(Equal(map[string]int{middleware.OutboxStatusPublished: 1}))
+		})
+	})
+
+	When("An event fails to relay more than the maximum attempts", func() {
+		It("Should mark it as failed", func() {
+			policy.MaxAttempts = 2
+			plugin := middleware.EventsWithOutbox(&unavailableEventService{}, documents, policy)
+			defer plugin.Close()
+
+			Expect(plugin.Publish("test", &events.NitricEvent{ID: "1"})).To(Succeed())
+
+			Eventually(func() map[string]int {
+				return documents.statuses()
+			}).Should(Equal(map[string]int{middleware.OutboxStatusFailed: 1}))
+		})
+	})
+})
@@ -0,0 +1,153 @@
+// Copyright 2021 Nitric Pty Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package middleware
+
+import (
+	"time"
+
+	"github.com/nitrictech/nitric/pkg/plugins/queue"
+)
+
+// retryingQueueService - Retries queue plugin calls that fail with transient errors
+type retryingQueueService struct {
+	queue.QueueService
+	policy *RetryPolicy
+}
+
+func (s *retryingQueueService) Send(queueName string, task queue.NitricTask) error {
+	return s.policy.do(func() error {
+		return s.QueueService.Send(queueName, task)
+	})
+}
+
+// SendBatch - Retries the batch when the call fails, tasks reported as failed in a successful response aren't retried
+func (s *retryingQueueService) SendBatch(queueName string, tasks []queue.NitricTask) (*queue.SendBatchResponse, error) {
+	var resp *queue.SendBatchResponse
+	err := s.policy.do(func() error {
+		var err error
+		resp, err = s.QueueService.SendBatch(queueName, tasks)
+		return err
+	})
+
+	return resp, err
+}
+
+func (s *retryingQueueService) Receive(options queue.ReceiveOptions) ([]queue.NitricTask, error) {
+	var tasks []queue.NitricTask
+	err := s.policy.do(func() error {
+		var err error
+		tasks, err = s.QueueService.Receive(options)
+		return err
+	})
+
+	return tasks, err
+}
+
+func (s *retryingQueueService) Complete(queueName string, leaseId string) error {
+	return s.policy.do(func() error {
+		return s.QueueService.Complete(queueName, leaseId)
+	})
+}
+
+// CompleteBatch - Retries the batch when the call fails, tasks reported as failed in a successful response aren't retried
+func (s *retryingQueueService) CompleteBatch(queueName string, leaseIds []string) (*queue.CompleteBatchResponse, error) {
+	var resp *queue.CompleteBatchResponse
+	err := s.policy.do(func() error {
+		var err error
+		resp, err = s.QueueService.CompleteBatch(queueName, leaseIds)
+		return err
+	})
+
+	return resp, err
+}
+
+func (s *retryingQueueService) LeaseExtend(queueName string, leaseId string, duration time.Duration) error {
+	return s.policy.do(func() error {
+		return s.QueueService.LeaseExtend(queueName, leaseId, duration)
+	})
+}
+
+// QueueWithRetry - Wraps a queue plugin, retrying calls that fail with transient errors
+func QueueWithRetry(plugin queue.QueueService, policy *RetryPolicy) queue.QueueService {
+	return &retryingQueueService{
+		QueueService: plugin,
+		policy:       policy,
+	}
+}
+
+// circuitBreakingQueueService - Fails queue plugin calls fast while the plugin is persistently failing
+type circuitBreakingQueueService struct {
+	queue.QueueService
+	breaker *circuitBreaker
+}
+
+func (s *circuitBreakingQueueService) Send(queueName string, task queue.NitricTask) error {
+	return s.breaker.do("CircuitBreakingQueueService.Send", func() error {
+		return s.QueueService.Send(queueName, task)
+	})
+}
+
+func (s *circuitBreakingQueueService) SendBatch(queueName string, tasks []queue.NitricTask) (*queue.SendBatchResponse, error) {
+	var resp *queue.SendBatchResponse
+	err := s.breaker.do("CircuitBreakingQueueService.SendBatch", func() error {
+		var err error
+		resp, err = s.QueueService.SendBatch(queueName, tasks)
+		return err
+	})
+
+	return resp, err
+}
+
+func (s *circuitBreakingQueueService) Receive(options queue.ReceiveOptions) ([]queue.NitricTask, error) {
+	var tasks []queue.NitricTask
+	err := s.breaker.do("CircuitBreakingQueueService.Receive", func() error {
+		var err error
+		tasks, err = s.QueueService.Receive(options)
+		return err
+	})
+
+	return tasks, err
+}
+
+func (s *circuitBreakingQueueService) Complete(queueName string, leaseId string) error {
+	return s.breaker.do("CircuitBreakingQueueService.Complete", func() error {
+		return s.QueueService.Complete(queueName, leaseId)
+	})
+}
+
+func (s *circuitBreakingQueueService) CompleteBatch(queueName string, leaseIds []string) (*queue.CompleteBatchResponse, error) {
+	var resp *queue.CompleteBatchResponse
+	err := s.breaker.do("CircuitBreakingQueueService.CompleteBatch", func() error {
+		var err error
+		resp, err = s.QueueService.CompleteBatch(queueName, leaseIds)
+		return err
+	})
+
+	return resp, err
+}
+
+func (s *circuitBreakingQueueService) LeaseExtend(queueName string, leaseId string, duration time.Duration) error {
+	return s.breaker.do("CircuitBreakingQueueService.LeaseExtend", func() error {
+		return s.QueueService.LeaseExtend(queueName, leaseId, duration)
+	})
+}
+
+// QueueWithCircuitBreaker - Wraps a queue plugin, failing calls fast while it is persistently failing
+func QueueWithCircuitBreaker(plugin queue.QueueService, policy *CircuitBreakerPolicy, metrics *CircuitBreakerMetrics) queue.QueueService {
+	return &circuitBreakingQueueService{
+		QueueService: plugin,
+		breaker:      newCircuitBreaker("queue", policy, metrics),
+	}
+}
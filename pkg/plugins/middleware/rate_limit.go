@@ -0,0 +1,253 @@
+// Copyright 2021 Nitric Pty Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package middleware
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nitrictech/nitric/pkg/plugins/errors"
+	"github.com/nitrictech/nitric/pkg/plugins/errors/codes"
+)
+
+// DefaultRateLimitTopic - The rate limit key applied to topics without their own limit
+const DefaultRateLimitTopic = "*"
+
+type RateLimitMode int
+
+const (
+	// RateLimitMode_Block waits until the limit allows the call
+	RateLimitMode_Block RateLimitMode = iota
+	// RateLimitMode_Reject fails calls over the limit with a ResourceExhausted error,
+	// batches larger than the burst fail with an InvalidArgument error as they can never be allowed
+	RateLimitMode_Reject
+)
+
+var rateLimitModes = [...]string{"BLOCK", "REJECT"}
+
+func (m RateLimitMode) String() string {
+	return rateLimitModes[m]
+}
+
+// RateLimitModeFromString - Returns the mode with the given name, e.g. BLOCK
+func RateLimitModeFromString(mode string) (RateLimitMode, error) {
+	for i, m := range rateLimitModes {
+		if strings.EqualFold(mode, m) {
+			return RateLimitMode(i), nil
+		}
+	}
+
+	return RateLimitMode_Block, fmt.Errorf("unknown rate limit mode %s, expected one of %s", mode, strings.Join(rateLimitModes[:], ", "))
+}
+
+// RateLimit - The sustained rate and burst of calls allowed
+type RateLimit struct {
+	// Calls per second
+	Rate float64
+	// The number of calls that may be made at once, defaults to the rate rounded up
+	Burst int
+}
+
+// burst - Returns the burst of the limit, at least one call
+func (l RateLimit) burst() float64 {
+	if l.Burst > 0 {
+		return float64(l.Burst)
+	}
+
+	return math.Max(1, math.Ceil(l.Rate))
+}
+
+// ParseRateLimits - Parses comma separated topic limits in the form topic=rate[:burst], e.g. orders=10:20,*=50
+func ParseRateLimits(value string) (map[string]RateLimit, error) {
+	limits := make(map[string]RateLimit)
+
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+			return nil, fmt.Errorf("invalid rate limit %s, expected topic=rate[:burst]", entry)
+		}
+
+		limitParts := strings.SplitN(parts[1], ":", 2)
+		rate, err := strconv.ParseFloat(strings.TrimSpace(limitParts[0]), 64)
+		if err != nil || rate <= 0 {
+			return nil, fmt.Errorf("invalid rate limit %s, expected a positive rate", entry)
+		}
+
+		limit := RateLimit{Rate: rate}
+		if len(limitParts) == 2 {
+			burst, err := strconv.Atoi(strings.TrimSpace(limitParts[1]))
+			if err != nil || burst < 1 {
+				return nil, fmt.Errorf("invalid rate limit %s, expected a positive burst", entry)
+			}
+			limit.Burst = burst
+		}
+
+		limits[strings.TrimSpace(parts[0])] = limit
+	}
+
+	return limits, nil
+}
+
+// RateLimitPolicy - Governs the rate calls are made to a plugin for each topic
+type RateLimitPolicy struct {
+	// Limits by topic name, the DefaultRateLimitTopic limit applies to other topics, which are unlimited without it
+	Limits map[string]RateLimit
+	// Whether calls over the limit wait or fail
+	Mode RateLimitMode
+}
+
+// limitFor - Returns the limit of a topic, false if the topic is unlimited
+func (p *RateLimitPolicy) limitFor(topic string) (RateLimit, bool) {
+	if limit, ok := p.Limits[topic]; ok {
+		return limit, true
+	}
+
+	limit, ok := p.Limits[DefaultRateLimitTopic]
+	return limit, ok
+}
+
+// tokenBucket - Allows calls at a sustained rate, with bursts up to its capacity
+type tokenBucket struct {
+	lock     sync.Mutex
+	rate     float64
+	capacity float64
+	tokens   float64
+	last     time.Time
+}
+
+func newTokenBucket(limit RateLimit) *tokenBucket {
+	return &tokenBucket{
+		rate:     limit.Rate,
+		capacity: limit.burst(),
+		tokens:   limit.burst(),
+		last:     time.Now(),
+	}
+}
+
+// refill - Adds the tokens accrued since the last refill, the lock must be held
+func (b *tokenBucket) refill(now time.Time) {
+	b.tokens = math.Min(b.capacity, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+}
+
+// take - Takes n tokens if they're available, returning false if they're not
+func (b *tokenBucket) take(n int) bool {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.refill(time.Now())
+
+	if b.tokens < float64(n) {
+		return false
+	}
+
+	b.tokens -= float64(n)
+	return true
+}
+
+// reserve - Takes n tokens, returning how long to wait until they would have been available.
+// Tokens are taken in advance so concurrent callers wait in turn rather than all at once
+func (b *tokenBucket) reserve(n int) time.Duration {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.refill(time.Now())
+	b.tokens -= float64(n)
+
+	if b.tokens >= 0 {
+		return 0
+	}
+
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// rateLimiter - Limits the rate of calls for each topic with a token bucket per topic
+type rateLimiter struct {
+	policy  *RateLimitPolicy
+	lock    sync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+func newRateLimiter(policy *RateLimitPolicy) *rateLimiter {
+	return &rateLimiter{
+		policy:  policy,
+		buckets: make(map[string]*tokenBucket),
+	}
+}
+
+// bucket - Returns the bucket of a topic, nil if the topic is unlimited
+func (l *rateLimiter) bucket(topic string) *tokenBucket {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if b, ok := l.buckets[topic]; ok {
+		return b
+	}
+
+	limit, ok := l.policy.limitFor(topic)
+	if !ok {
+		return nil
+	}
+
+	b := newTokenBucket(limit)
+	l.buckets[topic] = b
+	return b
+}
+
+// wait - Waits until n calls are allowed for the topic, or fails if the policy rejects calls over the limit
+func (l *rateLimiter) wait(scope string, topic string, n int) error {
+	b := l.bucket(topic)
+	if b == nil {
+		return nil
+	}
+
+	if l.policy.Mode == RateLimitMode_Reject {
+		// Waiting can't help a batch larger than the burst, so it's rejected as invalid rather than retried
+		if float64(n) > b.capacity {
+			return errors.ErrorsWithScope(scope, map[string]interface{}{
+				"topic": topic,
+				"calls": n,
+			})(
+				codes.InvalidArgument,
+				fmt.Sprintf("%d calls exceed the rate limit burst of %v for topic %s", n, b.capacity, topic),
+				nil,
+			)
+		}
+
+		if !b.take(n) {
+			return errors.ErrorsWithScope(scope, map[string]interface{}{
+				"topic": topic,
+			})(
+				codes.ResourceExhausted,
+				fmt.Sprintf("rate limit of %v per second exceeded for topic %s", b.rate, topic),
+				nil,
+			)
+		}
+
+		return nil
+	}
+
+	time.Sleep(b.reserve(n))
+	return nil
+}
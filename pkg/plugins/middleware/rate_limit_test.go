@@ -0,0 +1,161 @@
+// Copyright 2021 Nitric Pty Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package middleware_test
+
+import (
+	"time"
+
+	"github.com/nitrictech/nitric/pkg/plugins/errors"
+	"github.com/nitrictech/nitric/pkg/plugins/errors/codes"
+	"github.com/nitrictech/nitric/pkg/plugins/events"
+	"github.com/nitrictech/nitric/pkg/plugins/middleware"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// countingEventService - Counts the events published to each topic
+type countingEventService struct {
+	events.UnimplementedeventsPlugin
+	published map[string]int
+}
+
+func (s *countingEventService) Publish(topic string, event *events.NitricEvent) error {
+	s.published[topic]++
+	return nil
+}
+
+func (s *countingEventService) PublishBatch(topic string, evts []*events.NitricEvent) error {
+	s.published[topic] += len(evts)
+	return nil
+}
+
+var _ = Describe("Rate Limit", func() {
+	var counting *countingEventService
+
+	BeforeEach(func() {
+		counting = &countingEventService{published: make(map[string]int)}
+	})
+
+	Context("ParseRateLimits", func() {
+		When("Parsing valid limits", func() {
+			It("Should return the limit of each topic", func() {
+				limits, err := middleware.ParseRateLimits("orders=10:20, *=2.5")
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(limits).To(Equal(map[string]middleware.RateLimit{
+					"orders": {Rate: 10, Burst: 20},
+					"*":      {Rate: 2.5},
+				}))
+			})
+		})
+
+		When("A limit has no rate", func() {
+			It("Should return an error", func() {
+				_, err := middleware.ParseRateLimits("orders=")
+				Expect(err).Should(HaveOccurred())
+			})
+		})
+
+		When("A limit has an invalid burst", func() {
+			It("Should return an error", func() {
+				_, err := middleware.ParseRateLimits("orders=10:0")
+				Expect(err).Should(HaveOccurred())
+			})
+		})
+	})
+
+	Context("RateLimitMode_Reject", func() {
+		When("The burst of a topic is exceeded", func() {
+			It("Should reject publishes with ResourceExhausted", func() {
+				plugin := middleware.EventsWithRateLimit(counting, &middleware.RateLimitPolicy{
+					Limits: map[string]middleware.RateLimit{
+						"orders": {Rate: 1, Burst: 2},
+					},
+					Mode: middleware.RateLimitMode_Reject,
+				})
+
+				Expect(plugin.Publish("orders", &events.NitricEvent{})).To(Succeed())
+				Expect(plugin.Publish("orders", &events.NitricEvent{})).To(Succeed())
+
+				err := plugin.Publish("orders", &events.NitricEvent{})
+				Expect(err).Should(HaveOccurred())
+				Expect(errors.Code(err)).To(Equal(codes.ResourceExhausted))
+				Expect(counting.published["orders"]).To(Equal(2))
+
+				By("Not limiting topics without a limit")
+				for i := 0; i < 5; i++ {
+					Expect(plugin.Publish("payments", &events.NitricEvent{})).To(Succeed())
+				}
+			})
+		})
+
+		When("A batch exceeds the remaining tokens", func() {
+			It("Should reject the whole batch", func() {
+				plugin := middleware.EventsWithRateLimit(counting, &middleware.RateLimitPolicy{
+					Limits: map[string]middleware.RateLimit{
+						middleware.DefaultRateLimitTopic: {Rate: 1, Burst: 2},
+					},
+					Mode: middleware.RateLimitMode_Reject,
+				})
+
+				Expect(plugin.Publish("orders", &events.NitricEvent{})).To(Succeed())
+
+				err := plugin.PublishBatch("orders", []*events.NitricEvent{{}, {}})
+				Expect(errors.Code(err)).To(Equal(codes.ResourceExhausted))
+				Expect(counting.published["orders"]).To(Equal(1))
+			})
+		})
+
+		When("A batch is larger than the burst", func() {
+			It("Should reject the batch with InvalidArgument", func() {
+				plugin := middleware.EventsWithRateLimit(counting, &middleware.RateLimitPolicy{
+					Limits: map[string]middleware.RateLimit{
+						middleware.DefaultRateLimitTopic: {Rate: 1, Burst: 2},
+					},
+					Mode: middleware.RateLimitMode_Reject,
+				})
+
+				err := plugin.PublishBatch("orders", []*events.NitricEvent{{}, {}, {}})
+				Expect(errors.Code(err)).To(Equal(codes.InvalidArgument))
+				Expect(counting.published["orders"]).To(Equal(0))
+
+				By("Not taking tokens for the rejected batch")
+				Expect(plugin.PublishBatch("orders", []*events.NitricEvent{{}, {}})).To(Succeed())
+			})
+		})
+	})
+
+	Context("RateLimitMode_Block", func() {
+		When("The burst of a topic is exceeded", func() {
+			It("Should wait for tokens before publishing", func() {
+				plugin := middleware.EventsWithRateLimit(counting, &middleware.RateLimitPolicy{
+					Limits: map[string]middleware.RateLimit{
+						"orders": {Rate: 20, Burst: 1},
+					},
+					Mode: middleware.RateLimitMode_Block,
+				})
+
+				start := time.Now()
+				for i := 0; i < 3; i++ {
+					Expect(plugin.Publish("orders", &events.NitricEvent{})).To(Succeed())
+				}
+
+				// The first publish uses the burst, the next two wait 50ms each
+				Expect(time.Since(start)).To(BeNumerically(">=", 90*time.Millisecond))
+				Expect(counting.published["orders"]).To(Equal(3))
+			})
+		})
+	})
+})
@@ -0,0 +1,100 @@
+// Copyright 2021 Nitric Pty Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Middleware that wraps plugins to add behaviour common to every provider
+package middleware
+
+import (
+	"math/rand"
+	"time"
+
+	"github.com/nitrictech/nitric/pkg/plugins/errors"
+	"github.com/nitrictech/nitric/pkg/plugins/errors/codes"
+)
+
+// The error codes retried by default, each indicates a failure that may succeed if attempted again
+var DefaultRetryableCodes = []codes.Code{
+	codes.Unavailable,
+	codes.ResourceExhausted,
+	codes.DeadlineExceeded,
+}
+
+// RetryPolicy - Governs how plugin calls that fail with transient errors are retried
+type RetryPolicy struct {
+	// The number of times a failed call is retried, 0 disables retries
+	MaxRetries int
+	// The delay before the first retry, doubled for each subsequent retry
+	BaseBackoff time.Duration
+	// The maximum delay between retries, unlimited if 0
+	MaxBackoff time.Duration
+	// The error codes that are retried, defaults to DefaultRetryableCodes
+	RetryableCodes []codes.Code
+}
+
+// DefaultRetryPolicy - Returns a policy retrying transient errors 3 times, starting with a 100ms backoff
+func DefaultRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{
+		MaxRetries:  3,
+		BaseBackoff: 100 * time.Millisecond,
+		MaxBackoff:  5 * time.Second,
+	}
+}
+
+// isRetryable - Returns true if the error's code indicates it may succeed when retried
+func (p *RetryPolicy) isRetryable(err error) bool {
+	retryable := p.RetryableCodes
+	if len(retryable) == 0 {
+		retryable = DefaultRetryableCodes
+	}
+
+	code := errors.Code(err)
+	for _, c := range retryable {
+		if code == c {
+			return true
+		}
+	}
+
+	return false
+}
+
+// backoff - Returns the delay before the given retry, jittered so concurrent callers don't retry in lockstep
+func (p *RetryPolicy) backoff(retry int) time.Duration {
+	backoff := p.BaseBackoff
+	for i := 0; i < retry && (p.MaxBackoff == 0 || backoff < p.MaxBackoff); i++ {
+		backoff *= 2
+	}
+
+	if p.MaxBackoff > 0 && backoff > p.MaxBackoff {
+		backoff = p.MaxBackoff
+	}
+
+	if backoff <= 0 {
+		return 0
+	}
+
+	// Between half and the full backoff
+	return backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1))
+}
+
+// do - Calls fn, retrying it while it fails with a retryable error. The last error is returned
+func (p *RetryPolicy) do(fn func() error) error {
+	err := fn()
+
+	for retry := 0; err != nil && retry < p.MaxRetries && p.isRetryable(err); retry++ {
+		time.Sleep(p.backoff(retry))
+		err = fn()
+	}
+
+	return err
+}
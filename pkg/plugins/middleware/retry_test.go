@@ -0,0 +1,173 @@
+// Copyright 2021 Nitric Pty Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package middleware_test
+
+import (
+	"time"
+
+	"github.com/nitrictech/nitric/pkg/plugins/errors"
+	"github.com/nitrictech/nitric/pkg/plugins/errors/codes"
+	"github.com/nitrictech/nitric/pkg/plugins/events"
+	"github.com/nitrictech/nitric/pkg/plugins/middleware"
+	"github.com/nitrictech/nitric/pkg/plugins/storage"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// flakyEventService - Fails publishes with the given code until it has failed the given number of times
+type flakyEventService struct {
+	events.UnimplementedeventsPlugin
+	failures  int
+	code      codes.Code
+	attempts  int
+	published []string
+}
+
+func (s *flakyEventService) Publish(topic string, event *events.NitricEvent) error {
+	s.attempts++
+	if s.attempts <= s.failures {
+		return errors.ErrorsWithScope("flakyEventService.Publish", nil)(s.code, "publish failed", nil)
+	}
+
+	s.published = append(s.published, event.ID)
+	return nil
+}
+
+// partialEventService - Rejects the given event IDs from batches until it has failed the given number of times
+type partialEventService struct {
+	events.UnimplementedeventsPlugin
+	failures  int
+	rejected  map[string]bool
+	attempts  int
+	published []string
+}
+
+func (s *partialEventService) PublishBatch(topic string, evts []*events.NitricEvent) error {
+	s.attempts++
+	partialErr := &events.PartialPublishError{}
+	for i, evt := range evts {
+		if s.attempts <= s.failures && s.rejected[evt.ID] {
+			partialErr.Failed = append(partialErr.Failed, &events.FailedEvent{Index: i, ID: evt.ID, StatusCode: 429})
+			continue
+		}
+		s.published = append(s.published, evt.ID)
+		partialErr.Published++
+	}
+
+	if len(partialErr.Failed) > 0 {
+		return errors.ErrorsWithScope("partialEventService.PublishBatch", nil)(codes.ResourceExhausted, "publish failed", partialErr)
+	}
+	return nil
+}
+
+// flakyStorageService - Fails reads with the given code until it has failed the given number of times
+type flakyStorageService struct {
+	storage.UnimplementedStoragePlugin
+	failures int
+	code     codes.Code
+	attempts int
+}
+
+func (s *flakyStorageService) Read(bucket string, key string) ([]byte, error) {
+	s.attempts++
+	if s.attempts <= s.failures {
+		return nil, errors.ErrorsWithScope("flakyStorageService.Read", nil)(s.code, "read failed", nil)
+	}
+
+	return []byte("object"), nil
+}
+
+var _ = Describe("Retry", func() {
+	policy := &middleware.RetryPolicy{
+		MaxRetries:  3,
+		BaseBackoff: time.Millisecond,
+	}
+
+	When("A call fails with a transient error", func() {
+		It("Should retry the call until it succeeds", func() {
+			flaky := &flakyEventService{failures: 2, code: codes.Unavailable}
+			plugin := middleware.EventsWithRetry(flaky, policy)
+
+			err := plugin.Publish("test", &events.NitricEvent{ID: "1234"})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(flaky.attempts).To(Equal(3))
+			Expect(flaky.published).To(Equal([]string{"1234"}))
+		})
+	})
+
+	When("A call continues to fail with a transient error", func() {
+		It("Should return the error after the maximum retries", func() {
+			flaky := &flakyStorageService{failures: 10, code: codes.ResourceExhausted}
+			plugin := middleware.StorageWithRetry(flaky, policy)
+
+			_, err := plugin.Read("bucket", "key")
+			Expect(err).Should(HaveOccurred())
+			Expect(errors.Code(err)).To(Equal(codes.ResourceExhausted))
+			Expect(flaky.attempts).To(Equal(4))
+		})
+	})
+
+	When("A call fails with an error that isn't transient", func() {
+		It("Should return the error without retrying", func() {
+			flaky := &flakyStorageService{failures: 1, code: codes.NotFound}
+			plugin := middleware.StorageWithRetry(flaky, policy)
+
+			_, err := plugin.Read("bucket", "key")
+			Expect(err).Should(HaveOccurred())
+			Expect(flaky.attempts).To(Equal(1))
+		})
+	})
+
+	When("The policy lists the retryable codes", func() {
+		It("Should only retry those codes", func() {
+			flaky := &flakyStorageService{failures: 1, code: codes.Internal}
+			plugin := middleware.StorageWithRetry(flaky, &middleware.RetryPolicy{
+				MaxRetries:     1,
+				RetryableCodes: []codes.Code{codes.Internal},
+			})
+
+			object, err := plugin.Read("bucket", "key")
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(object).To(Equal([]byte("object")))
+			Expect(flaky.attempts).To(Equal(2))
+		})
+	})
+
+	When("A batch is partially published", func() {
+		It("Should only retry the failed events", func() {
+			partial := &partialEventService{failures: 1, rejected: map[string]bool{"2": true}}
+			plugin := middleware.EventsWithRetry(partial, policy)
+
+			err := plugin.PublishBatch("test", []*events.NitricEvent{{ID: "1"}, {ID: "2"}, {ID: "3"}})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(partial.attempts).To(Equal(2))
+			Expect(partial.published).To(Equal([]string{"1", "3", "2"}))
+		})
+
+		It("Should report the failures against the original batch", func() {
+			partial := &partialEventService{failures: 10, rejected: map[string]bool{"2": true}}
+			plugin := middleware.EventsWithRetry(partial, policy)
+
+			err := plugin.PublishBatch("test", []*events.NitricEvent{{ID: "1"}, {ID: "2"}, {ID: "3"}})
+			Expect(err).Should(HaveOccurred())
+
+			partialErr, ok := events.AsPartialPublishError(err)
+			Expect(ok).To(BeTrue())
+			Expect(partialErr.Published).To(Equal(2))
+			Expect(partialErr.FailedIndices()).To(Equal([]int{1}))
+		})
+	})
+})
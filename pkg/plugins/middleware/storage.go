@@ -0,0 +1,119 @@
+// Copyright 2021 Nitric Pty Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package middleware
+
+import "github.com/nitrictech/nitric/pkg/plugins/storage"
+
+// retryingStorageService - Retries storage plugin calls that fail with transient errors
+type retryingStorageService struct {
+	storage.StorageService
+	policy *RetryPolicy
+}
+
+func (s *retryingStorageService) Read(bucket string, key string) ([]byte, error) {
+	var object []byte
+	err := s.policy.do(func() error {
+		var err error
+		object, err = s.StorageService.Read(bucket, key)
+		return err
+	})
+
+	return object, err
+}
+
+func (s *retryingStorageService) ReadRange(bucket string, key string, start int64, end int64) ([]byte, error) {
+	var object []byte
+	err := s.policy.do(func() error {
+		var err error
+		object, err = s.StorageService.ReadRange(bucket, key, start, end)
+		return err
+	})
+
+	return object, err
+}
+
+func (s *retryingStorageService) Write(bucket string, key string, object []byte) error {
+	return s.policy.do(func() error {
+		return s.StorageService.Write(bucket, key, object)
+	})
+}
+
+func (s *retryingStorageService) WriteWithMetadata(bucket string, key string, object []byte, metadata map[string]string) error {
+	return s.policy.do(func() error {
+		return s.StorageService.WriteWithMetadata(bucket, key, object, metadata)
+	})
+}
+
+func (s *retryingStorageService) GetMetadata(bucket string, key string) (map[string]string, error) {
+	var metadata map[string]string
+	err := s.policy.do(func() error {
+		var err error
+		metadata, err = s.StorageService.GetMetadata(bucket, key)
+		return err
+	})
+	return metadata, err
+}
+
+func (s *retryingStorageService) Delete(bucket string, key string) error {
+	return s.policy.do(func() error {
+		return s.StorageService.Delete(bucket, key)
+	})
+}
+
+func (s *retryingStorageService) PreSignUrl(bucket string, key string, operation storage.Operation, expiry uint32) (string, error) {
+	var url string
+	err := s.policy.do(func() error {
+		var err error
+		url, err = s.StorageService.PreSignUrl(bucket, key, operation, expiry)
+		return err
+	})
+
+	return url, err
+}
+
+func (s *retryingStorageService) ListFiles(bucket string, prefix string) ([]*storage.FileInfo, error) {
+	var files []*storage.FileInfo
+	err := s.policy.do(func() error {
+		var err error
+		files, err = s.StorageService.ListFiles(bucket, prefix)
+		return err
+	})
+
+	return files, err
+}
+
+// ListFilesStream - Retries reading each file, iterators refetch the page that failed when they're called again
+func (s *retryingStorageService) ListFilesStream(bucket string, prefix string) storage.FileIterator {
+	next := s.StorageService.ListFilesStream(bucket, prefix)
+
+	return func() (*storage.FileInfo, error) {
+		var file *storage.FileInfo
+		err := s.policy.do(func() error {
+			var err error
+			file, err = next()
+			return err
+		})
+
+		return file, err
+	}
+}
+
+// StorageWithRetry - Wraps a storage plugin, retrying calls that fail with transient errors
+func StorageWithRetry(plugin storage.StorageService, policy *RetryPolicy) storage.StorageService {
+	return &retryingStorageService{
+		StorageService: plugin,
+		policy:         policy,
+	}
+}
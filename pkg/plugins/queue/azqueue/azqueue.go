@@ -39,7 +39,10 @@ import (
 const defaultVisibilityTimeout = 30 * time.Second
 
 type AzqueueQueueService struct {
+	queue.UnimplementedQueuePlugin
 	client azqueueserviceiface.AzqueueServiceUrlIface
+	// Encodes tasks as message text
+	codec queue.Codec
 }
 
 // Returns an adapted azqueue MessagesUrl, which is a client for interacting with messages in a specific queue
@@ -68,7 +71,7 @@ func (s *AzqueueQueueService) Send(queue string, task queue.NitricTask) error {
 	messages := s.getMessagesUrl(queue)
 
 	// Send the tasks to the queue
-	if taskBytes, err := json.Marshal(task); err == nil {
+	if taskBytes, err := s.codec.Marshal(&task); err == nil {
 		ctx := context.TODO()
 		if _, err := messages.Enqueue(ctx, string(taskBytes), 0, 0); err != nil {
 			return newErr(
@@ -151,10 +154,24 @@ func (s *AzqueueQueueService) Receive(options queue.ReceiveOptions) ([]queue.Nit
 		)
 	}
 
+	// Messages can't be filtered when dequeued, and unmatched messages can't be released using the queue client
+	if len(options.Filter) > 0 {
+		return nil, newErr(
+			codes.Unimplemented,
+			"receive filters are not supported by Azure Storage Queues",
+			nil,
+		)
+	}
+
 	messages := s.getMessagesUrl(options.QueueName)
 
+	visibilityTimeout := defaultVisibilityTimeout
+	if options.VisibilityTimeout > 0 {
+		visibilityTimeout = options.VisibilityTimeout
+	}
+
 	ctx := context.TODO()
-	dequeueResp, err := messages.Dequeue(ctx, int32(*options.Depth), defaultVisibilityTimeout)
+	dequeueResp, err := messages.Dequeue(ctx, int32(*options.Depth), visibilityTimeout)
 	if err != nil {
 		return nil, newErr(
 			codes.Internal,
@@ -172,7 +189,7 @@ func (s *AzqueueQueueService) Receive(options queue.ReceiveOptions) ([]queue.Nit
 	for i := int32(0); i < dequeueResp.NumMessages(); i++ {
 		m := dequeueResp.Message(i)
 		var nitricTask queue.NitricTask
-		err := json.Unmarshal([]byte(m.Text), &nitricTask)
+		err := s.codec.Unmarshal([]byte(m.Text), &nitricTask)
 		if err != nil {
 			// TODO: append error to error list and Nack the message.
 			continue
@@ -196,6 +213,7 @@ func (s *AzqueueQueueService) Receive(options queue.ReceiveOptions) ([]queue.Nit
 			ID:          nitricTask.ID,
 			Payload:     nitricTask.Payload,
 			PayloadType: nitricTask.PayloadType,
+			Attributes:  nitricTask.Attributes,
 			LeaseID:     leaseID,
 		})
 	}
@@ -204,11 +222,11 @@ func (s *AzqueueQueueService) Receive(options queue.ReceiveOptions) ([]queue.Nit
 }
 
 // Complete - Completes a previously popped queue item
-func (s *AzqueueQueueService) Complete(queue string, leaseId string) error {
+func (s *AzqueueQueueService) Complete(q string, leaseId string) error {
 	newErr := errors.ErrorsWithScope(
 		"AzqueueQueueService.Complete",
 		map[string]interface{}{
-			"queue":   queue,
+			"queue":   q,
 			"leaseId": leaseId,
 		},
 	)
@@ -223,20 +241,60 @@ func (s *AzqueueQueueService) Complete(queue string, leaseId string) error {
 	}
 
 	// Client for the specific message referenced by the lease
-	task := s.getMessageIdUrl(queue, azqueue.MessageID(lease.ID))
+	task := s.getMessageIdUrl(q, azqueue.MessageID(lease.ID))
 	ctx := context.TODO()
 	_, err = task.Delete(ctx, azqueue.PopReceipt(lease.PopReceipt))
 	if err != nil {
+		reason := completeFailure(err)
 		return newErr(
-			codes.Internal,
+			reason.Code(),
 			"failed to complete task",
-			err,
+			queue.NewCompleteError(reason, leaseId, err),
 		)
 	}
 
 	return nil
 }
 
+// completeFailure - Classifies an error deleting a message. A message that can't be found was already deleted,
+// while a pop receipt that doesn't match means the message was received again after the lease expired
+func completeFailure(err error) queue.CompleteFailure {
+	if storageErr, ok := err.(azqueue.StorageError); ok {
+		switch storageErr.ServiceCode() {
+		case azqueue.ServiceCodeMessageNotFound:
+			return queue.CompleteFailure_AlreadyCompleted
+		case azqueue.ServiceCodePopReceiptMismatch:
+			return queue.CompleteFailure_LeaseExpired
+		}
+	}
+
+	return queue.CompleteFailure_Transient
+}
+
+// CompleteBatch - Azure Storage Queues delete messages individually, so each task is completed in turn
+func (s *AzqueueQueueService) CompleteBatch(q string, leaseIds []string) (*queue.CompleteBatchResponse, error) {
+	return queue.CompleteEach(s, q, leaseIds), nil
+}
+
+// LeaseExtend - Azure Storage Queues issue a new pop receipt whenever a message's visibility is updated,
+// which would invalidate the lease id held by the receiver, so leases can't be extended
+func (s *AzqueueQueueService) LeaseExtend(queue string, leaseId string, duration time.Duration) error {
+	newErr := errors.ErrorsWithScope(
+		"AzqueueQueueService.LeaseExtend",
+		map[string]interface{}{
+			"queue":    queue,
+			"leaseId":  leaseId,
+			"duration": duration.String(),
+		},
+	)
+
+	return newErr(
+		codes.Unimplemented,
+		"lease extension is not supported by Azure Storage Queues, set a visibility timeout when receiving instead",
+		nil,
+	)
+}
+
 const expiryBuffer = 2 * time.Minute
 
 func tokenRefresherFromSpt(spt *adal.ServicePrincipalToken) azqueue.TokenRefresher {
@@ -255,7 +313,16 @@ func tokenRefresherFromSpt(spt *adal.ServicePrincipalToken) azqueue.TokenRefresh
 	}
 }
 
-// New - Constructs a new Azure Storage Queues client with defaults
+// Capabilities - Returns the baseline capabilities along with the optional operations the plugin supports
+func (s *AzqueueQueueService) Capabilities() []string {
+	return append(queue.BaselineCapabilities(),
+		queue.CapabilityCompleteBatch,
+		queue.CapabilityLeaseExtend,
+		queue.CapabilityVisibilityTimeout,
+	)
+}
+
+// New - Constructs a new Azure Storage Queues client with defaults, encoding tasks with the QUEUE_CODEC codec
 func New() (queue.QueueService, error) {
 	queueUrl := utils.GetEnv(azureutils.AZURE_STORAGE_QUEUE_ENDPOINT, "")
 	if queueUrl == "" {
@@ -277,13 +344,23 @@ func New() (queue.QueueService, error) {
 	pipeline := azqueue.NewPipeline(cTkn, azqueue.PipelineOptions{})
 	client := azqueue.NewServiceURL(*accountURL, pipeline)
 
-	return &AzqueueQueueService{
-		client: azqueueserviceiface.AdaptServiceUrl(client),
-	}, nil
+	codec, err := queue.CodecFromEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	return NewWithClient(azqueueserviceiface.AdaptServiceUrl(client), WithCodec(codec)), nil
 }
 
-func NewWithClient(client azqueueserviceiface.AzqueueServiceUrlIface) queue.QueueService {
-	return &AzqueueQueueService{
+func NewWithClient(client azqueueserviceiface.AzqueueServiceUrlIface, opts ...AzqueueQueueServiceOption) queue.QueueService {
+	s := &AzqueueQueueService{
 		client: client,
+		codec:  &queue.JSONCodec{},
+	}
+
+	for _, o := range opts {
+		o.Apply(s)
 	}
+
+	return s
 }
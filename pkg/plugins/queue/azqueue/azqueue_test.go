@@ -40,6 +40,7 @@ var _ = Describe("Azqueue", func() {
 
 			queuePlugin := &AzqueueQueueService{
 				client: mockAzqueue,
+				codec:  &queue.JSONCodec{},
 			}
 
 			It("should successfully send the queue item(s)", func() {
@@ -77,6 +78,7 @@ var _ = Describe("Azqueue", func() {
 
 			queuePlugin := &AzqueueQueueService{
 				client: mockAzqueue,
+				codec:  &queue.JSONCodec{},
 			}
 
 			It("should successfully send the queue item(s)", func() {
@@ -116,6 +118,7 @@ var _ = Describe("Azqueue", func() {
 
 			queuePlugin := &AzqueueQueueService{
 				client: mockAzqueue,
+				codec:  &queue.JSONCodec{},
 			}
 
 			It("should successfully send the queue item(s)", func() {
@@ -157,6 +160,7 @@ var _ = Describe("Azqueue", func() {
 
 			queuePlugin := &AzqueueQueueService{
 				client: mockAzqueue,
+				codec:  &queue.JSONCodec{},
 			}
 
 			It("should successfully send the queue item(s)", func() {
@@ -202,6 +206,7 @@ var _ = Describe("Azqueue", func() {
 
 			queuePlugin := &AzqueueQueueService{
 				client: mockAzqueue,
+				codec:  &queue.JSONCodec{},
 			}
 
 			It("should successfully send the queue item(s)", func() {
@@ -257,6 +262,7 @@ var _ = Describe("Azqueue", func() {
 
 			queuePlugin := &AzqueueQueueService{
 				client: mockAzqueue,
+				codec:  &queue.JSONCodec{},
 			}
 
 			It("should successfully send the queue item(s)", func() {
@@ -299,6 +305,7 @@ var _ = Describe("Azqueue", func() {
 
 			queuePlugin := &AzqueueQueueService{
 				client: mockAzqueue,
+				codec:  &queue.JSONCodec{},
 			}
 
 			It("should successfully send the queue item(s)", func() {
@@ -337,6 +344,7 @@ var _ = Describe("Azqueue", func() {
 
 			queuePlugin := &AzqueueQueueService{
 				client: mockAzqueue,
+				codec:  &queue.JSONCodec{},
 			}
 
 			It("should successfully send the queue item(s)", func() {
@@ -361,6 +369,11 @@ var _ = Describe("Azqueue", func() {
 				By("Returning an error")
 				Expect(err).To(HaveOccurred())
 
+				By("Classifying the failure as transient")
+				completeErr, ok := queue.AsCompleteError(err)
+				Expect(ok).To(BeTrue())
+				Expect(completeErr.Reason).To(Equal(queue.CompleteFailure_Transient))
+
 				crtl.Finish()
 			})
 		})
@@ -0,0 +1,303 @@
+// Copyright 2021 Nitric Pty Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Cloud Tasks pushes tasks to an HTTP target rather than being polled, so this plugin only supports
+// sending tasks. Each task is delivered as a POST of the JSON encoded NitricTask to the configured target,
+// normally the function's own Cloud Run service, where the gateway dispatches it to the function as a trigger.
+// A successful response completes the task, any other response causes Cloud Tasks to retry it
+// according to the queue's retry configuration.
+package cloudtasks_queue_service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"time"
+
+	cloudtasks "cloud.google.com/go/cloudtasks/apiv2"
+	"github.com/googleapis/gax-go/v2"
+	"github.com/nitrictech/nitric/pkg/plugins/errors"
+	"github.com/nitrictech/nitric/pkg/plugins/errors/codes"
+	"github.com/nitrictech/nitric/pkg/plugins/queue"
+	"github.com/nitrictech/nitric/pkg/utils"
+	"golang.org/x/oauth2/google"
+	taskspb "google.golang.org/genproto/googleapis/cloud/tasks/v2"
+	grpccodes "google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	CLOUDTASKS_LOCATION_ENV        = "CLOUDTASKS_LOCATION"
+	CLOUDTASKS_TARGET_URL_ENV      = "CLOUDTASKS_TARGET_URL"
+	CLOUDTASKS_SERVICE_ACCOUNT_ENV = "CLOUDTASKS_SERVICE_ACCOUNT"
+)
+
+// Task IDs that are valid Cloud Tasks task names, these are used to deduplicate sends
+var taskIdPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,500}$`)
+
+// The message returned for operations Cloud Tasks doesn't support
+const pushDeliveryMessage = "Cloud Tasks pushes tasks to the function as triggers, tasks can't be received, completed or leased"
+
+// CloudTasksClient - iface that exposes utilized subset of generated Cloud Tasks Client
+// Used with gomock to assert create client -> service interaction in unit tests
+type CloudTasksClient interface {
+	CreateTask(context.Context, *taskspb.CreateTaskRequest, ...gax.CallOption) (*taskspb.Task, error)
+}
+
+type CloudTasksQueueService struct {
+	queue.UnimplementedQueuePlugin
+	client    CloudTasksClient
+	projectId string
+	location  string
+	// The URL tasks are pushed to
+	targetUrl string
+	// The service account used to sign OIDC tokens for the target, tasks are unauthenticated when empty
+	serviceAccount string
+}
+
+// queuePath - Returns the Cloud Tasks queue path for a nitric queue name
+func (s *CloudTasksQueueService) queuePath(queue string) string {
+	return fmt.Sprintf("projects/%s/locations/%s/queues/%s", s.projectId, s.location, queue)
+}
+
+// errorCode - Maps a Cloud Tasks API error to a nitric error code
+func errorCode(err error) codes.Code {
+	switch status.Code(err) {
+	case grpccodes.NotFound:
+		return codes.NotFound
+	case grpccodes.PermissionDenied:
+		return codes.PermissionDenied
+	case grpccodes.Unauthenticated:
+		return codes.Unauthenticated
+	case grpccodes.ResourceExhausted:
+		return codes.ResourceExhausted
+	case grpccodes.Unavailable:
+		return codes.Unavailable
+	case grpccodes.DeadlineExceeded:
+		return codes.DeadlineExceeded
+	case grpccodes.InvalidArgument:
+		return codes.InvalidArgument
+	case grpccodes.FailedPrecondition:
+		return codes.FailedPrecondition
+	default:
+		return codes.Internal
+	}
+}
+
+// newTask - Creates a task that pushes the nitric task to the target
+func (s *CloudTasksQueueService) newTask(queue string, task queue.NitricTask) (*taskspb.Task, error) {
+	body, err := json.Marshal(task)
+	if err != nil {
+		return nil, err
+	}
+
+	httpRequest := &taskspb.HttpRequest{
+		Url:        s.targetUrl,
+		HttpMethod: taskspb.HttpMethod_POST,
+		Headers: map[string]string{
+			"Content-Type": "application/json",
+		},
+		Body: body,
+	}
+
+	if s.serviceAccount != "" {
+		httpRequest.AuthorizationHeader = &taskspb.HttpRequest_OidcToken{
+			OidcToken: &taskspb.OidcToken{
+				ServiceAccountEmail: s.serviceAccount,
+			},
+		}
+	}
+
+	t := &taskspb.Task{
+		MessageType: &taskspb.Task_HttpRequest{
+			HttpRequest: httpRequest,
+		},
+	}
+
+	// Naming the task after its ID lets Cloud Tasks reject duplicate sends
+	if taskIdPattern.MatchString(task.ID) {
+		t.Name = fmt.Sprintf("%s/tasks/%s", s.queuePath(queue), task.ID)
+	}
+
+	return t, nil
+}
+
+// send - Creates a task on the queue, tasks that have already been sent are treated as sent
+func (s *CloudTasksQueueService) send(queue string, task queue.NitricTask) (codes.Code, string, error) {
+	t, err := s.newTask(queue, task)
+	if err != nil {
+		return codes.InvalidArgument, "error marshalling task", err
+	}
+
+	_, err = s.client.CreateTask(context.TODO(), &taskspb.CreateTaskRequest{
+		Parent: s.queuePath(queue),
+		Task:   t,
+	})
+
+	if err != nil && status.Code(err) != grpccodes.AlreadyExists {
+		return errorCode(err), "error creating task", err
+	}
+
+	return codes.OK, "", nil
+}
+
+func (s *CloudTasksQueueService) Send(queue string, task queue.NitricTask) error {
+	newErr := errors.ErrorsWithScope(
+		"CloudTasksQueueService.Send",
+		map[string]interface{}{
+			"queue": queue,
+			"task":  task,
+		},
+	)
+
+	if code, msg, err := s.send(queue, task); err != nil {
+		return newErr(code, msg, err)
+	}
+
+	return nil
+}
+
+func (s *CloudTasksQueueService) SendBatch(q string, tasks []queue.NitricTask) (*queue.SendBatchResponse, error) {
+	newErr := errors.ErrorsWithScope(
+		"CloudTasksQueueService.SendBatch",
+		map[string]interface{}{
+			"queue":     q,
+			"tasks.len": len(tasks),
+		},
+	)
+
+	failedTasks := make([]*queue.FailedTask, 0)
+
+	for i, task := range tasks {
+		if code, msg, err := s.send(q, task); err != nil {
+			// Fail the whole batch if the queue can't be used at all
+			if code == codes.NotFound || code == codes.PermissionDenied || code == codes.Unauthenticated {
+				return nil, newErr(code, msg, err)
+			}
+
+			failedTasks = append(failedTasks, &queue.FailedTask{
+				Task:    &tasks[i],
+				Message: fmt.Sprintf("%s: %v", msg, err),
+			})
+		}
+	}
+
+	return &queue.SendBatchResponse{
+		FailedTasks: failedTasks,
+	}, nil
+}
+
+func (s *CloudTasksQueueService) Receive(options queue.ReceiveOptions) ([]queue.NitricTask, error) {
+	newErr := errors.ErrorsWithScope(
+		"CloudTasksQueueService.Receive",
+		map[string]interface{}{
+			"options": options,
+		},
+	)
+
+	return nil, newErr(codes.Unimplemented, pushDeliveryMessage, nil)
+}
+
+func (s *CloudTasksQueueService) Complete(queue string, leaseId string) error {
+	newErr := errors.ErrorsWithScope(
+		"CloudTasksQueueService.Complete",
+		map[string]interface{}{
+			"queue":   queue,
+			"leaseId": leaseId,
+		},
+	)
+
+	return newErr(codes.Unimplemented, pushDeliveryMessage, nil)
+}
+
+func (s *CloudTasksQueueService) CompleteBatch(q string, leaseIds []string) (*queue.CompleteBatchResponse, error) {
+	newErr := errors.ErrorsWithScope(
+		"CloudTasksQueueService.CompleteBatch",
+		map[string]interface{}{
+			"queue":    q,
+			"leaseIds": leaseIds,
+		},
+	)
+
+	return nil, newErr(codes.Unimplemented, pushDeliveryMessage, nil)
+}
+
+// Capabilities - Tasks are pushed to their handlers, so they can only be sent
+func (s *CloudTasksQueueService) Capabilities() []string {
+	return []string{queue.CapabilitySend, queue.CapabilitySendBatch}
+}
+
+func (s *CloudTasksQueueService) LeaseExtend(queue string, leaseId string, duration time.Duration) error {
+	newErr := errors.ErrorsWithScope(
+		"CloudTasksQueueService.LeaseExtend",
+		map[string]interface{}{
+			"queue":    queue,
+			"leaseId":  leaseId,
+			"duration": duration,
+		},
+	)
+
+	return newErr(codes.Unimplemented, pushDeliveryMessage, nil)
+}
+
+// New - Creates a Cloud Tasks queue plugin, pushing tasks to CLOUDTASKS_TARGET_URL
+// from queues in the CLOUDTASKS_LOCATION region of the default credentials' project
+func New() (queue.QueueService, error) {
+	ctx := context.Background()
+
+	location := utils.GetEnv(CLOUDTASKS_LOCATION_ENV, "")
+	if location == "" {
+		return nil, fmt.Errorf("%s not configured", CLOUDTASKS_LOCATION_ENV)
+	}
+
+	targetUrl := utils.GetEnv(CLOUDTASKS_TARGET_URL_ENV, "")
+	if targetUrl == "" {
+		return nil, fmt.Errorf("%s not configured", CLOUDTASKS_TARGET_URL_ENV)
+	}
+
+	credentials, credentialsError := google.FindDefaultCredentials(ctx, cloudtasks.DefaultAuthScopes()...)
+	if credentialsError != nil {
+		return nil, fmt.Errorf("GCP credentials error: %v", credentialsError)
+	}
+
+	client, clientError := cloudtasks.NewClient(ctx)
+	if clientError != nil {
+		return nil, fmt.Errorf("cloud tasks client error: %v", clientError)
+	}
+
+	return NewWithClient(
+		client,
+		credentials.ProjectID,
+		location,
+		targetUrl,
+		WithServiceAccount(utils.GetEnv(CLOUDTASKS_SERVICE_ACCOUNT_ENV, "")),
+	)
+}
+
+// NewWithClient - Creates a Cloud Tasks queue plugin using the given client
+func NewWithClient(client CloudTasksClient, projectId string, location string, targetUrl string, opts ...CloudTasksQueueServiceOption) (queue.QueueService, error) {
+	s := &CloudTasksQueueService{
+		client:    client,
+		projectId: projectId,
+		location:  location,
+		targetUrl: targetUrl,
+	}
+
+	for _, o := range opts {
+		o.Apply(s)
+	}
+
+	return s, nil
+}
@@ -0,0 +1,181 @@
+// Copyright 2021 Nitric Pty Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudtasks_queue_service_test
+
+import (
+	"fmt"
+
+	"github.com/golang/mock/gomock"
+	mocks "github.com/nitrictech/nitric/mocks/cloudtasks"
+	"github.com/nitrictech/nitric/pkg/plugins/errors"
+	"github.com/nitrictech/nitric/pkg/plugins/errors/codes"
+	"github.com/nitrictech/nitric/pkg/plugins/queue"
+	cloudtasks_queue_service "github.com/nitrictech/nitric/pkg/plugins/queue/cloudtasks"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	taskspb "google.golang.org/genproto/googleapis/cloud/tasks/v2"
+	grpccodes "google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const queuePath = "projects/my-project/locations/us-central1/queues/test-queue"
+
+var _ = Describe("Cloud Tasks", func() {
+	var ctrl *gomock.Controller
+	var mockClient *mocks.MockCloudTasksClient
+	var queuePlugin queue.QueueService
+
+	BeforeEach(func() {
+		ctrl = gomock.NewController(GinkgoT())
+		mockClient = mocks.NewMockCloudTasksClient(ctrl)
+		queuePlugin, _ = cloudtasks_queue_service.NewWithClient(
+			mockClient,
+			"my-project",
+			"us-central1",
+			"https://my-service.a.run.app/",
+			cloudtasks_queue_service.WithServiceAccount("tasks@my-project.iam.gserviceaccount.com"),
+		)
+	})
+
+	AfterEach(func() {
+		ctrl.Finish()
+	})
+
+	Context("Send", func() {
+		When("Cloud Tasks accepts the task", func() {
+			It("Should create a named HTTP task pushing to the target", func() {
+				mockClient.EXPECT().CreateTask(gomock.Any(), &taskspb.CreateTaskRequest{
+					Parent: queuePath,
+					Task: &taskspb.Task{
+						Name: queuePath + "/tasks/1234",
+						MessageType: &taskspb.Task_HttpRequest{
+							HttpRequest: &taskspb.HttpRequest{
+								Url:        "https://my-service.a.run.app/",
+								HttpMethod: taskspb.HttpMethod_POST,
+								Headers: map[string]string{
+									"Content-Type": "application/json",
+								},
+								Body: []byte(`{"id":"1234","payload":{"testval":"testkey"}}`),
+								AuthorizationHeader: &taskspb.HttpRequest_OidcToken{
+									OidcToken: &taskspb.OidcToken{
+										ServiceAccountEmail: "tasks@my-project.iam.gserviceaccount.com",
+									},
+								},
+							},
+						},
+					},
+				}).Times(1).Return(&taskspb.Task{}, nil)
+
+				err := queuePlugin.Send("test-queue", queue.NitricTask{
+					ID: "1234",
+					Payload: map[string]interface{}{
+						"testval": "testkey",
+					},
+				})
+
+				Expect(err).ShouldNot(HaveOccurred())
+			})
+		})
+
+		When("The task has already been sent", func() {
+			It("Should not return an error", func() {
+				mockClient.EXPECT().CreateTask(gomock.Any(), gomock.Any()).Times(1).Return(
+					nil, status.Error(grpccodes.AlreadyExists, "task already exists"),
+				)
+
+				err := queuePlugin.Send("test-queue", queue.NitricTask{ID: "1234"})
+
+				Expect(err).ShouldNot(HaveOccurred())
+			})
+		})
+
+		When("The task ID isn't a valid task name", func() {
+			It("Should let Cloud Tasks name the task", func() {
+				mockClient.EXPECT().CreateTask(gomock.Any(), gomock.Any()).Times(1).DoAndReturn(
+					func(_ interface{}, req *taskspb.CreateTaskRequest, _ ...interface{}) (*taskspb.Task, error) {
+						Expect(req.Task.Name).To(BeEmpty())
+						return &taskspb.Task{}, nil
+					},
+				)
+
+				err := queuePlugin.Send("test-queue", queue.NitricTask{ID: "not/valid"})
+
+				Expect(err).ShouldNot(HaveOccurred())
+			})
+		})
+
+		When("The queue doesn't exist", func() {
+			It("Should return a NotFound error", func() {
+				mockClient.EXPECT().CreateTask(gomock.Any(), gomock.Any()).Times(1).Return(
+					nil, status.Error(grpccodes.NotFound, "queue not found"),
+				)
+
+				err := queuePlugin.Send("test-queue", queue.NitricTask{ID: "1234"})
+
+				Expect(err).Should(HaveOccurred())
+				Expect(errors.Code(err)).To(Equal(codes.NotFound))
+			})
+		})
+	})
+
+	Context("SendBatch", func() {
+		When("Some tasks fail to be created", func() {
+			It("Should return the failed tasks", func() {
+				gomock.InOrder(
+					mockClient.EXPECT().CreateTask(gomock.Any(), gomock.Any()).Return(&taskspb.Task{}, nil),
+					mockClient.EXPECT().CreateTask(gomock.Any(), gomock.Any()).Return(
+						nil, status.Error(grpccodes.Unavailable, "try again"),
+					),
+				)
+
+				tasks := []queue.NitricTask{{ID: "1"}, {ID: "2"}}
+				resp, err := queuePlugin.SendBatch("test-queue", tasks)
+
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(resp.FailedTasks).To(HaveLen(1))
+				Expect(resp.FailedTasks[0].Task.ID).To(Equal("2"))
+			})
+		})
+
+		When("The queue doesn't exist", func() {
+			It("Should return a NotFound error", func() {
+				mockClient.EXPECT().CreateTask(gomock.Any(), gomock.Any()).Times(1).Return(
+					nil, status.Error(grpccodes.NotFound, "queue not found"),
+				)
+
+				_, err := queuePlugin.SendBatch("test-queue", []queue.NitricTask{{ID: "1"}, {ID: "2"}})
+
+				Expect(errors.Code(err)).To(Equal(codes.NotFound))
+			})
+		})
+	})
+
+	Context("Receive", func() {
+		It("Should return an Unimplemented error", func() {
+			_, err := queuePlugin.Receive(queue.ReceiveOptions{QueueName: "test-queue"})
+
+			Expect(errors.Code(err)).To(Equal(codes.Unimplemented))
+			Expect(err.Error()).To(ContainSubstring("pushes tasks"))
+		})
+	})
+
+	Context("Complete", func() {
+		It("Should return an Unimplemented error", func() {
+			err := queuePlugin.Complete("test-queue", fmt.Sprintf("lease-%d", 1))
+
+			Expect(errors.Code(err)).To(Equal(codes.Unimplemented))
+		})
+	})
+})
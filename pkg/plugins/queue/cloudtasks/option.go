@@ -0,0 +1,35 @@
+// Copyright 2021 Nitric Pty Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudtasks_queue_service
+
+type CloudTasksQueueServiceOption interface {
+	Apply(*CloudTasksQueueService)
+}
+
+type withServiceAccount struct {
+	serviceAccount string
+}
+
+func (w *withServiceAccount) Apply(service *CloudTasksQueueService) {
+	service.serviceAccount = w.serviceAccount
+}
+
+// WithServiceAccount - sets the service account used to sign OIDC tokens authenticating pushed tasks with the target,
+// tasks are pushed unauthenticated if empty
+func WithServiceAccount(serviceAccount string) CloudTasksQueueServiceOption {
+	return &withServiceAccount{
+		serviceAccount: serviceAccount,
+	}
+}
@@ -0,0 +1,128 @@
+// Copyright 2021 Nitric Pty Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queue
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/nitrictech/nitric/pkg/utils"
+)
+
+// QUEUE_CODEC_ENV - Names the codec queue plugins encode message bodies with
+const QUEUE_CODEC_ENV = "QUEUE_CODEC"
+
+const (
+	// CodecName_Json - Encodes the whole task as JSON, this is the default
+	CodecName_Json = "json"
+	// CodecName_Raw - Sends the raw bytes of the task payload as the message body
+	CodecName_Raw = "raw"
+)
+
+// RawPayloadKey - The key of the task payload value holding the message body for the raw codec
+const RawPayloadKey = "data"
+
+// RawPayloadType - The payload type of tasks decoded by the raw codec
+const RawPayloadType = "application/octet-stream"
+
+// Codec - Encodes tasks as queue message bodies and decodes them when they're received
+type Codec interface {
+	// Marshal - Encodes a task as a message body
+	Marshal(task *NitricTask) ([]byte, error)
+	// Unmarshal - Decodes a message body into the given task
+	Unmarshal(data []byte, task *NitricTask) error
+}
+
+// JSONCodec - Encodes the whole task, including its ID and attributes, as JSON
+type JSONCodec struct{}
+
+func (*JSONCodec) Marshal(task *NitricTask) ([]byte, error) {
+	return json.Marshal(task)
+}
+
+func (*JSONCodec) Unmarshal(data []byte, task *NitricTask) error {
+	return json.Unmarshal(data, task)
+}
+
+// RawCodec - Sends the RawPayloadKey value of the task payload as the message body unchanged,
+// for consumers that expect their own encoding, e.g. Avro or Protobuf.
+// Received message bodies are decoded into the RawPayloadKey value of the task payload as a string,
+// the task's ID and attributes are only preserved by queues that carry them separately from the body
+type RawCodec struct{}
+
+func (*RawCodec) Marshal(task *NitricTask) ([]byte, error) {
+	switch data := task.Payload[RawPayloadKey].(type) {
+	case []byte:
+		return data, nil
+	case string:
+		return []byte(data), nil
+	default:
+		return nil, fmt.Errorf("raw codec expects a string or bytes payload %q value, got %T", RawPayloadKey, data)
+	}
+}
+
+func (*RawCodec) Unmarshal(data []byte, task *NitricTask) error {
+	task.PayloadType = RawPayloadType
+	task.Payload = map[string]interface{}{
+		RawPayloadKey: string(data),
+	}
+
+	return nil
+}
+
+var (
+	codecsLock sync.RWMutex
+	codecs     = map[string]Codec{
+		CodecName_Json: &JSONCodec{},
+		CodecName_Raw:  &RawCodec{},
+	}
+)
+
+// RegisterCodec - Makes a custom codec available to queue plugins by name, e.g. for use with QUEUE_CODEC
+func RegisterCodec(name string, codec Codec) error {
+	if name == "" || codec == nil {
+		return fmt.Errorf("codec name and codec must be provided")
+	}
+
+	codecsLock.Lock()
+	defer codecsLock.Unlock()
+
+	if _, ok := codecs[name]; ok {
+		return fmt.Errorf("codec %s is already registered", name)
+	}
+
+	codecs[name] = codec
+
+	return nil
+}
+
+// GetCodec - Returns the registered codec with the given name
+func GetCodec(name string) (Codec, error) {
+	codecsLock.RLock()
+	defer codecsLock.RUnlock()
+
+	codec, ok := codecs[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown queue codec %s", name)
+	}
+
+	return codec, nil
+}
+
+// CodecFromEnv - Returns the codec named by the QUEUE_CODEC env var, defaulting to JSON
+func CodecFromEnv() (Codec, error) {
+	return GetCodec(utils.GetEnv(QUEUE_CODEC_ENV, CodecName_Json))
+}
@@ -0,0 +1,126 @@
+// Copyright 2021 Nitric Pty Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queue_test
+
+import (
+	"strings"
+
+	"github.com/nitrictech/nitric/pkg/plugins/queue"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// upperCodec - A custom codec that upper cases a string payload
+type upperCodec struct {
+	queue.RawCodec
+}
+
+func (c *upperCodec) Marshal(task *queue.NitricTask) ([]byte, error) {
+	data, err := c.RawCodec.Marshal(task)
+	return []byte(strings.ToUpper(string(data))), err
+}
+
+var _ = Describe("Codec", func() {
+	task := queue.NitricTask{
+		ID:          "1234",
+		PayloadType: "test-payload",
+		Payload: map[string]interface{}{
+			"Test": "Test",
+		},
+		Attributes: map[string]string{
+			"type": "test",
+		},
+	}
+
+	Context("JSONCodec", func() {
+		It("Should round trip the whole task", func() {
+			codec := &queue.JSONCodec{}
+
+			data, err := codec.Marshal(&task)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(string(data)).To(Equal(`{"id":"1234","payloadType":"test-payload","payload":{"Test":"Test"},"attributes":{"type":"test"}}`))
+
+			var decoded queue.NitricTask
+			Expect(codec.Unmarshal(data, &decoded)).To(Succeed())
+			Expect(decoded).To(Equal(task))
+		})
+	})
+
+	Context("RawCodec", func() {
+		codec := &queue.RawCodec{}
+
+		When("The payload data is bytes or a string", func() {
+			It("Should encode the data unchanged", func() {
+				for _, value := range []interface{}{[]byte("\x00avro"), "\x00avro"} {
+					data, err := codec.Marshal(&queue.NitricTask{
+						Payload: map[string]interface{}{queue.RawPayloadKey: value},
+					})
+					Expect(err).ShouldNot(HaveOccurred())
+					Expect(data).To(Equal([]byte("\x00avro")))
+				}
+			})
+		})
+
+		When("The payload has no data", func() {
+			It("Should return an error", func() {
+				_, err := codec.Marshal(&task)
+				Expect(err).Should(HaveOccurred())
+			})
+		})
+
+		It("Should decode the message body into the payload data", func() {
+			var decoded queue.NitricTask
+			Expect(codec.Unmarshal([]byte("\x00avro"), &decoded)).To(Succeed())
+			Expect(decoded.PayloadType).To(Equal(queue.RawPayloadType))
+			Expect(decoded.Payload).To(Equal(map[string]interface{}{
+				queue.RawPayloadKey: "\x00avro",
+			}))
+		})
+	})
+
+	Context("Registry", func() {
+		It("Should provide the built in codecs", func() {
+			codec, err := queue.GetCodec(queue.CodecName_Json)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(codec).To(BeAssignableToTypeOf(&queue.JSONCodec{}))
+
+			codec, err = queue.GetCodec(queue.CodecName_Raw)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(codec).To(BeAssignableToTypeOf(&queue.RawCodec{}))
+		})
+
+		It("Should provide registered custom codecs", func() {
+			Expect(queue.RegisterCodec("upper", &upperCodec{})).To(Succeed())
+
+			codec, err := queue.GetCodec("upper")
+			Expect(err).ShouldNot(HaveOccurred())
+
+			data, err := codec.Marshal(&queue.NitricTask{
+				Payload: map[string]interface{}{queue.RawPayloadKey: "test"},
+			})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(data).To(Equal([]byte("TEST")))
+
+			By("Rejecting codecs registered with the same name")
+			Expect(queue.RegisterCodec("upper", &upperCodec{})).ShouldNot(Succeed())
+		})
+
+		It("Should return an error for unknown codecs", func() {
+			_, err := queue.GetCodec("unknown")
+			Expect(err).Should(HaveOccurred())
+		})
+	})
+})
@@ -0,0 +1,135 @@
+// Copyright 2021 Nitric Pty Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queue
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/nitrictech/nitric/pkg/plugins/errors/codes"
+)
+
+// CompleteFailure - Why a task couldn't be completed
+type CompleteFailure int
+
+const (
+	// CompleteFailure_Transient - The provider failed to settle the task, completing it again may succeed
+	CompleteFailure_Transient CompleteFailure = iota
+	// CompleteFailure_AlreadyCompleted - The task isn't on the queue, it was already completed so it won't be redelivered
+	CompleteFailure_AlreadyCompleted
+	// CompleteFailure_LeaseExpired - The lease expired before the task was completed, the task will be redelivered
+	CompleteFailure_LeaseExpired
+)
+
+var completeFailures = [...]string{"TRANSIENT", "ALREADY_COMPLETED", "LEASE_EXPIRED"}
+
+func (f CompleteFailure) String() string {
+	return completeFailures[f]
+}
+
+// Code - The error code plugins return for the failure, transient failures are Unavailable so they're retried
+func (f CompleteFailure) Code() codes.Code {
+	switch f {
+	case CompleteFailure_AlreadyCompleted:
+		return codes.NotFound
+	case CompleteFailure_LeaseExpired:
+		return codes.Aborted
+	default:
+		return codes.Unavailable
+	}
+}
+
+// CompleteError - Returned from Complete when a task couldn't be completed, so callers can decide whether to retry.
+// Plugins wrap it in a plugin error with the failure's code
+type CompleteError struct {
+	Reason  CompleteFailure
+	LeaseID string
+	Err     error
+}
+
+func (e *CompleteError) Error() string {
+	if e.Err == nil {
+		return fmt.Sprintf("task %s could not be completed: %s", e.LeaseID, e.Reason)
+	}
+
+	return fmt.Sprintf("task %s could not be completed: %s: %v", e.LeaseID, e.Reason, e.Err)
+}
+
+func (e *CompleteError) Unwrap() error {
+	return e.Err
+}
+
+// Retryable - Returns true if completing the task again may succeed
+func (e *CompleteError) Retryable() bool {
+	return e.Reason == CompleteFailure_Transient
+}
+
+// NewCompleteError - Creates a CompleteError for the lease, caused by err
+func NewCompleteError(reason CompleteFailure, leaseId string, err error) *CompleteError {
+	return &CompleteError{
+		Reason:  reason,
+		LeaseID: leaseId,
+		Err:     err,
+	}
+}
+
+// AsCompleteError - Finds a CompleteError in the error chain, if there is one
+func AsCompleteError(err error) (*CompleteError, bool) {
+	var completeErr *CompleteError
+	if errors.As(err, &completeErr) {
+		return completeErr, true
+	}
+
+	return nil, false
+}
+
+// CompleteResult - The outcome of completing a single task of a batch
+type CompleteResult struct {
+	LeaseID string
+	// Err - nil if the task was completed, usually wrapping a CompleteError otherwise
+	Err error
+}
+
+// CompleteBatchResponse - The outcome of completing each task of a batch, in the order the leases were given
+type CompleteBatchResponse struct {
+	Results []*CompleteResult
+}
+
+// Failed - Returns the results of the tasks that couldn't be completed
+func (r *CompleteBatchResponse) Failed() []*CompleteResult {
+	failed := make([]*CompleteResult, 0)
+	for _, result := range r.Results {
+		if result.Err != nil {
+			failed = append(failed, result)
+		}
+	}
+
+	return failed
+}
+
+// CompleteEach - Completes a batch of tasks one at a time, for plugins without a batch settle operation
+func CompleteEach(plugin QueueService, queue string, leaseIds []string) *CompleteBatchResponse {
+	results := make([]*CompleteResult, 0, len(leaseIds))
+	for _, leaseId := range leaseIds {
+		results = append(results, &CompleteResult{
+			LeaseID: leaseId,
+			Err:     plugin.Complete(queue, leaseId),
+		})
+	}
+
+	return &CompleteBatchResponse{
+		Results: results,
+	}
+}
@@ -29,11 +29,18 @@ import (
 	"github.com/nitrictech/nitric/pkg/plugins/errors"
 	"github.com/nitrictech/nitric/pkg/plugins/errors/codes"
 	"github.com/nitrictech/nitric/pkg/plugins/queue"
+	memory_service "github.com/nitrictech/nitric/pkg/plugins/queue/memory"
 	"go.etcd.io/bbolt"
 )
 
 const DEV_SUB_DIRECTORY = "./queues/"
 
+// The time received tasks remain invisible when no visibility timeout is requested
+const defaultVisibilityTimeout = 30 * time.Second
+
+// How often an empty queue is checked for new tasks while a receive is waiting
+const receivePollInterval = 100 * time.Millisecond
+
 type DevQueueService struct {
 	queue.UnimplementedQueuePlugin
 	dbDir string
@@ -42,6 +49,41 @@ type DevQueueService struct {
 type Item struct {
 	ID   int `storm:"id,increment"` // primary key with auto increment
 	Data []byte
+	// The ids of previous leases of the task that expired before it was completed
+	ExpiredLeases []string
+}
+
+// Lease - A received task, which is returned to the queue if it isn't completed before the lease expires
+type Lease struct {
+	ID            string `storm:"id"` // the lease id returned with the task
+	Data          []byte
+	Expiry        time.Time
+	ExpiredLeases []string
+}
+
+// requeueExpiredLeases - Returns the tasks of any expired leases to the queue
+func requeueExpiredLeases(db *storm.DB) error {
+	var leases []Lease
+	if err := db.All(&leases); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, lease := range leases {
+		if lease.Expiry.After(now) {
+			continue
+		}
+
+		if err := db.Save(&Item{Data: lease.Data, ExpiredLeases: append(lease.ExpiredLeases, lease.ID)}); err != nil {
+			return err
+		}
+
+		if err := db.DeleteStruct(&lease); err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
 func (s *DevQueueService) Send(queue string, task queue.NitricTask) error {
@@ -159,6 +201,7 @@ func (s *DevQueueService) SendBatch(q string, tasks []queue.NitricTask) (*queue.
 	}, nil
 }
 
+// Receive - Receives tasks from the queue, polling until the wait time has passed if the queue is empty
 func (s *DevQueueService) Receive(options queue.ReceiveOptions) ([]queue.NitricTask, error) {
 	newErr := errors.ErrorsWithScope(
 		"DevQueueService.Receive",
@@ -167,6 +210,34 @@ func (s *DevQueueService) Receive(options queue.ReceiveOptions) ([]queue.NitricT
 		},
 	)
 
+	if options.WaitTime < 0 || options.WaitTime > queue.MaxReceiveWaitTime {
+		return nil, newErr(
+			codes.InvalidArgument,
+			fmt.Sprintf("provide a wait time between 0 and %v", queue.MaxReceiveWaitTime),
+			nil,
+		)
+	}
+
+	deadline := time.Now().Add(options.WaitTime)
+	for {
+		tasks, err := s.receiveTasks(options)
+		if err != nil || len(tasks) > 0 || !time.Now().Before(deadline) {
+			return tasks, err
+		}
+
+		time.Sleep(receivePollInterval)
+	}
+}
+
+// receiveTasks - Leases up to the requested depth of tasks currently on the queue
+func (s *DevQueueService) receiveTasks(options queue.ReceiveOptions) ([]queue.NitricTask, error) {
+	newErr := errors.ErrorsWithScope(
+		"DevQueueService.Receive",
+		map[string]interface{}{
+			"options": options,
+		},
+	)
+
 	if options.QueueName == "" {
 		return nil, newErr(
 			codes.InvalidArgument,
@@ -185,8 +256,26 @@ func (s *DevQueueService) Receive(options queue.ReceiveOptions) ([]queue.NitricT
 	}
 	defer db.Close()
 
+	if err := requeueExpiredLeases(db); err != nil {
+		return nil, newErr(
+			codes.Internal,
+			"error returning expired tasks to the queue",
+			err,
+		)
+	}
+
+	visibilityTimeout := defaultVisibilityTimeout
+	if options.VisibilityTimeout > 0 {
+		visibilityTimeout = options.VisibilityTimeout
+	}
+
+	// When filtering, every task is checked as matching tasks may be anywhere in the queue
 	var items []Item
-	err = db.All(&items, storm.Limit(int(*options.Depth)))
+	if len(options.Filter) > 0 {
+		err = db.All(&items)
+	} else {
+		err = db.All(&items, storm.Limit(int(*options.Depth)))
+	}
 	if err != nil {
 		return nil, newErr(
 			codes.Internal,
@@ -197,6 +286,10 @@ func (s *DevQueueService) Receive(options queue.ReceiveOptions) ([]queue.NitricT
 
 	poppedTasks := make([]queue.NitricTask, 0)
 	for _, item := range items {
+		if len(poppedTasks) >= int(*options.Depth) {
+			break
+		}
+
 		var task queue.NitricTask
 		err := json.Unmarshal(item.Data, &task)
 		if err != nil {
@@ -206,9 +299,29 @@ func (s *DevQueueService) Receive(options queue.ReceiveOptions) ([]queue.NitricT
 				err,
 			)
 		}
+
+		// Tasks that don't match the filter remain on the queue
+		if !task.MatchesFilter(options.Filter) {
+			continue
+		}
+
 		task.LeaseID = uuid.New().String()
 		poppedTasks = append(poppedTasks, task)
 
+		err = db.Save(&Lease{
+			ID:            task.LeaseID,
+			Data:          item.Data,
+			Expiry:        time.Now().Add(visibilityTimeout),
+			ExpiredLeases: item.ExpiredLeases,
+		})
+		if err != nil {
+			return nil, newErr(
+				codes.Internal,
+				"error leasing task",
+				err,
+			)
+		}
+
 		err = db.DeleteStruct(&item)
 		if err != nil {
 			return nil, newErr(
@@ -222,16 +335,155 @@ func (s *DevQueueService) Receive(options queue.ReceiveOptions) ([]queue.NitricT
 	return poppedTasks, nil
 }
 
+// hasExpiredLease - Returns true if a task on the queue, leased or not, previously had the lease and it expired
+func hasExpiredLease(db *storm.DB, leaseId string) (bool, error) {
+	var items []Item
+	if err := db.All(&items); err != nil {
+		return false, err
+	}
+	for _, item := range items {
+		for _, expired := range item.ExpiredLeases {
+			if expired == leaseId {
+				return true, nil
+			}
+		}
+	}
+
+	var leases []Lease
+	if err := db.All(&leases); err != nil {
+		return false, err
+	}
+	for _, lease := range leases {
+		for _, expired := range lease.ExpiredLeases {
+			if expired == leaseId {
+				return true, nil
+			}
+		}
+	}
+
+	return false, nil
+}
+
+// complete - Deletes a lease, returning a CompleteError if the task was already completed or its lease expired
+func complete(db *storm.DB, newErr errors.ErrorFactory, leaseId string) error {
+	// Completing a lease that has expired but hasn't been redelivered is tolerated, matching the behavior of the cloud queues
+	err := db.DeleteStruct(&Lease{ID: leaseId})
+	if err == nil {
+		return nil
+	}
+
+	reason := queue.CompleteFailure_Transient
+	if err == storm.ErrNotFound {
+		reason = queue.CompleteFailure_AlreadyCompleted
+
+		expired, findErr := hasExpiredLease(db, leaseId)
+		if findErr != nil {
+			reason, err = queue.CompleteFailure_Transient, findErr
+		} else if expired {
+			reason = queue.CompleteFailure_LeaseExpired
+		}
+	}
+
+	return newErr(
+		reason.Code(),
+		"error completing task",
+		queue.NewCompleteError(reason, leaseId, err),
+	)
+}
+
 // Completes a previously popped queue item
-func (s *DevQueueService) Complete(queue string, leaseId string) error {
+func (s *DevQueueService) Complete(q string, leaseId string) error {
 	newErr := errors.ErrorsWithScope(
 		"DevQueueService.Complete",
 		map[string]interface{}{
-			"queue":   queue,
+			"queue":   q,
 			"leaseId": leaseId,
 		},
 	)
 
+	if q == "" {
+		return newErr(
+			codes.InvalidArgument,
+			"provide non-blank queue",
+			nil,
+		)
+	}
+	if leaseId == "" {
+		return newErr(
+			codes.InvalidArgument,
+			"provide non-blank leaseId",
+			nil,
+		)
+	}
+
+	db, err := s.createDb(q)
+	if err != nil {
+		return newErr(
+			codes.FailedPrecondition,
+			"createDb error",
+			err,
+		)
+	}
+	defer db.Close()
+
+	return complete(db, newErr, leaseId)
+}
+
+// CompleteBatch - Completes previously popped queue items, returning the outcome for each
+func (s *DevQueueService) CompleteBatch(q string, leaseIds []string) (*queue.CompleteBatchResponse, error) {
+	newErr := errors.ErrorsWithScope(
+		"DevQueueService.CompleteBatch",
+		map[string]interface{}{
+			"queue":        q,
+			"leaseIds.len": len(leaseIds),
+		},
+	)
+
+	if q == "" {
+		return nil, newErr(
+			codes.InvalidArgument,
+			"provide non-blank queue",
+			nil,
+		)
+	}
+
+	db, err := s.createDb(q)
+	if err != nil {
+		return nil, newErr(
+			codes.FailedPrecondition,
+			"createDb error",
+			err,
+		)
+	}
+	defer db.Close()
+
+	results := make([]*queue.CompleteResult, 0, len(leaseIds))
+	for _, leaseId := range leaseIds {
+		result := &queue.CompleteResult{LeaseID: leaseId}
+		if leaseId == "" {
+			result.Err = newErr(codes.InvalidArgument, "provide non-blank leaseId", nil)
+		} else {
+			result.Err = complete(db, newErr, leaseId)
+		}
+		results = append(results, result)
+	}
+
+	return &queue.CompleteBatchResponse{
+		Results: results,
+	}, nil
+}
+
+// Extends the lease of a previously popped queue item
+func (s *DevQueueService) LeaseExtend(queue string, leaseId string, duration time.Duration) error {
+	newErr := errors.ErrorsWithScope(
+		"DevQueueService.LeaseExtend",
+		map[string]interface{}{
+			"queue":    queue,
+			"leaseId":  leaseId,
+			"duration": duration.String(),
+		},
+	)
+
 	if queue == "" {
 		return newErr(
 			codes.InvalidArgument,
@@ -246,10 +498,77 @@ func (s *DevQueueService) Complete(queue string, leaseId string) error {
 			nil,
 		)
 	}
+	if duration < 0 {
+		return newErr(
+			codes.InvalidArgument,
+			"provide non-negative duration",
+			nil,
+		)
+	}
+
+	db, err := s.createDb(queue)
+	if err != nil {
+		return newErr(
+			codes.FailedPrecondition,
+			"createDb error",
+			err,
+		)
+	}
+	defer db.Close()
+
+	var lease Lease
+	if err := db.One("ID", leaseId, &lease); err != nil {
+		if err == storm.ErrNotFound {
+			return newErr(
+				codes.NotFound,
+				"lease not found",
+				err,
+			)
+		}
+		return newErr(
+			codes.Internal,
+			"error reading lease",
+			err,
+		)
+	}
+
+	if !lease.Expiry.After(time.Now()) {
+		return newErr(
+			codes.NotFound,
+			"lease has expired",
+			nil,
+		)
+	}
+
+	lease.Expiry = time.Now().Add(duration)
+	if err := db.Save(&lease); err != nil {
+		return newErr(
+			codes.Internal,
+			"error extending lease",
+			err,
+		)
+	}
+
 	return nil
 }
 
+// Capabilities - Returns the baseline capabilities along with the optional operations the plugin supports
+func (s *DevQueueService) Capabilities() []string {
+	return append(queue.BaselineCapabilities(),
+		queue.CapabilityCompleteBatch,
+		queue.CapabilityLeaseExtend,
+		queue.CapabilityLongPolling,
+		queue.CapabilityReceiveFilter,
+		queue.CapabilityVisibilityTimeout,
+	)
+}
+
+// New - Returns the dev queue service, or the memory queue service when QUEUE_ENVIRONMENT is memory
 func New() (queue.QueueService, error) {
+	if utils.GetEnv("QUEUE_ENVIRONMENT", "") == "memory" {
+		return memory_service.New()
+	}
+
 	dbDir := utils.GetEnv("LOCAL_QUEUE_DIR", utils.GetRelativeDevPath(DEV_SUB_DIRECTORY))
 
 	// Check whether file exists
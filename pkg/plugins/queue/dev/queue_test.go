@@ -245,14 +245,235 @@ var _ = Describe("Queue", func() {
 		})
 	})
 
+	Context("Receive with a filter", func() {
+		When("Only some tasks match the filter", func() {
+			It("Should return the matching tasks and leave the others queued", func() {
+				highPriority := task1
+				highPriority.Attributes = map[string]string{"priority": "high"}
+				lowPriority := task2
+				lowPriority.Attributes = map[string]string{"priority": "low"}
+
+				queuePlugin.SendBatch("test", []queue.NitricTask{lowPriority, highPriority, task3})
+
+				depth := uint32(10)
+				items, err := queuePlugin.Receive(queue.ReceiveOptions{
+					QueueName: "test",
+					Depth:     &depth,
+					Filter:    map[string]string{"priority": "high"},
+				})
+				Expect(err).ShouldNot(HaveOccurred())
+
+				By("Returning the matching task")
+				Expect(items).To(HaveLen(1))
+				Expect(items[0].ID).To(Equal(highPriority.ID))
+				Expect(items[0].Attributes).To(Equal(highPriority.Attributes))
+
+				By("Leaving the unmatched tasks on the queue")
+				storedTasks := GetAllTasks("test")
+				Expect(storedTasks).To(HaveLen(2))
+			})
+		})
+	})
+
+	Context("Receive with a wait time", func() {
+		When("A task is sent while waiting", func() {
+			It("Should return the task", func() {
+				go func() {
+					time.Sleep(200 * time.Millisecond)
+					queuePlugin.Send("test", task1)
+				}()
+
+				depth := uint32(10)
+				items, err := queuePlugin.Receive(queue.ReceiveOptions{
+					QueueName: "test",
+					Depth:     &depth,
+					WaitTime:  5 * time.Second,
+				})
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(items).To(HaveLen(1))
+				Expect(items[0].ID).To(Equal(task1.ID))
+			})
+		})
+
+		When("No task is sent before the wait time", func() {
+			It("Should return an empty slice after waiting", func() {
+				depth := uint32(10)
+				start := time.Now()
+				items, err := queuePlugin.Receive(queue.ReceiveOptions{
+					QueueName: "test",
+					Depth:     &depth,
+					WaitTime:  300 * time.Millisecond,
+				})
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(items).To(HaveLen(0))
+				Expect(time.Since(start)).To(BeNumerically(">=", 300*time.Millisecond))
+			})
+		})
+	})
+
+	Context("Receive with a visibility timeout", func() {
+		When("The task is not completed before the timeout", func() {
+			It("Should return the task to the queue", func() {
+				err := queuePlugin.Send("test", task1)
+				Expect(err).ShouldNot(HaveOccurred())
+
+				depth := uint32(10)
+				items, err := queuePlugin.Receive(queue.ReceiveOptions{
+					QueueName:         "test",
+					Depth:             &depth,
+					VisibilityTimeout: time.Millisecond,
+				})
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(items).To(HaveLen(1))
+
+				time.Sleep(10 * time.Millisecond)
+
+				By("Redelivering the task with a new lease")
+				redelivered, err := queuePlugin.Receive(queue.ReceiveOptions{
+					QueueName: "test",
+					Depth:     &depth,
+				})
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(redelivered).To(HaveLen(1))
+				Expect(redelivered[0].ID).To(Equal(task1.ID))
+				Expect(redelivered[0].LeaseID).ToNot(Equal(items[0].LeaseID))
+			})
+		})
+
+		When("The task is completed before the timeout", func() {
+			It("Should not return the task to the queue", func() {
+				err := queuePlugin.Send("test", task1)
+				Expect(err).ShouldNot(HaveOccurred())
+
+				depth := uint32(10)
+				items, err := queuePlugin.Receive(queue.ReceiveOptions{
+					QueueName:         "test",
+					Depth:             &depth,
+					VisibilityTimeout: 5 * time.Millisecond,
+				})
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(items).To(HaveLen(1))
+
+				err = queuePlugin.Complete("test", items[0].LeaseID)
+				Expect(err).ShouldNot(HaveOccurred())
+
+				time.Sleep(10 * time.Millisecond)
+
+				redelivered, err := queuePlugin.Receive(queue.ReceiveOptions{
+					QueueName: "test",
+					Depth:     &depth,
+				})
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(redelivered).To(HaveLen(0))
+			})
+		})
+	})
+
+	Context("LeaseExtend", func() {
+		When("The lease is active", func() {
+			It("Should keep the task invisible", func() {
+				err := queuePlugin.Send("test", task1)
+				Expect(err).ShouldNot(HaveOccurred())
+
+				depth := uint32(10)
+				items, err := queuePlugin.Receive(queue.ReceiveOptions{
+					QueueName:         "test",
+					Depth:             &depth,
+					VisibilityTimeout: 5 * time.Millisecond,
+				})
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(items).To(HaveLen(1))
+
+				err = queuePlugin.LeaseExtend("test", items[0].LeaseID, time.Minute)
+				Expect(err).ShouldNot(HaveOccurred())
+
+				time.Sleep(10 * time.Millisecond)
+
+				redelivered, err := queuePlugin.Receive(queue.ReceiveOptions{
+					QueueName: "test",
+					Depth:     &depth,
+				})
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(redelivered).To(HaveLen(0))
+			})
+		})
+
+		When("The lease does not exist", func() {
+			It("Should return an error", func() {
+				err := queuePlugin.LeaseExtend("test", "unknown-lease", time.Minute)
+				Expect(err).Should(HaveOccurred())
+			})
+		})
+	})
+
 	Context("Complete", func() {
-		// Currently the local queue complete method is a stub that always returns successfully.
-		// We may consider adding more realistic behavior if that is useful in future.
-		When("it always returns successfully", func() {
-			It("Should retnot return an error", func() {
+		When("The task was already completed", func() {
+			It("Should return an already completed error", func() {
 				err := queuePlugin.Complete("test-queue", "test-id")
-				By("Not returning an error")
+				Expect(err).Should(HaveOccurred())
+
+				completeErr, ok := queue.AsCompleteError(err)
+				Expect(ok).To(BeTrue())
+				Expect(completeErr.Reason).To(Equal(queue.CompleteFailure_AlreadyCompleted))
+				Expect(completeErr.Retryable()).To(BeFalse())
+			})
+		})
+
+		When("The lease expired and the task was redelivered", func() {
+			It("Should return a lease expired error", func() {
+				err := queuePlugin.Send("test", task1)
 				Expect(err).ShouldNot(HaveOccurred())
+
+				depth := uint32(10)
+				items, err := queuePlugin.Receive(queue.ReceiveOptions{
+					QueueName:         "test",
+					Depth:             &depth,
+					VisibilityTimeout: 5 * time.Millisecond,
+				})
+				Expect(err).ShouldNot(HaveOccurred())
+
+				time.Sleep(10 * time.Millisecond)
+				redelivered, err := queuePlugin.Receive(queue.ReceiveOptions{
+					QueueName: "test",
+					Depth:     &depth,
+				})
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(redelivered).To(HaveLen(1))
+
+				err = queuePlugin.Complete("test", items[0].LeaseID)
+				completeErr, ok := queue.AsCompleteError(err)
+				Expect(ok).To(BeTrue())
+				Expect(completeErr.Reason).To(Equal(queue.CompleteFailure_LeaseExpired))
+
+				By("Completing the redelivered task")
+				Expect(queuePlugin.Complete("test", redelivered[0].LeaseID)).To(Succeed())
+			})
+		})
+	})
+
+	Context("CompleteBatch", func() {
+		When("Completing leased and unknown tasks", func() {
+			It("Should return the outcome of each", func() {
+				_, err := queuePlugin.SendBatch("test", []queue.NitricTask{task1, task2})
+				Expect(err).ShouldNot(HaveOccurred())
+
+				depth := uint32(10)
+				items, err := queuePlugin.Receive(queue.ReceiveOptions{
+					QueueName: "test",
+					Depth:     &depth,
+				})
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(items).To(HaveLen(2))
+
+				resp, err := queuePlugin.CompleteBatch("test", []string{items[0].LeaseID, "unknown", items[1].LeaseID})
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(resp.Results).To(HaveLen(3))
+				Expect(resp.Results[0].Err).ToNot(HaveOccurred())
+				Expect(resp.Results[2].Err).ToNot(HaveOccurred())
+
+				failed := resp.Failed()
+				Expect(failed).To(HaveLen(1))
+				Expect(failed[0].LeaseID).To(Equal("unknown"))
 			})
 		})
 	})
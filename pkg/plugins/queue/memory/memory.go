@@ -0,0 +1,752 @@
+// Copyright 2021 Nitric Pty Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package memory_service
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/nitrictech/nitric/pkg/plugins/errors"
+	"github.com/nitrictech/nitric/pkg/plugins/errors/codes"
+	"github.com/nitrictech/nitric/pkg/plugins/queue"
+	"github.com/nitrictech/nitric/pkg/utils"
+)
+
+const DEV_SUB_DIRECTORY = "./queues/"
+
+// DefaultWalFile - The write-ahead log file name, within the dev queue directory unless a path is configured
+const DefaultWalFile = "queues.wal"
+
+// DefaultSyncInterval - How often the write-ahead log is fsynced unless configured
+const DefaultSyncInterval = time.Second
+
+// DefaultSnapshotInterval - How often the queues are snapshotted unless configured
+const DefaultSnapshotInterval = time.Minute
+
+// The time received tasks remain invisible when no visibility timeout is requested
+const defaultVisibilityTimeout = 30 * time.Second
+
+// How often an empty queue is checked for new tasks while a receive is waiting
+const receivePollInterval = 100 * time.Millisecond
+
+const (
+	opSend     = "send"
+	opComplete = "complete"
+)
+
+// walRecord - An entry in the write-ahead log, replayed over the latest snapshot on startup
+type walRecord struct {
+	Op    string            `json:"op"`
+	Queue string            `json:"queue"`
+	ID    uint64            `json:"id"`
+	Task  *queue.NitricTask `json:"task,omitempty"`
+}
+
+// snapshot - The tasks on every queue at a point in time
+type snapshot struct {
+	NextID uint64                   `json:"nextId"`
+	Queues map[string][]*storedTask `json:"queues"`
+}
+
+type storedTask struct {
+	ID   uint64           `json:"id"`
+	Task queue.NitricTask `json:"task"`
+}
+
+// message - A task on a queue, invisible to receivers until visibleAt while it's leased
+type message struct {
+	id        uint64
+	task      queue.NitricTask
+	leaseId   string
+	visibleAt time.Time
+	// The ids of previous leases that expired before the task was completed
+	expiredLeases []string
+}
+
+// MemoryQueueService - A queue plugin that keeps tasks in memory, durably logging sends and completions to disk.
+// Leases aren't persisted, so tasks that were leased but not completed are redelivered after a restart.
+type MemoryQueueService struct {
+	queue.UnimplementedQueuePlugin
+	walPath          string
+	syncInterval     time.Duration
+	snapshotInterval time.Duration
+
+	lock   sync.Mutex
+	queues map[string][]*message
+	nextId uint64
+	wal    *os.File
+	// unsynced is true when the write-ahead log has appends that haven't been fsynced
+	unsynced bool
+
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+}
+
+func (s *MemoryQueueService) snapshotPath() string {
+	return s.walPath + ".snapshot"
+}
+
+// restore - Loads the latest snapshot, then replays the write-ahead log over it
+func (s *MemoryQueueService) restore() error {
+	ids := make(map[uint64]bool)
+
+	snapshotFile, err := os.Open(s.snapshotPath())
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err == nil {
+		var snp snapshot
+		err := json.NewDecoder(snapshotFile).Decode(&snp)
+		snapshotFile.Close()
+		if err != nil {
+			return fmt.Errorf("error reading queue snapshot: %v", err)
+		}
+
+		s.nextId = snp.NextID
+		for name, tasks := range snp.Queues {
+			for _, t := range tasks {
+				s.queues[name] = append(s.queues[name], &message{id: t.ID, task: t.Task})
+				ids[t.ID] = true
+			}
+		}
+	}
+
+	walFile, err := os.Open(s.walPath)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer walFile.Close()
+
+	reader := bufio.NewReader(walFile)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			// A trailing record without a newline was torn by a crash mid-append, so was never acknowledged
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		var record walRecord
+		if err := json.Unmarshal(line, &record); err != nil {
+			return fmt.Errorf("error reading queue write-ahead log: %v", err)
+		}
+
+		if record.ID >= s.nextId {
+			s.nextId = record.ID + 1
+		}
+
+		switch record.Op {
+		case opSend:
+			// Records from before the latest snapshot are already restored if the log wasn't truncated after it
+			if ids[record.ID] || record.Task == nil {
+				continue
+			}
+			s.queues[record.Queue] = append(s.queues[record.Queue], &message{id: record.ID, task: *record.Task})
+			ids[record.ID] = true
+		case opComplete:
+			s.remove(record.Queue, record.ID)
+		}
+	}
+}
+
+// remove - Removes a task from a queue, returning false if it's not on the queue
+func (s *MemoryQueueService) remove(name string, id uint64) bool {
+	messages := s.queues[name]
+	for i, msg := range messages {
+		if msg.id == id {
+			s.queues[name] = append(messages[:i], messages[i+1:]...)
+			return true
+		}
+	}
+
+	return false
+}
+
+// append - Appends a record to the write-ahead log, the lock must be held
+func (s *MemoryQueueService) append(record *walRecord) error {
+	if s.wal == nil {
+		return fmt.Errorf("queue service is closed")
+	}
+
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	if _, err := s.wal.Write(append(line, '\n')); err != nil {
+		return err
+	}
+
+	if s.syncInterval == 0 {
+		return s.wal.Sync()
+	}
+	s.unsynced = true
+
+	return nil
+}
+
+// sync - Fsyncs any unsynced appends to the write-ahead log, the lock must be held
+func (s *MemoryQueueService) sync() error {
+	if s.wal == nil || !s.unsynced {
+		return nil
+	}
+
+	if err := s.wal.Sync(); err != nil {
+		return err
+	}
+	s.unsynced = false
+
+	return nil
+}
+
+// compact - Snapshots every queue then truncates the write-ahead log, the lock must be held
+func (s *MemoryQueueService) compact() error {
+	snp := snapshot{
+		NextID: s.nextId,
+		Queues: make(map[string][]*storedTask),
+	}
+	for name, messages := range s.queues {
+		if len(messages) == 0 {
+			continue
+		}
+
+		tasks := make([]*storedTask, 0, len(messages))
+		for _, msg := range messages {
+			tasks = append(tasks, &storedTask{ID: msg.id, Task: msg.task})
+		}
+		snp.Queues[name] = tasks
+	}
+
+	// The snapshot is written to a temporary file and renamed over the previous one, so a crash leaves one of them intact
+	tmpPath := s.snapshotPath() + ".tmp"
+	tmp, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+
+	if err := json.NewEncoder(tmp).Encode(&snp); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmpPath, s.snapshotPath()); err != nil {
+		return err
+	}
+
+	// Records the snapshot contains are no longer needed, any left by a crash before this point are skipped on restore
+	if err := s.wal.Truncate(0); err != nil {
+		return err
+	}
+	s.unsynced = true
+
+	return s.sync()
+}
+
+// run - Periodically fsyncs the write-ahead log and snapshots the queues until the service is closed
+func (s *MemoryQueueService) run() {
+	defer close(s.done)
+
+	var syncTick, snapshotTick <-chan time.Time
+	if s.syncInterval > 0 {
+		ticker := time.NewTicker(s.syncInterval)
+		defer ticker.Stop()
+		syncTick = ticker.C
+	}
+	if s.snapshotInterval > 0 {
+		ticker := time.NewTicker(s.snapshotInterval)
+		defer ticker.Stop()
+		snapshotTick = ticker.C
+	}
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-syncTick:
+			s.lock.Lock()
+			if err := s.sync(); err != nil {
+				fmt.Printf("error syncing queue write-ahead log: %v\n", err)
+			}
+			s.lock.Unlock()
+		case <-snapshotTick:
+			s.lock.Lock()
+			if err := s.compact(); err != nil {
+				fmt.Printf("error snapshotting queues: %v\n", err)
+			}
+			s.lock.Unlock()
+		}
+	}
+}
+
+// enqueue - Logs then adds a task to the queue, the lock must be held
+func (s *MemoryQueueService) enqueue(name string, task queue.NitricTask) error {
+	id := s.nextId
+	if err := s.append(&walRecord{Op: opSend, Queue: name, ID: id, Task: &task}); err != nil {
+		return err
+	}
+	s.nextId++
+
+	s.queues[name] = append(s.queues[name], &message{id: id, task: task})
+
+	return nil
+}
+
+func (s *MemoryQueueService) Send(queue string, task queue.NitricTask) error {
+	newErr := errors.ErrorsWithScope(
+		"MemoryQueueService.Send",
+		map[string]interface{}{
+			"queue": queue,
+			"task":  task,
+		},
+	)
+
+	if queue == "" {
+		return newErr(
+			codes.InvalidArgument,
+			"provide non-blank queue",
+			nil,
+		)
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if err := s.enqueue(queue, task); err != nil {
+		return newErr(
+			codes.Internal,
+			"error sending task",
+			err,
+		)
+	}
+
+	return nil
+}
+
+func (s *MemoryQueueService) SendBatch(q string, tasks []queue.NitricTask) (*queue.SendBatchResponse, error) {
+	newErr := errors.ErrorsWithScope(
+		"MemoryQueueService.SendBatch",
+		map[string]interface{}{
+			"queue":     q,
+			"tasks.len": len(tasks),
+		},
+	)
+
+	if q == "" {
+		return nil, newErr(
+			codes.InvalidArgument,
+			"provide non-blank queue",
+			nil,
+		)
+	}
+	if tasks == nil {
+		return nil, newErr(
+			codes.InvalidArgument,
+			"provide non-nil tasks",
+			nil,
+		)
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	failedTasks := make([]*queue.FailedTask, 0)
+	for _, task := range tasks {
+		if err := s.enqueue(q, task); err != nil {
+			failedTask := task
+			failedTasks = append(failedTasks, &queue.FailedTask{
+				Task:    &failedTask,
+				Message: err.Error(),
+			})
+		}
+	}
+
+	return &queue.SendBatchResponse{
+		FailedTasks: failedTasks,
+	}, nil
+}
+
+// Receive - Receives tasks from the queue, polling until the wait time has passed if the queue is empty
+func (s *MemoryQueueService) Receive(options queue.ReceiveOptions) ([]queue.NitricTask, error) {
+	newErr := errors.ErrorsWithScope(
+		"MemoryQueueService.Receive",
+		map[string]interface{}{
+			"options": options,
+		},
+	)
+
+	if err := options.Validate(); err != nil {
+		return nil, newErr(
+			codes.InvalidArgument,
+			"invalid receive options",
+			err,
+		)
+	}
+
+	visibilityTimeout := defaultVisibilityTimeout
+	if options.VisibilityTimeout > 0 {
+		visibilityTimeout = options.VisibilityTimeout
+	}
+
+	deadline := time.Now().Add(options.WaitTime)
+	for {
+		tasks := s.receiveTasks(options.QueueName, int(*options.Depth), visibilityTimeout, options.Filter)
+		if len(tasks) > 0 || !time.Now().Before(deadline) {
+			return tasks, nil
+		}
+
+		time.Sleep(receivePollInterval)
+	}
+}
+
+// receiveTasks - Leases up to depth visible tasks that match the filter
+func (s *MemoryQueueService) receiveTasks(name string, depth int, visibilityTimeout time.Duration, filter map[string]string) []queue.NitricTask {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	now := time.Now()
+	tasks := make([]queue.NitricTask, 0)
+	for _, msg := range s.queues[name] {
+		if len(tasks) >= depth {
+			break
+		}
+
+		// Leased tasks become visible again once their lease expires
+		if msg.visibleAt.After(now) || !msg.task.MatchesFilter(filter) {
+			continue
+		}
+
+		if msg.leaseId != "" {
+			msg.expiredLeases = append(msg.expiredLeases, msg.leaseId)
+		}
+		msg.leaseId = uuid.New().String()
+		msg.visibleAt = now.Add(visibilityTimeout)
+
+		task := msg.task
+		task.LeaseID = msg.leaseId
+		tasks = append(tasks, task)
+	}
+
+	return tasks
+}
+
+// leased - Returns the task currently leased with the given id, the lock must be held
+func (s *MemoryQueueService) leased(name string, leaseId string) *message {
+	for _, msg := range s.queues[name] {
+		if msg.leaseId == leaseId {
+			return msg
+		}
+	}
+
+	return nil
+}
+
+// complete - Logs then removes the task with the lease, the lock must be held
+func (s *MemoryQueueService) complete(newErr errors.ErrorFactory, name string, leaseId string) error {
+	// Completing a lease that has expired but hasn't been redelivered is tolerated, matching the behavior of the cloud queues
+	msg := s.leased(name, leaseId)
+	if msg == nil {
+		reason := queue.CompleteFailure_AlreadyCompleted
+		for _, m := range s.queues[name] {
+			for _, expired := range m.expiredLeases {
+				if expired == leaseId {
+					reason = queue.CompleteFailure_LeaseExpired
+				}
+			}
+		}
+
+		return newErr(
+			reason.Code(),
+			"error completing task",
+			queue.NewCompleteError(reason, leaseId, nil),
+		)
+	}
+
+	if err := s.append(&walRecord{Op: opComplete, Queue: name, ID: msg.id}); err != nil {
+		return newErr(
+			queue.CompleteFailure_Transient.Code(),
+			"error completing task",
+			queue.NewCompleteError(queue.CompleteFailure_Transient, leaseId, err),
+		)
+	}
+	s.remove(name, msg.id)
+
+	return nil
+}
+
+// Completes a previously received task
+func (s *MemoryQueueService) Complete(q string, leaseId string) error {
+	newErr := errors.ErrorsWithScope(
+		"MemoryQueueService.Complete",
+		map[string]interface{}{
+			"queue":   q,
+			"leaseId": leaseId,
+		},
+	)
+
+	if q == "" {
+		return newErr(
+			codes.InvalidArgument,
+			"provide non-blank queue",
+			nil,
+		)
+	}
+	if leaseId == "" {
+		return newErr(
+			codes.InvalidArgument,
+			"provide non-blank leaseId",
+			nil,
+		)
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.complete(newErr, q, leaseId)
+}
+
+// CompleteBatch - Completes previously received tasks, returning the outcome for each
+func (s *MemoryQueueService) CompleteBatch(q string, leaseIds []string) (*queue.CompleteBatchResponse, error) {
+	newErr := errors.ErrorsWithScope(
+		"MemoryQueueService.CompleteBatch",
+		map[string]interface{}{
+			"queue":        q,
+			"leaseIds.len": len(leaseIds),
+		},
+	)
+
+	if q == "" {
+		return nil, newErr(
+			codes.InvalidArgument,
+			"provide non-blank queue",
+			nil,
+		)
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	results := make([]*queue.CompleteResult, 0, len(leaseIds))
+	for _, leaseId := range leaseIds {
+		result := &queue.CompleteResult{LeaseID: leaseId}
+		if leaseId == "" {
+			result.Err = newErr(codes.InvalidArgument, "provide non-blank leaseId", nil)
+		} else {
+			result.Err = s.complete(newErr, q, leaseId)
+		}
+		results = append(results, result)
+	}
+
+	return &queue.CompleteBatchResponse{
+		Results: results,
+	}, nil
+}
+
+// Extends the lease of a previously received task
+func (s *MemoryQueueService) LeaseExtend(queue string, leaseId string, duration time.Duration) error {
+	newErr := errors.ErrorsWithScope(
+		"MemoryQueueService.LeaseExtend",
+		map[string]interface{}{
+			"queue":    queue,
+			"leaseId":  leaseId,
+			"duration": duration.String(),
+		},
+	)
+
+	if queue == "" {
+		return newErr(
+			codes.InvalidArgument,
+			"provide non-blank queue",
+			nil,
+		)
+	}
+	if leaseId == "" {
+		return newErr(
+			codes.InvalidArgument,
+			"provide non-blank leaseId",
+			nil,
+		)
+	}
+	if duration < 0 {
+		return newErr(
+			codes.InvalidArgument,
+			"provide non-negative duration",
+			nil,
+		)
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	msg := s.leased(queue, leaseId)
+	if msg == nil {
+		return newErr(
+			codes.NotFound,
+			"lease not found",
+			nil,
+		)
+	}
+
+	now := time.Now()
+	if !msg.visibleAt.After(now) {
+		return newErr(
+			codes.NotFound,
+			"lease has expired",
+			nil,
+		)
+	}
+
+	msg.visibleAt = now.Add(duration)
+
+	return nil
+}
+
+// Close - Stops the background sync, then snapshots the queues so the next start has no log to replay
+func (s *MemoryQueueService) Close() error {
+	s.stopOnce.Do(func() {
+		close(s.stop)
+	})
+	<-s.done
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.wal == nil {
+		return nil
+	}
+
+	err := s.compact()
+	if closeErr := s.wal.Close(); err == nil {
+		err = closeErr
+	}
+	s.wal = nil
+
+	return err
+}
+
+// nonNegativeIntEnv - Reads a non-negative integer env var, returning the default when it's unset
+func nonNegativeIntEnv(name string, defaultValue int) (int, error) {
+	value := utils.GetEnv(name, strconv.Itoa(defaultValue))
+	i, err := strconv.Atoi(value)
+	if err != nil || i < 0 {
+		return 0, fmt.Errorf("invalid %s env var, expected a non-negative integer, got %v", name, value)
+	}
+
+	return i, nil
+}
+
+// Capabilities - Returns the baseline capabilities along with the optional operations the plugin supports
+func (s *MemoryQueueService) Capabilities() []string {
+	return append(queue.BaselineCapabilities(),
+		queue.CapabilityCompleteBatch,
+		queue.CapabilityLeaseExtend,
+		queue.CapabilityLongPolling,
+		queue.CapabilityReceiveFilter,
+		queue.CapabilityVisibilityTimeout,
+	)
+}
+
+func New() (queue.QueueService, error) {
+	queueDir := utils.GetEnv("LOCAL_QUEUE_DIR", utils.GetRelativeDevPath(DEV_SUB_DIRECTORY))
+	walPath := utils.GetEnv("LOCAL_QUEUE_WAL_PATH", filepath.Join(queueDir, DefaultWalFile))
+
+	syncIntervalMs, err := nonNegativeIntEnv("LOCAL_QUEUE_FSYNC_INTERVAL_MS", int(DefaultSyncInterval/time.Millisecond))
+	if err != nil {
+		return nil, err
+	}
+
+	snapshotIntervalSeconds, err := nonNegativeIntEnv("LOCAL_QUEUE_SNAPSHOT_INTERVAL_SECONDS", int(DefaultSnapshotInterval/time.Second))
+	if err != nil {
+		return nil, err
+	}
+
+	return NewWithOptions(
+		WithWalPath(walPath),
+		WithSyncInterval(time.Duration(syncIntervalMs)*time.Millisecond),
+		WithSnapshotInterval(time.Duration(snapshotIntervalSeconds)*time.Second),
+	)
+}
+
+// NewWithOptions - Creates a memory queue service, restoring the tasks logged at its write-ahead log path
+func NewWithOptions(opts ...MemoryQueueServiceOption) (queue.QueueService, error) {
+	s := &MemoryQueueService{
+		walPath:          filepath.Join(utils.GetRelativeDevPath(DEV_SUB_DIRECTORY), DefaultWalFile),
+		syncInterval:     DefaultSyncInterval,
+		snapshotInterval: DefaultSnapshotInterval,
+		queues:           make(map[string][]*message),
+		stop:             make(chan struct{}),
+		done:             make(chan struct{}),
+	}
+
+	for _, o := range opts {
+		o.Apply(s)
+	}
+
+	if s.walPath == "" {
+		return nil, fmt.Errorf("queue write-ahead log path must not be blank")
+	}
+
+	if s.syncInterval < 0 {
+		return nil, fmt.Errorf("queue fsync interval must not be negative, got %v", s.syncInterval)
+	}
+
+	if s.snapshotInterval < 0 {
+		return nil, fmt.Errorf("queue snapshot interval must not be negative, got %v", s.snapshotInterval)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.walPath), 0777); err != nil {
+		return nil, err
+	}
+
+	if err := s.restore(); err != nil {
+		return nil, err
+	}
+
+	wal, err := os.OpenFile(s.walPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, err
+	}
+	s.wal = wal
+
+	// Compacting on start drops the replayed log, including any torn trailing record
+	if err := s.compact(); err != nil {
+		wal.Close()
+		return nil, err
+	}
+
+	go s.run()
+
+	return s, nil
+}
@@ -0,0 +1,343 @@
+// Copyright 2021 Nitric Pty Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package memory_service_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/nitrictech/nitric/pkg/plugins/errors"
+	"github.com/nitrictech/nitric/pkg/plugins/errors/codes"
+	"github.com/nitrictech/nitric/pkg/plugins/queue"
+	memory_service "github.com/nitrictech/nitric/pkg/plugins/queue/memory"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var task1 = queue.NitricTask{
+	ID:          "1234",
+	PayloadType: "test-payload",
+	Payload: map[string]interface{}{
+		"Test": "Test 1",
+	},
+}
+var task2 = queue.NitricTask{
+	ID:          "2345",
+	PayloadType: "test-payload",
+	Payload: map[string]interface{}{
+		"Test": "Test 2",
+	},
+}
+
+func depth(d uint32) *uint32 {
+	return &d
+}
+
+var _ = Describe("Memory Queue", func() {
+	var dir string
+	var walPath string
+	var queuePlugin queue.QueueService
+
+	open := func(opts ...memory_service.MemoryQueueServiceOption) queue.QueueService {
+		q, err := memory_service.NewWithOptions(append([]memory_service.MemoryQueueServiceOption{
+			memory_service.WithWalPath(walPath),
+			memory_service.WithSyncInterval(0),
+			memory_service.WithSnapshotInterval(0),
+		}, opts...)...)
+		Expect(err).ShouldNot(HaveOccurred())
+		return q
+	}
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "memory-queue")
+		Expect(err).ShouldNot(HaveOccurred())
+		walPath = filepath.Join(dir, "queues.wal")
+
+		queuePlugin = open()
+	})
+
+	AfterEach(func() {
+		Expect(queuePlugin.Close()).To(Succeed())
+		os.RemoveAll(dir)
+	})
+
+	Context("Send", func() {
+		When("The queue is blank", func() {
+			It("Should return an error", func() {
+				err := queuePlugin.Send("", task1)
+				Expect(err).Should(HaveOccurred())
+				Expect(errors.Code(err)).To(Equal(codes.InvalidArgument))
+			})
+		})
+
+		When("Sending a task", func() {
+			It("Should append it to the write-ahead log", func() {
+				Expect(queuePlugin.Send("test", task1)).To(Succeed())
+
+				wal, err := ioutil.ReadFile(walPath)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(string(wal)).To(ContainSubstring(`"op":"send"`))
+				Expect(string(wal)).To(ContainSubstring(task1.ID))
+			})
+		})
+	})
+
+	Context("SendBatch", func() {
+		When("Sending tasks", func() {
+			It("Should receive them in order", func() {
+				resp, err := queuePlugin.SendBatch("test", []queue.NitricTask{task1, task2})
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(resp.FailedTasks).To(BeEmpty())
+
+				tasks, err := queuePlugin.Receive(queue.ReceiveOptions{QueueName: "test", Depth: depth(10)})
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(tasks).To(HaveLen(2))
+				Expect(tasks[0].ID).To(Equal(task1.ID))
+				Expect(tasks[1].ID).To(Equal(task2.ID))
+			})
+		})
+	})
+
+	Context("Receive", func() {
+		When("The queue is empty", func() {
+			It("Should return no tasks", func() {
+				tasks, err := queuePlugin.Receive(queue.ReceiveOptions{QueueName: "test"})
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(tasks).To(BeEmpty())
+			})
+		})
+
+		When("A task is leased", func() {
+			It("Should be invisible to other receivers until the visibility timeout", func() {
+				Expect(queuePlugin.Send("test", task1)).To(Succeed())
+
+				tasks, err := queuePlugin.Receive(queue.ReceiveOptions{QueueName: "test", VisibilityTimeout: 200 * time.Millisecond})
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(tasks).To(HaveLen(1))
+				Expect(tasks[0].LeaseID).ToNot(BeEmpty())
+
+				tasks, err = queuePlugin.Receive(queue.ReceiveOptions{QueueName: "test"})
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(tasks).To(BeEmpty())
+
+				By("Redelivering it once the lease expires")
+				time.Sleep(250 * time.Millisecond)
+				tasks, err = queuePlugin.Receive(queue.ReceiveOptions{QueueName: "test"})
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(tasks).To(HaveLen(1))
+				Expect(tasks[0].ID).To(Equal(task1.ID))
+			})
+		})
+
+		When("Filtering by attributes", func() {
+			It("Should leave tasks that don't match on the queue", func() {
+				matching := task2
+				matching.Attributes = map[string]string{"type": "match"}
+				Expect(queuePlugin.Send("test", task1)).To(Succeed())
+				Expect(queuePlugin.Send("test", matching)).To(Succeed())
+
+				tasks, err := queuePlugin.Receive(queue.ReceiveOptions{QueueName: "test", Filter: map[string]string{"type": "match"}})
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(tasks).To(HaveLen(1))
+				Expect(tasks[0].ID).To(Equal(task2.ID))
+
+				tasks, err = queuePlugin.Receive(queue.ReceiveOptions{QueueName: "test"})
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(tasks).To(HaveLen(1))
+				Expect(tasks[0].ID).To(Equal(task1.ID))
+			})
+		})
+
+		When("Waiting for a task", func() {
+			It("Should return it once it's sent", func() {
+				go func() {
+					time.Sleep(200 * time.Millisecond)
+					queuePlugin.Send("test", task1)
+				}()
+
+				tasks, err := queuePlugin.Receive(queue.ReceiveOptions{QueueName: "test", WaitTime: 2 * time.Second})
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(tasks).To(HaveLen(1))
+			})
+		})
+	})
+
+	Context("Complete", func() {
+		When("Completing a leased task", func() {
+			It("Should remove it from the queue", func() {
+				Expect(queuePlugin.Send("test", task1)).To(Succeed())
+				tasks, err := queuePlugin.Receive(queue.ReceiveOptions{QueueName: "test", VisibilityTimeout: 100 * time.Millisecond})
+				Expect(err).ShouldNot(HaveOccurred())
+
+				Expect(queuePlugin.Complete("test", tasks[0].LeaseID)).To(Succeed())
+
+				time.Sleep(150 * time.Millisecond)
+				tasks, err = queuePlugin.Receive(queue.ReceiveOptions{QueueName: "test"})
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(tasks).To(BeEmpty())
+			})
+		})
+
+		When("The task was already completed", func() {
+			It("Should return an already completed error", func() {
+				err := queuePlugin.Complete("test", "unknown")
+				Expect(errors.Code(err)).To(Equal(codes.NotFound))
+
+				completeErr, ok := queue.AsCompleteError(err)
+				Expect(ok).To(BeTrue())
+				Expect(completeErr.Reason).To(Equal(queue.CompleteFailure_AlreadyCompleted))
+			})
+		})
+
+		When("The lease expired and the task was redelivered", func() {
+			It("Should return a lease expired error", func() {
+				Expect(queuePlugin.Send("test", task1)).To(Succeed())
+				tasks, err := queuePlugin.Receive(queue.ReceiveOptions{QueueName: "test", VisibilityTimeout: 50 * time.Millisecond})
+				Expect(err).ShouldNot(HaveOccurred())
+
+				time.Sleep(100 * time.Millisecond)
+				redelivered, err := queuePlugin.Receive(queue.ReceiveOptions{QueueName: "test"})
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(redelivered).To(HaveLen(1))
+
+				completeErr, ok := queue.AsCompleteError(queuePlugin.Complete("test", tasks[0].LeaseID))
+				Expect(ok).To(BeTrue())
+				Expect(completeErr.Reason).To(Equal(queue.CompleteFailure_LeaseExpired))
+			})
+		})
+	})
+
+	Context("CompleteBatch", func() {
+		When("Completing leased and unknown tasks", func() {
+			It("Should return the outcome of each", func() {
+				_, err := queuePlugin.SendBatch("test", []queue.NitricTask{task1, task2})
+				Expect(err).ShouldNot(HaveOccurred())
+				tasks, err := queuePlugin.Receive(queue.ReceiveOptions{QueueName: "test", Depth: depth(2)})
+				Expect(err).ShouldNot(HaveOccurred())
+
+				resp, err := queuePlugin.CompleteBatch("test", []string{tasks[0].LeaseID, "unknown", tasks[1].LeaseID})
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(resp.Results).To(HaveLen(3))
+
+				failed := resp.Failed()
+				Expect(failed).To(HaveLen(1))
+				Expect(failed[0].LeaseID).To(Equal("unknown"))
+			})
+		})
+	})
+
+	Context("LeaseExtend", func() {
+		When("The lease is unknown", func() {
+			It("Should return NotFound", func() {
+				err := queuePlugin.LeaseExtend("test", "unknown", time.Second)
+				Expect(errors.Code(err)).To(Equal(codes.NotFound))
+			})
+		})
+
+		When("The lease is active", func() {
+			It("Should keep the task invisible for the extended duration", func() {
+				Expect(queuePlugin.Send("test", task1)).To(Succeed())
+				tasks, err := queuePlugin.Receive(queue.ReceiveOptions{QueueName: "test", VisibilityTimeout: 100 * time.Millisecond})
+				Expect(err).ShouldNot(HaveOccurred())
+
+				Expect(queuePlugin.LeaseExtend("test", tasks[0].LeaseID, time.Minute)).To(Succeed())
+
+				time.Sleep(150 * time.Millisecond)
+				tasks, err = queuePlugin.Receive(queue.ReceiveOptions{QueueName: "test"})
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(tasks).To(BeEmpty())
+			})
+		})
+	})
+
+	Context("Restoring", func() {
+		When("The service is restarted", func() {
+			It("Should restore uncompleted tasks, including leased tasks", func() {
+				Expect(queuePlugin.Send("test", task1)).To(Succeed())
+				Expect(queuePlugin.Send("test", task2)).To(Succeed())
+
+				tasks, err := queuePlugin.Receive(queue.ReceiveOptions{QueueName: "test", Depth: depth(2)})
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(queuePlugin.Complete("test", tasks[0].LeaseID)).To(Succeed())
+
+				Expect(queuePlugin.Close()).To(Succeed())
+				queuePlugin = open()
+
+				tasks, err = queuePlugin.Receive(queue.ReceiveOptions{QueueName: "test", Depth: depth(10)})
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(tasks).To(HaveLen(1))
+				Expect(tasks[0].ID).To(Equal(task2.ID))
+			})
+		})
+
+		When("The service crashed without snapshotting", func() {
+			It("Should replay the write-ahead log, ignoring a torn trailing record", func() {
+				Expect(queuePlugin.Send("test", task1)).To(Succeed())
+
+				wal, err := ioutil.ReadFile(walPath)
+				Expect(err).ShouldNot(HaveOccurred())
+
+				// Simulate a crash by copying the log before the service snapshots on close
+				crashed := filepath.Join(dir, "crashed.wal")
+				Expect(ioutil.WriteFile(crashed, append(wal, []byte(`{"op":"send","queue":"test"`)...), 0600)).To(Succeed())
+
+				restored, err := memory_service.NewWithOptions(
+					memory_service.WithWalPath(crashed),
+					memory_service.WithSnapshotInterval(0),
+				)
+				Expect(err).ShouldNot(HaveOccurred())
+				defer restored.Close()
+
+				tasks, err := restored.Receive(queue.ReceiveOptions{QueueName: "test", Depth: depth(10)})
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(tasks).To(HaveLen(1))
+				Expect(tasks[0].ID).To(Equal(task1.ID))
+			})
+		})
+
+		When("Snapshotting periodically", func() {
+			It("Should truncate the write-ahead log", func() {
+				Expect(queuePlugin.Close()).To(Succeed())
+				queuePlugin = open(memory_service.WithSnapshotInterval(50 * time.Millisecond))
+
+				Expect(queuePlugin.Send("test", task1)).To(Succeed())
+
+				Eventually(func() int64 {
+					info, err := os.Stat(walPath)
+					Expect(err).ShouldNot(HaveOccurred())
+					return info.Size()
+				}).Should(BeZero())
+
+				_, err := os.Stat(walPath + ".snapshot")
+				Expect(err).ShouldNot(HaveOccurred())
+			})
+		})
+	})
+
+	Context("NewWithOptions", func() {
+		When("The fsync interval is negative", func() {
+			It("Should return an error", func() {
+				_, err := memory_service.NewWithOptions(
+					memory_service.WithWalPath(filepath.Join(dir, "invalid.wal")),
+					memory_service.WithSyncInterval(-time.Second),
+				)
+				Expect(err).Should(HaveOccurred())
+			})
+		})
+	})
+})
@@ -0,0 +1,66 @@
+// Copyright 2021 Nitric Pty Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package memory_service
+
+import "time"
+
+type MemoryQueueServiceOption interface {
+	Apply(*MemoryQueueService)
+}
+
+type withWalPath struct {
+	path string
+}
+
+func (w *withWalPath) Apply(service *MemoryQueueService) {
+	service.walPath = w.path
+}
+
+// WithWalPath - sets the file the write-ahead log is appended to, snapshots are written alongside it
+func WithWalPath(path string) MemoryQueueServiceOption {
+	return &withWalPath{
+		path: path,
+	}
+}
+
+type withSyncInterval struct {
+	interval time.Duration
+}
+
+func (w *withSyncInterval) Apply(service *MemoryQueueService) {
+	service.syncInterval = w.interval
+}
+
+// WithSyncInterval - sets how often the write-ahead log is fsynced, 0 fsyncs after every append
+func WithSyncInterval(interval time.Duration) MemoryQueueServiceOption {
+	return &withSyncInterval{
+		interval: interval,
+	}
+}
+
+type withSnapshotInterval struct {
+	interval time.Duration
+}
+
+func (w *withSnapshotInterval) Apply(service *MemoryQueueService) {
+	service.snapshotInterval = w.interval
+}
+
+// WithSnapshotInterval - sets how often the queues are snapshotted to disk, truncating the write-ahead log
+func WithSnapshotInterval(interval time.Duration) MemoryQueueServiceOption {
+	return &withSnapshotInterval{
+		interval: interval,
+	}
+}
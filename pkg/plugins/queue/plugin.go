@@ -17,8 +17,30 @@ package queue
 import (
 	"fmt"
 	"strings"
+	"time"
 )
 
+// MaxReceiveWaitTime - The longest a receive may wait for tasks, this is the SQS long polling limit
+const MaxReceiveWaitTime = 20 * time.Second
+
+// The capabilities a queue plugin may support, named after the operations and receive options of the queue service
+const (
+	CapabilitySend              = "send"
+	CapabilitySendBatch         = "send_batch"
+	CapabilityReceive           = "receive"
+	CapabilityComplete          = "complete"
+	CapabilityCompleteBatch     = "complete_batch"
+	CapabilityLeaseExtend       = "lease_extend"
+	CapabilityLongPolling       = "long_polling"
+	CapabilityReceiveFilter     = "receive_filter"
+	CapabilityVisibilityTimeout = "visibility_timeout"
+)
+
+// BaselineCapabilities - Returns the capabilities supported by every queue plugin
+func BaselineCapabilities() []string {
+	return []string{CapabilitySend, CapabilitySendBatch, CapabilityReceive, CapabilityComplete}
+}
+
 type SendBatchResponse struct {
 	FailedTasks []*FailedTask
 }
@@ -31,8 +53,17 @@ type QueueService interface {
 	SendBatch(queue string, tasks []NitricTask) (*SendBatchResponse, error)
 	// Receive - Receives one or more tasks(s) off a queue
 	Receive(options ReceiveOptions) ([]NitricTask, error)
-	// Complete - Marks a received task as completed
+	// Complete - Marks a received task as completed, failures wrap a CompleteError with the reason
 	Complete(queue string, leaseId string) error
+	// CompleteBatch - Marks multiple received tasks as completed, returning the outcome for each task.
+	// An error is only returned if the batch couldn't be attempted
+	CompleteBatch(queue string, leaseIds []string) (*CompleteBatchResponse, error)
+	// LeaseExtend - Keeps a received task invisible to other receivers for the given duration from now
+	LeaseExtend(queue string, leaseId string, duration time.Duration) error
+	// Capabilities - Returns the operations supported by the plugin, so clients can degrade gracefully without them
+	Capabilities() []string
+	// Close - Releases the clients and background resources held by the plugin, called once on shutdown
+	Close() error
 }
 
 type ReceiveOptions struct {
@@ -45,6 +76,21 @@ type ReceiveOptions struct {
 	//
 	// If nil or 0, defaults to depth 1.
 	Depth *uint32 `type:"int" required:"false" log:"Depth"`
+
+	// The time received tasks remain invisible to other receivers before they are redelivered.
+	//
+	// If 0, the queue's default visibility timeout is used.
+	VisibilityTimeout time.Duration `type:"int" required:"false" log:"VisibilityTimeout"`
+
+	// The time to wait for tasks to become available when the queue is empty, up to MaxReceiveWaitTime.
+	//
+	// If 0, receive returns immediately, unless the provider queue is configured with its own default wait time.
+	WaitTime time.Duration `type:"int" required:"false" log:"WaitTime"`
+
+	// Attributes tasks must have to be received, tasks that don't match remain on the queue.
+	//
+	// If nil or empty, tasks are received regardless of their attributes.
+	Filter map[string]string `type:"map" required:"false" log:"Filter"`
 }
 
 func (p *ReceiveOptions) Validate() error {
@@ -53,6 +99,12 @@ func (p *ReceiveOptions) Validate() error {
 	if p.QueueName == "" {
 		invalidParams = append(invalidParams, fmt.Errorf("queueName param must not be blank").Error())
 	}
+	if p.VisibilityTimeout < 0 {
+		invalidParams = append(invalidParams, fmt.Errorf("visibilityTimeout param must not be negative").Error())
+	}
+	if p.WaitTime < 0 || p.WaitTime > MaxReceiveWaitTime {
+		invalidParams = append(invalidParams, fmt.Errorf("waitTime param must be between 0 and %v", MaxReceiveWaitTime).Error())
+	}
 	if len(invalidParams) > 0 {
 		return fmt.Errorf("invalid params: %s", strings.Join(invalidParams, "\n"))
 	}
@@ -94,3 +146,21 @@ func (*UnimplementedQueuePlugin) Receive(options ReceiveOptions) ([]NitricTask,
 func (*UnimplementedQueuePlugin) Complete(queue string, leaseId string) error {
 	return fmt.Errorf("UNIMPLEMENTED")
 }
+
+func (*UnimplementedQueuePlugin) CompleteBatch(queue string, leaseIds []string) (*CompleteBatchResponse, error) {
+	return nil, fmt.Errorf("UNIMPLEMENTED")
+}
+
+func (*UnimplementedQueuePlugin) LeaseExtend(queue string, leaseId string, duration time.Duration) error {
+	return fmt.Errorf("UNIMPLEMENTED")
+}
+
+// Capabilities - Plugins support the baseline capabilities unless they declare others
+func (*UnimplementedQueuePlugin) Capabilities() []string {
+	return BaselineCapabilities()
+}
+
+// Close - Plugins without resources to release have nothing to close
+func (*UnimplementedQueuePlugin) Close() error {
+	return nil
+}
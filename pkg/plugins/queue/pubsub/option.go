@@ -0,0 +1,36 @@
+// Copyright 2021 Nitric Pty Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pubsub_queue_service
+
+import "github.com/nitrictech/nitric/pkg/plugins/queue"
+
+type PubsubQueueServiceOption interface {
+	Apply(*PubsubQueueService)
+}
+
+type withCodec struct {
+	codec queue.Codec
+}
+
+func (w *withCodec) Apply(service *PubsubQueueService) {
+	service.codec = w.codec
+}
+
+// WithCodec - sets the codec message data is encoded with, tasks are encoded as JSON by default
+func WithCodec(codec queue.Codec) PubsubQueueServiceOption {
+	return &withCodec{
+		codec: codec,
+	}
+}
@@ -16,8 +16,8 @@ package pubsub_queue_service
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
+	"time"
 
 	ifaces_pubsub "github.com/nitrictech/nitric/pkg/ifaces/pubsub"
 
@@ -30,14 +30,21 @@ import (
 	"google.golang.org/api/iterator"
 	"google.golang.org/api/option"
 	pubsubpb "google.golang.org/genproto/googleapis/pubsub/v1"
+	grpcCodes "google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
+// The maximum ack deadline Pub/Sub allows for a message
+const maxAckDeadline = 600 * time.Second
+
 type PubsubQueueService struct {
 	queue.UnimplementedQueuePlugin
 	client              ifaces_pubsub.PubsubClient
 	newSubscriberClient func(ctx context.Context, opts ...option.ClientOption) (ifaces_pubsub.SubscriberClient, error)
 	projectId           string
 	messages            []*pubsub.Message
+	// Encodes tasks as message data
+	codec queue.Codec
 }
 
 // TODO: clearly document the reason for this subscription.
@@ -46,6 +53,11 @@ func generateQueueSubscription(queue string) string {
 	return fmt.Sprintf("%s-nitricqueue", queue)
 }
 
+// Close - Closes the Pub/Sub client, subscriber clients are closed after each request
+func (s *PubsubQueueService) Close() error {
+	return s.client.Close()
+}
+
 func (s *PubsubQueueService) Send(queue string, task queue.NitricTask) error {
 	newErr := errors.ErrorsWithScope(
 		"PubsubQueueService.Send",
@@ -66,7 +78,7 @@ func (s *PubsubQueueService) Send(queue string, task queue.NitricTask) error {
 		)
 	}
 
-	if taskBytes, err := json.Marshal(task); err == nil {
+	if taskBytes, err := s.codec.Marshal(&task); err == nil {
 		msg := ifaces_pubsub.AdaptPubsubMessage(&pubsub.Message{
 			Data: taskBytes,
 		})
@@ -119,7 +131,7 @@ func (s *PubsubQueueService) SendBatch(q string, tasks []queue.NitricTask) (*que
 	publishedTasks := make([]queue.NitricTask, 0)
 
 	for _, task := range tasks {
-		if taskBytes, err := json.Marshal(task); err == nil {
+		if taskBytes, err := s.codec.Marshal(&task); err == nil {
 			msg := ifaces_pubsub.AdaptPubsubMessage(&pubsub.Message{
 				Data: taskBytes,
 			})
@@ -225,8 +237,23 @@ func (s *PubsubQueueService) Receive(options queue.ReceiveOptions) ([]queue.Nitr
 	req := pubsubpb.PullRequest{
 		Subscription: queueSubscription.String(),
 		MaxMessages:  int32(*options.Depth),
+		// Without a wait time, return as soon as Pub/Sub has checked for messages
+		ReturnImmediately: options.WaitTime == 0,
+	}
+
+	// Pub/Sub waits for messages until the request deadline, so the wait time is applied as the deadline
+	pullCtx := ctx
+	if options.WaitTime > 0 {
+		var cancel context.CancelFunc
+		pullCtx, cancel = context.WithTimeout(ctx, options.WaitTime)
+		defer cancel()
+	}
+
+	res, err := client.Pull(pullCtx, &req)
+	if err != nil && pullCtx.Err() == context.DeadlineExceeded {
+		// No messages arrived within the wait time
+		return []queue.NitricTask{}, nil
 	}
-	res, err := client.Pull(ctx, &req)
 	if err != nil {
 		// TODO: catch standard grpc errors, like NotFound.
 		return nil, newErr(
@@ -242,27 +269,126 @@ func (s *PubsubQueueService) Receive(options queue.ReceiveOptions) ([]queue.Nitr
 		return []queue.NitricTask{}, nil
 	}
 
+	// Override the subscription's ack deadline for the received messages
+	if options.VisibilityTimeout > 0 {
+		ackIds := make([]string, 0, len(res.ReceivedMessages))
+		for _, m := range res.ReceivedMessages {
+			ackIds = append(ackIds, m.AckId)
+		}
+
+		err = client.ModifyAckDeadline(ctx, &pubsubpb.ModifyAckDeadlineRequest{
+			Subscription:       queueSubscription.String(),
+			AckIds:             ackIds,
+			AckDeadlineSeconds: int32(options.VisibilityTimeout.Seconds()),
+		})
+		if err != nil {
+			return nil, newErr(
+				codes.Internal,
+				"failed to set visibility timeout of pulled messages",
+				err,
+			)
+		}
+	}
+
 	// Convert the PubSub messages into Nitric tasks
 	var tasks []queue.NitricTask
+	unmatchedAckIds := make([]string, 0)
 	for _, m := range res.ReceivedMessages {
 		var nitricTask queue.NitricTask
-		err := json.Unmarshal(m.Message.Data, &nitricTask)
+		err := s.codec.Unmarshal(m.Message.Data, &nitricTask)
 		if err != nil {
 			// TODO: append error to error list and Nack the message.
 			continue
 		}
 
+		// Pull subscriptions can't filter messages, so tasks are filtered once received
+		if !nitricTask.MatchesFilter(options.Filter) {
+			unmatchedAckIds = append(unmatchedAckIds, m.AckId)
+			continue
+		}
+
 		tasks = append(tasks, queue.NitricTask{
 			ID:          nitricTask.ID,
 			Payload:     nitricTask.Payload,
 			PayloadType: nitricTask.PayloadType,
+			Attributes:  nitricTask.Attributes,
 			LeaseID:     m.AckId,
 		})
 	}
 
+	// Nack tasks that didn't match the filter, so they're redelivered to other receivers immediately
+	if len(unmatchedAckIds) > 0 {
+		// Failures aren't fatal, the messages will be redelivered once their ack deadline expires
+		client.ModifyAckDeadline(ctx, &pubsubpb.ModifyAckDeadlineRequest{
+			Subscription:       queueSubscription.String(),
+			AckIds:             unmatchedAckIds,
+			AckDeadlineSeconds: 0,
+		})
+	}
+
+	if tasks == nil {
+		return []queue.NitricTask{}, nil
+	}
+
 	return tasks, nil
 }
 
+// acknowledge - Acknowledges queue items so they're removed from the queue, in a single request
+func (s *PubsubQueueService) acknowledge(newErr errors.ErrorFactory, q string, leaseIds []string) error {
+	ctx := context.Background()
+
+	// Find the generic pull subscription for the provided topic (queue)
+	queueSubscription, err := s.getQueueSubscription(q)
+	if err != nil {
+		return newErr(
+			codes.NotFound,
+			"could not find queue subscription",
+			err,
+		)
+	}
+
+	// Using base client, so that asynchronous message acknowledgement can take place without needing to keep messages
+	// in a stateful service. Standard PubSub go library is stateful and don't provide access to the acknowledge ID of
+	// the messages or an independent acknowledge function. It's only provided as a method on message objects.
+	client, err := s.newSubscriberClient(ctx)
+	if err != nil {
+		return newErr(
+			codes.Internal,
+			"failed to create subscriberclient",
+			err,
+		)
+	}
+	defer client.Close()
+
+	// Acknowledge the queue items so they're removed from the queue
+	req := pubsubpb.AcknowledgeRequest{
+		Subscription: queueSubscription.String(),
+		AckIds:       leaseIds,
+	}
+	err = client.Acknowledge(ctx, &req)
+	if err != nil {
+		// Pub/Sub ignores acknowledgements of messages that were already acknowledged,
+		// ack ids it rejects as invalid have expired and their messages will be redelivered
+		reason := queue.CompleteFailure_Transient
+		if status.Code(err) == grpcCodes.InvalidArgument {
+			reason = queue.CompleteFailure_LeaseExpired
+		}
+
+		leaseId := ""
+		if len(leaseIds) == 1 {
+			leaseId = leaseIds[0]
+		}
+
+		return newErr(
+			reason.Code(),
+			"failed to de-queue task",
+			queue.NewCompleteError(reason, leaseId, err),
+		)
+	}
+
+	return nil
+}
+
 // Completes a previously popped queue item
 func (s *PubsubQueueService) Complete(q string, leaseId string) error {
 	newErr := errors.ErrorsWithScope(
@@ -273,6 +399,68 @@ func (s *PubsubQueueService) Complete(q string, leaseId string) error {
 		},
 	)
 
+	return s.acknowledge(newErr, q, []string{leaseId})
+}
+
+// CompleteBatch - Acknowledges previously popped queue items in a single request,
+// Pub/Sub accepts or rejects the acknowledgements together so every task has the same outcome
+func (s *PubsubQueueService) CompleteBatch(q string, leaseIds []string) (*queue.CompleteBatchResponse, error) {
+	newErr := errors.ErrorsWithScope(
+		"PubsubQueueService.CompleteBatch",
+		map[string]interface{}{
+			"queue":        q,
+			"leaseIds.len": len(leaseIds),
+		},
+	)
+
+	results := make([]*queue.CompleteResult, 0, len(leaseIds))
+	if len(leaseIds) == 0 {
+		return &queue.CompleteBatchResponse{Results: results}, nil
+	}
+
+	// Errors finding the subscription or creating the client mean the batch wasn't attempted
+	err := s.acknowledge(newErr, q, leaseIds)
+	completeErr, ok := queue.AsCompleteError(err)
+	if err != nil && !ok {
+		return nil, err
+	}
+
+	for _, leaseId := range leaseIds {
+		result := &queue.CompleteResult{LeaseID: leaseId}
+		if completeErr != nil {
+			result.Err = newErr(
+				completeErr.Reason.Code(),
+				"failed to de-queue task",
+				queue.NewCompleteError(completeErr.Reason, leaseId, completeErr.Err),
+			)
+		}
+		results = append(results, result)
+	}
+
+	return &queue.CompleteBatchResponse{
+		Results: results,
+	}, nil
+}
+
+// Extends the ack deadline of a previously popped queue item
+func (s *PubsubQueueService) LeaseExtend(q string, leaseId string, duration time.Duration) error {
+	newErr := errors.ErrorsWithScope(
+		"PubsubQueueService.LeaseExtend",
+		map[string]interface{}{
+			"queue":    q,
+			"leaseId":  leaseId,
+			"duration": duration.String(),
+		},
+	)
+
+	if duration < 0 || duration > maxAckDeadline {
+		return newErr(
+			codes.InvalidArgument,
+			fmt.Sprintf("duration must be between 0 and %v", maxAckDeadline),
+			nil,
+		)
+	}
+
 	ctx := context.Background()
 
 	// Find the generic pull subscription for the provided topic (queue)
@@ -285,30 +473,27 @@ func (s *PubsubQueueService) Complete(q string, leaseId string) error {
 		)
 	}
 
-	// Using base client, so that asynchronous message acknowledgement can take place without needing to keep messages
-	// in a stateful service. Standard PubSub go library is stateful and don't provide access to the acknowledge ID of
-	// the messages or an independent acknowledge function. It's only provided as a method on message objects.
 	client, err := s.newSubscriberClient(ctx)
 	if err != nil {
 		return newErr(
 			codes.Internal,
-			"failed to create subscriberclient",
+			"failed to create subscriber client",
 			err,
 		)
 	}
 	defer client.Close()
 
-	// Acknowledge the queue item so it's removed from the queue
-	req := pubsubpb.AcknowledgeRequest{
-		Subscription: queueSubscription.String(),
-		AckIds:       []string{leaseId},
+	// The new deadline is relative to the time of this request
+	req := pubsubpb.ModifyAckDeadlineRequest{
+		Subscription:       queueSubscription.String(),
+		AckIds:             []string{leaseId},
+		AckDeadlineSeconds: int32(duration.Seconds()),
 	}
-	err = client.Acknowledge(ctx, &req)
+	err = client.ModifyAckDeadline(ctx, &req)
 	if err != nil {
-		// TODO: catch standard grpc errors, like NotFound.
 		return newErr(
 			codes.Internal,
-			"failed to de-queue task",
+			"failed to extend task lease",
 			err,
 		)
 	}
@@ -324,7 +509,18 @@ func adaptNewClient(f func(context.Context, ...option.ClientOption) (*pubsubbase
 	}
 }
 
-// New - Constructs a new GCP pubsub client with defaults
+// Capabilities - Returns the baseline capabilities along with the optional operations the plugin supports
+func (s *PubsubQueueService) Capabilities() []string {
+	return append(queue.BaselineCapabilities(),
+		queue.CapabilityCompleteBatch,
+		queue.CapabilityLeaseExtend,
+		queue.CapabilityLongPolling,
+		queue.CapabilityReceiveFilter,
+		queue.CapabilityVisibilityTimeout,
+	)
+}
+
+// New - Constructs a new GCP pubsub client with defaults, encoding tasks with the QUEUE_CODEC codec
 func New() (queue.QueueService, error) {
 	ctx := context.Background()
 
@@ -337,25 +533,35 @@ func New() (queue.QueueService, error) {
 		return nil, fmt.Errorf("pubsub client error: %v", clientError)
 	}
 
+	codec, err := queue.CodecFromEnv()
+	if err != nil {
+		return nil, err
+	}
+
 	return &PubsubQueueService{
 		client: ifaces_pubsub.AdaptPubsubClient(client),
 		// TODO: replace this with a better mechanism for mocking the client.
 		newSubscriberClient: adaptNewClient(pubsubbase.NewSubscriberClient),
 		projectId:           credentials.ProjectID,
+		codec:               codec,
 	}, nil
 }
 
-func NewWithClient(client ifaces_pubsub.PubsubClient) queue.QueueService {
-	return &PubsubQueueService{
-		client:              client,
-		newSubscriberClient: nil,
-	}
+func NewWithClient(client ifaces_pubsub.PubsubClient, opts ...PubsubQueueServiceOption) queue.QueueService {
+	return NewWithClients(client, nil, opts...)
 }
 
 //*pubsubbase.SubscriberClient
-func NewWithClients(client ifaces_pubsub.PubsubClient, subscriberClientGenerator func(ctx context.Context, opts ...option.ClientOption) (ifaces_pubsub.SubscriberClient, error)) queue.QueueService {
-	return &PubsubQueueService{
+func NewWithClients(client ifaces_pubsub.PubsubClient, subscriberClientGenerator func(ctx context.Context, opts ...option.ClientOption) (ifaces_pubsub.SubscriberClient, error), opts ...PubsubQueueServiceOption) queue.QueueService {
+	s := &PubsubQueueService{
 		client:              client,
 		newSubscriberClient: subscriberClientGenerator,
+		codec:               &queue.JSONCodec{},
 	}
+
+	for _, o := range opts {
+		o.Apply(s)
+	}
+
+	return s
 }
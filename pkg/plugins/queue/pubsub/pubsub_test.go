@@ -17,6 +17,7 @@ package pubsub_queue_service_test
 import (
 	"encoding/json"
 	"fmt"
+	"time"
 
 	ifaces_pubsub "github.com/nitrictech/nitric/pkg/ifaces/pubsub"
 	pubsub_queue_service "github.com/nitrictech/nitric/pkg/plugins/queue/pubsub"
@@ -232,4 +233,44 @@ var _ = Describe("Pubsub", func() {
 			})
 		})
 	})
+
+	Context("LeaseExtend", func() {
+		When("Pubsub modify ack deadline request succeeds", func() {
+			mockPubsubClient := mock_pubsub.NewMockPubsubClient(mock_pubsub.MockPubsubOptions{
+				Topics: []string{"mock-queue"},
+			})
+			ackDeadlines := make(map[string]int32)
+			queuePlugin := pubsub_queue_service.NewWithClients(mockPubsubClient, func(ctx context.Context, opts ...option.ClientOption) (ifaces_pubsub.SubscriberClient, error) {
+				return mock_pubsub.MockBaseClient{
+					AckDeadlines: ackDeadlines,
+				}, nil
+			})
+
+			It("Should extend the ack deadline of the task", func() {
+				err := queuePlugin.LeaseExtend("mock-queue", "test-id", 2*time.Minute)
+
+				By("Not returning an error")
+				Expect(err).ShouldNot(HaveOccurred())
+
+				By("Setting the new ack deadline")
+				Expect(ackDeadlines["test-id"]).To(Equal(int32(120)))
+			})
+		})
+
+		When("The duration exceeds the maximum ack deadline", func() {
+			mockPubsubClient := mock_pubsub.NewMockPubsubClient(mock_pubsub.MockPubsubOptions{
+				Topics: []string{"mock-queue"},
+			})
+			queuePlugin := pubsub_queue_service.NewWithClients(mockPubsubClient, func(ctx context.Context, opts ...option.ClientOption) (ifaces_pubsub.SubscriberClient, error) {
+				return mock_pubsub.MockBaseClient{}, nil
+			})
+
+			It("Should return an error", func() {
+				err := queuePlugin.LeaseExtend("mock-queue", "test-id", time.Hour)
+
+				By("Returning an error")
+				Expect(err).Should(HaveOccurred())
+			})
+		})
+	})
 })
@@ -0,0 +1,59 @@
+// Copyright 2021 Nitric Pty Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package servicebus_service_iface
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// OutgoingMessage - A message sent to a Service Bus queue
+type OutgoingMessage struct {
+	// Optional, used by Service Bus for duplicate detection when enabled on the queue
+	MessageID string
+	Body      []byte
+}
+
+// LockedMessage - A message received in peek-lock mode, it remains on the queue until it's completed
+type LockedMessage struct {
+	MessageID      string
+	SequenceNumber int64
+	// The token identifying the lock held on the message, required to settle it
+	LockToken   string
+	LockedUntil time.Time
+	Body        []byte
+}
+
+// ServiceBusClient - The Service Bus queue operations used by the queue plugin
+type ServiceBusClient interface {
+	// Send - Sends one or more messages to a queue in a single request
+	Send(ctx context.Context, queue string, messages []*OutgoingMessage) error
+	// ReceiveAndLock - Receives the next message on the queue in peek-lock mode,
+	// waiting up to timeout for a message to arrive. Returns nil if no message is available
+	ReceiveAndLock(ctx context.Context, queue string, timeout time.Duration) (*LockedMessage, error)
+	// Complete - Settles a locked message, removing it from the queue
+	Complete(ctx context.Context, queue string, sequenceNumber int64, lockToken string) error
+}
+
+// ServiceBusError - An error response returned by Service Bus
+type ServiceBusError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *ServiceBusError) Error() string {
+	return fmt.Sprintf("service bus responded with status %d: %s", e.StatusCode, e.Message)
+}
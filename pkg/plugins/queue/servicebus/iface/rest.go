@@ -0,0 +1,178 @@
+// Copyright 2021 Nitric Pty Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package servicebus_service_iface
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// The media type of batched messages sent to the Service Bus REST API
+const batchContentType = "application/vnd.microsoft.servicebus.json"
+
+// TokenProvider - Provides fresh OAuth tokens, e.g. an adal.ServicePrincipalToken
+type TokenProvider interface {
+	EnsureFresh() error
+	OAuthToken() string
+}
+
+// brokerProperties - Service Bus message properties, sent and received in the BrokerProperties header
+type brokerProperties struct {
+	MessageId      string `json:"MessageId,omitempty"`
+	SequenceNumber int64  `json:"SequenceNumber,omitempty"`
+	LockToken      string `json:"LockToken,omitempty"`
+	LockedUntilUtc string `json:"LockedUntilUtc,omitempty"`
+}
+
+type batchMessage struct {
+	Body             string            `json:"Body"`
+	BrokerProperties *brokerProperties `json:"BrokerProperties,omitempty"`
+}
+
+// restClient - A ServiceBusClient using the Service Bus REST API
+type restClient struct {
+	endpoint string
+	token    TokenProvider
+	client   *http.Client
+}
+
+// do - Sends an authorized request, returning a ServiceBusError for unsuccessful responses
+func (c *restClient) do(ctx context.Context, method string, path string, query url.Values, contentType string, body []byte) (*http.Response, error) {
+	if err := c.token.EnsureFresh(); err != nil {
+		return nil, fmt.Errorf("failed to refresh service bus token: %v", err)
+	}
+
+	reqUrl := fmt.Sprintf("%s/%s", c.endpoint, path)
+	if len(query) > 0 {
+		reqUrl = reqUrl + "?" + query.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, reqUrl, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Authorization", "Bearer "+c.token.OAuthToken())
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		msg, _ := ioutil.ReadAll(resp.Body)
+		return nil, &ServiceBusError{
+			StatusCode: resp.StatusCode,
+			Message:    string(msg),
+		}
+	}
+
+	return resp, nil
+}
+
+func (c *restClient) Send(ctx context.Context, queue string, messages []*OutgoingMessage) error {
+	batch := make([]batchMessage, 0, len(messages))
+	for _, m := range messages {
+		bm := batchMessage{
+			Body: string(m.Body),
+		}
+		if m.MessageID != "" {
+			bm.BrokerProperties = &brokerProperties{MessageId: m.MessageID}
+		}
+		batch = append(batch, bm)
+	}
+
+	body, err := json.Marshal(batch)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.do(ctx, http.MethodPost, url.PathEscape(queue)+"/messages", nil, batchContentType, body)
+	if err != nil {
+		return err
+	}
+
+	return resp.Body.Close()
+}
+
+func (c *restClient) ReceiveAndLock(ctx context.Context, queue string, timeout time.Duration) (*LockedMessage, error) {
+	query := url.Values{}
+	query.Set("timeout", fmt.Sprintf("%d", int64(math.Ceil(timeout.Seconds()))))
+
+	resp, err := c.do(ctx, http.MethodPost, url.PathEscape(queue)+"/messages/head", query, "", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	// No message arrived before the timeout
+	if resp.StatusCode == http.StatusNoContent {
+		return nil, nil
+	}
+
+	var props brokerProperties
+	if err := json.Unmarshal([]byte(resp.Header.Get("BrokerProperties")), &props); err != nil {
+		return nil, fmt.Errorf("invalid message broker properties: %v", err)
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	// The lock expiry is informational, so an unexpected format isn't treated as an error
+	lockedUntil, _ := time.Parse(time.RFC1123, props.LockedUntilUtc)
+
+	return &LockedMessage{
+		MessageID:      props.MessageId,
+		SequenceNumber: props.SequenceNumber,
+		LockToken:      props.LockToken,
+		LockedUntil:    lockedUntil,
+		Body:           body,
+	}, nil
+}
+
+func (c *restClient) Complete(ctx context.Context, queue string, sequenceNumber int64, lockToken string) error {
+	path := fmt.Sprintf("%s/messages/%d/%s", url.PathEscape(queue), sequenceNumber, url.PathEscape(lockToken))
+
+	resp, err := c.do(ctx, http.MethodDelete, path, nil, "", nil)
+	if err != nil {
+		return err
+	}
+
+	return resp.Body.Close()
+}
+
+// NewRestClient - Creates a Service Bus client for the namespace at the given endpoint,
+// e.g. https://my-namespace.servicebus.windows.net
+func NewRestClient(endpoint string, token TokenProvider, client *http.Client) ServiceBusClient {
+	return &restClient{
+		endpoint: strings.TrimSuffix(endpoint, "/"),
+		token:    token,
+		client:   client,
+	}
+}
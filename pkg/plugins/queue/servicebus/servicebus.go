@@ -0,0 +1,367 @@
+// Copyright 2021 Nitric Pty Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package servicebus_service
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nitrictech/nitric/pkg/plugins/errors"
+	"github.com/nitrictech/nitric/pkg/plugins/errors/codes"
+	"github.com/nitrictech/nitric/pkg/plugins/queue"
+	servicebusiface "github.com/nitrictech/nitric/pkg/plugins/queue/servicebus/iface"
+	azureutils "github.com/nitrictech/nitric/pkg/providers/azure/utils"
+	"github.com/nitrictech/nitric/pkg/utils"
+)
+
+// The resource Service Bus tokens are issued for
+const serviceBusResource = "https://servicebus.azure.net/"
+
+// The maximum number of messages sent in a single request, keeping batches under the 256KB message size limit of the standard tier
+const maxBatchSize = 100
+
+type ServiceBusQueueService struct {
+	queue.UnimplementedQueuePlugin
+	client servicebusiface.ServiceBusClient
+	// Prepended to nitric queue names to find their Service Bus queue
+	queuePrefix string
+	// Encodes tasks as message bodies
+	codec queue.Codec
+}
+
+// entityName - Returns the name of the Service Bus queue for a nitric queue
+func (s *ServiceBusQueueService) entityName(queueName string) string {
+	return s.queuePrefix + queueName
+}
+
+// errorCode - Maps Service Bus errors to nitric error codes, throttling and lost locks map to retryable codes
+func errorCode(err error, defaultCode codes.Code) codes.Code {
+	sbErr, ok := err.(*servicebusiface.ServiceBusError)
+	if !ok {
+		return defaultCode
+	}
+
+	switch sbErr.StatusCode {
+	case http.StatusTooManyRequests:
+		return codes.ResourceExhausted
+	case http.StatusServiceUnavailable:
+		// Service Bus responds with 503 Server Busy when the namespace is throttled
+		return codes.Unavailable
+	case http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusNotFound, http.StatusGone:
+		return codes.NotFound
+	}
+
+	return defaultCode
+}
+
+// leaseID - Service Bus messages are settled using their sequence number and lock token
+func leaseID(msg *servicebusiface.LockedMessage) string {
+	return fmt.Sprintf("%d:%s", msg.SequenceNumber, msg.LockToken)
+}
+
+// parseLeaseID - Returns the sequence number and lock token of a lease id
+func parseLeaseID(leaseId string) (int64, string, error) {
+	parts := strings.SplitN(leaseId, ":", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return 0, "", fmt.Errorf("lease id must be in the form <sequenceNumber>:<lockToken>")
+	}
+
+	sequenceNumber, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return 0, "", fmt.Errorf("invalid lease id sequence number: %v", err)
+	}
+
+	return sequenceNumber, parts[1], nil
+}
+
+// toMessage - Converts a nitric task into a Service Bus message
+func (s *ServiceBusQueueService) toMessage(task queue.NitricTask) (*servicebusiface.OutgoingMessage, error) {
+	body, err := s.codec.Marshal(&task)
+	if err != nil {
+		return nil, err
+	}
+
+	return &servicebusiface.OutgoingMessage{
+		MessageID: task.ID,
+		Body:      body,
+	}, nil
+}
+
+func (s *ServiceBusQueueService) Send(queueName string, task queue.NitricTask) error {
+	newErr := errors.ErrorsWithScope(
+		"ServiceBusQueueService.Send",
+		map[string]interface{}{
+			"queue": queueName,
+			"task":  task,
+		},
+	)
+
+	msg, err := s.toMessage(task)
+	if err != nil {
+		return newErr(
+			codes.Internal,
+			"error marshalling the task",
+			err,
+		)
+	}
+
+	if err := s.client.Send(context.TODO(), s.entityName(queueName), []*servicebusiface.OutgoingMessage{msg}); err != nil {
+		return newErr(
+			errorCode(err, codes.Internal),
+			"error sending task to queue",
+			err,
+		)
+	}
+
+	return nil
+}
+
+func (s *ServiceBusQueueService) SendBatch(queueName string, tasks []queue.NitricTask) (*queue.SendBatchResponse, error) {
+	newErr := errors.ErrorsWithScope(
+		"ServiceBusQueueService.SendBatch",
+		map[string]interface{}{
+			"queue":     queueName,
+			"tasks.len": len(tasks),
+		},
+	)
+
+	failedTasks := make([]*queue.FailedTask, 0)
+
+	for start := 0; start < len(tasks); start += maxBatchSize {
+		end := start + maxBatchSize
+		if end > len(tasks) {
+			end = len(tasks)
+		}
+
+		batch := make([]*servicebusiface.OutgoingMessage, 0, end-start)
+		batchTasks := make([]queue.NitricTask, 0, end-start)
+		for _, task := range tasks[start:end] {
+			t := task
+			msg, err := s.toMessage(t)
+			if err != nil {
+				failedTasks = append(failedTasks, &queue.FailedTask{
+					Task:    &t,
+					Message: fmt.Sprintf("error marshalling the task: %v", err),
+				})
+				continue
+			}
+			batch = append(batch, msg)
+			batchTasks = append(batchTasks, t)
+		}
+
+		if len(batch) == 0 {
+			continue
+		}
+
+		// Batches are sent atomically, so either all tasks in the batch are sent or none are
+		if err := s.client.Send(context.TODO(), s.entityName(queueName), batch); err != nil {
+			// Failing the entire request is more useful than failing every task when the queue can't be used at all
+			if code := errorCode(err, codes.Internal); code == codes.NotFound || code == codes.PermissionDenied || code == codes.Unauthenticated {
+				return nil, newErr(
+					code,
+					"error sending tasks to queue",
+					err,
+				)
+			}
+
+			for i := range batchTasks {
+				failedTasks = append(failedTasks, &queue.FailedTask{
+					Task:    &batchTasks[i],
+					Message: err.Error(),
+				})
+			}
+		}
+	}
+
+	return &queue.SendBatchResponse{
+		FailedTasks: failedTasks,
+	}, nil
+}
+
+// Receive - Receives tasks in peek-lock mode, tasks remain locked on the queue until they're completed
+// or their lock expires, after which they're redelivered
+func (s *ServiceBusQueueService) Receive(options queue.ReceiveOptions) ([]queue.NitricTask, error) {
+	newErr := errors.ErrorsWithScope(
+		"ServiceBusQueueService.Receive",
+		map[string]interface{}{
+			"options": options,
+		},
+	)
+
+	if err := options.Validate(); err != nil {
+		return nil, newErr(
+			codes.InvalidArgument,
+			"invalid receive options provided",
+			err,
+		)
+	}
+
+	if options.VisibilityTimeout > 0 {
+		return nil, newErr(
+			codes.Unimplemented,
+			"visibility timeouts are not supported by Service Bus, the lock duration is configured on the queue",
+			nil,
+		)
+	}
+
+	if len(options.Filter) > 0 {
+		return nil, newErr(
+			codes.Unimplemented,
+			"receive filters are not supported by Service Bus queues",
+			nil,
+		)
+	}
+
+	tasks := make([]queue.NitricTask, 0)
+	// Service Bus locks a single message per request, so only the first request waits for messages to arrive
+	timeout := options.WaitTime
+
+	for uint32(len(tasks)) < *options.Depth {
+		msg, err := s.client.ReceiveAndLock(context.TODO(), s.entityName(options.QueueName), timeout)
+		if err != nil {
+			// Return the tasks already locked, rather than leaving them locked until they expire
+			if len(tasks) > 0 {
+				break
+			}
+
+			return nil, newErr(
+				errorCode(err, codes.Internal),
+				"failed to receive messages from the queue",
+				err,
+			)
+		}
+
+		if msg == nil {
+			break
+		}
+		timeout = 0
+
+		var nitricTask queue.NitricTask
+		if err := s.codec.Unmarshal(msg.Body, &nitricTask); err != nil {
+			// TODO: append error to error list and dead-letter the message.
+			continue
+		}
+
+		tasks = append(tasks, queue.NitricTask{
+			ID:          nitricTask.ID,
+			Payload:     nitricTask.Payload,
+			PayloadType: nitricTask.PayloadType,
+			Attributes:  nitricTask.Attributes,
+			LeaseID:     leaseID(msg),
+		})
+	}
+
+	return tasks, nil
+}
+
+// Complete - Settles a locked message, removing it from the queue
+func (s *ServiceBusQueueService) Complete(queueName string, leaseId string) error {
+	newErr := errors.ErrorsWithScope(
+		"ServiceBusQueueService.Complete",
+		map[string]interface{}{
+			"queue":   queueName,
+			"leaseId": leaseId,
+		},
+	)
+
+	sequenceNumber, lockToken, err := parseLeaseID(leaseId)
+	if err != nil {
+		return newErr(
+			codes.InvalidArgument,
+			"invalid lease id",
+			err,
+		)
+	}
+
+	if err := s.client.Complete(context.TODO(), s.entityName(queueName), sequenceNumber, lockToken); err != nil {
+		reason := queue.CompleteFailure_Transient
+		msg := "failed to complete task"
+
+		// Service Bus can't find a locked message once its lock is lost, the message will be redelivered
+		if errorCode(err, codes.Internal) == codes.NotFound {
+			reason = queue.CompleteFailure_LeaseExpired
+			msg = "the task lock has expired or was lost, the task will be redelivered"
+		}
+
+		return newErr(
+			reason.Code(),
+			msg,
+			queue.NewCompleteError(reason, leaseId, err),
+		)
+	}
+
+	return nil
+}
+
+// CompleteBatch - Service Bus settles locked messages individually, so each task is completed in turn
+func (s *ServiceBusQueueService) CompleteBatch(queueName string, leaseIds []string) (*queue.CompleteBatchResponse, error) {
+	return queue.CompleteEach(s, queueName, leaseIds), nil
+}
+
+// Capabilities - Returns the baseline capabilities along with the optional operations the plugin supports
+func (s *ServiceBusQueueService) Capabilities() []string {
+	return append(queue.BaselineCapabilities(),
+		queue.CapabilityCompleteBatch,
+		queue.CapabilityLongPolling,
+	)
+}
+
+// New - Constructs a new Service Bus queue plugin for the namespace configured in the environment,
+// encoding tasks with the QUEUE_CODEC codec
+func New() (queue.QueueService, error) {
+	endpoint := utils.GetEnv(azureutils.AZURE_SERVICEBUS_ENDPOINT, "")
+	if endpoint == "" {
+		return nil, fmt.Errorf("failed to determine Service Bus endpoint, environment variable %s not set", azureutils.AZURE_SERVICEBUS_ENDPOINT)
+	}
+
+	spt, err := azureutils.GetServicePrincipalToken(serviceBusResource)
+	if err != nil {
+		return nil, err
+	}
+
+	client := servicebusiface.NewRestClient(endpoint, spt, &http.Client{
+		// Allow for the maximum receive wait time
+		Timeout: queue.MaxReceiveWaitTime + 30*time.Second,
+	})
+
+	codec, err := queue.CodecFromEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	return NewWithClient(client, WithQueuePrefix(utils.GetEnv("SERVICEBUS_QUEUE_PREFIX", "")), WithCodec(codec))
+}
+
+// NewWithClient - Creates a new Service Bus queue plugin using the provided client
+func NewWithClient(client servicebusiface.ServiceBusClient, opts ...ServiceBusQueueServiceOption) (queue.QueueService, error) {
+	s := &ServiceBusQueueService{
+		client: client,
+		codec:  &queue.JSONCodec{},
+	}
+
+	for _, o := range opts {
+		o.Apply(s)
+	}
+
+	return s, nil
+}
@@ -0,0 +1,175 @@
+// Copyright 2021 Nitric Pty Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package servicebus_service_test
+
+import (
+	"time"
+
+	"github.com/golang/mock/gomock"
+	mock_servicebus "github.com/nitrictech/nitric/mocks/servicebus"
+	"github.com/nitrictech/nitric/pkg/plugins/errors"
+	"github.com/nitrictech/nitric/pkg/plugins/errors/codes"
+	"github.com/nitrictech/nitric/pkg/plugins/queue"
+	servicebus_service "github.com/nitrictech/nitric/pkg/plugins/queue/servicebus"
+	servicebusiface "github.com/nitrictech/nitric/pkg/plugins/queue/servicebus/iface"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ServiceBus", func() {
+	var ctrl *gomock.Controller
+	var mockClient *mock_servicebus.MockServiceBusClient
+	var queuePlugin queue.QueueService
+
+	BeforeEach(func() {
+		ctrl = gomock.NewController(GinkgoT())
+		mockClient = mock_servicebus.NewMockServiceBusClient(ctrl)
+		queuePlugin, _ = servicebus_service.NewWithClient(mockClient, servicebus_service.WithQueuePrefix("app-"))
+	})
+
+	AfterEach(func() {
+		ctrl.Finish()
+	})
+
+	Context("Send", func() {
+		When("Service Bus accepts the message", func() {
+			It("Should send the task to the prefixed queue", func() {
+				mockClient.EXPECT().Send(gomock.Any(), "app-test-queue", []*servicebusiface.OutgoingMessage{
+					{
+						MessageID: "1234",
+						Body:      []byte(`{"id":"1234","payload":{"testval":"testkey"}}`),
+					},
+				}).Times(1).Return(nil)
+
+				err := queuePlugin.Send("test-queue", queue.NitricTask{
+					ID: "1234",
+					Payload: map[string]interface{}{
+						"testval": "testkey",
+					},
+				})
+				Expect(err).ShouldNot(HaveOccurred())
+			})
+		})
+
+		When("Service Bus is throttling requests", func() {
+			It("Should return a resource exhausted error", func() {
+				mockClient.EXPECT().Send(gomock.Any(), "app-test-queue", gomock.Any()).Times(1).Return(&servicebusiface.ServiceBusError{
+					StatusCode: 429,
+				})
+
+				err := queuePlugin.Send("test-queue", queue.NitricTask{ID: "1234"})
+				Expect(err).Should(HaveOccurred())
+				Expect(errors.Code(err)).To(Equal(codes.ResourceExhausted))
+			})
+		})
+	})
+
+	Context("SendBatch", func() {
+		When("A batch fails to send", func() {
+			It("Should return each task in the batch as failed", func() {
+				mockClient.EXPECT().Send(gomock.Any(), "app-test-queue", gomock.Any()).Times(1).Return(&servicebusiface.ServiceBusError{
+					StatusCode: 503,
+				})
+
+				resp, err := queuePlugin.SendBatch("test-queue", []queue.NitricTask{{ID: "1"}, {ID: "2"}})
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(resp.FailedTasks).To(HaveLen(2))
+				Expect(resp.FailedTasks[0].Task.ID).To(Equal("1"))
+				Expect(resp.FailedTasks[1].Task.ID).To(Equal("2"))
+			})
+		})
+	})
+
+	Context("Receive", func() {
+		When("There are messages on the queue", func() {
+			It("Should lock up to the requested depth of messages", func() {
+				gomock.InOrder(
+					mockClient.EXPECT().ReceiveAndLock(gomock.Any(), "app-test-queue", 5*time.Second).Times(1).Return(&servicebusiface.LockedMessage{
+						SequenceNumber: 10,
+						LockToken:      "lock-token",
+						Body:           []byte(`{"id":"1234","payloadType":"test-payload","payload":{"Test":"Test"}}`),
+					}, nil),
+					mockClient.EXPECT().ReceiveAndLock(gomock.Any(), "app-test-queue", time.Duration(0)).Times(1).Return(nil, nil),
+				)
+
+				depth := uint32(10)
+				tasks, err := queuePlugin.Receive(queue.ReceiveOptions{
+					QueueName: "test-queue",
+					Depth:     &depth,
+					WaitTime:  5 * time.Second,
+				})
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(tasks).To(Equal([]queue.NitricTask{
+					{
+						ID:          "1234",
+						PayloadType: "test-payload",
+						Payload: map[string]interface{}{
+							"Test": "Test",
+						},
+						LeaseID: "10:lock-token",
+					},
+				}))
+			})
+		})
+
+		When("The queue is empty", func() {
+			It("Should return an empty slice of tasks", func() {
+				mockClient.EXPECT().ReceiveAndLock(gomock.Any(), "app-test-queue", time.Duration(0)).Times(1).Return(nil, nil)
+
+				tasks, err := queuePlugin.Receive(queue.ReceiveOptions{
+					QueueName: "test-queue",
+				})
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(tasks).To(BeEmpty())
+			})
+		})
+	})
+
+	Context("Complete", func() {
+		When("The message is locked", func() {
+			It("Should settle the message", func() {
+				mockClient.EXPECT().Complete(gomock.Any(), "app-test-queue", int64(10), "lock-token").Times(1).Return(nil)
+
+				err := queuePlugin.Complete("test-queue", "10:lock-token")
+				Expect(err).ShouldNot(HaveOccurred())
+			})
+		})
+
+		When("The message lock has been lost", func() {
+			It("Should return an aborted error", func() {
+				mockClient.EXPECT().Complete(gomock.Any(), "app-test-queue", int64(10), "lock-token").Times(1).Return(&servicebusiface.ServiceBusError{
+					StatusCode: 404,
+				})
+
+				err := queuePlugin.Complete("test-queue", "10:lock-token")
+				Expect(err).Should(HaveOccurred())
+				Expect(errors.Code(err)).To(Equal(codes.Aborted))
+
+				completeErr, ok := queue.AsCompleteError(err)
+				Expect(ok).To(BeTrue())
+				Expect(completeErr.Reason).To(Equal(queue.CompleteFailure_LeaseExpired))
+			})
+		})
+
+		When("The lease id is invalid", func() {
+			It("Should return an invalid argument error", func() {
+				err := queuePlugin.Complete("test-queue", "invalid")
+				Expect(err).Should(HaveOccurred())
+				Expect(errors.Code(err)).To(Equal(codes.InvalidArgument))
+			})
+		})
+	})
+})
@@ -15,8 +15,12 @@
 package sqs_service
 
 import (
-	"encoding/json"
 	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/nitrictech/nitric/pkg/plugins/errors"
 	"github.com/nitrictech/nitric/pkg/plugins/errors/codes"
@@ -35,13 +39,80 @@ const (
 	ErrCodeNoSuchTagSet = "NoSuchTagSet"
 )
 
+// The maximum number of messages SQS accepts in a single SendMessageBatch request
+const maxBatchSize = 10
+
+// The maximum time SQS allows a message to remain invisible
+const maxVisibilityTimeout = 12 * time.Hour
+
+// Tag identifying a queue that only contains tasks matching a receive filter, e.g. one fed by an SNS filter policy
+const filterTagName = "x-nitric-filter"
+
 type SQSQueueService struct {
 	queue.UnimplementedQueuePlugin
 	client sqsiface.SQSAPI
+	// Encodes tasks as message bodies
+	codec queue.Codec
+	// Cache of nitric queue names to SQS queue URLs
+	queueUrls     map[string]*string
+	queueUrlsLock sync.RWMutex
+	// Queue URLs of tasks received from filtered queues, keyed by lease id, so they can be completed or extended
+	filteredLeases     map[string]filteredLease
+	filteredLeasesLock sync.Mutex
+}
+
+type filteredLease struct {
+	url     *string
+	expires time.Time
 }
 
 // Get the URL for a given queue name
 func (s *SQSQueueService) getUrlForQueueName(queue string) (*string, error) {
+	s.queueUrlsLock.RLock()
+	url, ok := s.queueUrls[queue]
+	s.queueUrlsLock.RUnlock()
+
+	if ok {
+		return url, nil
+	}
+
+	url, err := s.findUrlForQueueName(queue)
+	if err != nil {
+		return nil, err
+	}
+
+	s.queueUrlsLock.Lock()
+	s.queueUrls[queue] = url
+	s.queueUrlsLock.Unlock()
+
+	return url, nil
+}
+
+// Remove a cached queue URL, e.g. when the queue no longer exists
+func (s *SQSQueueService) invalidateUrlForQueueName(queue string, err error) {
+	if awsErr, ok := err.(awserr.Error); ok && awsErr.Code() == sqs.ErrCodeQueueDoesNotExist {
+		s.queueUrlsLock.Lock()
+		delete(s.queueUrls, queue)
+		s.queueUrlsLock.Unlock()
+	}
+}
+
+// Find the URL for a given queue name by searching the tags of the available queues
+func (s *SQSQueueService) findUrlForQueueName(queue string) (*string, error) {
+	url, err := s.findUrlForQueue(queue, "")
+	if err != nil {
+		return nil, err
+	}
+
+	if url == nil {
+		return nil, fmt.Errorf("Unable to find queue with name: %s", queue)
+	}
+
+	return url, nil
+}
+
+// Find the URL of the queue with the given name and filter tag, nil if there is no matching queue
+func (s *SQSQueueService) findUrlForQueue(queue string, filter string) (*string, error) {
 	out, err := s.client.ListQueues(&sqs.ListQueuesInput{})
 
 	if err != nil {
@@ -65,13 +136,104 @@ func (s *SQSQueueService) getUrlForQueueName(queue string) (*string, error) {
 			return nil, err
 		}
 
-		for k, v := range tagout.Tags {
-			if k == "x-nitric-name" && *v == queue {
-				return q, nil
-			}
+		if aws.StringValue(tagout.Tags["x-nitric-name"]) == queue && aws.StringValue(tagout.Tags[filterTagName]) == filter {
+			return q, nil
 		}
 	}
-	return nil, fmt.Errorf("Unable to find queue with name: %s", queue)
+	return nil, nil
+}
+
+// filterTag - returns the canonical form of a receive filter, used to tag filtered queues e.g. "priority=high,type=order"
+func filterTag(filter map[string]string) string {
+	pairs := make([]string, 0, len(filter))
+	for k, v := range filter {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+
+	return strings.Join(pairs, ",")
+}
+
+// Get the URL of the queue dedicated to tasks matching the given filter, nil if there isn't one
+func (s *SQSQueueService) getUrlForFilteredQueue(queue string, filter map[string]string) (*string, error) {
+	tag := filterTag(filter)
+	key := queue + "?" + tag
+
+	s.queueUrlsLock.RLock()
+	url, ok := s.queueUrls[key]
+	s.queueUrlsLock.RUnlock()
+
+	// The absence of a filtered queue is also cached, to avoid searching the queue tags on every receive
+	if ok {
+		return url, nil
+	}
+
+	url, err := s.findUrlForQueue(queue, tag)
+	if err != nil {
+		return nil, err
+	}
+
+	s.queueUrlsLock.Lock()
+	s.queueUrls[key] = url
+	s.queueUrlsLock.Unlock()
+
+	return url, nil
+}
+
+// Record the queue a filtered task was received from, expiring leases that can no longer be valid
+func (s *SQSQueueService) addFilteredLease(leaseId string, url *string) {
+	s.filteredLeasesLock.Lock()
+	defer s.filteredLeasesLock.Unlock()
+
+	now := time.Now()
+	for id, lease := range s.filteredLeases {
+		if now.After(lease.expires) {
+			delete(s.filteredLeases, id)
+		}
+	}
+
+	s.filteredLeases[leaseId] = filteredLease{
+		url:     url,
+		expires: now.Add(maxVisibilityTimeout),
+	}
+}
+
+// Get the URL of the queue a task was received from
+func (s *SQSQueueService) getUrlForLease(queue string, leaseId string) (*string, error) {
+	s.filteredLeasesLock.Lock()
+	lease, ok := s.filteredLeases[leaseId]
+	s.filteredLeasesLock.Unlock()
+
+	if ok {
+		return lease.url, nil
+	}
+
+	return s.getUrlForQueueName(queue)
+}
+
+// Make tasks that didn't match a receive filter immediately available to other receivers
+func (s *SQSQueueService) releaseMessages(url *string, messages []*sqs.Message) {
+	for start := 0; start < len(messages); start += maxBatchSize {
+		end := start + maxBatchSize
+		if end > len(messages) {
+			end = len(messages)
+		}
+
+		entries := make([]*sqs.ChangeMessageVisibilityBatchRequestEntry, 0, end-start)
+		for i, m := range messages[start:end] {
+			entries = append(entries, &sqs.ChangeMessageVisibilityBatchRequestEntry{
+				Id:                aws.String(strconv.Itoa(i)),
+				ReceiptHandle:     m.ReceiptHandle,
+				VisibilityTimeout: aws.Int64(0),
+			})
+		}
+
+		// Failures aren't fatal, unreleased messages become visible again once their visibility timeout expires
+		s.client.ChangeMessageVisibilityBatch(&sqs.ChangeMessageVisibilityBatchInput{
+			Entries:  entries,
+			QueueUrl: url,
+		})
+	}
 }
 
 func (s *SQSQueueService) Send(queueName string, task queue.NitricTask) error {
@@ -83,14 +245,36 @@ func (s *SQSQueueService) Send(queueName string, task queue.NitricTask) error {
 		},
 	)
 
-	tasks := []queue.NitricTask{task}
-	if _, err := s.SendBatch(queueName, tasks); err != nil {
+	url, err := s.getUrlForQueueName(queueName)
+	if err != nil {
+		return newErr(
+			codes.NotFound,
+			"unable to find queue",
+			err,
+		)
+	}
+
+	bytes, err := s.codec.Marshal(&task)
+	if err != nil {
+		return newErr(
+			codes.Internal,
+			"error marshalling task",
+			err,
+		)
+	}
+
+	if _, err := s.client.SendMessage(&sqs.SendMessageInput{
+		MessageBody: aws.String(string(bytes)),
+		QueueUrl:    url,
+	}); err != nil {
+		s.invalidateUrlForQueueName(queueName, err)
 		return newErr(
 			codes.Internal,
 			"failed to send task",
 			err,
 		)
 	}
+
 	return nil
 }
 
@@ -103,62 +287,75 @@ func (s *SQSQueueService) SendBatch(queueName string, tasks []queue.NitricTask)
 		},
 	)
 
-	if url, err := s.getUrlForQueueName(queueName); err == nil {
-		entries := make([]*sqs.SendMessageBatchRequestEntry, 0)
+	url, err := s.getUrlForQueueName(queueName)
+	if err != nil {
+		return nil, newErr(
+			codes.NotFound,
+			"unable to find queue",
+			err,
+		)
+	}
+
+	entries := make([]*sqs.SendMessageBatchRequestEntry, 0, len(tasks))
+	for i, task := range tasks {
+		bytes, err := s.codec.Marshal(&task)
+		if err != nil {
+			// TODO: Do we want to just mark this one as having errored?
+			return nil, newErr(
+				codes.Internal,
+				"error marshalling task",
+				err,
+			)
+		}
+
+		entries = append(entries, &sqs.SendMessageBatchRequestEntry{
+			// Task IDs may not be unique or valid SQS batch IDs, so entries are identified by their index
+			Id:          aws.String(strconv.Itoa(i)),
+			MessageBody: aws.String(string(bytes)),
+		})
+	}
 
-		for _, task := range tasks {
-			if bytes, err := json.Marshal(task); err == nil {
-				entries = append(entries, &sqs.SendMessageBatchRequestEntry{
-					// Share the request ID here...
-					Id:          &task.ID,
-					MessageBody: aws.String(string(bytes)),
+	failedTasks := make([]*queue.FailedTask, 0)
+	for start := 0; start < len(entries); start += maxBatchSize {
+		end := start + maxBatchSize
+		if end > len(entries) {
+			end = len(entries)
+		}
+
+		out, err := s.client.SendMessageBatch(&sqs.SendMessageBatchInput{
+			Entries:  entries[start:end],
+			QueueUrl: url,
+		})
+
+		if err != nil {
+			s.invalidateUrlForQueueName(queueName, err)
+			// The whole batch failed, so every task in it is returned as failed
+			for i := start; i < end; i++ {
+				failedTasks = append(failedTasks, &queue.FailedTask{
+					Task:    &tasks[i],
+					Message: err.Error(),
 				})
-			} else {
-				// TODO: Do we want to just mark this one as having errored?
-				return nil, newErr(
-					codes.Internal,
-					"error marshalling task",
-					err,
-				)
 			}
+			continue
 		}
 
-		if out, err := s.client.SendMessageBatch(&sqs.SendMessageBatchInput{
-			Entries:  entries,
-			QueueUrl: url,
-		}); err == nil {
-			// process out Failed messages to return to the user...
-			failedTasks := make([]*queue.FailedTask, 0)
-			for _, failed := range out.Failed {
-				for _, e := range tasks {
-					if e.ID == *failed.Id {
-						failedTasks = append(failedTasks, &queue.FailedTask{
-							Task:    &e,
-							Message: *failed.Message,
-						})
-						// continue processing failed messages
-						break
-					}
-				}
+		// process out Failed messages to return to the user...
+		for _, failed := range out.Failed {
+			i, err := strconv.Atoi(aws.StringValue(failed.Id))
+			if err != nil || i < 0 || i >= len(tasks) {
+				continue
 			}
 
-			return &queue.SendBatchResponse{
-				FailedTasks: failedTasks,
-			}, nil
-		} else {
-			return nil, newErr(
-				codes.Internal,
-				"error sending tasks",
-				err,
-			)
+			failedTasks = append(failedTasks, &queue.FailedTask{
+				Task:    &tasks[i],
+				Message: aws.StringValue(failed.Message),
+			})
 		}
-	} else {
-		return nil, newErr(
-			codes.NotFound,
-			"unable to find queue",
-			err,
-		)
 	}
+
+	return &queue.SendBatchResponse{
+		FailedTasks: failedTasks,
+	}, nil
 }
 
 func (s *SQSQueueService) Receive(options queue.ReceiveOptions) ([]queue.NitricTask, error) {
@@ -178,19 +375,45 @@ func (s *SQSQueueService) Receive(options queue.ReceiveOptions) ([]queue.NitricT
 	}
 
 	if url, err := s.getUrlForQueueName(options.QueueName); err == nil {
+		// SQS can't filter messages when receiving, so prefer a queue that only contains matching tasks
+		// and otherwise fall back to filtering the tasks received from the queue
+		filteredQueue := false
+		if len(options.Filter) > 0 {
+			filteredUrl, err := s.getUrlForFilteredQueue(options.QueueName, options.Filter)
+			if err != nil {
+				return nil, newErr(
+					codes.Internal,
+					"failed to find filtered queue",
+					err,
+				)
+			}
+
+			if filteredUrl != nil {
+				url = filteredUrl
+				filteredQueue = true
+			}
+		}
+
 		req := sqs.ReceiveMessageInput{
 			MaxNumberOfMessages: aws.Int64(int64(*options.Depth)),
 			MessageAttributeNames: []*string{
 				aws.String(sqs.QueueAttributeNameAll),
 			},
 			QueueUrl: url,
-			// TODO: Consider explicit timeout values
-			//VisibilityTimeout:       nil,
-			//WaitTimeSeconds:         nil,
+		}
+
+		if options.VisibilityTimeout > 0 {
+			req.VisibilityTimeout = aws.Int64(int64(options.VisibilityTimeout.Seconds()))
+		}
+
+		// Long poll when a wait time is given, SQS limits this to 20 seconds
+		if options.WaitTime > 0 {
+			req.WaitTimeSeconds = aws.Int64(int64(options.WaitTime.Seconds()))
 		}
 
 		res, err := s.client.ReceiveMessage(&req)
 		if err != nil {
+			s.invalidateUrlForQueueName(options.QueueName, err)
 			return nil, newErr(
 				codes.Internal,
 				"failed to retrieve message",
@@ -203,22 +426,41 @@ func (s *SQSQueueService) Receive(options queue.ReceiveOptions) ([]queue.NitricT
 		}
 
 		var tasks []queue.NitricTask
+		unmatched := make([]*sqs.Message, 0)
 		for _, m := range res.Messages {
 			var nitricTask queue.NitricTask
 			bodyBytes := []byte(*m.Body)
-			err := json.Unmarshal(bodyBytes, &nitricTask)
+			err := s.codec.Unmarshal(bodyBytes, &nitricTask)
 			if err != nil {
 				// TODO: append error to error list and Nack the message.
 			}
 
+			if !nitricTask.MatchesFilter(options.Filter) {
+				unmatched = append(unmatched, m)
+				continue
+			}
+
+			if filteredQueue {
+				s.addFilteredLease(*m.ReceiptHandle, url)
+			}
+
 			tasks = append(tasks, queue.NitricTask{
 				ID:          nitricTask.ID,
 				Payload:     nitricTask.Payload,
 				PayloadType: nitricTask.PayloadType,
+				Attributes:  nitricTask.Attributes,
 				LeaseID:     *m.ReceiptHandle,
 			})
 		}
 
+		if len(unmatched) > 0 {
+			s.releaseMessages(url, unmatched)
+		}
+
+		if tasks == nil {
+			return []queue.NitricTask{}, nil
+		}
+
 		return tasks, nil
 
 	} else {
@@ -240,20 +482,31 @@ func (s *SQSQueueService) Complete(q string, leaseId string) error {
 		},
 	)
 
-	if url, err := s.getUrlForQueueName(q); err == nil {
+	if url, err := s.getUrlForLease(q, leaseId); err == nil {
 		req := sqs.DeleteMessageInput{
 			QueueUrl:      url,
 			ReceiptHandle: aws.String(leaseId),
 		}
 
 		if _, err := s.client.DeleteMessage(&req); err != nil {
+			s.invalidateUrlForQueueName(q, err)
+
+			reason := queue.CompleteFailure_Transient
+			if awsErr, ok := err.(awserr.Error); ok {
+				reason = completeFailure(awsErr.Code())
+			}
+
 			return newErr(
-				codes.Internal,
+				reason.Code(),
 				"failed to dequeue task",
-				err,
+				queue.NewCompleteError(reason, leaseId, err),
 			)
 		}
 
+		s.filteredLeasesLock.Lock()
+		delete(s.filteredLeases, leaseId)
+		s.filteredLeasesLock.Unlock()
+
 		return nil
 	} else {
 		return newErr(
@@ -264,6 +517,170 @@ func (s *SQSQueueService) Complete(q string, leaseId string) error {
 	}
 }
 
+// completeFailure - Classifies the error code of a failed delete. SQS accepts deletes of messages that were
+// already deleted, so only receipt handles that expired before the delete can be distinguished
+func completeFailure(code string) queue.CompleteFailure {
+	switch code {
+	case sqs.ErrCodeReceiptHandleIsInvalid, "InvalidParameterValue":
+		return queue.CompleteFailure_LeaseExpired
+	}
+
+	return queue.CompleteFailure_Transient
+}
+
+// CompleteBatch - Deletes previously popped queue items in batches, returning the outcome for each
+func (s *SQSQueueService) CompleteBatch(q string, leaseIds []string) (*queue.CompleteBatchResponse, error) {
+	newErr := errors.ErrorsWithScope(
+		"SQSQueueService.CompleteBatch",
+		map[string]interface{}{
+			"queue":        q,
+			"leaseIds.len": len(leaseIds),
+		},
+	)
+
+	// Tasks received with a filter may be leased from a different queue, so leases are grouped by queue url
+	results := make([]*queue.CompleteResult, len(leaseIds))
+	urls := make(map[string]*string)
+	indicesByUrl := make(map[string][]int)
+	urlOrder := make([]string, 0)
+	for i, leaseId := range leaseIds {
+		results[i] = &queue.CompleteResult{LeaseID: leaseId}
+
+		url, err := s.getUrlForLease(q, leaseId)
+		if err != nil {
+			return nil, newErr(
+				codes.NotFound,
+				"unable to find queue",
+				err,
+			)
+		}
+
+		key := aws.StringValue(url)
+		if _, ok := urls[key]; !ok {
+			urls[key] = url
+			urlOrder = append(urlOrder, key)
+		}
+		indicesByUrl[key] = append(indicesByUrl[key], i)
+	}
+
+	for _, key := range urlOrder {
+		indices := indicesByUrl[key]
+		for start := 0; start < len(indices); start += maxBatchSize {
+			end := start + maxBatchSize
+			if end > len(indices) {
+				end = len(indices)
+			}
+
+			// Entries are identified by their position in the leases given, so failures can be matched to their result
+			entries := make([]*sqs.DeleteMessageBatchRequestEntry, 0, end-start)
+			for _, i := range indices[start:end] {
+				entries = append(entries, &sqs.DeleteMessageBatchRequestEntry{
+					Id:            aws.String(strconv.Itoa(i)),
+					ReceiptHandle: aws.String(leaseIds[i]),
+				})
+			}
+
+			out, err := s.client.DeleteMessageBatch(&sqs.DeleteMessageBatchInput{
+				QueueUrl: urls[key],
+				Entries:  entries,
+			})
+			if err != nil {
+				s.invalidateUrlForQueueName(q, err)
+				for _, i := range indices[start:end] {
+					results[i].Err = newErr(
+						queue.CompleteFailure_Transient.Code(),
+						"failed to dequeue task",
+						queue.NewCompleteError(queue.CompleteFailure_Transient, leaseIds[i], err),
+					)
+				}
+				continue
+			}
+
+			for _, failed := range out.Failed {
+				i, err := strconv.Atoi(aws.StringValue(failed.Id))
+				if err != nil || i < 0 || i >= len(results) {
+					continue
+				}
+
+				reason := completeFailure(aws.StringValue(failed.Code))
+				results[i].Err = newErr(
+					reason.Code(),
+					"failed to dequeue task",
+					queue.NewCompleteError(reason, leaseIds[i], fmt.Errorf("%s: %s", aws.StringValue(failed.Code), aws.StringValue(failed.Message))),
+				)
+			}
+		}
+	}
+
+	s.filteredLeasesLock.Lock()
+	for _, result := range results {
+		if result.Err == nil {
+			delete(s.filteredLeases, result.LeaseID)
+		}
+	}
+	s.filteredLeasesLock.Unlock()
+
+	return &queue.CompleteBatchResponse{
+		Results: results,
+	}, nil
+}
+
+// Extends the visibility timeout of a previously popped queue item
+func (s *SQSQueueService) LeaseExtend(q string, leaseId string, duration time.Duration) error {
+	newErr := errors.ErrorsWithScope(
+		"SQSQueueService.LeaseExtend",
+		map[string]interface{}{
+			"queue":    q,
+			"leaseId":  leaseId,
+			"duration": duration.String(),
+		},
+	)
+
+	if duration < 0 || duration > maxVisibilityTimeout {
+		return newErr(
+			codes.InvalidArgument,
+			fmt.Sprintf("duration must be between 0 and %v", maxVisibilityTimeout),
+			nil,
+		)
+	}
+
+	url, err := s.getUrlForLease(q, leaseId)
+	if err != nil {
+		return newErr(
+			codes.NotFound,
+			"unable to find queue",
+			err,
+		)
+	}
+
+	if _, err := s.client.ChangeMessageVisibility(&sqs.ChangeMessageVisibilityInput{
+		QueueUrl:          url,
+		ReceiptHandle:     aws.String(leaseId),
+		VisibilityTimeout: aws.Int64(int64(duration.Seconds())),
+	}); err != nil {
+		s.invalidateUrlForQueueName(q, err)
+		return newErr(
+			codes.Internal,
+			"failed to extend task lease",
+			err,
+		)
+	}
+
+	return nil
+}
+
+// Capabilities - Returns the baseline capabilities along with the optional operations the plugin supports
+func (s *SQSQueueService) Capabilities() []string {
+	return append(queue.BaselineCapabilities(),
+		queue.CapabilityCompleteBatch,
+		queue.CapabilityLeaseExtend,
+		queue.CapabilityLongPolling,
+		queue.CapabilityReceiveFilter,
+		queue.CapabilityVisibilityTimeout,
+	)
+}
+
+// Create a new SQS queue plugin using the AWS_REGION environment variable, encoding tasks with the QUEUE_CODEC codec
 func New() (queue.QueueService, error) {
 	awsRegion := utils.GetEnv("AWS_REGION", "us-east-1")
 
@@ -277,13 +694,26 @@ func New() (queue.QueueService, error) {
 
 	client := sqs.New(sess)
 
-	return &SQSQueueService{
-		client: client,
-	}, nil
+	codec, err := queue.CodecFromEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	return NewWithClient(client, WithCodec(codec)), nil
 }
 
-func NewWithClient(client sqsiface.SQSAPI) queue.QueueService {
-	return &SQSQueueService{
-		client: client,
+// Create a new SQS queue plugin using the provided client
+func NewWithClient(client sqsiface.SQSAPI, opts ...SQSQueueServiceOption) queue.QueueService {
+	s := &SQSQueueService{
+		client:         client,
+		codec:          &queue.JSONCodec{},
+		queueUrls:      make(map[string]*string),
+		filteredLeases: make(map[string]filteredLease),
 	}
+
+	for _, o := range opts {
+		o.Apply(s)
+	}
+
+	return s
 }
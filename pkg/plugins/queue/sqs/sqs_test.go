@@ -16,8 +16,10 @@ package sqs_service
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/service/sqs"
 	"github.com/golang/mock/gomock"
 	mocks_sqs "github.com/nitrictech/nitric/mocks/sqs"
@@ -127,7 +129,7 @@ var _ = Describe("Sqs", func() {
 					QueueUrl: queueUrl,
 					Entries: []*sqs.SendMessageBatchRequestEntry{
 						{
-							Id:          aws.String("1234"),
+							Id:          aws.String("0"),
 							MessageBody: aws.String(`{"id":"1234","payloadType":"test-payload","payload":{"Test":"Test"}}`),
 						},
 					},
@@ -150,6 +152,69 @@ var _ = Describe("Sqs", func() {
 
 		})
 
+		When("Sending more tasks than fit in a single SQS batch", func() {
+			It("Should split the tasks into batches and return the failed tasks", func() {
+				ctrl := gomock.NewController(GinkgoT())
+				sqsMock := mocks_sqs.NewMockSQSAPI(ctrl)
+				plugin := NewWithClient(sqsMock)
+
+				queueUrl := aws.String("https://example.com/test-queue")
+
+				By("Calling ListQueues to get the queue name")
+				sqsMock.EXPECT().ListQueues(&sqs.ListQueuesInput{}).Times(1).Return(&sqs.ListQueuesOutput{
+					QueueUrls: []*string{queueUrl},
+				}, nil)
+
+				By("Calling ListQueueTags to get the x-nitric-name")
+				sqsMock.EXPECT().ListQueueTags(gomock.Any()).Times(1).Return(&sqs.ListQueueTagsOutput{
+					Tags: map[string]*string{
+						"x-nitric-name": aws.String("test-queue"),
+					},
+				}, nil)
+
+				batchSizes := make([]int, 0)
+				By("Calling SendMessageBatch with at most 10 entries at a time")
+				sqsMock.EXPECT().SendMessageBatch(gomock.Any()).Times(3).DoAndReturn(func(in *sqs.SendMessageBatchInput) (*sqs.SendMessageBatchOutput, error) {
+					batchSizes = append(batchSizes, len(in.Entries))
+					out := &sqs.SendMessageBatchOutput{}
+					for _, e := range in.Entries {
+						if *e.Id == "12" {
+							out.Failed = append(out.Failed, &sqs.BatchResultErrorEntry{
+								Id:      e.Id,
+								Message: aws.String("mock-failure"),
+							})
+						}
+					}
+					return out, nil
+				})
+
+				tasks := make([]queue.NitricTask, 0)
+				for i := 0; i < 25; i++ {
+					tasks = append(tasks, queue.NitricTask{
+						ID:          fmt.Sprintf("task-%d", i),
+						PayloadType: "test-payload",
+						Payload: map[string]interface{}{
+							"Test": "Test",
+						},
+					})
+				}
+
+				resp, err := plugin.SendBatch("test-queue", tasks)
+
+				By("Not returning an error")
+				Expect(err).ShouldNot(HaveOccurred())
+
+				By("Sending every task")
+				Expect(batchSizes).To(Equal([]int{10, 10, 5}))
+
+				By("Returning the failed task")
+				Expect(resp.FailedTasks).To(HaveLen(1))
+				Expect(resp.FailedTasks[0].Task.ID).To(Equal("task-12"))
+				Expect(resp.FailedTasks[0].Message).To(Equal("mock-failure"))
+				ctrl.Finish()
+			})
+		})
+
 		When("Publishing to a queue that doesn't exist", func() {
 			When("List queues returns an error", func() {
 				It("Should fail to publish the message", func() {
@@ -179,6 +244,84 @@ var _ = Describe("Sqs", func() {
 		})
 	})
 
+	// Tests for the Send method
+	Context("Send", func() {
+		When("Sending to a queue that exists", func() {
+			It("Should send the task to the queue, resolving the queue url once", func() {
+				ctrl := gomock.NewController(GinkgoT())
+				sqsMock := mocks_sqs.NewMockSQSAPI(ctrl)
+				plugin := NewWithClient(sqsMock)
+
+				queueUrl := aws.String("https://example.com/test-queue")
+
+				By("Calling ListQueues to get the queue name")
+				sqsMock.EXPECT().ListQueues(&sqs.ListQueuesInput{}).Times(1).Return(&sqs.ListQueuesOutput{
+					QueueUrls: []*string{queueUrl},
+				}, nil)
+
+				By("Calling ListQueueTags to get the x-nitric-name")
+				sqsMock.EXPECT().ListQueueTags(gomock.Any()).Times(1).Return(&sqs.ListQueueTagsOutput{
+					Tags: map[string]*string{
+						"x-nitric-name": aws.String("test-queue"),
+					},
+				}, nil)
+
+				By("Calling SendMessage with the task")
+				sqsMock.EXPECT().SendMessage(&sqs.SendMessageInput{
+					QueueUrl:    queueUrl,
+					MessageBody: aws.String(`{"id":"1234","payloadType":"test-payload","payload":{"Test":"Test"}}`),
+				}).Times(2).Return(&sqs.SendMessageOutput{}, nil)
+
+				task := queue.NitricTask{
+					ID:          "1234",
+					PayloadType: "test-payload",
+					Payload: map[string]interface{}{
+						"Test": "Test",
+					},
+				}
+
+				By("Not returning an error")
+				Expect(plugin.Send("test-queue", task)).ShouldNot(HaveOccurred())
+				Expect(plugin.Send("test-queue", task)).ShouldNot(HaveOccurred())
+				ctrl.Finish()
+			})
+		})
+
+		When("Sending with the raw codec", func() {
+			It("Should send the payload data as the message body", func() {
+				ctrl := gomock.NewController(GinkgoT())
+				sqsMock := mocks_sqs.NewMockSQSAPI(ctrl)
+				plugin := NewWithClient(sqsMock, WithCodec(&queue.RawCodec{}))
+
+				queueUrl := aws.String("https://example.com/test-queue")
+
+				sqsMock.EXPECT().ListQueues(&sqs.ListQueuesInput{}).Times(1).Return(&sqs.ListQueuesOutput{
+					QueueUrls: []*string{queueUrl},
+				}, nil)
+
+				sqsMock.EXPECT().ListQueueTags(gomock.Any()).Times(1).Return(&sqs.ListQueueTagsOutput{
+					Tags: map[string]*string{
+						"x-nitric-name": aws.String("test-queue"),
+					},
+				}, nil)
+
+				By("Calling SendMessage with the payload data")
+				sqsMock.EXPECT().SendMessage(&sqs.SendMessageInput{
+					QueueUrl:    queueUrl,
+					MessageBody: aws.String("encoded-by-the-function"),
+				}).Times(1).Return(&sqs.SendMessageOutput{}, nil)
+
+				Expect(plugin.Send("test-queue", queue.NitricTask{
+					ID: "1234",
+					Payload: map[string]interface{}{
+						queue.RawPayloadKey: "encoded-by-the-function",
+					},
+				})).ShouldNot(HaveOccurred())
+				ctrl.Finish()
+			})
+		})
+	})
+
 	// Tests for the Receive method
 	Context("Receive", func() {
 		When("Receive from a queue that exists", func() {
@@ -288,6 +431,250 @@ var _ = Describe("Sqs", func() {
 					ctrl.Finish()
 				})
 			})
+
+			When("A filter is provided and there is no filtered queue", func() {
+				It("Should return matching tasks and release the others", func() {
+					ctrl := gomock.NewController(GinkgoT())
+					sqsMock := mocks_sqs.NewMockSQSAPI(ctrl)
+					plugin := NewWithClient(sqsMock)
+
+					queueUrl := aws.String("https://example.com/test-queue")
+
+					By("Searching the queue tags for the queue and a filtered queue")
+					sqsMock.EXPECT().ListQueues(&sqs.ListQueuesInput{}).Times(2).Return(&sqs.ListQueuesOutput{
+						QueueUrls: []*string{queueUrl},
+					}, nil)
+
+					sqsMock.EXPECT().ListQueueTags(gomock.Any()).Times(2).Return(&sqs.ListQueueTagsOutput{
+						Tags: map[string]*string{
+							"x-nitric-name": aws.String("mock-queue"),
+						},
+					}, nil)
+
+					By("Receiving from the unfiltered queue")
+					sqsMock.EXPECT().ReceiveMessage(&sqs.ReceiveMessageInput{
+						MaxNumberOfMessages: aws.Int64(int64(10)),
+						MessageAttributeNames: []*string{
+							aws.String(sqs.QueueAttributeNameAll),
+						},
+						QueueUrl: queueUrl,
+					}).Times(1).Return(&sqs.ReceiveMessageOutput{
+						Messages: []*sqs.Message{
+							{
+								ReceiptHandle: aws.String("matchingreceipthandle"),
+								Body:          aws.String(`{"id":"1234","payloadType":"test-payload","attributes":{"priority":"high"}}`),
+							},
+							{
+								ReceiptHandle: aws.String("unmatchedreceipthandle"),
+								Body:          aws.String(`{"id":"5678","payloadType":"test-payload","attributes":{"priority":"low"}}`),
+							},
+						},
+					}, nil)
+
+					By("Making the unmatched message visible again")
+					sqsMock.EXPECT().ChangeMessageVisibilityBatch(&sqs.ChangeMessageVisibilityBatchInput{
+						Entries: []*sqs.ChangeMessageVisibilityBatchRequestEntry{
+							{
+								Id:                aws.String("0"),
+								ReceiptHandle:     aws.String("unmatchedreceipthandle"),
+								VisibilityTimeout: aws.Int64(0),
+							},
+						},
+						QueueUrl: queueUrl,
+					}).Times(1).Return(&sqs.ChangeMessageVisibilityBatchOutput{}, nil)
+
+					depth := uint32(10)
+
+					tasks, err := plugin.Receive(queue.ReceiveOptions{
+						QueueName: "mock-queue",
+						Depth:     &depth,
+						Filter:    map[string]string{"priority": "high"},
+					})
+					Expect(err).ShouldNot(HaveOccurred())
+
+					By("Returning only the matching task")
+					Expect(tasks).To(HaveLen(1))
+					Expect(tasks[0].ID).To(Equal("1234"))
+					Expect(tasks[0].LeaseID).To(Equal("matchingreceipthandle"))
+
+					ctrl.Finish()
+				})
+			})
+
+			When("A filter is provided and there is a filtered queue", func() {
+				It("Should receive from the filtered queue", func() {
+					ctrl := gomock.NewController(GinkgoT())
+					sqsMock := mocks_sqs.NewMockSQSAPI(ctrl)
+					plugin := NewWithClient(sqsMock)
+
+					queueUrl := aws.String("https://example.com/test-queue")
+					filteredUrl := aws.String("https://example.com/test-queue-high")
+
+					sqsMock.EXPECT().ListQueues(&sqs.ListQueuesInput{}).Times(2).Return(&sqs.ListQueuesOutput{
+						QueueUrls: []*string{queueUrl, filteredUrl},
+					}, nil)
+
+					sqsMock.EXPECT().ListQueueTags(&sqs.ListQueueTagsInput{QueueUrl: queueUrl}).AnyTimes().Return(&sqs.ListQueueTagsOutput{
+						Tags: map[string]*string{
+							"x-nitric-name": aws.String("mock-queue"),
+						},
+					}, nil)
+
+					sqsMock.EXPECT().ListQueueTags(&sqs.ListQueueTagsInput{QueueUrl: filteredUrl}).AnyTimes().Return(&sqs.ListQueueTagsOutput{
+						Tags: map[string]*string{
+							"x-nitric-name":   aws.String("mock-queue"),
+							"x-nitric-filter": aws.String("priority=high"),
+						},
+					}, nil)
+
+					By("Calling ReceiveMessage with the filtered queue url")
+					sqsMock.EXPECT().ReceiveMessage(&sqs.ReceiveMessageInput{
+						MaxNumberOfMessages: aws.Int64(int64(10)),
+						MessageAttributeNames: []*string{
+							aws.String(sqs.QueueAttributeNameAll),
+						},
+						QueueUrl: filteredUrl,
+					}).Times(1).Return(&sqs.ReceiveMessageOutput{
+						Messages: []*sqs.Message{
+							{
+								ReceiptHandle: aws.String("mockreceipthandle"),
+								Body:          aws.String(`{"id":"1234","payloadType":"test-payload","attributes":{"priority":"high"}}`),
+							},
+						},
+					}, nil)
+
+					depth := uint32(10)
+
+					tasks, err := plugin.Receive(queue.ReceiveOptions{
+						QueueName: "mock-queue",
+						Depth:     &depth,
+						Filter:    map[string]string{"priority": "high"},
+					})
+					Expect(err).ShouldNot(HaveOccurred())
+					Expect(tasks).To(HaveLen(1))
+
+					By("Completing the task on the filtered queue")
+					sqsMock.EXPECT().DeleteMessage(&sqs.DeleteMessageInput{
+						QueueUrl:      filteredUrl,
+						ReceiptHandle: aws.String("mockreceipthandle"),
+					}).Times(1).Return(&sqs.DeleteMessageOutput{}, nil)
+
+					Expect(plugin.Complete("mock-queue", tasks[0].LeaseID)).ShouldNot(HaveOccurred())
+
+					ctrl.Finish()
+				})
+			})
+
+			When("A wait time is provided", func() {
+				It("Should long poll for the wait time", func() {
+					ctrl := gomock.NewController(GinkgoT())
+					sqsMock := mocks_sqs.NewMockSQSAPI(ctrl)
+					plugin := NewWithClient(sqsMock)
+
+					queueUrl := aws.String("https://example.com/test-queue")
+
+					sqsMock.EXPECT().ListQueues(&sqs.ListQueuesInput{}).Times(1).Return(&sqs.ListQueuesOutput{
+						QueueUrls: []*string{queueUrl},
+					}, nil)
+
+					sqsMock.EXPECT().ListQueueTags(gomock.Any()).Times(1).Return(&sqs.ListQueueTagsOutput{
+						Tags: map[string]*string{
+							"x-nitric-name": aws.String("mock-queue"),
+						},
+					}, nil)
+
+					By("Calling ReceiveMessage with the wait time in seconds")
+					sqsMock.EXPECT().ReceiveMessage(&sqs.ReceiveMessageInput{
+						MaxNumberOfMessages: aws.Int64(int64(1)),
+						MessageAttributeNames: []*string{
+							aws.String(sqs.QueueAttributeNameAll),
+						},
+						QueueUrl:        queueUrl,
+						WaitTimeSeconds: aws.Int64(int64(20)),
+					}).Times(1).Return(&sqs.ReceiveMessageOutput{
+						Messages: []*sqs.Message{},
+					}, nil)
+
+					_, err := plugin.Receive(queue.ReceiveOptions{
+						QueueName: "mock-queue",
+						WaitTime:  20 * time.Second,
+					})
+					Expect(err).ShouldNot(HaveOccurred())
+
+					ctrl.Finish()
+				})
+			})
+
+			When("The wait time exceeds the SQS maximum", func() {
+				It("Should return an invalid argument error", func() {
+					ctrl := gomock.NewController(GinkgoT())
+					sqsMock := mocks_sqs.NewMockSQSAPI(ctrl)
+					plugin := NewWithClient(sqsMock)
+
+					_, err := plugin.Receive(queue.ReceiveOptions{
+						QueueName: "mock-queue",
+						WaitTime:  21 * time.Second,
+					})
+					Expect(err).Should(HaveOccurred())
+					Expect(err.Error()).To(ContainSubstring("waitTime param must be between 0 and 20s"))
+
+					ctrl.Finish()
+				})
+			})
+		})
+
+		// Tests for the LeaseExtend method
+		Context("LeaseExtend", func() {
+			When("The message visibility is successfully changed", func() {
+				It("Should extend the lease", func() {
+					ctrl := gomock.NewController(GinkgoT())
+					sqsMock := mocks_sqs.NewMockSQSAPI(ctrl)
+					plugin := NewWithClient(sqsMock)
+
+					queueUrl := aws.String("https://example.com/test-queue")
+
+					By("Calling ListQueues to get the queue name")
+					sqsMock.EXPECT().ListQueues(&sqs.ListQueuesInput{}).Times(1).Return(&sqs.ListQueuesOutput{
+						QueueUrls: []*string{queueUrl},
+					}, nil)
+
+					By("Calling ListQueueTags to get the x-nitric-name")
+					sqsMock.EXPECT().ListQueueTags(gomock.Any()).Times(1).Return(&sqs.ListQueueTagsOutput{
+						Tags: map[string]*string{
+							"x-nitric-name": aws.String("test-queue"),
+						},
+					}, nil)
+
+					By("Calling ChangeMessageVisibility with the lease id and duration")
+					sqsMock.EXPECT().ChangeMessageVisibility(&sqs.ChangeMessageVisibilityInput{
+						QueueUrl:          queueUrl,
+						ReceiptHandle:     aws.String("lease-id"),
+						VisibilityTimeout: aws.Int64(300),
+					}).Times(1).Return(&sqs.ChangeMessageVisibilityOutput{}, nil)
+
+					err := plugin.LeaseExtend("test-queue", "lease-id", 5*time.Minute)
+
+					By("Not returning an error")
+					Expect(err).ShouldNot(HaveOccurred())
+
+					ctrl.Finish()
+				})
+			})
+
+			When("The duration exceeds the SQS maximum", func() {
+				It("Should return an error without calling SQS", func() {
+					ctrl := gomock.NewController(GinkgoT())
+					sqsMock := mocks_sqs.NewMockSQSAPI(ctrl)
+					plugin := NewWithClient(sqsMock)
+
+					err := plugin.LeaseExtend("test-queue", "lease-id", 13*time.Hour)
+
+					By("Returning an error")
+					Expect(err).Should(HaveOccurred())
+
+					ctrl.Finish()
+				})
+			})
 		})
 
 		// Tests for the Complete method
@@ -364,6 +751,87 @@ var _ = Describe("Sqs", func() {
 					By("returning the error")
 					Expect(err).Should(HaveOccurred())
 
+					By("Classifying it as transient")
+					completeErr, ok := queue.AsCompleteError(err)
+					Expect(ok).To(BeTrue())
+					Expect(completeErr.Retryable()).To(BeTrue())
+
+					ctrl.Finish()
+				})
+			})
+
+			When("The receipt handle has expired", func() {
+				It("Should return a lease expired error", func() {
+					ctrl := gomock.NewController(GinkgoT())
+					sqsMock := mocks_sqs.NewMockSQSAPI(ctrl)
+					plugin := NewWithClient(sqsMock)
+
+					queueUrl := aws.String("http://example.com/queue")
+
+					sqsMock.EXPECT().ListQueues(&sqs.ListQueuesInput{}).Times(1).Return(&sqs.ListQueuesOutput{
+						QueueUrls: []*string{queueUrl},
+					}, nil)
+					sqsMock.EXPECT().ListQueueTags(gomock.Any()).Times(1).Return(&sqs.ListQueueTagsOutput{
+						Tags: map[string]*string{
+							"x-nitric-name": aws.String("test-queue"),
+						},
+					}, nil)
+					sqsMock.EXPECT().DeleteMessage(gomock.Any()).Return(nil, awserr.New(sqs.ErrCodeReceiptHandleIsInvalid, "expired", nil))
+
+					err := plugin.Complete("test-queue", "test-id")
+
+					completeErr, ok := queue.AsCompleteError(err)
+					Expect(ok).To(BeTrue())
+					Expect(completeErr.Reason).To(Equal(queue.CompleteFailure_LeaseExpired))
+
+					ctrl.Finish()
+				})
+			})
+		})
+
+		Context("CompleteBatch", func() {
+			When("Some of the messages fail to delete", func() {
+				It("Should return the outcome of each", func() {
+					ctrl := gomock.NewController(GinkgoT())
+					sqsMock := mocks_sqs.NewMockSQSAPI(ctrl)
+					plugin := NewWithClient(sqsMock)
+
+					queueUrl := aws.String("http://example.com/queue")
+
+					sqsMock.EXPECT().ListQueues(&sqs.ListQueuesInput{}).Times(1).Return(&sqs.ListQueuesOutput{
+						QueueUrls: []*string{queueUrl},
+					}, nil)
+					sqsMock.EXPECT().ListQueueTags(gomock.Any()).Times(1).Return(&sqs.ListQueueTagsOutput{
+						Tags: map[string]*string{
+							"x-nitric-name": aws.String("test-queue"),
+						},
+					}, nil)
+
+					By("Deleting the messages in a single batch")
+					sqsMock.EXPECT().DeleteMessageBatch(&sqs.DeleteMessageBatchInput{
+						QueueUrl: queueUrl,
+						Entries: []*sqs.DeleteMessageBatchRequestEntry{
+							{Id: aws.String("0"), ReceiptHandle: aws.String("lease-1")},
+							{Id: aws.String("1"), ReceiptHandle: aws.String("lease-2")},
+						},
+					}).Times(1).Return(&sqs.DeleteMessageBatchOutput{
+						Successful: []*sqs.DeleteMessageBatchResultEntry{{Id: aws.String("0")}},
+						Failed: []*sqs.BatchResultErrorEntry{{
+							Id:      aws.String("1"),
+							Code:    aws.String(sqs.ErrCodeReceiptHandleIsInvalid),
+							Message: aws.String("expired"),
+						}},
+					}, nil)
+
+					resp, err := plugin.CompleteBatch("test-queue", []string{"lease-1", "lease-2"})
+					Expect(err).ShouldNot(HaveOccurred())
+					Expect(resp.Results).To(HaveLen(2))
+					Expect(resp.Results[0].Err).ToNot(HaveOccurred())
+
+					completeErr, ok := queue.AsCompleteError(resp.Results[1].Err)
+					Expect(ok).To(BeTrue())
+					Expect(completeErr.Reason).To(Equal(queue.CompleteFailure_LeaseExpired))
+
 					ctrl.Finish()
 				})
 			})
@@ -26,4 +26,17 @@ type NitricTask struct {
 	LeaseID     string                 `json:"leaseId,omitempty" log:"LeaseID"`
 	PayloadType string                 `json:"payloadType,omitempty" log:"PayLoadType"`
 	Payload     map[string]interface{} `json:"payload,omitempty"`
+	// Attributes - string metadata used to filter tasks when they're received
+	Attributes map[string]string `json:"attributes,omitempty" log:"Attributes"`
+}
+
+// MatchesFilter - returns true if the task has every attribute in the filter, with the same value
+func (t *NitricTask) MatchesFilter(filter map[string]string) bool {
+	for key, value := range filter {
+		if attr, ok := t.Attributes[key]; !ok || attr != value {
+			return false
+		}
+	}
+
+	return true
 }
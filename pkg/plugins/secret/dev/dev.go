@@ -15,12 +15,13 @@
 package secret_service
 
 import (
-	"bufio"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
-	"strings"
+	"sync"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/nitrictech/nitric/pkg/plugins/errors"
@@ -31,16 +32,60 @@ import (
 
 const DEV_SUB_DIRECTORY = "./secrets/"
 
+// secretFileVersion - a single stored version of a secret
+type secretFileVersion struct {
+	Version string    `json:"version"`
+	Value   []byte    `json:"value"`
+	Created time.Time `json:"created"`
+}
+
+// secretFile - the on disk representation of a secret and all of its versions
+type secretFile struct {
+	Name     string               `json:"name"`
+	Latest   string               `json:"latest"`
+	Versions []*secretFileVersion `json:"versions"`
+}
+
 type DevSecretService struct {
 	secret.UnimplementedSecretPlugin
 	secDir string
+	lock   sync.Mutex
 }
 
-func (s *DevSecretService) secretFileName(sec *secret.Secret, v string) string {
-	filename := fmt.Sprintf("%s_%s.txt", sec.Name, v)
+func (s *DevSecretService) secretFileName(sec *secret.Secret) string {
+	filename := fmt.Sprintf("%s.json", sec.Name)
 	return filepath.Join(s.secDir, filename)
 }
 
+// readSecretFile - reads the stored versions of a secret, returning an empty secret if none have been stored
+func (s *DevSecretService) readSecretFile(sec *secret.Secret) (*secretFile, error) {
+	content, err := ioutil.ReadFile(s.secretFileName(sec))
+	if os.IsNotExist(err) {
+		return &secretFile{
+			Name:     sec.Name,
+			Versions: make([]*secretFileVersion, 0),
+		}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	file := &secretFile{}
+	if err := json.Unmarshal(content, file); err != nil {
+		return nil, err
+	}
+
+	return file, nil
+}
+
+func (s *DevSecretService) writeSecretFile(file *secretFile) error {
+	content, err := json.Marshal(file)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(s.secretFileName(&secret.Secret{Name: file.Name}), content, 0600)
+}
+
 func (s *DevSecretService) Put(sec *secret.Secret, val []byte) (*secret.SecretPutResponse, error) {
 	newErr := errors.ErrorsWithScope(
 		"DevSecretService.Put",
@@ -59,34 +104,34 @@ func (s *DevSecretService) Put(sec *secret.Secret, val []byte) (*secret.SecretPu
 		return nil, newErr(codes.InvalidArgument, "provide non-blank secret value", nil)
 	}
 
-	var versionId = uuid.New().String()
-	//Creates a new file in the form:
-	// DIR/Name_Version.txt
-	file, err := os.Create(s.secretFileName(sec, versionId))
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	file, err := s.readSecretFile(sec)
 	if err != nil {
 		return nil, newErr(
 			codes.FailedPrecondition,
-			"error creating secret store",
+			"error reading secret store",
 			err,
 		)
 	}
-	writer := bufio.NewWriter(file)
-	writer.WriteString(string(val))
-	writer.Flush()
 
-	//Creates a new file as latest
-	latestFile, err := os.Create(s.secretFileName(sec, "latest"))
-	if err != nil {
+	// Every put creates a new version, which becomes the latest
+	versionId := uuid.New().String()
+	file.Versions = append(file.Versions, &secretFileVersion{
+		Version: versionId,
+		Value:   val,
+		Created: time.Now().UTC(),
+	})
+	file.Latest = versionId
+
+	if err := s.writeSecretFile(file); err != nil {
 		return nil, newErr(
 			codes.FailedPrecondition,
-			"error creating latest secret",
+			"error writing secret store",
 			err,
 		)
 	}
-	latestWriter := bufio.NewWriter(latestFile)
-	latestWriter.WriteString(string(val))
-	latestWriter.WriteString("," + versionId)
-	latestWriter.Flush()
 
 	return &secret.SecretPutResponse{
 		SecretVersion: &secret.SecretVersion{
@@ -106,7 +151,7 @@ func (s *DevSecretService) Access(sv *secret.SecretVersion) (*secret.SecretAcces
 		},
 	)
 
-	if sv.Secret.Name == "" {
+	if sv == nil || sv.Secret == nil || sv.Secret.Name == "" {
 		return nil, newErr(
 			codes.InvalidArgument,
 			"provide non-blank name",
@@ -121,25 +166,42 @@ func (s *DevSecretService) Access(sv *secret.SecretVersion) (*secret.SecretAcces
 		)
 	}
 
-	content, err := ioutil.ReadFile(s.secretFileName(sv.Secret, sv.Version))
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	file, err := s.readSecretFile(sv.Secret)
 	if err != nil {
 		return nil, newErr(
-			codes.InvalidArgument,
+			codes.Internal,
 			"error reading secret store",
 			err,
 		)
 	}
 
-	splitContent := strings.Split(string(content), ",")
-	return &secret.SecretAccessResponse{
-		SecretVersion: &secret.SecretVersion{
-			Secret: &secret.Secret{
-				Name: sv.Secret.Name,
-			},
-			Version: splitContent[len(splitContent)-1],
-		},
-		Value: []byte(splitContent[0]),
-	}, nil
+	versionId := sv.Version
+	if versionId == "latest" {
+		versionId = file.Latest
+	}
+
+	for _, v := range file.Versions {
+		if v.Version == versionId {
+			return &secret.SecretAccessResponse{
+				SecretVersion: &secret.SecretVersion{
+					Secret: &secret.Secret{
+						Name: sv.Secret.Name,
+					},
+					Version: v.Version,
+				},
+				Value: v.Value,
+			}, nil
+		}
+	}
+
+	return nil, newErr(
+		codes.NotFound,
+		"secret version not found",
+		nil,
+	)
 }
 
 //Create new secret store
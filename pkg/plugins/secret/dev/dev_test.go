@@ -102,6 +102,20 @@ var _ = Describe("Dev Secret Manager", func() {
 				Expect(response.Value).Should(Equal(testSecretVal))
 			})
 		})
+		When("Getting a previous version of a secret", func() {
+			secretPlugin, _ := secretPlugin.New()
+			It("Should return the value of that version", func() {
+				firstPut, _ := secretPlugin.Put(&testSecret, []byte("first"))
+				secretPlugin.Put(&testSecret, []byte("second"))
+
+				response, err := secretPlugin.Access(firstPut.SecretVersion)
+				By("Not returning an error")
+				Expect(err).ShouldNot(HaveOccurred())
+				By("Returning the first value")
+				Expect(response.SecretVersion.Version).Should(Equal(firstPut.SecretVersion.Version))
+				Expect(response.Value).Should(Equal([]byte("first")))
+			})
+		})
 		When("Getting a secret that doesn't exist", func() {
 			secretPlugin, _ := secretPlugin.New()
 			It("Should return an error", func() {
@@ -16,11 +16,26 @@ package secret
 
 import "fmt"
 
+// The capabilities a secret plugin may support, named after the operations of the secret service
+const (
+	CapabilityPut    = "put"
+	CapabilityAccess = "access"
+)
+
+// BaselineCapabilities - Returns the capabilities supported by every secret plugin
+func BaselineCapabilities() []string {
+	return []string{CapabilityPut, CapabilityAccess}
+}
+
 type SecretService interface {
 	// Put - Creates a new version for a given secret
 	Put(*Secret, []byte) (*SecretPutResponse, error)
 	// Access - Retrieves the value for a given secret version
 	Access(*SecretVersion) (*SecretAccessResponse, error)
+	// Capabilities - Returns the operations supported by the plugin, so clients can degrade gracefully without them
+	Capabilities() []string
+	// Close - Releases the clients and background resources held by the plugin, called once on shutdown
+	Close() error
 }
 
 type UnimplementedSecretPlugin struct {
@@ -36,3 +51,13 @@ func (*UnimplementedSecretPlugin) Put(secret *Secret, value []byte) (*SecretPutR
 func (*UnimplementedSecretPlugin) Access(version *SecretVersion) (*SecretAccessResponse, error) {
 	return nil, fmt.Errorf("UNIMPLEMENTED")
 }
+
+// Capabilities - Plugins support the baseline capabilities unless they declare others
+func (*UnimplementedSecretPlugin) Capabilities() []string {
+	return BaselineCapabilities()
+}
+
+// Close - Plugins without resources to release have nothing to close
+func (*UnimplementedSecretPlugin) Close() error {
+	return nil
+}
@@ -214,3 +214,10 @@ func New() (secret.SecretService, error) {
 		client: client,
 	}, nil
 }
+
+// NewWithClient - Creates a new Secrets Manager plugin using the provided client
+func NewWithClient(client secretsmanageriface.SecretsManagerAPI) (secret.SecretService, error) {
+	return &secretsManagerSecretService{
+		client: client,
+	}, nil
+}
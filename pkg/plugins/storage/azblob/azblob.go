@@ -18,7 +18,9 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"net/http"
 	"net/url"
 	"time"
 
@@ -39,6 +41,44 @@ type AzblobStorageService struct {
 	storage.UnimplementedStoragePlugin
 }
 
+// azblobErrorCode - maps Azure Storage errors to nitric error codes
+func azblobErrorCode(err error) codes.Code {
+	stgErr, ok := err.(azblob.StorageError)
+	if !ok {
+		return codes.Internal
+	}
+
+	switch stgErr.ServiceCode() {
+	case azblob.ServiceCodeBlobNotFound, azblob.ServiceCodeContainerNotFound, azblob.ServiceCodeResourceNotFound:
+		return codes.NotFound
+	case azblob.ServiceCodeContainerBeingDeleted, azblob.ServiceCodeBlobBeingRehydrated:
+		return codes.FailedPrecondition
+	case azblob.ServiceCodeInvalidRange:
+		return codes.OutOfRange
+	}
+
+	if stgErr.Response() == nil {
+		return codes.Internal
+	}
+
+	switch status := stgErr.Response().StatusCode; {
+	case status == 401 || status == 403:
+		return codes.PermissionDenied
+	case status == 404:
+		return codes.NotFound
+	case status == 409 || status == 412:
+		return codes.FailedPrecondition
+	case status == 429:
+		return codes.ResourceExhausted
+	case status >= 500:
+		return codes.Unavailable
+	case status >= 400:
+		return codes.InvalidArgument
+	}
+
+	return codes.Internal
+}
+
 func (a *AzblobStorageService) getBlobUrl(bucket string, key string) azblob_service_iface.AzblobBlockBlobUrlIface {
 	cUrl := a.client.NewContainerURL(bucket)
 	// Get a new blob for the key name
@@ -67,7 +107,7 @@ func (a *AzblobStorageService) Read(bucket string, key string) ([]byte, error) {
 
 	if err != nil {
 		return nil, newErr(
-			codes.Internal,
+			azblobErrorCode(err),
 			"Unable to download blob",
 			err,
 		)
@@ -79,29 +119,90 @@ func (a *AzblobStorageService) Read(bucket string, key string) ([]byte, error) {
 	return ioutil.ReadAll(data)
 }
 
+func (a *AzblobStorageService) ReadRange(bucket string, key string, start int64, end int64) ([]byte, error) {
+	newErr := errors.ErrorsWithScope(
+		"AzblobStorageService.ReadRange",
+		map[string]interface{}{
+			"bucket": bucket,
+			"key":    key,
+			"start":  start,
+			"end":    end,
+		},
+	)
+
+	if err := storage.ValidateRange(start, end); err != nil {
+		return nil, newErr(
+			codes.InvalidArgument,
+			"invalid range",
+			err,
+		)
+	}
+
+	count := int64(azblob.CountToEnd)
+	if end >= 0 {
+		count = end - start + 1
+	}
+
+	blob := a.getBlobUrl(bucket, key)
+	r, err := blob.Download(
+		context.TODO(),
+		start,
+		count,
+		azblob.BlobAccessConditions{},
+		false,
+		azblob.ClientProvidedKeyOptions{},
+	)
+
+	if err != nil {
+		return nil, newErr(
+			azblobErrorCode(err),
+			"Unable to download blob range",
+			err,
+		)
+	}
+
+	data := r.Body(azblob.RetryReaderOptions{MaxRetryRequests: 20})
+
+	return ioutil.ReadAll(data)
+}
+
 func (a *AzblobStorageService) Write(bucket string, key string, object []byte) error {
+	return a.WriteWithMetadata(bucket, key, object, nil)
+}
+
+// WriteWithMetadata - uploads the blob with its Content-Type and Cache-Control as blob properties
+// and any other metadata as blob metadata
+func (a *AzblobStorageService) WriteWithMetadata(bucket string, key string, object []byte, metadata map[string]string) error {
 	newErr := errors.ErrorsWithScope(
-		"AzblobStorageService.Write",
+		"AzblobStorageService.WriteWithMetadata",
 		map[string]interface{}{
 			"bucket": bucket,
 			"key":    key,
 		},
 	)
 
+	meta := storage.ParseMetadata(metadata)
+	if meta.ContentType == "" {
+		meta.ContentType = http.DetectContentType(object)
+	}
+
 	blob := a.getBlobUrl(bucket, key)
 
 	if _, err := blob.Upload(
 		context.TODO(),
 		bytes.NewReader(object),
-		azblob.BlobHTTPHeaders{},
-		azblob.Metadata{},
+		azblob.BlobHTTPHeaders{
+			ContentType:  meta.ContentType,
+			CacheControl: meta.CacheControl,
+		},
+		azblob.Metadata(meta.Custom),
 		azblob.BlobAccessConditions{},
 		azblob.DefaultAccessTier,
 		nil,
 		azblob.ClientProvidedKeyOptions{},
 	); err != nil {
 		return newErr(
-			codes.Internal,
+			azblobErrorCode(err),
 			"Unable to write blob data",
 			err,
 		)
@@ -110,6 +211,41 @@ func (a *AzblobStorageService) Write(bucket string, key string, object []byte) e
 	return nil
 }
 
+// GetMetadata - returns the Content-Type and Cache-Control properties and the metadata of the blob.
+// Azure returns metadata keys in lower case
+func (a *AzblobStorageService) GetMetadata(bucket string, key string) (map[string]string, error) {
+	newErr := errors.ErrorsWithScope(
+		"AzblobStorageService.GetMetadata",
+		map[string]interface{}{
+			"bucket": bucket,
+			"key":    key,
+		},
+	)
+
+	blob := a.getBlobUrl(bucket, key)
+
+	props, err := blob.GetProperties(
+		context.TODO(),
+		azblob.BlobAccessConditions{},
+		azblob.ClientProvidedKeyOptions{},
+	)
+	if err != nil {
+		return nil, newErr(
+			azblobErrorCode(err),
+			"Unable to get blob properties",
+			err,
+		)
+	}
+
+	meta := &storage.ObjectMetadata{
+		ContentType:  props.ContentType(),
+		CacheControl: props.CacheControl(),
+		Custom:       props.NewMetadata(),
+	}
+
+	return meta.ToMap(), nil
+}
+
 func (a *AzblobStorageService) Delete(bucket string, key string) error {
 	newErr := errors.ErrorsWithScope(
 		"AzblobStorageService.Delete",
@@ -128,7 +264,7 @@ func (a *AzblobStorageService) Delete(bucket string, key string) error {
 		azblob.BlobAccessConditions{},
 	); err != nil {
 		return newErr(
-			codes.Internal,
+			azblobErrorCode(err),
 			"Unable to delete blob",
 			err,
 		)
@@ -137,6 +273,85 @@ func (a *AzblobStorageService) Delete(bucket string, key string) error {
 	return nil
 }
 
+// listFiles - returns an iterator over the blobs in the container with keys beginning with the given prefix,
+// listing each segment of blobs once the previous segment has been read
+func (a *AzblobStorageService) listFiles(bucket string, prefix string, newErr errors.ErrorFactory) storage.FileIterator {
+	cUrl := a.client.NewContainerURL(bucket)
+
+	return storage.NewPagedFileIterator(func(pageToken string) ([]*storage.FileInfo, string, error) {
+		marker := azblob.Marker{}
+		if pageToken != "" {
+			marker.Val = &pageToken
+		}
+
+		resp, err := cUrl.ListBlobsFlatSegment(
+			context.TODO(),
+			marker,
+			azblob.ListBlobsSegmentOptions{
+				Prefix: prefix,
+			},
+		)
+
+		if err != nil {
+			return nil, "", newErr(
+				azblobErrorCode(err),
+				"Unable to list blobs",
+				err,
+			)
+		}
+
+		files := make([]*storage.FileInfo, 0, len(resp.Segment.BlobItems))
+		for _, blob := range resp.Segment.BlobItems {
+			files = append(files, &storage.FileInfo{
+				Key: blob.Name,
+			})
+		}
+
+		nextToken := ""
+		if resp.NextMarker.NotDone() && resp.NextMarker.Val != nil {
+			nextToken = *resp.NextMarker.Val
+		}
+
+		return files, nextToken, nil
+	})
+}
+
+// ListFiles - lists the blobs in the container with keys beginning with the given prefix
+func (a *AzblobStorageService) ListFiles(bucket string, prefix string) ([]*storage.FileInfo, error) {
+	newErr := errors.ErrorsWithScope(
+		"AzblobStorageService.ListFiles",
+		map[string]interface{}{
+			"bucket": bucket,
+			"prefix": prefix,
+		},
+	)
+
+	files := make([]*storage.FileInfo, 0)
+	next := a.listFiles(bucket, prefix, newErr)
+
+	for file, err := next(); err != io.EOF; file, err = next() {
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, file)
+	}
+
+	return files, nil
+}
+
+// ListFilesStream - lists the blobs in the container with keys beginning with the given prefix, a segment at a time
+func (a *AzblobStorageService) ListFilesStream(bucket string, prefix string) storage.FileIterator {
+	newErr := errors.ErrorsWithScope(
+		"AzblobStorageService.ListFilesStream",
+		map[string]interface{}{
+			"bucket": bucket,
+			"prefix": prefix,
+		},
+	)
+
+	return a.listFiles(bucket, prefix, newErr)
+}
+
 func (s *AzblobStorageService) PreSignUrl(bucket string, key string, operation storage.Operation, expiry uint32) (string, error) {
 	newErr := errors.ErrorsWithScope(
 		"AzblobStorageService.PreSignUrl",
@@ -154,7 +369,7 @@ func (s *AzblobStorageService) PreSignUrl(bucket string, key string, operation s
 
 	if err != nil {
 		return "", newErr(
-			codes.Internal,
+			azblobErrorCode(err),
 			"could not get user delegation credential",
 			err,
 		)
@@ -205,6 +420,17 @@ func tokenRefresherFromSpt(spt *adal.ServicePrincipalToken) azblob.TokenRefreshe
 	}
 }
 
+// Capabilities - Returns the baseline capabilities along with the optional operations the plugin supports
+func (a *AzblobStorageService) Capabilities() []string {
+	return append(storage.BaselineCapabilities(),
+		storage.CapabilityReadRange,
+		storage.CapabilityMetadata,
+		storage.CapabilityPreSignUrl,
+		storage.CapabilityListFiles,
+		storage.CapabilityListFilesStream,
+	)
+}
+
 // New - Creates a new instance of the AzblobStorageService
 func New() (storage.StorageService, error) {
 	// TODO: Create a default storage account for the stack???
@@ -234,3 +460,10 @@ func New() (storage.StorageService, error) {
 		client: azblob_service_iface.AdaptServiceUrl(client),
 	}, nil
 }
+
+// NewWithClient - Creates a new instance of the AzblobStorageService using the given client
+func NewWithClient(client azblob_service_iface.AzblobServiceUrlIface) (storage.StorageService, error) {
+	return &AzblobStorageService{
+		client: client,
+	}, nil
+}
@@ -18,6 +18,7 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/url"
 	"strings"
@@ -139,7 +140,7 @@ var _ = Describe("Azblob", func() {
 				mockBlob.EXPECT().Upload(
 					gomock.Any(),
 					bytes.NewReader([]byte("test")),
-					azblob.BlobHTTPHeaders{},
+					azblob.BlobHTTPHeaders{ContentType: "text/plain; charset=utf-8"},
 					azblob.Metadata{},
 					azblob.BlobAccessConditions{},
 					azblob.DefaultAccessTier,
@@ -177,7 +178,7 @@ var _ = Describe("Azblob", func() {
 				mockBlob.EXPECT().Upload(
 					gomock.Any(),
 					bytes.NewReader([]byte("test")),
-					azblob.BlobHTTPHeaders{},
+					azblob.BlobHTTPHeaders{ContentType: "text/plain; charset=utf-8"},
 					azblob.Metadata{},
 					azblob.BlobAccessConditions{},
 					azblob.DefaultAccessTier,
@@ -195,6 +196,80 @@ var _ = Describe("Azblob", func() {
 		})
 	})
 
+	Context("WriteWithMetadata", func() {
+		When("Azure returns a successful response", func() {
+			crtl := gomock.NewController(GinkgoT())
+			mockAzblob := mock_azblob.NewMockAzblobServiceUrlIface(crtl)
+			mockContainer := mock_azblob.NewMockAzblobContainerUrlIface(crtl)
+			mockBlob := mock_azblob.NewMockAzblobBlockBlobUrlIface(crtl)
+
+			storagePlugin := &AzblobStorageService{
+				client: mockAzblob,
+			}
+
+			It("should upload the blob with its properties and metadata", func() {
+				mockAzblob.EXPECT().NewContainerURL("my-bucket").Times(1).Return(mockContainer)
+				mockContainer.EXPECT().NewBlockBlobURL("my-blob").Times(1).Return(mockBlob)
+
+				By("Calling Upload with the properties and metadata")
+				mockBlob.EXPECT().Upload(
+					gomock.Any(),
+					bytes.NewReader([]byte("test")),
+					azblob.BlobHTTPHeaders{
+						ContentType:  "application/json",
+						CacheControl: "no-cache",
+					},
+					azblob.Metadata{"owner": "test"},
+					azblob.BlobAccessConditions{},
+					azblob.DefaultAccessTier,
+					nil,
+					azblob.ClientProvidedKeyOptions{},
+				).Times(1).Return(&azblob.BlockBlobUploadResponse{}, nil)
+
+				err := storagePlugin.WriteWithMetadata("my-bucket", "my-blob", []byte("test"), map[string]string{
+					"Content-Type":  "application/json",
+					"Cache-Control": "no-cache",
+					"owner":         "test",
+				})
+
+				By("Not returning an error")
+				Expect(err).ToNot(HaveOccurred())
+
+				crtl.Finish()
+			})
+		})
+	})
+
+	Context("GetMetadata", func() {
+		When("Azure returns an error", func() {
+			crtl := gomock.NewController(GinkgoT())
+			mockAzblob := mock_azblob.NewMockAzblobServiceUrlIface(crtl)
+			mockContainer := mock_azblob.NewMockAzblobContainerUrlIface(crtl)
+			mockBlob := mock_azblob.NewMockAzblobBlockBlobUrlIface(crtl)
+
+			storagePlugin := &AzblobStorageService{
+				client: mockAzblob,
+			}
+
+			It("should return an error", func() {
+				mockAzblob.EXPECT().NewContainerURL("my-bucket").Times(1).Return(mockContainer)
+				mockContainer.EXPECT().NewBlockBlobURL("my-blob").Times(1).Return(mockBlob)
+				mockBlob.EXPECT().GetProperties(
+					gomock.Any(),
+					azblob.BlobAccessConditions{},
+					azblob.ClientProvidedKeyOptions{},
+				).Times(1).Return(nil, fmt.Errorf("mock-error"))
+
+				_, err := storagePlugin.GetMetadata("my-bucket", "my-blob")
+
+				By("returning an error")
+				Expect(err).To(HaveOccurred())
+
+				crtl.Finish()
+			})
+		})
+	})
+
 	Context("Delete", func() {
 		When("Azure returns a successful response", func() {
 			crtl := gomock.NewController(GinkgoT())
@@ -342,4 +417,126 @@ var _ = Describe("Azblob", func() {
 			})
 		})
 	})
+
+	Context("ListFiles", func() {
+		When("Azure returns a successful response", func() {
+			crtl := gomock.NewController(GinkgoT())
+			mockAzblob := mock_azblob.NewMockAzblobServiceUrlIface(crtl)
+			mockContainer := mock_azblob.NewMockAzblobContainerUrlIface(crtl)
+
+			storagePlugin, _ := NewWithClient(mockAzblob)
+
+			It("should return the blobs from every segment", func() {
+				nextMarker := "next"
+				endMarker := ""
+
+				By("Retrieving the Container URL for the requested bucket")
+				mockAzblob.EXPECT().NewContainerURL("my-bucket").Times(1).Return(mockContainer)
+
+				By("Listing each segment with the requested prefix")
+				gomock.InOrder(
+					mockContainer.EXPECT().ListBlobsFlatSegment(
+						gomock.Any(), azblob.Marker{}, azblob.ListBlobsSegmentOptions{Prefix: "images/"},
+					).Return(&azblob.ListBlobsFlatSegmentResponse{
+						Segment: azblob.BlobFlatListSegment{
+							BlobItems: []azblob.BlobItemInternal{{Name: "images/a.png"}},
+						},
+						NextMarker: azblob.Marker{Val: &nextMarker},
+					}, nil),
+					mockContainer.EXPECT().ListBlobsFlatSegment(
+						gomock.Any(), azblob.Marker{Val: &nextMarker}, azblob.ListBlobsSegmentOptions{Prefix: "images/"},
+					).Return(&azblob.ListBlobsFlatSegmentResponse{
+						Segment: azblob.BlobFlatListSegment{
+							BlobItems: []azblob.BlobItemInternal{{Name: "images/b.png"}},
+						},
+						NextMarker: azblob.Marker{Val: &endMarker},
+					}, nil),
+				)
+
+				files, err := storagePlugin.ListFiles("my-bucket", "images/")
+
+				By("Not returning an error")
+				Expect(err).ShouldNot(HaveOccurred())
+
+				By("Returning the keys of all listed blobs")
+				Expect(files).To(HaveLen(2))
+				Expect(files[0].Key).To(Equal("images/a.png"))
+				Expect(files[1].Key).To(Equal("images/b.png"))
+			})
+		})
+
+		When("Azure returns an error", func() {
+			crtl := gomock.NewController(GinkgoT())
+			mockAzblob := mock_azblob.NewMockAzblobServiceUrlIface(crtl)
+			mockContainer := mock_azblob.NewMockAzblobContainerUrlIface(crtl)
+
+			storagePlugin, _ := NewWithClient(mockAzblob)
+
+			It("should return an error", func() {
+				mockAzblob.EXPECT().NewContainerURL("my-bucket").Times(1).Return(mockContainer)
+				mockContainer.EXPECT().ListBlobsFlatSegment(
+					gomock.Any(), gomock.Any(), gomock.Any(),
+				).Return(nil, fmt.Errorf("mock-error"))
+
+				files, err := storagePlugin.ListFiles("my-bucket", "")
+
+				By("Not returning files")
+				Expect(files).To(BeNil())
+
+				By("Returning an error")
+				Expect(err).Should(HaveOccurred())
+			})
+		})
+	})
+
+	Context("ListFilesStream", func() {
+		When("Azure returns more than one segment", func() {
+			crtl := gomock.NewController(GinkgoT())
+			mockAzblob := mock_azblob.NewMockAzblobServiceUrlIface(crtl)
+			mockContainer := mock_azblob.NewMockAzblobContainerUrlIface(crtl)
+
+			storagePlugin, _ := NewWithClient(mockAzblob)
+
+			It("should only list the next segment once the previous segment has been read", func() {
+				nextMarker := "next"
+				endMarker := ""
+
+				mockAzblob.EXPECT().NewContainerURL("my-bucket").Times(1).Return(mockContainer)
+
+				mockContainer.EXPECT().ListBlobsFlatSegment(
+					gomock.Any(), azblob.Marker{}, azblob.ListBlobsSegmentOptions{Prefix: "images/"},
+				).Return(&azblob.ListBlobsFlatSegmentResponse{
+					Segment: azblob.BlobFlatListSegment{
+						BlobItems: []azblob.BlobItemInternal{{Name: "images/a.png"}},
+					},
+					NextMarker: azblob.Marker{Val: &nextMarker},
+				}, nil).Times(1)
+
+				next := storagePlugin.ListFilesStream("my-bucket", "images/")
+
+				By("Returning the first segment's blobs")
+				file, err := next()
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(file.Key).To(Equal("images/a.png"))
+
+				mockContainer.EXPECT().ListBlobsFlatSegment(
+					gomock.Any(), azblob.Marker{Val: &nextMarker}, azblob.ListBlobsSegmentOptions{Prefix: "images/"},
+				).Return(&azblob.ListBlobsFlatSegmentResponse{
+					Segment: azblob.BlobFlatListSegment{
+						BlobItems: []azblob.BlobItemInternal{{Name: "images/b.png"}},
+					},
+					NextMarker: azblob.Marker{Val: &endMarker},
+				}, nil).Times(1)
+
+				By("Listing the next segment when it's needed")
+				file, err = next()
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(file.Key).To(Equal("images/b.png"))
+
+				By("Ending after the last segment")
+				_, err = next()
+				Expect(err).To(Equal(io.EOF))
+			})
+		})
+	})
 })
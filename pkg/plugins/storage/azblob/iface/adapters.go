@@ -52,6 +52,10 @@ func (c containerUrl) NewBlockBlobURL(blob string) AzblobBlockBlobUrlIface {
 	return AdaptBlobUrl(c.c.NewBlockBlobURL(blob))
 }
 
+func (c containerUrl) ListBlobsFlatSegment(ctx context.Context, marker azblob.Marker, o azblob.ListBlobsSegmentOptions) (*azblob.ListBlobsFlatSegmentResponse, error) {
+	return c.c.ListBlobsFlatSegment(ctx, marker, o)
+}
+
 func (c blobUrl) Download(ctx context.Context, offset int64, count int64, bac azblob.BlobAccessConditions, f bool, cpk azblob.ClientProvidedKeyOptions) (AzblobDownloadResponse, error) {
 	return c.c.Download(ctx, offset, count, bac, f, cpk)
 }
@@ -67,3 +71,7 @@ func (c blobUrl) Upload(ctx context.Context, r io.ReadSeeker, h azblob.BlobHTTPH
 func (c blobUrl) Delete(ctx context.Context, dot azblob.DeleteSnapshotsOptionType, bac azblob.BlobAccessConditions) (*azblob.BlobDeleteResponse, error) {
 	return c.c.Delete(ctx, dot, bac)
 }
+
+func (c blobUrl) GetProperties(ctx context.Context, bac azblob.BlobAccessConditions, cpk azblob.ClientProvidedKeyOptions) (*azblob.BlobGetPropertiesResponse, error) {
+	return c.c.GetProperties(ctx, bac, cpk)
+}
@@ -15,10 +15,15 @@
 package boltdb_storage_service
 
 import (
+	"fmt"
+	"net/http"
 	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/nitrictech/nitric/pkg/logger"
 	"github.com/nitrictech/nitric/pkg/utils"
 
 	"github.com/asdine/storm"
@@ -30,20 +35,34 @@ import (
 
 const DEV_SUB_DIRECTORY = "./buckets/"
 
+// BoltStorageService - A storage plugin for local development, nothing is cached between operations
+// so objects written to a bucket's file by other tools are read without restarting the membrane
 type BoltStorageService struct {
 	storage.UnimplementedStoragePlugin
-	dbDir string
+	dbDir         string
+	watch         bool
+	watchInterval time.Duration
+	log           logger.Logger
+	// Reloads bucket files modified by other tools, nil unless watching is enabled
+	watcher *utils.FileWatcher
 }
 
 type Object struct {
 	Key  string `storm:"id"`
 	Data []byte
+	// Metadata - Stored alongside the object's data, objects written before metadata was supported have none
+	Metadata map[string]string
 }
 
 // Write - will create a new item or overwrite an existing item in storage
 func (s *BoltStorageService) Write(bucket string, key string, object []byte) error {
+	return s.WriteWithMetadata(bucket, key, object, nil)
+}
+
+// WriteWithMetadata - will create a new item or overwrite an existing item in storage, storing its metadata with it
+func (s *BoltStorageService) WriteWithMetadata(bucket string, key string, object []byte, metadata map[string]string) error {
 	newErr := errors.ErrorsWithScope(
-		"BoltStorageService.Write",
+		"BoltStorageService.WriteWithMetadata",
 		map[string]interface{}{
 			"bucket":     bucket,
 			"key":        key,
@@ -88,11 +107,17 @@ func (s *BoltStorageService) Write(bucket string, key string, object []byte) err
 			err,
 		)
 	}
-	defer db.Close()
+	defer s.closeDb(db)
+
+	meta := storage.ParseMetadata(metadata)
+	if meta.ContentType == "" {
+		meta.ContentType = http.DetectContentType(object)
+	}
 
 	obj := Object{
-		Key:  key,
-		Data: object,
+		Key:      key,
+		Data:     object,
+		Metadata: meta.ToMap(),
 	}
 
 	err = db.Save(&obj)
@@ -140,7 +165,7 @@ func (s *BoltStorageService) Read(bucket string, key string) ([]byte, error) {
 			err,
 		)
 	}
-	defer db.Close()
+	defer s.closeDb(db)
 
 	var obj = Object{}
 	err = db.One("Key", key, &obj)
@@ -156,6 +181,98 @@ func (s *BoltStorageService) Read(bucket string, key string) ([]byte, error) {
 	return obj.Data, nil
 }
 
+// GetMetadata - returns the metadata stored alongside an item
+func (s *BoltStorageService) GetMetadata(bucket string, key string) (map[string]string, error) {
+	newErr := errors.ErrorsWithScope(
+		"BoltStorageService.GetMetadata",
+		map[string]interface{}{
+			"bucket": bucket,
+			"key":    key,
+		},
+	)
+
+	if bucket == "" {
+		return nil, newErr(
+			codes.InvalidArgument,
+			"provide non-blank bucket",
+			nil,
+		)
+	}
+	if key == "" {
+		return nil, newErr(
+			codes.InvalidArgument,
+			"provide non-blank key",
+			nil,
+		)
+	}
+
+	db, err := s.createDb(bucket)
+	if err != nil {
+		return nil, newErr(
+			codes.FailedPrecondition,
+			"createDb error",
+			err,
+		)
+	}
+	defer s.closeDb(db)
+
+	var obj = Object{}
+	err = db.One("Key", key, &obj)
+	if err != nil {
+		code := codes.Internal
+		if err == storm.ErrNotFound {
+			code = codes.NotFound
+		}
+		return nil, newErr(
+			code,
+			"failed to retrieve key",
+			err,
+		)
+	}
+
+	if obj.Metadata == nil {
+		return map[string]string{}, nil
+	}
+
+	return obj.Metadata, nil
+}
+
+func (s *BoltStorageService) ReadRange(bucket string, key string, start int64, end int64) ([]byte, error) {
+	newErr := errors.ErrorsWithScope(
+		"BoltStorageService.ReadRange",
+		map[string]interface{}{
+			"bucket": bucket,
+			"key":    key,
+			"start":  start,
+			"end":    end,
+		},
+	)
+
+	if err := storage.ValidateRange(start, end); err != nil {
+		return nil, newErr(
+			codes.InvalidArgument,
+			"invalid range",
+			err,
+		)
+	}
+
+	object, err := s.Read(bucket, key)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := storage.SliceRange(object, start, end)
+	if err != nil {
+		return nil, newErr(
+			codes.OutOfRange,
+			"range is out of bounds",
+			err,
+		)
+	}
+
+	return data, nil
+}
+
 // Delete - deletes an item from Storage
 func (s *BoltStorageService) Delete(bucket string, key string) error {
 	newErr := errors.ErrorsWithScope(
@@ -189,7 +306,7 @@ func (s *BoltStorageService) Delete(bucket string, key string) error {
 			err,
 		)
 	}
-	defer db.Close()
+	defer s.closeDb(db)
 
 	doc := Object{
 		Key: key,
@@ -203,31 +320,107 @@ func (s *BoltStorageService) Delete(bucket string, key string) error {
 	// }
 }
 
-// New - Create a new BoltDB Storage plugin
+// Capabilities - Returns the baseline capabilities along with the optional operations the plugin supports
+func (s *BoltStorageService) Capabilities() []string {
+	return append(storage.BaselineCapabilities(),
+		storage.CapabilityReadRange,
+		storage.CapabilityMetadata,
+	)
+}
+
+// Close - Stops watching the bucket files, if enabled
+func (s *BoltStorageService) Close() error {
+	s.watcher.Stop()
+	return nil
+}
+
+// New - Create a new BoltDB Storage plugin, watching the bucket files for changes by other tools when LOCAL_BLOB_WATCH is true
 func New() (storage.StorageService, error) {
 	dbDir := utils.GetEnv("LOCAL_BLOB_DIR", utils.GetRelativeDevPath(DEV_SUB_DIRECTORY))
 
+	opts := []BoltStorageServiceOption{WithDbDir(dbDir)}
+
+	watchEnv := utils.GetEnv("LOCAL_BLOB_WATCH", "false")
+	watch, err := strconv.ParseBool(watchEnv)
+	if err != nil {
+		return nil, fmt.Errorf("invalid LOCAL_BLOB_WATCH env var, expected true or false, got %v", watchEnv)
+	}
+
+	if watch {
+		opts = append(opts,
+			WithWatch(utils.DefaultWatchInterval),
+			WithLogger(logger.NewJSONLogger(os.Stdout, logger.Level_Info)),
+		)
+	}
+
+	return NewWithOptions(opts...)
+}
+
+// NewWithOptions - Create a new BoltDB Storage plugin, storing the bucket files in the dev volume unless configured
+func NewWithOptions(opts ...BoltStorageServiceOption) (storage.StorageService, error) {
+	s := &BoltStorageService{
+		dbDir: utils.GetRelativeDevPath(DEV_SUB_DIRECTORY),
+		log:   logger.NewNoopLogger(),
+	}
+
+	for _, o := range opts {
+		o.Apply(s)
+	}
+
 	// Check whether file exists
-	_, err := os.Stat(dbDir)
+	_, err := os.Stat(s.dbDir)
 	if os.IsNotExist(err) {
 		// Make diretory if not present
-		err := os.MkdirAll(dbDir, 0777)
+		err := os.MkdirAll(s.dbDir, 0777)
 		if err != nil {
 			return nil, err
 		}
 	}
 
-	return &BoltStorageService{dbDir: dbDir}, nil
+	if s.watch {
+		// Nothing is cached between operations, so reopening the file is enough to check it's readable
+		s.watcher, err = utils.NewFileWatcher(filepath.Join(s.dbDir, "*.db"), s.watchInterval, reopenDb, s.log)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return s, nil
 }
 
+// openDb - Opens a bucket file, waiting for any other process holding it to close it
+func openDb(dbPath string) (*storm.DB, error) {
+	options := storm.BoltOptions(0600, &bbolt.Options{Timeout: 1 * time.Second})
+	return storm.Open(dbPath, options)
+}
+
+// reopenDb - Opens and closes a bucket file, checking a file changed by another tool can be read
+func reopenDb(dbPath string) error {
+	db, err := openDb(dbPath)
+	if err != nil {
+		return err
+	}
+
+	return db.Close()
+}
+
+// createDb - Opens the bucket's file. Reloads of watched files are held off until the file is closed with closeDb
 func (s *BoltStorageService) createDb(bucket string) (*storm.DB, error) {
-	dbPath := s.dbDir + strings.ToLower(bucket) + ".db"
+	dbPath := filepath.Join(s.dbDir, strings.ToLower(bucket)+".db")
 
-	options := storm.BoltOptions(0600, &bbolt.Options{Timeout: 1 * time.Second})
-	db, err := storm.Open(dbPath, options)
+	s.watcher.Acquire()
+	db, err := openDb(dbPath)
 	if err != nil {
+		s.watcher.Release(dbPath)
 		return nil, err
 	}
 
 	return db, nil
 }
+
+// closeDb - Closes a file opened with createDb
+func (s *BoltStorageService) closeDb(db *storm.DB) {
+	dbPath := db.Bolt.Path()
+	db.Close()
+	s.watcher.Release(dbPath)
+}
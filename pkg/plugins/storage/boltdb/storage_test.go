@@ -16,12 +16,19 @@ package boltdb_storage_service_test
 
 import (
 	"os"
+	"path/filepath"
+	"time"
 
+	"github.com/nitrictech/nitric/pkg/logger"
+	"github.com/nitrictech/nitric/pkg/plugins/errors"
+	"github.com/nitrictech/nitric/pkg/plugins/errors/codes"
 	boltdb_storage_service "github.com/nitrictech/nitric/pkg/plugins/storage/boltdb"
 	"github.com/nitrictech/nitric/pkg/utils"
 
+	"github.com/asdine/storm"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
 )
 
 const BUCKET = "bucket"
@@ -99,6 +106,39 @@ var _ = Describe("Storage", func() {
 		})
 	})
 
+	Context("Metadata", func() {
+		Context("When an object is written with metadata", func() {
+			It("Should store the metadata alongside the object", func() {
+				err := storagePlugin.WriteWithMetadata(BUCKET, KEY, []byte(DATA), map[string]string{
+					"cache-control": "no-cache",
+					"owner":         "test",
+				})
+				Expect(err).To(BeNil())
+
+				metadata, err := storagePlugin.GetMetadata(BUCKET, KEY)
+				Expect(err).To(BeNil())
+				Expect(metadata).To(Equal(map[string]string{
+					"Content-Type":  "text/plain; charset=utf-8",
+					"Cache-Control": "no-cache",
+					"owner":         "test",
+				}))
+
+				By("Still reading the object's data")
+				data, err := storagePlugin.Read(BUCKET, KEY)
+				Expect(err).To(BeNil())
+				Expect(data).To(BeEquivalentTo([]byte(DATA)))
+			})
+		})
+
+		Context("When the object doesn't exist", func() {
+			It("Should return a not found error", func() {
+				_, err := storagePlugin.GetMetadata(BUCKET, KEY)
+				Expect(err).Should(HaveOccurred())
+				Expect(errors.Code(err)).To(Equal(codes.NotFound))
+			})
+		})
+	})
+
 	Context("Read", func() {
 		Context("When bucket is blank", func() {
 			It("Should return an error", func() {
@@ -128,6 +168,50 @@ var _ = Describe("Storage", func() {
 			})
 		})
 
+		Context("When the object is written by another tool", func() {
+			It("Should read the object without recreating the plugin", func() {
+				Expect(storagePlugin.Write(BUCKET, KEY, []byte(DATA))).To(Succeed())
+
+				By("Updating the bucket file directly")
+				db, err := storm.Open(filepath.Join(local_storage_directory, BUCKET+".db"))
+				Expect(err).To(BeNil())
+				Expect(db.Save(&boltdb_storage_service.Object{Key: KEY, Data: []byte("seeded")})).To(Succeed())
+				Expect(db.Close()).To(Succeed())
+
+				data, err := storagePlugin.Read(BUCKET, KEY)
+				Expect(err).To(BeNil())
+				Expect(data).To(BeEquivalentTo([]byte("seeded")))
+			})
+		})
+
+		Context("When the bucket files are watched", func() {
+			It("Should log a reload of files written by another tool, but not of its own writes", func() {
+				log := gbytes.NewBuffer()
+				watchedPlugin, err := boltdb_storage_service.NewWithOptions(
+					boltdb_storage_service.WithDbDir(local_storage_directory),
+					boltdb_storage_service.WithWatch(10*time.Millisecond),
+					boltdb_storage_service.WithLogger(logger.NewJSONLogger(log, logger.Level_Info)),
+				)
+				Expect(err).To(BeNil())
+				defer watchedPlugin.Close()
+
+				Expect(watchedPlugin.Write(BUCKET, KEY, []byte(DATA))).To(Succeed())
+				Consistently(log, "100ms").ShouldNot(gbytes.Say("reloaded"))
+
+				By("Updating the bucket file directly")
+				db, err := storm.Open(filepath.Join(local_storage_directory, BUCKET+".db"))
+				Expect(err).To(BeNil())
+				Expect(db.Save(&boltdb_storage_service.Object{Key: KEY, Data: []byte("seeded")})).To(Succeed())
+				Expect(db.Close()).To(Succeed())
+
+				Eventually(log).Should(gbytes.Say("reloaded file modified by another tool"))
+
+				data, err := watchedPlugin.Read(BUCKET, KEY)
+				Expect(err).To(BeNil())
+				Expect(data).To(BeEquivalentTo([]byte("seeded")))
+			})
+		})
+
 		Context("Read missing object operation", func() {
 			It("Should return an error", func() {
 				data, err := storagePlugin.Read(BUCKET, "not-found")
@@ -137,6 +221,52 @@ var _ = Describe("Storage", func() {
 		})
 	})
 
+	Context("ReadRange", func() {
+		Context("When the range is within the object", func() {
+			It("Should read the bytes of the range", func() {
+				Expect(storagePlugin.Write(BUCKET, KEY, []byte(DATA))).To(Succeed())
+
+				data, err := storagePlugin.ReadRange(BUCKET, KEY, 1, 2)
+				Expect(err).To(BeNil())
+				Expect(data).To(BeEquivalentTo([]byte("at")))
+			})
+		})
+
+		Context("When the range is open ended or ends beyond the object", func() {
+			It("Should read to the end of the object", func() {
+				Expect(storagePlugin.Write(BUCKET, KEY, []byte(DATA))).To(Succeed())
+
+				data, err := storagePlugin.ReadRange(BUCKET, KEY, 2, -1)
+				Expect(err).To(BeNil())
+				Expect(data).To(BeEquivalentTo([]byte("ta")))
+
+				data, err = storagePlugin.ReadRange(BUCKET, KEY, 2, 100)
+				Expect(err).To(BeNil())
+				Expect(data).To(BeEquivalentTo([]byte("ta")))
+			})
+		})
+
+		Context("When the range starts beyond the object", func() {
+			It("Should return an out of range error", func() {
+				Expect(storagePlugin.Write(BUCKET, KEY, []byte(DATA))).To(Succeed())
+
+				data, err := storagePlugin.ReadRange(BUCKET, KEY, 4, 10)
+				Expect(data).To(BeNil())
+				Expect(errors.Code(err)).To(Equal(codes.OutOfRange))
+			})
+		})
+
+		Context("When the range is invalid", func() {
+			It("Should return an invalid argument error", func() {
+				_, err := storagePlugin.ReadRange(BUCKET, KEY, 2, 1)
+				Expect(errors.Code(err)).To(Equal(codes.InvalidArgument))
+
+				_, err = storagePlugin.ReadRange(BUCKET, KEY, -1, 1)
+				Expect(errors.Code(err)).To(Equal(codes.InvalidArgument))
+			})
+		})
+	})
+
 	Context("Delete", func() {
 		Context("When bucket is blank", func() {
 			It("Should return an error", func() {
@@ -0,0 +1,74 @@
+// Copyright 2021 Nitric Pty Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"net/http"
+)
+
+const (
+	// MetadataContentType - The metadata key of the content type objects are served with
+	MetadataContentType = "Content-Type"
+	// MetadataCacheControl - The metadata key of the cache control objects are served with
+	MetadataCacheControl = "Cache-Control"
+)
+
+// ObjectMetadata - The metadata stored with an object, split into the properties providers store natively
+// and custom key/values
+type ObjectMetadata struct {
+	ContentType  string
+	CacheControl string
+	// Custom key/values, providers may normalize the case of their keys
+	Custom map[string]string
+}
+
+// ParseMetadata - Splits metadata into the content type, cache control and custom key/values.
+// The content type and cache control keys are matched case insensitively
+func ParseMetadata(metadata map[string]string) *ObjectMetadata {
+	parsed := &ObjectMetadata{
+		Custom: make(map[string]string),
+	}
+
+	for key, value := range metadata {
+		switch http.CanonicalHeaderKey(key) {
+		case MetadataContentType:
+			parsed.ContentType = value
+		case MetadataCacheControl:
+			parsed.CacheControl = value
+		default:
+			parsed.Custom[key] = value
+		}
+	}
+
+	return parsed
+}
+
+// ToMap - Returns the metadata as a single map, omitting properties that aren't set
+func (m *ObjectMetadata) ToMap() map[string]string {
+	metadata := make(map[string]string, len(m.Custom)+2)
+	for key, value := range m.Custom {
+		metadata[key] = value
+	}
+
+	if m.ContentType != "" {
+		metadata[MetadataContentType] = m.ContentType
+	}
+
+	if m.CacheControl != "" {
+		metadata[MetadataCacheControl] = m.CacheControl
+	}
+
+	return metadata
+}
@@ -28,11 +28,52 @@ func (op Operation) String() string {
 	return [2]string{"READ", "WRITE"}[op]
 }
 
+// The capabilities a storage plugin may support, named after the operations of the storage service
+const (
+	CapabilityRead            = "read"
+	CapabilityWrite           = "write"
+	CapabilityDelete          = "delete"
+	CapabilityReadRange       = "read_range"
+	CapabilityMetadata        = "metadata"
+	CapabilityPreSignUrl      = "presign_url"
+	CapabilityListFiles       = "list_files"
+	CapabilityListFilesStream = "list_files_stream"
+)
+
+// BaselineCapabilities - Returns the capabilities supported by every storage plugin
+func BaselineCapabilities() []string {
+	return []string{CapabilityRead, CapabilityWrite, CapabilityDelete}
+}
+
+// FileInfo - describes a stored object
+type FileInfo struct {
+	Key string
+}
+
+// FileIterator - Returns the next file of a listing, or io.EOF once every file has been returned
+type FileIterator = func() (*FileInfo, error)
+
 type StorageService interface {
 	Read(bucket string, key string) ([]byte, error)
+	// ReadRange - Reads the bytes from start to end of an object, as in a HTTP Range header the offsets are inclusive.
+	// A negative end reads to the end of the object and an end beyond the end of the object is truncated to it
+	ReadRange(bucket string, key string, start int64, end int64) ([]byte, error)
 	Write(bucket string, key string, object []byte) error
+	// WriteWithMetadata - Writes an object with metadata, e.g. its Content-Type and Cache-Control or custom key/values.
+	// Objects written without a Content-Type have it detected from their content
+	WriteWithMetadata(bucket string, key string, object []byte, metadata map[string]string) error
+	// GetMetadata - Returns the metadata stored with an object
+	GetMetadata(bucket string, key string) (map[string]string, error)
 	Delete(bucket string, key string) error
 	PreSignUrl(bucket string, key string, operation Operation, expiry uint32) (string, error)
+	ListFiles(bucket string, prefix string) ([]*FileInfo, error)
+	// ListFilesStream - Lists the files in a bucket with keys starting with the prefix, fetching pages of files as they're
+	// read, so buckets with millions of keys can be processed without holding every key in memory
+	ListFilesStream(bucket string, prefix string) FileIterator
+	// Capabilities - Returns the operations supported by the plugin, so clients can degrade gracefully without them
+	Capabilities() []string
+	// Close - Releases the clients and background resources held by the plugin, called once on shutdown
+	Close() error
 }
 
 type UnimplementedStoragePlugin struct{}
@@ -43,10 +84,22 @@ func (*UnimplementedStoragePlugin) Read(bucket string, key string) ([]byte, erro
 	return nil, fmt.Errorf("UNIMPLEMENTED")
 }
 
+func (*UnimplementedStoragePlugin) ReadRange(bucket string, key string, start int64, end int64) ([]byte, error) {
+	return nil, fmt.Errorf("UNIMPLEMENTED")
+}
+
 func (*UnimplementedStoragePlugin) Write(bucket string, key string, object []byte) error {
 	return fmt.Errorf("UNIMPLEMENTED")
 }
 
+func (*UnimplementedStoragePlugin) WriteWithMetadata(bucket string, key string, object []byte, metadata map[string]string) error {
+	return fmt.Errorf("UNIMPLEMENTED")
+}
+
+func (*UnimplementedStoragePlugin) GetMetadata(bucket string, key string) (map[string]string, error) {
+	return nil, fmt.Errorf("UNIMPLEMENTED")
+}
+
 func (*UnimplementedStoragePlugin) Delete(bucket string, key string) error {
 	return fmt.Errorf("UNIMPLEMENTED")
 }
@@ -54,3 +107,55 @@ func (*UnimplementedStoragePlugin) Delete(bucket string, key string) error {
 func (*UnimplementedStoragePlugin) PreSignUrl(bucket string, key string, operation Operation, expiry uint32) (string, error) {
 	return "", fmt.Errorf("UNIMPLEMENTED")
 }
+
+func (*UnimplementedStoragePlugin) ListFiles(bucket string, prefix string) ([]*FileInfo, error) {
+	return nil, fmt.Errorf("UNIMPLEMENTED")
+}
+
+func (*UnimplementedStoragePlugin) ListFilesStream(bucket string, prefix string) FileIterator {
+	return func() (*FileInfo, error) {
+		return nil, fmt.Errorf("UNIMPLEMENTED")
+	}
+}
+
+// Capabilities - Plugins support the baseline capabilities unless they declare others
+func (*UnimplementedStoragePlugin) Capabilities() []string {
+	return BaselineCapabilities()
+}
+
+// ValidateRange - Returns an error if start and end aren't a valid range for ReadRange
+func ValidateRange(start int64, end int64) error {
+	if start < 0 {
+		return fmt.Errorf("range start must not be negative, got %d", start)
+	}
+
+	if end >= 0 && end < start {
+		return fmt.Errorf("range end must not be before its start, got %d-%d", start, end)
+	}
+
+	return nil
+}
+
+// SliceRange - Returns the bytes from start to end of an object read in full, following the semantics of ReadRange.
+// Returns an error if the range is invalid or starts beyond the end of the object
+func SliceRange(object []byte, start int64, end int64) ([]byte, error) {
+	if err := ValidateRange(start, end); err != nil {
+		return nil, err
+	}
+
+	size := int64(len(object))
+	if start >= size {
+		return nil, fmt.Errorf("range start %d is beyond the end of the object of %d bytes", start, size)
+	}
+
+	if end < 0 || end >= size {
+		end = size - 1
+	}
+
+	return object[start : end+1], nil
+}
+
+// Close - Plugins without resources to release have nothing to close
+func (*UnimplementedStoragePlugin) Close() error {
+	return nil
+}
@@ -31,3 +31,21 @@ func WithSelector(selector BucketSelector) S3StorageServiceOption {
 		selector: selector,
 	}
 }
+
+type withMultipartUploads struct {
+	thresholdBytes int
+	partBytes      int
+}
+
+func (w *withMultipartUploads) Apply(service *S3StorageService) {
+	service.multipartThresholdBytes = w.thresholdBytes
+	service.multipartPartBytes = w.partBytes
+}
+
+// WithMultipartUploads - Uploads objects larger than thresholdBytes in parts of partBytes, a threshold of 0 disables multipart uploads
+func WithMultipartUploads(thresholdBytes int, partBytes int) S3StorageServiceOption {
+	return &withMultipartUploads{
+		thresholdBytes: thresholdBytes,
+		partBytes:      partBytes,
+	}
+}
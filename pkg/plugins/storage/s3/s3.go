@@ -17,8 +17,10 @@ package s3_service
 import (
 	"bytes"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/nitrictech/nitric/pkg/utils"
@@ -36,13 +38,66 @@ import (
 const (
 	// ErrCodeNoSuchTagSet - AWS API neglects to include a constant for this error code.
 	ErrCodeNoSuchTagSet = "NoSuchTagSet"
+	// ErrCodeAccessDenied - AWS API neglects to include a constant for this error code.
+	ErrCodeAccessDenied = "AccessDenied"
+	// ErrCodeSlowDown - AWS API neglects to include a constant for this error code.
+	ErrCodeSlowDown = "SlowDown"
+	// ErrCodeInvalidRange - AWS API neglects to include a constant for this error code.
+	ErrCodeInvalidRange = "InvalidRange"
 )
 
+const (
+	// DefaultMultipartThresholdBytes - Objects larger than this are written using multipart uploads by default
+	DefaultMultipartThresholdBytes = 64 * 1024 * 1024
+	// DefaultMultipartPartBytes - The size of each part of a multipart upload by default
+	DefaultMultipartPartBytes = 16 * 1024 * 1024
+	// MinMultipartPartBytes - The smallest part S3 accepts, other than the last part of an upload
+	MinMultipartPartBytes = 5 * 1024 * 1024
+)
+
+// listPageSize - The number of objects requested per page when streaming a listing, the most S3 returns
+const listPageSize int64 = 1000
+
 // S3StorageService - Is the concrete implementation of AWS S3 for the Nitric Storage Plugin
 type S3StorageService struct {
-	//storage.UnimplementedStoragePlugin
+	storage.UnimplementedStoragePlugin
 	client   s3iface.S3API
 	selector BucketSelector
+	// Objects larger than this are uploaded in parts of multipartPartBytes, 0 disables multipart uploads
+	multipartThresholdBytes int
+	multipartPartBytes      int
+}
+
+// s3ErrorCode - Maps an S3 API error to a nitric error code
+func s3ErrorCode(err error) codes.Code {
+	if awsErr, ok := err.(awserr.Error); ok {
+		switch awsErr.Code() {
+		case s3.ErrCodeNoSuchBucket, s3.ErrCodeNoSuchKey, s3.ErrCodeNoSuchUpload:
+			return codes.NotFound
+		case ErrCodeAccessDenied:
+			return codes.PermissionDenied
+		case ErrCodeSlowDown:
+			return codes.ResourceExhausted
+		case ErrCodeInvalidRange:
+			return codes.OutOfRange
+		}
+	}
+
+	if reqErr, ok := err.(awserr.RequestFailure); ok {
+		if reqErr.StatusCode() == 404 {
+			return codes.NotFound
+		} else if reqErr.StatusCode() == 403 {
+			return codes.PermissionDenied
+		} else if reqErr.StatusCode() == 416 {
+			return codes.OutOfRange
+		} else if reqErr.StatusCode() >= 500 {
+			return codes.Unavailable
+		} else if reqErr.StatusCode() >= 400 {
+			return codes.FailedPrecondition
+		}
+	}
+
+	return codes.Internal
 }
 
 type BucketSelector = func(nitricName string, b *s3.Bucket) (bool, error)
@@ -94,7 +149,7 @@ func (s *S3StorageService) getBucketByName(bucket string) (*s3.Bucket, error) {
 		}
 
 		if selectErr != nil {
-			return nil, err
+			return nil, selectErr
 		}
 
 		if selected {
@@ -123,15 +178,23 @@ func (s *S3StorageService) Read(bucket string, key string) ([]byte, error) {
 
 		if err != nil {
 			return nil, newErr(
-				codes.NotFound,
+				s3ErrorCode(err),
 				"error retrieving key",
 				err,
 			)
 		}
 
 		defer resp.Body.Close()
-		//TODO: Wrap the possible error from ReadAll
-		return ioutil.ReadAll(resp.Body)
+		object, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			return nil, newErr(
+				codes.Unavailable,
+				"error reading object",
+				err,
+			)
+		}
+
+		return object, nil
 	} else {
 		return nil, newErr(
 			codes.NotFound,
@@ -141,10 +204,81 @@ func (s *S3StorageService) Read(bucket string, key string) ([]byte, error) {
 	}
 }
 
+// rangeHeader - Returns the HTTP Range header value for an inclusive byte range, open ended when end is negative
+func rangeHeader(start int64, end int64) string {
+	if end < 0 {
+		return fmt.Sprintf("bytes=%d-", start)
+	}
+
+	return fmt.Sprintf("bytes=%d-%d", start, end)
+}
+
+// ReadRange - Reads a range of bytes from an item in a bucket
+func (s *S3StorageService) ReadRange(bucket string, key string, start int64, end int64) ([]byte, error) {
+	newErr := errors.ErrorsWithScope(
+		"S3StorageService.ReadRange",
+		map[string]interface{}{
+			"bucket": bucket,
+			"key":    key,
+			"start":  start,
+			"end":    end,
+		},
+	)
+
+	if err := storage.ValidateRange(start, end); err != nil {
+		return nil, newErr(
+			codes.InvalidArgument,
+			"invalid range",
+			err,
+		)
+	}
+
+	b, err := s.getBucketByName(bucket)
+	if err != nil {
+		return nil, newErr(
+			codes.NotFound,
+			"unable to locate bucket",
+			err,
+		)
+	}
+
+	resp, err := s.client.GetObject(&s3.GetObjectInput{
+		Bucket: b.Name,
+		Key:    aws.String(key),
+		Range:  aws.String(rangeHeader(start, end)),
+	})
+
+	if err != nil {
+		return nil, newErr(
+			s3ErrorCode(err),
+			"error retrieving key range",
+			err,
+		)
+	}
+
+	defer resp.Body.Close()
+	object, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, newErr(
+			codes.Unavailable,
+			"error reading object",
+			err,
+		)
+	}
+
+	return object, nil
+}
+
 // Write - Writes an item to a bucket
 func (s *S3StorageService) Write(bucket string, key string, object []byte) error {
+	return s.WriteWithMetadata(bucket, key, object, nil)
+}
+
+// WriteWithMetadata - Writes an item to a bucket, storing its Content-Type and Cache-Control as object properties
+// and any other metadata as user-defined object metadata
+func (s *S3StorageService) WriteWithMetadata(bucket string, key string, object []byte, metadata map[string]string) error {
 	newErr := errors.ErrorsWithScope(
-		"S3StorageService.Write",
+		"S3StorageService.WriteWithMetadata",
 		map[string]interface{}{
 			"bucket":     bucket,
 			"key":        key,
@@ -153,16 +287,33 @@ func (s *S3StorageService) Write(bucket string, key string, object []byte) error
 	)
 
 	if b, err := s.getBucketByName(bucket); err == nil {
-		contentType := http.DetectContentType(object)
+		meta := storage.ParseMetadata(metadata)
+		if meta.ContentType == "" {
+			meta.ContentType = http.DetectContentType(object)
+		}
+
+		if s.multipartThresholdBytes > 0 && s.multipartPartBytes > 0 && len(object) > s.multipartThresholdBytes {
+			if err := s.writeMultipart(b.Name, key, meta, bytes.NewReader(object)); err != nil {
+				return newErr(
+					s3ErrorCode(err),
+					"unable to upload object",
+					err,
+				)
+			}
+
+			return nil
+		}
 
 		if _, err := s.client.PutObject(&s3.PutObjectInput{
-			Bucket:      b.Name,
-			Body:        bytes.NewReader(object),
-			ContentType: &contentType,
-			Key:         aws.String(key),
+			Bucket:       b.Name,
+			Body:         bytes.NewReader(object),
+			ContentType:  aws.String(meta.ContentType),
+			CacheControl: optionalString(meta.CacheControl),
+			Metadata:     aws.StringMap(meta.Custom),
+			Key:          aws.String(key),
 		}); err != nil {
 			return newErr(
-				codes.Internal,
+				s3ErrorCode(err),
 				"unable to put object",
 				err,
 			)
@@ -178,6 +329,128 @@ func (s *S3StorageService) Write(bucket string, key string, object []byte) error
 	return nil
 }
 
+// writeMultipart - Uploads an object in parts read from body, so only a single part is buffered at a time.
+// The upload is aborted if any part fails, so incomplete parts aren't left stored
+func (s *S3StorageService) writeMultipart(bucket *string, key string, meta *storage.ObjectMetadata, body io.Reader) error {
+	upload, err := s.client.CreateMultipartUpload(&s3.CreateMultipartUploadInput{
+		Bucket:       bucket,
+		Key:          aws.String(key),
+		ContentType:  aws.String(meta.ContentType),
+		CacheControl: optionalString(meta.CacheControl),
+		Metadata:     aws.StringMap(meta.Custom),
+	})
+	if err != nil {
+		return err
+	}
+
+	abort := func(err error) error {
+		// The part error is more useful to the caller than any error aborting the upload
+		_, _ = s.client.AbortMultipartUpload(&s3.AbortMultipartUploadInput{
+			Bucket:   bucket,
+			Key:      aws.String(key),
+			UploadId: upload.UploadId,
+		})
+		return err
+	}
+
+	parts := make([]*s3.CompletedPart, 0)
+	part := make([]byte, s.multipartPartBytes)
+
+	for partNumber := int64(1); ; partNumber++ {
+		n, readErr := io.ReadFull(body, part)
+		if readErr != nil && readErr != io.EOF && readErr != io.ErrUnexpectedEOF {
+			return abort(readErr)
+		}
+
+		if n == 0 {
+			break
+		}
+
+		out, err := s.client.UploadPart(&s3.UploadPartInput{
+			Bucket:     bucket,
+			Key:        aws.String(key),
+			UploadId:   upload.UploadId,
+			PartNumber: aws.Int64(partNumber),
+			Body:       bytes.NewReader(part[:n]),
+		})
+		if err != nil {
+			return abort(err)
+		}
+
+		parts = append(parts, &s3.CompletedPart{
+			ETag:       out.ETag,
+			PartNumber: aws.Int64(partNumber),
+		})
+
+		if readErr != nil {
+			break
+		}
+	}
+
+	if _, err := s.client.CompleteMultipartUpload(&s3.CompleteMultipartUploadInput{
+		Bucket:   bucket,
+		Key:      aws.String(key),
+		UploadId: upload.UploadId,
+		MultipartUpload: &s3.CompletedMultipartUpload{
+			Parts: parts,
+		},
+	}); err != nil {
+		return abort(err)
+	}
+
+	return nil
+}
+
+// optionalString - Returns nil for an empty string, so unset properties aren't sent to S3
+func optionalString(value string) *string {
+	if value == "" {
+		return nil
+	}
+
+	return aws.String(value)
+}
+
+// GetMetadata - Returns the Content-Type, Cache-Control and user-defined metadata of an object.
+// S3 returns user-defined metadata keys in canonical header case
+func (s *S3StorageService) GetMetadata(bucket string, key string) (map[string]string, error) {
+	newErr := errors.ErrorsWithScope(
+		"S3StorageService.GetMetadata",
+		map[string]interface{}{
+			"bucket": bucket,
+			"key":    key,
+		},
+	)
+
+	b, err := s.getBucketByName(bucket)
+	if err != nil {
+		return nil, newErr(
+			codes.NotFound,
+			"unable to locate bucket",
+			err,
+		)
+	}
+
+	resp, err := s.client.HeadObject(&s3.HeadObjectInput{
+		Bucket: b.Name,
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, newErr(
+			s3ErrorCode(err),
+			"error retrieving object metadata",
+			err,
+		)
+	}
+
+	meta := &storage.ObjectMetadata{
+		ContentType:  aws.StringValue(resp.ContentType),
+		CacheControl: aws.StringValue(resp.CacheControl),
+		Custom:       aws.StringValueMap(resp.Metadata),
+	}
+
+	return meta.ToMap(), nil
+}
+
 // Delete - Deletes an item from a bucket
 func (s *S3StorageService) Delete(bucket string, key string) error {
 	newErr := errors.ErrorsWithScope(
@@ -195,7 +468,7 @@ func (s *S3StorageService) Delete(bucket string, key string) error {
 			Key:    aws.String(key),
 		}); err != nil {
 			return newErr(
-				codes.Internal,
+				s3ErrorCode(err),
 				"unable to delete object",
 				err,
 			)
@@ -265,6 +538,113 @@ func (s *S3StorageService) PreSignUrl(bucket string, key string, operation stora
 	}
 }
 
+// ListFiles - Lists the objects in a bucket with keys starting with the prefix
+func (s *S3StorageService) ListFiles(bucket string, prefix string) ([]*storage.FileInfo, error) {
+	newErr := errors.ErrorsWithScope(
+		"S3StorageService.ListFiles",
+		map[string]interface{}{
+			"bucket": bucket,
+			"prefix": prefix,
+		},
+	)
+
+	b, err := s.getBucketByName(bucket)
+	if err != nil {
+		return nil, newErr(
+			codes.NotFound,
+			"unable to locate bucket",
+			err,
+		)
+	}
+
+	files := make([]*storage.FileInfo, 0)
+	err = s.client.ListObjectsV2Pages(&s3.ListObjectsV2Input{
+		Bucket: b.Name,
+		Prefix: aws.String(prefix),
+	}, func(page *s3.ListObjectsV2Output, lastPage bool) bool {
+		for _, o := range page.Contents {
+			files = append(files, &storage.FileInfo{
+				Key: aws.StringValue(o.Key),
+			})
+		}
+		return true
+	})
+
+	if err != nil {
+		return nil, newErr(
+			s3ErrorCode(err),
+			"unable to list objects",
+			err,
+		)
+	}
+
+	return files, nil
+}
+
+// ListFilesStream - Lists the objects in a bucket with keys starting with the prefix, requesting each page of objects once
+// the previous page has been read
+func (s *S3StorageService) ListFilesStream(bucket string, prefix string) storage.FileIterator {
+	newErr := errors.ErrorsWithScope(
+		"S3StorageService.ListFilesStream",
+		map[string]interface{}{
+			"bucket": bucket,
+			"prefix": prefix,
+		},
+	)
+
+	b, err := s.getBucketByName(bucket)
+	if err != nil {
+		// Return an error only iterator
+		return func() (*storage.FileInfo, error) {
+			return nil, newErr(
+				codes.NotFound,
+				"unable to locate bucket",
+				err,
+			)
+		}
+	}
+
+	return storage.NewPagedFileIterator(func(pageToken string) ([]*storage.FileInfo, string, error) {
+		input := &s3.ListObjectsV2Input{
+			Bucket:  b.Name,
+			Prefix:  aws.String(prefix),
+			MaxKeys: aws.Int64(listPageSize),
+		}
+		if pageToken != "" {
+			input.ContinuationToken = aws.String(pageToken)
+		}
+
+		page, err := s.client.ListObjectsV2(input)
+		if err != nil {
+			return nil, "", newErr(
+				s3ErrorCode(err),
+				"unable to list objects",
+				err,
+			)
+		}
+
+		files := make([]*storage.FileInfo, 0, len(page.Contents))
+		for _, o := range page.Contents {
+			files = append(files, &storage.FileInfo{
+				Key: aws.StringValue(o.Key),
+			})
+		}
+
+		return files, aws.StringValue(page.NextContinuationToken), nil
+	})
+}
+
+// Capabilities - Returns the baseline capabilities along with the optional operations the plugin supports
+func (s *S3StorageService) Capabilities() []string {
+	return append(storage.BaselineCapabilities(),
+		storage.CapabilityReadRange,
+		storage.CapabilityMetadata,
+		storage.CapabilityPreSignUrl,
+		storage.CapabilityListFiles,
+		storage.CapabilityListFilesStream,
+	)
+}
+
 // New creates a new default S3 storage plugin
 func New() (storage.StorageService, error) {
 	awsRegion := utils.GetEnv("AWS_REGION", "us-east-1")
@@ -280,15 +660,27 @@ func New() (storage.StorageService, error) {
 
 	s3Client := s3.New(sess)
 
-	return &S3StorageService{
-		client: s3Client,
-	}, nil
+	thresholdEnv := utils.GetEnv("S3_MULTIPART_THRESHOLD_BYTES", strconv.Itoa(DefaultMultipartThresholdBytes))
+	threshold, err := strconv.Atoi(thresholdEnv)
+	if err != nil || threshold < 0 {
+		return nil, fmt.Errorf("invalid S3_MULTIPART_THRESHOLD_BYTES env var, expected non-negative integer value, got %v", thresholdEnv)
+	}
+
+	partEnv := utils.GetEnv("S3_MULTIPART_PART_BYTES", strconv.Itoa(DefaultMultipartPartBytes))
+	partBytes, err := strconv.Atoi(partEnv)
+	if err != nil || partBytes < MinMultipartPartBytes {
+		return nil, fmt.Errorf("invalid S3_MULTIPART_PART_BYTES env var, expected integer value of at least %d, got %v", MinMultipartPartBytes, partEnv)
+	}
+
+	return NewWithClient(s3Client, WithMultipartUploads(threshold, partBytes))
 }
 
 // NewWithClient creates a new S3 Storage plugin and injects the given client
 func NewWithClient(client s3iface.S3API, opts ...S3StorageServiceOption) (storage.StorageService, error) {
 	s3Client := &S3StorageService{
-		client: client,
+		client:                  client,
+		multipartThresholdBytes: DefaultMultipartThresholdBytes,
+		multipartPartBytes:      DefaultMultipartPartBytes,
 	}
 
 	for _, o := range opts {
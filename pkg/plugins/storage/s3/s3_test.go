@@ -15,7 +15,9 @@
 package s3_service_test
 
 import (
+	"bytes"
 	"fmt"
+	"io"
 	"net/http"
 	"net/url"
 
@@ -24,6 +26,8 @@ import (
 	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/golang/mock/gomock"
 	mock_s3iface "github.com/nitrictech/nitric/mocks/s3"
+	"github.com/nitrictech/nitric/pkg/plugins/errors"
+	"github.com/nitrictech/nitric/pkg/plugins/errors/codes"
 	s3_service "github.com/nitrictech/nitric/pkg/plugins/storage/s3"
 	mock_s3 "github.com/nitrictech/nitric/tests/mocks/s3"
 	. "github.com/onsi/ginkgo"
@@ -56,6 +60,75 @@ var _ = Describe("S3", func() {
 				})
 			})
 
+			When("Creating an object larger than the multipart threshold", func() {
+				testPayload := bytes.Repeat([]byte("a"), 25)
+				storage := make(map[string]map[string][]byte)
+				mockStorageClient := mock_s3.NewStorageClient([]*mock_s3.MockBucket{
+					{
+						Name: "my-bucket",
+						Tags: map[string]string{
+							"x-nitric-name": "my-bucket",
+						},
+					},
+				}, &storage)
+
+				storagePlugin, _ := s3_service.NewWithClient(mockStorageClient, s3_service.WithMultipartUploads(20, 10))
+				It("Should upload the object in parts", func() {
+					err := storagePlugin.Write("my-bucket", "test-item", testPayload)
+					By("Not returning an error")
+					Expect(err).ShouldNot(HaveOccurred())
+
+					By("Uploading parts no larger than the part size")
+					Expect(mockStorageClient.(*mock_s3.MockS3Client).UploadedParts["test-item"]).To(Equal([]int{10, 10, 5}))
+
+					By("Storing the complete item")
+					Expect(storage["my-bucket"]["test-item"]).To(BeEquivalentTo(testPayload))
+				})
+			})
+
+			When("Creating an object with metadata", func() {
+				storage := make(map[string]map[string][]byte)
+				mockStorageClient := mock_s3.NewStorageClient([]*mock_s3.MockBucket{
+					{
+						Name: "my-bucket",
+						Tags: map[string]string{
+							"x-nitric-name": "my-bucket",
+						},
+					},
+				}, &storage)
+
+				storagePlugin, _ := s3_service.NewWithClient(mockStorageClient)
+				It("Should store the metadata with the object", func() {
+					err := storagePlugin.WriteWithMetadata("my-bucket", "test-item", []byte("Test"), map[string]string{
+						"content-type":  "application/json",
+						"Cache-Control": "no-cache",
+						"owner":         "test",
+					})
+					By("Not returning an error")
+					Expect(err).ShouldNot(HaveOccurred())
+
+					By("Returning the metadata")
+					metadata, err := storagePlugin.GetMetadata("my-bucket", "test-item")
+					Expect(err).ShouldNot(HaveOccurred())
+					Expect(metadata).To(Equal(map[string]string{
+						"Content-Type":  "application/json",
+						"Cache-Control": "no-cache",
+						"owner":         "test",
+					}))
+				})
+
+				It("Should detect the content type when it isn't provided", func() {
+					err := storagePlugin.WriteWithMetadata("my-bucket", "test-text", []byte("Test"), map[string]string{
+						"owner": "test",
+					})
+					Expect(err).ShouldNot(HaveOccurred())
+
+					metadata, err := storagePlugin.GetMetadata("my-bucket", "test-text")
+					Expect(err).ShouldNot(HaveOccurred())
+					Expect(metadata["Content-Type"]).To(Equal("text/plain; charset=utf-8"))
+				})
+			})
+
 			When("Creating an object in a non-existent bucket", func() {
 				storage := make(map[string]map[string][]byte)
 				mockStorageClient := mock_s3.NewStorageClient([]*mock_s3.MockBucket{}, &storage)
@@ -140,6 +213,93 @@ var _ = Describe("S3", func() {
 			})
 		})
 	})
+	When("ListFiles", func() {
+		When("The bucket exists", func() {
+			storage := make(map[string]map[string][]byte)
+			storage["test-bucket"] = map[string][]byte{
+				"images/a.png": []byte("a"),
+				"images/b.png": []byte("b"),
+				"docs/c.txt":   []byte("c"),
+			}
+			mockStorageClient := mock_s3.NewStorageClient([]*mock_s3.MockBucket{
+				{
+					Name: "test-bucket",
+					Tags: map[string]string{
+						"x-nitric-name": "test-bucket",
+					},
+				},
+			}, &storage)
+			storagePlugin, _ := s3_service.NewWithClient(mockStorageClient)
+
+			It("Should list the objects starting with the prefix", func() {
+				files, err := storagePlugin.ListFiles("test-bucket", "images/")
+				Expect(err).ShouldNot(HaveOccurred())
+
+				keys := make([]string, 0)
+				for _, f := range files {
+					keys = append(keys, f.Key)
+				}
+				Expect(keys).To(ConsistOf("images/a.png", "images/b.png"))
+			})
+		})
+
+		When("The bucket doesn't exist", func() {
+			storage := make(map[string]map[string][]byte)
+			mockStorageClient := mock_s3.NewStorageClient([]*mock_s3.MockBucket{}, &storage)
+			storagePlugin, _ := s3_service.NewWithClient(mockStorageClient)
+
+			It("Should return a NotFound error", func() {
+				_, err := storagePlugin.ListFiles("test-bucket", "")
+				Expect(errors.Code(err)).To(Equal(codes.NotFound))
+			})
+		})
+	})
+	When("ListFilesStream", func() {
+		When("The listing spans several pages", func() {
+			storage := make(map[string]map[string][]byte)
+			storage["test-bucket"] = map[string][]byte{
+				"docs/c.txt": []byte("c"),
+			}
+			for i := 0; i < 2500; i++ {
+				storage["test-bucket"][fmt.Sprintf("logs/%04d.log", i)] = []byte("log")
+			}
+			mockStorageClient := mock_s3.NewStorageClient([]*mock_s3.MockBucket{
+				{
+					Name: "test-bucket",
+					Tags: map[string]string{
+						"x-nitric-name": "test-bucket",
+					},
+				},
+			}, &storage)
+			storagePlugin, _ := s3_service.NewWithClient(mockStorageClient)
+
+			It("Should return every object starting with the prefix in order", func() {
+				next := storagePlugin.ListFilesStream("test-bucket", "logs/")
+
+				keys := make([]string, 0)
+				for file, err := next(); err != io.EOF; file, err = next() {
+					Expect(err).ShouldNot(HaveOccurred())
+					keys = append(keys, file.Key)
+				}
+
+				Expect(keys).To(HaveLen(2500))
+				Expect(keys[0]).To(Equal("logs/0000.log"))
+				Expect(keys[1000]).To(Equal("logs/1000.log"))
+				Expect(keys[2499]).To(Equal("logs/2499.log"))
+			})
+		})
+
+		When("The bucket doesn't exist", func() {
+			storage := make(map[string]map[string][]byte)
+			mockStorageClient := mock_s3.NewStorageClient([]*mock_s3.MockBucket{}, &storage)
+			storagePlugin, _ := s3_service.NewWithClient(mockStorageClient)
+
+			It("Should return a NotFound error", func() {
+				_, err := storagePlugin.ListFilesStream("test-bucket", "")()
+				Expect(errors.Code(err)).To(Equal(codes.NotFound))
+			})
+		})
+	})
 	When("PreSignUrl", func() {
 		When("The bucket exists", func() {
 			// Set up a mock bucket, with a single item
@@ -0,0 +1,36 @@
+// Copyright 2021 Nitric Pty Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage_service
+
+import "cloud.google.com/go/storage"
+
+type StorageStorageServiceOption interface {
+	Apply(*StorageStorageService)
+}
+
+type withSigningOptions struct {
+	signingOptions *storage.SignedURLOptions
+}
+
+func (w *withSigningOptions) Apply(service *StorageStorageService) {
+	service.signingOptions = w.signingOptions
+}
+
+// WithSigningOptions - sets the service account identity and key used to sign URLs
+func WithSigningOptions(signingOptions *storage.SignedURLOptions) StorageStorageServiceOption {
+	return &withSigningOptions{
+		signingOptions: signingOptions,
+	}
+}
@@ -16,27 +16,38 @@ package storage_service
 
 import (
 	"context"
+	"encoding/base64"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"time"
 
 	ifaces_gcloud_storage "github.com/nitrictech/nitric/pkg/ifaces/gcloud_storage"
 
+	"cloud.google.com/go/compute/metadata"
 	"cloud.google.com/go/storage"
 	"github.com/nitrictech/nitric/pkg/plugins/errors"
 	"github.com/nitrictech/nitric/pkg/plugins/errors/codes"
 	plugin "github.com/nitrictech/nitric/pkg/plugins/storage"
 	"golang.org/x/oauth2/google"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/iamcredentials/v1"
 	"google.golang.org/api/iterator"
 	"google.golang.org/api/option"
 )
 
+// The maximum expiry of a V4 signed URL
+const maxSignedUrlExpiry = 7 * 24 * time.Hour
+
 type StorageStorageService struct {
 	plugin.UnimplementedStoragePlugin
 	client    ifaces_gcloud_storage.StorageClient
 	projectID string
+	// The identity and key used to sign URLs, signing is unavailable if nil
+	signingOptions *storage.SignedURLOptions
 }
 
-func (s *StorageStorageService) getBucketByName(bucket string) (ifaces_gcloud_storage.BucketHandle, error) {
+func (s *StorageStorageService) getBucketName(bucket string) (string, error) {
 	buckets := s.client.Buckets(context.Background(), s.projectID)
 	for {
 		b, err := buckets.Next()
@@ -44,15 +55,23 @@ func (s *StorageStorageService) getBucketByName(bucket string) (ifaces_gcloud_st
 			break
 		}
 		if err != nil {
-			return nil, fmt.Errorf("an error occurred finding bucket: %s; %v", bucket, err)
+			return "", fmt.Errorf("an error occurred finding bucket: %s; %v", bucket, err)
 		}
 		// We'll label the buckets by their name in the nitric.yaml file and use this...
 		if b.Labels["x-nitric-name"] == bucket {
-			bucketHandle := s.client.Bucket(b.Name)
-			return bucketHandle, nil
+			return b.Name, nil
 		}
 	}
-	return nil, fmt.Errorf("bucket not found")
+	return "", fmt.Errorf("bucket not found")
+}
+
+func (s *StorageStorageService) getBucketByName(bucket string) (ifaces_gcloud_storage.BucketHandle, error) {
+	bucketName, err := s.getBucketName(bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.client.Bucket(bucketName), nil
 }
 
 /**
@@ -98,12 +117,91 @@ func (s *StorageStorageService) Read(bucket string, key string) ([]byte, error)
 	return bytes, nil
 }
 
+// rangeErrorCode - Maps an error opening a range reader to a nitric error code
+func rangeErrorCode(err error) codes.Code {
+	if err == storage.ErrObjectNotExist {
+		return codes.NotFound
+	}
+
+	if apiErr, ok := err.(*googleapi.Error); ok && apiErr.Code == 416 {
+		return codes.OutOfRange
+	}
+
+	return codes.Internal
+}
+
+/**
+ * Retrieves a range of bytes of an Item from a Google Cloud Storage Bucket
+ */
+func (s *StorageStorageService) ReadRange(bucket string, key string, start int64, end int64) ([]byte, error) {
+	newErr := errors.ErrorsWithScope(
+		"StorageStorageService.ReadRange",
+		map[string]interface{}{
+			"bucket": bucket,
+			"key":    key,
+			"start":  start,
+			"end":    end,
+		},
+	)
+
+	if err := plugin.ValidateRange(start, end); err != nil {
+		return nil, newErr(
+			codes.InvalidArgument,
+			"invalid range",
+			err,
+		)
+	}
+
+	bucketHandle, err := s.getBucketByName(bucket)
+	if err != nil {
+		return nil, newErr(
+			codes.NotFound,
+			"unable to locate bucket",
+			err,
+		)
+	}
+
+	// A negative length reads to the end of the object
+	length := int64(-1)
+	if end >= 0 {
+		length = end - start + 1
+	}
+
+	reader, err := bucketHandle.Object(key).NewRangeReader(context.Background(), start, length)
+	if err != nil {
+		return nil, newErr(
+			rangeErrorCode(err),
+			"unable to get reader for object range",
+			err,
+		)
+	}
+	defer reader.Close()
+
+	bytes, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, newErr(
+			codes.Internal,
+			"error reading object stream",
+			err,
+		)
+	}
+
+	return bytes, nil
+}
+
 /**
  * Stores a new Item in a Google Cloud Storage Bucket
  */
 func (s *StorageStorageService) Write(bucket string, key string, object []byte) error {
+	return s.WriteWithMetadata(bucket, key, object, nil)
+}
+
+/**
+ * Stores a new Item in a Google Cloud Storage Bucket with metadata, GCS detects the Content-Type when it isn't provided
+ */
+func (s *StorageStorageService) WriteWithMetadata(bucket string, key string, object []byte, metadata map[string]string) error {
 	newErr := errors.ErrorsWithScope(
-		"StorageStorageService.Write",
+		"StorageStorageService.WriteWithMetadata",
 		map[string]interface{}{
 			"bucket":     bucket,
 			"key":        key,
@@ -121,7 +219,9 @@ func (s *StorageStorageService) Write(bucket string, key string, object []byte)
 		)
 	}
 
+	meta := plugin.ParseMetadata(metadata)
 	writer := bucketHandle.Object(key).NewWriter(context.Background())
+	writer.SetMetadata(meta.ContentType, meta.CacheControl, meta.Custom)
 
 	if _, err := writer.Write(object); err != nil {
 		return newErr(
@@ -142,6 +242,45 @@ func (s *StorageStorageService) Write(bucket string, key string, object []byte)
 	return nil
 }
 
+/**
+ * Retrieves the metadata of an Item in a Google Cloud Storage Bucket
+ */
+func (s *StorageStorageService) GetMetadata(bucket string, key string) (map[string]string, error) {
+	newErr := errors.ErrorsWithScope(
+		"StorageStorageService.GetMetadata",
+		map[string]interface{}{
+			"bucket": bucket,
+			"key":    key,
+		},
+	)
+
+	bucketHandle, err := s.getBucketByName(bucket)
+	if err != nil {
+		return nil, newErr(
+			codes.NotFound,
+			"unable to locate bucket",
+			err,
+		)
+	}
+
+	attrs, err := bucketHandle.Object(key).Attrs(context.Background())
+	if err != nil {
+		return nil, newErr(
+			rangeErrorCode(err),
+			"unable to retrieve object metadata",
+			err,
+		)
+	}
+
+	meta := &plugin.ObjectMetadata{
+		ContentType:  attrs.ContentType,
+		CacheControl: attrs.CacheControl,
+		Custom:       attrs.Metadata,
+	}
+
+	return meta.ToMap(), nil
+}
+
 /**
  * Delete an Item in a Google Cloud Storage Bucket
  */
@@ -179,9 +318,204 @@ func (s *StorageStorageService) Delete(bucket string, key string) error {
 	return nil
 }
 
+/**
+ * Generates a V4 signed URL granting temporary direct access to an object
+ */
+func (s *StorageStorageService) PreSignUrl(bucket string, key string, operation plugin.Operation, expiry uint32) (string, error) {
+	newErr := errors.ErrorsWithScope(
+		"StorageStorageService.PreSignUrl",
+		map[string]interface{}{
+			"bucket":    bucket,
+			"key":       key,
+			"operation": operation.String(),
+		},
+	)
+
+	if s.signingOptions == nil {
+		return "", newErr(
+			codes.FailedPrecondition,
+			"URL signing is not configured for these credentials",
+			nil,
+		)
+	}
+
+	expires := time.Duration(expiry) * time.Second
+	if expires > maxSignedUrlExpiry {
+		return "", newErr(
+			codes.InvalidArgument,
+			fmt.Sprintf("expiry must not exceed %v", maxSignedUrlExpiry),
+			nil,
+		)
+	}
+
+	var method string
+	switch operation {
+	case plugin.READ:
+		method = "GET"
+	case plugin.WRITE:
+		method = "PUT"
+	default:
+		return "", newErr(
+			codes.InvalidArgument,
+			"requested operation not supported for pre-signed Google Cloud Storage urls",
+			nil,
+		)
+	}
+
+	bucketName, err := s.getBucketName(bucket)
+	if err != nil {
+		return "", newErr(
+			codes.NotFound,
+			"unable to locate bucket",
+			err,
+		)
+	}
+
+	opts := *s.signingOptions
+	opts.Method = method
+	opts.Expires = time.Now().Add(expires)
+	opts.Scheme = storage.SigningSchemeV4
+
+	url, err := storage.SignedURL(bucketName, key, &opts)
+	if err != nil {
+		return "", newErr(
+			codes.Internal,
+			fmt.Sprintf("failed to generate pre-signed %s URL", operation.String()),
+			err,
+		)
+	}
+
+	return url, nil
+}
+
+// newSigningOptions - Uses the service account key from the credentials where available,
+// otherwise signs using the IAM credentials API as the default compute service account
+func newSigningOptions(ctx context.Context, credentials *google.Credentials) (*storage.SignedURLOptions, error) {
+	if len(credentials.JSON) > 0 {
+		if jwtConfig, err := google.JWTConfigFromJSON(credentials.JSON); err == nil && len(jwtConfig.PrivateKey) > 0 {
+			return &storage.SignedURLOptions{
+				GoogleAccessID: jwtConfig.Email,
+				PrivateKey:     jwtConfig.PrivateKey,
+			}, nil
+		}
+	}
+
+	if !metadata.OnGCE() {
+		return nil, fmt.Errorf("credentials do not contain a private key and no metadata server is available")
+	}
+
+	email, err := metadata.Email("default")
+	if err != nil {
+		return nil, fmt.Errorf("unable to determine service account: %v", err)
+	}
+
+	iamService, err := iamcredentials.NewService(ctx, option.WithCredentials(credentials))
+	if err != nil {
+		return nil, err
+	}
+
+	return &storage.SignedURLOptions{
+		GoogleAccessID: email,
+		SignBytes: func(b []byte) ([]byte, error) {
+			resp, err := iamService.Projects.ServiceAccounts.SignBlob(
+				fmt.Sprintf("projects/-/serviceAccounts/%s", email),
+				&iamcredentials.SignBlobRequest{Payload: base64.StdEncoding.EncodeToString(b)},
+			).Context(ctx).Do()
+			if err != nil {
+				return nil, err
+			}
+			return base64.StdEncoding.DecodeString(resp.SignedBlob)
+		},
+	}, nil
+}
+
 /**
  * Creates a new Storage Plugin for use in GCP
  */
+// listFiles - Returns an iterator over the objects in a bucket with keys starting with the prefix,
+// the object iterator requests each page of objects once the previous page has been read
+func (s *StorageStorageService) listFiles(bucket string, prefix string, newErr errors.ErrorFactory) plugin.FileIterator {
+	bucketHandle, err := s.getBucketByName(bucket)
+	if err != nil {
+		// Return an error only iterator
+		return func() (*plugin.FileInfo, error) {
+			return nil, newErr(
+				codes.NotFound,
+				"unable to locate bucket",
+				err,
+			)
+		}
+	}
+
+	objects := bucketHandle.Objects(context.Background(), &storage.Query{Prefix: prefix})
+
+	return func() (*plugin.FileInfo, error) {
+		attrs, err := objects.Next()
+		if err == iterator.Done {
+			return nil, io.EOF
+		}
+
+		if err != nil {
+			return nil, newErr(
+				codes.Internal,
+				"unable to list objects",
+				err,
+			)
+		}
+
+		return &plugin.FileInfo{
+			Key: attrs.Name,
+		}, nil
+	}
+}
+
+// ListFiles - Lists the objects in a bucket with keys starting with the prefix
+func (s *StorageStorageService) ListFiles(bucket string, prefix string) ([]*plugin.FileInfo, error) {
+	newErr := errors.ErrorsWithScope(
+		"StorageStorageService.ListFiles",
+		map[string]interface{}{
+			"bucket": bucket,
+			"prefix": prefix,
+		},
+	)
+
+	files := make([]*plugin.FileInfo, 0)
+	next := s.listFiles(bucket, prefix, newErr)
+
+	for file, err := next(); err != io.EOF; file, err = next() {
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, file)
+	}
+
+	return files, nil
+}
+
+// ListFilesStream - Lists the objects in a bucket with keys starting with the prefix, a page at a time
+func (s *StorageStorageService) ListFilesStream(bucket string, prefix string) plugin.FileIterator {
+	newErr := errors.ErrorsWithScope(
+		"StorageStorageService.ListFilesStream",
+		map[string]interface{}{
+			"bucket": bucket,
+			"prefix": prefix,
+		},
+	)
+
+	return s.listFiles(bucket, prefix, newErr)
+}
+
+// Capabilities - Returns the baseline capabilities along with the optional operations the plugin supports
+func (s *StorageStorageService) Capabilities() []string {
+	return append(plugin.BaselineCapabilities(),
+		plugin.CapabilityReadRange,
+		plugin.CapabilityMetadata,
+		plugin.CapabilityPreSignUrl,
+		plugin.CapabilityListFiles,
+		plugin.CapabilityListFilesStream,
+	)
+}
+
 func New() (plugin.StorageService, error) {
 	ctx := context.Background()
 
@@ -196,14 +530,27 @@ func New() (plugin.StorageService, error) {
 		return nil, fmt.Errorf("storage client error: %v", err)
 	}
 
+	signingOptions, err := newSigningOptions(ctx, credentials)
+	if err != nil {
+		// Storage remains usable, only pre-signed URLs are unavailable
+		fmt.Println(fmt.Sprintf("unable to configure URL signing: %v", err))
+	}
+
 	return &StorageStorageService{
-		client:    ifaces_gcloud_storage.AdaptStorageClient(client),
-		projectID: credentials.ProjectID,
+		client:         ifaces_gcloud_storage.AdaptStorageClient(client),
+		projectID:      credentials.ProjectID,
+		signingOptions: signingOptions,
 	}, nil
 }
 
-func NewWithClient(client ifaces_gcloud_storage.StorageClient) (plugin.StorageService, error) {
-	return &StorageStorageService{
+func NewWithClient(client ifaces_gcloud_storage.StorageClient, opts ...StorageStorageServiceOption) (plugin.StorageService, error) {
+	s := &StorageStorageService{
 		client: client,
-	}, nil
+	}
+
+	for _, o := range opts {
+		o.Apply(s)
+	}
+
+	return s, nil
 }
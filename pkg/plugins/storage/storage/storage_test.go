@@ -15,6 +15,14 @@
 package storage_service_test
 
 import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"io"
+
+	gcs "cloud.google.com/go/storage"
+	plugin "github.com/nitrictech/nitric/pkg/plugins/storage"
 	storage_service "github.com/nitrictech/nitric/pkg/plugins/storage/storage"
 	mock_gcp_storage "github.com/nitrictech/nitric/tests/mocks/gcp_storage"
 	. "github.com/onsi/ginkgo"
@@ -57,6 +65,36 @@ var _ = Describe("Storage", func() {
 		})
 	})
 
+	Context("Metadata", func() {
+		When("Writing an item with metadata", func() {
+			storage := make(map[string]map[string][]byte)
+			mockStorageClient := mock_gcp_storage.NewStorageClient([]string{"my-bucket"}, &storage)
+			storagePlugin, _ := storage_service.NewWithClient(mockStorageClient)
+
+			It("Should return the metadata the item was written with", func() {
+				err := storagePlugin.WriteWithMetadata("my-bucket", "test-file", []byte("Test"), map[string]string{
+					"content-type":  "text/plain",
+					"cache-control": "max-age=60",
+					"owner":         "test",
+				})
+				Expect(err).ShouldNot(HaveOccurred())
+
+				metadata, err := storagePlugin.GetMetadata("my-bucket", "test-file")
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(metadata).To(Equal(map[string]string{
+					"Content-Type":  "text/plain",
+					"Cache-Control": "max-age=60",
+					"owner":         "test",
+				}))
+			})
+
+			It("Should fail to return the metadata of an item that doesn't exist", func() {
+				_, err := storagePlugin.GetMetadata("my-bucket", "missing-file")
+				Expect(err).Should(HaveOccurred())
+			})
+		})
+	})
+
 	Context("Read", func() {
 		When("The Google Cloud Storage Backend is available", func() {
 			When("The bucket exists", func() {
@@ -113,6 +151,34 @@ var _ = Describe("Storage", func() {
 		})
 	})
 
+	Context("ReadRange", func() {
+		When("The item exists", func() {
+			storage := make(map[string]map[string][]byte)
+			storage["test-bucket"] = make(map[string][]byte)
+			storage["test-bucket"]["test-key"] = []byte("Test")
+			mockStorageClient := mock_gcp_storage.NewStorageClient([]string{"test-bucket"}, &storage)
+			storagePlugin, _ := storage_service.NewWithClient(mockStorageClient)
+
+			It("Should retrieve the range of the item", func() {
+				item, err := storagePlugin.ReadRange("test-bucket", "test-key", 1, 2)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(item).To(Equal([]byte("es")))
+			})
+
+			It("Should retrieve the rest of the item for open ended ranges", func() {
+				item, err := storagePlugin.ReadRange("test-bucket", "test-key", 1, -1)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(item).To(Equal([]byte("est")))
+			})
+
+			It("Should reject invalid ranges", func() {
+				_, err := storagePlugin.ReadRange("test-bucket", "test-key", 2, 1)
+				Expect(err).Should(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("invalid range"))
+			})
+		})
+	})
+
 	Context("Delete", func() {
 		When("The Google Cloud Storage Backend is available", func() {
 			When("The bucket exists", func() {
@@ -163,4 +229,120 @@ var _ = Describe("Storage", func() {
 			})
 		})
 	})
+
+	Context("PreSignUrl", func() {
+		key, _ := rsa.GenerateKey(rand.Reader, 2048)
+		privateKey := pem.EncodeToMemory(&pem.Block{
+			Type:  "RSA PRIVATE KEY",
+			Bytes: x509.MarshalPKCS1PrivateKey(key),
+		})
+		signingOptions := &gcs.SignedURLOptions{
+			GoogleAccessID: "test@test-project.iam.gserviceaccount.com",
+			PrivateKey:     privateKey,
+		}
+
+		When("URL signing is configured", func() {
+			When("The bucket exists", func() {
+				storage := make(map[string]map[string][]byte)
+				mockStorageClient := mock_gcp_storage.NewStorageClient([]string{"test-bucket"}, &storage)
+				storagePlugin, _ := storage_service.NewWithClient(mockStorageClient, storage_service.WithSigningOptions(signingOptions))
+
+				It("Should return a V4 signed URL for the object", func() {
+					url, err := storagePlugin.PreSignUrl("test-bucket", "test-key", plugin.WRITE, 60)
+
+					By("Not returning an error")
+					Expect(err).ShouldNot(HaveOccurred())
+
+					By("Returning a V4 signed URL")
+					Expect(url).To(ContainSubstring("test-bucket/test-key"))
+					Expect(url).To(ContainSubstring("X-Goog-Algorithm=GOOG4-RSA-SHA256"))
+					Expect(url).To(ContainSubstring("X-Goog-Expires=60"))
+				})
+			})
+
+			When("The expiry is longer than 7 days", func() {
+				storage := make(map[string]map[string][]byte)
+				mockStorageClient := mock_gcp_storage.NewStorageClient([]string{"test-bucket"}, &storage)
+				storagePlugin, _ := storage_service.NewWithClient(mockStorageClient, storage_service.WithSigningOptions(signingOptions))
+
+				It("Should return an error", func() {
+					_, err := storagePlugin.PreSignUrl("test-bucket", "test-key", plugin.READ, 8*24*60*60)
+
+					By("Returning an error")
+					Expect(err).Should(HaveOccurred())
+				})
+			})
+
+			When("The bucket doesn't exist", func() {
+				storage := make(map[string]map[string][]byte)
+				mockStorageClient := mock_gcp_storage.NewStorageClient([]string{}, &storage)
+				storagePlugin, _ := storage_service.NewWithClient(mockStorageClient, storage_service.WithSigningOptions(signingOptions))
+
+				It("Should return an error", func() {
+					_, err := storagePlugin.PreSignUrl("test-bucket", "test-key", plugin.READ, 60)
+
+					By("Returning an error")
+					Expect(err).Should(HaveOccurred())
+				})
+			})
+		})
+
+		When("URL signing is not configured", func() {
+			storage := make(map[string]map[string][]byte)
+			mockStorageClient := mock_gcp_storage.NewStorageClient([]string{"test-bucket"}, &storage)
+			storagePlugin, _ := storage_service.NewWithClient(mockStorageClient)
+
+			It("Should return an error", func() {
+				_, err := storagePlugin.PreSignUrl("test-bucket", "test-key", plugin.READ, 60)
+
+				By("Returning an error")
+				Expect(err).Should(HaveOccurred())
+			})
+		})
+	})
+
+	Context("ListFilesStream", func() {
+		When("The bucket exists", func() {
+			storage := make(map[string]map[string][]byte)
+			storage["test-bucket"] = map[string][]byte{
+				"images/b.png": []byte("b"),
+				"images/a.png": []byte("a"),
+				"docs/c.txt":   []byte("c"),
+			}
+			mockStorageClient := mock_gcp_storage.NewStorageClient([]string{"test-bucket"}, &storage)
+			storagePlugin, _ := storage_service.NewWithClient(mockStorageClient)
+
+			It("Should return each object starting with the prefix", func() {
+				next := storagePlugin.ListFilesStream("test-bucket", "images/")
+
+				file, err := next()
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(file.Key).To(Equal("images/a.png"))
+
+				file, err = next()
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(file.Key).To(Equal("images/b.png"))
+
+				_, err = next()
+				Expect(err).To(Equal(io.EOF))
+			})
+
+			It("Should list the same objects with ListFiles", func() {
+				files, err := storagePlugin.ListFiles("test-bucket", "images/")
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(files).To(HaveLen(2))
+			})
+		})
+
+		When("The bucket doesn't exist", func() {
+			storage := make(map[string]map[string][]byte)
+			mockStorageClient := mock_gcp_storage.NewStorageClient([]string{}, &storage)
+			storagePlugin, _ := storage_service.NewWithClient(mockStorageClient)
+
+			It("Should return an error", func() {
+				_, err := storagePlugin.ListFilesStream("test-bucket", "")()
+				Expect(err).Should(HaveOccurred())
+			})
+		})
+	})
 })
@@ -0,0 +1,51 @@
+// Copyright 2021 Nitric Pty Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import "io"
+
+// FilePageFetcher - Fetches a page of files continuing from the page token,
+// returning the token of the next page or an empty token when it's the last page
+type FilePageFetcher = func(pageToken string) ([]*FileInfo, string, error)
+
+// NewPagedFileIterator - Returns an iterator over a paged listing, fetching each page only once the previous page has been read
+func NewPagedFileIterator(fetch FilePageFetcher) FileIterator {
+	var files []*FileInfo
+	pageToken := ""
+	fetched := false
+
+	return func() (*FileInfo, error) {
+		// Pages may be empty while more remain, so keep reading until a page has files
+		for len(files) == 0 {
+			if fetched && pageToken == "" {
+				return nil, io.EOF
+			}
+
+			page, nextToken, err := fetch(pageToken)
+			if err != nil {
+				return nil, err
+			}
+
+			fetched = true
+			files = page
+			pageToken = nextToken
+		}
+
+		file := files[0]
+		files = files[1:]
+
+		return file, nil
+	}
+}
@@ -0,0 +1,66 @@
+// Copyright 2021 Nitric Pty Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package auto
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/nitrictech/nitric/pkg/providers"
+	aws_factory "github.com/nitrictech/nitric/pkg/providers/aws/factory"
+	azure_factory "github.com/nitrictech/nitric/pkg/providers/azure/factory"
+	dev_factory "github.com/nitrictech/nitric/pkg/providers/dev/factory"
+	gcp_factory "github.com/nitrictech/nitric/pkg/providers/gcp/factory"
+	"github.com/nitrictech/nitric/pkg/utils"
+)
+
+// NITRIC_PROVIDER_ENV - Names the provider whose plugins the membrane is started with
+const NITRIC_PROVIDER_ENV = "NITRIC_PROVIDER"
+
+const (
+	Provider_AWS   = "aws"
+	Provider_Azure = "azure"
+	Provider_GCP   = "gcp"
+	Provider_Dev   = "dev"
+)
+
+// Providers - The names of the providers that can be selected
+var Providers = []string{Provider_AWS, Provider_Azure, Provider_GCP, Provider_Dev}
+
+// NewForProvider - Returns the service factory for the named provider, one of aws, azure, gcp or dev
+func NewForProvider(name string) (providers.ServiceFactory, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case Provider_AWS:
+		return aws_factory.New(), nil
+	case Provider_Azure:
+		return azure_factory.New(), nil
+	case Provider_GCP:
+		return gcp_factory.New(), nil
+	case Provider_Dev:
+		return dev_factory.New(), nil
+	default:
+		return nil, fmt.Errorf("unknown provider %q, expected one of %s", name, strings.Join(Providers, ", "))
+	}
+}
+
+// New - Returns the service factory for the provider named by the NITRIC_PROVIDER env var
+func New() (providers.ServiceFactory, error) {
+	name := utils.GetEnv(NITRIC_PROVIDER_ENV, "")
+	if name == "" {
+		return nil, fmt.Errorf("%s not configured, expected one of %s", NITRIC_PROVIDER_ENV, strings.Join(Providers, ", "))
+	}
+
+	return NewForProvider(name)
+}
@@ -0,0 +1,89 @@
+// Copyright 2021 Nitric Pty Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package auto_test
+
+import (
+	"os"
+
+	"github.com/nitrictech/nitric/pkg/providers/auto"
+	aws_factory "github.com/nitrictech/nitric/pkg/providers/aws/factory"
+	azure_factory "github.com/nitrictech/nitric/pkg/providers/azure/factory"
+	dev_factory "github.com/nitrictech/nitric/pkg/providers/dev/factory"
+	gcp_factory "github.com/nitrictech/nitric/pkg/providers/gcp/factory"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Auto", func() {
+	Context("NewForProvider", func() {
+		When("Naming a supported provider", func() {
+			It("Should return the provider's service factory", func() {
+				expected := map[string]interface{}{
+					"aws":   &aws_factory.AWSServiceFactory{},
+					"azure": &azure_factory.AzureServiceFactory{},
+					"gcp":   &gcp_factory.GCPServiceFactory{},
+					"dev":   &dev_factory.DevServiceFactory{},
+				}
+
+				for name, factory := range expected {
+					serviceFactory, err := auto.NewForProvider(name)
+					Expect(err).ShouldNot(HaveOccurred())
+					Expect(serviceFactory).To(BeAssignableToTypeOf(factory))
+				}
+			})
+
+			It("Should ignore case and surrounding whitespace", func() {
+				serviceFactory, err := auto.NewForProvider(" AWS ")
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(serviceFactory).To(BeAssignableToTypeOf(&aws_factory.AWSServiceFactory{}))
+			})
+		})
+
+		When("Naming an unknown provider", func() {
+			It("Should return an error listing the supported providers", func() {
+				_, err := auto.NewForProvider("digitalocean")
+				Expect(err).Should(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("unknown provider \"digitalocean\""))
+				Expect(err.Error()).To(ContainSubstring("aws, azure, gcp, dev"))
+			})
+		})
+	})
+
+	Context("New", func() {
+		AfterEach(func() {
+			os.Unsetenv(auto.NITRIC_PROVIDER_ENV)
+		})
+
+		When("NITRIC_PROVIDER is set", func() {
+			It("Should return the named provider's service factory", func() {
+				os.Setenv(auto.NITRIC_PROVIDER_ENV, "dev")
+
+				serviceFactory, err := auto.New()
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(serviceFactory).To(BeAssignableToTypeOf(&dev_factory.DevServiceFactory{}))
+			})
+		})
+
+		When("NITRIC_PROVIDER isn't set", func() {
+			It("Should return an error", func() {
+				os.Unsetenv(auto.NITRIC_PROVIDER_ENV)
+
+				_, err := auto.New()
+				Expect(err).Should(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("NITRIC_PROVIDER not configured"))
+			})
+		})
+	})
+})
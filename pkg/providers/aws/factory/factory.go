@@ -0,0 +1,83 @@
+// Copyright 2021 Nitric Pty Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package factory
+
+import (
+	"github.com/nitrictech/nitric/pkg/plugins/config"
+	appconfig_config_service "github.com/nitrictech/nitric/pkg/plugins/config/appconfig"
+	"github.com/nitrictech/nitric/pkg/plugins/document"
+	dynamodb_service "github.com/nitrictech/nitric/pkg/plugins/document/dynamodb"
+	"github.com/nitrictech/nitric/pkg/plugins/events"
+	sns_service "github.com/nitrictech/nitric/pkg/plugins/events/sns"
+	"github.com/nitrictech/nitric/pkg/plugins/gateway"
+	ecs_service "github.com/nitrictech/nitric/pkg/plugins/gateway/ecs"
+	lambda_service "github.com/nitrictech/nitric/pkg/plugins/gateway/lambda"
+	"github.com/nitrictech/nitric/pkg/plugins/queue"
+	sqs_service "github.com/nitrictech/nitric/pkg/plugins/queue/sqs"
+	"github.com/nitrictech/nitric/pkg/plugins/secret"
+	secrets_manager_secret_service "github.com/nitrictech/nitric/pkg/plugins/secret/secrets_manager"
+	"github.com/nitrictech/nitric/pkg/plugins/storage"
+	s3_service "github.com/nitrictech/nitric/pkg/plugins/storage/s3"
+	"github.com/nitrictech/nitric/pkg/providers"
+	"github.com/nitrictech/nitric/pkg/utils"
+)
+
+type AWSServiceFactory struct {
+}
+
+func New() providers.ServiceFactory {
+	return &AWSServiceFactory{}
+}
+
+// NewDocumentService - Return AWS DynamoDB document plugin
+func (p *AWSServiceFactory) NewDocumentService() (document.DocumentService, error) {
+	return dynamodb_service.New()
+}
+
+// NewEventService - Returns AWS SNS based events plugin
+func (p *AWSServiceFactory) NewEventService() (events.EventService, error) {
+	return sns_service.New()
+}
+
+// NewGatewayService - Returns AWS Lambda Gateway plugin, or the ECS Gateway plugin when GATEWAY_ENVIRONMENT isn't lambda
+func (p *AWSServiceFactory) NewGatewayService() (gateway.GatewayService, error) {
+	if utils.GetEnv("GATEWAY_ENVIRONMENT", "lambda") != "lambda" {
+		return ecs_service.New()
+	}
+	return lambda_service.New()
+}
+
+// NewQueueService - Returns AWS SQS based queue plugin
+func (p *AWSServiceFactory) NewQueueService() (queue.QueueService, error) {
+	return sqs_service.New()
+}
+
+// NewStorageService - Returns AWS S3 based storage plugin
+func (p *AWSServiceFactory) NewStorageService() (storage.StorageService, error) {
+	return s3_service.New()
+}
+
+// NewSecretService - Returns AWS Secrets Manager based secret plugin
+func (p *AWSServiceFactory) NewSecretService() (secret.SecretService, error) {
+	return secrets_manager_secret_service.New()
+}
+
+// NewConfigService - Returns AWS AppConfig based config plugin, config is unimplemented if no AppConfig application is set
+func (p *AWSServiceFactory) NewConfigService() (config.ConfigService, error) {
+	if utils.GetEnv(appconfig_config_service.APPCONFIG_APPLICATION_ENV, "") == "" {
+		return nil, nil
+	}
+	return appconfig_config_service.New()
+}
@@ -22,6 +22,7 @@ import (
 	"syscall"
 
 	"github.com/nitrictech/nitric/pkg/membrane"
+	appconfig_config_service "github.com/nitrictech/nitric/pkg/plugins/config/appconfig"
 	dynamodb_service "github.com/nitrictech/nitric/pkg/plugins/document/dynamodb"
 	sns_service "github.com/nitrictech/nitric/pkg/plugins/events/sns"
 	"github.com/nitrictech/nitric/pkg/plugins/gateway"
@@ -49,6 +50,8 @@ func main() {
 		gatewayPlugin, _ = ecs_service.New()
 	}
 	secretPlugin, _ := secrets_manager_secret_service.New()
+	// AppConfig is optional, functions receive unimplemented errors if it isn't configured
+	configPlugin, _ := appconfig_config_service.New()
 	documentPlugin, _ := dynamodb_service.New()
 	eventsPlugin, _ := sns_service.New()
 	queuePlugin, _ := sqs_service.New()
@@ -61,6 +64,7 @@ func main() {
 		QueuePlugin:    queuePlugin,
 		StoragePlugin:  storagePlugin,
 		SecretPlugin:   secretPlugin,
+		ConfigPlugin:   configPlugin,
 	})
 
 	if err != nil {
@@ -80,5 +84,7 @@ func main() {
 		fmt.Println(fmt.Sprintf("Received %v, exiting", sigTerm))
 	}
 
-	m.Stop()
+	if err := m.Stop(); err != nil {
+		fmt.Println(fmt.Sprintf("Error stopping membrane: %v", err))
+	}
 }
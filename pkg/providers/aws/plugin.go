@@ -15,47 +15,11 @@
 package main
 
 import (
-	"github.com/nitrictech/nitric/pkg/plugins/document"
-	dynamodb_service "github.com/nitrictech/nitric/pkg/plugins/document/dynamodb"
-	"github.com/nitrictech/nitric/pkg/plugins/events"
-	sns_service "github.com/nitrictech/nitric/pkg/plugins/events/sns"
-	"github.com/nitrictech/nitric/pkg/plugins/gateway"
-	lambda_service "github.com/nitrictech/nitric/pkg/plugins/gateway/lambda"
-	"github.com/nitrictech/nitric/pkg/plugins/queue"
-	sqs_service "github.com/nitrictech/nitric/pkg/plugins/queue/sqs"
-	"github.com/nitrictech/nitric/pkg/plugins/storage"
-	s3_service "github.com/nitrictech/nitric/pkg/plugins/storage/s3"
 	"github.com/nitrictech/nitric/pkg/providers"
+	aws_factory "github.com/nitrictech/nitric/pkg/providers/aws/factory"
 )
 
-type AWSServiceFactory struct {
-}
-
+// New - Returns the AWS service factory, see the factory package for the plugins it provides
 func New() providers.ServiceFactory {
-	return &AWSServiceFactory{}
-}
-
-// NewDocumentService - Return AWS DynamoDB document plugin
-func (p *AWSServiceFactory) NewDocumentService() (document.DocumentService, error) {
-	return dynamodb_service.New()
-}
-
-// NewEventService - Returns AWS SNS based events plugin
-func (p *AWSServiceFactory) NewEventService() (events.EventService, error) {
-	return sns_service.New()
-}
-
-// NewGatewayService - Returns AWS Lambda Gateway plugin
-func (p *AWSServiceFactory) NewGatewayService() (gateway.GatewayService, error) {
-	return lambda_service.New()
-}
-
-// NewQueueService - Returns AWS SQS based queue plugin
-func (p *AWSServiceFactory) NewQueueService() (queue.QueueService, error) {
-	return sqs_service.New()
-}
-
-// NewStorageService - Returns AWS S3 based storage plugin
-func (p *AWSServiceFactory) NewStorageService() (storage.StorageService, error) {
-	return s3_service.New()
+	return aws_factory.New()
 }
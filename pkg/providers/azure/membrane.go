@@ -21,7 +21,11 @@ import (
 	"os/signal"
 	"syscall"
 
+	"github.com/nitrictech/nitric/pkg/plugins/queue"
 	azqueue_service "github.com/nitrictech/nitric/pkg/plugins/queue/azqueue"
+	servicebus_service "github.com/nitrictech/nitric/pkg/plugins/queue/servicebus"
+	azureutils "github.com/nitrictech/nitric/pkg/providers/azure/utils"
+	"github.com/nitrictech/nitric/pkg/utils"
 
 	"github.com/nitrictech/nitric/pkg/membrane"
 	mongodb_service "github.com/nitrictech/nitric/pkg/plugins/document/mongodb"
@@ -46,7 +50,16 @@ func main() {
 		fmt.Println("Failed to load event plugin:", err.Error())
 	}
 	gatewayPlugin, _ := http_service.New()
-	queuePlugin, _ := azqueue_service.New()
+	// Service Bus is used for queues when a namespace is configured, otherwise Storage Queues are used
+	var queuePlugin queue.QueueService
+	if utils.GetEnv(azureutils.AZURE_SERVICEBUS_ENDPOINT, "") != "" {
+		queuePlugin, err = servicebus_service.New()
+		if err != nil {
+			fmt.Println("Failed to load queue plugin:", err.Error())
+		}
+	} else {
+		queuePlugin, _ = azqueue_service.New()
+	}
 	storagePlugin, _ := azblob_service.New()
 	secretPlugin, err := key_vault.New()
 	if err != nil {
@@ -79,5 +92,7 @@ func main() {
 		fmt.Printf("Received %v, exiting\n", sigTerm)
 	}
 
-	m.Stop()
+	if err := m.Stop(); err != nil {
+		fmt.Println(fmt.Sprintf("Error stopping membrane: %v", err))
+	}
 }
@@ -15,52 +15,11 @@
 package main
 
 import (
-	"github.com/nitrictech/nitric/pkg/plugins/document"
-	mongodb_service "github.com/nitrictech/nitric/pkg/plugins/document/mongodb"
-	"github.com/nitrictech/nitric/pkg/plugins/events"
-	event_grid "github.com/nitrictech/nitric/pkg/plugins/events/eventgrid"
-	"github.com/nitrictech/nitric/pkg/plugins/gateway"
-	http_service "github.com/nitrictech/nitric/pkg/plugins/gateway/appservice"
-	"github.com/nitrictech/nitric/pkg/plugins/queue"
-	"github.com/nitrictech/nitric/pkg/plugins/secret"
-	key_vault "github.com/nitrictech/nitric/pkg/plugins/secret/key_vault"
-	"github.com/nitrictech/nitric/pkg/plugins/storage"
-	azblob_service "github.com/nitrictech/nitric/pkg/plugins/storage/azblob"
 	"github.com/nitrictech/nitric/pkg/providers"
+	azure_factory "github.com/nitrictech/nitric/pkg/providers/azure/factory"
 )
 
-type AzureServiceFactory struct {
-}
-
+// New - Returns the Azure service factory, see the factory package for the plugins it provides
 func New() providers.ServiceFactory {
-	return &AzureServiceFactory{}
-}
-
-func (p *AzureServiceFactory) NewSecretService() (secret.SecretService, error) {
-	return key_vault.New()
-}
-
-// NewDocumentService - Returns a MongoDB based document service
-func (p *AzureServiceFactory) NewDocumentService() (document.DocumentService, error) {
-	return mongodb_service.New()
-}
-
-// NewEventService - Returns Azure _ based events plugin
-func (p *AzureServiceFactory) NewEventService() (events.EventService, error) {
-	return event_grid.New()
-}
-
-// NewGatewayService - Returns Azure _ Gateway plugin
-func (p *AzureServiceFactory) NewGatewayService() (gateway.GatewayService, error) {
-	return http_service.New()
-}
-
-// NewQueueService - Returns Azure _ based queue plugin
-func (p *AzureServiceFactory) NewQueueService() (queue.QueueService, error) {
-	return &queue.UnimplementedQueuePlugin{}, nil
-}
-
-// NewStorageService - Returns Azure _ based storage plugin
-func (p *AzureServiceFactory) NewStorageService() (storage.StorageService, error) {
-	return azblob_service.New()
+	return azure_factory.New()
 }
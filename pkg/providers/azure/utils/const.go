@@ -19,3 +19,6 @@ const AZURE_STORAGE_BLOB_ENDPOINT = "AZURE_STORAGE_ACCOUNT_BLOB_ENDPOINT"
 
 // AZURE_STORAGE_BLOB_ENDPOINT - Endpoint for azqueue queue plugin
 const AZURE_STORAGE_QUEUE_ENDPOINT = "AZURE_STORAGE_ACCOUNT_QUEUE_ENDPOINT"
+
+// AZURE_SERVICEBUS_ENDPOINT - Namespace endpoint for the servicebus queue plugin
+const AZURE_SERVICEBUS_ENDPOINT = "AZURE_SERVICEBUS_ENDPOINT"
@@ -22,6 +22,7 @@ import (
 	"syscall"
 
 	"github.com/nitrictech/nitric/pkg/membrane"
+	config_service "github.com/nitrictech/nitric/pkg/plugins/config/dev"
 	boltdb_service "github.com/nitrictech/nitric/pkg/plugins/document/boltdb"
 	events_service "github.com/nitrictech/nitric/pkg/plugins/events/dev"
 	gateway_plugin "github.com/nitrictech/nitric/pkg/plugins/gateway/dev"
@@ -37,6 +38,7 @@ func main() {
 	signal.Notify(term, os.Interrupt, syscall.SIGINT)
 
 	secretPlugin, _ := secret_service.New()
+	configPlugin, _ := config_service.New()
 	documentPlugin, _ := boltdb_service.New()
 	eventsPlugin, _ := events_service.New()
 	gatewayPlugin, _ := gateway_plugin.New()
@@ -50,6 +52,7 @@ func main() {
 		QueuePlugin:    queuePlugin,
 		StoragePlugin:  storagePlugin,
 		SecretPlugin:   secretPlugin,
+		ConfigPlugin:   configPlugin,
 	})
 
 	if err != nil {
@@ -69,5 +72,7 @@ func main() {
 		fmt.Println(fmt.Sprintf("Received %v, exiting", sigTerm))
 	}
 
-	m.Stop()
+	if err := m.Stop(); err != nil {
+		fmt.Println(fmt.Sprintf("Error stopping membrane: %v", err))
+	}
 }
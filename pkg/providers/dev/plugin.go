@@ -15,47 +15,11 @@
 package main
 
 import (
-	"github.com/nitrictech/nitric/pkg/plugins/document"
-	boltdb_service "github.com/nitrictech/nitric/pkg/plugins/document/boltdb"
-	"github.com/nitrictech/nitric/pkg/plugins/events"
-	events_service "github.com/nitrictech/nitric/pkg/plugins/events/dev"
-	"github.com/nitrictech/nitric/pkg/plugins/gateway"
-	gateway_plugin "github.com/nitrictech/nitric/pkg/plugins/gateway/dev"
-	"github.com/nitrictech/nitric/pkg/plugins/queue"
-	queue_service "github.com/nitrictech/nitric/pkg/plugins/queue/dev"
-	"github.com/nitrictech/nitric/pkg/plugins/storage"
-	minio_storage_service "github.com/nitrictech/nitric/pkg/plugins/storage/minio"
 	"github.com/nitrictech/nitric/pkg/providers"
+	dev_factory "github.com/nitrictech/nitric/pkg/providers/dev/factory"
 )
 
-type DevServiceFactory struct {
-}
-
+// New - Returns the local dev service factory, see the factory package for the plugins it provides
 func New() providers.ServiceFactory {
-	return &DevServiceFactory{}
-}
-
-// NewDocumentService - Returns local dev document plugin
-func (p *DevServiceFactory) NewDocumentService() (document.DocumentService, error) {
-	return boltdb_service.New()
-}
-
-// NewEventService - Returns local dev events plugin
-func (p *DevServiceFactory) NewEventService() (events.EventService, error) {
-	return events_service.New()
-}
-
-// NewGatewayService - Returns local dev Gateway plugin
-func (p *DevServiceFactory) NewGatewayService() (gateway.GatewayService, error) {
-	return gateway_plugin.New()
-}
-
-// NewQueueService - Returns local dev queue plugin
-func (p *DevServiceFactory) NewQueueService() (queue.QueueService, error) {
-	return queue_service.New()
-}
-
-// NewStorageService - Returns local dev storage plugin
-func (p *DevServiceFactory) NewStorageService() (storage.StorageService, error) {
-	return minio_storage_service.New()
+	return dev_factory.New()
 }
@@ -55,5 +55,7 @@ func main() {
 		fmt.Println(fmt.Sprintf("Received %v, exiting", sigTerm))
 	}
 
-	m.Stop()
+	if err := m.Stop(); err != nil {
+		fmt.Println(fmt.Sprintf("Error stopping membrane: %v", err))
+	}
 }
@@ -25,9 +25,12 @@ import (
 	firestore_service "github.com/nitrictech/nitric/pkg/plugins/document/firestore"
 	pubsub_service "github.com/nitrictech/nitric/pkg/plugins/events/pubsub"
 	cloudrun_plugin "github.com/nitrictech/nitric/pkg/plugins/gateway/cloudrun"
+	"github.com/nitrictech/nitric/pkg/plugins/queue"
+	cloudtasks_queue_service "github.com/nitrictech/nitric/pkg/plugins/queue/cloudtasks"
 	pubsub_queue_service "github.com/nitrictech/nitric/pkg/plugins/queue/pubsub"
 	secret_manager_secret_service "github.com/nitrictech/nitric/pkg/plugins/secret/secret_manager"
 	storage_service "github.com/nitrictech/nitric/pkg/plugins/storage/storage"
+	"github.com/nitrictech/nitric/pkg/utils"
 )
 
 func main() {
@@ -57,7 +60,13 @@ func main() {
 	if err != nil {
 		fmt.Println("Failed to load gateway plugin:", err.Error())
 	}
-	queuePlugin, err := pubsub_queue_service.New()
+	var queuePlugin queue.QueueService
+	// Cloud Tasks pushes tasks to the function rather than having them received
+	if utils.GetEnv(cloudtasks_queue_service.CLOUDTASKS_LOCATION_ENV, "") != "" {
+		queuePlugin, err = cloudtasks_queue_service.New()
+	} else {
+		queuePlugin, err = pubsub_queue_service.New()
+	}
 	if err != nil {
 		fmt.Println("Failed to load queue plugin:", err.Error())
 	}
@@ -88,5 +97,7 @@ func main() {
 		fmt.Println(fmt.Sprintf("Received %v, exiting", sigTerm))
 	}
 
-	m.Stop()
+	if err := m.Stop(); err != nil {
+		fmt.Println(fmt.Sprintf("Error stopping membrane: %v", err))
+	}
 }
@@ -15,47 +15,11 @@
 package main
 
 import (
-	"github.com/nitrictech/nitric/pkg/plugins/document"
-	firestore_service "github.com/nitrictech/nitric/pkg/plugins/document/firestore"
-	"github.com/nitrictech/nitric/pkg/plugins/events"
-	pubsub_service "github.com/nitrictech/nitric/pkg/plugins/events/pubsub"
-	"github.com/nitrictech/nitric/pkg/plugins/gateway"
-	cloudrun_plugin "github.com/nitrictech/nitric/pkg/plugins/gateway/cloudrun"
-	"github.com/nitrictech/nitric/pkg/plugins/queue"
-	pubsub_queue_service "github.com/nitrictech/nitric/pkg/plugins/queue/pubsub"
-	"github.com/nitrictech/nitric/pkg/plugins/storage"
-	storage_service "github.com/nitrictech/nitric/pkg/plugins/storage/storage"
 	"github.com/nitrictech/nitric/pkg/providers"
+	gcp_factory "github.com/nitrictech/nitric/pkg/providers/gcp/factory"
 )
 
-type GCPServiceFactory struct {
-}
-
+// New - Returns the GCP service factory, see the factory package for the plugins it provides
 func New() providers.ServiceFactory {
-	return &GCPServiceFactory{}
-}
-
-// NewDocumentService - Returns Google Cloud Firestore based document service
-func (p *GCPServiceFactory) NewDocumentService() (document.DocumentService, error) {
-	return firestore_service.New()
-}
-
-// NewEventService - Returns Google Cloud Pubsub based events service
-func (p *GCPServiceFactory) NewEventService() (events.EventService, error) {
-	return pubsub_service.New()
-}
-
-// NewGatewayService - Google Cloud Http Gateway service
-func (p *GCPServiceFactory) NewGatewayService() (gateway.GatewayService, error) {
-	return cloudrun_plugin.New()
-}
-
-// NewQueueService - Returns Google Cloud Pubsub based queue service
-func (p *GCPServiceFactory) NewQueueService() (queue.QueueService, error) {
-	return pubsub_queue_service.New()
-}
-
-// NewStorageService - Returns Google Cloud Storage based storage service
-func (p *GCPServiceFactory) NewStorageService() (storage.StorageService, error) {
-	return storage_service.New()
+	return gcp_factory.New()
 }
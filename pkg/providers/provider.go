@@ -15,10 +15,12 @@
 package providers
 
 import (
+	"github.com/nitrictech/nitric/pkg/plugins/config"
 	"github.com/nitrictech/nitric/pkg/plugins/document"
 	"github.com/nitrictech/nitric/pkg/plugins/events"
 	"github.com/nitrictech/nitric/pkg/plugins/gateway"
 	"github.com/nitrictech/nitric/pkg/plugins/queue"
+	"github.com/nitrictech/nitric/pkg/plugins/secret"
 	"github.com/nitrictech/nitric/pkg/plugins/storage"
 )
 
@@ -29,6 +31,8 @@ type ServiceFactory interface {
 	NewGatewayService() (gateway.GatewayService, error)
 	NewQueueService() (queue.QueueService, error)
 	NewStorageService() (storage.StorageService, error)
+	NewSecretService() (secret.SecretService, error)
+	NewConfigService() (config.ConfigService, error)
 }
 
 // UnimplementedServiceFactory - provides stub methods for a ServiceFactory which return Unimplemented Methods.
@@ -68,3 +72,13 @@ func (p *UnimplementedServiceFactory) NewQueueService() (queue.QueueService, err
 func (p *UnimplementedServiceFactory) NewStorageService() (storage.StorageService, error) {
 	return nil, nil
 }
+
+// NewSecretService - Unimplemented
+func (p *UnimplementedServiceFactory) NewSecretService() (secret.SecretService, error) {
+	return nil, nil
+}
+
+// NewConfigService - Unimplemented
+func (p *UnimplementedServiceFactory) NewConfigService() (config.ConfigService, error) {
+	return nil, nil
+}
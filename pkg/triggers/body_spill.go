@@ -0,0 +1,64 @@
+// Copyright 2021 Nitric Pty Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package triggers
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"sync"
+)
+
+// spilledBody - A request body buffered to a temp file, the file is removed when the body is closed
+type spilledBody struct {
+	*os.File
+	closeOnce sync.Once
+	closeErr  error
+}
+
+// Close - Closes and removes the temp file, subsequent calls have no effect
+func (b *spilledBody) Close() error {
+	b.closeOnce.Do(func() {
+		b.closeErr = b.File.Close()
+		if err := os.Remove(b.File.Name()); err != nil && b.closeErr == nil {
+			b.closeErr = err
+		}
+	})
+
+	return b.closeErr
+}
+
+// spillBody - Copies a body to a temp file in dir, the default temp directory if empty,
+// returning a reader over the file from its start along with the size of the body
+func spillBody(body io.Reader, dir string) (io.ReadCloser, int64, error) {
+	file, err := ioutil.TempFile(dir, "nitric-request-body-")
+	if err != nil {
+		return nil, 0, err
+	}
+
+	spilled := &spilledBody{File: file}
+
+	length, err := io.Copy(file, body)
+	if err == nil {
+		_, err = file.Seek(0, io.SeekStart)
+	}
+
+	if err != nil {
+		spilled.Close()
+		return nil, 0, err
+	}
+
+	return spilled, length, nil
+}
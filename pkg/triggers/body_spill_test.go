@@ -0,0 +1,88 @@
+// Copyright 2021 Nitric Pty Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package triggers_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+
+	"github.com/nitrictech/nitric/pkg/triggers"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/valyala/fasthttp"
+)
+
+var _ = Describe("Body Spilling", func() {
+	var dir string
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "spill-test")
+		Expect(err).ShouldNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	Context("FromHttpRequestSpillingBody", func() {
+		When("The streamed body is larger than the threshold", func() {
+			body := bytes.Repeat([]byte("spilled "), 64)
+
+			It("Should buffer the body to a temp file until the request is closed", func() {
+				ctx := &fasthttp.RequestCtx{}
+				ctx.Request.SetBodyStream(bytes.NewReader(body), len(body))
+
+				request, err := triggers.FromHttpRequestSpillingBody(ctx, 16, dir)
+				Expect(err).ShouldNot(HaveOccurred())
+
+				By("Streaming the body")
+				Expect(request.IsStreamed()).To(BeTrue())
+				Expect(request.Body).To(BeNil())
+				Expect(request.BodyLength()).To(Equal(int64(len(body))))
+
+				By("Writing the body to a temp file")
+				files, _ := ioutil.ReadDir(dir)
+				Expect(files).To(HaveLen(1))
+
+				read, err := ioutil.ReadAll(request.BodyStream)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(read).To(Equal(body))
+
+				By("Removing the temp file when closed")
+				Expect(request.Close()).To(Succeed())
+				files, _ = ioutil.ReadDir(dir)
+				Expect(files).To(BeEmpty())
+			})
+		})
+
+		When("The streamed body is within the threshold", func() {
+			It("Should read the body into memory", func() {
+				ctx := &fasthttp.RequestCtx{}
+				ctx.Request.SetBodyStream(bytes.NewReader([]byte("small")), 5)
+
+				request, err := triggers.FromHttpRequestSpillingBody(ctx, 16, dir)
+				Expect(err).ShouldNot(HaveOccurred())
+
+				Expect(request.IsStreamed()).To(BeFalse())
+				Expect(request.Body).To(Equal([]byte("small")))
+
+				files, _ := ioutil.ReadDir(dir)
+				Expect(files).To(BeEmpty())
+			})
+		})
+	})
+})
@@ -0,0 +1,44 @@
+// Copyright 2021 Nitric Pty Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package triggers
+
+type BucketNotificationType int
+
+const (
+	BucketNotificationType_Created BucketNotificationType = iota
+	BucketNotificationType_Deleted
+)
+
+func (e BucketNotificationType) String() string {
+	return []string{"CREATED", "DELETED"}[e]
+}
+
+// BucketNotification - A notification that an object in a storage bucket has changed
+type BucketNotification struct {
+	// The name of the bucket containing the object
+	Bucket string
+	// The key of the object
+	Key string
+	// The type of change made to the object
+	EventType BucketNotificationType
+	// The size of the object in bytes, zero for deleted objects
+	Size int64
+	// The entity tag of the object, providers may omit it for deleted objects
+	ETag string
+}
+
+func (*BucketNotification) GetTriggerType() TriggerType {
+	return TriggerType_Bucket
+}
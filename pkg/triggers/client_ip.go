@@ -0,0 +1,174 @@
+// Copyright 2021 Nitric Pty Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package triggers
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/valyala/fasthttp"
+)
+
+// TrustedProxies - The networks of the proxies and load balancers whose forwarding headers are trusted
+type TrustedProxies struct {
+	networks []*net.IPNet
+}
+
+// ParseTrustedProxies - Parses a list of CIDRs or single IP addresses of trusted proxies
+func ParseTrustedProxies(cidrs []string) (*TrustedProxies, error) {
+	proxies := &TrustedProxies{
+		networks: make([]*net.IPNet, 0, len(cidrs)),
+	}
+
+	for _, cidr := range cidrs {
+		cidr = strings.TrimSpace(cidr)
+		if cidr == "" {
+			continue
+		}
+
+		if !strings.Contains(cidr, "/") {
+			ip := net.ParseIP(cidr)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid trusted proxy %s, expected an IP address or CIDR", cidr)
+			}
+
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+
+			proxies.networks = append(proxies.networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %s, expected an IP address or CIDR", cidr)
+		}
+
+		proxies.networks = append(proxies.networks, network)
+	}
+
+	return proxies, nil
+}
+
+// Trusts - Returns true if the address belongs to a trusted proxy
+func (p *TrustedProxies) Trusts(ip net.IP) bool {
+	if p == nil || ip == nil {
+		return false
+	}
+
+	for _, network := range p.networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// parseHop - Parses a forwarded address, which may have a port and IPv6 addresses may be bracketed.
+// Returns nil for obfuscated identifiers and unknown addresses
+func parseHop(hop string) net.IP {
+	hop = strings.Trim(strings.TrimSpace(hop), `"`)
+
+	if host, _, err := net.SplitHostPort(hop); err == nil {
+		hop = host
+	}
+
+	return net.ParseIP(strings.Trim(hop, "[]"))
+}
+
+// forwardedFor - Returns the addresses of the for parameters of Forwarded header values, see RFC 7239
+func forwardedFor(values []string) []string {
+	hops := make([]string, 0)
+	for _, value := range values {
+		for _, element := range strings.Split(value, ",") {
+			for _, pair := range strings.Split(element, ";") {
+				kv := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+				if len(kv) == 2 && strings.EqualFold(kv[0], "for") {
+					hops = append(hops, kv[1])
+				}
+			}
+		}
+	}
+
+	return hops
+}
+
+// xForwardedFor - Returns the addresses of X-Forwarded-For header values
+func xForwardedFor(values []string) []string {
+	hops := make([]string, 0)
+	for _, value := range values {
+		for _, hop := range strings.Split(value, ",") {
+			if hop = strings.TrimSpace(hop); hop != "" {
+				hops = append(hops, hop)
+			}
+		}
+	}
+
+	return hops
+}
+
+// ClientIP - Returns the address of the client from the remote address of the connection and the addresses forwarded
+// by the proxies in front of it, in order from the client to the proxy connected to. Forwarded addresses are only
+// used while the hop that forwarded them is trusted, so the rightmost untrusted address is returned. Empty if that
+// address was hidden by a proxy
+func (p *TrustedProxies) ClientIP(remote net.IP, forwarded []string) string {
+	if remote == nil {
+		return ""
+	}
+
+	if !p.Trusts(remote) {
+		return remote.String()
+	}
+
+	ip := remote
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		ip = parseHop(forwarded[i])
+		if ip == nil {
+			return ""
+		}
+
+		if !p.Trusts(ip) {
+			return ip.String()
+		}
+	}
+
+	// Every hop was a trusted proxy, so the leftmost is the closest to the client
+	return ip.String()
+}
+
+// ClientIPFromRequest - Returns the address of the client of a request, trusting its Forwarded header,
+// or X-Forwarded-For if it has none, when they were set by trusted proxies
+func ClientIPFromRequest(ctx *fasthttp.RequestCtx, proxies *TrustedProxies) string {
+	var forwarded, xff []string
+	ctx.Request.Header.VisitAll(func(key []byte, value []byte) {
+		switch strings.ToLower(string(key)) {
+		case "forwarded":
+			forwarded = append(forwarded, string(value))
+		case "x-forwarded-for":
+			xff = append(xff, string(value))
+		}
+	})
+
+	hops := forwardedFor(forwarded)
+	if len(forwarded) == 0 {
+		hops = xForwardedFor(xff)
+	}
+
+	return proxies.ClientIP(ctx.RemoteIP(), hops)
+}
@@ -0,0 +1,118 @@
+// Copyright 2021 Nitric Pty Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package triggers_test
+
+import (
+	"net"
+
+	"github.com/nitrictech/nitric/pkg/triggers"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/valyala/fasthttp"
+)
+
+func requestFrom(remote string, headers map[string]string) *fasthttp.RequestCtx {
+	req := &fasthttp.Request{}
+	req.SetRequestURI("/")
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Init(req, &net.TCPAddr{IP: net.ParseIP(remote), Port: 51234}, nil)
+
+	return ctx
+}
+
+var _ = Describe("ClientIP", func() {
+	var proxies *triggers.TrustedProxies
+
+	BeforeEach(func() {
+		var err error
+		proxies, err = triggers.ParseTrustedProxies([]string{"10.0.0.0/8", "192.168.1.1"})
+		Expect(err).ShouldNot(HaveOccurred())
+	})
+
+	Context("ParseTrustedProxies", func() {
+		When("A proxy isn't an IP address or CIDR", func() {
+			It("Should return an error", func() {
+				_, err := triggers.ParseTrustedProxies([]string{"10.0.0.0/8", "proxy.local"})
+				Expect(err).Should(HaveOccurred())
+			})
+		})
+
+		When("A proxy is a single IP address", func() {
+			It("Should only trust that address", func() {
+				Expect(proxies.Trusts(net.ParseIP("192.168.1.1"))).To(BeTrue())
+				Expect(proxies.Trusts(net.ParseIP("192.168.1.2"))).To(BeFalse())
+			})
+		})
+	})
+
+	Context("ClientIPFromRequest", func() {
+		When("The request is from a trusted load balancer with an X-Forwarded-For header", func() {
+			It("Should return the rightmost untrusted hop", func() {
+				ctx := requestFrom("10.0.0.5", map[string]string{
+					// The leftmost address is set by the client, so can't be trusted
+					"X-Forwarded-For": "1.1.1.1, 203.0.113.7, 10.0.0.2",
+				})
+
+				Expect(triggers.ClientIPFromRequest(ctx, proxies)).To(Equal("203.0.113.7"))
+			})
+		})
+
+		When("The request is from a trusted load balancer with a Forwarded header", func() {
+			It("Should prefer it to X-Forwarded-For", func() {
+				ctx := requestFrom("10.0.0.5", map[string]string{
+					"Forwarded":       `for=1.1.1.1, for="[2001:db8::1]:4711";proto=https, for=192.168.1.1`,
+					"X-Forwarded-For": "198.51.100.1",
+				})
+
+				Expect(triggers.ClientIPFromRequest(ctx, proxies)).To(Equal("2001:db8::1"))
+			})
+		})
+
+		When("The request isn't from a trusted proxy", func() {
+			It("Should ignore the forwarded headers", func() {
+				ctx := requestFrom("203.0.113.9", map[string]string{
+					"X-Forwarded-For": "1.1.1.1",
+				})
+
+				Expect(triggers.ClientIPFromRequest(ctx, proxies)).To(Equal("203.0.113.9"))
+				Expect(triggers.FromHttpRequest(ctx).ClientIP).To(Equal("203.0.113.9"))
+			})
+		})
+
+		When("Every hop is a trusted proxy", func() {
+			It("Should return the leftmost hop", func() {
+				ctx := requestFrom("10.0.0.5", map[string]string{
+					"X-Forwarded-For": "10.1.1.1, 10.0.0.2",
+				})
+
+				Expect(triggers.ClientIPFromRequest(ctx, proxies)).To(Equal("10.1.1.1"))
+			})
+		})
+
+		When("A trusted proxy hid the address of the hop before it", func() {
+			It("Should return no address", func() {
+				ctx := requestFrom("10.0.0.5", map[string]string{
+					"Forwarded": "for=_hidden, for=10.0.0.2",
+				})
+
+				Expect(triggers.ClientIPFromRequest(ctx, proxies)).To(Equal(""))
+			})
+		})
+	})
+})
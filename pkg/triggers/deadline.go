@@ -0,0 +1,97 @@
+// Copyright 2021 Nitric Pty Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package triggers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// GrpcTimeoutHeader - The header gRPC and gRPC-Web clients send the time remaining before their deadline in
+	GrpcTimeoutHeader = "grpc-timeout"
+	// EnvoyTimeoutHeader - The header Envoy proxies send the time they'll wait for the upstream response in, in milliseconds
+	EnvoyTimeoutHeader = "x-envoy-expected-rq-timeout-ms"
+)
+
+// The units of a grpc-timeout header value
+var grpcTimeoutUnits = map[byte]time.Duration{
+	'H': time.Hour,
+	'M': time.Minute,
+	'S': time.Second,
+	'm': time.Millisecond,
+	'u': time.Microsecond,
+	'n': time.Nanosecond,
+}
+
+// ParseGrpcTimeout - Parses a grpc-timeout header value, a positive integer of up to 8 digits followed by a unit,
+// e.g. 100m for 100 milliseconds
+func ParseGrpcTimeout(value string) (time.Duration, error) {
+	value = strings.TrimSpace(value)
+	if len(value) < 2 || len(value) > 9 {
+		return 0, fmt.Errorf("invalid grpc-timeout %s, expected up to 8 digits followed by a unit", value)
+	}
+
+	unit, ok := grpcTimeoutUnits[value[len(value)-1]]
+	if !ok {
+		return 0, fmt.Errorf("invalid grpc-timeout %s, unknown unit %c", value, value[len(value)-1])
+	}
+
+	amount, err := strconv.ParseInt(value[:len(value)-1], 10, 64)
+	if err != nil || amount < 0 {
+		return 0, fmt.Errorf("invalid grpc-timeout %s, expected up to 8 digits followed by a unit", value)
+	}
+
+	return time.Duration(amount) * unit, nil
+}
+
+// DeadlineFromHeader - Returns the deadline set by the caller of a request received at the given time,
+// from its grpc-timeout or Envoy timeout headers. The earliest deadline is used if both are set,
+// and the zero time if neither is set or valid
+func DeadlineFromHeader(header map[string][]string, received time.Time) time.Time {
+	var deadline time.Time
+
+	for key, values := range header {
+		if len(values) == 0 {
+			continue
+		}
+
+		var timeout time.Duration
+		switch strings.ToLower(key) {
+		case GrpcTimeoutHeader:
+			t, err := ParseGrpcTimeout(values[0])
+			if err != nil {
+				continue
+			}
+			timeout = t
+		case EnvoyTimeoutHeader:
+			ms, err := strconv.ParseInt(strings.TrimSpace(values[0]), 10, 64)
+			if err != nil || ms < 0 {
+				continue
+			}
+			timeout = time.Duration(ms) * time.Millisecond
+		default:
+			continue
+		}
+
+		if d := received.Add(timeout); deadline.IsZero() || d.Before(deadline) {
+			deadline = d
+		}
+	}
+
+	return deadline
+}
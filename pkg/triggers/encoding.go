@@ -0,0 +1,233 @@
+// Copyright 2021 Nitric Pty Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package triggers
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/valyala/fasthttp"
+)
+
+// Encoder - encodes a value into a HTTP response body
+type Encoder func(value interface{}) ([]byte, error)
+
+// ErrNotAcceptable - returned when no registered encoder satisfies a request's Accept header
+var ErrNotAcceptable = fmt.Errorf("no encoder available for the accepted media types")
+
+// EncoderRegistry - the encoders available for content negotiation, keyed by media type
+type EncoderRegistry struct {
+	lock     sync.RWMutex
+	encoders map[string]Encoder
+	// Media types in the order they were registered, used to resolve wildcard media ranges
+	mediaTypes []string
+	// The media type used when a request accepts any media type
+	defaultType string
+}
+
+// Register - adds an encoder for a media type, replacing any existing encoder for the type
+func (r *EncoderRegistry) Register(mediaType string, encoder Encoder) error {
+	parsed, _, err := mime.ParseMediaType(mediaType)
+	if err != nil {
+		return fmt.Errorf("invalid media type %s: %v", mediaType, err)
+	}
+
+	if strings.Contains(parsed, "*") {
+		return fmt.Errorf("invalid media type %s, encoders can't be registered for wildcard media types", mediaType)
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if _, ok := r.encoders[parsed]; !ok {
+		r.mediaTypes = append(r.mediaTypes, parsed)
+	}
+	r.encoders[parsed] = encoder
+
+	return nil
+}
+
+type mediaRange struct {
+	mediaType string
+	quality   float64
+	// 0 for */*, 1 for type/*, 2 for type/subtype
+	specificity int
+}
+
+// parseAccept - parses an Accept header into media ranges, ordered by preference
+func parseAccept(accept string) []mediaRange {
+	ranges := make([]mediaRange, 0)
+
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			// Ignore malformed ranges rather than rejecting the request
+			continue
+		}
+
+		quality := 1.0
+		if q, ok := params["q"]; ok {
+			if quality, err = strconv.ParseFloat(q, 64); err != nil {
+				continue
+			}
+		}
+
+		specificity := 2
+		if mediaType == "*/*" {
+			specificity = 0
+		} else if strings.HasSuffix(mediaType, "/*") {
+			specificity = 1
+		}
+
+		ranges = append(ranges, mediaRange{
+			mediaType:   mediaType,
+			quality:     quality,
+			specificity: specificity,
+		})
+	}
+
+	sort.SliceStable(ranges, func(i, j int) bool {
+		if ranges[i].quality != ranges[j].quality {
+			return ranges[i].quality > ranges[j].quality
+		}
+		return ranges[i].specificity > ranges[j].specificity
+	})
+
+	return ranges
+}
+
+// excluded - returns true if a media type has been explicitly refused with a quality of 0
+func excluded(ranges []mediaRange, mediaType string) bool {
+	for _, r := range ranges {
+		if r.quality == 0 && r.mediaType == mediaType {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Negotiate - selects the media type and encoder best satisfying the given Accept header
+// An empty Accept header accepts the default media type
+func (r *EncoderRegistry) Negotiate(accept string) (string, Encoder, error) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	if strings.TrimSpace(accept) == "" {
+		return r.defaultType, r.encoders[r.defaultType], nil
+	}
+
+	ranges := parseAccept(accept)
+
+	for _, mr := range ranges {
+		if mr.quality <= 0 {
+			continue
+		}
+
+		switch mr.specificity {
+		case 2:
+			if encoder, ok := r.encoders[mr.mediaType]; ok {
+				return mr.mediaType, encoder, nil
+			}
+		case 1:
+			prefix := strings.TrimSuffix(mr.mediaType, "*")
+			// Prefer the default media type where it satisfies the range
+			if strings.HasPrefix(r.defaultType, prefix) && !excluded(ranges, r.defaultType) {
+				return r.defaultType, r.encoders[r.defaultType], nil
+			}
+			for _, mediaType := range r.mediaTypes {
+				if strings.HasPrefix(mediaType, prefix) && !excluded(ranges, mediaType) {
+					return mediaType, r.encoders[mediaType], nil
+				}
+			}
+		case 0:
+			if !excluded(ranges, r.defaultType) {
+				return r.defaultType, r.encoders[r.defaultType], nil
+			}
+			for _, mediaType := range r.mediaTypes {
+				if !excluded(ranges, mediaType) {
+					return mediaType, r.encoders[mediaType], nil
+				}
+			}
+		}
+	}
+
+	return "", nil, ErrNotAcceptable
+}
+
+// EncodeResponse - encodes a value as the body of a response, using the media type best satisfying the request's
+// Accept header. ErrNotAcceptable is returned if none of the registered encoders are acceptable, which should
+// usually be reported to the caller with a 406 Not Acceptable response
+func (r *EncoderRegistry) EncodeResponse(request *HttpRequest, statusCode int, value interface{}) (*HttpResponse, error) {
+	mediaType, encoder, err := r.Negotiate(request.accept())
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := encoder(value)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding response as %s: %v", mediaType, err)
+	}
+
+	header := &fasthttp.ResponseHeader{}
+	header.SetContentType(mediaType)
+
+	return &HttpResponse{
+		Header:     header,
+		Body:       body,
+		StatusCode: statusCode,
+	}, nil
+}
+
+// accept - returns the request's Accept header, combining multiple headers into a single list
+func (r *HttpRequest) accept() string {
+	accepted := make([]string, 0)
+	for key, val := range r.Header {
+		if strings.EqualFold(key, "Accept") {
+			accepted = append(accepted, val...)
+		}
+	}
+
+	return strings.Join(accepted, ",")
+}
+
+// NewEncoderRegistry - creates a new registry, with JSON as the default encoding
+func NewEncoderRegistry() *EncoderRegistry {
+	return &EncoderRegistry{
+		encoders: map[string]Encoder{
+			"application/json": json.Marshal,
+		},
+		mediaTypes:  []string{"application/json"},
+		defaultType: "application/json",
+	}
+}
+
+// DefaultEncoders - the registry used by RegisterEncoder and EncodeResponse
+var DefaultEncoders = NewEncoderRegistry()
+
+// RegisterEncoder - adds an encoder for a custom media type to the default registry
+func RegisterEncoder(mediaType string, encoder Encoder) error {
+	return DefaultEncoders.Register(mediaType, encoder)
+}
+
+// EncodeResponse - encodes a value as the body of a response using the default registry
+func EncodeResponse(request *HttpRequest, statusCode int, value interface{}) (*HttpResponse, error) {
+	return DefaultEncoders.EncodeResponse(request, statusCode, value)
+}
@@ -0,0 +1,106 @@
+// Copyright 2021 Nitric Pty Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package triggers_test
+
+import (
+	"fmt"
+
+	"github.com/nitrictech/nitric/pkg/triggers"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type greeting struct {
+	Message string `json:"message"`
+}
+
+func textEncoder(value interface{}) ([]byte, error) {
+	return []byte(fmt.Sprintf("%v", value)), nil
+}
+
+func requestAccepting(accept string) *triggers.HttpRequest {
+	return &triggers.HttpRequest{
+		Header: map[string][]string{
+			"accept": {accept},
+		},
+	}
+}
+
+var _ = Describe("Encoding", func() {
+	Context("EncodeResponse", func() {
+		When("The request has no Accept header", func() {
+			It("Should encode the value as JSON", func() {
+				registry := triggers.NewEncoderRegistry()
+
+				response, err := registry.EncodeResponse(&triggers.HttpRequest{}, 200, &greeting{Message: "hello"})
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(response.StatusCode).To(Equal(200))
+				Expect(string(response.Header.ContentType())).To(Equal("application/json"))
+				Expect(string(response.Body)).To(Equal(`{"message":"hello"}`))
+			})
+		})
+
+		When("The request prefers a registered custom media type", func() {
+			It("Should encode the value with the custom encoder", func() {
+				registry := triggers.NewEncoderRegistry()
+				Expect(registry.Register("text/plain", textEncoder)).ShouldNot(HaveOccurred())
+
+				response, err := registry.EncodeResponse(requestAccepting("application/json;q=0.5, text/plain"), 200, "hello")
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(string(response.Header.ContentType())).To(Equal("text/plain"))
+				Expect(string(response.Body)).To(Equal("hello"))
+			})
+		})
+
+		When("The request accepts a wildcard media range", func() {
+			It("Should select a registered encoder matching the range", func() {
+				registry := triggers.NewEncoderRegistry()
+				registry.Register("text/plain", textEncoder)
+
+				response, err := registry.EncodeResponse(requestAccepting("text/*"), 200, "hello")
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(string(response.Header.ContentType())).To(Equal("text/plain"))
+			})
+
+			It("Should prefer JSON for any media type", func() {
+				registry := triggers.NewEncoderRegistry()
+				registry.Register("text/plain", textEncoder)
+
+				response, err := registry.EncodeResponse(requestAccepting("*/*"), 200, "hello")
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(string(response.Header.ContentType())).To(Equal("application/json"))
+			})
+		})
+
+		When("No registered encoder is acceptable", func() {
+			It("Should return ErrNotAcceptable", func() {
+				registry := triggers.NewEncoderRegistry()
+
+				_, err := registry.EncodeResponse(requestAccepting("application/xml, application/json;q=0"), 200, "hello")
+				Expect(err).To(Equal(triggers.ErrNotAcceptable))
+			})
+		})
+	})
+
+	Context("Register", func() {
+		When("Registering a wildcard media type", func() {
+			It("Should return an error", func() {
+				registry := triggers.NewEncoderRegistry()
+
+				Expect(registry.Register("text/*", textEncoder)).Should(HaveOccurred())
+			})
+		})
+	})
+})
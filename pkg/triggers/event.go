@@ -19,6 +19,8 @@ type Event struct {
 	ID      string
 	Topic   string
 	Payload []byte
+	// The number of times the event has been replayed from the dead-letter queue
+	ReplayCount int
 }
 
 func (*Event) GetTriggerType() TriggerType {
@@ -15,7 +15,10 @@
 package triggers
 
 import (
+	"io"
+	"io/ioutil"
 	"strings"
+	"time"
 
 	"github.com/valyala/fasthttp"
 )
@@ -25,20 +28,112 @@ type HttpRequest struct {
 	Header map[string][]string
 	// The original body stream
 	Body []byte
+	// A body buffered outside of memory, when set it is read in place of Body
+	BodyStream io.ReadCloser
+	// The size of BodyStream in bytes
+	BodyStreamLength int64
 	// The original method
 	Method string
 	// The original path
 	Path string
-	// URL query parameters
+	// URL query parameters, decoded, with a value for each occurrence of a repeated key
 	Query map[string][]string
+	// The route template the path matched, e.g. /users/:id, empty if no route matched
+	Route string
+	// The parameters extracted from the path by the matched route
+	RouteParams map[string]string
+	// The time by which the caller needs a response, e.g. from a grpc-timeout header, zero if the caller set none
+	Deadline time.Time
+	// The address of the client, resolved through the forwarding headers of trusted proxies, empty if unknown
+	ClientIP string
+	// The caller the request was authorized for, nil if the request wasn't authorized
+	Principal *Principal
+}
+
+// Principal - The identity of the caller of an authorized request
+type Principal struct {
+	// The subject the caller was authorized as, e.g. the sub claim of their token
+	Subject string
+	// The claims of the caller's token
+	Claims map[string]interface{}
 }
 
 func (*HttpRequest) GetTriggerType() TriggerType {
 	return TriggerType_Request
 }
 
+// IsStreamed - Returns true if the request body is streamed
+func (r *HttpRequest) IsStreamed() bool {
+	return r.BodyStream != nil
+}
+
+// BodyLength - Returns the size of the request body in bytes
+func (r *HttpRequest) BodyLength() int64 {
+	if r.BodyStream != nil {
+		return r.BodyStreamLength
+	}
+
+	return int64(len(r.Body))
+}
+
+// BufferBody - Reads a streamed body into Body, for use where a request can't be streamed
+func (r *HttpRequest) BufferBody() error {
+	if r.BodyStream == nil {
+		return nil
+	}
+
+	defer r.BodyStream.Close()
+	body, err := ioutil.ReadAll(r.BodyStream)
+	r.BodyStream = nil
+
+	if err != nil {
+		return err
+	}
+
+	r.Body = body
+	return nil
+}
+
+// Close - Releases a streamed body, removing any temp file it was buffered to
+func (r *HttpRequest) Close() error {
+	if r.BodyStream == nil {
+		return nil
+	}
+
+	return r.BodyStream.Close()
+}
+
 // FromHttpRequest (constructs a HttpRequest source type from a HttpRequest)
 func FromHttpRequest(ctx *fasthttp.RequestCtx) *HttpRequest {
+	request := fromHttpRequestHeaders(ctx)
+	request.Body = ctx.Request.Body()
+
+	return request
+}
+
+// FromHttpRequestSpillingBody - Constructs a HttpRequest, buffering streamed bodies larger than spillBytes
+// to a temp file in dir instead of memory. The request must be closed to remove the file
+func FromHttpRequestSpillingBody(ctx *fasthttp.RequestCtx, spillBytes int, dir string) (*HttpRequest, error) {
+	// Bodies of unknown length are chunked, and may be of any size
+	contentLength := ctx.Request.Header.ContentLength()
+	if !ctx.Request.IsBodyStream() || (contentLength >= 0 && contentLength <= spillBytes) {
+		return FromHttpRequest(ctx), nil
+	}
+
+	body, length, err := spillBody(ctx.RequestBodyStream(), dir)
+	if err != nil {
+		return nil, err
+	}
+
+	request := fromHttpRequestHeaders(ctx)
+	request.BodyStream = body
+	request.BodyStreamLength = length
+
+	return request, nil
+}
+
+// fromHttpRequestHeaders - Constructs a HttpRequest without its body
+func fromHttpRequestHeaders(ctx *fasthttp.RequestCtx) *HttpRequest {
 	headerCopy := make(map[string][]string)
 	queryArgs := make(map[string][]string)
 
@@ -67,11 +162,19 @@ func FromHttpRequest(ctx *fasthttp.RequestCtx) *HttpRequest {
 		queryArgs[k] = append(queryArgs[k], string(val))
 	})
 
+	// Deadlines are relative to when the request was received, rather than when its body was read
+	received := ctx.Time()
+	if received.IsZero() {
+		received = time.Now()
+	}
+
 	return &HttpRequest{
-		Header: headerCopy,
-		Body:   ctx.Request.Body(),
-		Method: string(ctx.Method()),
-		Path:   string(ctx.Path()),
-		Query:  queryArgs,
+		Header:   headerCopy,
+		Method:   string(ctx.Method()),
+		Path:     string(ctx.Path()),
+		Query:    queryArgs,
+		Deadline: DeadlineFromHeader(headerCopy, received),
+		// Forwarding headers can be spoofed, so only the connection's address is used until proxies are trusted
+		ClientIP: ClientIPFromRequest(ctx, nil),
 	}
 }
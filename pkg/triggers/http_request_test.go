@@ -0,0 +1,93 @@
+// Copyright 2021 Nitric Pty Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package triggers_test
+
+import (
+	"time"
+
+	"github.com/nitrictech/nitric/pkg/triggers"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/valyala/fasthttp"
+)
+
+var _ = Describe("HttpRequest", func() {
+	Context("FromHttpRequest", func() {
+		When("The URL has repeated and encoded query parameters", func() {
+			It("Should decode them into structured query params", func() {
+				ctx := &fasthttp.RequestCtx{}
+				ctx.Request.SetRequestURI("/search?tag=a&tag=b&na%20me=J%C3%B6rg&q=hello+world&amp%26=%3D&empty")
+
+				request := triggers.FromHttpRequest(ctx)
+
+				Expect(request.Path).To(Equal("/search"))
+				Expect(request.Query).To(Equal(map[string][]string{
+					"tag":   {"a", "b"},
+					"na me": {"Jörg"},
+					"q":     {"hello world"},
+					"amp&":  {"="},
+					"empty": {""},
+				}))
+			})
+		})
+	})
+
+	Context("DeadlineFromHeader", func() {
+		received := time.Now()
+
+		When("The request has a grpc-timeout header", func() {
+			It("Should return the deadline relative to when the request was received", func() {
+				deadline := triggers.DeadlineFromHeader(map[string][]string{
+					"Grpc-Timeout": {"250m"},
+				}, received)
+
+				Expect(deadline).To(BeTemporally("==", received.Add(250*time.Millisecond)))
+			})
+		})
+
+		When("The request has both grpc-timeout and Envoy timeout headers", func() {
+			It("Should return the earlier deadline", func() {
+				deadline := triggers.DeadlineFromHeader(map[string][]string{
+					"grpc-timeout":                   {"2S"},
+					"X-Envoy-Expected-Rq-Timeout-Ms": {"1500"},
+				}, received)
+
+				Expect(deadline).To(BeTemporally("==", received.Add(1500*time.Millisecond)))
+			})
+		})
+
+		When("The request has no valid timeout header", func() {
+			It("Should return the zero time", func() {
+				deadline := triggers.DeadlineFromHeader(map[string][]string{
+					"grpc-timeout": {"10x"},
+				}, received)
+
+				Expect(deadline.IsZero()).To(BeTrue())
+			})
+		})
+	})
+
+	Context("FromHttpRequest with a grpc-timeout header", func() {
+		It("Should set the request deadline", func() {
+			ctx := &fasthttp.RequestCtx{}
+			ctx.Request.SetRequestURI("/")
+			ctx.Request.Header.Set("grpc-timeout", "1M")
+
+			request := triggers.FromHttpRequest(ctx)
+
+			Expect(request.Deadline).To(BeTemporally("~", time.Now().Add(time.Minute), time.Second))
+		})
+	})
+})
@@ -16,6 +16,8 @@ package triggers
 
 import (
 	"fmt"
+	"io"
+	"io/ioutil"
 
 	pb "github.com/nitrictech/nitric/interfaces/nitric/v1"
 	"github.com/valyala/fasthttp"
@@ -27,10 +29,35 @@ type HttpResponse struct {
 	Header *fasthttp.ResponseHeader
 	// The original body stream
 	Body []byte
+	// A streamed body, when set it is sent in place of Body
+	BodyStream io.ReadCloser
 	// The original method
 	StatusCode int
 }
 
+// IsStreamed - Returns true if the response body is streamed
+func (r *HttpResponse) IsStreamed() bool {
+	return r.BodyStream != nil
+}
+
+// BufferBody - Reads a streamed body into Body, for use where a response can't be streamed
+func (r *HttpResponse) BufferBody() error {
+	if r.BodyStream == nil {
+		return nil
+	}
+
+	defer r.BodyStream.Close()
+	body, err := ioutil.ReadAll(r.BodyStream)
+	r.BodyStream = nil
+
+	if err != nil {
+		return err
+	}
+
+	r.Body = body
+	return nil
+}
+
 // FromHttpRequest (constructs a HttpRequest source type from a HttpRequest)
 func FromHttpResponse(resp *fasthttp.Response) *HttpResponse {
 	return &HttpResponse{
@@ -40,23 +67,34 @@ func FromHttpResponse(resp *fasthttp.Response) *HttpResponse {
 	}
 }
 
+// HeaderFromResponseContext - Builds the headers of a HTTP response context, each value of a header is added
+// as a separate header line so functions can set several cookies.
+// The deprecated single value headers are only used when the function provides no multi-value headers
+func HeaderFromResponseContext(httpContext *pb.HttpResponseContext) *fasthttp.ResponseHeader {
+	fasthttpHeader := &fasthttp.ResponseHeader{}
+
+	if len(httpContext.GetHeaders()) > 0 {
+		for key, val := range httpContext.GetHeaders() {
+			for _, v := range val.GetValue() {
+				fasthttpHeader.Add(key, v)
+			}
+		}
+	} else {
+		for key, val := range httpContext.GetHeadersOld() {
+			fasthttpHeader.Set(key, val)
+		}
+	}
+
+	return fasthttpHeader
+}
+
 // FromTriggerResponse (constructs a HttpResponse from a FaaS TriggerResponse)
 func FromTriggerResponse(triggerResponse *pb.TriggerResponse) (*HttpResponse, error) {
 	// FIXME: This will panic if the incorrect response type is provided
 	httpContext := triggerResponse.GetHttp()
 	if httpContext != nil {
-		fasthttpHeader := &fasthttp.ResponseHeader{}
-
-		if httpContext.GetHeaders() != nil {
-			for key, val := range httpContext.GetHeaders() {
-				for _, v := range val.Value {
-					fasthttpHeader.Add(key, v)
-				}
-			}
-		}
-
 		return &HttpResponse{
-			Header:     fasthttpHeader,
+			Header:     HeaderFromResponseContext(httpContext),
 			StatusCode: int(httpContext.Status),
 			Body:       triggerResponse.GetData(),
 		}, nil
@@ -0,0 +1,71 @@
+// Copyright 2021 Nitric Pty Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package triggers_test
+
+import (
+	pb "github.com/nitrictech/nitric/interfaces/nitric/v1"
+	"github.com/nitrictech/nitric/pkg/triggers"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("HttpResponse", func() {
+	Context("FromTriggerResponse", func() {
+		When("The function sets multiple cookies", func() {
+			It("Should add each cookie as a separate header", func() {
+				response, err := triggers.FromTriggerResponse(&pb.TriggerResponse{
+					Context: &pb.TriggerResponse_Http{
+						Http: &pb.HttpResponseContext{
+							Status: 200,
+							Headers: map[string]*pb.HeaderValue{
+								"Set-Cookie": {Value: []string{"session=abc; HttpOnly", "csrf=def"}},
+							},
+							HeadersOld: map[string]string{
+								"Set-Cookie": "session=abc; HttpOnly",
+							},
+						},
+					},
+				})
+				Expect(err).ShouldNot(HaveOccurred())
+
+				cookies := make([]string, 0)
+				response.Header.VisitAll(func(key []byte, value []byte) {
+					if string(key) == "Set-Cookie" {
+						cookies = append(cookies, string(value))
+					}
+				})
+				Expect(cookies).To(Equal([]string{"session=abc; HttpOnly", "csrf=def"}))
+			})
+		})
+
+		When("The function only sets the deprecated single value headers", func() {
+			It("Should use them", func() {
+				response, err := triggers.FromTriggerResponse(&pb.TriggerResponse{
+					Context: &pb.TriggerResponse_Http{
+						Http: &pb.HttpResponseContext{
+							Status: 200,
+							HeadersOld: map[string]string{
+								"X-Test": "test",
+							},
+						},
+					},
+				})
+				Expect(err).ShouldNot(HaveOccurred())
+
+				Expect(string(response.Header.Peek("X-Test"))).To(Equal("test"))
+			})
+		})
+	})
+})
@@ -0,0 +1,103 @@
+// Copyright 2021 Nitric Pty Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package triggers
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"strings"
+)
+
+// FormPart - A single part of a multipart/form-data HTTP request body
+type FormPart struct {
+	// The form field name of the part
+	Name string
+	// The name of the uploaded file, empty for non-file fields
+	Filename string
+	// The headers of the part, e.g. its Content-Type
+	Header map[string][]string
+	// The content of the part
+	Content []byte
+}
+
+// contentType - returns the media type and parameters of the request's Content-Type header
+func (r *HttpRequest) contentType() (string, map[string]string, error) {
+	for key, val := range r.Header {
+		if strings.EqualFold(key, "Content-Type") && len(val) > 0 {
+			return mime.ParseMediaType(val[0])
+		}
+	}
+
+	return "", nil, fmt.Errorf("request has no Content-Type header")
+}
+
+// IsMultipart - returns true if the request body is multipart/form-data
+func (r *HttpRequest) IsMultipart() bool {
+	mediaType, _, err := r.contentType()
+
+	return err == nil && mediaType == "multipart/form-data"
+}
+
+// MultipartForm - parses the parts of a multipart/form-data request body, the raw Body is left unchanged
+func (r *HttpRequest) MultipartForm() ([]*FormPart, error) {
+	mediaType, params, err := r.contentType()
+	if err != nil {
+		return nil, err
+	}
+
+	if mediaType != "multipart/form-data" {
+		return nil, fmt.Errorf("expected multipart/form-data request, got %s", mediaType)
+	}
+
+	if r.IsStreamed() {
+		return nil, fmt.Errorf("multipart/form-data request body is streamed and can't be parsed in memory")
+	}
+
+	boundary := params["boundary"]
+	if boundary == "" {
+		return nil, fmt.Errorf("multipart/form-data request has no boundary")
+	}
+
+	reader := multipart.NewReader(bytes.NewReader(r.Body), boundary)
+	parts := make([]*FormPart, 0)
+
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading multipart body: %v", err)
+		}
+
+		content, err := ioutil.ReadAll(part)
+		if err != nil {
+			return nil, fmt.Errorf("error reading part %s: %v", part.FormName(), err)
+		}
+
+		parts = append(parts, &FormPart{
+			Name:     part.FormName(),
+			Filename: part.FileName(),
+			Header:   map[string][]string(part.Header),
+			Content:  content,
+		})
+	}
+
+	return parts, nil
+}
@@ -0,0 +1,106 @@
+// Copyright 2021 Nitric Pty Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package triggers_test
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/textproto"
+
+	"github.com/nitrictech/nitric/pkg/triggers"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Multipart", func() {
+	Context("MultipartForm", func() {
+		When("The request contains two files and a text field", func() {
+			body := &bytes.Buffer{}
+			writer := multipart.NewWriter(body)
+
+			writer.WriteField("description", "test uploads")
+
+			textHeader := textproto.MIMEHeader{}
+			textHeader.Set("Content-Disposition", `form-data; name="first"; filename="first.txt"`)
+			textHeader.Set("Content-Type", "text/plain")
+			textPart, _ := writer.CreatePart(textHeader)
+			textPart.Write([]byte("first file contents"))
+
+			binaryPart, _ := writer.CreateFormFile("second", "second.bin")
+			binaryPart.Write([]byte{0x00, 0x01, 0x02})
+
+			writer.Close()
+
+			request := &triggers.HttpRequest{
+				Header: map[string][]string{
+					"Content-Type": {writer.FormDataContentType()},
+				},
+				Body:   body.Bytes(),
+				Method: "POST",
+				Path:   "/upload",
+			}
+			rawBody := append([]byte{}, body.Bytes()...)
+
+			It("Should be detected as multipart", func() {
+				Expect(request.IsMultipart()).To(BeTrue())
+			})
+
+			It("Should return the parts in order", func() {
+				parts, err := request.MultipartForm()
+
+				By("Not returning an error")
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(parts).To(HaveLen(3))
+
+				By("Returning the text field")
+				Expect(parts[0].Name).To(Equal("description"))
+				Expect(parts[0].Filename).To(BeEmpty())
+				Expect(parts[0].Content).To(Equal([]byte("test uploads")))
+
+				By("Returning the first file with its headers")
+				Expect(parts[1].Name).To(Equal("first"))
+				Expect(parts[1].Filename).To(Equal("first.txt"))
+				Expect(parts[1].Header["Content-Type"]).To(Equal([]string{"text/plain"}))
+				Expect(parts[1].Content).To(Equal([]byte("first file contents")))
+
+				By("Returning the second file")
+				Expect(parts[2].Name).To(Equal("second"))
+				Expect(parts[2].Filename).To(Equal("second.bin"))
+				Expect(parts[2].Content).To(Equal([]byte{0x00, 0x01, 0x02}))
+
+				By("Leaving the raw body unchanged")
+				Expect(request.Body).To(Equal(rawBody))
+			})
+		})
+
+		When("The request is not multipart", func() {
+			request := &triggers.HttpRequest{
+				Header: map[string][]string{
+					"content-type": {"application/json"},
+				},
+				Body: []byte(`{"test": "test"}`),
+			}
+
+			It("Should not be detected as multipart", func() {
+				Expect(request.IsMultipart()).To(BeFalse())
+			})
+
+			It("Should return an error when parsed", func() {
+				_, err := request.MultipartForm()
+				Expect(err).Should(HaveOccurred())
+			})
+		})
+	})
+})
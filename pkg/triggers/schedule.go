@@ -0,0 +1,31 @@
+// Copyright 2021 Nitric Pty Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package triggers
+
+import "time"
+
+// Schedule - A trigger fired by a schedule at the times matched by its cron expression
+type Schedule struct {
+	// The name of the schedule
+	Name string
+	// The cron expression of the schedule
+	Expression string
+	// The time the schedule was due to fire
+	Time time.Time
+}
+
+func (*Schedule) GetTriggerType() TriggerType {
+	return TriggerType_Schedule
+}
@@ -0,0 +1,42 @@
+// Copyright 2021 Nitric Pty Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package triggers
+
+// WebsocketEventType enum
+type WebsocketEventType int
+
+const (
+	WebsocketEventType_Connect WebsocketEventType = iota
+	WebsocketEventType_Message
+	WebsocketEventType_Disconnect
+)
+
+func (e WebsocketEventType) String() string {
+	return []string{"CONNECT", "MESSAGE", "DISCONNECT"}[e]
+}
+
+// WebsocketMessage - A message received on (or a change in the lifecycle of) a websocket connection
+type WebsocketMessage struct {
+	// The ID of the connection the message relates to
+	ConnectionID string
+	// The type of websocket event
+	EventType WebsocketEventType
+	// The message payload, empty for connect and disconnect events
+	Payload []byte
+}
+
+func (*WebsocketMessage) GetTriggerType() TriggerType {
+	return TriggerType_Websocket
+}
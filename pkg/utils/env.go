@@ -17,6 +17,7 @@ package utils
 import (
 	"os"
 	"path/filepath"
+	"strings"
 )
 
 // GetEnv - Retrieve an environment variable with a fallback
@@ -27,6 +28,17 @@ func GetEnv(key, fallback string) string {
 	return fallback
 }
 
+// GetEnvList - Retrieve a comma separated environment variable as a list, empty entries are ignored
+func GetEnvList(key string) []string {
+	list := make([]string, 0)
+	for _, v := range strings.Split(GetEnv(key, ""), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
 // GetDevVolumePath - Returns the default directory to be used for local development plugins
 // this directory points at a docker volume, used to share data between running containers.
 func GetDevVolumePath() string {
@@ -0,0 +1,201 @@
+// Copyright 2021 Nitric Pty Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/nitrictech/nitric/pkg/logger"
+)
+
+// DefaultWatchInterval - How often watched files are checked for changes unless configured
+const DefaultWatchInterval = time.Second
+
+// FileWatcher - Polls the files matching a pattern for changes made by other tools, reloading them once
+// the operations using the files have finished. Intended for local development plugins only
+type FileWatcher struct {
+	pattern  string
+	interval time.Duration
+	reload   func(path string) error
+	log      logger.Logger
+
+	// Held for reading by operations using the files and for writing while they're reloaded,
+	// so reads never see a file that's part way through being reloaded
+	lock sync.RWMutex
+	// Guards the file states, which operations record concurrently while holding the read lock
+	stateLock sync.Mutex
+	states    map[string]fileState
+
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// fileState - The state a file was last seen in, changes by other tools update the modification time or size
+type fileState struct {
+	modTime time.Time
+	size    int64
+}
+
+// Acquire - Waits for any reload in progress, then holds off reloads until the operation calls Release.
+// A nil watcher does nothing, so plugins can call it whether or not watching is enabled
+func (w *FileWatcher) Acquire() {
+	if w == nil {
+		return
+	}
+
+	w.lock.RLock()
+}
+
+// Release - Records the state the operation left the file in, so the operation's own changes aren't reloaded
+func (w *FileWatcher) Release(path string) {
+	if w == nil {
+		return
+	}
+
+	w.record(path)
+	w.lock.RUnlock()
+}
+
+// Stop - Stops polling for changes, waiting for a check in progress to finish
+func (w *FileWatcher) Stop() {
+	if w == nil {
+		return
+	}
+
+	w.stopOnce.Do(func() {
+		close(w.stop)
+	})
+	<-w.done
+}
+
+// record - Stores the current state of the file, forgetting it if the file no longer exists
+func (w *FileWatcher) record(path string) {
+	w.stateLock.Lock()
+	defer w.stateLock.Unlock()
+
+	info, err := os.Stat(path)
+	if err != nil {
+		delete(w.states, path)
+		return
+	}
+
+	w.states[path] = fileState{modTime: info.ModTime(), size: info.Size()}
+}
+
+// changed - Returns the files that were created or modified since their state was last recorded
+func (w *FileWatcher) changed() ([]string, error) {
+	paths, err := filepath.Glob(w.pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	w.stateLock.Lock()
+	defer w.stateLock.Unlock()
+
+	changed := make([]string, 0)
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil || info.IsDir() {
+			continue
+		}
+
+		if known, ok := w.states[path]; ok && known.modTime.Equal(info.ModTime()) && known.size == info.Size() {
+			continue
+		}
+
+		changed = append(changed, path)
+	}
+
+	return changed, nil
+}
+
+// check - Reloads the files changed by other tools, holding off operations until the reloads are done
+func (w *FileWatcher) check() {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	changed, err := w.changed()
+	if err != nil {
+		w.log.Warn("error listing watched files", "pattern", w.pattern, "error", err)
+		return
+	}
+
+	for _, path := range changed {
+		if err := w.reload(path); err != nil {
+			// The state isn't recorded, so the reload is retried on the next check
+			w.log.Warn("error reloading file modified by another tool", "path", path, "error", err)
+			continue
+		}
+
+		// Reloading may write to the file, so its state is recorded afterwards
+		w.record(path)
+		w.log.Info("reloaded file modified by another tool", "path", path)
+	}
+}
+
+func (w *FileWatcher) run() {
+	defer close(w.done)
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-ticker.C:
+			w.check()
+		}
+	}
+}
+
+// NewFileWatcher - Starts polling the files matching the pattern, calling reload for each file another tool changes.
+// Files that already exist are treated as unchanged until they're next modified
+func NewFileWatcher(pattern string, interval time.Duration, reload func(path string) error, log logger.Logger) (*FileWatcher, error) {
+	if interval <= 0 {
+		interval = DefaultWatchInterval
+	}
+
+	if log == nil {
+		log = logger.NewNoopLogger()
+	}
+
+	paths, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &FileWatcher{
+		pattern:  pattern,
+		interval: interval,
+		reload:   reload,
+		log:      log,
+		states:   make(map[string]fileState),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+
+	for _, path := range paths {
+		w.record(path)
+	}
+
+	go w.run()
+
+	return w, nil
+}
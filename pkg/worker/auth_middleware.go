@@ -0,0 +1,73 @@
+// Copyright 2021 Nitric Pty Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package worker
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/nitrictech/nitric/pkg/triggers"
+	"github.com/valyala/fasthttp"
+)
+
+// bearerToken - Returns the bearer token of the request or an empty string if it doesn't have one
+func bearerToken(header map[string][]string) string {
+	for key, values := range header {
+		if http.CanonicalHeaderKey(key) != "Authorization" || len(values) == 0 {
+			continue
+		}
+
+		if parts := strings.SplitN(values[0], " ", 2); len(parts) == 2 && strings.EqualFold(parts[0], "Bearer") {
+			return strings.TrimSpace(parts[1])
+		}
+	}
+	return ""
+}
+
+// unauthorizedResponse - Returns a 401 response challenging the client for a bearer token
+func unauthorizedResponse() *triggers.HttpResponse {
+	header := &fasthttp.ResponseHeader{}
+	header.Set("WWW-Authenticate", "Bearer")
+	header.SetContentType("text/plain")
+
+	return &triggers.HttpResponse{
+		Header:     header,
+		Body:       []byte("Unauthorized"),
+		StatusCode: 401,
+	}
+}
+
+// BearerTokenAuth - Example middleware rejecting HTTP requests without one of the given bearer tokens
+// with a 401 response, events are delivered by the platform and pass through unchecked
+func BearerTokenAuth(tokens ...string) TriggerMiddleware {
+	return func(next Handler) Handler {
+		return func(trigger triggers.Trigger) (*triggers.HttpResponse, error) {
+			request, ok := trigger.(*triggers.HttpRequest)
+			if !ok {
+				return next(trigger)
+			}
+
+			provided := bearerToken(request.Header)
+			for _, token := range tokens {
+				if provided != "" && subtle.ConstantTimeCompare([]byte(provided), []byte(token)) == 1 {
+					return next(trigger)
+				}
+			}
+
+			return unauthorizedResponse(), nil
+		}
+	}
+}
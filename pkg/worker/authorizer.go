@@ -0,0 +1,63 @@
+// Copyright 2021 Nitric Pty Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package worker
+
+import "github.com/nitrictech/nitric/pkg/triggers"
+
+// Authorizer - Authorizes HTTP requests before they're dispatched to a worker
+type Authorizer interface {
+	// Authorize - Returns the principal the request was made by, or an error if the request isn't authorized.
+	// A nil principal and error allows the request without identifying its caller
+	Authorize(request *triggers.HttpRequest) (*triggers.Principal, error)
+}
+
+// NoopAuthorizer - Allows every request without identifying its caller
+type NoopAuthorizer struct{}
+
+var _ Authorizer = (*NoopAuthorizer)(nil)
+
+func (*NoopAuthorizer) Authorize(request *triggers.HttpRequest) (*triggers.Principal, error) {
+	return nil, nil
+}
+
+// invalidTokenResponse - Returns a 401 response telling the client its bearer token was rejected
+func invalidTokenResponse() *triggers.HttpResponse {
+	response := unauthorizedResponse()
+	// The reason isn't described, as it may help an attacker craft a token
+	response.Header.Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+
+	return response
+}
+
+// Authorize - Middleware attaching the principal of authorized HTTP requests, unauthorized requests receive a 401
+// response without reaching the worker. Events are delivered by the platform and pass through unchecked
+func Authorize(authorizer Authorizer) TriggerMiddleware {
+	return func(next Handler) Handler {
+		return func(trigger triggers.Trigger) (*triggers.HttpResponse, error) {
+			request, ok := trigger.(*triggers.HttpRequest)
+			if !ok {
+				return next(trigger)
+			}
+
+			principal, err := authorizer.Authorize(request)
+			if err != nil {
+				return invalidTokenResponse(), nil
+			}
+
+			request.Principal = principal
+			return next(trigger)
+		}
+	}
+}
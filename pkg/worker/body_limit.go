@@ -0,0 +1,104 @@
+// Copyright 2021 Nitric Pty Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package worker
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/nitrictech/nitric/pkg/triggers"
+	"github.com/valyala/fasthttp"
+)
+
+// bodyLimitWorker - Enforces maximum request and response body sizes for HTTP triggers
+type bodyLimitWorker struct {
+	Worker
+	maxRequestBodyBytes  int
+	maxResponseBodyBytes int
+}
+
+// HandleHttpRequest - Rejects requests with bodies over the limit with a 413 response
+// Responses over the limit are truncated and returned with an error
+func (w *bodyLimitWorker) HandleHttpRequest(trigger *triggers.HttpRequest) (*triggers.HttpResponse, error) {
+	if w.maxRequestBodyBytes > 0 && trigger.BodyLength() > int64(w.maxRequestBodyBytes) {
+		return &triggers.HttpResponse{
+			Header:     &fasthttp.ResponseHeader{},
+			Body:       []byte(fmt.Sprintf("Request body exceeds the maximum size of %d bytes", w.maxRequestBodyBytes)),
+			StatusCode: 413,
+		}, nil
+	}
+
+	response, err := w.Worker.HandleHttpRequest(trigger)
+
+	if err != nil {
+		return response, err
+	}
+
+	if w.maxResponseBodyBytes > 0 && response != nil && response.IsStreamed() {
+		response.BodyStream = &limitedBodyStream{
+			ReadCloser: response.BodyStream,
+			remaining:  w.maxResponseBodyBytes,
+			limit:      w.maxResponseBodyBytes,
+		}
+		return response, nil
+	}
+
+	if w.maxResponseBodyBytes > 0 && response != nil && len(response.Body) > w.maxResponseBodyBytes {
+		response.Body = response.Body[:w.maxResponseBodyBytes]
+		return response, fmt.Errorf("response body exceeds the maximum size of %d bytes and was truncated", w.maxResponseBodyBytes)
+	}
+
+	return response, nil
+}
+
+// limitedBodyStream - Fails a streamed response body once it exceeds the limit
+// the status and headers have already been sent, so the stream is cut short instead
+type limitedBodyStream struct {
+	io.ReadCloser
+	remaining int
+	limit     int
+}
+
+func (l *limitedBodyStream) Read(p []byte) (int, error) {
+	if l.remaining <= 0 {
+		// Check for any remaining body before reporting the limit as exceeded
+		var b [1]byte
+		n, err := l.ReadCloser.Read(b[:])
+		if n == 0 && err != nil {
+			return 0, err
+		}
+		return 0, fmt.Errorf("response body exceeds the maximum size of %d bytes and was truncated", l.limit)
+	}
+
+	if len(p) > l.remaining {
+		p = p[:l.remaining]
+	}
+
+	n, err := l.ReadCloser.Read(p)
+	l.remaining -= n
+
+	return n, err
+}
+
+// WithBodyLimits - Limits the size of HTTP request and response bodies, limits less than 1 are unlimited
+func WithBodyLimits(maxRequestBodyBytes int, maxResponseBodyBytes int) WorkerDecorator {
+	return func(wrkr Worker) Worker {
+		return &bodyLimitWorker{
+			Worker:               wrkr,
+			maxRequestBodyBytes:  maxRequestBodyBytes,
+			maxResponseBodyBytes: maxResponseBodyBytes,
+		}
+	}
+}
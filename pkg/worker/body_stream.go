@@ -0,0 +1,89 @@
+// Copyright 2021 Nitric Pty Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package worker
+
+import (
+	"io"
+	"sync"
+)
+
+// The number of chunks buffered for a streamed body before the sender waits for the reader
+const bodyStreamBufferSize = 16
+
+// httpBodyStream - An io.ReadCloser over a HTTP response body that is received in chunks
+type httpBodyStream struct {
+	chunks    chan []byte
+	current   []byte
+	closed    chan struct{}
+	closeOnce sync.Once
+	// Set before chunks is closed, if the body ended before it was complete
+	err error
+}
+
+func newHttpBodyStream() *httpBodyStream {
+	return &httpBodyStream{
+		chunks: make(chan []byte, bodyStreamBufferSize),
+		closed: make(chan struct{}),
+	}
+}
+
+// write - Adds a chunk to the body, waiting for the reader if the buffer is full.
+// Chunks written after the reader has closed the stream are discarded
+func (b *httpBodyStream) write(chunk []byte) {
+	if len(chunk) == 0 {
+		return
+	}
+
+	select {
+	case b.chunks <- chunk:
+	case <-b.closed:
+	}
+}
+
+// end - Marks the body as complete, or as failed if err is not nil
+func (b *httpBodyStream) end(err error) {
+	b.err = err
+	close(b.chunks)
+}
+
+func (b *httpBodyStream) Read(p []byte) (int, error) {
+	if len(b.current) == 0 {
+		select {
+		case chunk, ok := <-b.chunks:
+			if !ok {
+				if b.err != nil {
+					return 0, b.err
+				}
+				return 0, io.EOF
+			}
+			b.current = chunk
+		case <-b.closed:
+			return 0, io.ErrClosedPipe
+		}
+	}
+
+	n := copy(p, b.current)
+	b.current = b.current[n:]
+
+	return n, nil
+}
+
+// Close - Stops reading the body, any remaining chunks are discarded
+func (b *httpBodyStream) Close() error {
+	b.closeOnce.Do(func() {
+		close(b.closed)
+	})
+	return nil
+}
@@ -0,0 +1,206 @@
+// Copyright 2021 Nitric Pty Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package worker
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/nitrictech/nitric/pkg/triggers"
+	"github.com/valyala/fasthttp"
+)
+
+// The default minimum response body size compressed, smaller bodies rarely benefit from compression
+const DefaultCompressionMinBytes = 1024
+
+// Content types that are already compressed, compressing them again only costs CPU
+var compressedContentTypes = []string{
+	"image/",
+	"video/",
+	"audio/",
+	"font/woff",
+	"application/gzip",
+	"application/x-gzip",
+	"application/zip",
+	"application/x-bzip2",
+	"application/x-7z-compressed",
+	"application/x-rar-compressed",
+	"application/zstd",
+	"application/pdf",
+}
+
+// Image formats that are text, and so benefit from compression
+var compressibleImageTypes = []string{
+	"image/svg+xml",
+	"image/bmp",
+}
+
+// compressionWorker - Compresses HTTP response bodies for clients that accept compressed responses
+type compressionWorker struct {
+	Worker
+	minBytes int
+}
+
+// isCompressedContentType - Returns true if the content type is already compressed
+func isCompressedContentType(contentType string) bool {
+	contentType = strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0]))
+
+	for _, t := range compressibleImageTypes {
+		if contentType == t {
+			return false
+		}
+	}
+
+	for _, t := range compressedContentTypes {
+		if strings.HasPrefix(contentType, t) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// acceptedEncoding - Returns the preferred supported encoding in the Accept-Encoding headers, empty if none are accepted
+func acceptedEncoding(header map[string][]string) string {
+	qualities := make(map[string]float64)
+
+	for key, values := range header {
+		if !strings.EqualFold(key, "Accept-Encoding") {
+			continue
+		}
+
+		for _, value := range values {
+			for _, part := range strings.Split(value, ",") {
+				params := strings.Split(part, ";")
+				coding := strings.ToLower(strings.TrimSpace(params[0]))
+				if coding == "" {
+					continue
+				}
+
+				quality := 1.0
+				for _, p := range params[1:] {
+					p = strings.TrimSpace(p)
+					if strings.HasPrefix(p, "q=") {
+						q, err := strconv.ParseFloat(p[2:], 64)
+						if err != nil {
+							// Ignore malformed codings rather than rejecting the request
+							quality = 0
+						} else {
+							quality = q
+						}
+					}
+				}
+
+				qualities[coding] = quality
+			}
+		}
+	}
+
+	best := ""
+	bestQuality := 0.0
+	// gzip is preferred when both are equally acceptable
+	for _, coding := range []string{"gzip", "deflate"} {
+		quality, ok := qualities[coding]
+		if !ok {
+			quality, ok = qualities["*"]
+		}
+
+		if ok && quality > bestQuality {
+			best = coding
+			bestQuality = quality
+		}
+	}
+
+	return best
+}
+
+// compress - Compresses the body with the given encoding
+func compress(encoding string, body []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	var writer io.WriteCloser
+
+	switch encoding {
+	case "gzip":
+		writer = gzip.NewWriter(&buf)
+	default:
+		// The HTTP deflate coding is the zlib format, see RFC 7230 section 4.2.2
+		writer = zlib.NewWriter(&buf)
+	}
+
+	if _, err := writer.Write(body); err != nil {
+		return nil, err
+	}
+
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// HandleHttpRequest - Compresses the response body when the request accepts gzip or deflate encoding
+// streamed responses are returned uncompressed, as their size isn't known before they're sent
+func (w *compressionWorker) HandleHttpRequest(trigger *triggers.HttpRequest) (*triggers.HttpResponse, error) {
+	response, err := w.Worker.HandleHttpRequest(trigger)
+
+	if err != nil || response == nil || response.IsStreamed() {
+		return response, err
+	}
+
+	if len(response.Body) < w.minBytes || response.StatusCode == 204 || response.StatusCode == 304 {
+		return response, nil
+	}
+
+	encoding := acceptedEncoding(trigger.Header)
+	if encoding == "" {
+		return response, nil
+	}
+
+	if response.Header == nil {
+		response.Header = &fasthttp.ResponseHeader{}
+	}
+
+	if len(response.Header.Peek("Content-Encoding")) > 0 || isCompressedContentType(string(response.Header.ContentType())) {
+		return response, nil
+	}
+
+	compressed, err := compress(encoding, response.Body)
+	if err != nil {
+		// Fall back to the uncompressed response
+		return response, nil
+	}
+
+	response.Body = compressed
+	response.Header.Set("Content-Encoding", encoding)
+	response.Header.Add("Vary", "Accept-Encoding")
+	response.Header.SetContentLength(len(compressed))
+
+	return response, nil
+}
+
+// WithCompression - Compresses HTTP response bodies of at least minBytes with gzip or deflate,
+// when accepted by the client and the content isn't already compressed
+func WithCompression(minBytes int) WorkerDecorator {
+	return func(wrkr Worker) Worker {
+		return &compressionWorker{
+			Worker:   wrkr,
+			minBytes: minBytes,
+		}
+	}
+}
@@ -0,0 +1,108 @@
+// Copyright 2021 Nitric Pty Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package worker
+
+import (
+	"net/http"
+
+	"github.com/nitrictech/nitric/pkg/triggers"
+	"github.com/valyala/fasthttp"
+)
+
+// The content type of HTTP responses returned without one by default, matching the type fasthttp sends them as
+const DefaultResponseContentType = "text/plain; charset=utf-8"
+
+// The content type sniffing returns when it doesn't recognize the content
+const unknownContentType = "application/octet-stream"
+
+// The number of bytes considered when sniffing the content type, see https://mimesniff.spec.whatwg.org/
+const sniffLen = 512
+
+// contentTypeWorker - Sets the content type of HTTP responses returned without one
+type contentTypeWorker struct {
+	Worker
+	defaultContentType string
+	sniff              bool
+}
+
+// hasContentType - Returns true if the header sets a content type, fasthttp reports a default one otherwise
+func hasContentType(header *fasthttp.ResponseHeader) bool {
+	header.SetNoDefaultContentType(true)
+	defer header.SetNoDefaultContentType(false)
+
+	return len(header.ContentType()) > 0
+}
+
+// sniffContentType - Returns the content type detected from the first bytes of the body,
+// or an empty string if the content isn't recognized
+func sniffContentType(body []byte) string {
+	if len(body) == 0 {
+		return ""
+	}
+
+	if len(body) > sniffLen {
+		body = body[:sniffLen]
+	}
+
+	contentType := http.DetectContentType(body)
+	if contentType == unknownContentType {
+		return ""
+	}
+
+	return contentType
+}
+
+// HandleHttpRequest - Sets the content type of responses without one, sniffing it from the body when enabled.
+// Streamed responses aren't sniffed, as their body isn't read before it's sent
+func (w *contentTypeWorker) HandleHttpRequest(trigger *triggers.HttpRequest) (*triggers.HttpResponse, error) {
+	response, err := w.Worker.HandleHttpRequest(trigger)
+
+	if err != nil || response == nil || response.StatusCode == 204 || response.StatusCode == 304 {
+		return response, err
+	}
+
+	if response.Header == nil {
+		response.Header = &fasthttp.ResponseHeader{}
+	}
+
+	if hasContentType(response.Header) {
+		return response, nil
+	}
+
+	contentType := ""
+	if w.sniff && !response.IsStreamed() {
+		contentType = sniffContentType(response.Body)
+	}
+
+	if contentType == "" {
+		contentType = w.defaultContentType
+	}
+
+	response.Header.SetContentType(contentType)
+
+	return response, nil
+}
+
+// WithDefaultContentType - Sets the content type of HTTP responses returned without one to the default content type,
+// or to the type sniffed from the first bytes of the body when sniff is enabled and the content is recognized
+func WithDefaultContentType(defaultContentType string, sniff bool) WorkerDecorator {
+	return func(wrkr Worker) Worker {
+		return &contentTypeWorker{
+			Worker:             wrkr,
+			defaultContentType: defaultContentType,
+			sniff:              sniff,
+		}
+	}
+}
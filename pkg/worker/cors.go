@@ -0,0 +1,290 @@
+// Copyright 2021 Nitric Pty Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package worker
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/nitrictech/nitric/pkg/triggers"
+	"github.com/valyala/fasthttp"
+)
+
+// The methods allowed by default when none are configured
+var defaultCorsMethods = []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE"}
+
+// Request headers that are always allowed in cross-origin requests
+var corsSafelistedHeaders = []string{"Accept", "Accept-Language", "Content-Language", "Content-Type"}
+
+// CorsOptions - Cross-Origin Resource Sharing configuration for HTTP triggers
+type CorsOptions struct {
+	// Origins allowed to make cross-origin requests, "*" allows any origin.
+	// Origins may contain * wildcards e.g. https://*.example.com, origins starting with ^ are regular expressions
+	AllowedOrigins []string
+	// Methods allowed in cross-origin requests, defaults to GET, HEAD, POST, PUT, PATCH and DELETE
+	AllowedMethods []string
+	// Request headers allowed in cross-origin requests in addition to Accept, Accept-Language, Content-Language
+	// and Content-Type, "*" allows any header
+	AllowedHeaders []string
+	// Response headers exposed to the client
+	ExposedHeaders []string
+	// Allows cross-origin requests to include credentials such as cookies, can't be combined with the "*" origin
+	AllowCredentials bool
+	// The time in seconds clients may cache preflight responses, the header is omitted if 0
+	MaxAgeSeconds int
+}
+
+// CorsPolicy - Compiled CORS options, used to check and respond to cross-origin requests
+type CorsPolicy struct {
+	allowAnyOrigin   bool
+	origins          map[string]bool
+	originPatterns   []*regexp.Regexp
+	methods          map[string]bool
+	allowedMethods   string
+	allowAnyHeader   bool
+	headers          map[string]bool
+	exposedHeaders   string
+	allowCredentials bool
+	maxAge           string
+}
+
+// originPattern - Compiles an allowed origin into a regular expression
+func originPattern(origin string) (*regexp.Regexp, error) {
+	if strings.HasPrefix(origin, "^") {
+		return regexp.Compile(origin)
+	}
+
+	parts := strings.Split(origin, "*")
+	for i, p := range parts {
+		parts[i] = regexp.QuoteMeta(p)
+	}
+
+	// Origins are case-insensitive, and wildcards don't match across the scheme separator
+	return regexp.Compile("(?i)^" + strings.Join(parts, "[^/]*") + "$")
+}
+
+// isOriginAllowed - Returns true if the origin is allowed to make cross-origin requests
+func (p *CorsPolicy) isOriginAllowed(origin string) bool {
+	if p.allowAnyOrigin || p.origins[strings.ToLower(origin)] {
+		return true
+	}
+
+	for _, pattern := range p.originPatterns {
+		if pattern.MatchString(origin) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// areHeadersAllowed - Returns true if all of the comma separated headers are allowed
+func (p *CorsPolicy) areHeadersAllowed(requested string) bool {
+	if p.allowAnyHeader {
+		return true
+	}
+
+	for _, h := range strings.Split(requested, ",") {
+		if h = strings.TrimSpace(h); h != "" && !p.headers[http.CanonicalHeaderKey(h)] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// setAllowOrigin - Sets the headers allowing the origin to read the response
+func (p *CorsPolicy) setAllowOrigin(header *fasthttp.ResponseHeader, origin string) {
+	if p.allowAnyOrigin {
+		header.Set("Access-Control-Allow-Origin", "*")
+	} else {
+		header.Set("Access-Control-Allow-Origin", origin)
+		header.Add("Vary", "Origin")
+	}
+
+	if p.allowCredentials {
+		header.Set("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+// preflight - Responds to a preflight request, the CORS headers are omitted if the request isn't allowed
+func (p *CorsPolicy) preflight(origin string, trigger *triggers.HttpRequest) *triggers.HttpResponse {
+	header := &fasthttp.ResponseHeader{}
+	header.Add("Vary", "Origin")
+	header.Add("Vary", "Access-Control-Request-Method")
+	header.Add("Vary", "Access-Control-Request-Headers")
+
+	response := &triggers.HttpResponse{
+		Header:     header,
+		StatusCode: 204,
+	}
+
+	method := strings.ToUpper(headerValue(trigger.Header, "Access-Control-Request-Method"))
+	requestedHeaders := strings.Join(headerValues(trigger.Header, "Access-Control-Request-Headers"), ",")
+
+	if !p.isOriginAllowed(origin) || !p.methods[method] || !p.areHeadersAllowed(requestedHeaders) {
+		return response
+	}
+
+	p.setAllowOrigin(header, origin)
+	header.Set("Access-Control-Allow-Methods", p.allowedMethods)
+
+	if requestedHeaders != "" {
+		// The requested headers have been checked, so they're echoed rather than listing every allowed header
+		header.Set("Access-Control-Allow-Headers", requestedHeaders)
+	}
+
+	if p.maxAge != "" {
+		header.Set("Access-Control-Max-Age", p.maxAge)
+	}
+
+	return response
+}
+
+// headerValues - Returns the values of a header, matching the header name case-insensitively
+func headerValues(header map[string][]string, key string) []string {
+	values := make([]string, 0)
+	for k, v := range header {
+		if strings.EqualFold(k, key) {
+			values = append(values, v...)
+		}
+	}
+
+	return values
+}
+
+// headerValue - Returns the first value of a header, empty if it isn't present
+func headerValue(header map[string][]string, key string) string {
+	if values := headerValues(header, key); len(values) > 0 {
+		return values[0]
+	}
+
+	return ""
+}
+
+// NewCorsPolicy - Validates and compiles CORS options
+func NewCorsPolicy(options *CorsOptions) (*CorsPolicy, error) {
+	policy := &CorsPolicy{
+		origins:          make(map[string]bool),
+		originPatterns:   make([]*regexp.Regexp, 0),
+		methods:          make(map[string]bool),
+		headers:          toHeaderSet(append(append([]string{}, corsSafelistedHeaders...), options.AllowedHeaders...)),
+		exposedHeaders:   strings.Join(options.ExposedHeaders, ", "),
+		allowCredentials: options.AllowCredentials,
+	}
+
+	if len(options.AllowedOrigins) == 0 {
+		return nil, fmt.Errorf("at least one allowed origin is required")
+	}
+
+	for _, origin := range options.AllowedOrigins {
+		switch {
+		case origin == "*":
+			policy.allowAnyOrigin = true
+		case strings.HasPrefix(origin, "^") || strings.Contains(origin, "*"):
+			pattern, err := originPattern(origin)
+			if err != nil {
+				return nil, fmt.Errorf("invalid allowed origin pattern %s: %v", origin, err)
+			}
+			policy.originPatterns = append(policy.originPatterns, pattern)
+		default:
+			policy.origins[strings.ToLower(origin)] = true
+		}
+	}
+
+	// Echoing any origin with credentials would let every site make credentialed requests
+	if policy.allowAnyOrigin && policy.allowCredentials {
+		return nil, fmt.Errorf("credentials can't be allowed for any origin, list the allowed origins instead of *")
+	}
+
+	methods := options.AllowedMethods
+	if len(methods) == 0 {
+		methods = defaultCorsMethods
+	}
+
+	allowedMethods := make([]string, 0, len(methods))
+	for _, m := range methods {
+		m = strings.ToUpper(strings.TrimSpace(m))
+		policy.methods[m] = true
+		allowedMethods = append(allowedMethods, m)
+	}
+	policy.allowedMethods = strings.Join(allowedMethods, ", ")
+
+	for _, h := range options.AllowedHeaders {
+		if strings.TrimSpace(h) == "*" {
+			policy.allowAnyHeader = true
+		}
+	}
+
+	if options.MaxAgeSeconds < 0 {
+		return nil, fmt.Errorf("max age must be non-negative, got %d", options.MaxAgeSeconds)
+	} else if options.MaxAgeSeconds > 0 {
+		policy.maxAge = strconv.Itoa(options.MaxAgeSeconds)
+	}
+
+	return policy, nil
+}
+
+// corsWorker - Responds to CORS preflight requests and adds CORS headers to responses
+type corsWorker struct {
+	Worker
+	policy *CorsPolicy
+}
+
+// HandleHttpRequest - Responds to preflight requests without dispatching them to the worker,
+// other cross-origin requests are dispatched and the CORS headers are added to the response
+func (w *corsWorker) HandleHttpRequest(trigger *triggers.HttpRequest) (*triggers.HttpResponse, error) {
+	origin := headerValue(trigger.Header, "Origin")
+
+	// Requests without an origin aren't cross-origin requests
+	if origin == "" {
+		return w.Worker.HandleHttpRequest(trigger)
+	}
+
+	if strings.EqualFold(trigger.Method, "OPTIONS") && headerValue(trigger.Header, "Access-Control-Request-Method") != "" {
+		return w.policy.preflight(origin, trigger), nil
+	}
+
+	response, err := w.Worker.HandleHttpRequest(trigger)
+
+	if err != nil || response == nil || !w.policy.isOriginAllowed(origin) {
+		return response, err
+	}
+
+	if response.Header == nil {
+		response.Header = &fasthttp.ResponseHeader{}
+	}
+
+	w.policy.setAllowOrigin(response.Header, origin)
+
+	if w.policy.exposedHeaders != "" {
+		response.Header.Set("Access-Control-Expose-Headers", w.policy.exposedHeaders)
+	}
+
+	return response, nil
+}
+
+// WithCors - Handles CORS preflight requests and adds CORS headers to responses for allowed origins
+func WithCors(policy *CorsPolicy) WorkerDecorator {
+	return func(wrkr Worker) Worker {
+		return &corsWorker{
+			Worker: wrkr,
+			policy: policy,
+		}
+	}
+}
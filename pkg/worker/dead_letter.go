@@ -0,0 +1,118 @@
+// Copyright 2021 Nitric Pty Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package worker
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/nitrictech/nitric/pkg/logger"
+	"github.com/nitrictech/nitric/pkg/plugins/queue"
+	"github.com/nitrictech/nitric/pkg/triggers"
+)
+
+// DeadLetterPayloadType - The payload type of tasks sent to the dead-letter queue
+const DeadLetterPayloadType = "io.nitric.deadletter"
+
+// ReplayCountAttribute - The attribute of dead-lettered tasks counting the times their event has been replayed
+const ReplayCountAttribute = "replay-count"
+
+// deadLetterWorker - Retries failed events, sending events that continue to fail to a dead-letter queue
+type deadLetterWorker struct {
+	Worker
+	deadLetterPlugin queue.QueueService
+	deadLetterQueue  string
+	retries          int
+	log              logger.Logger
+}
+
+// newDeadLetterTask - Wraps a failed event with metadata describing the failure, so it can be inspected or replayed
+func newDeadLetterTask(trigger *triggers.Event, attempts int, handlerErr error) queue.NitricTask {
+	payload := map[string]interface{}{
+		"topic":       trigger.Topic,
+		"eventId":     trigger.ID,
+		"error":       handlerErr.Error(),
+		"attempts":    attempts,
+		"failedAt":    time.Now().UTC().Format(time.RFC3339),
+		"replayCount": trigger.ReplayCount,
+	}
+
+	// Preserve the original payload as-is where possible
+	var original interface{}
+	if err := json.Unmarshal(trigger.Payload, &original); err == nil {
+		payload["payload"] = original
+	} else {
+		payload["payloadBase64"] = base64.StdEncoding.EncodeToString(trigger.Payload)
+	}
+
+	return queue.NitricTask{
+		ID:          trigger.ID,
+		PayloadType: DeadLetterPayloadType,
+		Payload:     payload,
+		Attributes: map[string]string{
+			ReplayCountAttribute: strconv.Itoa(trigger.ReplayCount),
+		},
+	}
+}
+
+// HandleEvent - Retries the event, sending it to the dead-letter queue if every attempt fails.
+// Events that are successfully dead-lettered are treated as handled
+func (w *deadLetterWorker) HandleEvent(trigger *triggers.Event) error {
+	var err error
+	attempts := 0
+
+	for attempts <= w.retries {
+		attempts++
+		if err = w.Worker.HandleEvent(trigger); err == nil {
+			return nil
+		}
+	}
+
+	if w.deadLetterPlugin == nil {
+		return err
+	}
+
+	if dlqErr := w.deadLetterPlugin.Send(w.deadLetterQueue, newDeadLetterTask(trigger, attempts, err)); dlqErr != nil {
+		return fmt.Errorf("event failed after %d attempts: %v, and could not be sent to dead-letter queue %s: %v", attempts, err, w.deadLetterQueue, dlqErr)
+	}
+
+	w.log.Warn(
+		"event sent to dead-letter queue",
+		"eventId", trigger.ID,
+		"topic", trigger.Topic,
+		"attempts", attempts,
+		"queue", w.deadLetterQueue,
+		"error", err,
+	)
+
+	return nil
+}
+
+// WithDeadLetterQueue - Retries failed events, then sends them to the given queue.
+// A nil plugin retries failed events without dead-lettering them
+func WithDeadLetterQueue(deadLetterPlugin queue.QueueService, deadLetterQueue string, retries int, log logger.Logger) WorkerDecorator {
+	return func(wrkr Worker) Worker {
+		return &deadLetterWorker{
+			Worker:           wrkr,
+			deadLetterPlugin: deadLetterPlugin,
+			deadLetterQueue:  deadLetterQueue,
+			retries:          retries,
+			log:              log,
+		}
+	}
+}
@@ -0,0 +1,214 @@
+// Copyright 2021 Nitric Pty Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package worker
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/nitrictech/nitric/pkg/logger"
+	"github.com/nitrictech/nitric/pkg/plugins/queue"
+	"github.com/nitrictech/nitric/pkg/triggers"
+)
+
+const (
+	// replayBatchSize - The number of dead-lettered tasks received at a time
+	replayBatchSize = 10
+	// replayVisibilityTimeout - How long received tasks are hidden from other receivers while they're replayed
+	replayVisibilityTimeout = 60 * time.Second
+)
+
+// ErrDeadLetterNotFound - returned when replaying a dead-lettered event that isn't on the dead-letter queue
+var ErrDeadLetterNotFound = fmt.Errorf("event not found in the dead-letter queue")
+
+// ReplayResult - The outcome of replaying dead-lettered events
+type ReplayResult struct {
+	// Replayed - events dispatched and removed from the dead-letter queue, those that failed again are dead-lettered again
+	Replayed int `json:"replayed"`
+	// Skipped - events left on the dead-letter queue as they've been replayed the maximum number of times
+	Skipped int `json:"skipped"`
+	// Failed - events left on the dead-letter queue as they couldn't be replayed
+	Failed int `json:"failed"`
+}
+
+// DeadLetterReplayer - Replays dead-lettered events through the normal event dispatch path,
+// so events can be recovered once the cause of their failure has been fixed
+type DeadLetterReplayer struct {
+	pool             WorkerPool
+	deadLetterPlugin queue.QueueService
+	deadLetterQueue  string
+	maxReplays       int
+	log              logger.Logger
+}
+
+// eventFromDeadLetterTask - Restores the event a dead-letter task was created for, counting this replay
+func eventFromDeadLetterTask(task queue.NitricTask) (*triggers.Event, error) {
+	topic, _ := task.Payload["topic"].(string)
+	if topic == "" {
+		return nil, fmt.Errorf("dead-letter task %s has no topic", task.ID)
+	}
+
+	var payload []byte
+	if encoded, ok := task.Payload["payloadBase64"].(string); ok {
+		decoded, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("dead-letter task %s has an invalid payload: %v", task.ID, err)
+		}
+		payload = decoded
+	} else if original, ok := task.Payload["payload"]; ok {
+		encoded, err := json.Marshal(original)
+		if err != nil {
+			return nil, fmt.Errorf("dead-letter task %s has an invalid payload: %v", task.ID, err)
+		}
+		payload = encoded
+	}
+
+	eventId, _ := task.Payload["eventId"].(string)
+	if eventId == "" {
+		eventId = task.ID
+	}
+
+	return &triggers.Event{
+		ID:          eventId,
+		Topic:       topic,
+		Payload:     payload,
+		ReplayCount: replayCount(task) + 1,
+	}, nil
+}
+
+// replayCount - Returns the number of times the task's event has already been replayed
+func replayCount(task queue.NitricTask) int {
+	count, err := strconv.Atoi(task.Attributes[ReplayCountAttribute])
+	if err != nil {
+		return 0
+	}
+
+	return count
+}
+
+// receive - Receives the next batch of dead-lettered tasks
+func (r *DeadLetterReplayer) receive() ([]queue.NitricTask, error) {
+	depth := uint32(replayBatchSize)
+
+	return r.deadLetterPlugin.Receive(queue.ReceiveOptions{
+		QueueName:         r.deadLetterQueue,
+		Depth:             &depth,
+		VisibilityTimeout: replayVisibilityTimeout,
+	})
+}
+
+// replay - Dispatches the task's event, removing the task from the dead-letter queue once it's handled.
+// Events that fail again are dead-lettered again by the pool with their replay count incremented
+func (r *DeadLetterReplayer) replay(task queue.NitricTask, result *ReplayResult) {
+	if r.maxReplays > 0 && replayCount(task) >= r.maxReplays {
+		r.log.Warn("dead-lettered event has been replayed the maximum number of times", "eventId", task.ID, "replays", replayCount(task))
+		result.Skipped++
+		return
+	}
+
+	event, err := eventFromDeadLetterTask(task)
+	if err == nil {
+		var wrkr Worker
+		if wrkr, err = r.pool.GetWorker(); err == nil {
+			err = wrkr.HandleEvent(event)
+			ReleaseWorker(wrkr)
+		}
+	}
+
+	if err != nil {
+		r.log.Error("unable to replay dead-lettered event", "eventId", task.ID, "error", err)
+		result.Failed++
+		return
+	}
+
+	if err := r.deadLetterPlugin.Complete(r.deadLetterQueue, task.LeaseID); err != nil {
+		// The event was handled, but it will be replayed again once it's visible on the queue
+		r.log.Warn("replayed event could not be removed from the dead-letter queue", "eventId", task.ID, "error", err)
+	}
+
+	result.Replayed++
+}
+
+// Replay - Replays the dead-lettered event with the given ID.
+// Other events received while searching for it are hidden from receivers until their visibility timeout elapses
+func (r *DeadLetterReplayer) Replay(id string) (*ReplayResult, error) {
+	result := &ReplayResult{}
+
+	for {
+		tasks, err := r.receive()
+		if err != nil {
+			return nil, err
+		}
+
+		if len(tasks) == 0 {
+			return nil, ErrDeadLetterNotFound
+		}
+
+		for _, task := range tasks {
+			if task.ID == id {
+				r.replay(task, result)
+				return result, nil
+			}
+		}
+	}
+}
+
+// ReplayAll - Drains the dead-letter queue, replaying each event at most once.
+// Events that fail again are dead-lettered again and left for a later replay
+func (r *DeadLetterReplayer) ReplayAll() (*ReplayResult, error) {
+	result := &ReplayResult{}
+	seen := make(map[string]bool)
+
+	for {
+		tasks, err := r.receive()
+		if err != nil {
+			return result, err
+		}
+
+		if len(tasks) == 0 {
+			return result, nil
+		}
+
+		for _, task := range tasks {
+			// The event failed again during this drain and was dead-lettered again,
+			// or it was left on the queue and has become visible again
+			if seen[task.ID] {
+				continue
+			}
+
+			seen[task.ID] = true
+			r.replay(task, result)
+		}
+	}
+}
+
+// NewDeadLetterReplayer - Creates a replayer for the given dead-letter queue, dispatching events to the pool.
+// Events that have been replayed maxReplays times are left on the queue, 0 replays events without limit
+func NewDeadLetterReplayer(pool WorkerPool, deadLetterPlugin queue.QueueService, deadLetterQueue string, maxReplays int, log logger.Logger) *DeadLetterReplayer {
+	if log == nil {
+		log = logger.NewNoopLogger()
+	}
+
+	return &DeadLetterReplayer{
+		pool:             pool,
+		deadLetterPlugin: deadLetterPlugin,
+		deadLetterQueue:  deadLetterQueue,
+		maxReplays:       maxReplays,
+		log:              log,
+	}
+}
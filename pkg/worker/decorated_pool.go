@@ -0,0 +1,86 @@
+// Copyright 2021 Nitric Pty Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package worker
+
+import "context"
+
+// WorkerDecorator - Wraps a worker to add behaviour when triggers are dispatched to it
+type WorkerDecorator func(Worker) Worker
+
+// DecoratedPool - A worker pool that applies decorators to each worker retrieved from an underlying pool
+type DecoratedPool struct {
+	WorkerPool
+	decorators []WorkerDecorator
+}
+
+// GetWorker - Retrieves a worker from the underlying pool, wrapped with the pool decorators
+// decorators are applied in order, so the first decorator will be the first to handle a trigger
+func (p *DecoratedPool) GetWorker() (Worker, error) {
+	wrkr, err := p.WorkerPool.GetWorker()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return p.decorate(wrkr), nil
+}
+
+// GetWorkerWait - Waits for a worker from the underlying pool, wrapped with the pool decorators
+func (p *DecoratedPool) GetWorkerWait(ctx context.Context) (Worker, error) {
+	wrkr, err := p.WorkerPool.GetWorkerWait(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return p.decorate(wrkr), nil
+}
+
+// decoratedWorker - A worker wrapped with the pool decorators, decorators may handle a trigger without dispatching it
+// to the underlying worker so its reservation is released through here
+type decoratedWorker struct {
+	Worker
+	underlying Worker
+}
+
+// Release - Returns the underlying worker's reserved concurrency slot to its pool if no trigger used it
+func (w *decoratedWorker) Release() {
+	ReleaseWorker(w.underlying)
+}
+
+// decorate - Wraps the worker with the pool decorators
+func (p *DecoratedPool) decorate(wrkr Worker) Worker {
+	decorated := wrkr
+	for i := len(p.decorators) - 1; i >= 0; i-- {
+		decorated = p.decorators[i](decorated)
+	}
+
+	return &decoratedWorker{
+		Worker:     decorated,
+		underlying: wrkr,
+	}
+}
+
+// NewDecoratedPool - Creates a new pool, decorating the workers of the given pool
+func NewDecoratedPool(pool WorkerPool, decorators ...WorkerDecorator) WorkerPool {
+	if len(decorators) == 0 {
+		return pool
+	}
+
+	return &DecoratedPool{
+		WorkerPool: pool,
+		decorators: decorators,
+	}
+}
@@ -0,0 +1,63 @@
+// Copyright 2021 Nitric Pty Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package worker
+
+import "fmt"
+
+type PoolStartupErrorKind int
+
+const (
+	// PoolStartupError_PoolFull - the pool has reached its maximum number of workers
+	PoolStartupError_PoolFull PoolStartupErrorKind = iota
+	// PoolStartupError_WorkerRejected - the pool is not accepting workers, e.g. it is shutting down
+	PoolStartupError_WorkerRejected
+	// PoolStartupError_DuplicateWorker - the worker has already been added to the pool
+	PoolStartupError_DuplicateWorker
+)
+
+func (k PoolStartupErrorKind) String() string {
+	switch k {
+	case PoolStartupError_PoolFull:
+		return "pool full"
+	case PoolStartupError_WorkerRejected:
+		return "worker rejected"
+	case PoolStartupError_DuplicateWorker:
+		return "duplicate worker"
+	default:
+		return fmt.Sprintf("unknown startup error: %d", k)
+	}
+}
+
+// PoolStartupError - returned when a worker could not be added to a WorkerPool
+type PoolStartupError struct {
+	Kind PoolStartupErrorKind
+	Msg  string
+}
+
+func (e *PoolStartupError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Kind, e.Msg)
+}
+
+// Retryable - returns true if adding the worker may succeed when attempted again later
+func (e *PoolStartupError) Retryable() bool {
+	return e.Kind == PoolStartupError_PoolFull
+}
+
+func newPoolStartupError(kind PoolStartupErrorKind, msg string) error {
+	return &PoolStartupError{
+		Kind: kind,
+		Msg:  msg,
+	}
+}
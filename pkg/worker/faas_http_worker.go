@@ -29,6 +29,7 @@ import (
 
 // A Nitric HTTP worker
 type FaasHttpWorker struct {
+	UnimplementedWorker
 	address string
 }
 
@@ -96,6 +97,11 @@ func (h *FaasHttpWorker) HandleEvent(trigger *triggers.Event) error {
 
 // HandleHttpRequest - Handles an HTTP request by forwarding it as an HTTP request.
 func (h *FaasHttpWorker) HandleHttpRequest(trigger *triggers.HttpRequest) (*triggers.HttpResponse, error) {
+	// The trigger request is sent as a single message, so streamed bodies are read into memory
+	if err := trigger.BufferBody(); err != nil {
+		return nil, fmt.Errorf("error reading request body. Details: %v", err)
+	}
+
 	address := fmt.Sprintf("http://%s", h.address)
 	request := fasthttp.AcquireRequest()
 	response := fasthttp.AcquireResponse()
@@ -140,6 +146,8 @@ func (h *FaasHttpWorker) HandleHttpRequest(trigger *triggers.HttpRequest) (*trig
 				Headers:     headers,
 				Method:      trigger.Method,
 				QueryParams: query,
+				Route:       trigger.Route,
+				RouteParams: trigger.RouteParams,
 			},
 		},
 	}
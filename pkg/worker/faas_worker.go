@@ -15,27 +15,123 @@
 package worker
 
 import (
+	"bytes"
+	"context"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
 	"sync"
+	"time"
 
+	"github.com/nitrictech/nitric/pkg/logger"
+	"github.com/nitrictech/nitric/pkg/plugins/errors"
+	"github.com/nitrictech/nitric/pkg/plugins/errors/codes"
 	"github.com/nitrictech/nitric/pkg/triggers"
 
 	"github.com/google/uuid"
 	pb "github.com/nitrictech/nitric/interfaces/nitric/v1"
-	"github.com/valyala/fasthttp"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 // FaasWorker
 // Worker representation for a Nitric FaaS function using gRPC
 type FaasWorker struct {
+	UnimplementedWorker
+	id      string
+	log     logger.Logger
+	metrics *Metrics
 	// gRPC Stream for this worker
 	stream pb.FaasService_TriggerStreamServer
+	// Done once the stream has closed, cancelling triggers still waiting on a response
+	streamCtx   context.Context
+	closeStream context.CancelFunc
 	// Response channels for this worker
 	responseQueueLock sync.Mutex
 	responseQueue     map[string]chan *pb.TriggerResponse
+	// Streamed HTTP response bodies still being received
+	bodyStreamLock sync.Mutex
+	bodyStreams    map[string]*httpBodyStream
+	// Trigger data larger than this is split across multiple messages, 0 never splits it
+	chunkBytes int
+	// Serializes sends, as messages of concurrent triggers share the stream
+	sendLock sync.Mutex
+	// Chunked responses still being received, only accessed by Listen
+	chunkedResponses map[string]*chunkedResponse
+	// Readiness signalled by the function, guarded by readyLock
+	readyLock sync.Mutex
+	ready     bool
+	onReady   []func()
+	// Topics the function subscribes to from its init request, nil subscribes to all topics
+	topicsLock sync.RWMutex
+	topics     map[string]bool
+}
+
+// chunkedResponse - A trigger response waiting for the rest of its data
+type chunkedResponse struct {
+	response *pb.TriggerResponse
+	data     bytes.Buffer
+}
+
+// ID - Returns the unique ID of this worker
+func (s *FaasWorker) ID() string {
+	return s.id
+}
+
+// OnReady - Registers a callback made once the function signals it's ready to handle triggers,
+// immediately if it already has
+func (s *FaasWorker) OnReady(fn func()) {
+	s.readyLock.Lock()
+	if !s.ready {
+		s.onReady = append(s.onReady, fn)
+		s.readyLock.Unlock()
+		return
+	}
+	s.readyLock.Unlock()
+
+	fn()
+}
+
+// Subscribes - Returns true if the function handles events for the given topic
+func (s *FaasWorker) Subscribes(topic string) bool {
+	s.topicsLock.RLock()
+	defer s.topicsLock.RUnlock()
+
+	return s.topics == nil || s.topics[topic]
+}
+
+// setTopics - Records the topics the function subscribes to, an empty list subscribes to all topics
+func (s *FaasWorker) setTopics(topics []string) {
+	s.topicsLock.Lock()
+	defer s.topicsLock.Unlock()
+
+	if len(topics) == 0 {
+		s.topics = nil
+		return
+	}
+
+	s.topics = make(map[string]bool, len(topics))
+	for _, topic := range topics {
+		s.topics[topic] = true
+	}
+}
+
+// setReady - Records that the function is ready, making the registered callbacks once
+func (s *FaasWorker) setReady() {
+	s.readyLock.Lock()
+	if s.ready {
+		s.readyLock.Unlock()
+		return
+	}
+	s.ready = true
+	callbacks := s.onReady
+	s.onReady = nil
+	s.readyLock.Unlock()
+
+	s.log.Info("worker is ready to handle triggers", "workerId", s.id)
+	for _, fn := range callbacks {
+		fn()
+	}
 }
 
 // newTicket - Generates a request/response ID and response channel
@@ -45,7 +141,8 @@ func (s *FaasWorker) newTicket() (string, chan *pb.TriggerResponse) {
 	defer s.responseQueueLock.Unlock()
 
 	ID := uuid.New().String()
-	responseChan := make(chan *pb.TriggerResponse)
+	// Buffered so a late response for a cancelled ticket never blocks the listener
+	responseChan := make(chan *pb.TriggerResponse, 1)
 
 	s.responseQueue[ID] = responseChan
 
@@ -68,7 +165,282 @@ func (s *FaasWorker) resolveTicket(ID string) (chan *pb.TriggerResponse, error)
 	return s.responseQueue[ID], nil
 }
 
+// cancelTicket - Removes a ticket that is no longer being waited on
+func (s *FaasWorker) cancelTicket(ID string) {
+	s.responseQueueLock.Lock()
+	defer s.responseQueueLock.Unlock()
+
+	delete(s.responseQueue, ID)
+}
+
+// awaitResponse - Waits for the response to a ticket, cancelling the ticket if the context is done
+// or the stream closes first
+func (s *FaasWorker) awaitResponse(ctx context.Context, ID string, returnChan chan *pb.TriggerResponse) (*pb.TriggerResponse, error) {
+	select {
+	case response := <-returnChan:
+		return response, nil
+	case <-ctx.Done():
+		s.cancelTicket(ID)
+		return nil, ctx.Err()
+	case <-s.streamCtx.Done():
+		s.cancelTicket(ID)
+		newErr := errors.ErrorsWithScope("FaasWorker.awaitResponse", map[string]interface{}{
+			"workerId":  s.id,
+			"triggerId": ID,
+		})
+		return nil, newErr(codes.Unavailable, "worker stream closed before the trigger was handled", nil)
+	}
+}
+
+// openBodyStream - Registers a new streamed response body for the given ID
+func (s *FaasWorker) openBodyStream(ID string) *httpBodyStream {
+	s.bodyStreamLock.Lock()
+	defer s.bodyStreamLock.Unlock()
+
+	stream := newHttpBodyStream()
+	s.bodyStreams[ID] = stream
+
+	return stream
+}
+
+// getBodyStream - Retrieves the streamed response body for the given ID
+func (s *FaasWorker) getBodyStream(ID string) *httpBodyStream {
+	s.bodyStreamLock.Lock()
+	defer s.bodyStreamLock.Unlock()
+
+	return s.bodyStreams[ID]
+}
+
+// closeBodyStream - Ends the streamed response body for the given ID and removes it
+func (s *FaasWorker) closeBodyStream(ID string, err error) {
+	s.bodyStreamLock.Lock()
+	defer s.bodyStreamLock.Unlock()
+
+	if stream, ok := s.bodyStreams[ID]; ok {
+		stream.end(err)
+		delete(s.bodyStreams, ID)
+	}
+}
+
+// abortBodyStreams - Ends all open streamed response bodies with the given error
+func (s *FaasWorker) abortBodyStreams(err error) {
+	s.bodyStreamLock.Lock()
+	defer s.bodyStreamLock.Unlock()
+
+	for ID, stream := range s.bodyStreams {
+		stream.end(err)
+		delete(s.bodyStreams, ID)
+	}
+}
+
+// handleResponseChunk - Writes a chunk of a streamed response body to its reader
+func (s *FaasWorker) handleResponseChunk(ID string, chunk *pb.HttpResponseChunk) {
+	stream := s.getBodyStream(ID)
+
+	if stream == nil {
+		s.log.Warn("discarding response chunk for unknown stream", "workerId", s.id, "triggerId", ID)
+		return
+	}
+
+	stream.write(chunk.GetData())
+
+	if chunk.GetDone() {
+		s.closeBodyStream(ID, nil)
+	}
+}
+
+// send - Sends a message to the function
+func (s *FaasWorker) send(message *pb.ServerMessage) error {
+	s.sendLock.Lock()
+	defer s.sendLock.Unlock()
+
+	return s.stream.Send(message)
+}
+
+// sendTriggerRequest - Sends a trigger request to the function, splitting data larger
+// than the chunk size across trigger request chunk messages with the same ID
+func (s *FaasWorker) sendTriggerRequest(ID string, triggerRequest *pb.TriggerRequest) error {
+	data := triggerRequest.Data
+	chunked := s.chunkBytes > 0 && len(data) > s.chunkBytes
+
+	if chunked {
+		triggerRequest.Data = data[:s.chunkBytes]
+		triggerRequest.Chunked = true
+		data = data[s.chunkBytes:]
+	}
+
+	err := s.send(&pb.ServerMessage{
+		Id: ID,
+		Content: &pb.ServerMessage_TriggerRequest{
+			TriggerRequest: triggerRequest,
+		},
+	})
+
+	for chunked && err == nil {
+		size := len(data)
+		if size > s.chunkBytes {
+			size = s.chunkBytes
+		}
+
+		err = s.send(&pb.ServerMessage{
+			Id: ID,
+			Content: &pb.ServerMessage_TriggerRequestChunk{
+				TriggerRequestChunk: &pb.DataChunk{
+					Data: data[:size],
+					Done: size == len(data),
+				},
+			},
+		})
+
+		data = data[size:]
+		chunked = len(data) > 0
+	}
+
+	return err
+}
+
+// readChunk - Reads up to size bytes from body, returning io.EOF once the body has been read in full
+func readChunk(body io.Reader, size int) ([]byte, error) {
+	chunk := make([]byte, size)
+	n, err := io.ReadFull(body, chunk)
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	}
+
+	return chunk[:n], err
+}
+
+// sendTriggerRequestStream - Sends a trigger request to the function with its data read from body
+// a chunk at a time, so the body is never held in memory in full
+func (s *FaasWorker) sendTriggerRequestStream(ID string, triggerRequest *pb.TriggerRequest, body io.Reader) error {
+	chunk, err := readChunk(body, s.chunkBytes)
+	if err != nil && err != io.EOF {
+		return err
+	}
+
+	triggerRequest.Data = chunk
+	triggerRequest.Chunked = err != io.EOF
+
+	err = s.send(&pb.ServerMessage{
+		Id: ID,
+		Content: &pb.ServerMessage_TriggerRequest{
+			TriggerRequest: triggerRequest,
+		},
+	})
+
+	for chunked := triggerRequest.Chunked; chunked && err == nil; {
+		chunk, err = readChunk(body, s.chunkBytes)
+		if err != nil && err != io.EOF {
+			return err
+		}
+		chunked = err != io.EOF
+
+		err = s.send(&pb.ServerMessage{
+			Id: ID,
+			Content: &pb.ServerMessage_TriggerRequestChunk{
+				TriggerRequestChunk: &pb.DataChunk{
+					Data: chunk,
+					Done: !chunked,
+				},
+			},
+		})
+	}
+
+	return err
+}
+
+// handleTriggerResponseChunk - Adds a chunk to a chunked response, returning the response once all of its data is received
+func (s *FaasWorker) handleTriggerResponseChunk(ID string, chunk *pb.DataChunk) *pb.TriggerResponse {
+	pending, ok := s.chunkedResponses[ID]
+	if !ok {
+		s.log.Warn("discarding response chunk for unknown response", "workerId", s.id, "triggerId", ID)
+		return nil
+	}
+
+	pending.data.Write(chunk.GetData())
+
+	if !chunk.GetDone() {
+		return nil
+	}
+
+	delete(s.chunkedResponses, ID)
+	pending.response.Data = pending.data.Bytes()
+	pending.response.Chunked = false
+
+	return pending.response
+}
+
+// toPbFormParts - Converts parsed multipart form parts to their gRPC representation
+func toPbFormParts(parts []*triggers.FormPart) []*pb.FormPart {
+	pbParts := make([]*pb.FormPart, 0, len(parts))
+	for _, part := range parts {
+		headers := make(map[string]*pb.HeaderValue)
+		for k, v := range part.Header {
+			headers[k] = &pb.HeaderValue{
+				Value: v,
+			}
+		}
+
+		pbParts = append(pbParts, &pb.FormPart{
+			Name:     part.Name,
+			Filename: part.Filename,
+			Headers:  headers,
+			Content:  part.Content,
+		})
+	}
+
+	return pbParts
+}
+
+// toPbPrincipal - Converts the principal of an authorized request, nil if the request wasn't authorized
+func toPbPrincipal(principal *triggers.Principal) *pb.Principal {
+	if principal == nil {
+		return nil
+	}
+
+	// Claims are decoded from JSON, so they're always representable as a struct
+	claims, _ := structpb.NewStruct(principal.Claims)
+
+	return &pb.Principal{
+		Subject: principal.Subject,
+		Claims:  claims,
+	}
+}
+
+// triggerDeadline - The earlier of the trigger's deadline and the context's, nil if neither has one
+func triggerDeadline(ctx context.Context, deadline time.Time) *timestamppb.Timestamp {
+	if d, ok := ctx.Deadline(); ok && (deadline.IsZero() || d.Before(deadline)) {
+		deadline = d
+	}
+
+	if deadline.IsZero() {
+		return nil
+	}
+
+	return timestamppb.New(deadline)
+}
+
 func (s *FaasWorker) HandleHttpRequest(trigger *triggers.HttpRequest) (*triggers.HttpResponse, error) {
+	return s.handleHttpRequestWithContext(context.Background(), trigger)
+}
+
+func (s *FaasWorker) handleHttpRequestWithContext(ctx context.Context, trigger *triggers.HttpRequest) (*triggers.HttpResponse, error) {
+	start := time.Now()
+	response, err := s.dispatchHttpRequest(ctx, trigger)
+	s.metrics.observe(triggers.TriggerType_Request, start, err)
+
+	return response, err
+}
+
+// dispatchHttpRequest - Sends a HTTP request to the function over the stream and waits for its response
+func (s *FaasWorker) dispatchHttpRequest(ctx context.Context, trigger *triggers.HttpRequest) (*triggers.HttpResponse, error) {
+	// Streamed bodies are sent in chunks, so they're read into memory when chunking is disabled
+	if s.chunkBytes <= 0 {
+		if err := trigger.BufferBody(); err != nil {
+			return nil, fmt.Errorf("error reading request body: %v", err)
+		}
+	}
+
 	// Generate an ID here
 	ID, returnChan := s.newTicket()
 
@@ -77,7 +449,9 @@ func (s *FaasWorker) HandleHttpRequest(trigger *triggers.HttpRequest) (*triggers
 		mimeType = trigger.Header["Content-Type"][0]
 	}
 
-	if mimeType == "" {
+	if mimeType == "" && trigger.IsStreamed() {
+		mimeType = "application/octet-stream"
+	} else if mimeType == "" {
 		mimeType = http.DetectContentType(trigger.Body)
 	}
 
@@ -107,9 +481,22 @@ func (s *FaasWorker) HandleHttpRequest(trigger *triggers.HttpRequest) (*triggers
 		}
 	}
 
+	// Deliver multipart forms structured as well as raw, so functions don't need to parse them
+	var formParts []*pb.FormPart
+	if trigger.IsMultipart() {
+		parts, err := trigger.MultipartForm()
+		if err == nil {
+			formParts = toPbFormParts(parts)
+		} else {
+			s.log.Debug("unable to parse multipart request, delivering raw body only", "workerId", s.id, "error", err)
+		}
+	}
+
 	triggerRequest := &pb.TriggerRequest{
-		Data:     trigger.Body,
-		MimeType: mimeType,
+		Data:      trigger.Body,
+		MimeType:  mimeType,
+		RequestId: requestIdFromHeader(trigger.Header),
+		Deadline:  triggerDeadline(ctx, trigger.Deadline),
 		Context: &pb.TriggerRequest_Http{
 			Http: &pb.HttpTriggerContext{
 				Path:           trigger.Path,
@@ -118,28 +505,39 @@ func (s *FaasWorker) HandleHttpRequest(trigger *triggers.HttpRequest) (*triggers
 				QueryParamsOld: queryOld,
 				Headers:        headers,
 				HeadersOld:     headersOld,
+				FormParts:      formParts,
+				Route:          trigger.Route,
+				RouteParams:    trigger.RouteParams,
+				ClientIp:       trigger.ClientIP,
+				Principal:      toPbPrincipal(trigger.Principal),
 			},
 		},
 	}
 
-	// construct the message
-	message := &pb.ServerMessage{
-		Id: ID,
-		Content: &pb.ServerMessage_TriggerRequest{
-			TriggerRequest: triggerRequest,
-		},
-	}
-
 	// send the message
-	err := s.stream.Send(message)
+	var err error
+	if trigger.IsStreamed() {
+		err = s.sendTriggerRequestStream(ID, triggerRequest, trigger.BodyStream)
+	} else {
+		err = s.sendTriggerRequest(ID, triggerRequest)
+	}
 
 	if err != nil {
 		// There was an error enqueuing the message
+		s.cancelTicket(ID)
 		return nil, err
 	}
 
 	// wait for the response
-	triggerResponse := <-returnChan
+	triggerResponse, err := s.awaitResponse(ctx, ID, returnChan)
+
+	if err != nil {
+		// A streamed body may have opened as the trigger was abandoned, discard anything written to it
+		if stream := s.getBodyStream(ID); stream != nil {
+			stream.Close()
+		}
+		return nil, err
+	}
 
 	httpResponse := triggerResponse.GetHttp()
 
@@ -147,36 +545,49 @@ func (s *FaasWorker) HandleHttpRequest(trigger *triggers.HttpRequest) (*triggers
 		return nil, fmt.Errorf("fatal: Error handling event, incorrect response received from function")
 	}
 
-	fasthttpHeader := &fasthttp.ResponseHeader{}
-
-	for key, val := range httpResponse.GetHeaders() {
-		headerList := val.Value
-		if key == "Set-Cookie" || key == "Cookie" {
-			for _, v := range headerList {
-				fasthttpHeader.Add(key, v)
-			}
-		} else if len(headerList) > 0 {
-			fasthttpHeader.Set(key, headerList[0])
-		}
-	}
-
 	response := &triggers.HttpResponse{
 		Body: triggerResponse.Data,
 		// No need to worry about integer truncation
 		// as this should be a HTTP status code...
 		StatusCode: int(httpResponse.Status),
-		Header:     fasthttpHeader,
+		Header:     triggers.HeaderFromResponseContext(httpResponse),
+	}
+
+	if httpResponse.GetStreamed() {
+		stream := s.getBodyStream(ID)
+		if stream == nil {
+			return nil, fmt.Errorf("fatal: streamed response body was not found")
+		}
+
+		// The first chunk of the body is already on the stream
+		response.Body = nil
+		response.BodyStream = stream
 	}
 
 	return response, nil
 }
 
 func (s *FaasWorker) HandleEvent(trigger *triggers.Event) error {
+	return s.handleEventWithContext(context.Background(), trigger)
+}
+
+func (s *FaasWorker) handleEventWithContext(ctx context.Context, trigger *triggers.Event) error {
+	start := time.Now()
+	err := s.dispatchEvent(ctx, trigger)
+	s.metrics.observe(triggers.TriggerType_Subscription, start, err)
+
+	return err
+}
+
+// dispatchEvent - Sends an event to the function over the stream and waits for it to be handled
+func (s *FaasWorker) dispatchEvent(ctx context.Context, trigger *triggers.Event) error {
 	// Generate an ID here
 	ID, returnChan := s.newTicket()
 	triggerRequest := &pb.TriggerRequest{
-		Data:     trigger.Payload,
-		MimeType: http.DetectContentType(trigger.Payload),
+		Data:      trigger.Payload,
+		MimeType:  http.DetectContentType(trigger.Payload),
+		RequestId: trigger.ID,
+		Deadline:  triggerDeadline(ctx, time.Time{}),
 		Context: &pb.TriggerRequest_Topic{
 			Topic: &pb.TopicTriggerContext{
 				Topic: trigger.Topic,
@@ -185,25 +596,21 @@ func (s *FaasWorker) HandleEvent(trigger *triggers.Event) error {
 		},
 	}
 
-	// construct the message
-	message := &pb.ServerMessage{
-		Id: ID,
-		Content: &pb.ServerMessage_TriggerRequest{
-			TriggerRequest: triggerRequest,
-		},
-	}
-
 	// send the message
-	err := s.stream.Send(message)
+	err := s.sendTriggerRequest(ID, triggerRequest)
 
 	if err != nil {
 		// There was an error enqueuing the message
+		s.cancelTicket(ID)
 		return err
 	}
 
 	// wait for the response
-	// FIXME: Need to handle timeouts here...
-	response := <-returnChan
+	response, err := s.awaitResponse(ctx, ID, returnChan)
+
+	if err != nil {
+		return err
+	}
 
 	topic := response.GetTopic()
 
@@ -229,18 +636,33 @@ func (s *FaasWorker) Listen(errchan chan error) {
 		if err != nil {
 			if err == io.EOF {
 				// return will close stream from server side
-				log.Println("exit")
-			}
-			if err != nil {
-				log.Printf("received error %v", err)
+				s.log.Info("FaaS stream ended by worker", "workerId", s.id)
+			} else {
+				s.log.Error("error receiving from FaaS stream", "workerId", s.id, "error", err)
 			}
 
+			// Streamed bodies, chunked responses and in-flight triggers can't be completed once the stream has ended
+			s.abortBodyStreams(io.ErrUnexpectedEOF)
+			s.chunkedResponses = make(map[string]*chunkedResponse)
+			s.closeStream()
+
 			errchan <- err
 			break
 		}
 
-		if msg.GetInitRequest() != nil {
-			fmt.Println("Received init request from worker")
+		if msg.GetReadyRequest() != nil {
+			s.setReady()
+			continue
+		}
+
+		if init := msg.GetInitRequest(); init != nil {
+			s.log.Info("received init request from worker", "workerId", s.id, "topics", init.GetTopics())
+			// Subscriptions are recorded before the worker can be selected to handle events
+			s.setTopics(init.GetTopics())
+			// Functions that are still warming up signal they are ready separately
+			if !init.GetWaitForReady() {
+				s.setReady()
+			}
 			// FIXME: This appears to not work with the PHP runtime?
 			//s.stream.Send(&pb.ServerMessage{
 			//	Content: &pb.ServerMessage_InitResponse{
@@ -250,26 +672,58 @@ func (s *FaasWorker) Listen(errchan chan error) {
 			continue
 		}
 
+		if chunk := msg.GetHttpResponseChunk(); chunk != nil {
+			s.handleResponseChunk(msg.GetId(), chunk)
+			continue
+		}
+
+		// For now assume this is a trigger response...
+		response := msg.GetTriggerResponse()
+
+		if chunk := msg.GetTriggerResponseChunk(); chunk != nil {
+			if response = s.handleTriggerResponseChunk(msg.GetId(), chunk); response == nil {
+				continue
+			}
+		} else if response.GetChunked() {
+			// Hold the response until the rest of its data is received
+			pending := &chunkedResponse{response: response}
+			pending.data.Write(response.GetData())
+			s.chunkedResponses[msg.GetId()] = pending
+			continue
+		}
+
 		// Load the response channel and delete its map key reference
 		if val, err := s.resolveTicket(msg.GetId()); err == nil {
-			// For now assume this is a trigger response...
-			response := msg.GetTriggerResponse()
+			// The body stream must exist before the response is received, so chunks that follow it aren't lost
+			if response.GetHttp().GetStreamed() {
+				s.openBodyStream(msg.GetId()).write(response.GetData())
+			}
+
 			// Write the response the the waiting recipient
 			val <- response
 		} else {
-			fmt.Println("Fatal: FaaS Worker in bad state closing stream: ", msg.GetId())
-			errchan <- fmt.Errorf("Fatal: FaaS Worker in bad state closing stream! %v", msg.GetId())
-			break
+			// The trigger may have timed out and been cancelled before the function responded
+			s.log.Warn("discarding response for cancelled or unknown trigger", "workerId", s.id, "triggerId", msg.GetId())
 		}
 	}
 }
 
 // Package private method
-// Only a pool may create a new faas worker
-func NewFaasWorker(stream pb.FaasService_TriggerStreamServer) *FaasWorker {
+// Only a pool may create a new faas worker, trigger data larger than chunkBytes is split across messages
+func NewFaasWorker(stream pb.FaasService_TriggerStreamServer, log logger.Logger, metrics *Metrics, chunkBytes int) *FaasWorker {
+	streamCtx, closeStream := context.WithCancel(context.Background())
+
 	return &FaasWorker{
+		id:                uuid.New().String(),
+		log:               log,
+		metrics:           metrics,
 		stream:            stream,
+		streamCtx:         streamCtx,
+		closeStream:       closeStream,
 		responseQueueLock: sync.Mutex{},
 		responseQueue:     make(map[string]chan *pb.TriggerResponse),
+		bodyStreams:       make(map[string]*httpBodyStream),
+		chunkBytes:        chunkBytes,
+		chunkedResponses:  make(map[string]*chunkedResponse),
 	}
 }
@@ -0,0 +1,401 @@
+// Copyright 2021 Nitric Pty Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package worker
+
+import (
+	"bytes"
+	"crypto/rand"
+	"io"
+	"io/ioutil"
+	"time"
+
+	pb "github.com/nitrictech/nitric/interfaces/nitric/v1"
+	"github.com/nitrictech/nitric/pkg/logger"
+	"github.com/nitrictech/nitric/pkg/plugins/errors"
+	"github.com/nitrictech/nitric/pkg/plugins/errors/codes"
+	"github.com/nitrictech/nitric/pkg/triggers"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"google.golang.org/grpc"
+)
+
+// mockFaasStream - A FaaS stream connected to channels in place of a function
+type mockFaasStream struct {
+	grpc.ServerStream
+	sent     chan *pb.ServerMessage
+	received chan *pb.ClientMessage
+}
+
+func (m *mockFaasStream) Send(msg *pb.ServerMessage) error {
+	m.sent <- msg
+	return nil
+}
+
+func (m *mockFaasStream) Recv() (*pb.ClientMessage, error) {
+	msg, ok := <-m.received
+	if !ok {
+		return nil, io.EOF
+	}
+	return msg, nil
+}
+
+// echoChunkedFunction - Reassembles the data of the next trigger request, recording the size of each message's data,
+// and responds with the same data split into chunks of the given size
+func echoChunkedFunction(stream *mockFaasStream, chunkBytes int, frameSizes chan []int) {
+	var ID string
+	data := make([]byte, 0)
+	sizes := make([]int, 0)
+
+	for msg := range stream.sent {
+		if request := msg.GetTriggerRequest(); request != nil {
+			ID = msg.GetId()
+			data = append(data, request.GetData()...)
+			sizes = append(sizes, len(request.GetData()))
+			if !request.GetChunked() {
+				break
+			}
+			continue
+		}
+
+		chunk := msg.GetTriggerRequestChunk()
+		data = append(data, chunk.GetData()...)
+		sizes = append(sizes, len(chunk.GetData()))
+		if chunk.GetDone() {
+			break
+		}
+	}
+	frameSizes <- sizes
+
+	stream.received <- &pb.ClientMessage{
+		Id: ID,
+		Content: &pb.ClientMessage_TriggerResponse{
+			TriggerResponse: &pb.TriggerResponse{
+				Data:    data[:chunkBytes],
+				Chunked: true,
+				Context: &pb.TriggerResponse_Http{
+					Http: &pb.HttpResponseContext{
+						Status: 200,
+					},
+				},
+			},
+		},
+	}
+
+	for offset := chunkBytes; offset < len(data); offset += chunkBytes {
+		end := offset + chunkBytes
+		if end > len(data) {
+			end = len(data)
+		}
+
+		stream.received <- &pb.ClientMessage{
+			Id: ID,
+			Content: &pb.ClientMessage_TriggerResponseChunk{
+				TriggerResponseChunk: &pb.DataChunk{
+					Data: data[offset:end],
+					Done: end == len(data),
+				},
+			},
+		}
+	}
+}
+
+var _ = Describe("FaasWorker", func() {
+	Context("Chunking", func() {
+		When("A HTTP request body is larger than the chunk size", func() {
+			It("Should round trip the body across multiple messages", func() {
+				chunkBytes := 1024 * 1024
+				body := make([]byte, 10*1024*1024)
+				_, err := rand.Read(body)
+				Expect(err).ShouldNot(HaveOccurred())
+
+				stream := &mockFaasStream{
+					sent:     make(chan *pb.ServerMessage, 16),
+					received: make(chan *pb.ClientMessage, 16),
+				}
+				defer close(stream.received)
+
+				wrkr := NewFaasWorker(stream, logger.NewNoopLogger(), nil, chunkBytes)
+				go wrkr.Listen(make(chan error, 1))
+
+				frameSizes := make(chan []int, 1)
+				go echoChunkedFunction(stream, chunkBytes, frameSizes)
+
+				response, err := wrkr.HandleHttpRequest(&triggers.HttpRequest{
+					Method: "POST",
+					Path:   "/upload",
+					Body:   body,
+				})
+				Expect(err).ShouldNot(HaveOccurred())
+
+				By("Splitting the request into messages no larger than the chunk size")
+				sizes := <-frameSizes
+				Expect(sizes).To(HaveLen(10))
+				for _, size := range sizes {
+					Expect(size).To(BeNumerically("<=", chunkBytes))
+				}
+
+				By("Reassembling the chunked response")
+				Expect(response.StatusCode).To(Equal(200))
+				Expect(response.Body).To(Equal(body))
+			})
+		})
+
+		When("A HTTP request body is streamed", func() {
+			It("Should send the body in chunks read from the stream", func() {
+				chunkBytes := 1024 * 1024
+				body := make([]byte, 5*1024*1024+512)
+				_, err := rand.Read(body)
+				Expect(err).ShouldNot(HaveOccurred())
+
+				stream := &mockFaasStream{
+					sent:     make(chan *pb.ServerMessage, 16),
+					received: make(chan *pb.ClientMessage, 16),
+				}
+				defer close(stream.received)
+
+				wrkr := NewFaasWorker(stream, logger.NewNoopLogger(), nil, chunkBytes)
+				go wrkr.Listen(make(chan error, 1))
+
+				frameSizes := make(chan []int, 1)
+				go echoChunkedFunction(stream, chunkBytes, frameSizes)
+
+				response, err := wrkr.HandleHttpRequest(&triggers.HttpRequest{
+					Method:           "POST",
+					Path:             "/upload",
+					BodyStream:       ioutil.NopCloser(bytes.NewReader(body)),
+					BodyStreamLength: int64(len(body)),
+				})
+				Expect(err).ShouldNot(HaveOccurred())
+
+				By("Splitting the request into messages no larger than the chunk size")
+				Expect(<-frameSizes).To(Equal([]int{chunkBytes, chunkBytes, chunkBytes, chunkBytes, chunkBytes, 512}))
+
+				By("Delivering the whole body")
+				Expect(response.Body).To(Equal(body))
+			})
+		})
+
+		When("A HTTP request body is within the chunk size", func() {
+			It("Should send the body in a single message", func() {
+				stream := &mockFaasStream{
+					sent:     make(chan *pb.ServerMessage, 1),
+					received: make(chan *pb.ClientMessage, 1),
+				}
+
+				wrkr := NewFaasWorker(stream, logger.NewNoopLogger(), nil, 1024)
+				err := wrkr.sendTriggerRequest("test", &pb.TriggerRequest{Data: []byte("small")})
+				Expect(err).ShouldNot(HaveOccurred())
+
+				msg := <-stream.sent
+				Expect(msg.GetTriggerRequest().GetChunked()).To(BeFalse())
+				Expect(msg.GetTriggerRequest().GetData()).To(Equal([]byte("small")))
+				Expect(stream.sent).To(BeEmpty())
+			})
+		})
+	})
+
+	Context("HTTP trigger context", func() {
+		When("A HTTP request has query parameters", func() {
+			It("Should send them structured, including repeated keys", func() {
+				stream := &mockFaasStream{
+					sent:     make(chan *pb.ServerMessage, 1),
+					received: make(chan *pb.ClientMessage, 1),
+				}
+				defer close(stream.received)
+
+				wrkr := NewFaasWorker(stream, logger.NewNoopLogger(), nil, 1024)
+				go wrkr.Listen(make(chan error, 1))
+
+				sentContext := make(chan *pb.HttpTriggerContext, 1)
+				go func() {
+					msg := <-stream.sent
+					sentContext <- msg.GetTriggerRequest().GetHttp()
+
+					stream.received <- &pb.ClientMessage{
+						Id: msg.GetId(),
+						Content: &pb.ClientMessage_TriggerResponse{
+							TriggerResponse: &pb.TriggerResponse{
+								Context: &pb.TriggerResponse_Http{
+									Http: &pb.HttpResponseContext{
+										Status: 200,
+									},
+								},
+							},
+						},
+					}
+				}()
+
+				_, err := wrkr.HandleHttpRequest(&triggers.HttpRequest{
+					Method: "GET",
+					Path:   "/search",
+					Query: map[string][]string{
+						"tag":   {"a", "b"},
+						"na me": {"Jörg"},
+					},
+				})
+				Expect(err).ShouldNot(HaveOccurred())
+
+				httpContext := <-sentContext
+				Expect(httpContext.GetQueryParams()["tag"].GetValue()).To(Equal([]string{"a", "b"}))
+				Expect(httpContext.GetQueryParams()["na me"].GetValue()).To(Equal([]string{"Jörg"}))
+
+				By("Keeping the first value of each key in the deprecated params")
+				Expect(httpContext.GetQueryParamsOld()["tag"]).To(Equal("a"))
+			})
+		})
+
+		When("A HTTP request has a deadline", func() {
+			It("Should send the deadline with the trigger", func() {
+				stream := &mockFaasStream{
+					sent:     make(chan *pb.ServerMessage, 1),
+					received: make(chan *pb.ClientMessage, 1),
+				}
+				defer close(stream.received)
+
+				wrkr := NewFaasWorker(stream, logger.NewNoopLogger(), nil, 1024)
+				go wrkr.Listen(make(chan error, 1))
+
+				sentRequest := make(chan *pb.TriggerRequest, 1)
+				go func() {
+					msg := <-stream.sent
+					sentRequest <- msg.GetTriggerRequest()
+
+					stream.received <- &pb.ClientMessage{
+						Id: msg.GetId(),
+						Content: &pb.ClientMessage_TriggerResponse{
+							TriggerResponse: &pb.TriggerResponse{
+								Context: &pb.TriggerResponse_Http{
+									Http: &pb.HttpResponseContext{
+										Status: 200,
+									},
+								},
+							},
+						},
+					}
+				}()
+
+				deadline := time.Now().Add(time.Minute)
+				_, err := wrkr.HandleHttpRequest(&triggers.HttpRequest{
+					Method:   "GET",
+					Path:     "/",
+					Deadline: deadline,
+				})
+				Expect(err).ShouldNot(HaveOccurred())
+
+				request := <-sentRequest
+				Expect(request.GetDeadline()).ToNot(BeNil())
+				Expect(request.GetDeadline().AsTime()).To(BeTemporally("==", deadline))
+			})
+		})
+	})
+
+	Context("Stream closure", func() {
+		When("The stream closes while a trigger is in-flight", func() {
+			It("Should cancel the trigger with an unavailable error", func() {
+				stream := &mockFaasStream{
+					sent:     make(chan *pb.ServerMessage, 1),
+					received: make(chan *pb.ClientMessage),
+				}
+
+				wrkr := NewFaasWorker(stream, logger.NewNoopLogger(), nil, 0)
+				errchan := make(chan error, 1)
+				go wrkr.Listen(errchan)
+
+				result := make(chan error, 1)
+				go func() {
+					_, err := wrkr.HandleHttpRequest(&triggers.HttpRequest{
+						Method: "GET",
+						Path:   "/slow",
+					})
+					result <- err
+				}()
+
+				By("Closing the stream once the function has the request")
+				Eventually(stream.sent).Should(Receive())
+				close(stream.received)
+				Eventually(errchan).Should(Receive(Equal(io.EOF)))
+
+				var err error
+				Eventually(result).Should(Receive(&err))
+				Expect(err).Should(HaveOccurred())
+				Expect(errors.Code(err)).To(Equal(codes.Unavailable))
+			})
+		})
+	})
+
+	Context("Readiness", func() {
+		When("The function waits to signal it's ready", func() {
+			It("Should only be ready once the ready request is received", func() {
+				stream := &mockFaasStream{
+					sent:     make(chan *pb.ServerMessage, 1),
+					received: make(chan *pb.ClientMessage, 2),
+				}
+				defer close(stream.received)
+
+				wrkr := NewFaasWorker(stream, logger.NewNoopLogger(), nil, 1024)
+				ready := make(chan bool, 1)
+				wrkr.OnReady(func() {
+					ready <- true
+				})
+				go wrkr.Listen(make(chan error, 1))
+
+				stream.received <- &pb.ClientMessage{
+					Content: &pb.ClientMessage_InitRequest{
+						InitRequest: &pb.InitRequest{WaitForReady: true},
+					},
+				}
+				Consistently(ready, "50ms").ShouldNot(Receive())
+
+				stream.received <- &pb.ClientMessage{
+					Content: &pb.ClientMessage_ReadyRequest{
+						ReadyRequest: &pb.ReadyRequest{},
+					},
+				}
+				Eventually(ready).Should(Receive())
+
+				By("Calling callbacks registered afterwards immediately")
+				readyAgain := false
+				wrkr.OnReady(func() {
+					readyAgain = true
+				})
+				Expect(readyAgain).To(BeTrue())
+			})
+		})
+
+		When("The function doesn't wait to signal it's ready", func() {
+			It("Should be ready once initialised", func() {
+				stream := &mockFaasStream{
+					sent:     make(chan *pb.ServerMessage, 1),
+					received: make(chan *pb.ClientMessage, 1),
+				}
+				defer close(stream.received)
+
+				wrkr := NewFaasWorker(stream, logger.NewNoopLogger(), nil, 1024)
+				ready := make(chan bool, 1)
+				wrkr.OnReady(func() {
+					ready <- true
+				})
+				go wrkr.Listen(make(chan error, 1))
+
+				stream.received <- &pb.ClientMessage{
+					Content: &pb.ClientMessage_InitRequest{
+						InitRequest: &pb.InitRequest{},
+					},
+				}
+				Eventually(ready).Should(Receive())
+			})
+		})
+	})
+})
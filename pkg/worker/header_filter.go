@@ -0,0 +1,108 @@
+// Copyright 2021 Nitric Pty Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package worker
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/nitrictech/nitric/pkg/triggers"
+)
+
+// Headers that only apply to a single connection and are never forwarded, see RFC 7230 section 6.1
+var hopByHopHeaders = []string{
+	"Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Proxy-Connection",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+// headerFilterWorker - Removes headers from HTTP triggers before they're dispatched to the worker
+type headerFilterWorker struct {
+	Worker
+	// Canonical header names, all headers are allowed if empty
+	allow map[string]bool
+	// Canonical header names
+	deny map[string]bool
+}
+
+// toHeaderSet - Builds a set of canonical header names
+func toHeaderSet(headers []string) map[string]bool {
+	set := make(map[string]bool)
+	for _, h := range headers {
+		if h = strings.TrimSpace(h); h != "" {
+			set[http.CanonicalHeaderKey(h)] = true
+		}
+	}
+	return set
+}
+
+// filterHeaders - Returns a copy of the headers without hop-by-hop, denied or unlisted headers
+func (w *headerFilterWorker) filterHeaders(header map[string][]string) map[string][]string {
+	removed := toHeaderSet(hopByHopHeaders)
+
+	// Headers listed in Connection are also hop-by-hop
+	for key, values := range header {
+		if http.CanonicalHeaderKey(key) == "Connection" {
+			for _, v := range values {
+				for k := range toHeaderSet(strings.Split(v, ",")) {
+					removed[k] = true
+				}
+			}
+		}
+	}
+
+	filtered := make(map[string][]string)
+	for key, values := range header {
+		canonical := http.CanonicalHeaderKey(key)
+
+		if removed[canonical] || w.deny[canonical] {
+			continue
+		}
+
+		if len(w.allow) > 0 && !w.allow[canonical] {
+			continue
+		}
+
+		filtered[key] = values
+	}
+
+	return filtered
+}
+
+// HandleHttpRequest - Dispatches a copy of the request with its headers filtered
+func (w *headerFilterWorker) HandleHttpRequest(trigger *triggers.HttpRequest) (*triggers.HttpResponse, error) {
+	filtered := *trigger
+	filtered.Header = w.filterHeaders(trigger.Header)
+
+	return w.Worker.HandleHttpRequest(&filtered)
+}
+
+// WithHeaderFilter - Strips hop-by-hop and denied headers from HTTP requests,
+// if an allow list is given only the listed headers are passed to the worker
+func WithHeaderFilter(allow []string, deny []string) WorkerDecorator {
+	return func(wrkr Worker) Worker {
+		return &headerFilterWorker{
+			Worker: wrkr,
+			allow:  toHeaderSet(allow),
+			deny:   toHeaderSet(deny),
+		}
+	}
+}
@@ -0,0 +1,367 @@
+// Copyright 2021 Nitric Pty Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package worker
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/nitrictech/nitric/pkg/logger"
+	"github.com/nitrictech/nitric/pkg/triggers"
+	"github.com/valyala/fasthttp"
+)
+
+// DefaultHttpLogBodyBytes - The size of the body preview logged when none is configured
+const DefaultHttpLogBodyBytes = 1024
+
+// The value logged in place of redacted headers and JSON values
+const redactedValue = "[REDACTED]"
+
+// Headers that carry credentials, which are always redacted
+var defaultRedactedHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie"}
+
+// Matches a JSON object key and the colon that follows it
+var jsonKeyPattern = regexp.MustCompile(`"((?:[^"\\]|\\.)*)"\s*:\s*`)
+
+// HttpLoggingOptions - Configures logging of HTTP requests dispatched to workers and the responses they return
+type HttpLoggingOptions struct {
+	// Headers included in log entries, all headers are logged if empty
+	Headers []string
+	// Headers whose values are redacted, in addition to Authorization, Proxy-Authorization, Cookie and Set-Cookie
+	RedactHeaders []string
+	// A regular expression matched against the keys of JSON bodies, the values of matching keys are redacted
+	RedactBodyKeys string
+	// The maximum size of the body preview logged, defaults to 1024. Bodies aren't logged if negative
+	BodyBytes int
+}
+
+// HttpLogPolicy - Compiled HTTP logging options
+type HttpLogPolicy struct {
+	headers        map[string]bool
+	redactHeaders  map[string]bool
+	redactBodyKeys *regexp.Regexp
+	bodyBytes      int
+}
+
+// NewHttpLogPolicy - Compiles HTTP logging options, returning an error if the body key pattern is invalid
+func NewHttpLogPolicy(options *HttpLoggingOptions) (*HttpLogPolicy, error) {
+	policy := &HttpLogPolicy{
+		headers:       toHeaderSet(options.Headers),
+		redactHeaders: toHeaderSet(append(append([]string{}, defaultRedactedHeaders...), options.RedactHeaders...)),
+		bodyBytes:     options.BodyBytes,
+	}
+
+	if policy.bodyBytes == 0 {
+		policy.bodyBytes = DefaultHttpLogBodyBytes
+	}
+
+	if options.RedactBodyKeys != "" {
+		pattern, err := regexp.Compile(options.RedactBodyKeys)
+		if err != nil {
+			return nil, fmt.Errorf("invalid body key redaction pattern: %v", err)
+		}
+		policy.redactBodyKeys = pattern
+	}
+
+	return policy, nil
+}
+
+// logHeader - Returns true if the header is included in log entries
+func (p *HttpLogPolicy) logHeader(canonical string) bool {
+	return len(p.headers) == 0 || p.headers[canonical]
+}
+
+// requestHeaders - Returns the logged request headers, with sensitive values redacted
+func (p *HttpLogPolicy) requestHeaders(header map[string][]string) map[string][]string {
+	logged := make(map[string][]string)
+	for key, values := range header {
+		canonical := http.CanonicalHeaderKey(key)
+		if !p.logHeader(canonical) {
+			continue
+		}
+
+		if p.redactHeaders[canonical] {
+			logged[key] = []string{redactedValue}
+		} else {
+			logged[key] = values
+		}
+	}
+
+	return logged
+}
+
+// responseHeaders - Returns the logged response headers, with sensitive values redacted
+func (p *HttpLogPolicy) responseHeaders(header *fasthttp.ResponseHeader) map[string][]string {
+	logged := make(map[string][]string)
+	if header == nil {
+		return logged
+	}
+
+	header.VisitAll(func(k []byte, v []byte) {
+		canonical := http.CanonicalHeaderKey(string(k))
+		if !p.logHeader(canonical) {
+			return
+		}
+
+		if p.redactHeaders[canonical] {
+			logged[canonical] = []string{redactedValue}
+		} else {
+			logged[canonical] = append(logged[canonical], string(v))
+		}
+	})
+
+	return logged
+}
+
+// redactJsonValue - Replaces the values of keys matching the pattern throughout a decoded JSON value
+func redactJsonValue(value interface{}, pattern *regexp.Regexp) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			if pattern.MatchString(key) {
+				v[key] = redactedValue
+			} else {
+				v[key] = redactJsonValue(child, pattern)
+			}
+		}
+	case []interface{}:
+		for i, child := range v {
+			v[i] = redactJsonValue(child, pattern)
+		}
+	}
+
+	return value
+}
+
+// jsonStringEnd - Returns the index after the closing quote of the JSON string starting at start, or -1 if it's unterminated
+func jsonStringEnd(text []byte, start int) int {
+	for i := start + 1; i < len(text); i++ {
+		switch text[i] {
+		case '\\':
+			i++
+		case '"':
+			return i + 1
+		}
+	}
+
+	return -1
+}
+
+// redactJsonText - Redacts the values of matching keys in JSON text that can't be decoded, such as a truncated preview.
+// Once a matching key's value is an object, an array or is unterminated the rest of the text is dropped,
+// so a redacted value never appears in the output
+func redactJsonText(text []byte, pattern *regexp.Regexp) []byte {
+	var out bytes.Buffer
+
+	i := 0
+	for i < len(text) {
+		loc := jsonKeyPattern.FindSubmatchIndex(text[i:])
+		if loc == nil {
+			break
+		}
+
+		keyStart, keyEnd, valueStart := i+loc[2], i+loc[3], i+loc[1]
+
+		var key string
+		if err := json.Unmarshal(text[keyStart-1:keyEnd+1], &key); err != nil {
+			key = string(text[keyStart:keyEnd])
+		}
+
+		out.Write(text[i:valueStart])
+		i = valueStart
+		if !pattern.MatchString(key) || valueStart >= len(text) {
+			continue
+		}
+
+		valueEnd := -1
+		switch text[valueStart] {
+		case '"':
+			valueEnd = jsonStringEnd(text, valueStart)
+		case '{', '[':
+		default:
+			valueEnd = valueStart
+			for valueEnd < len(text) && bytes.IndexByte([]byte(",}] \t\r\n"), text[valueEnd]) < 0 {
+				valueEnd++
+			}
+			if valueEnd == len(text) {
+				valueEnd = -1
+			}
+		}
+
+		out.WriteString(`"` + redactedValue + `"`)
+		if valueEnd < 0 {
+			return out.Bytes()
+		}
+		i = valueEnd
+	}
+
+	out.Write(text[i:])
+
+	return out.Bytes()
+}
+
+// bodyPreview - Returns the logged preview of a body, complete is false when body is only the start of a longer body
+func (p *HttpLogPolicy) bodyPreview(body []byte, complete bool) string {
+	if p.redactBodyKeys != nil {
+		trimmed := bytes.TrimSpace(body)
+		if len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[') {
+			var decoded interface{}
+			decoder := json.NewDecoder(bytes.NewReader(body))
+			decoder.UseNumber()
+
+			if complete && decoder.Decode(&decoded) == nil && !decoder.More() {
+				if redacted, err := json.Marshal(redactJsonValue(decoded, p.redactBodyKeys)); err == nil {
+					body = redacted
+				} else {
+					body = redactJsonText(body, p.redactBodyKeys)
+				}
+			} else {
+				body = redactJsonText(body, p.redactBodyKeys)
+			}
+		}
+	}
+
+	if len(body) > p.bodyBytes {
+		body = body[:p.bodyBytes]
+	}
+
+	return string(body)
+}
+
+// capturingBody - Wraps a streamed body, capturing its start as it's read by the worker or the gateway,
+// so the body is only read once
+type capturingBody struct {
+	io.ReadCloser
+	limit    int
+	captured []byte
+	length   int64
+	done     func(captured []byte, length int64, complete bool)
+	doneOnce sync.Once
+}
+
+func (b *capturingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if remaining := b.limit - len(b.captured); remaining > 0 {
+		if remaining > n {
+			remaining = n
+		}
+		b.captured = append(b.captured, p[:remaining]...)
+	}
+	b.length += int64(n)
+
+	if err == io.EOF {
+		b.finish(true)
+	}
+
+	return n, err
+}
+
+// finish - Reports the captured body once, when it's read to the end or closed
+func (b *capturingBody) finish(complete bool) {
+	b.doneOnce.Do(func() {
+		if b.done != nil {
+			b.done(b.captured, b.length, complete && int64(len(b.captured)) == b.length)
+		}
+	})
+}
+
+func (b *capturingBody) Close() error {
+	b.finish(false)
+	return b.ReadCloser.Close()
+}
+
+// httpLoggingWorker - Logs HTTP requests and the responses returned by the worker
+type httpLoggingWorker struct {
+	Worker
+	policy *HttpLogPolicy
+	log    logger.Logger
+}
+
+// HandleHttpRequest - Logs the request and response, streamed bodies are previewed as they're read
+// rather than read in advance, so the request preview covers what the worker read
+func (w *httpLoggingWorker) HandleHttpRequest(trigger *triggers.HttpRequest) (*triggers.HttpResponse, error) {
+	start := time.Now()
+
+	logged := *trigger
+	var requestBody *capturingBody
+	if trigger.IsStreamed() && w.policy.bodyBytes > 0 {
+		requestBody = &capturingBody{ReadCloser: trigger.BodyStream, limit: w.policy.bodyBytes}
+		logged.BodyStream = requestBody
+	}
+
+	response, err := w.Worker.HandleHttpRequest(&logged)
+
+	requestFields := []interface{}{
+		"method", trigger.Method,
+		"path", trigger.Path,
+		"headers", w.policy.requestHeaders(trigger.Header),
+		"bodyBytes", trigger.BodyLength(),
+	}
+	if w.policy.bodyBytes > 0 {
+		if requestBody != nil {
+			requestFields = append(requestFields, "body", w.policy.bodyPreview(requestBody.captured, int64(len(requestBody.captured)) == trigger.BodyStreamLength))
+		} else {
+			requestFields = append(requestFields, "body", w.policy.bodyPreview(trigger.Body, true))
+		}
+	}
+	w.log.Info("http request", requestFields...)
+
+	if err != nil {
+		w.log.Info("http response", "method", trigger.Method, "path", trigger.Path, "error", err.Error(), "duration", time.Since(start).String())
+		return response, err
+	}
+
+	logResponse := func(body []byte, length int64, complete bool) {
+		fields := []interface{}{
+			"method", trigger.Method,
+			"path", trigger.Path,
+			"status", response.StatusCode,
+			"headers", w.policy.responseHeaders(response.Header),
+			"bodyBytes", length,
+			"duration", time.Since(start).String(),
+		}
+		if w.policy.bodyBytes > 0 {
+			fields = append(fields, "body", w.policy.bodyPreview(body, complete))
+		}
+		w.log.Info("http response", fields...)
+	}
+
+	// Streamed responses are logged once the gateway has finished sending them
+	if response.IsStreamed() {
+		response.BodyStream = &capturingBody{ReadCloser: response.BodyStream, limit: w.policy.bodyBytes, done: logResponse}
+	} else {
+		logResponse(response.Body, int64(len(response.Body)), true)
+	}
+
+	return response, nil
+}
+
+// WithHttpLogging - Logs HTTP requests dispatched to the worker and the responses it returns,
+// with the headers and JSON body keys configured in the policy redacted
+func WithHttpLogging(policy *HttpLogPolicy, log logger.Logger) WorkerDecorator {
+	return func(wrkr Worker) Worker {
+		return &httpLoggingWorker{
+			Worker: wrkr,
+			policy: policy,
+			log:    log,
+		}
+	}
+}
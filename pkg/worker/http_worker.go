@@ -26,6 +26,7 @@ import (
 
 // A Nitric HTTP worker
 type HttpWorker struct {
+	UnimplementedWorker
 	address string
 }
 
@@ -35,7 +36,7 @@ func (h *HttpWorker) HandleEvent(trigger *triggers.Event) error {
 
 	httpRequest := fasthttp.AcquireRequest()
 	httpRequest.SetRequestURI(address)
-	httpRequest.Header.Add("x-nitric-request-id", trigger.ID)
+	httpRequest.Header.Add(RequestIdHeader, trigger.ID)
 	httpRequest.Header.Add("x-nitric-source-type", triggers.TriggerType_Subscription.String())
 	httpRequest.Header.Add("x-nitric-source", trigger.Topic)
 
@@ -76,8 +77,12 @@ func (h *HttpWorker) HandleHttpRequest(trigger *triggers.HttpRequest) (*triggers
 	}
 
 	httpRequest.Header.Del("Content-Length")
-	httpRequest.SetBody(trigger.Body)
-	httpRequest.Header.SetContentLength(len(trigger.Body))
+	if trigger.IsStreamed() {
+		httpRequest.SetBodyStream(trigger.BodyStream, int(trigger.BodyStreamLength))
+	} else {
+		httpRequest.SetBody(trigger.Body)
+		httpRequest.Header.SetContentLength(len(trigger.Body))
+	}
 
 	var resp fasthttp.Response
 	err := fasthttp.Do(httpRequest, &resp)
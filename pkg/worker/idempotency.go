@@ -0,0 +1,331 @@
+// Copyright 2021 Nitric Pty Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package worker
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nitrictech/nitric/pkg/logger"
+	"github.com/nitrictech/nitric/pkg/plugins/document"
+	"github.com/nitrictech/nitric/pkg/plugins/errors"
+	"github.com/nitrictech/nitric/pkg/plugins/errors/codes"
+	"github.com/nitrictech/nitric/pkg/triggers"
+	"github.com/valyala/fasthttp"
+)
+
+// IdempotencyKeyHeader - The header callers set to a unique key, so retries of the request are only handled once
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// IdempotentReplayedHeader - Set on responses replayed from the idempotency store
+const IdempotentReplayedHeader = "Idempotent-Replayed"
+
+// IdempotencyCollection - The document collection responses are stored in by the document idempotency store
+const IdempotencyCollection = "nitric-idempotency"
+
+// CachedResponse - A HTTP response stored for an idempotency key
+type CachedResponse struct {
+	StatusCode int                 `json:"statusCode"`
+	Header     map[string][]string `json:"header,omitempty"`
+	Body       []byte              `json:"body,omitempty"`
+}
+
+// cachedResponse - Copies a buffered response, so it can be stored
+func cachedResponse(response *triggers.HttpResponse) *CachedResponse {
+	cached := &CachedResponse{
+		StatusCode: response.StatusCode,
+		Header:     make(map[string][]string),
+		Body:       append([]byte(nil), response.Body...),
+	}
+
+	if response.Header != nil {
+		response.Header.VisitAll(func(key []byte, value []byte) {
+			cached.Header[string(key)] = append(cached.Header[string(key)], string(value))
+		})
+	}
+
+	return cached
+}
+
+// HttpResponse - Returns a new response replaying the stored response
+func (c *CachedResponse) HttpResponse() *triggers.HttpResponse {
+	header := &fasthttp.ResponseHeader{}
+	for key, values := range c.Header {
+		for _, value := range values {
+			header.Add(key, value)
+		}
+	}
+	header.Set(IdempotentReplayedHeader, "true")
+
+	return &triggers.HttpResponse{
+		Header:     header,
+		Body:       append([]byte(nil), c.Body...),
+		StatusCode: c.StatusCode,
+	}
+}
+
+// IdempotencyStore - Stores the responses to requests by their idempotency key
+type IdempotencyStore interface {
+	// Get - Returns the response stored for the key, nil if there isn't one or it has expired
+	Get(key string) (*CachedResponse, error)
+	// Put - Stores the response for the key until the ttl has elapsed
+	Put(key string, response *CachedResponse, ttl time.Duration) error
+}
+
+// memoryIdempotencyEntry - A response stored in memory, keyed by its idempotency key
+type memoryIdempotencyEntry struct {
+	key      string
+	response *CachedResponse
+	expires  time.Time
+}
+
+// memoryIdempotencyStore - An LRU cache of responses, the least recently stored are evicted once it's full
+type memoryIdempotencyStore struct {
+	lock    sync.Mutex
+	size    int
+	entries map[string]*list.Element
+	// Most recently stored first
+	order *list.List
+}
+
+func (s *memoryIdempotencyStore) Get(key string) (*CachedResponse, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	el, ok := s.entries[key]
+	if !ok {
+		return nil, nil
+	}
+
+	entry := el.Value.(*memoryIdempotencyEntry)
+	if time.Now().After(entry.expires) {
+		s.order.Remove(el)
+		delete(s.entries, key)
+		return nil, nil
+	}
+
+	return entry.response, nil
+}
+
+func (s *memoryIdempotencyStore) Put(key string, response *CachedResponse, ttl time.Duration) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if el, ok := s.entries[key]; ok {
+		s.order.Remove(el)
+	}
+
+	s.entries[key] = s.order.PushFront(&memoryIdempotencyEntry{
+		key:      key,
+		response: response,
+		expires:  time.Now().Add(ttl),
+	})
+
+	for s.order.Len() > s.size {
+		oldest := s.order.Back()
+		s.order.Remove(oldest)
+		delete(s.entries, oldest.Value.(*memoryIdempotencyEntry).key)
+	}
+
+	return nil
+}
+
+// NewMemoryIdempotencyStore - Creates a store keeping up to size responses in memory,
+// responses aren't shared between membranes and are lost when the membrane stops
+func NewMemoryIdempotencyStore(size int) IdempotencyStore {
+	return &memoryIdempotencyStore{
+		size:    size,
+		entries: make(map[string]*list.Element),
+		order:   list.New(),
+	}
+}
+
+// documentIdempotencyStore - Stores responses as documents, so they're shared by every membrane using the plugin
+type documentIdempotencyStore struct {
+	plugin     document.DocumentService
+	collection string
+}
+
+// documentKey - Hashes the idempotency key, as keys may contain characters document IDs can't
+func (s *documentIdempotencyStore) documentKey(key string) *document.Key {
+	hash := sha256.Sum256([]byte(key))
+
+	return &document.Key{
+		Collection: &document.Collection{Name: s.collection},
+		Id:         hex.EncodeToString(hash[:]),
+	}
+}
+
+func (s *documentIdempotencyStore) Get(key string) (*CachedResponse, error) {
+	doc, err := s.plugin.Get(s.documentKey(key))
+	if err != nil {
+		if errors.Code(err) == codes.NotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	// Expired responses may remain until the plugin removes them, if it supports expiry at all
+	expires, _ := doc.Content["expires"].(string)
+	if expiry, err := time.Parse(time.RFC3339Nano, expires); err != nil || time.Now().After(expiry) {
+		return nil, nil
+	}
+
+	encoded, _ := doc.Content["response"].(string)
+	response := &CachedResponse{}
+	if err := json.Unmarshal([]byte(encoded), response); err != nil {
+		return nil, fmt.Errorf("invalid idempotency response stored for key %s: %v", key, err)
+	}
+
+	return response, nil
+}
+
+func (s *documentIdempotencyStore) Put(key string, response *CachedResponse, ttl time.Duration) error {
+	encoded, err := json.Marshal(response)
+	if err != nil {
+		return err
+	}
+
+	content := map[string]interface{}{
+		// Encoded, so the body and headers are stored as is by every document plugin
+		"response": string(encoded),
+		"expires":  time.Now().Add(ttl).UTC().Format(time.RFC3339Nano),
+	}
+
+	if expiring, ok := s.plugin.(document.ExpiringDocumentService); ok {
+		return expiring.SetWithTtl(s.documentKey(key), content, ttl)
+	}
+
+	return s.plugin.Set(s.documentKey(key), content)
+}
+
+// NewDocumentIdempotencyStore - Creates a store keeping responses in the IdempotencyCollection of the document plugin
+func NewDocumentIdempotencyStore(plugin document.DocumentService) IdempotencyStore {
+	return &documentIdempotencyStore{
+		plugin:     plugin,
+		collection: IdempotencyCollection,
+	}
+}
+
+// idempotencyState - The requests in flight for each key, shared by every decorated worker
+type idempotencyState struct {
+	store IdempotencyStore
+	ttl   time.Duration
+	log   logger.Logger
+
+	lock sync.Mutex
+	// Closed when the request in flight for the key completes
+	inflight map[string]chan struct{}
+}
+
+// idempotencyWorker - Replays the stored response to requests with an idempotency key that was already handled
+type idempotencyWorker struct {
+	Worker
+	state *idempotencyState
+}
+
+// cacheable - Returns true if the response can be replayed, server errors aren't stored so the request can be retried
+func cacheable(response *triggers.HttpResponse) bool {
+	return response != nil && !response.IsStreamed() && response.StatusCode < 500
+}
+
+// HandleHttpRequest - Returns the stored response for the request's idempotency key, if there is one. Requests with
+// the same key as a request in flight wait for it to complete, then replay its response. If it wasn't stored,
+// e.g. it failed with a server error, the next waiting request is dispatched to the worker instead
+func (w *idempotencyWorker) HandleHttpRequest(trigger *triggers.HttpRequest) (*triggers.HttpResponse, error) {
+	idempotencyKey := headerValue(trigger.Header, IdempotencyKeyHeader)
+	if idempotencyKey == "" {
+		return w.Worker.HandleHttpRequest(trigger)
+	}
+
+	key := strings.ToUpper(trigger.Method) + " " + trigger.Path + " " + idempotencyKey
+
+	for {
+		cached, err := w.state.store.Get(key)
+		if err != nil {
+			return nil, fmt.Errorf("error reading idempotency key %s: %v", idempotencyKey, err)
+		}
+
+		if cached != nil {
+			return cached.HttpResponse(), nil
+		}
+
+		w.state.lock.Lock()
+		if done, ok := w.state.inflight[key]; ok {
+			w.state.lock.Unlock()
+			<-done
+			continue
+		}
+
+		done := make(chan struct{})
+		w.state.inflight[key] = done
+		w.state.lock.Unlock()
+
+		response, err := w.handle(key, trigger)
+
+		w.state.lock.Lock()
+		delete(w.state.inflight, key)
+		close(done)
+		w.state.lock.Unlock()
+
+		return response, err
+	}
+}
+
+// handle - Dispatches the request to the worker, storing its response if it can be replayed
+func (w *idempotencyWorker) handle(key string, trigger *triggers.HttpRequest) (*triggers.HttpResponse, error) {
+	response, err := w.Worker.HandleHttpRequest(trigger)
+	if err != nil || !cacheable(response) {
+		return response, err
+	}
+
+	if err := w.state.store.Put(key, cachedResponse(response), w.state.ttl); err != nil {
+		// The request was handled, so its response is returned even though a retry will be handled again
+		w.state.log.Warn(
+			"unable to store response for idempotency key",
+			"key", key,
+			"method", trigger.Method,
+			"path", trigger.Path,
+			"error", err,
+		)
+	}
+
+	return response, nil
+}
+
+// WithIdempotency - Handles requests with an Idempotency-Key header once per method, path and key within the ttl,
+// replaying the stored response to retries without invoking the worker. Streamed responses and server errors
+// aren't stored, so those requests are handled again when retried. Responses that can't be stored are logged to log
+func WithIdempotency(store IdempotencyStore, ttl time.Duration, log logger.Logger) WorkerDecorator {
+	state := &idempotencyState{
+		store:    store,
+		ttl:      ttl,
+		log:      log,
+		inflight: make(map[string]chan struct{}),
+	}
+
+	return func(wrkr Worker) Worker {
+		return &idempotencyWorker{
+			Worker: wrkr,
+			state:  state,
+		}
+	}
+}
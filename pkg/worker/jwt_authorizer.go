@@ -0,0 +1,383 @@
+// Copyright 2021 Nitric Pty Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package worker
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nitrictech/nitric/pkg/triggers"
+)
+
+// jwtAlgorithms - The supported signing algorithms, symmetric algorithms and none are rejected
+var jwtAlgorithms = map[string]crypto.Hash{
+	"RS256": crypto.SHA256,
+	"RS384": crypto.SHA384,
+	"RS512": crypto.SHA512,
+	"PS256": crypto.SHA256,
+	"PS384": crypto.SHA384,
+	"PS512": crypto.SHA512,
+	"ES256": crypto.SHA256,
+	"ES384": crypto.SHA384,
+	"ES512": crypto.SHA512,
+}
+
+// JwtOptions - Configures the validation of JWT bearer tokens
+type JwtOptions struct {
+	// The URL of the JSON Web Key Set tokens are signed with, e.g. https://example.auth0.com/.well-known/jwks.json
+	JwksUrl string
+	// The iss claim tokens must have, any issuer is accepted if empty
+	Issuer string
+	// The aud claims accepted, tokens must have one of them. Any audience is accepted if empty
+	Audiences []string
+	// The clock skew allowed when checking the exp and nbf claims
+	Leeway time.Duration
+	// The time keys are cached for before the key set is fetched again, defaults to 1 hour.
+	// Tokens signed with an unknown key fetch the key set at most once a minute
+	CacheDuration time.Duration
+	// The client the key set is fetched with, defaults to a client with a 10 second timeout
+	HTTPClient *http.Client
+}
+
+// jsonWebKey - A public key of a JSON Web Key Set, see RFC 7517
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	// RSA keys
+	N string `json:"n"`
+	E string `json:"e"`
+	// EC keys
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// publicKey - Decodes the key, returning nil for unsupported key types
+func (k *jsonWebKey) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("invalid modulus for key %s: %v", k.Kid, err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("invalid exponent for key %s: %v", k.Kid, err)
+		}
+
+		return &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %s for key %s", k.Crv, k.Kid)
+		}
+
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, fmt.Errorf("invalid x coordinate for key %s: %v", k.Kid, err)
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, fmt.Errorf("invalid y coordinate for key %s: %v", k.Kid, err)
+		}
+
+		return &ecdsa.PublicKey{
+			Curve: curve,
+			X:     new(big.Int).SetBytes(x),
+			Y:     new(big.Int).SetBytes(y),
+		}, nil
+	}
+
+	return nil, nil
+}
+
+// jwksKey - A decoded signing key
+type jwksKey struct {
+	alg string
+	key crypto.PublicKey
+}
+
+// JwtAuthorizer - Authorizes requests with a JWT bearer token, signed by a key of a JSON Web Key Set
+type JwtAuthorizer struct {
+	options *JwtOptions
+
+	lock    sync.Mutex
+	keys    map[string]*jwksKey
+	fetched time.Time
+}
+
+var _ Authorizer = (*JwtAuthorizer)(nil)
+
+// fetchKeys - Fetches the signing keys of the key set, keys that can't verify signatures are skipped
+func (a *JwtAuthorizer) fetchKeys() (map[string]*jwksKey, error) {
+	resp, err := a.options.HTTPClient.Get(a.options.JwksUrl)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching key set: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("error fetching key set: status %d", resp.StatusCode)
+	}
+
+	var jwks struct {
+		Keys []*jsonWebKey `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&jwks); err != nil {
+		return nil, fmt.Errorf("invalid key set: %v", err)
+	}
+
+	keys := make(map[string]*jwksKey, len(jwks.Keys))
+	for _, k := range jwks.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+
+		key, err := k.publicKey()
+		if err != nil || key == nil {
+			continue
+		}
+
+		keys[k.Kid] = &jwksKey{alg: k.Alg, key: key}
+	}
+
+	return keys, nil
+}
+
+// key - Returns the key with the ID, fetching the key set when the cache has expired or the key is unknown
+func (a *JwtAuthorizer) key(kid string) (*jwksKey, error) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	key, ok := a.keys[kid]
+	age := time.Since(a.fetched)
+	// Unknown keys refetch the key set so rotated keys are found, limited so bad tokens can't flood the endpoint
+	if a.keys == nil || age > a.options.CacheDuration || (!ok && age > time.Minute) {
+		keys, err := a.fetchKeys()
+		if err != nil {
+			// Keep validating with the cached key while the key set can't be fetched
+			if ok {
+				return key, nil
+			}
+			return nil, err
+		}
+
+		a.keys = keys
+		a.fetched = time.Now()
+		key, ok = a.keys[kid]
+	}
+
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %s", kid)
+	}
+
+	return key, nil
+}
+
+// verifyJwtSignature - Verifies the signature of the signed content with the key
+func verifyJwtSignature(alg string, key crypto.PublicKey, signed []byte, signature []byte) error {
+	hash := jwtAlgorithms[alg]
+	hasher := hash.New()
+	hasher.Write(signed)
+	digest := hasher.Sum(nil)
+
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		if strings.HasPrefix(alg, "RS") {
+			return rsa.VerifyPKCS1v15(k, hash, digest, signature)
+		}
+		if strings.HasPrefix(alg, "PS") {
+			return rsa.VerifyPSS(k, hash, digest, signature, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+		}
+	case *ecdsa.PublicKey:
+		size := (k.Curve.Params().BitSize + 7) / 8
+		if strings.HasPrefix(alg, "ES") && len(signature) == 2*size {
+			r := new(big.Int).SetBytes(signature[:size])
+			s := new(big.Int).SetBytes(signature[size:])
+			if ecdsa.Verify(k, digest, r, s) {
+				return nil
+			}
+		}
+	}
+
+	return fmt.Errorf("invalid signature")
+}
+
+// audiences - Returns the aud claim, which may be a single string or an array of them
+func audiences(claim interface{}) []string {
+	switch aud := claim.(type) {
+	case string:
+		return []string{aud}
+	case []interface{}:
+		values := make([]string, 0, len(aud))
+		for _, a := range aud {
+			if s, ok := a.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+
+	return nil
+}
+
+// ValidateToken - Verifies the signature and claims of the token, returning its claims
+func (a *JwtAuthorizer) ValidateToken(token string) (map[string]interface{}, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("malformed token")
+	}
+
+	headerJson, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, fmt.Errorf("malformed token header: %v", err)
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := json.Unmarshal(headerJson, &header); err != nil {
+		return nil, fmt.Errorf("malformed token header: %v", err)
+	}
+
+	if _, ok := jwtAlgorithms[header.Alg]; !ok {
+		return nil, fmt.Errorf("unsupported signing algorithm %s", header.Alg)
+	}
+
+	key, err := a.key(header.Kid)
+	if err != nil {
+		return nil, err
+	}
+
+	// Keys may be restricted to an algorithm, preventing tokens from choosing a weaker one
+	if key.alg != "" && key.alg != header.Alg {
+		return nil, fmt.Errorf("token algorithm %s doesn't match key algorithm %s", header.Alg, key.alg)
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("malformed token signature: %v", err)
+	}
+
+	if err := verifyJwtSignature(header.Alg, key.key, []byte(parts[0]+"."+parts[1]), signature); err != nil {
+		return nil, err
+	}
+
+	claimsJson, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("malformed token claims: %v", err)
+	}
+
+	claims := make(map[string]interface{})
+	if err := json.Unmarshal(claimsJson, &claims); err != nil {
+		return nil, fmt.Errorf("malformed token claims: %v", err)
+	}
+
+	now := time.Now()
+	if exp, ok := claims["exp"].(float64); ok && now.After(time.Unix(int64(exp), 0).Add(a.options.Leeway)) {
+		return nil, fmt.Errorf("token has expired")
+	}
+
+	if nbf, ok := claims["nbf"].(float64); ok && now.Add(a.options.Leeway).Before(time.Unix(int64(nbf), 0)) {
+		return nil, fmt.Errorf("token is not valid yet")
+	}
+
+	if a.options.Issuer != "" {
+		if iss, _ := claims["iss"].(string); iss != a.options.Issuer {
+			return nil, fmt.Errorf("unexpected token issuer %s", iss)
+		}
+	}
+
+	if len(a.options.Audiences) > 0 && !hasAudience(audiences(claims["aud"]), a.options.Audiences) {
+		return nil, fmt.Errorf("token is not intended for this audience")
+	}
+
+	return claims, nil
+}
+
+// hasAudience - Returns true if any of the token's audiences are accepted
+func hasAudience(tokenAudiences []string, accepted []string) bool {
+	for _, aud := range tokenAudiences {
+		for _, a := range accepted {
+			if aud == a {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// Authorize - Validates the request's bearer token, the principal is the token's subject
+func (a *JwtAuthorizer) Authorize(request *triggers.HttpRequest) (*triggers.Principal, error) {
+	token := bearerToken(request.Header)
+	if token == "" {
+		return nil, fmt.Errorf("missing bearer token")
+	}
+
+	claims, err := a.ValidateToken(token)
+	if err != nil {
+		return nil, err
+	}
+
+	subject, _ := claims["sub"].(string)
+
+	return &triggers.Principal{
+		Subject: subject,
+		Claims:  claims,
+	}, nil
+}
+
+// NewJwtAuthorizer - Creates an authorizer validating JWT bearer tokens signed by the keys of the JWKS URL
+func NewJwtAuthorizer(options *JwtOptions) (*JwtAuthorizer, error) {
+	if options.JwksUrl == "" {
+		return nil, fmt.Errorf("a JWKS URL is required to validate JWTs")
+	}
+
+	if options.CacheDuration <= 0 {
+		options.CacheDuration = time.Hour
+	}
+
+	if options.HTTPClient == nil {
+		options.HTTPClient = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	return &JwtAuthorizer{
+		options: options,
+	}, nil
+}
@@ -0,0 +1,184 @@
+// Copyright 2021 Nitric Pty Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package worker
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/nitrictech/nitric/pkg/triggers"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// signJwt - Returns an RS256 token with the claims, signed by the key
+func signJwt(key *rsa.PrivateKey, kid string, claims map[string]interface{}) string {
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT", "kid": kid})
+	payload, _ := json.Marshal(claims)
+
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signed))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	Expect(err).ShouldNot(HaveOccurred())
+
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+var _ = Describe("JwtAuthorizer", func() {
+	var key *rsa.PrivateKey
+	var jwks *httptest.Server
+	var authorizer *JwtAuthorizer
+	var inner *countingWorker
+	var w Worker
+
+	validClaims := func() map[string]interface{} {
+		return map[string]interface{}{
+			"sub": "user-1",
+			"iss": "https://issuer.example.com/",
+			"aud": []string{"orders-api"},
+			"exp": time.Now().Add(time.Hour).Unix(),
+		}
+	}
+
+	authorizedRequest := func(token string) *triggers.HttpRequest {
+		return &triggers.HttpRequest{
+			Method: "GET",
+			Path:   "/orders",
+			Header: map[string][]string{"Authorization": {"Bearer " + token}},
+		}
+	}
+
+	BeforeEach(func() {
+		var err error
+		key, err = rsa.GenerateKey(rand.Reader, 2048)
+		Expect(err).ShouldNot(HaveOccurred())
+
+		jwks = httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			_ = json.NewEncoder(rw).Encode(map[string]interface{}{
+				"keys": []map[string]string{{
+					"kty": "RSA",
+					"kid": "key-1",
+					"use": "sig",
+					"alg": "RS256",
+					"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+					"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+				}},
+			})
+		}))
+
+		authorizer, err = NewJwtAuthorizer(&JwtOptions{
+			JwksUrl:   jwks.URL,
+			Issuer:    "https://issuer.example.com/",
+			Audiences: []string{"orders-api"},
+		})
+		Expect(err).ShouldNot(HaveOccurred())
+
+		inner = &countingWorker{statusCode: 200}
+		w = WithTriggerMiddleware(Authorize(authorizer))(inner)
+	})
+
+	AfterEach(func() {
+		jwks.Close()
+	})
+
+	When("The token is valid", func() {
+		It("Should dispatch the request with the token's principal", func() {
+			request := authorizedRequest(signJwt(key, "key-1", validClaims()))
+
+			resp, err := w.HandleHttpRequest(request)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(resp.StatusCode).To(Equal(200))
+			Expect(inner.count()).To(Equal(1))
+
+			Expect(request.Principal).ToNot(BeNil())
+			Expect(request.Principal.Subject).To(Equal("user-1"))
+			Expect(request.Principal.Claims).To(HaveKeyWithValue("iss", "https://issuer.example.com/"))
+		})
+	})
+
+	When("The token has expired", func() {
+		It("Should respond with a 401 without dispatching the request", func() {
+			claims := validClaims()
+			claims["exp"] = time.Now().Add(-time.Minute).Unix()
+
+			resp, err := w.HandleHttpRequest(authorizedRequest(signJwt(key, "key-1", claims)))
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(resp.StatusCode).To(Equal(401))
+			Expect(string(resp.Header.Peek("WWW-Authenticate"))).To(Equal(`Bearer error="invalid_token"`))
+			Expect(inner.count()).To(Equal(0))
+		})
+
+		It("Should accept it within the leeway", func() {
+			authorizer.options.Leeway = 5 * time.Minute
+			claims := validClaims()
+			claims["exp"] = time.Now().Add(-time.Minute).Unix()
+
+			_, err := authorizer.ValidateToken(signJwt(key, "key-1", claims))
+			Expect(err).ShouldNot(HaveOccurred())
+		})
+	})
+
+	When("The token is for a different audience", func() {
+		It("Should respond with a 401 without dispatching the request", func() {
+			claims := validClaims()
+			claims["aud"] = "billing-api"
+
+			resp, err := w.HandleHttpRequest(authorizedRequest(signJwt(key, "key-1", claims)))
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(resp.StatusCode).To(Equal(401))
+			Expect(inner.count()).To(Equal(0))
+		})
+	})
+
+	When("The token is signed by a key that isn't in the key set", func() {
+		It("Should reject it", func() {
+			other, err := rsa.GenerateKey(rand.Reader, 2048)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			_, err = authorizer.ValidateToken(signJwt(other, "key-1", validClaims()))
+			Expect(err).Should(HaveOccurred())
+
+			_, err = authorizer.ValidateToken(signJwt(other, "key-2", validClaims()))
+			Expect(err).Should(HaveOccurred())
+		})
+	})
+
+	When("The request has no bearer token", func() {
+		It("Should respond with a 401", func() {
+			resp, err := w.HandleHttpRequest(&triggers.HttpRequest{Method: "GET", Path: "/orders"})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(resp.StatusCode).To(Equal(401))
+			Expect(inner.count()).To(Equal(0))
+		})
+	})
+
+	When("The token uses the none algorithm", func() {
+		It("Should reject it", func() {
+			header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none"}`))
+			payload, _ := json.Marshal(validClaims())
+
+			_, err := authorizer.ValidateToken(header + "." + base64.RawURLEncoding.EncodeToString(payload) + ".")
+			Expect(err).Should(HaveOccurred())
+		})
+	})
+})
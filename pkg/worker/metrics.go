@@ -0,0 +1,111 @@
+// Copyright 2021 Nitric Pty Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package worker
+
+import (
+	"time"
+
+	"github.com/nitrictech/nitric/pkg/triggers"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const metricsNamespace = "nitric"
+
+// Metrics - Prometheus collectors for the triggers handled by workers, a nil *Metrics records nothing
+type Metrics struct {
+	httpRequests prometheus.Counter
+	events       prometheus.Counter
+	errors       *prometheus.CounterVec
+	latency      *prometheus.HistogramVec
+}
+
+// observe - Records a trigger dispatched to a worker at start, that completed with the given error
+func (m *Metrics) observe(triggerType triggers.TriggerType, start time.Time, err error) {
+	if m == nil {
+		return
+	}
+
+	switch triggerType {
+	case triggers.TriggerType_Request:
+		m.httpRequests.Inc()
+	case triggers.TriggerType_Subscription:
+		m.events.Inc()
+	}
+
+	m.latency.WithLabelValues(triggerType.String()).Observe(time.Since(start).Seconds())
+
+	if err != nil {
+		m.errors.WithLabelValues(triggerType.String()).Inc()
+	}
+}
+
+// NewMetrics - Creates the worker collectors, including gauges of the size and pending triggers of the given pool,
+// and registers them with the registerer
+func NewMetrics(registerer prometheus.Registerer, pool WorkerPool) (*Metrics, error) {
+	m := &Metrics{
+		httpRequests: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "worker",
+			Name:      "http_requests_total",
+			Help:      "The number of HTTP requests handled by workers.",
+		}),
+		events: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "worker",
+			Name:      "events_total",
+			Help:      "The number of events handled by workers.",
+		}),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "worker",
+			Name:      "errors_total",
+			Help:      "The number of triggers workers failed to handle.",
+		}, []string{"trigger_type"}),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "worker",
+			Name:      "handler_duration_seconds",
+			Help:      "The time taken by workers to handle triggers.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"trigger_type"}),
+	}
+
+	poolSize := prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "pool",
+		Name:      "workers",
+		Help:      "The number of workers currently registered with the pool.",
+	}, func() float64 {
+		return float64(pool.GetWorkerCount())
+	})
+
+	poolPending := prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "pool",
+		Name:      "pending_triggers",
+		Help:      "The number of triggers currently waiting for a worker.",
+	}, func() float64 {
+		return float64(pool.GetPendingCount())
+	})
+
+	collectors := []prometheus.Collector{m.httpRequests, m.events, m.errors, m.latency, poolSize, poolPending}
+	for _, c := range collectors {
+		if err := registerer.Register(c); err != nil {
+			return nil, err
+		}
+	}
+
+	return m, nil
+}
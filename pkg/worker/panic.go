@@ -0,0 +1,66 @@
+// Copyright 2021 Nitric Pty Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package worker
+
+import (
+	"fmt"
+	"runtime/debug"
+
+	"github.com/nitrictech/nitric/pkg/triggers"
+	"github.com/valyala/fasthttp"
+)
+
+// PanicError - Returned when a worker panics while handling a trigger
+type PanicError struct {
+	// The value passed to panic
+	Value interface{}
+	// The stack trace of the goroutine that panicked
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("worker panicked handling trigger: %v", e.Value)
+}
+
+// recoverPanic - Recovers from a panic, returning it as a PanicError through err. Must be deferred
+func recoverPanic(err *error) {
+	if r := recover(); r != nil {
+		*err = &PanicError{
+			Value: r,
+			Stack: debug.Stack(),
+		}
+	}
+}
+
+// callHttpHandler - Calls the handler, returning a PanicError if it panics
+func callHttpHandler(handle func() (*triggers.HttpResponse, error)) (response *triggers.HttpResponse, err error) {
+	defer recoverPanic(&err)
+	return handle()
+}
+
+// callHandler - Calls the handler, returning a PanicError if it panics
+func callHandler(handle func() error) (err error) {
+	defer recoverPanic(&err)
+	return handle()
+}
+
+// panicResponse - The response returned to clients when a worker panics handling their request
+func panicResponse() *triggers.HttpResponse {
+	return &triggers.HttpResponse{
+		Header:     &fasthttp.ResponseHeader{},
+		Body:       []byte("Internal Server Error"),
+		StatusCode: 500,
+	}
+}
@@ -15,33 +15,133 @@
 package worker
 
 import (
+	"context"
 	"fmt"
 	"sync"
 	"time"
+
+	"github.com/google/uuid"
+	"github.com/nitrictech/nitric/pkg/logger"
+	"github.com/nitrictech/nitric/pkg/plugins/errors"
+	"github.com/nitrictech/nitric/pkg/plugins/errors/codes"
 )
 
 type WorkerPool interface {
 	// WaitForMinimumWorkers - A blocking method
 	WaitForMinimumWorkers(timeout int) error
 	GetWorkerCount() int
+	// GetPendingCount - Returns the number of callers currently waiting for a worker
+	GetPendingCount() int
 	GetWorker() (Worker, error)
+	// GetWorkerWait - A blocking method, waits for a worker to be added to an empty pool or the context to be done
+	GetWorkerWait(ctx context.Context) (Worker, error)
+	// AddWorker - Adds a worker to the pool, failures are returned as a *PoolStartupError
 	AddWorker(Worker) error
 	RemoveWorker(Worker) error
+	// RemoveWorkerByID - Stops routing new triggers to a worker, removing it once its in-flight triggers complete
+	RemoveWorkerByID(id string) error
+	// ListWorkers - Returns the current state of each worker in the pool
+	ListWorkers() []WorkerInfo
 	Monitor() error
+	// Shutdown - A blocking method, stops handing out workers and waits for in-flight triggers to complete
+	Shutdown(timeout int) error
+}
+
+// ReleasableWorker - A worker retrieved from a pool that holds a concurrency slot reserved for its first trigger
+type ReleasableWorker interface {
+	Worker
+	// Release - Returns the reserved concurrency slot to the pool if no trigger has been dispatched to the worker
+	Release()
+}
+
+// ReleaseWorker - Returns the concurrency slot reserved for a retrieved worker to its pool if no trigger was dispatched to it.
+// Callers that may not dispatch a trigger to the worker they retrieve should defer this, it has no effect on other workers
+func ReleaseWorker(wrkr Worker) {
+	if releasable, ok := wrkr.(ReleasableWorker); ok {
+		releasable.Release()
+	}
 }
 
+// WorkerInfo - The state of a worker registered with a pool
+type WorkerInfo struct {
+	// ID assigned to the worker when it was added to the pool
+	ID string
+	// Busy - the worker is handling its maximum number of concurrent triggers
+	Busy bool
+	// InFlight - the number of triggers the worker is currently handling
+	InFlight int
+	// Draining - the worker is waiting for in-flight triggers to complete before being removed
+	Draining bool
+	// Ready - the worker has signalled it can handle triggers, workers that aren't ready are never selected
+	Ready bool
+	// Handled - the number of triggers the worker has completed
+	Handled int64
+	// LastError - the most recent error handling a trigger, empty if there hasn't been one
+	LastError string
+	// LastErrorAt - when LastError occurred
+	LastErrorAt time.Time
+}
+
+// ErrAllWorkersBusy - returned with a ResourceExhausted code by non-blocking pools when every worker is handling
+// its maximum concurrent triggers, so load is shed as it is by saturated pools
+var ErrAllWorkersBusy = errors.ErrorsWithScope("ProcessPool", nil)(
+	codes.ResourceExhausted,
+	"all workers are busy",
+	nil,
+)
+
+// ErrNoWorkersAvailable - returned when no workers in the pool can handle triggers, e.g. the function hasn't connected
+// or hasn't signalled it's ready yet
+var ErrNoWorkersAvailable = fmt.Errorf("no workers available in this pool")
+
+// ErrNoSubscribedWorkers - returned when workers are available but none subscribe to the topic of an event
+var ErrNoSubscribedWorkers = fmt.Errorf("no workers in this pool subscribe to the topic")
+
+// ErrPoolShuttingDown - returned once the pool has stopped handing out workers
+var ErrPoolShuttingDown = fmt.Errorf("worker pool is shutting down")
+
+// ErrPoolSaturated - returned with a ResourceExhausted code when the maximum number of callers are already waiting
+// for a worker, so load is shed instead of queueing without bound
+var ErrPoolSaturated = errors.ErrorsWithScope("ProcessPool", nil)(
+	codes.ResourceExhausted,
+	"worker pool is saturated, too many triggers are waiting for a worker",
+	nil,
+)
+
 type ProcessPoolOptions struct {
 	MinWorkers int
 	MaxWorkers int
+	// The maximum number of triggers a single worker will handle concurrently, 0 is unlimited
+	MaxConcurrency int
+	// Block GetWorker until a worker is free, instead of returning ErrAllWorkersBusy
+	Blocking bool
+	// The logger worker panics are logged to, defaults to a no-op logger
+	Logger logger.Logger
+	// Route no triggers to workers that can signal readiness, e.g. FaaS workers, until they have signalled they are ready
+	RequireReady bool
+	// The maximum number of callers that may wait for a worker, additional callers fail with ErrPoolSaturated, 0 is unlimited
+	MaxPending int
 }
 
 // ProcessPool - A worker pool that represent co-located processes
 type ProcessPool struct {
-	minWorkers int
-	maxWorkers int
-	workerLock sync.Mutex
-	workers    []Worker
+	minWorkers     int
+	maxWorkers     int
+	maxConcurrency int
+	blocking       bool
+	requireReady   bool
+	maxPending     int
+	log            logger.Logger
+	workerLock     sync.Mutex
+	// Signalled when a worker may have become available
+	workerAvailable *sync.Cond
+	workers         []*poolWorker
+	// Index of the next worker to be selected
+	nextWorker int
 	poolErr    chan error
+	closed     bool
+	// The number of callers waiting for a worker
+	pending int
 }
 
 func (p *ProcessPool) GetWorkerCount() int {
@@ -50,6 +150,28 @@ func (p *ProcessPool) GetWorkerCount() int {
 	return len(p.workers)
 }
 
+// GetPendingCount - Returns the number of callers currently waiting for a worker
+func (p *ProcessPool) GetPendingCount() int {
+	p.workerLock.Lock()
+	defer p.workerLock.Unlock()
+	return p.pending
+}
+
+// readyWorkerCount - Returns the number of workers that can be selected, ignoring how busy they are
+func (p *ProcessPool) readyWorkerCount() int {
+	p.workerLock.Lock()
+	defer p.workerLock.Unlock()
+
+	ready := 0
+	for _, w := range p.workers {
+		if w.ready && !w.draining {
+			ready++
+		}
+	}
+
+	return ready
+}
+
 // GetMinWorkers - return the minimum number of workers for this pool
 func (p *ProcessPool) GetMinWorkers() int {
 	return p.minWorkers
@@ -69,7 +191,7 @@ func (p *ProcessPool) Monitor() error {
 	return err
 }
 
-// WaitForMinimumWorkers - Waits for the configured minimum number of workers to be available in this pool
+// WaitForMinimumWorkers - Waits for the configured minimum number of workers to be ready in this pool
 func (p *ProcessPool) WaitForMinimumWorkers(timeout int) error {
 	maxWaitTime := time.Duration(timeout) * time.Second
 	// Longer poll times, e.g. 200 milliseconds results in slow lambda cold starts (15s+)
@@ -77,14 +199,14 @@ func (p *ProcessPool) WaitForMinimumWorkers(timeout int) error {
 
 	var waitedTime = time.Duration(0)
 	for {
-		if p.GetWorkerCount() >= p.minWorkers {
+		if p.readyWorkerCount() >= p.minWorkers {
 			break
 		} else {
 			if waitedTime < maxWaitTime {
 				time.Sleep(pollInterval)
 				waitedTime += pollInterval
 			} else {
-				return fmt.Errorf("available workers below required minimum of %d, %d available, timedout waiting for more workers", p.minWorkers, p.GetWorkerCount())
+				return fmt.Errorf("available workers below required minimum of %d, %d available, timedout waiting for more workers", p.minWorkers, p.readyWorkerCount())
 			}
 		}
 	}
@@ -92,15 +214,195 @@ func (p *ProcessPool) WaitForMinimumWorkers(timeout int) error {
 	return nil
 }
 
-// GetWorker - Retrieves a worker from this pool
+// selectWorker - Selects the next free worker round-robin, the worker lock must be held
+func (p *ProcessPool) selectWorker() (Worker, error) {
+	wrkr, err := p.selectMatchingWorker(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return wrkr, nil
+}
+
+// selectMatchingWorker - Selects the next free worker accepted by the filter round-robin, a nil filter accepts all workers.
+// A concurrency slot is reserved for the returned worker, so concurrent callers can't be handed the same free slot.
+// The worker lock must be held
+func (p *ProcessPool) selectMatchingWorker(accept func(*poolWorker) bool) (*reservedWorker, error) {
+	if p.closed {
+		return nil, ErrPoolShuttingDown
+	}
+
+	available := false
+	matched := false
+	for i := 0; i < len(p.workers); i++ {
+		idx := (p.nextWorker + i) % len(p.workers)
+		w := p.workers[idx]
+		if !w.ready || w.draining {
+			continue
+		}
+
+		available = true
+		if accept != nil && !accept(w) {
+			continue
+		}
+
+		matched = true
+		if w.reserve() {
+			p.nextWorker = (idx + 1) % len(p.workers)
+			return newReservedWorker(w), nil
+		}
+	}
+
+	// Workers that are still warming up can't be waited on as busy workers are
+	if !available {
+		return nil, ErrNoWorkersAvailable
+	}
+
+	if !matched {
+		return nil, ErrNoSubscribedWorkers
+	}
+
+	return nil, ErrAllWorkersBusy
+}
+
+// workerForTopic - Retrieves a worker subscribed to the topic, waiting on busy workers as GetWorker does
+func (p *ProcessPool) workerForTopic(topic string) (*reservedWorker, error) {
+	p.workerLock.Lock()
+	defer p.workerLock.Unlock()
+
+	queued := false
+	defer func() {
+		if queued {
+			p.pending--
+		}
+	}()
+
+	for {
+		wrkr, err := p.selectMatchingWorker(func(w *poolWorker) bool {
+			return w.subscribes(topic)
+		})
+		if err != ErrAllWorkersBusy || !p.blocking {
+			return wrkr, err
+		}
+
+		if err := p.queuePending(&queued); err != nil {
+			return nil, err
+		}
+
+		p.workerAvailable.Wait()
+	}
+}
+
+// queuePending - Counts the caller as waiting for a worker, unless it already is, the worker lock must be held.
+// ErrPoolSaturated is returned if the maximum number of callers are already waiting
+func (p *ProcessPool) queuePending(queued *bool) error {
+	if *queued {
+		return nil
+	}
+
+	if p.maxPending > 0 && p.pending >= p.maxPending {
+		return ErrPoolSaturated
+	}
+
+	p.pending++
+	*queued = true
+
+	return nil
+}
+
+// GetWorker - Retrieves a worker from this pool, workers are selected round-robin
+// If all workers are busy this will block until one is free for blocking pools, otherwise ErrAllWorkersBusy is returned.
+// ErrNoWorkersAvailable is returned if no workers have been added to the pool or none are ready
+// and ErrPoolSaturated if too many callers are already waiting.
+// The worker holds a concurrency slot until a trigger is dispatched to it or it's released with ReleaseWorker
 func (p *ProcessPool) GetWorker() (Worker, error) {
 	p.workerLock.Lock()
 	defer p.workerLock.Unlock()
 
-	if len(p.workers) > 0 {
-		return p.workers[0], nil
-	} else {
-		return nil, fmt.Errorf("no workers available in this pool")
+	queued := false
+	defer func() {
+		if queued {
+			p.pending--
+		}
+	}()
+
+	for {
+		wrkr, err := p.selectWorker()
+		if err != ErrAllWorkersBusy || !p.blocking {
+			return wrkr, err
+		}
+
+		if err := p.queuePending(&queued); err != nil {
+			return nil, err
+		}
+
+		p.workerAvailable.Wait()
+	}
+}
+
+// GetWorkerWait - Retrieves a worker from this pool, waiting for one to be added if the pool has no workers.
+// Busy workers are handled as they are by GetWorker and the context error is returned if it's done first
+func (p *ProcessPool) GetWorkerWait(ctx context.Context) (Worker, error) {
+	waiting := make(chan struct{})
+	defer close(waiting)
+
+	// Wake the waiter when the context is done, holding the lock so the wake up can't be missed
+	go func() {
+		select {
+		case <-ctx.Done():
+			p.workerLock.Lock()
+			p.workerAvailable.Broadcast()
+			p.workerLock.Unlock()
+		case <-waiting:
+		}
+	}()
+
+	p.workerLock.Lock()
+	defer p.workerLock.Unlock()
+
+	queued := false
+	defer func() {
+		if queued {
+			p.pending--
+		}
+	}()
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		wrkr, err := p.selectWorker()
+		if err != ErrNoWorkersAvailable && (err != ErrAllWorkersBusy || !p.blocking) {
+			return wrkr, err
+		}
+
+		if err := p.queuePending(&queued); err != nil {
+			return nil, err
+		}
+
+		p.workerAvailable.Wait()
+	}
+}
+
+// removeWorkerAt - Removes the worker at the given index, the worker lock must be held
+func (p *ProcessPool) removeWorkerAt(i int) {
+	// Workers drained by ID are removed intentionally, so dropping below the minimum isn't a pool error
+	drained := p.workers[i].draining
+
+	p.workers = append(p.workers[:i], p.workers[i+1:]...)
+	p.workerAvailable.Broadcast()
+	if !drained && len(p.workers) < p.minWorkers {
+		p.notifyPoolError(fmt.Errorf("insufficient workers in pool, need minimum of %d, %d available", p.minWorkers, len(p.workers)))
+	}
+}
+
+// notifyPoolError - Reports an error to the pool monitor without blocking, as the worker lock may be held.
+// If an error is already waiting to be monitored it's kept and this one is dropped
+func (p *ProcessPool) notifyPoolError(err error) {
+	select {
+	case p.poolErr <- err:
+	default:
 	}
 }
 
@@ -109,33 +411,227 @@ func (p *ProcessPool) RemoveWorker(wrkr Worker) error {
 	p.workerLock.Lock()
 	defer p.workerLock.Unlock()
 
+	// Workers retrieved from the pool are handed out with their reservation
+	if reserved, ok := wrkr.(*reservedWorker); ok {
+		wrkr = reserved.poolWorker
+	}
+
+	for i, w := range p.workers {
+		if wrkr == w.Worker || wrkr == w {
+			p.removeWorkerAt(i)
+			return nil
+		}
+	}
+
+	return fmt.Errorf("worker does not exist in this pool")
+}
+
+// RemoveWorkerByID - Drains the worker with the given ID, no new triggers are routed to the worker
+// and it is removed from the pool once the triggers it is currently handling complete
+func (p *ProcessPool) RemoveWorkerByID(id string) error {
+	p.workerLock.Lock()
+	defer p.workerLock.Unlock()
+
 	for i, w := range p.workers {
-		if wrkr == w {
-			p.workers = append(p.workers[:i], p.workers[i+1:]...)
-			if len(p.workers) < p.minWorkers {
-				p.poolErr <- fmt.Errorf("insufficient workers in pool, need minimum of %d, %d available", p.minWorkers, len(p.workers))
+		if w.id == id {
+			w.draining = true
+			if w.getInFlight() == 0 {
+				p.removeWorkerAt(i)
 			}
 
 			return nil
 		}
 	}
 
-	return fmt.Errorf("worker does not exist in this pool")
+	return fmt.Errorf("worker %s does not exist in this pool", id)
 }
 
-// AddWorker - Adds the given worker to this pool
+// ListWorkers - Returns the state of each worker in this pool, in the order they were added
+func (p *ProcessPool) ListWorkers() []WorkerInfo {
+	p.workerLock.Lock()
+	defer p.workerLock.Unlock()
+
+	infos := make([]WorkerInfo, 0, len(p.workers))
+	for _, w := range p.workers {
+		lastError, lastErrorAt := w.getLastError()
+		infos = append(infos, WorkerInfo{
+			ID:          w.id,
+			Busy:        w.isBusy(),
+			InFlight:    w.getInFlight(),
+			Draining:    w.draining,
+			Ready:       w.ready,
+			Handled:     w.getHandled(),
+			LastError:   lastError,
+			LastErrorAt: lastErrorAt,
+		})
+	}
+
+	return infos
+}
+
+// workerReleased - Called each time a worker completes a trigger, removing draining workers once they are idle
+func (p *ProcessPool) workerReleased(wrkr *poolWorker) {
+	// Hold the lock so the wake up can't be missed between a waiter checking the workers and beginning its wait
+	p.workerLock.Lock()
+	defer p.workerLock.Unlock()
+
+	if wrkr.draining && wrkr.getInFlight() == 0 {
+		for i, w := range p.workers {
+			if w == wrkr {
+				p.removeWorkerAt(i)
+				return
+			}
+		}
+	}
+
+	p.workerAvailable.Broadcast()
+}
+
+// identifiedWorker - A worker that has its own unique ID
+type identifiedWorker interface {
+	ID() string
+}
+
+// ReadinessWorker - A worker that signals when it's ready to handle triggers, e.g. once the function has warmed up
+type ReadinessWorker interface {
+	// OnReady - Registers a callback made once the worker is ready, immediately if it already is
+	OnReady(func())
+}
+
+// SubscribingWorker - A worker that only handles events for the topics it subscribes to,
+// workers that don't implement this handle events for all topics
+type SubscribingWorker interface {
+	// Subscribes - Returns true if the worker handles events for the topic
+	Subscribes(topic string) bool
+}
+
+// setWorkerReady - Marks the worker as ready to be selected
+func (p *ProcessPool) setWorkerReady(wrkr *poolWorker) {
+	p.workerLock.Lock()
+	defer p.workerLock.Unlock()
+
+	wrkr.ready = true
+	p.workerAvailable.Broadcast()
+}
+
+// AddWorker - Adds the given worker to this pool, returns a PoolStartupError if the worker could not be added
+// When the pool requires readiness, workers that signal readiness aren't selected until they are ready
 func (p *ProcessPool) AddWorker(wrkr Worker) error {
+	pw, err := p.addWorker(wrkr)
+	if err != nil {
+		return err
+	}
+
+	// Registered once the lock is released, as the callback may be made immediately
+	if readiness, ok := wrkr.(ReadinessWorker); ok && !pw.ready {
+		readiness.OnReady(func() {
+			p.setWorkerReady(pw)
+		})
+	}
+
+	return nil
+}
+
+// addWorker - Registers the worker with this pool, it's ready immediately unless it must signal readiness
+func (p *ProcessPool) addWorker(wrkr Worker) (*poolWorker, error) {
 	p.workerLock.Lock()
 	defer p.workerLock.Unlock()
 
+	if p.closed {
+		return nil, newPoolStartupError(PoolStartupError_WorkerRejected, "worker pool is shutting down")
+	}
+
+	for _, w := range p.workers {
+		if reserved, ok := wrkr.(*reservedWorker); ok {
+			wrkr = reserved.poolWorker
+		}
+
+		if wrkr == w.Worker || wrkr == w {
+			return nil, newPoolStartupError(PoolStartupError_DuplicateWorker, "worker already exists in this pool")
+		}
+	}
+
 	workerCount := len(p.workers)
 
 	// Ensure we haven't reached the maximum number of workers
-	if workerCount > p.maxWorkers {
-		return fmt.Errorf("max worker capacity reached! cannot add more workers")
+	if workerCount >= p.maxWorkers {
+		return nil, newPoolStartupError(PoolStartupError_PoolFull, "max worker capacity reached! cannot add more workers")
 	}
 
-	p.workers = append(p.workers, wrkr)
+	// Prefer the worker's own ID where it has one, so pool IDs match those in the worker's logs
+	id := uuid.New().String()
+	if identified, ok := wrkr.(identifiedWorker); ok && identified.ID() != "" {
+		id = identified.ID()
+	}
+
+	pw := newPoolWorker(wrkr, id, p.maxConcurrency, p.log, nil)
+	pw.onRelease = func() {
+		p.workerReleased(pw)
+	}
+	pw.route = p.workerForTopic
+
+	_, signalsReady := wrkr.(ReadinessWorker)
+	pw.ready = !(p.requireReady && signalsReady)
+
+	p.workers = append(p.workers, pw)
+	p.workerAvailable.Broadcast()
+
+	return pw, nil
+}
+
+// Shutdown - Stops handing out workers and waits for the triggers currently being handled to complete
+// If the timeout elapses all workers are removed from the pool regardless of in-flight triggers
+func (p *ProcessPool) Shutdown(timeout int) error {
+	p.workerLock.Lock()
+	p.closed = true
+	workers := make([]*poolWorker, len(p.workers))
+	copy(workers, p.workers)
+	p.workerLock.Unlock()
+
+	// Wake any callers waiting on a worker
+	p.workerAvailable.Broadcast()
+
+	// Stop workers that have already been handed out from accepting new triggers
+	for _, w := range workers {
+		w.close()
+	}
+
+	maxWaitTime := time.Duration(timeout) * time.Second
+	pollInterval := time.Duration(15) * time.Millisecond
+
+	var waitedTime = time.Duration(0)
+	for {
+		busy := false
+		for _, w := range workers {
+			if w.getInFlight() > 0 {
+				busy = true
+				break
+			}
+		}
+
+		if !busy || waitedTime >= maxWaitTime {
+			break
+		}
+
+		time.Sleep(pollInterval)
+		waitedTime += pollInterval
+	}
+
+	shutdownErrors := make([]error, 0)
+	for _, w := range workers {
+		if inFlight := w.getInFlight(); inFlight > 0 {
+			shutdownErrors = append(shutdownErrors, fmt.Errorf("worker %s still handling %d triggers", w.id, inFlight))
+		}
+	}
+
+	// Force remove any remaining workers
+	p.workerLock.Lock()
+	p.workers = make([]*poolWorker, 0)
+	p.workerLock.Unlock()
+
+	if len(shutdownErrors) > 0 {
+		return fmt.Errorf("timed out waiting for workers to finish: %v", shutdownErrors)
+	}
 
 	return nil
 }
@@ -146,11 +642,23 @@ func NewProcessPool(opts *ProcessPoolOptions) WorkerPool {
 		opts.MaxWorkers = 1
 	}
 
-	return &ProcessPool{
-		minWorkers: opts.MinWorkers,
-		maxWorkers: opts.MaxWorkers,
-		workerLock: sync.Mutex{},
-		workers:    make([]Worker, 0),
-		poolErr:    make(chan error),
+	if opts.Logger == nil {
+		opts.Logger = logger.NewNoopLogger()
 	}
+
+	pool := &ProcessPool{
+		minWorkers:     opts.MinWorkers,
+		maxWorkers:     opts.MaxWorkers,
+		maxConcurrency: opts.MaxConcurrency,
+		blocking:       opts.Blocking,
+		requireReady:   opts.RequireReady,
+		maxPending:     opts.MaxPending,
+		log:            opts.Logger,
+		workerLock:     sync.Mutex{},
+		workers:        make([]*poolWorker, 0),
+		poolErr:        make(chan error, 1),
+	}
+	pool.workerAvailable = sync.NewCond(&pool.workerLock)
+
+	return pool
 }
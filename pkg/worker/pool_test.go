@@ -0,0 +1,2172 @@
+// Copyright 2021 Nitric Pty Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package worker
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nitrictech/nitric/pkg/logger"
+	"github.com/nitrictech/nitric/pkg/plugins/document"
+	"github.com/nitrictech/nitric/pkg/plugins/errors"
+	"github.com/nitrictech/nitric/pkg/plugins/errors/codes"
+	"github.com/nitrictech/nitric/pkg/plugins/queue"
+	"github.com/nitrictech/nitric/pkg/plugins/storage"
+	"github.com/nitrictech/nitric/pkg/triggers"
+	mock_worker "github.com/nitrictech/nitric/tests/mocks/worker"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/valyala/fasthttp"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// blockingWorker - A worker that blocks handling triggers until released
+type blockingWorker struct {
+	started chan bool
+	release chan bool
+}
+
+func (b *blockingWorker) HandleEvent(trigger *triggers.Event) error {
+	b.started <- true
+	<-b.release
+	return nil
+}
+
+func (b *blockingWorker) HandleHttpRequest(trigger *triggers.HttpRequest) (*triggers.HttpResponse, error) {
+	b.started <- true
+	<-b.release
+	return &triggers.HttpResponse{StatusCode: 200}, nil
+}
+
+func (b *blockingWorker) HandleWebsocketMessage(trigger *triggers.WebsocketMessage) error {
+	b.started <- true
+	<-b.release
+	return nil
+}
+
+func (b *blockingWorker) HandleBucketNotification(trigger *triggers.BucketNotification) error {
+	b.started <- true
+	<-b.release
+	return nil
+}
+
+func (b *blockingWorker) HandleSchedule(trigger *triggers.Schedule) error {
+	b.started <- true
+	<-b.release
+	return nil
+}
+
+// warmingWorker - A worker that signals it's ready to handle triggers when told to
+type warmingWorker struct {
+	UnimplementedWorker
+	onReady func()
+}
+
+func (w *warmingWorker) OnReady(fn func()) {
+	w.onReady = fn
+}
+
+func (w *warmingWorker) signalReady() {
+	w.onReady()
+}
+
+// streamingWorker - A worker that responds to HTTP requests with a streamed body
+type streamingWorker struct {
+	UnimplementedWorker
+	body string
+}
+
+func (s *streamingWorker) HandleHttpRequest(trigger *triggers.HttpRequest) (*triggers.HttpResponse, error) {
+	return &triggers.HttpResponse{
+		StatusCode: 200,
+		BodyStream: ioutil.NopCloser(strings.NewReader(s.body)),
+	}, nil
+}
+
+// echoWorker - A worker that responds to HTTP requests with the request body, reading streamed bodies
+type echoWorker struct {
+	UnimplementedWorker
+}
+
+func (e *echoWorker) HandleHttpRequest(trigger *triggers.HttpRequest) (*triggers.HttpResponse, error) {
+	if err := trigger.BufferBody(); err != nil {
+		return nil, err
+	}
+
+	return &triggers.HttpResponse{
+		StatusCode: 200,
+		Body:       trigger.Body,
+	}, nil
+}
+
+// countingWorker - A worker that counts the HTTP requests it handles, blocking each until released if started is set
+type countingWorker struct {
+	UnimplementedWorker
+	lock       sync.Mutex
+	handled    int
+	statusCode int
+	started    chan bool
+	release    chan bool
+}
+
+func (c *countingWorker) HandleHttpRequest(trigger *triggers.HttpRequest) (*triggers.HttpResponse, error) {
+	if c.started != nil {
+		c.started <- true
+		<-c.release
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.handled++
+
+	return &triggers.HttpResponse{
+		StatusCode: c.statusCode,
+		Header:     &fasthttp.ResponseHeader{},
+		Body:       []byte(fmt.Sprintf("response %d", c.handled)),
+	}, nil
+}
+
+func (c *countingWorker) count() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.handled
+}
+
+// idempotencyDocuments - A document plugin storing documents in memory by ID
+type idempotencyDocuments struct {
+	document.UnimplementedDocumentPlugin
+	docs map[string]map[string]interface{}
+}
+
+func (d *idempotencyDocuments) Get(key *document.Key) (*document.Document, error) {
+	content, ok := d.docs[key.Id]
+	if !ok {
+		return nil, errors.ErrorsWithScope("idempotencyDocuments.Get", nil)(codes.NotFound, "document not found", nil)
+	}
+	return &document.Document{Key: key, Content: content}, nil
+}
+
+func (d *idempotencyDocuments) Set(key *document.Key, content map[string]interface{}) error {
+	d.docs[key.Id] = content
+	return nil
+}
+
+// failingIdempotencyStore - An idempotency store that has nothing stored and can't store responses
+type failingIdempotencyStore struct{}
+
+func (failingIdempotencyStore) Get(key string) (*CachedResponse, error) {
+	return nil, nil
+}
+
+func (failingIdempotencyStore) Put(key string, response *CachedResponse, ttl time.Duration) error {
+	return fmt.Errorf("store unavailable")
+}
+
+// deadlineWorker - A worker that records the deadline of the context it handles triggers within
+type deadlineWorker struct {
+	UnimplementedWorker
+	deadlines chan time.Time
+}
+
+func (d *deadlineWorker) handleHttpRequestWithContext(ctx context.Context, trigger *triggers.HttpRequest) (*triggers.HttpResponse, error) {
+	deadline, _ := ctx.Deadline()
+	d.deadlines <- deadline
+
+	return &triggers.HttpResponse{StatusCode: 200}, nil
+}
+
+func (d *deadlineWorker) handleEventWithContext(ctx context.Context, trigger *triggers.Event) error {
+	deadline, _ := ctx.Deadline()
+	d.deadlines <- deadline
+
+	return nil
+}
+
+// staticStorage - A storage plugin serving objects keyed by bucket/key from memory
+type staticStorage struct {
+	storage.UnimplementedStoragePlugin
+	objects  map[string][]byte
+	metadata map[string]map[string]string
+}
+
+func (s *staticStorage) Read(bucket string, key string) ([]byte, error) {
+	if object, ok := s.objects[bucket+"/"+key]; ok {
+		return object, nil
+	}
+
+	return nil, errors.ErrorsWithScope("staticStorage.Read", nil)(codes.NotFound, "object not found", nil)
+}
+
+func (s *staticStorage) GetMetadata(bucket string, key string) (map[string]string, error) {
+	return s.metadata[bucket+"/"+key], nil
+}
+
+// subscribedWorker - A worker that records the events it handles for the topics it subscribes to
+type subscribedWorker struct {
+	UnimplementedWorker
+	topic  string
+	events []string
+}
+
+func (s *subscribedWorker) Subscribes(topic string) bool {
+	return topic == s.topic
+}
+
+func (s *subscribedWorker) HandleEvent(trigger *triggers.Event) error {
+	s.events = append(s.events, trigger.Topic)
+	return nil
+}
+
+// recordingQueue - A queue plugin that records sent tasks
+type recordingQueue struct {
+	queue.UnimplementedQueuePlugin
+	sent map[string][]queue.NitricTask
+}
+
+func (q *recordingQueue) Send(queueName string, task queue.NitricTask) error {
+	q.sent[queueName] = append(q.sent[queueName], task)
+	return nil
+}
+
+// memoryQueue - A queue plugin holding tasks in memory, received tasks stay invisible until they're completed
+type memoryQueue struct {
+	queue.UnimplementedQueuePlugin
+	tasks  []queue.NitricTask
+	leased map[string]queue.NitricTask
+}
+
+func (q *memoryQueue) Send(queueName string, task queue.NitricTask) error {
+	q.tasks = append(q.tasks, task)
+	return nil
+}
+
+func (q *memoryQueue) Receive(options queue.ReceiveOptions) ([]queue.NitricTask, error) {
+	received := make([]queue.NitricTask, 0)
+	for len(q.tasks) > 0 && len(received) < int(*options.Depth) {
+		task := q.tasks[0]
+		q.tasks = q.tasks[1:]
+		task.LeaseID = fmt.Sprintf("lease-%d", len(q.leased))
+		q.leased[task.LeaseID] = task
+		received = append(received, task)
+	}
+
+	return received, nil
+}
+
+func (q *memoryQueue) Complete(queueName string, leaseId string) error {
+	delete(q.leased, leaseId)
+	return nil
+}
+
+func newBlockingWorker() *blockingWorker {
+	return &blockingWorker{
+		started: make(chan bool, 10),
+		release: make(chan bool),
+	}
+}
+
+var _ = Describe("ProcessPool", func() {
+	Context("Shutdown", func() {
+		When("There are no in-flight triggers", func() {
+			It("Should shutdown without error", func() {
+				pool := NewProcessPool(&ProcessPoolOptions{})
+				pool.AddWorker(mock_worker.NewMockWorker(&mock_worker.MockWorkerOptions{}))
+
+				Expect(pool.Shutdown(1)).ShouldNot(HaveOccurred())
+
+				By("No longer providing workers")
+				_, err := pool.GetWorker()
+				Expect(err).Should(HaveOccurred())
+
+				By("No longer accepting workers")
+				err = pool.AddWorker(mock_worker.NewMockWorker(&mock_worker.MockWorkerOptions{}))
+				Expect(err).Should(HaveOccurred())
+			})
+		})
+
+		When("A trigger completes before the timeout", func() {
+			It("Should wait for the trigger to complete", func() {
+				pool := NewProcessPool(&ProcessPoolOptions{})
+				bw := newBlockingWorker()
+				pool.AddWorker(bw)
+				wrkr, _ := pool.GetWorker()
+
+				done := make(chan error)
+				go func() {
+					done <- wrkr.HandleEvent(&triggers.Event{})
+				}()
+				<-bw.started
+
+				go func() {
+					bw.release <- true
+				}()
+
+				Expect(pool.Shutdown(1)).ShouldNot(HaveOccurred())
+				Expect(<-done).ShouldNot(HaveOccurred())
+			})
+		})
+
+		When("A trigger is still in-flight after the timeout", func() {
+			It("Should return an error", func() {
+				pool := NewProcessPool(&ProcessPoolOptions{})
+				bw := newBlockingWorker()
+				pool.AddWorker(bw)
+				wrkr, _ := pool.GetWorker()
+
+				go wrkr.HandleEvent(&triggers.Event{})
+				<-bw.started
+
+				err := pool.Shutdown(0)
+				Expect(err).Should(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("still handling 1 triggers"))
+				Expect(pool.GetWorkerCount()).To(Equal(0))
+
+				bw.release <- true
+			})
+		})
+
+		When("A worker was retrieved before shutdown", func() {
+			It("Should reject new triggers", func() {
+				pool := NewProcessPool(&ProcessPoolOptions{})
+				pool.AddWorker(mock_worker.NewMockWorker(&mock_worker.MockWorkerOptions{}))
+				wrkr, _ := pool.GetWorker()
+
+				Expect(pool.Shutdown(1)).ShouldNot(HaveOccurred())
+				Expect(wrkr.HandleEvent(&triggers.Event{})).Should(HaveOccurred())
+			})
+		})
+	})
+	Context("MaxConcurrency", func() {
+		When("A worker is handling its maximum concurrent triggers", func() {
+			It("Should serialize concurrent triggers", func() {
+				pool := NewProcessPool(&ProcessPoolOptions{
+					MaxConcurrency: 1,
+					Blocking:       true,
+				})
+				bw := newBlockingWorker()
+				pool.AddWorker(bw)
+
+				By("Starting the first trigger")
+				wrkr, _ := pool.GetWorker()
+				go wrkr.HandleEvent(&triggers.Event{})
+				<-bw.started
+
+				By("Starting the second trigger")
+				secondDone := make(chan bool)
+				go func() {
+					wrkr, _ := pool.GetWorker()
+					wrkr.HandleEvent(&triggers.Event{})
+					secondDone <- true
+				}()
+
+				Consistently(bw.started, "100ms").ShouldNot(Receive())
+
+				By("Completing the first trigger")
+				bw.release <- true
+				Eventually(bw.started).Should(Receive())
+
+				bw.release <- true
+				Eventually(secondDone).Should(Receive())
+			})
+		})
+
+		When("The pool is not blocking", func() {
+			It("Should return ErrAllWorkersBusy", func() {
+				pool := NewProcessPool(&ProcessPoolOptions{
+					MaxConcurrency: 1,
+				})
+				bw := newBlockingWorker()
+				pool.AddWorker(bw)
+
+				wrkr, _ := pool.GetWorker()
+				go wrkr.HandleEvent(&triggers.Event{})
+				<-bw.started
+
+				_, err := pool.GetWorker()
+				Expect(err).To(Equal(ErrAllWorkersBusy))
+
+				bw.release <- true
+			})
+		})
+
+		When("Workers are retrieved concurrently from a pool that is not blocking", func() {
+			It("Should hand out each free slot once and return ErrAllWorkersBusy", func() {
+				pool := NewProcessPool(&ProcessPoolOptions{
+					MaxConcurrency: 1,
+				})
+				pool.AddWorker(mock_worker.NewMockWorker(&mock_worker.MockWorkerOptions{}))
+
+				const callers = 10
+				results := make(chan error, callers)
+				start := make(chan struct{})
+				wg := sync.WaitGroup{}
+				for i := 0; i < callers; i++ {
+					wg.Add(1)
+					go func() {
+						defer wg.Done()
+						<-start
+						_, err := pool.GetWorker()
+						results <- err
+					}()
+				}
+				close(start)
+				wg.Wait()
+				close(results)
+
+				retrieved := 0
+				for err := range results {
+					if err == nil {
+						retrieved++
+						continue
+					}
+					Expect(err).To(Equal(ErrAllWorkersBusy))
+				}
+				Expect(retrieved).To(Equal(1))
+			})
+		})
+
+		When("A retrieved worker is released without handling a trigger", func() {
+			It("Should return its reserved slot to the pool", func() {
+				pool := NewProcessPool(&ProcessPoolOptions{
+					MaxConcurrency: 1,
+				})
+				pool.AddWorker(mock_worker.NewMockWorker(&mock_worker.MockWorkerOptions{}))
+
+				wrkr, err := pool.GetWorker()
+				Expect(err).ShouldNot(HaveOccurred())
+
+				_, err = pool.GetWorker()
+				Expect(err).To(Equal(ErrAllWorkersBusy))
+
+				ReleaseWorker(wrkr)
+
+				wrkr, err = pool.GetWorker()
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(wrkr.HandleEvent(&triggers.Event{})).To(Succeed())
+
+				By("Not releasing the slot twice once it has been used")
+				ReleaseWorker(wrkr)
+				_, err = pool.GetWorker()
+				Expect(err).ShouldNot(HaveOccurred())
+				_, err = pool.GetWorker()
+				Expect(err).To(Equal(ErrAllWorkersBusy))
+			})
+		})
+
+		When("The maximum number of triggers are waiting for a worker", func() {
+			It("Should shed additional triggers with ErrPoolSaturated", func() {
+				pool := NewProcessPool(&ProcessPoolOptions{
+					MaxConcurrency: 1,
+					Blocking:       true,
+					MaxPending:     1,
+				})
+				bw := newBlockingWorker()
+				pool.AddWorker(bw)
+
+				wrkr, _ := pool.GetWorker()
+				go wrkr.HandleEvent(&triggers.Event{})
+				<-bw.started
+
+				By("Queueing a second trigger")
+				secondDone := make(chan bool)
+				go func() {
+					wrkr, _ := pool.GetWorker()
+					wrkr.HandleEvent(&triggers.Event{})
+					secondDone <- true
+				}()
+				Eventually(pool.GetPendingCount).Should(Equal(1))
+
+				By("Rejecting a third trigger")
+				_, err := pool.GetWorker()
+				Expect(err).To(Equal(ErrPoolSaturated))
+				Expect(errors.Code(err)).To(Equal(codes.ResourceExhausted))
+
+				_, err = pool.GetWorkerWait(context.Background())
+				Expect(err).To(Equal(ErrPoolSaturated))
+
+				By("Releasing the queued trigger's place once it has a worker")
+				bw.release <- true
+				Eventually(bw.started).Should(Receive())
+				Expect(pool.GetPendingCount()).To(Equal(0))
+
+				bw.release <- true
+				Eventually(secondDone).Should(Receive())
+			})
+		})
+	})
+	Context("GetWorker", func() {
+		When("There are multiple workers in the pool", func() {
+			It("Should distribute triggers across all workers", func() {
+				pool := NewProcessPool(&ProcessPoolOptions{
+					MaxWorkers: 3,
+				})
+				mockWorkers := make([]*mock_worker.MockWorker, 0)
+				for i := 0; i < 3; i++ {
+					mw := mock_worker.NewMockWorker(&mock_worker.MockWorkerOptions{})
+					mockWorkers = append(mockWorkers, mw)
+					Expect(pool.AddWorker(mw)).ShouldNot(HaveOccurred())
+				}
+				Expect(pool.GetWorkerCount()).To(Equal(3))
+
+				for i := 0; i < 6; i++ {
+					wrkr, err := pool.GetWorker()
+					Expect(err).ShouldNot(HaveOccurred())
+					wrkr.HandleEvent(&triggers.Event{})
+				}
+
+				for _, mw := range mockWorkers {
+					Expect(mw.ReceivedEvents).To(HaveLen(2))
+				}
+			})
+		})
+
+		When("Dispatching a websocket message", func() {
+			It("Should be handled by the pooled worker", func() {
+				pool := NewProcessPool(&ProcessPoolOptions{})
+				mw := mock_worker.NewMockWorker(&mock_worker.MockWorkerOptions{})
+				pool.AddWorker(mw)
+
+				wrkr, err := pool.GetWorker()
+				Expect(err).ShouldNot(HaveOccurred())
+
+				err = wrkr.HandleWebsocketMessage(&triggers.WebsocketMessage{
+					ConnectionID: "test-connection",
+					EventType:    triggers.WebsocketEventType_Message,
+					Payload:      []byte("hello"),
+				})
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(mw.ReceivedMessages).To(HaveLen(1))
+				Expect(mw.ReceivedMessages[0].ConnectionID).To(Equal("test-connection"))
+			})
+		})
+	})
+	Context("GetWorkerWait", func() {
+		When("No workers have been added", func() {
+			It("GetWorker should return ErrNoWorkersAvailable", func() {
+				pool := NewProcessPool(&ProcessPoolOptions{})
+
+				_, err := pool.GetWorker()
+				Expect(err).To(Equal(ErrNoWorkersAvailable))
+			})
+		})
+
+		When("A worker is added while waiting", func() {
+			It("Should return the added worker", func() {
+				pool := NewProcessPool(&ProcessPoolOptions{})
+				mw := mock_worker.NewMockWorker(&mock_worker.MockWorkerOptions{})
+
+				result := make(chan error, 1)
+				var wrkr Worker
+				go func() {
+					var err error
+					wrkr, err = pool.GetWorkerWait(context.Background())
+					result <- err
+				}()
+
+				By("Waiting until a worker is added")
+				Consistently(result, "100ms").ShouldNot(Receive())
+
+				Expect(pool.AddWorker(mw)).To(Succeed())
+
+				Eventually(result).Should(Receive(BeNil()))
+				wrkr.HandleEvent(&triggers.Event{ID: "test"})
+				Expect(mw.ReceivedEvents).To(HaveLen(1))
+			})
+		})
+
+		When("The context is cancelled while waiting", func() {
+			It("Should return the context error", func() {
+				pool := NewProcessPool(&ProcessPoolOptions{})
+				ctx, cancel := context.WithCancel(context.Background())
+
+				result := make(chan error, 1)
+				go func() {
+					_, err := pool.GetWorkerWait(ctx)
+					result <- err
+				}()
+
+				Consistently(result, "50ms").ShouldNot(Receive())
+				cancel()
+
+				Eventually(result).Should(Receive(Equal(context.Canceled)))
+			})
+		})
+
+		When("The pool is shut down while waiting", func() {
+			It("Should return ErrPoolShuttingDown", func() {
+				pool := NewProcessPool(&ProcessPoolOptions{})
+
+				result := make(chan error, 1)
+				go func() {
+					_, err := pool.GetWorkerWait(context.Background())
+					result <- err
+				}()
+
+				Consistently(result, "50ms").ShouldNot(Receive())
+				Expect(pool.Shutdown(1)).To(Succeed())
+
+				Eventually(result).Should(Receive(Equal(ErrPoolShuttingDown)))
+			})
+		})
+	})
+
+	Context("WithRequestTimeout", func() {
+		When("A HTTP request is not handled before the timeout", func() {
+			It("Should return a 504 response", func() {
+				pool := NewProcessPool(&ProcessPoolOptions{})
+				wrkr := newBlockingWorker()
+				pool.AddWorker(wrkr)
+				defer close(wrkr.release)
+
+				decorated := NewDecoratedPool(pool, WithRequestTimeout(50*time.Millisecond))
+				w, err := decorated.GetWorker()
+				Expect(err).ShouldNot(HaveOccurred())
+
+				resp, err := w.HandleHttpRequest(&triggers.HttpRequest{})
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(resp.StatusCode).To(Equal(504))
+			})
+		})
+
+		When("An event is not handled before the timeout", func() {
+			It("Should return an error", func() {
+				pool := NewProcessPool(&ProcessPoolOptions{})
+				wrkr := newBlockingWorker()
+				pool.AddWorker(wrkr)
+				defer close(wrkr.release)
+
+				decorated := NewDecoratedPool(pool, WithRequestTimeout(50*time.Millisecond))
+				w, err := decorated.GetWorker()
+				Expect(err).ShouldNot(HaveOccurred())
+
+				err = w.HandleEvent(&triggers.Event{ID: "test", Topic: "test"})
+				Expect(err).Should(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("was not handled within"))
+			})
+		})
+
+		When("A HTTP request has a deadline", func() {
+			It("Should pass the deadline to the worker in its context", func() {
+				pool := NewProcessPool(&ProcessPoolOptions{})
+				wrkr := &deadlineWorker{deadlines: make(chan time.Time, 1)}
+				pool.AddWorker(wrkr)
+
+				decorated := NewDecoratedPool(pool, WithRequestTimeout(0))
+				w, err := decorated.GetWorker()
+				Expect(err).ShouldNot(HaveOccurred())
+
+				deadline := time.Now().Add(time.Minute)
+				resp, err := w.HandleHttpRequest(&triggers.HttpRequest{Deadline: deadline})
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(resp.StatusCode).To(Equal(200))
+
+				Expect(<-wrkr.deadlines).To(BeTemporally("==", deadline))
+			})
+
+			It("Should pass the timeout to the worker when it's earlier than the deadline", func() {
+				pool := NewProcessPool(&ProcessPoolOptions{})
+				wrkr := &deadlineWorker{deadlines: make(chan time.Time, 1)}
+				pool.AddWorker(wrkr)
+
+				decorated := NewDecoratedPool(pool, WithRequestTimeout(time.Second))
+				w, err := decorated.GetWorker()
+				Expect(err).ShouldNot(HaveOccurred())
+
+				_, err = w.HandleHttpRequest(&triggers.HttpRequest{Deadline: time.Now().Add(time.Minute)})
+				Expect(err).ShouldNot(HaveOccurred())
+
+				Expect(<-wrkr.deadlines).To(BeTemporally("~", time.Now().Add(time.Second), 100*time.Millisecond))
+			})
+
+			It("Should return a 504 response if the worker doesn't respond before the deadline", func() {
+				pool := NewProcessPool(&ProcessPoolOptions{})
+				wrkr := newBlockingWorker()
+				pool.AddWorker(wrkr)
+				defer close(wrkr.release)
+
+				decorated := NewDecoratedPool(pool, WithRequestTimeout(0))
+				w, err := decorated.GetWorker()
+				Expect(err).ShouldNot(HaveOccurred())
+
+				resp, err := w.HandleHttpRequest(&triggers.HttpRequest{Deadline: time.Now().Add(50 * time.Millisecond)})
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(resp.StatusCode).To(Equal(504))
+				Expect(string(resp.Body)).To(ContainSubstring("before its deadline"))
+			})
+
+			It("Should not dispatch a request whose deadline has passed", func() {
+				pool := NewProcessPool(&ProcessPoolOptions{})
+				wrkr := &deadlineWorker{deadlines: make(chan time.Time, 1)}
+				pool.AddWorker(wrkr)
+
+				decorated := NewDecoratedPool(pool, WithRequestTimeout(0))
+				w, err := decorated.GetWorker()
+				Expect(err).ShouldNot(HaveOccurred())
+
+				resp, err := w.HandleHttpRequest(&triggers.HttpRequest{Deadline: time.Now().Add(-time.Second)})
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(resp.StatusCode).To(Equal(504))
+				Expect(wrkr.deadlines).ToNot(Receive())
+			})
+		})
+	})
+
+	Context("WithBodyLimits", func() {
+		When("A streamed response body exceeds the limit", func() {
+			It("Should fail the stream after the limit", func() {
+				pool := NewProcessPool(&ProcessPoolOptions{})
+				pool.AddWorker(&streamingWorker{body: "0123456789"})
+
+				decorated := NewDecoratedPool(pool, WithBodyLimits(0, 4))
+				w, err := decorated.GetWorker()
+				Expect(err).ShouldNot(HaveOccurred())
+
+				resp, err := w.HandleHttpRequest(&triggers.HttpRequest{})
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(resp.IsStreamed()).To(BeTrue())
+
+				body, err := ioutil.ReadAll(resp.BodyStream)
+				Expect(err).Should(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("exceeds the maximum size of 4 bytes"))
+				Expect(string(body)).To(Equal("0123"))
+			})
+		})
+
+		When("A streamed response body is within the limit", func() {
+			It("Should stream the full body", func() {
+				pool := NewProcessPool(&ProcessPoolOptions{})
+				pool.AddWorker(&streamingWorker{body: "0123"})
+
+				decorated := NewDecoratedPool(pool, WithBodyLimits(0, 4))
+				w, err := decorated.GetWorker()
+				Expect(err).ShouldNot(HaveOccurred())
+
+				resp, err := w.HandleHttpRequest(&triggers.HttpRequest{})
+				Expect(err).ShouldNot(HaveOccurred())
+
+				body, err := ioutil.ReadAll(resp.BodyStream)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(string(body)).To(Equal("0123"))
+			})
+		})
+	})
+
+	Context("WithCompression", func() {
+		When("The request accepts gzip encoding", func() {
+			It("Should return a gzip encoded response", func() {
+				body := strings.Repeat("compressible ", 100)
+				pool := NewProcessPool(&ProcessPoolOptions{})
+				pool.AddWorker(mock_worker.NewMockWorker(&mock_worker.MockWorkerOptions{
+					ReturnHttp: &triggers.HttpResponse{
+						StatusCode: 200,
+						Body:       []byte(body),
+					},
+				}))
+
+				decorated := NewDecoratedPool(pool, WithCompression(DefaultCompressionMinBytes))
+				w, err := decorated.GetWorker()
+				Expect(err).ShouldNot(HaveOccurred())
+
+				resp, err := w.HandleHttpRequest(&triggers.HttpRequest{
+					Header: map[string][]string{
+						"Accept-Encoding": {"deflate;q=0.5, gzip"},
+					},
+				})
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(string(resp.Header.Peek("Content-Encoding"))).To(Equal("gzip"))
+
+				By("Returning a gzip decodable body")
+				reader, err := gzip.NewReader(bytes.NewReader(resp.Body))
+				Expect(err).ShouldNot(HaveOccurred())
+				decoded, err := ioutil.ReadAll(reader)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(string(decoded)).To(Equal(body))
+			})
+		})
+
+		When("The response is below the minimum size", func() {
+			It("Should return the response uncompressed", func() {
+				pool := NewProcessPool(&ProcessPoolOptions{})
+				pool.AddWorker(mock_worker.NewMockWorker(&mock_worker.MockWorkerOptions{
+					ReturnHttp: &triggers.HttpResponse{
+						StatusCode: 200,
+						Body:       []byte("small"),
+					},
+				}))
+
+				decorated := NewDecoratedPool(pool, WithCompression(DefaultCompressionMinBytes))
+				w, err := decorated.GetWorker()
+				Expect(err).ShouldNot(HaveOccurred())
+
+				resp, err := w.HandleHttpRequest(&triggers.HttpRequest{
+					Header: map[string][]string{
+						"Accept-Encoding": {"gzip"},
+					},
+				})
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(string(resp.Body)).To(Equal("small"))
+			})
+		})
+
+		When("The response content is already compressed", func() {
+			It("Should return the response uncompressed", func() {
+				header := &fasthttp.ResponseHeader{}
+				header.SetContentType("image/png")
+				body := strings.Repeat("png", 1000)
+
+				pool := NewProcessPool(&ProcessPoolOptions{})
+				pool.AddWorker(mock_worker.NewMockWorker(&mock_worker.MockWorkerOptions{
+					ReturnHttp: &triggers.HttpResponse{
+						Header:     header,
+						StatusCode: 200,
+						Body:       []byte(body),
+					},
+				}))
+
+				decorated := NewDecoratedPool(pool, WithCompression(DefaultCompressionMinBytes))
+				w, err := decorated.GetWorker()
+				Expect(err).ShouldNot(HaveOccurred())
+
+				resp, err := w.HandleHttpRequest(&triggers.HttpRequest{
+					Header: map[string][]string{
+						"Accept-Encoding": {"gzip"},
+					},
+				})
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(resp.Header.Peek("Content-Encoding")).To(BeEmpty())
+				Expect(string(resp.Body)).To(Equal(body))
+			})
+		})
+	})
+
+	Context("WithDefaultContentType", func() {
+		newDecoratedWorker := func(response *triggers.HttpResponse, sniff bool) Worker {
+			pool := NewProcessPool(&ProcessPoolOptions{})
+			pool.AddWorker(mock_worker.NewMockWorker(&mock_worker.MockWorkerOptions{
+				ReturnHttp: response,
+			}))
+
+			decorated := NewDecoratedPool(pool, WithDefaultContentType("application/octet-stream", sniff))
+			w, err := decorated.GetWorker()
+			Expect(err).ShouldNot(HaveOccurred())
+
+			return w
+		}
+
+		When("The response has no content type", func() {
+			It("Should set the default content type", func() {
+				w := newDecoratedWorker(&triggers.HttpResponse{
+					StatusCode: 200,
+					Body:       []byte("<html><body>Hello</body></html>"),
+				}, false)
+
+				resp, err := w.HandleHttpRequest(&triggers.HttpRequest{})
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(string(resp.Header.ContentType())).To(Equal("application/octet-stream"))
+			})
+		})
+
+		When("The response has a content type", func() {
+			It("Should keep the content type", func() {
+				header := &fasthttp.ResponseHeader{}
+				header.SetContentType("application/json")
+
+				w := newDecoratedWorker(&triggers.HttpResponse{
+					Header:     header,
+					StatusCode: 200,
+					Body:       []byte("<html><body>Hello</body></html>"),
+				}, true)
+
+				resp, err := w.HandleHttpRequest(&triggers.HttpRequest{})
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(string(resp.Header.ContentType())).To(Equal("application/json"))
+			})
+		})
+
+		When("Sniffing a recognized body", func() {
+			It("Should set the detected content type", func() {
+				w := newDecoratedWorker(&triggers.HttpResponse{
+					StatusCode: 200,
+					Body:       []byte("\x89PNG\x0D\x0A\x1A\x0A" + strings.Repeat("\x00", 600)),
+				}, true)
+
+				resp, err := w.HandleHttpRequest(&triggers.HttpRequest{})
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(string(resp.Header.ContentType())).To(Equal("image/png"))
+			})
+		})
+
+		When("Sniffing an unrecognized body", func() {
+			It("Should set the default content type", func() {
+				w := newDecoratedWorker(&triggers.HttpResponse{
+					StatusCode: 200,
+					Body:       []byte{0x00, 0x01, 0x02, 0x03},
+				}, true)
+
+				resp, err := w.HandleHttpRequest(&triggers.HttpRequest{})
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(string(resp.Header.ContentType())).To(Equal("application/octet-stream"))
+			})
+		})
+
+		When("The response has no content", func() {
+			It("Should not set a content type", func() {
+				w := newDecoratedWorker(&triggers.HttpResponse{
+					StatusCode: 204,
+				}, true)
+
+				resp, err := w.HandleHttpRequest(&triggers.HttpRequest{})
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(resp.Header).To(BeNil())
+			})
+		})
+	})
+
+	Context("WithHttpLogging", func() {
+		var out *bytes.Buffer
+		var log logger.Logger
+
+		BeforeEach(func() {
+			out = &bytes.Buffer{}
+			log = logger.NewJSONLogger(out, logger.Level_Debug)
+		})
+
+		When("Logging a request and response with sensitive values", func() {
+			It("Should never log the redacted values", func() {
+				header := &fasthttp.ResponseHeader{}
+				header.Set("Set-Cookie", "session=response-session")
+				header.Set("X-Api-Key", "response-api-key")
+
+				pool := NewProcessPool(&ProcessPoolOptions{})
+				pool.AddWorker(mock_worker.NewMockWorker(&mock_worker.MockWorkerOptions{
+					ReturnHttp: &triggers.HttpResponse{
+						Header:     header,
+						StatusCode: 200,
+						Body:       []byte(`{"id":"1","token":"response-token"}`),
+					},
+				}))
+
+				policy, err := NewHttpLogPolicy(&HttpLoggingOptions{
+					RedactHeaders:  []string{"x-api-key"},
+					RedactBodyKeys: "(?i)password|token",
+				})
+				Expect(err).ShouldNot(HaveOccurred())
+
+				w, err := NewDecoratedPool(pool, WithHttpLogging(policy, log)).GetWorker()
+				Expect(err).ShouldNot(HaveOccurred())
+
+				_, err = w.HandleHttpRequest(&triggers.HttpRequest{
+					Method: "POST",
+					Path:   "/login",
+					Header: map[string][]string{
+						"Authorization": {"Bearer request-bearer"},
+						"X-Api-Key":     {"request-api-key"},
+						"Content-Type":  {"application/json"},
+					},
+					Body: []byte(`{"user":"alice","Password":"request-password","nested":[{"accessToken":"request-token"}]}`),
+				})
+				Expect(err).ShouldNot(HaveOccurred())
+
+				logs := out.String()
+				for _, secret := range []string{"request-bearer", "request-api-key", "request-password", "request-token", "response-session", "response-api-key", "response-token"} {
+					Expect(logs).ToNot(ContainSubstring(secret))
+				}
+
+				By("Logging the values that aren't redacted")
+				Expect(logs).To(ContainSubstring("alice"))
+				Expect(logs).To(ContainSubstring("application/json"))
+				Expect(logs).To(ContainSubstring(redactedValue))
+			})
+		})
+
+		When("A streamed request is longer than the preview", func() {
+			It("Should pass the whole body to the worker and not log the truncated value", func() {
+				body := `{"user":"alice","password":"request-password-that-is-truncated"}`
+
+				pool := NewProcessPool(&ProcessPoolOptions{})
+				pool.AddWorker(&echoWorker{})
+
+				policy, err := NewHttpLogPolicy(&HttpLoggingOptions{
+					RedactBodyKeys: "password",
+					BodyBytes:      40,
+				})
+				Expect(err).ShouldNot(HaveOccurred())
+
+				w, err := NewDecoratedPool(pool, WithHttpLogging(policy, log)).GetWorker()
+				Expect(err).ShouldNot(HaveOccurred())
+
+				resp, err := w.HandleHttpRequest(&triggers.HttpRequest{
+					Method:           "POST",
+					Path:             "/login",
+					BodyStream:       ioutil.NopCloser(strings.NewReader(body)),
+					BodyStreamLength: int64(len(body)),
+				})
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(string(resp.Body)).To(Equal(body))
+
+				Expect(out.String()).ToNot(ContainSubstring("request-pass"))
+				Expect(out.String()).To(ContainSubstring("alice"))
+			})
+		})
+
+		When("The response is streamed", func() {
+			It("Should log the response once it's read", func() {
+				pool := NewProcessPool(&ProcessPoolOptions{})
+				pool.AddWorker(&streamingWorker{body: "streamed response"})
+
+				policy, err := NewHttpLogPolicy(&HttpLoggingOptions{})
+				Expect(err).ShouldNot(HaveOccurred())
+
+				w, err := NewDecoratedPool(pool, WithHttpLogging(policy, log)).GetWorker()
+				Expect(err).ShouldNot(HaveOccurred())
+
+				resp, err := w.HandleHttpRequest(&triggers.HttpRequest{Method: "GET", Path: "/"})
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(out.String()).ToNot(ContainSubstring("http response"))
+
+				Expect(resp.BufferBody()).To(Succeed())
+				Expect(string(resp.Body)).To(Equal("streamed response"))
+				Expect(out.String()).To(ContainSubstring("http response"))
+				Expect(out.String()).To(ContainSubstring("streamed response"))
+			})
+		})
+	})
+
+	Context("NewHttpLogPolicy", func() {
+		When("The body key pattern is invalid", func() {
+			It("Should return an error", func() {
+				_, err := NewHttpLogPolicy(&HttpLoggingOptions{RedactBodyKeys: "("})
+				Expect(err).Should(HaveOccurred())
+			})
+		})
+	})
+
+	Context("WithStaticFiles", func() {
+		var w Worker
+
+		BeforeEach(func() {
+			pool := NewProcessPool(&ProcessPoolOptions{})
+			pool.AddWorker(&echoWorker{})
+
+			plugin := &staticStorage{
+				objects: map[string][]byte{
+					"site-assets/css/main.css": []byte("body {}"),
+					"site-assets/index.html":   []byte("<html></html>"),
+					"site-assets/logo":         []byte("logo"),
+				},
+				metadata: map[string]map[string]string{
+					"site-assets/logo": {"content-type": "image/png", "cache-control": "max-age=3600"},
+				},
+			}
+
+			decorated := NewDecoratedPool(pool, WithStaticFiles(plugin, map[string]string{"/assets/": "site-assets"}))
+
+			var err error
+			w, err = decorated.GetWorker()
+			Expect(err).ShouldNot(HaveOccurred())
+		})
+
+		When("An object under the prefix is requested", func() {
+			It("Should serve the object without invoking the worker", func() {
+				resp, err := w.HandleHttpRequest(&triggers.HttpRequest{Method: "GET", Path: "/assets/css/main.css", Body: []byte("worker")})
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(resp.StatusCode).To(Equal(200))
+				Expect(string(resp.Body)).To(Equal("body {}"))
+				Expect(string(resp.Header.ContentType())).To(HavePrefix("text/css"))
+			})
+
+			It("Should use the content type and cache control of the object's metadata", func() {
+				resp, err := w.HandleHttpRequest(&triggers.HttpRequest{Method: "GET", Path: "/assets/logo"})
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(string(resp.Header.ContentType())).To(Equal("image/png"))
+				Expect(string(resp.Header.Peek("Cache-Control"))).To(Equal("max-age=3600"))
+			})
+
+			It("Should serve index.html for the prefix", func() {
+				resp, err := w.HandleHttpRequest(&triggers.HttpRequest{Method: "GET", Path: "/assets"})
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(string(resp.Body)).To(Equal("<html></html>"))
+			})
+
+			It("Should resolve dot segments within the prefix", func() {
+				resp, err := w.HandleHttpRequest(&triggers.HttpRequest{Method: "GET", Path: "/assets/../../css/main.css"})
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(string(resp.Body)).To(Equal("body {}"))
+			})
+
+			It("Should omit the body of HEAD requests", func() {
+				resp, err := w.HandleHttpRequest(&triggers.HttpRequest{Method: "HEAD", Path: "/assets/css/main.css"})
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(resp.StatusCode).To(Equal(200))
+				Expect(resp.Body).To(BeEmpty())
+			})
+		})
+
+		When("The object doesn't exist", func() {
+			It("Should respond with a 404", func() {
+				resp, err := w.HandleHttpRequest(&triggers.HttpRequest{Method: "GET", Path: "/assets/missing.js"})
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(resp.StatusCode).To(Equal(404))
+			})
+		})
+
+		When("The request isn't static", func() {
+			It("Should dispatch paths outside the prefix to the worker", func() {
+				resp, err := w.HandleHttpRequest(&triggers.HttpRequest{Method: "GET", Path: "/assets-api", Body: []byte("worker")})
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(string(resp.Body)).To(Equal("worker"))
+			})
+
+			It("Should dispatch other methods to the worker", func() {
+				resp, err := w.HandleHttpRequest(&triggers.HttpRequest{Method: "POST", Path: "/assets/css/main.css", Body: []byte("worker")})
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(string(resp.Body)).To(Equal("worker"))
+			})
+		})
+	})
+
+	Context("ParseStaticRoutes", func() {
+		It("Should parse prefix=bucket pairs", func() {
+			routes, err := ParseStaticRoutes("/assets=site-assets, /=site")
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(routes).To(Equal(map[string]string{"/assets": "site-assets", "/": "site"}))
+		})
+
+		It("Should reject entries without a bucket", func() {
+			_, err := ParseStaticRoutes("/assets")
+			Expect(err).Should(HaveOccurred())
+		})
+	})
+
+	Context("WithIdempotency", func() {
+		var inner *countingWorker
+		var w Worker
+
+		idempotentRequest := func(path string, key string) *triggers.HttpRequest {
+			return &triggers.HttpRequest{
+				Method: "POST",
+				Path:   path,
+				Header: map[string][]string{"idempotency-key": {key}},
+			}
+		}
+
+		BeforeEach(func() {
+			inner = &countingWorker{statusCode: 201}
+			w = WithIdempotency(NewMemoryIdempotencyStore(10), time.Minute, logger.NewNoopLogger())(inner)
+		})
+
+		When("A request with an idempotency key is retried", func() {
+			It("Should replay the stored response without invoking the worker", func() {
+				first, err := w.HandleHttpRequest(idempotentRequest("/orders", "abc"))
+				Expect(err).ShouldNot(HaveOccurred())
+
+				replayed, err := w.HandleHttpRequest(idempotentRequest("/orders", "abc"))
+				Expect(err).ShouldNot(HaveOccurred())
+
+				Expect(inner.count()).To(Equal(1))
+				Expect(replayed.StatusCode).To(Equal(201))
+				Expect(replayed.Body).To(Equal(first.Body))
+				Expect(string(replayed.Header.Peek(IdempotentReplayedHeader))).To(Equal("true"))
+				Expect(first.Header.Peek(IdempotentReplayedHeader)).To(BeEmpty())
+			})
+
+			It("Should handle requests with a different path or key", func() {
+				_, _ = w.HandleHttpRequest(idempotentRequest("/orders", "abc"))
+				_, _ = w.HandleHttpRequest(idempotentRequest("/refunds", "abc"))
+				_, _ = w.HandleHttpRequest(idempotentRequest("/orders", "def"))
+
+				Expect(inner.count()).To(Equal(3))
+			})
+		})
+
+		When("Requests don't have an idempotency key", func() {
+			It("Should handle every request", func() {
+				_, _ = w.HandleHttpRequest(&triggers.HttpRequest{Method: "POST", Path: "/orders"})
+				_, _ = w.HandleHttpRequest(&triggers.HttpRequest{Method: "POST", Path: "/orders"})
+
+				Expect(inner.count()).To(Equal(2))
+			})
+		})
+
+		When("The worker responds with a server error", func() {
+			It("Should handle the retry", func() {
+				inner.statusCode = 503
+				_, _ = w.HandleHttpRequest(idempotentRequest("/orders", "abc"))
+				_, _ = w.HandleHttpRequest(idempotentRequest("/orders", "abc"))
+
+				Expect(inner.count()).To(Equal(2))
+			})
+		})
+
+		When("The stored response has expired", func() {
+			It("Should handle the retry", func() {
+				w = WithIdempotency(NewMemoryIdempotencyStore(10), time.Millisecond, logger.NewNoopLogger())(inner)
+				_, _ = w.HandleHttpRequest(idempotentRequest("/orders", "abc"))
+				time.Sleep(5 * time.Millisecond)
+				_, _ = w.HandleHttpRequest(idempotentRequest("/orders", "abc"))
+
+				Expect(inner.count()).To(Equal(2))
+			})
+		})
+
+		When("Requests with the same key are handled concurrently", func() {
+			It("Should wait for the first to complete and replay its response", func() {
+				inner.started = make(chan bool, 2)
+				inner.release = make(chan bool)
+
+				// Workers decorated by the same decorator share the requests in flight
+				decorate := WithIdempotency(NewMemoryIdempotencyStore(10), time.Minute, logger.NewNoopLogger())
+				responses := make(chan *triggers.HttpResponse, 2)
+				for i := 0; i < 2; i++ {
+					wrkr := decorate(inner)
+					go func() {
+						defer GinkgoRecover()
+						resp, err := wrkr.HandleHttpRequest(idempotentRequest("/orders", "abc"))
+						Expect(err).ShouldNot(HaveOccurred())
+						responses <- resp
+					}()
+				}
+
+				Eventually(inner.started).Should(Receive())
+				Consistently(inner.started, 50*time.Millisecond).ShouldNot(Receive())
+				close(inner.release)
+
+				first, second := <-responses, <-responses
+				Expect(inner.count()).To(Equal(1))
+				Expect(first.Body).To(Equal(second.Body))
+			})
+		})
+
+		When("Responses are stored with the document plugin", func() {
+			It("Should replay them from the stored documents", func() {
+				documents := &idempotencyDocuments{docs: make(map[string]map[string]interface{})}
+				store := NewDocumentIdempotencyStore(documents)
+				_, _ = WithIdempotency(store, time.Minute, logger.NewNoopLogger())(inner).HandleHttpRequest(idempotentRequest("/orders", "abc"))
+
+				// Another membrane sharing the document plugin
+				replayed, err := WithIdempotency(NewDocumentIdempotencyStore(documents), time.Minute, logger.NewNoopLogger())(inner).HandleHttpRequest(idempotentRequest("/orders", "abc"))
+				Expect(err).ShouldNot(HaveOccurred())
+
+				Expect(documents.docs).To(HaveLen(1))
+				Expect(inner.count()).To(Equal(1))
+				Expect(string(replayed.Body)).To(Equal("response 1"))
+			})
+		})
+
+		When("The response can't be stored", func() {
+			It("Should return the response and log a warning", func() {
+				out := &bytes.Buffer{}
+				w = WithIdempotency(failingIdempotencyStore{}, time.Minute, logger.NewJSONLogger(out, logger.Level_Debug))(inner)
+
+				response, err := w.HandleHttpRequest(idempotentRequest("/orders", "abc"))
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(response.StatusCode).To(Equal(201))
+
+				Expect(out.String()).To(ContainSubstring("unable to store response for idempotency key"))
+				Expect(out.String()).To(ContainSubstring("store unavailable"))
+			})
+		})
+	})
+
+	Context("WithCors", func() {
+		var mw *mock_worker.MockWorker
+		var w Worker
+
+		BeforeEach(func() {
+			policy, err := NewCorsPolicy(&CorsOptions{
+				AllowedOrigins: []string{"https://app.example.com", "https://*.preview.example.com", `^https://localhost:\d+$`},
+				AllowedHeaders: []string{"Authorization"},
+				MaxAgeSeconds:  600,
+			})
+			Expect(err).ShouldNot(HaveOccurred())
+
+			mw = mock_worker.NewMockWorker(&mock_worker.MockWorkerOptions{
+				ReturnHttp: &triggers.HttpResponse{
+					StatusCode: 200,
+					Body:       []byte("success"),
+				},
+			})
+			pool := NewProcessPool(&ProcessPoolOptions{})
+			pool.AddWorker(mw)
+
+			w, err = NewDecoratedPool(pool, WithCors(policy)).GetWorker()
+			Expect(err).ShouldNot(HaveOccurred())
+		})
+
+		When("A preflight request is allowed", func() {
+			It("Should respond without dispatching the request to the worker", func() {
+				resp, err := w.HandleHttpRequest(&triggers.HttpRequest{
+					Method: "OPTIONS",
+					Header: map[string][]string{
+						"Origin":                         {"https://pr-12.preview.example.com"},
+						"Access-Control-Request-Method":  {"POST"},
+						"Access-Control-Request-Headers": {"authorization, content-type"},
+					},
+				})
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(resp.StatusCode).To(Equal(204))
+				Expect(string(resp.Header.Peek("Access-Control-Allow-Origin"))).To(Equal("https://pr-12.preview.example.com"))
+				Expect(string(resp.Header.Peek("Access-Control-Allow-Methods"))).To(ContainSubstring("POST"))
+				Expect(string(resp.Header.Peek("Access-Control-Allow-Headers"))).To(Equal("authorization, content-type"))
+				Expect(string(resp.Header.Peek("Access-Control-Max-Age"))).To(Equal("600"))
+
+				Expect(mw.ReceivedRequests).To(BeEmpty())
+			})
+		})
+
+		When("A preflight request is from an origin that isn't allowed", func() {
+			It("Should respond without the CORS headers", func() {
+				resp, err := w.HandleHttpRequest(&triggers.HttpRequest{
+					Method: "OPTIONS",
+					Header: map[string][]string{
+						"Origin":                        {"https://evil.com"},
+						"Access-Control-Request-Method": {"GET"},
+					},
+				})
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(resp.Header.Peek("Access-Control-Allow-Origin")).To(BeEmpty())
+				Expect(mw.ReceivedRequests).To(BeEmpty())
+			})
+		})
+
+		When("A cross-origin request is from an origin matching a regular expression", func() {
+			It("Should add the CORS headers to the worker response", func() {
+				resp, err := w.HandleHttpRequest(&triggers.HttpRequest{
+					Method: "GET",
+					Header: map[string][]string{
+						"Origin": {"https://localhost:3000"},
+					},
+				})
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(string(resp.Body)).To(Equal("success"))
+				Expect(string(resp.Header.Peek("Access-Control-Allow-Origin"))).To(Equal("https://localhost:3000"))
+				Expect(mw.ReceivedRequests).To(HaveLen(1))
+			})
+		})
+	})
+
+	Context("NewCorsPolicy", func() {
+		When("An origin is an invalid regular expression", func() {
+			It("Should return an error", func() {
+				_, err := NewCorsPolicy(&CorsOptions{
+					AllowedOrigins: []string{"^https://(localhost"},
+				})
+				Expect(err).Should(HaveOccurred())
+			})
+		})
+
+		When("Credentials are allowed for any origin", func() {
+			It("Should return an error", func() {
+				_, err := NewCorsPolicy(&CorsOptions{
+					AllowedOrigins:   []string{"https://example.com", "*"},
+					AllowCredentials: true,
+				})
+				Expect(err).Should(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("credentials can't be allowed for any origin"))
+			})
+		})
+	})
+
+	Context("Recovering from worker panics", func() {
+		When("A worker panics handling a HTTP request", func() {
+			It("Should return a 500 response and keep the worker in the pool", func() {
+				pool := NewProcessPool(&ProcessPoolOptions{})
+				pool.AddWorker(mock_worker.NewMockWorker(&mock_worker.MockWorkerOptions{
+					Panic: "handler failure",
+				}))
+
+				w, err := pool.GetWorker()
+				Expect(err).ShouldNot(HaveOccurred())
+
+				resp, err := w.HandleHttpRequest(&triggers.HttpRequest{})
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(resp.StatusCode).To(Equal(500))
+				Expect(pool.GetWorkerCount()).To(Equal(1))
+			})
+		})
+
+		When("A worker panics handling an event with a timeout", func() {
+			It("Should return a PanicError", func() {
+				pool := NewProcessPool(&ProcessPoolOptions{})
+				pool.AddWorker(mock_worker.NewMockWorker(&mock_worker.MockWorkerOptions{
+					Panic: "handler failure",
+				}))
+
+				w, err := NewDecoratedPool(pool, WithRequestTimeout(time.Second)).GetWorker()
+				Expect(err).ShouldNot(HaveOccurred())
+
+				err = w.HandleEvent(&triggers.Event{ID: "test", Topic: "test"})
+				Expect(err).Should(HaveOccurred())
+				_, isPanic := err.(*PanicError)
+				Expect(isPanic).To(BeTrue())
+			})
+		})
+	})
+
+	Context("httpBodyStream", func() {
+		When("Chunks are written and the stream ends", func() {
+			It("Should read the chunks in order", func() {
+				stream := newHttpBodyStream()
+				go func() {
+					stream.write([]byte("hello "))
+					stream.write([]byte("world"))
+					stream.end(nil)
+				}()
+
+				body, err := ioutil.ReadAll(stream)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(string(body)).To(Equal("hello world"))
+			})
+		})
+
+		When("The stream ends with an error", func() {
+			It("Should return the error after the written chunks", func() {
+				stream := newHttpBodyStream()
+				stream.write([]byte("partial"))
+				stream.end(fmt.Errorf("stream ended"))
+
+				body, err := ioutil.ReadAll(stream)
+				Expect(err).Should(HaveOccurred())
+				Expect(string(body)).To(Equal("partial"))
+			})
+		})
+	})
+
+	Context("WithTracing", func() {
+		When("A HTTP request carries a trace context", func() {
+			It("Should propagate the trace to the worker", func() {
+				traceparent := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+				pool := NewProcessPool(&ProcessPoolOptions{})
+				mw := mock_worker.NewMockWorker(&mock_worker.MockWorkerOptions{})
+				pool.AddWorker(mw)
+
+				decorated := NewDecoratedPool(pool, WithTracing(trace.NewNoopTracerProvider()))
+				w, err := decorated.GetWorker()
+				Expect(err).ShouldNot(HaveOccurred())
+
+				w.HandleHttpRequest(&triggers.HttpRequest{
+					Header: map[string][]string{
+						"Traceparent": {traceparent},
+					},
+				})
+
+				Expect(mw.ReceivedRequests).To(HaveLen(1))
+				Expect(mw.ReceivedRequests[0].Header["Traceparent"]).To(Equal([]string{traceparent}))
+			})
+		})
+	})
+
+	Context("WithRequestId", func() {
+		When("A HTTP request has no request ID", func() {
+			It("Should generate one", func() {
+				pool := NewProcessPool(&ProcessPoolOptions{})
+				mw := mock_worker.NewMockWorker(&mock_worker.MockWorkerOptions{})
+				pool.AddWorker(mw)
+
+				w, err := NewDecoratedPool(pool, WithRequestId()).GetWorker()
+				Expect(err).ShouldNot(HaveOccurred())
+
+				w.HandleHttpRequest(&triggers.HttpRequest{})
+
+				Expect(mw.ReceivedRequests).To(HaveLen(1))
+				Expect(mw.ReceivedRequests[0].Header["X-Nitric-Request-Id"]).To(HaveLen(1))
+				Expect(mw.ReceivedRequests[0].Header["X-Nitric-Request-Id"][0]).ToNot(BeEmpty())
+			})
+		})
+
+		When("A HTTP request already has a request ID", func() {
+			It("Should keep it", func() {
+				pool := NewProcessPool(&ProcessPoolOptions{})
+				mw := mock_worker.NewMockWorker(&mock_worker.MockWorkerOptions{})
+				pool.AddWorker(mw)
+
+				w, err := NewDecoratedPool(pool, WithRequestId()).GetWorker()
+				Expect(err).ShouldNot(HaveOccurred())
+
+				w.HandleHttpRequest(&triggers.HttpRequest{
+					Header: map[string][]string{
+						"x-nitric-request-id": {"test-request"},
+					},
+				})
+
+				Expect(mw.ReceivedRequests).To(HaveLen(1))
+				Expect(mw.ReceivedRequests[0].Header).To(Equal(map[string][]string{
+					"x-nitric-request-id": {"test-request"},
+				}))
+			})
+		})
+
+		When("A HTTP request without a request ID is handled", func() {
+			It("Should return the generated ID in the response", func() {
+				pool := NewProcessPool(&ProcessPoolOptions{})
+				mw := mock_worker.NewMockWorker(&mock_worker.MockWorkerOptions{
+					ReturnHttp: &triggers.HttpResponse{StatusCode: 200},
+				})
+				pool.AddWorker(mw)
+
+				w, err := NewDecoratedPool(pool, WithRequestId()).GetWorker()
+				Expect(err).ShouldNot(HaveOccurred())
+
+				response, err := w.HandleHttpRequest(&triggers.HttpRequest{})
+				Expect(err).ShouldNot(HaveOccurred())
+
+				generated := mw.ReceivedRequests[0].Header["X-Nitric-Request-Id"][0]
+				Expect(generated).ToNot(BeEmpty())
+				Expect(string(response.Header.Peek("X-Request-Id"))).To(Equal(generated))
+			})
+		})
+
+		When("A HTTP request has an X-Request-Id header", func() {
+			It("Should use it as the request ID and echo it back", func() {
+				pool := NewProcessPool(&ProcessPoolOptions{})
+				mw := mock_worker.NewMockWorker(&mock_worker.MockWorkerOptions{
+					ReturnHttp: &triggers.HttpResponse{StatusCode: 200},
+				})
+				pool.AddWorker(mw)
+
+				w, err := NewDecoratedPool(pool, WithRequestId()).GetWorker()
+				Expect(err).ShouldNot(HaveOccurred())
+
+				response, err := w.HandleHttpRequest(&triggers.HttpRequest{
+					Header: map[string][]string{
+						"x-request-id": {"client-request"},
+					},
+				})
+				Expect(err).ShouldNot(HaveOccurred())
+
+				Expect(mw.ReceivedRequests[0].Header["X-Nitric-Request-Id"]).To(Equal([]string{"client-request"}))
+				Expect(string(response.Header.Peek("X-Request-Id"))).To(Equal("client-request"))
+			})
+		})
+
+		When("A HTTP request has a traceparent header", func() {
+			It("Should use the trace ID as the request ID", func() {
+				pool := NewProcessPool(&ProcessPoolOptions{})
+				mw := mock_worker.NewMockWorker(&mock_worker.MockWorkerOptions{})
+				pool.AddWorker(mw)
+
+				w, err := NewDecoratedPool(pool, WithRequestId()).GetWorker()
+				Expect(err).ShouldNot(HaveOccurred())
+
+				w.HandleHttpRequest(&triggers.HttpRequest{
+					Header: map[string][]string{
+						"traceparent": {"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"},
+					},
+				})
+
+				Expect(mw.ReceivedRequests[0].Header["X-Nitric-Request-Id"]).To(Equal([]string{"4bf92f3577b34da6a3ce929d0e0e4736"}))
+			})
+
+			It("Should generate an ID if the traceparent is invalid", func() {
+				pool := NewProcessPool(&ProcessPoolOptions{})
+				mw := mock_worker.NewMockWorker(&mock_worker.MockWorkerOptions{})
+				pool.AddWorker(mw)
+
+				w, err := NewDecoratedPool(pool, WithRequestId()).GetWorker()
+				Expect(err).ShouldNot(HaveOccurred())
+
+				w.HandleHttpRequest(&triggers.HttpRequest{
+					Header: map[string][]string{
+						"traceparent": {"00-00000000000000000000000000000000-00f067aa0ba902b7-01"},
+					},
+				})
+
+				requestId := mw.ReceivedRequests[0].Header["X-Nitric-Request-Id"][0]
+				Expect(requestId).ToNot(BeEmpty())
+				Expect(requestId).ToNot(Equal("00000000000000000000000000000000"))
+			})
+		})
+
+		When("An event has no ID", func() {
+			It("Should generate one", func() {
+				pool := NewProcessPool(&ProcessPoolOptions{})
+				mw := mock_worker.NewMockWorker(&mock_worker.MockWorkerOptions{})
+				pool.AddWorker(mw)
+
+				w, err := NewDecoratedPool(pool, WithRequestId()).GetWorker()
+				Expect(err).ShouldNot(HaveOccurred())
+
+				w.HandleEvent(&triggers.Event{Topic: "test"})
+
+				Expect(mw.ReceivedEvents).To(HaveLen(1))
+				Expect(mw.ReceivedEvents[0].ID).ToNot(BeEmpty())
+			})
+		})
+	})
+
+	Context("WithTriggerMiddleware", func() {
+		When("Multiple middleware are registered", func() {
+			It("Should apply them in order before dispatching to the worker", func() {
+				pool := NewProcessPool(&ProcessPoolOptions{})
+				mw := mock_worker.NewMockWorker(&mock_worker.MockWorkerOptions{
+					ReturnHttp: &triggers.HttpResponse{StatusCode: 200},
+				})
+				pool.AddWorker(mw)
+
+				order := make([]string, 0)
+				recordAs := func(name string) TriggerMiddleware {
+					return func(next Handler) Handler {
+						return func(trigger triggers.Trigger) (*triggers.HttpResponse, error) {
+							order = append(order, name)
+							return next(trigger)
+						}
+					}
+				}
+
+				w, err := NewDecoratedPool(pool, WithTriggerMiddleware(recordAs("first"), recordAs("second"))).GetWorker()
+				Expect(err).ShouldNot(HaveOccurred())
+
+				response, err := w.HandleHttpRequest(&triggers.HttpRequest{})
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(response.StatusCode).To(Equal(200))
+				Expect(mw.ReceivedRequests).To(HaveLen(1))
+
+				err = w.HandleEvent(&triggers.Event{Topic: "test"})
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(mw.ReceivedEvents).To(HaveLen(1))
+
+				Expect(order).To(Equal([]string{"first", "second", "first", "second"}))
+			})
+		})
+
+		When("A request doesn't have a valid bearer token", func() {
+			It("Should respond with a 401 without dispatching to the worker", func() {
+				pool := NewProcessPool(&ProcessPoolOptions{})
+				mw := mock_worker.NewMockWorker(&mock_worker.MockWorkerOptions{})
+				pool.AddWorker(mw)
+
+				w, err := NewDecoratedPool(pool, WithTriggerMiddleware(BearerTokenAuth("secret"))).GetWorker()
+				Expect(err).ShouldNot(HaveOccurred())
+
+				response, err := w.HandleHttpRequest(&triggers.HttpRequest{
+					Header: map[string][]string{
+						"Authorization": {"Bearer wrong"},
+					},
+				})
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(response.StatusCode).To(Equal(401))
+				Expect(string(response.Header.Peek("WWW-Authenticate"))).To(Equal("Bearer"))
+				Expect(mw.ReceivedRequests).To(BeEmpty())
+			})
+		})
+
+		When("A request has a valid bearer token", func() {
+			It("Should dispatch the request to the worker", func() {
+				pool := NewProcessPool(&ProcessPoolOptions{})
+				mw := mock_worker.NewMockWorker(&mock_worker.MockWorkerOptions{
+					ReturnHttp: &triggers.HttpResponse{StatusCode: 200},
+				})
+				pool.AddWorker(mw)
+
+				w, err := NewDecoratedPool(pool, WithTriggerMiddleware(BearerTokenAuth("other", "secret"))).GetWorker()
+				Expect(err).ShouldNot(HaveOccurred())
+
+				response, err := w.HandleHttpRequest(&triggers.HttpRequest{
+					Header: map[string][]string{
+						"authorization": {"bearer secret"},
+					},
+				})
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(response.StatusCode).To(Equal(200))
+				Expect(mw.ReceivedRequests).To(HaveLen(1))
+			})
+		})
+
+		When("Middleware returns no response for a HTTP request", func() {
+			It("Should return an error", func() {
+				pool := NewProcessPool(&ProcessPoolOptions{})
+				mw := mock_worker.NewMockWorker(&mock_worker.MockWorkerOptions{})
+				pool.AddWorker(mw)
+
+				drop := func(next Handler) Handler {
+					return func(trigger triggers.Trigger) (*triggers.HttpResponse, error) {
+						return nil, nil
+					}
+				}
+
+				w, err := NewDecoratedPool(pool, WithTriggerMiddleware(drop)).GetWorker()
+				Expect(err).ShouldNot(HaveOccurred())
+
+				_, err = w.HandleHttpRequest(&triggers.HttpRequest{})
+				Expect(err).Should(HaveOccurred())
+			})
+		})
+	})
+
+	Context("WithDeadLetterQueue", func() {
+		When("An event continues to fail after retrying", func() {
+			It("Should send the event to the dead-letter queue", func() {
+				pool := NewProcessPool(&ProcessPoolOptions{})
+				mw := mock_worker.NewMockWorker(&mock_worker.MockWorkerOptions{
+					EventError: fmt.Errorf("mock error"),
+				})
+				pool.AddWorker(mw)
+				dlq := &recordingQueue{sent: make(map[string][]queue.NitricTask)}
+
+				decorated := NewDecoratedPool(pool, WithDeadLetterQueue(dlq, "dead-letters", 2, logger.NewNoopLogger()))
+				w, err := decorated.GetWorker()
+				Expect(err).ShouldNot(HaveOccurred())
+
+				err = w.HandleEvent(&triggers.Event{
+					ID:      "test-event",
+					Topic:   "test-topic",
+					Payload: []byte(`{"test": "payload"}`),
+				})
+
+				By("Treating the dead-lettered event as handled")
+				Expect(err).ShouldNot(HaveOccurred())
+
+				By("Retrying the event")
+				Expect(mw.ReceivedEvents).To(HaveLen(3))
+
+				By("Sending the original payload with the failure details")
+				Expect(dlq.sent["dead-letters"]).To(HaveLen(1))
+				task := dlq.sent["dead-letters"][0]
+				Expect(task.ID).To(Equal("test-event"))
+				Expect(task.PayloadType).To(Equal(DeadLetterPayloadType))
+				Expect(task.Payload["topic"]).To(Equal("test-topic"))
+				Expect(task.Payload["error"]).To(Equal("mock error"))
+				Expect(task.Payload["attempts"]).To(Equal(3))
+				Expect(task.Payload["payload"]).To(Equal(map[string]interface{}{"test": "payload"}))
+			})
+		})
+	})
+
+	Context("DeadLetterReplayer", func() {
+		var dlq *memoryQueue
+
+		BeforeEach(func() {
+			dlq = &memoryQueue{leased: make(map[string]queue.NitricTask)}
+			dlq.Send("dead-letters", newDeadLetterTask(&triggers.Event{
+				ID:      "event-1",
+				Topic:   "test-topic",
+				Payload: []byte(`{"test":"payload"}`),
+			}, 1, fmt.Errorf("mock error")))
+			dlq.Send("dead-letters", newDeadLetterTask(&triggers.Event{
+				ID:          "event-2",
+				Topic:       "test-topic",
+				Payload:     []byte("not json"),
+				ReplayCount: 3,
+			}, 1, fmt.Errorf("mock error")))
+		})
+
+		When("Replaying every dead-lettered event", func() {
+			It("Should dispatch the events that haven't reached the maximum replays", func() {
+				pool := NewProcessPool(&ProcessPoolOptions{})
+				mw := mock_worker.NewMockWorker(&mock_worker.MockWorkerOptions{})
+				pool.AddWorker(mw)
+
+				result, err := NewDeadLetterReplayer(pool, dlq, "dead-letters", 3, nil).ReplayAll()
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(*result).To(Equal(ReplayResult{Replayed: 1, Skipped: 1}))
+
+				By("Restoring the original event")
+				Expect(mw.ReceivedEvents).To(HaveLen(1))
+				Expect(mw.ReceivedEvents[0].ID).To(Equal("event-1"))
+				Expect(mw.ReceivedEvents[0].Topic).To(Equal("test-topic"))
+				Expect(string(mw.ReceivedEvents[0].Payload)).To(Equal(`{"test":"payload"}`))
+				Expect(mw.ReceivedEvents[0].ReplayCount).To(Equal(1))
+
+				By("Leaving the skipped event on the dead-letter queue")
+				Expect(dlq.leased).To(HaveLen(1))
+			})
+		})
+
+		When("A replayed event fails again", func() {
+			It("Should dead-letter it again with its replay count", func() {
+				pool := NewProcessPool(&ProcessPoolOptions{})
+				pool.AddWorker(mock_worker.NewMockWorker(&mock_worker.MockWorkerOptions{
+					EventError: fmt.Errorf("mock error"),
+				}))
+				decorated := NewDecoratedPool(pool, WithDeadLetterQueue(dlq, "dead-letters", 0, logger.NewNoopLogger()))
+
+				result, err := NewDeadLetterReplayer(decorated, dlq, "dead-letters", 0, nil).Replay("event-1")
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(result.Replayed).To(Equal(1))
+
+				var redelivered *queue.NitricTask
+				for i := range dlq.tasks {
+					if dlq.tasks[i].ID == "event-1" {
+						redelivered = &dlq.tasks[i]
+					}
+				}
+				Expect(redelivered).ToNot(BeNil())
+				Expect(redelivered.Attributes[ReplayCountAttribute]).To(Equal("1"))
+			})
+		})
+
+		When("The event isn't on the dead-letter queue", func() {
+			It("Should return ErrDeadLetterNotFound", func() {
+				pool := NewProcessPool(&ProcessPoolOptions{})
+				pool.AddWorker(mock_worker.NewMockWorker(&mock_worker.MockWorkerOptions{}))
+
+				_, err := NewDeadLetterReplayer(pool, dlq, "dead-letters", 0, nil).Replay("unknown")
+				Expect(err).To(Equal(ErrDeadLetterNotFound))
+			})
+		})
+	})
+
+	Context("Metrics", func() {
+		When("Triggers are observed", func() {
+			It("Should count triggers and errors by trigger type", func() {
+				pool := NewProcessPool(&ProcessPoolOptions{})
+				pool.AddWorker(mock_worker.NewMockWorker(&mock_worker.MockWorkerOptions{}))
+
+				metrics, err := NewMetrics(prometheus.NewRegistry(), pool)
+				Expect(err).ShouldNot(HaveOccurred())
+
+				metrics.observe(triggers.TriggerType_Request, time.Now(), nil)
+				metrics.observe(triggers.TriggerType_Subscription, time.Now(), fmt.Errorf("mock error"))
+
+				Expect(testutil.ToFloat64(metrics.httpRequests)).To(Equal(1.0))
+				Expect(testutil.ToFloat64(metrics.events)).To(Equal(1.0))
+				Expect(testutil.ToFloat64(metrics.errors.WithLabelValues(triggers.TriggerType_Request.String()))).To(Equal(0.0))
+				Expect(testutil.ToFloat64(metrics.errors.WithLabelValues(triggers.TriggerType_Subscription.String()))).To(Equal(1.0))
+			})
+
+			It("Should report the current pool size", func() {
+				pool := NewProcessPool(&ProcessPoolOptions{MaxWorkers: 2})
+				pool.AddWorker(mock_worker.NewMockWorker(&mock_worker.MockWorkerOptions{}))
+				pool.AddWorker(mock_worker.NewMockWorker(&mock_worker.MockWorkerOptions{}))
+
+				registry := prometheus.NewRegistry()
+				_, err := NewMetrics(registry, pool)
+				Expect(err).ShouldNot(HaveOccurred())
+
+				families, err := registry.Gather()
+				Expect(err).ShouldNot(HaveOccurred())
+
+				workers := -1.0
+				for _, family := range families {
+					if family.GetName() == "nitric_pool_workers" {
+						workers = family.GetMetric()[0].GetGauge().GetValue()
+					}
+				}
+				Expect(workers).To(Equal(2.0))
+			})
+		})
+	})
+
+	Context("AddWorker", func() {
+		When("The pool is full", func() {
+			It("Should return a retryable pool full error", func() {
+				pool := NewProcessPool(&ProcessPoolOptions{
+					MaxWorkers: 1,
+				})
+				pool.AddWorker(mock_worker.NewMockWorker(&mock_worker.MockWorkerOptions{}))
+
+				err := pool.AddWorker(mock_worker.NewMockWorker(&mock_worker.MockWorkerOptions{}))
+				Expect(err).Should(HaveOccurred())
+
+				startupErr, ok := err.(*PoolStartupError)
+				Expect(ok).To(BeTrue())
+				Expect(startupErr.Kind).To(Equal(PoolStartupError_PoolFull))
+				Expect(startupErr.Retryable()).To(BeTrue())
+			})
+		})
+
+		When("The worker has already been added", func() {
+			It("Should return a duplicate worker error", func() {
+				pool := NewProcessPool(&ProcessPoolOptions{
+					MaxWorkers: 2,
+				})
+				wrkr := mock_worker.NewMockWorker(&mock_worker.MockWorkerOptions{})
+				pool.AddWorker(wrkr)
+
+				err := pool.AddWorker(wrkr)
+				Expect(err).Should(HaveOccurred())
+				Expect(err.(*PoolStartupError).Kind).To(Equal(PoolStartupError_DuplicateWorker))
+			})
+		})
+
+		When("The pool has been shutdown", func() {
+			It("Should return a worker rejected error", func() {
+				pool := NewProcessPool(&ProcessPoolOptions{})
+				pool.Shutdown(0)
+
+				err := pool.AddWorker(mock_worker.NewMockWorker(&mock_worker.MockWorkerOptions{}))
+				Expect(err).Should(HaveOccurred())
+				Expect(err.(*PoolStartupError).Kind).To(Equal(PoolStartupError_WorkerRejected))
+				Expect(err.(*PoolStartupError).Retryable()).To(BeFalse())
+			})
+		})
+	})
+
+	Context("RequireReady", func() {
+		When("A worker connects but doesn't signal it's ready", func() {
+			It("Should not select the worker", func() {
+				pool := NewProcessPool(&ProcessPoolOptions{
+					MaxWorkers:   2,
+					RequireReady: true,
+				})
+				warming := &warmingWorker{}
+				Expect(pool.AddWorker(warming)).To(Succeed())
+
+				_, err := pool.GetWorker()
+				Expect(err).To(Equal(ErrNoWorkersAvailable))
+				Expect(pool.ListWorkers()[0].Ready).To(BeFalse())
+
+				By("Selecting workers that are ready instead")
+				ready := mock_worker.NewMockWorker(&mock_worker.MockWorkerOptions{})
+				Expect(pool.AddWorker(ready)).To(Succeed())
+				for i := 0; i < 3; i++ {
+					wrkr, err := pool.GetWorker()
+					Expect(err).ShouldNot(HaveOccurred())
+					Expect(wrkr.(*reservedWorker).Worker).To(Equal(ready))
+				}
+			})
+
+			It("Should not count the worker towards the minimum", func() {
+				pool := NewProcessPool(&ProcessPoolOptions{
+					MinWorkers:   1,
+					RequireReady: true,
+				})
+				Expect(pool.AddWorker(&warmingWorker{})).To(Succeed())
+
+				Expect(pool.WaitForMinimumWorkers(0)).Should(HaveOccurred())
+			})
+		})
+
+		When("A worker signals it's ready", func() {
+			It("Should select the worker", func() {
+				pool := NewProcessPool(&ProcessPoolOptions{
+					RequireReady: true,
+				})
+				warming := &warmingWorker{}
+				Expect(pool.AddWorker(warming)).To(Succeed())
+
+				waited := make(chan Worker, 1)
+				go func() {
+					wrkr, _ := pool.GetWorkerWait(context.Background())
+					waited <- wrkr
+				}()
+				Consistently(waited, "50ms").ShouldNot(Receive())
+
+				warming.signalReady()
+
+				var wrkr Worker
+				Eventually(waited).Should(Receive(&wrkr))
+				Expect(wrkr.(*reservedWorker).Worker).To(Equal(warming))
+				Expect(pool.ListWorkers()[0].Ready).To(BeTrue())
+			})
+		})
+
+		When("The pool doesn't require readiness", func() {
+			It("Should select workers as soon as they are added", func() {
+				pool := NewProcessPool(&ProcessPoolOptions{})
+				Expect(pool.AddWorker(&warmingWorker{})).To(Succeed())
+
+				_, err := pool.GetWorker()
+				Expect(err).ShouldNot(HaveOccurred())
+			})
+		})
+	})
+
+	Context("Topic subscriptions", func() {
+		When("Workers subscribe to different topics", func() {
+			It("Should route each event to the worker subscribed to its topic", func() {
+				pool := NewProcessPool(&ProcessPoolOptions{})
+				orders := &subscribedWorker{topic: "orders"}
+				payments := &subscribedWorker{topic: "payments"}
+				Expect(pool.AddWorker(orders)).To(Succeed())
+				Expect(pool.AddWorker(payments)).To(Succeed())
+
+				// Workers are selected round-robin before the event's topic is known
+				for i := 0; i < 2; i++ {
+					for _, topic := range []string{"orders", "payments"} {
+						wrkr, err := pool.GetWorker()
+						Expect(err).ShouldNot(HaveOccurred())
+						Expect(wrkr.HandleEvent(&triggers.Event{ID: "test", Topic: topic})).To(Succeed())
+					}
+				}
+
+				Expect(orders.events).To(Equal([]string{"orders", "orders"}))
+				Expect(payments.events).To(Equal([]string{"payments", "payments"}))
+			})
+		})
+
+		When("No worker subscribes to the topic", func() {
+			It("Should return ErrNoSubscribedWorkers", func() {
+				pool := NewProcessPool(&ProcessPoolOptions{})
+				Expect(pool.AddWorker(&subscribedWorker{topic: "orders"})).To(Succeed())
+
+				wrkr, err := pool.GetWorker()
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(wrkr.HandleEvent(&triggers.Event{ID: "test", Topic: "refunds"})).To(Equal(ErrNoSubscribedWorkers))
+			})
+		})
+	})
+
+	Context("ListWorkers", func() {
+		When("Workers have handled triggers", func() {
+			It("Should report the triggers handled and the last error", func() {
+				pool := NewProcessPool(&ProcessPoolOptions{})
+				pool.AddWorker(mock_worker.NewMockWorker(&mock_worker.MockWorkerOptions{
+					EventError: fmt.Errorf("mock error"),
+				}))
+
+				Expect(pool.ListWorkers()[0].Handled).To(BeZero())
+				Expect(pool.ListWorkers()[0].LastError).To(BeEmpty())
+
+				for i := 0; i < 2; i++ {
+					wrkr, err := pool.GetWorker()
+					Expect(err).ShouldNot(HaveOccurred())
+					Expect(wrkr.HandleEvent(&triggers.Event{})).Should(HaveOccurred())
+				}
+
+				info := pool.ListWorkers()[0]
+				Expect(info.Handled).To(Equal(int64(2)))
+				Expect(info.LastError).To(Equal("mock error"))
+				Expect(info.LastErrorAt).ToNot(BeZero())
+			})
+		})
+	})
+
+	Context("RemoveWorker", func() {
+		When("The pool drops below its minimum workers", func() {
+			It("Should report the error to the monitor without blocking", func() {
+				pool := NewProcessPool(&ProcessPoolOptions{
+					MinWorkers: 1,
+				})
+				mw := mock_worker.NewMockWorker(&mock_worker.MockWorkerOptions{})
+				pool.AddWorker(mw)
+
+				removed := make(chan error)
+				go func() {
+					removed <- pool.RemoveWorker(mw)
+				}()
+				Eventually(removed).Should(Receive(BeNil()))
+
+				By("Not blocking the pool while the error hasn't been monitored")
+				_, err := pool.GetWorker()
+				Expect(err).To(Equal(ErrNoWorkersAvailable))
+
+				err = pool.Monitor()
+				Expect(err).Should(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("insufficient workers in pool"))
+			})
+		})
+	})
+
+	Context("RemoveWorkerByID", func() {
+		When("The worker is idle", func() {
+			It("Should remove the worker immediately", func() {
+				pool := NewProcessPool(&ProcessPoolOptions{
+					MaxWorkers: 2,
+				})
+				pool.AddWorker(mock_worker.NewMockWorker(&mock_worker.MockWorkerOptions{}))
+				pool.AddWorker(mock_worker.NewMockWorker(&mock_worker.MockWorkerOptions{}))
+
+				workers := pool.ListWorkers()
+				Expect(workers).To(HaveLen(2))
+				Expect(workers[0].ID).ToNot(Equal(workers[1].ID))
+
+				Expect(pool.RemoveWorkerByID(workers[0].ID)).ShouldNot(HaveOccurred())
+				Expect(pool.ListWorkers()).To(Equal([]WorkerInfo{workers[1]}))
+			})
+		})
+
+		When("The worker is handling a trigger", func() {
+			It("Should stop routing triggers to the worker and remove it once drained", func() {
+				pool := NewProcessPool(&ProcessPoolOptions{
+					MaxWorkers: 2,
+				})
+				bw := newBlockingWorker()
+				pool.AddWorker(bw)
+				oldWorker, _ := pool.GetWorker()
+
+				done := make(chan error)
+				go func() {
+					done <- oldWorker.HandleEvent(&triggers.Event{})
+				}()
+				<-bw.started
+
+				By("Attaching a new worker before draining the old one")
+				newWorker := mock_worker.NewMockWorker(&mock_worker.MockWorkerOptions{})
+				pool.AddWorker(newWorker)
+
+				oldID := pool.ListWorkers()[0].ID
+				Expect(pool.RemoveWorkerByID(oldID)).ShouldNot(HaveOccurred())
+
+				workers := pool.ListWorkers()
+				Expect(workers).To(HaveLen(2))
+				Expect(workers[0].Draining).To(BeTrue())
+				Expect(workers[0].InFlight).To(Equal(1))
+
+				By("Routing new triggers to the new worker")
+				for i := 0; i < 2; i++ {
+					wrkr, err := pool.GetWorker()
+					Expect(err).ShouldNot(HaveOccurred())
+					Expect(wrkr.HandleEvent(&triggers.Event{})).ShouldNot(HaveOccurred())
+				}
+				Expect(newWorker.ReceivedEvents).To(HaveLen(2))
+
+				By("Removing the old worker once its trigger completes")
+				bw.release <- true
+				Expect(<-done).ShouldNot(HaveOccurred())
+
+				workers = pool.ListWorkers()
+				Expect(workers).To(HaveLen(1))
+				Expect(workers[0].ID).ToNot(Equal(oldID))
+			})
+		})
+
+		When("The last worker is drained below the minimum", func() {
+			It("Should remove the worker without reporting a pool error", func() {
+				pool := NewProcessPool(&ProcessPoolOptions{
+					MinWorkers: 1,
+				})
+				bw := newBlockingWorker()
+				pool.AddWorker(bw)
+				wrkr, _ := pool.GetWorker()
+
+				done := make(chan error)
+				go func() {
+					done <- wrkr.HandleEvent(&triggers.Event{})
+				}()
+				<-bw.started
+
+				Expect(pool.RemoveWorkerByID(pool.ListWorkers()[0].ID)).ShouldNot(HaveOccurred())
+
+				By("Removing the worker once its trigger completes")
+				bw.release <- true
+				Eventually(done).Should(Receive(BeNil()))
+				Expect(pool.GetWorkerCount()).To(Equal(0))
+
+				monitored := make(chan error, 1)
+				go func() {
+					monitored <- pool.Monitor()
+				}()
+				Consistently(monitored, "100ms").ShouldNot(Receive())
+			})
+		})
+
+		When("The worker does not exist", func() {
+			It("Should return an error", func() {
+				pool := NewProcessPool(&ProcessPoolOptions{})
+
+				Expect(pool.RemoveWorkerByID("unknown")).Should(HaveOccurred())
+			})
+		})
+	})
+})
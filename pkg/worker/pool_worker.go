@@ -0,0 +1,411 @@
+// Copyright 2021 Nitric Pty Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package worker
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/nitrictech/nitric/pkg/logger"
+	"github.com/nitrictech/nitric/pkg/triggers"
+)
+
+// poolWorker - A worker registered with a ProcessPool
+// Tracks the triggers the worker is currently handling so the pool can drain them on shutdown
+// and limits the number of triggers the worker handles concurrently
+type poolWorker struct {
+	// The number of triggers the worker has completed, first so it's aligned for atomic access on 32 bit platforms
+	handled int64
+	Worker
+	// ID assigned to the worker by the pool
+	id       string
+	log      logger.Logger
+	inFlight int32
+	closed   int32
+	// Semaphore limiting concurrent triggers, nil if unlimited
+	slots chan struct{}
+	// Called each time the worker completes a trigger
+	onRelease func()
+	// Selects a worker subscribed to the topic for events this worker doesn't subscribe to, nil never reroutes
+	route func(topic string) (*reservedWorker, error)
+	// The worker is being removed from the pool, guarded by the pool's worker lock
+	draining bool
+	// The worker has signalled it can handle triggers, guarded by the pool's worker lock
+	ready bool
+	// The most recent error handling a trigger, for inspecting the worker
+	lastErrorLock sync.Mutex
+	lastError     string
+	lastErrorAt   time.Time
+}
+
+// acquire - Registers a new in-flight trigger, failing if the worker has been closed
+// Triggers using a concurrency slot reserved when the worker was selected start immediately,
+// others block until a slot is available
+func (w *poolWorker) acquire(reserved bool) error {
+	atomic.AddInt32(&w.inFlight, 1)
+
+	if atomic.LoadInt32(&w.closed) == 1 {
+		atomic.AddInt32(&w.inFlight, -1)
+		if reserved {
+			w.releaseSlot()
+		}
+		return fmt.Errorf("worker is shutting down and is no longer accepting triggers")
+	}
+
+	if w.slots != nil && !reserved {
+		w.slots <- struct{}{}
+	}
+
+	return nil
+}
+
+// release - Completes an in-flight trigger
+func (w *poolWorker) release() {
+	w.releaseSlot()
+
+	atomic.AddInt32(&w.inFlight, -1)
+
+	if w.onRelease != nil {
+		w.onRelease()
+	}
+}
+
+// reserve - Reserves a concurrency slot for a trigger the worker has been selected to handle, returning false if
+// the worker is busy. The pool's worker lock must be held so concurrent callers can't reserve the same slot
+func (w *poolWorker) reserve() bool {
+	if w.slots == nil {
+		return true
+	}
+
+	select {
+	case w.slots <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+// releaseSlot - Frees a concurrency slot
+func (w *poolWorker) releaseSlot() {
+	if w.slots != nil {
+		<-w.slots
+	}
+}
+
+// cancelReservation - Frees a reserved concurrency slot no trigger used, so waiting callers can select the worker
+func (w *poolWorker) cancelReservation() {
+	w.releaseSlot()
+
+	if w.onRelease != nil {
+		w.onRelease()
+	}
+}
+
+// isBusy - Returns true if the worker is handling its maximum number of concurrent triggers
+func (w *poolWorker) isBusy() bool {
+	return w.slots != nil && len(w.slots) >= cap(w.slots)
+}
+
+// close - Stops the worker from accepting new triggers
+func (w *poolWorker) close() {
+	atomic.StoreInt32(&w.closed, 1)
+}
+
+// getInFlight - Returns the number of triggers currently being handled by this worker
+func (w *poolWorker) getInFlight() int {
+	return int(atomic.LoadInt32(&w.inFlight))
+}
+
+// getHandled - Returns the number of triggers this worker has completed
+func (w *poolWorker) getHandled() int64 {
+	return atomic.LoadInt64(&w.handled)
+}
+
+// getLastError - Returns the most recent error handling a trigger and when it occurred, empty if there hasn't been one
+func (w *poolWorker) getLastError() (string, time.Time) {
+	w.lastErrorLock.Lock()
+	defer w.lastErrorLock.Unlock()
+
+	return w.lastError, w.lastErrorAt
+}
+
+// recordResult - Counts a completed trigger, remembering the error if it failed
+func (w *poolWorker) recordResult(err error) {
+	atomic.AddInt64(&w.handled, 1)
+
+	if err == nil {
+		return
+	}
+
+	w.lastErrorLock.Lock()
+	defer w.lastErrorLock.Unlock()
+
+	w.lastError = err.Error()
+	w.lastErrorAt = time.Now()
+}
+
+// subscribes - Returns true if the worker handles events for the topic
+func (w *poolWorker) subscribes(topic string) bool {
+	if subscriber, ok := w.Worker.(SubscribingWorker); ok {
+		return subscriber.Subscribes(topic)
+	}
+
+	return true
+}
+
+// shouldReroute - Returns true if the event should be rerouted to a worker subscribed to its topic,
+// gateways select workers before they know a trigger's topic so events are rerouted here
+func (w *poolWorker) shouldReroute(topic string) bool {
+	return w.route != nil && !w.subscribes(topic)
+}
+
+// handleHttp - Calls the handler, a panic while handling the request is logged and returned as a 500 response
+// so a single failing trigger can't take down the membrane
+func (w *poolWorker) handleHttp(handle func() (*triggers.HttpResponse, error)) (*triggers.HttpResponse, error) {
+	response, err := callHttpHandler(handle)
+	w.recordResult(err)
+
+	if panicErr, ok := err.(*PanicError); ok {
+		w.log.Error("worker panicked handling HTTP request", "workerId", w.id, "panic", fmt.Sprint(panicErr.Value), "stack", string(panicErr.Stack))
+		return panicResponse(), nil
+	}
+
+	return response, err
+}
+
+// handle - Calls the handler, a panic while handling the trigger is logged and returned as an error
+func (w *poolWorker) handle(triggerType string, handle func() error) error {
+	err := callHandler(handle)
+	w.recordResult(err)
+
+	if panicErr, ok := err.(*PanicError); ok {
+		w.log.Error(fmt.Sprintf("worker panicked handling %s", triggerType), "workerId", w.id, "panic", fmt.Sprint(panicErr.Value), "stack", string(panicErr.Stack))
+	}
+
+	return err
+}
+
+func (w *poolWorker) HandleEvent(trigger *triggers.Event) error {
+	return w.dispatchEvent(false, trigger)
+}
+
+// dispatchEvent - Handles the event, reserved is true if the trigger uses a concurrency slot reserved for it
+func (w *poolWorker) dispatchEvent(reserved bool, trigger *triggers.Event) error {
+	if w.shouldReroute(trigger.Topic) {
+		if reserved {
+			w.cancelReservation()
+		}
+
+		target, err := w.route(trigger.Topic)
+		if err != nil {
+			return err
+		}
+
+		return target.HandleEvent(trigger)
+	}
+
+	if err := w.acquire(reserved); err != nil {
+		return err
+	}
+	defer w.release()
+
+	return w.handle("event", func() error {
+		return w.Worker.HandleEvent(trigger)
+	})
+}
+
+func (w *poolWorker) HandleHttpRequest(trigger *triggers.HttpRequest) (*triggers.HttpResponse, error) {
+	return w.dispatchHttpRequest(false, trigger)
+}
+
+// dispatchHttpRequest - Handles the request, reserved is true if the trigger uses a concurrency slot reserved for it
+func (w *poolWorker) dispatchHttpRequest(reserved bool, trigger *triggers.HttpRequest) (*triggers.HttpResponse, error) {
+	if err := w.acquire(reserved); err != nil {
+		return nil, err
+	}
+	defer w.release()
+
+	return w.handleHttp(func() (*triggers.HttpResponse, error) {
+		return w.Worker.HandleHttpRequest(trigger)
+	})
+}
+
+func (w *poolWorker) handleEventWithContext(ctx context.Context, trigger *triggers.Event) error {
+	return w.dispatchEventWithContext(false, ctx, trigger)
+}
+
+// dispatchEventWithContext - Handles the event until the context is done, reserved is true if the trigger uses
+// a concurrency slot reserved for it
+func (w *poolWorker) dispatchEventWithContext(reserved bool, ctx context.Context, trigger *triggers.Event) error {
+	if w.shouldReroute(trigger.Topic) {
+		if reserved {
+			w.cancelReservation()
+		}
+
+		target, err := w.route(trigger.Topic)
+		if err != nil {
+			return err
+		}
+
+		return target.handleEventWithContext(ctx, trigger)
+	}
+
+	if err := w.acquire(reserved); err != nil {
+		return err
+	}
+	defer w.release()
+
+	return w.handle("event", func() error {
+		return handleEventWithContext(ctx, w.Worker, trigger)
+	})
+}
+
+func (w *poolWorker) handleHttpRequestWithContext(ctx context.Context, trigger *triggers.HttpRequest) (*triggers.HttpResponse, error) {
+	return w.dispatchHttpRequestWithContext(false, ctx, trigger)
+}
+
+// dispatchHttpRequestWithContext - Handles the request until the context is done, reserved is true if the trigger uses
+// a concurrency slot reserved for it
+func (w *poolWorker) dispatchHttpRequestWithContext(reserved bool, ctx context.Context, trigger *triggers.HttpRequest) (*triggers.HttpResponse, error) {
+	if err := w.acquire(reserved); err != nil {
+		return nil, err
+	}
+	defer w.release()
+
+	return w.handleHttp(func() (*triggers.HttpResponse, error) {
+		return handleHttpRequestWithContext(ctx, w.Worker, trigger)
+	})
+}
+
+func (w *poolWorker) HandleWebsocketMessage(trigger *triggers.WebsocketMessage) error {
+	return w.dispatchWebsocketMessage(false, trigger)
+}
+
+// dispatchWebsocketMessage - Handles the websocket message, reserved is true if the trigger uses a concurrency slot reserved for it
+func (w *poolWorker) dispatchWebsocketMessage(reserved bool, trigger *triggers.WebsocketMessage) error {
+	if err := w.acquire(reserved); err != nil {
+		return err
+	}
+	defer w.release()
+
+	return w.handle("websocket message", func() error {
+		return w.Worker.HandleWebsocketMessage(trigger)
+	})
+}
+
+func (w *poolWorker) HandleBucketNotification(trigger *triggers.BucketNotification) error {
+	return w.dispatchBucketNotification(false, trigger)
+}
+
+// dispatchBucketNotification - Handles the bucket notification, reserved is true if the trigger uses a concurrency slot reserved for it
+func (w *poolWorker) dispatchBucketNotification(reserved bool, trigger *triggers.BucketNotification) error {
+	if err := w.acquire(reserved); err != nil {
+		return err
+	}
+	defer w.release()
+
+	return w.handle("bucket notification", func() error {
+		return w.Worker.HandleBucketNotification(trigger)
+	})
+}
+
+func (w *poolWorker) HandleSchedule(trigger *triggers.Schedule) error {
+	return w.dispatchSchedule(false, trigger)
+}
+
+// dispatchSchedule - Handles the schedule, reserved is true if the trigger uses a concurrency slot reserved for it
+func (w *poolWorker) dispatchSchedule(reserved bool, trigger *triggers.Schedule) error {
+	if err := w.acquire(reserved); err != nil {
+		return err
+	}
+	defer w.release()
+
+	return w.handle("schedule", func() error {
+		return w.Worker.HandleSchedule(trigger)
+	})
+}
+
+func newPoolWorker(wrkr Worker, id string, maxConcurrency int, log logger.Logger, onRelease func()) *poolWorker {
+	var slots chan struct{} = nil
+	if maxConcurrency > 0 {
+		slots = make(chan struct{}, maxConcurrency)
+	}
+
+	return &poolWorker{
+		Worker:    wrkr,
+		id:        id,
+		log:       log,
+		slots:     slots,
+		onRelease: onRelease,
+	}
+}
+
+// reservedWorker - A pool worker handed out with a concurrency slot reserved for it when it was selected,
+// so concurrent callers can't be handed the same free slot. The first trigger dispatched to it uses the reservation
+type reservedWorker struct {
+	*poolWorker
+	// 1 until the reservation is used by a trigger or released
+	unused int32
+}
+
+// take - Claims the reservation, returning false if it has already been used or released
+func (r *reservedWorker) take() bool {
+	return atomic.CompareAndSwapInt32(&r.unused, 1, 0)
+}
+
+// Release - Returns the reserved concurrency slot to the pool if no trigger used it
+func (r *reservedWorker) Release() {
+	if r.take() {
+		r.cancelReservation()
+	}
+}
+
+func (r *reservedWorker) HandleEvent(trigger *triggers.Event) error {
+	return r.dispatchEvent(r.take(), trigger)
+}
+
+func (r *reservedWorker) HandleHttpRequest(trigger *triggers.HttpRequest) (*triggers.HttpResponse, error) {
+	return r.dispatchHttpRequest(r.take(), trigger)
+}
+
+func (r *reservedWorker) handleEventWithContext(ctx context.Context, trigger *triggers.Event) error {
+	return r.dispatchEventWithContext(r.take(), ctx, trigger)
+}
+
+func (r *reservedWorker) handleHttpRequestWithContext(ctx context.Context, trigger *triggers.HttpRequest) (*triggers.HttpResponse, error) {
+	return r.dispatchHttpRequestWithContext(r.take(), ctx, trigger)
+}
+
+func (r *reservedWorker) HandleWebsocketMessage(trigger *triggers.WebsocketMessage) error {
+	return r.dispatchWebsocketMessage(r.take(), trigger)
+}
+
+func (r *reservedWorker) HandleBucketNotification(trigger *triggers.BucketNotification) error {
+	return r.dispatchBucketNotification(r.take(), trigger)
+}
+
+func (r *reservedWorker) HandleSchedule(trigger *triggers.Schedule) error {
+	return r.dispatchSchedule(r.take(), trigger)
+}
+
+func newReservedWorker(w *poolWorker) *reservedWorker {
+	return &reservedWorker{
+		poolWorker: w,
+		unused:     1,
+	}
+}
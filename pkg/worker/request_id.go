@@ -0,0 +1,130 @@
+// Copyright 2021 Nitric Pty Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package worker
+
+import (
+	"encoding/hex"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/nitrictech/nitric/pkg/triggers"
+	"github.com/valyala/fasthttp"
+)
+
+// RequestIdHeader - The header carrying the ID of the inbound request to the function
+const RequestIdHeader = "x-nitric-request-id"
+
+// CorrelationIdHeader - The conventional header callers pass their own request ID in,
+// the request ID is echoed back in this header on HTTP responses
+const CorrelationIdHeader = "X-Request-Id"
+
+// TraceParentHeader - The W3C trace context header, its trace ID is used as the request ID when there isn't one
+const TraceParentHeader = "traceparent"
+
+// requestIdFromHeader - Returns the request ID from the trigger headers or an empty string if there isn't one,
+// gateways don't always canonicalize header names so they're matched case-insensitively
+func requestIdFromHeader(header map[string][]string) string {
+	for key, values := range header {
+		if strings.EqualFold(key, RequestIdHeader) && len(values) > 0 {
+			return values[0]
+		}
+	}
+	return ""
+}
+
+// traceIdFromTraceParent - Returns the trace ID of a traceparent header value, or an empty string if it's invalid
+func traceIdFromTraceParent(traceParent string) string {
+	parts := strings.Split(strings.TrimSpace(traceParent), "-")
+	if len(parts) < 4 || len(parts[1]) != 32 || parts[1] == strings.Repeat("0", 32) {
+		return ""
+	}
+
+	if _, err := hex.DecodeString(parts[1]); err != nil {
+		return ""
+	}
+
+	return strings.ToLower(parts[1])
+}
+
+// inboundRequestId - Returns the ID the caller correlates the request with, preferring the nitric request ID,
+// then X-Request-Id, then the trace ID of the traceparent header. Empty if the caller didn't provide one
+func inboundRequestId(header map[string][]string) string {
+	if requestId := requestIdFromHeader(header); requestId != "" {
+		return requestId
+	}
+
+	if requestId := headerValue(header, CorrelationIdHeader); requestId != "" {
+		return requestId
+	}
+
+	return traceIdFromTraceParent(headerValue(header, TraceParentHeader))
+}
+
+// requestIdWorker - Ensures every trigger carries a request ID, so plugin calls made while
+// handling it can be correlated with the inbound request
+type requestIdWorker struct {
+	Worker
+}
+
+// HandleHttpRequest - Keeps the request ID provided by the caller, generating one if there isn't one,
+// and echoes it back on the response
+func (w *requestIdWorker) HandleHttpRequest(trigger *triggers.HttpRequest) (*triggers.HttpResponse, error) {
+	requestId := inboundRequestId(trigger.Header)
+	if requestId == "" {
+		requestId = uuid.New().String()
+	}
+
+	if requestIdFromHeader(trigger.Header) == "" {
+		if trigger.Header == nil {
+			trigger.Header = make(map[string][]string)
+		}
+		http.Header(trigger.Header).Set(RequestIdHeader, requestId)
+	}
+
+	response, err := w.Worker.HandleHttpRequest(trigger)
+	if err != nil || response == nil {
+		return response, err
+	}
+
+	if response.Header == nil {
+		response.Header = &fasthttp.ResponseHeader{}
+	}
+
+	// Functions may already respond with their own correlation ID
+	if len(response.Header.Peek(CorrelationIdHeader)) == 0 {
+		response.Header.Set(CorrelationIdHeader, requestId)
+	}
+
+	return response, nil
+}
+
+// HandleEvent - Uses the event ID as the request ID, generating one if the event doesn't have one
+func (w *requestIdWorker) HandleEvent(trigger *triggers.Event) error {
+	if trigger.ID == "" {
+		trigger.ID = uuid.New().String()
+	}
+
+	return w.Worker.HandleEvent(trigger)
+}
+
+// WithRequestId - Assigns a request ID to triggers that don't already carry one
+func WithRequestId() WorkerDecorator {
+	return func(wrkr Worker) Worker {
+		return &requestIdWorker{
+			Worker: wrkr,
+		}
+	}
+}
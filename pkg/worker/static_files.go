@@ -0,0 +1,187 @@
+// Copyright 2021 Nitric Pty Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package worker
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/nitrictech/nitric/pkg/plugins/errors"
+	"github.com/nitrictech/nitric/pkg/plugins/errors/codes"
+	"github.com/nitrictech/nitric/pkg/plugins/storage"
+	"github.com/nitrictech/nitric/pkg/triggers"
+	"github.com/valyala/fasthttp"
+)
+
+// StaticIndexFile - The object served for requests to a static prefix or a path ending in /
+const StaticIndexFile = "index.html"
+
+// staticRoute - A path prefix served from a storage bucket
+type staticRoute struct {
+	prefix string
+	bucket string
+}
+
+// staticFilesWorker - Serves GET and HEAD requests under static prefixes from storage, other requests are dispatched to the worker
+type staticFilesWorker struct {
+	Worker
+	storage storage.StorageService
+	// Ordered longest prefix first, so the most specific route matches
+	routes []staticRoute
+}
+
+// normalizePrefix - Ensures a prefix starts with a / and doesn't end with one, so / becomes the empty prefix
+func normalizePrefix(prefix string) string {
+	return strings.TrimSuffix("/"+strings.Trim(strings.TrimSpace(prefix), "/"), "/")
+}
+
+// ParseStaticRoutes - Parses a comma separated list of prefix=bucket pairs, e.g. /assets=site-assets,/=site
+func ParseStaticRoutes(value string) (map[string]string, error) {
+	routes := make(map[string]string)
+
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" || strings.TrimSpace(parts[1]) == "" {
+			return nil, fmt.Errorf("invalid static route %s, expected prefix=bucket", entry)
+		}
+
+		routes[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+	}
+
+	return routes, nil
+}
+
+// route - Returns the route serving the path and the key of the object it maps to, or false if the path isn't static
+func (w *staticFilesWorker) route(requestPath string) (*staticRoute, string, bool) {
+	for i, r := range w.routes {
+		if requestPath != r.prefix && !strings.HasPrefix(requestPath, r.prefix+"/") {
+			continue
+		}
+
+		// Cleaning the remaining path resolves dot segments, so keys can't escape the prefix
+		key := strings.TrimPrefix(path.Clean("/"+strings.TrimPrefix(requestPath, r.prefix)), "/")
+		if key == "" || strings.HasSuffix(requestPath, "/") {
+			key = path.Join(key, StaticIndexFile)
+		}
+
+		return &w.routes[i], key, true
+	}
+
+	return nil, "", false
+}
+
+// notFound - The response for static paths without an object
+func notFound() *triggers.HttpResponse {
+	header := &fasthttp.ResponseHeader{}
+	header.SetContentType("text/plain; charset=utf-8")
+
+	return &triggers.HttpResponse{
+		Header:     header,
+		Body:       []byte("Not Found"),
+		StatusCode: 404,
+	}
+}
+
+// serve - Responds with the object, its content type taken from its metadata or detected when it has none
+func (w *staticFilesWorker) serve(trigger *triggers.HttpRequest, r *staticRoute, key string) (*triggers.HttpResponse, error) {
+	object, err := w.storage.Read(r.bucket, key)
+	if err != nil {
+		if errors.Code(err) == codes.NotFound {
+			return notFound(), nil
+		}
+
+		return nil, fmt.Errorf("failed to read static object %s from bucket %s: %v", key, r.bucket, err)
+	}
+
+	// Not every storage plugin stores metadata, so objects are still served when it can't be read
+	metadata := &storage.ObjectMetadata{}
+	if m, err := w.storage.GetMetadata(r.bucket, key); err == nil {
+		metadata = storage.ParseMetadata(m)
+	}
+
+	contentType := metadata.ContentType
+	if contentType == "" {
+		contentType = mime.TypeByExtension(path.Ext(key))
+	}
+	if contentType == "" {
+		contentType = http.DetectContentType(object)
+	}
+
+	header := &fasthttp.ResponseHeader{}
+	header.SetContentType(contentType)
+	if metadata.CacheControl != "" {
+		header.Set("Cache-Control", metadata.CacheControl)
+	}
+
+	response := &triggers.HttpResponse{
+		Header:     header,
+		StatusCode: 200,
+	}
+
+	if !strings.EqualFold(trigger.Method, "HEAD") {
+		response.Body = object
+	}
+
+	return response, nil
+}
+
+// HandleHttpRequest - Serves GET and HEAD requests under a static prefix, dispatching any other request to the worker
+func (w *staticFilesWorker) HandleHttpRequest(trigger *triggers.HttpRequest) (*triggers.HttpResponse, error) {
+	method := strings.ToUpper(trigger.Method)
+	if method != "GET" && method != "HEAD" {
+		return w.Worker.HandleHttpRequest(trigger)
+	}
+
+	r, key, ok := w.route(trigger.Path)
+	if !ok {
+		return w.Worker.HandleHttpRequest(trigger)
+	}
+
+	return w.serve(trigger, r, key)
+}
+
+// WithStaticFiles - Serves GET and HEAD requests under each path prefix from the objects of the mapped storage bucket,
+// without invoking the worker. Paths are mapped to the key following the prefix, with index.html served for the prefix
+// itself and paths ending in /. Objects that don't exist respond with a 404
+func WithStaticFiles(plugin storage.StorageService, routes map[string]string) WorkerDecorator {
+	staticRoutes := make([]staticRoute, 0, len(routes))
+	for prefix, bucket := range routes {
+		staticRoutes = append(staticRoutes, staticRoute{
+			prefix: normalizePrefix(prefix),
+			bucket: bucket,
+		})
+	}
+
+	sort.Slice(staticRoutes, func(i, j int) bool {
+		return len(staticRoutes[i].prefix) > len(staticRoutes[j].prefix)
+	})
+
+	return func(wrkr Worker) Worker {
+		return &staticFilesWorker{
+			Worker:  wrkr,
+			storage: plugin,
+			routes:  staticRoutes,
+		}
+	}
+}
@@ -0,0 +1,166 @@
+// Copyright 2021 Nitric Pty Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package worker
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/nitrictech/nitric/pkg/triggers"
+	"github.com/valyala/fasthttp"
+)
+
+// contextWorker - A worker that can abandon an in-flight trigger when its context is done
+type contextWorker interface {
+	handleHttpRequestWithContext(ctx context.Context, trigger *triggers.HttpRequest) (*triggers.HttpResponse, error)
+	handleEventWithContext(ctx context.Context, trigger *triggers.Event) error
+}
+
+type httpResult struct {
+	response *triggers.HttpResponse
+	err      error
+}
+
+// handleHttpRequestWithContext - Dispatches a HTTP trigger to the worker, returning early if the context is done
+// Workers that don't support cancellation continue handling the trigger in the background
+func handleHttpRequestWithContext(ctx context.Context, wrkr Worker, trigger *triggers.HttpRequest) (*triggers.HttpResponse, error) {
+	if cw, ok := wrkr.(contextWorker); ok {
+		return cw.handleHttpRequestWithContext(ctx, trigger)
+	}
+
+	result := make(chan httpResult, 1)
+	go func() {
+		// Recovered here as a panic can't be recovered from outside the goroutine it occurs in
+		response, err := callHttpHandler(func() (*triggers.HttpResponse, error) {
+			return wrkr.HandleHttpRequest(trigger)
+		})
+		result <- httpResult{response, err}
+	}()
+
+	select {
+	case r := <-result:
+		return r.response, r.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// handleEventWithContext - Dispatches an event trigger to the worker, returning early if the context is done
+// Workers that don't support cancellation continue handling the trigger in the background
+func handleEventWithContext(ctx context.Context, wrkr Worker, trigger *triggers.Event) error {
+	if cw, ok := wrkr.(contextWorker); ok {
+		return cw.handleEventWithContext(ctx, trigger)
+	}
+
+	result := make(chan error, 1)
+	go func() {
+		result <- callHandler(func() error {
+			return wrkr.HandleEvent(trigger)
+		})
+	}()
+
+	select {
+	case err := <-result:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// timeoutWorker - Limits the time a worker may spend handling a single trigger, and the time it may spend
+// handling HTTP requests that carry a deadline
+type timeoutWorker struct {
+	Worker
+	// 0 is unlimited
+	timeout time.Duration
+}
+
+// deadlineExceeded - The response to a HTTP request that wasn't handled before the timeout or its deadline
+func (w *timeoutWorker) deadlineExceeded(trigger *triggers.HttpRequest) *triggers.HttpResponse {
+	msg := fmt.Sprintf("Request was not handled within %v", w.timeout)
+	if !trigger.Deadline.IsZero() && !time.Now().Before(trigger.Deadline) {
+		msg = "Request was not handled before its deadline"
+	}
+
+	return &triggers.HttpResponse{
+		Header:     &fasthttp.ResponseHeader{},
+		Body:       []byte(msg),
+		StatusCode: 504,
+	}
+}
+
+// HandleHttpRequest - Returns a 504 response if the request isn't handled before the timeout or its deadline,
+// the deadline is passed to the worker so it can abandon the request once the caller has given up on it
+func (w *timeoutWorker) HandleHttpRequest(trigger *triggers.HttpRequest) (*triggers.HttpResponse, error) {
+	if w.timeout <= 0 && trigger.Deadline.IsZero() {
+		return w.Worker.HandleHttpRequest(trigger)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if w.timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, w.timeout)
+		defer cancel()
+	}
+
+	if !trigger.Deadline.IsZero() {
+		ctx, cancel = context.WithDeadline(ctx, trigger.Deadline)
+		defer cancel()
+	}
+
+	// The caller has already given up on the request, so it isn't dispatched
+	if ctx.Err() == context.DeadlineExceeded {
+		return w.deadlineExceeded(trigger), nil
+	}
+
+	response, err := handleHttpRequestWithContext(ctx, w.Worker, trigger)
+
+	if err != nil && ctx.Err() == context.DeadlineExceeded {
+		return w.deadlineExceeded(trigger), nil
+	}
+
+	return response, err
+}
+
+// HandleEvent - Returns an error if the event isn't handled before the timeout
+func (w *timeoutWorker) HandleEvent(trigger *triggers.Event) error {
+	if w.timeout <= 0 {
+		return w.Worker.HandleEvent(trigger)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), w.timeout)
+	defer cancel()
+
+	err := handleEventWithContext(ctx, w.Worker, trigger)
+
+	if err != nil && ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("event %s on topic %s was not handled within %v", trigger.ID, trigger.Topic, w.timeout)
+	}
+
+	return err
+}
+
+// WithRequestTimeout - Limits the time taken to handle each HTTP or event trigger, a timeout of 0 is unlimited.
+// HTTP requests with a deadline are also limited to it, whatever the timeout
+func WithRequestTimeout(timeout time.Duration) WorkerDecorator {
+	return func(wrkr Worker) Worker {
+		return &timeoutWorker{
+			Worker:  wrkr,
+			timeout: timeout,
+		}
+	}
+}
@@ -0,0 +1,106 @@
+// Copyright 2021 Nitric Pty Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package worker
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/nitrictech/nitric/pkg/triggers"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/nitrictech/nitric/pkg/worker"
+
+// tracingWorker - Creates a span for each trigger dispatched to a worker
+type tracingWorker struct {
+	Worker
+	tracer     trace.Tracer
+	propagator propagation.TextMapPropagator
+}
+
+// HandleHttpRequest - Continues any trace propagated in the request headers,
+// passing the dispatch span on to the function in the same headers
+func (w *tracingWorker) HandleHttpRequest(trigger *triggers.HttpRequest) (*triggers.HttpResponse, error) {
+	if trigger.Header == nil {
+		trigger.Header = make(map[string][]string)
+	}
+	carrier := propagation.HeaderCarrier(http.Header(trigger.Header))
+
+	ctx := w.propagator.Extract(context.Background(), carrier)
+	ctx, span := w.tracer.Start(
+		ctx,
+		"worker.HandleHttpRequest",
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			attribute.String("http.method", trigger.Method),
+			attribute.String("http.target", trigger.Path),
+		),
+	)
+	defer span.End()
+
+	w.propagator.Inject(ctx, carrier)
+
+	response, err := w.Worker.HandleHttpRequest(trigger)
+
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	} else if response != nil {
+		span.SetAttributes(attribute.Int("http.status_code", response.StatusCode))
+		if response.StatusCode >= 500 {
+			span.SetStatus(codes.Error, http.StatusText(response.StatusCode))
+		}
+	}
+
+	return response, err
+}
+
+// HandleEvent - Creates a span for the event, events carry no trace context so this starts a new trace
+func (w *tracingWorker) HandleEvent(trigger *triggers.Event) error {
+	_, span := w.tracer.Start(
+		context.Background(),
+		"worker.HandleEvent",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			attribute.String("messaging.destination", trigger.Topic),
+			attribute.String("messaging.message_id", trigger.ID),
+		),
+	)
+	defer span.End()
+
+	err := w.Worker.HandleEvent(trigger)
+
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	return err
+}
+
+// WithTracing - Traces trigger dispatch using the given provider, propagating W3C trace context
+func WithTracing(tp trace.TracerProvider) WorkerDecorator {
+	return func(wrkr Worker) Worker {
+		return &tracingWorker{
+			Worker:     wrkr,
+			tracer:     tp.Tracer(instrumentationName),
+			propagator: propagation.TraceContext{},
+		}
+	}
+}
@@ -0,0 +1,80 @@
+// Copyright 2021 Nitric Pty Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package worker
+
+import (
+	"fmt"
+
+	"github.com/nitrictech/nitric/pkg/triggers"
+)
+
+// Handler - Handles a HTTP request or event trigger, the response is nil for events
+type Handler func(trigger triggers.Trigger) (*triggers.HttpResponse, error)
+
+// TriggerMiddleware - Wraps the dispatch of triggers to the worker, middleware may respond to
+// a trigger itself without calling next, e.g. to reject an unauthorized request
+type TriggerMiddleware func(next Handler) Handler
+
+// middlewareWorker - Passes HTTP requests and events through a middleware chain before dispatching them to the worker
+type middlewareWorker struct {
+	Worker
+	handler Handler
+}
+
+// dispatch - Hands the trigger to the worker once it has passed through every middleware
+func (w *middlewareWorker) dispatch(trigger triggers.Trigger) (*triggers.HttpResponse, error) {
+	switch t := trigger.(type) {
+	case *triggers.HttpRequest:
+		return w.Worker.HandleHttpRequest(t)
+	case *triggers.Event:
+		return nil, w.Worker.HandleEvent(t)
+	}
+
+	return nil, fmt.Errorf("unsupported trigger type %s", trigger.GetTriggerType())
+}
+
+// HandleHttpRequest - Handles the request with the middleware chain
+func (w *middlewareWorker) HandleHttpRequest(trigger *triggers.HttpRequest) (*triggers.HttpResponse, error) {
+	response, err := w.handler(trigger)
+	if err == nil && response == nil {
+		return nil, fmt.Errorf("trigger middleware returned no response for HTTP request")
+	}
+
+	return response, err
+}
+
+// HandleEvent - Handles the event with the middleware chain, any response is ignored
+func (w *middlewareWorker) HandleEvent(trigger *triggers.Event) error {
+	_, err := w.handler(trigger)
+
+	return err
+}
+
+// WithTriggerMiddleware - Passes HTTP requests and events through the middleware before they reach the worker,
+// middleware is applied in order, so the first middleware will be the first to handle a trigger
+func WithTriggerMiddleware(middleware ...TriggerMiddleware) WorkerDecorator {
+	return func(wrkr Worker) Worker {
+		w := &middlewareWorker{
+			Worker: wrkr,
+		}
+
+		w.handler = w.dispatch
+		for i := len(middleware) - 1; i >= 0; i-- {
+			w.handler = middleware[i](w.handler)
+		}
+
+		return w
+	}
+}
@@ -15,10 +15,7 @@
 package worker
 
 import (
-	"bytes"
 	"fmt"
-	"io/ioutil"
-	"net/http"
 
 	"github.com/nitrictech/nitric/pkg/triggers"
 )
@@ -26,6 +23,9 @@ import (
 type Worker interface {
 	HandleEvent(trigger *triggers.Event) error
 	HandleHttpRequest(trigger *triggers.HttpRequest) (*triggers.HttpResponse, error)
+	HandleWebsocketMessage(trigger *triggers.WebsocketMessage) error
+	HandleBucketNotification(trigger *triggers.BucketNotification) error
+	HandleSchedule(trigger *triggers.Schedule) error
 }
 
 type UnimplementedWorker struct{}
@@ -34,10 +34,21 @@ func (*UnimplementedWorker) HandleEvent(trigger *triggers.Event) error {
 	return fmt.Errorf("UNIMPLEMENTED")
 }
 
-func (*UnimplementedWorker) HandleHttpRequest(trigger *triggers.HttpRequest) *http.Response {
-	return &http.Response{
-		Status:     "Unimplemented",
+func (*UnimplementedWorker) HandleHttpRequest(trigger *triggers.HttpRequest) (*triggers.HttpResponse, error) {
+	return &triggers.HttpResponse{
 		StatusCode: 501,
-		Body:       ioutil.NopCloser(bytes.NewReader([]byte("HTTP Handler Unimplemented"))),
-	}
+		Body:       []byte("HTTP Handler Unimplemented"),
+	}, nil
+}
+
+func (*UnimplementedWorker) HandleWebsocketMessage(trigger *triggers.WebsocketMessage) error {
+	return fmt.Errorf("UNIMPLEMENTED")
+}
+
+func (*UnimplementedWorker) HandleBucketNotification(trigger *triggers.BucketNotification) error {
+	return fmt.Errorf("UNIMPLEMENTED")
+}
+
+func (*UnimplementedWorker) HandleSchedule(trigger *triggers.Schedule) error {
+	return fmt.Errorf("UNIMPLEMENTED")
 }
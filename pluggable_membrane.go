@@ -23,12 +23,15 @@ import (
 	"strings"
 
 	"github.com/nitrictech/nitric/pkg/membrane"
+	"github.com/nitrictech/nitric/pkg/plugins/config"
 	"github.com/nitrictech/nitric/pkg/plugins/document"
 	"github.com/nitrictech/nitric/pkg/plugins/events"
 	"github.com/nitrictech/nitric/pkg/plugins/gateway"
 	"github.com/nitrictech/nitric/pkg/plugins/queue"
+	"github.com/nitrictech/nitric/pkg/plugins/secret"
 	"github.com/nitrictech/nitric/pkg/plugins/storage"
 	"github.com/nitrictech/nitric/pkg/providers"
+	"github.com/nitrictech/nitric/pkg/providers/auto"
 	"github.com/nitrictech/nitric/pkg/utils"
 )
 
@@ -60,8 +63,13 @@ func main() {
 	}
 	var serviceFactory providers.ServiceFactory = nil
 
-	// Load the Plugin Factory
-	if plug, err := plugin.Open(fmt.Sprintf("%s/%s", pluginDir, serviceFactoryPluginFile)); err == nil {
+	// Select the built in provider by name when configured, instead of loading a plugin
+	if utils.GetEnv(auto.NITRIC_PROVIDER_ENV, "") != "" {
+		if serviceFactory, err = auto.New(); err != nil {
+			log.Fatalf("failed to select provider: %v", err)
+		}
+	} else if plug, err := plugin.Open(fmt.Sprintf("%s/%s", pluginDir, serviceFactoryPluginFile)); err == nil {
+		// Load the Plugin Factory
 		if symbol, err := plug.Lookup("New"); err == nil {
 			if newFunc, ok := symbol.(func() (providers.ServiceFactory, error)); ok {
 				if serviceFactoryPlugin, err := newFunc(); err == nil {
@@ -80,6 +88,8 @@ func main() {
 	var gatewayService gateway.GatewayService = nil
 	var queueService queue.QueueService = nil
 	var storageService storage.StorageService = nil
+	var secretService secret.SecretService = nil
+	var configService config.ConfigService = nil
 
 	// Load the document service
 	if documentService, err = serviceFactory.NewDocumentService(); err != nil {
@@ -101,6 +111,14 @@ func main() {
 	if storageService, err = serviceFactory.NewStorageService(); err != nil {
 		log.Fatal(err)
 	}
+	// Load the secret service
+	if secretService, err = serviceFactory.NewSecretService(); err != nil {
+		log.Fatal(err)
+	}
+	// Load the config service
+	if configService, err = serviceFactory.NewConfigService(); err != nil {
+		log.Fatal(err)
+	}
 
 	// Construct and validate the membrane server
 	membraneServer, err := membrane.New(&membrane.MembraneOptions{
@@ -112,6 +130,8 @@ func main() {
 		StoragePlugin:           storageService,
 		GatewayPlugin:           gatewayService,
 		QueuePlugin:             queueService,
+		SecretPlugin:            secretService,
+		ConfigPlugin:            configService,
 		TolerateMissingServices: tolerateMissing,
 	})
 
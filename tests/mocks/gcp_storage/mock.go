@@ -19,15 +19,20 @@ import (
 	"context"
 	"fmt"
 	"io/ioutil"
+	"sort"
+	"strings"
 
 	"cloud.google.com/go/storage"
 	ifaces_gcloud_storage "github.com/nitrictech/nitric/pkg/ifaces/gcloud_storage"
+	"google.golang.org/api/iterator"
 )
 
 type MockStorageClient struct {
 	ifaces_gcloud_storage.StorageClient
 	buckets []string
 	storage *map[string]map[string][]byte
+	// The attributes objects were written with, keyed by bucket and then object key
+	attrs map[string]map[string]*storage.ObjectAttrs
 }
 
 func (s *MockStorageClient) Bucket(name string) ifaces_gcloud_storage.BucketHandle {
@@ -78,6 +83,34 @@ func (s *MockBucketHandle) Object(name string) ifaces_gcloud_storage.ObjectHandl
 	}
 }
 
+func (s *MockBucketHandle) Objects(ctx context.Context, query *storage.Query) ifaces_gcloud_storage.ObjectIterator {
+	keys := make([]string, 0)
+	for key := range (*s.client.storage)[s.name] {
+		if strings.HasPrefix(key, query.Prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	return &MockObjectIterator{keys: keys}
+}
+
+// MockObjectIterator - Iterates over object keys in order
+type MockObjectIterator struct {
+	keys []string
+}
+
+func (s *MockObjectIterator) Next() (*storage.ObjectAttrs, error) {
+	if len(s.keys) == 0 {
+		return nil, iterator.Done
+	}
+
+	key := s.keys[0]
+	s.keys = s.keys[1:]
+
+	return &storage.ObjectAttrs{Name: key}, nil
+}
+
 type MockObjectHandle struct {
 	//ifaces.ObjectHandle
 	bucket string
@@ -90,9 +123,22 @@ func (s *MockObjectHandle) NewWriter(ctx context.Context) ifaces_gcloud_storage.
 		bucket: s.bucket,
 		key:    s.name,
 		client: s.client,
+		attrs:  &storage.ObjectAttrs{},
 	}
 }
 
+func (s *MockObjectHandle) Attrs(ctx context.Context) (*storage.ObjectAttrs, error) {
+	if _, ok := (*s.client.storage)[s.bucket][s.name]; !ok {
+		return nil, storage.ErrObjectNotExist
+	}
+
+	if attrs, ok := s.client.attrs[s.bucket][s.name]; ok {
+		return attrs, nil
+	}
+
+	return &storage.ObjectAttrs{}, nil
+}
+
 func (s *MockObjectHandle) NewReader(ctx context.Context) (ifaces_gcloud_storage.Reader, error) {
 	for _, b := range s.client.buckets {
 		if s.bucket == b {
@@ -110,6 +156,25 @@ func (s *MockObjectHandle) NewReader(ctx context.Context) (ifaces_gcloud_storage
 	return nil, fmt.Errorf("cannot not read from bucket that does not exist")
 }
 
+func (s *MockObjectHandle) NewRangeReader(ctx context.Context, offset int64, length int64) (ifaces_gcloud_storage.Reader, error) {
+	r, err := s.NewReader(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	data, _ := ioutil.ReadAll(r)
+	if offset >= int64(len(data)) {
+		return nil, fmt.Errorf("range offset %d is beyond the end of object with key %s", offset, s.name)
+	}
+
+	end := int64(len(data))
+	if length >= 0 && offset+length < end {
+		end = offset + length
+	}
+
+	return ioutil.NopCloser(bytes.NewReader(data[offset:end])), nil
+}
+
 func (s *MockObjectHandle) Delete(ctx context.Context) error {
 	for _, b := range s.client.buckets {
 		if s.bucket == b {
@@ -132,6 +197,13 @@ type MockWriter struct {
 	bucket string
 	key    string
 	client *MockStorageClient
+	attrs  *storage.ObjectAttrs
+}
+
+func (s *MockWriter) SetMetadata(contentType string, cacheControl string, metadata map[string]string) {
+	s.attrs.ContentType = contentType
+	s.attrs.CacheControl = cacheControl
+	s.attrs.Metadata = metadata
 }
 
 func (s *MockWriter) Write(p []byte) (n int, err error) {
@@ -144,6 +216,10 @@ func (s *MockWriter) Write(p []byte) (n int, err error) {
 			}
 			// Store the item...
 			store[s.bucket][s.key] = p
+			if s.client.attrs[s.bucket] == nil {
+				s.client.attrs[s.bucket] = make(map[string]*storage.ObjectAttrs)
+			}
+			s.client.attrs[s.bucket][s.key] = s.attrs
 			return len(p), nil
 		}
 	}
@@ -160,5 +236,6 @@ func NewStorageClient(buckets []string, storage *map[string]map[string][]byte) i
 	return &MockStorageClient{
 		buckets: buckets,
 		storage: storage,
+		attrs:   make(map[string]map[string]*storage.ObjectAttrs),
 	}
 }
@@ -96,6 +96,11 @@ func (s *MockPubsubClient) Topic(name string) ifaces_pubsub.Topic {
 	}
 }
 
+func (s *MockPubsubClient) Close() error {
+	// do nothing, no need to close a mock.
+	return nil
+}
+
 func (s *MockPubsubClient) Topics(context.Context) ifaces_pubsub.TopicIterator {
 	return &MockTopicIterator{
 		c:   s,
@@ -224,8 +229,11 @@ func (s *MockPublishResult) Get(context.Context) (string, error) {
 }
 
 type MockBaseClient struct {
-	Messages      map[string][]ifaces_pubsub.Message
-	CompleteError error
+	Messages         map[string][]ifaces_pubsub.Message
+	CompleteError    error
+	LeaseExtendError error
+	// The ack deadlines requested for each ack id
+	AckDeadlines map[string]int32
 }
 
 func (m MockBaseClient) Close() error {
@@ -240,6 +248,18 @@ func (m MockBaseClient) Acknowledge(ctx context.Context, req *pubsubpb.Acknowled
 	return nil
 }
 
+func (m MockBaseClient) ModifyAckDeadline(ctx context.Context, req *pubsubpb.ModifyAckDeadlineRequest, opts ...gax.CallOption) error {
+	if m.LeaseExtendError != nil {
+		return m.LeaseExtendError
+	}
+	if m.AckDeadlines != nil {
+		for _, id := range req.AckIds {
+			m.AckDeadlines[id] = req.AckDeadlineSeconds
+		}
+	}
+	return nil
+}
+
 func (m MockBaseClient) Pull(ctx context.Context, req *pubsubpb.PullRequest, opts ...gax.CallOption) (*pubsubpb.PullResponse, error) {
 	sub := req.Subscription
 
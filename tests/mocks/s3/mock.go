@@ -18,6 +18,8 @@ import (
 	"bytes"
 	"fmt"
 	"io/ioutil"
+	"sort"
+	"strings"
 	"sync"
 
 	"github.com/aws/aws-sdk-go/aws"
@@ -36,6 +38,167 @@ type MockS3Client struct {
 	s3iface.S3API
 	buckets []*MockBucket
 	storage *map[string]map[string][]byte
+	// Parts of in progress multipart uploads, keyed by upload ID
+	uploads map[string][][]byte
+	// The sizes of the parts of completed multipart uploads, keyed by object key
+	UploadedParts map[string][]int
+	// The properties and metadata objects were written with, keyed by object key
+	metadata map[string]*s3.HeadObjectOutput
+}
+
+// setMetadata - Records the metadata an object is written with, the lock must be held
+func (s *MockS3Client) setMetadata(key string, contentType *string, cacheControl *string, metadata map[string]*string) {
+	s.metadata[key] = &s3.HeadObjectOutput{
+		ContentType:  contentType,
+		CacheControl: cacheControl,
+		Metadata:     metadata,
+	}
+}
+
+// findBucket - Returns the named bucket's objects, creating them if necessary, the lock must be held
+func (s *MockS3Client) findBucket(name string) (map[string][]byte, bool) {
+	for _, b := range s.buckets {
+		if b.Name == name {
+			store := *s.storage
+			if store[b.Name] == nil {
+				store[b.Name] = make(map[string][]byte)
+			}
+			return store[b.Name], true
+		}
+	}
+
+	return nil, false
+}
+
+func (s *MockS3Client) CreateMultipartUpload(in *s3.CreateMultipartUploadInput) (*s3.CreateMultipartUploadOutput, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	if _, ok := s.findBucket(*in.Bucket); !ok {
+		return nil, fmt.Errorf("bucket does not exist")
+	}
+
+	uploadId := fmt.Sprintf("upload-%d", len(s.uploads))
+	s.uploads[uploadId] = make([][]byte, 0)
+	s.setMetadata(*in.Key, in.ContentType, in.CacheControl, in.Metadata)
+
+	return &s3.CreateMultipartUploadOutput{
+		UploadId: aws.String(uploadId),
+	}, nil
+}
+
+func (s *MockS3Client) UploadPart(in *s3.UploadPartInput) (*s3.UploadPartOutput, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	parts, ok := s.uploads[*in.UploadId]
+	if !ok {
+		return nil, fmt.Errorf("upload does not exist")
+	}
+
+	part, _ := ioutil.ReadAll(in.Body)
+	s.uploads[*in.UploadId] = append(parts, part)
+
+	return &s3.UploadPartOutput{
+		ETag: aws.String(fmt.Sprintf("etag-%d", *in.PartNumber)),
+	}, nil
+}
+
+func (s *MockS3Client) CompleteMultipartUpload(in *s3.CompleteMultipartUploadInput) (*s3.CompleteMultipartUploadOutput, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	parts, ok := s.uploads[*in.UploadId]
+	if !ok {
+		return nil, fmt.Errorf("upload does not exist")
+	}
+	delete(s.uploads, *in.UploadId)
+
+	bucket, ok := s.findBucket(*in.Bucket)
+	if !ok {
+		return nil, fmt.Errorf("bucket does not exist")
+	}
+
+	object := make([]byte, 0)
+	sizes := make([]int, 0)
+	for _, part := range parts {
+		object = append(object, part...)
+		sizes = append(sizes, len(part))
+	}
+	bucket[*in.Key] = object
+	s.UploadedParts[*in.Key] = sizes
+
+	return &s3.CompleteMultipartUploadOutput{}, nil
+}
+
+func (s *MockS3Client) AbortMultipartUpload(in *s3.AbortMultipartUploadInput) (*s3.AbortMultipartUploadOutput, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	delete(s.uploads, *in.UploadId)
+
+	return &s3.AbortMultipartUploadOutput{}, nil
+}
+
+func (s *MockS3Client) ListObjectsV2Pages(in *s3.ListObjectsV2Input, fn func(*s3.ListObjectsV2Output, bool) bool) error {
+	s.RLock()
+	defer s.RUnlock()
+
+	for _, b := range s.buckets {
+		if b.Name == *in.Bucket {
+			contents := make([]*s3.Object, 0)
+			for key := range (*s.storage)[b.Name] {
+				if strings.HasPrefix(key, aws.StringValue(in.Prefix)) {
+					contents = append(contents, &s3.Object{
+						Key: aws.String(key),
+					})
+				}
+			}
+
+			fn(&s3.ListObjectsV2Output{
+				Contents: contents,
+			}, true)
+			return nil
+		}
+	}
+
+	return fmt.Errorf("bucket does not exist")
+}
+
+// ListObjectsV2 - Lists a page of objects in key order, continuation tokens are the last key of the previous page
+func (s *MockS3Client) ListObjectsV2(in *s3.ListObjectsV2Input) (*s3.ListObjectsV2Output, error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	for _, b := range s.buckets {
+		if b.Name == *in.Bucket {
+			keys := make([]string, 0)
+			for key := range (*s.storage)[b.Name] {
+				if strings.HasPrefix(key, aws.StringValue(in.Prefix)) && key > aws.StringValue(in.ContinuationToken) {
+					keys = append(keys, key)
+				}
+			}
+			sort.Strings(keys)
+
+			out := &s3.ListObjectsV2Output{
+				Contents: make([]*s3.Object, 0),
+			}
+			if in.MaxKeys != nil && int64(len(keys)) > *in.MaxKeys {
+				keys = keys[:*in.MaxKeys]
+				out.NextContinuationToken = aws.String(keys[len(keys)-1])
+			}
+
+			for _, key := range keys {
+				out.Contents = append(out.Contents, &s3.Object{
+					Key: aws.String(key),
+				})
+			}
+
+			return out, nil
+		}
+	}
+
+	return nil, fmt.Errorf("bucket does not exist")
 }
 
 func (s *MockS3Client) DeleteObject(in *s3.DeleteObjectInput) (*s3.DeleteObjectOutput, error) {
@@ -114,6 +277,7 @@ func (s *MockS3Client) PutObject(in *s3.PutObjectInput) (*s3.PutObjectOutput, er
 			bytes, _ := ioutil.ReadAll(reader)
 
 			store[b.Name][storeKey] = bytes
+			s.setMetadata(storeKey, in.ContentType, in.CacheControl, in.Metadata)
 
 			return &s3.PutObjectOutput{}, nil
 		}
@@ -148,9 +312,32 @@ func (s *MockS3Client) GetObject(in *s3.GetObjectInput) (*s3.GetObjectOutput, er
 	return nil, fmt.Errorf("bucket does not exist")
 }
 
+func (s *MockS3Client) HeadObject(in *s3.HeadObjectInput) (*s3.HeadObjectOutput, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	bucket, ok := s.findBucket(*in.Bucket)
+	if !ok {
+		return nil, fmt.Errorf("bucket does not exist")
+	}
+
+	if _, ok := bucket[*in.Key]; !ok {
+		return nil, fmt.Errorf("key does not exists in bucket %s", *in.Bucket)
+	}
+
+	if metadata, ok := s.metadata[*in.Key]; ok {
+		return metadata, nil
+	}
+
+	return &s3.HeadObjectOutput{}, nil
+}
+
 func NewStorageClient(buckets []*MockBucket, storage *map[string]map[string][]byte) s3iface.S3API {
 	return &MockS3Client{
-		buckets: buckets,
-		storage: storage,
+		buckets:       buckets,
+		storage:       storage,
+		uploads:       make(map[string][][]byte),
+		UploadedParts: make(map[string][]int),
+		metadata:      make(map[string]*s3.HeadObjectOutput),
 	}
 }
@@ -15,13 +15,17 @@
 package worker_mocks
 
 import (
+	"sync"
+
 	triggers2 "github.com/nitrictech/nitric/pkg/triggers"
 )
 
 type MockWorkerOptions struct {
 	ReturnHttp *triggers2.HttpResponse
 	HttpError  error
-	eventError error
+	EventError error
+	// When set the worker panics with this value handling HTTP requests and events
+	Panic interface{}
 }
 
 // MockWorker - A mock worker interface for testing
@@ -29,33 +33,85 @@ type MockWorker struct {
 	returnHttp       *triggers2.HttpResponse
 	httpError        error
 	eventError       error
+	panicValue       interface{}
 	ReceivedEvents   []*triggers2.Event
 	ReceivedRequests []*triggers2.HttpRequest
+	ReceivedMessages []*triggers2.WebsocketMessage
+	// Bucket notifications received by the worker
+	ReceivedNotifications []*triggers2.BucketNotification
+	// Schedules received by the worker, appended concurrently by scheduling gateways
+	receivedSchedules []*triggers2.Schedule
+	lock              sync.Mutex
 }
 
 func (m *MockWorker) HandleEvent(trigger *triggers2.Event) error {
 	m.ReceivedEvents = append(m.ReceivedEvents, trigger)
 
+	if m.panicValue != nil {
+		panic(m.panicValue)
+	}
+
 	return m.eventError
 }
 
 func (m *MockWorker) HandleHttpRequest(trigger *triggers2.HttpRequest) (*triggers2.HttpResponse, error) {
 	m.ReceivedRequests = append(m.ReceivedRequests, trigger)
 
+	if m.panicValue != nil {
+		panic(m.panicValue)
+	}
+
 	return m.returnHttp, m.httpError
 }
 
+func (m *MockWorker) HandleWebsocketMessage(trigger *triggers2.WebsocketMessage) error {
+	m.ReceivedMessages = append(m.ReceivedMessages, trigger)
+
+	return nil
+}
+
+func (m *MockWorker) HandleBucketNotification(trigger *triggers2.BucketNotification) error {
+	m.ReceivedNotifications = append(m.ReceivedNotifications, trigger)
+
+	return nil
+}
+
+func (m *MockWorker) HandleSchedule(trigger *triggers2.Schedule) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.receivedSchedules = append(m.receivedSchedules, trigger)
+
+	return nil
+}
+
+// ReceivedSchedules - Returns a copy of the schedules received by the worker
+func (m *MockWorker) ReceivedSchedules() []*triggers2.Schedule {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	return append([]*triggers2.Schedule{}, m.receivedSchedules...)
+}
+
 func (m *MockWorker) Reset() {
 	m.ReceivedEvents = make([]*triggers2.Event, 0)
 	m.ReceivedRequests = make([]*triggers2.HttpRequest, 0)
+	m.ReceivedMessages = make([]*triggers2.WebsocketMessage, 0)
+	m.ReceivedNotifications = make([]*triggers2.BucketNotification, 0)
+	m.lock.Lock()
+	m.receivedSchedules = make([]*triggers2.Schedule, 0)
+	m.lock.Unlock()
 }
 
 func NewMockWorker(opts *MockWorkerOptions) *MockWorker {
 	return &MockWorker{
-		httpError:        opts.HttpError,
-		returnHttp:       opts.ReturnHttp,
-		eventError:       opts.eventError,
-		ReceivedEvents:   make([]*triggers2.Event, 0),
-		ReceivedRequests: make([]*triggers2.HttpRequest, 0),
+		httpError:             opts.HttpError,
+		returnHttp:            opts.ReturnHttp,
+		eventError:            opts.EventError,
+		panicValue:            opts.Panic,
+		ReceivedEvents:        make([]*triggers2.Event, 0),
+		ReceivedRequests:      make([]*triggers2.HttpRequest, 0),
+		ReceivedMessages:      make([]*triggers2.WebsocketMessage, 0),
+		ReceivedNotifications: make([]*triggers2.BucketNotification, 0),
+		receivedSchedules:     make([]*triggers2.Schedule, 0),
 	}
 }
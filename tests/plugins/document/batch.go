@@ -0,0 +1,131 @@
+// Copyright 2021 Nitric Pty Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package document_suite
+
+import (
+	"github.com/nitrictech/nitric/pkg/plugins/document"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func BatchTests(docPlugin document.DocumentService) {
+	Context("SetBatch", func() {
+		When("No items are provided", func() {
+			It("Should return error", func() {
+				_, err := docPlugin.SetBatch([]*document.BatchSetItem{})
+				Expect(err).Should(HaveOccurred())
+			})
+		})
+		When("Valid SetBatch", func() {
+			It("Should store each item successfully", func() {
+				results, err := docPlugin.SetBatch([]*document.BatchSetItem{
+					{Key: &UserKey1, Content: UserItem1},
+					{Key: &UserKey2, Content: UserItem2},
+				})
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(results).To(HaveLen(2))
+				Expect(results[0].Err).ShouldNot(HaveOccurred())
+				Expect(results[1].Err).ShouldNot(HaveOccurred())
+
+				doc, err := docPlugin.Get(&UserKey2)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(doc.Content["email"]).To(BeEquivalentTo(UserItem2["email"]))
+			})
+		})
+		When("An item has an invalid key", func() {
+			It("Should only fail the invalid item", func() {
+				results, err := docPlugin.SetBatch([]*document.BatchSetItem{
+					{Key: &document.Key{Collection: &document.Collection{Name: "users"}}, Content: UserItem1},
+					{Key: &UserKey3, Content: UserItem3},
+				})
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(results).To(HaveLen(2))
+				Expect(results[0].Err).Should(HaveOccurred())
+				Expect(results[1].Err).ShouldNot(HaveOccurred())
+			})
+		})
+	})
+	Context("GetBatch", func() {
+		When("No keys are provided", func() {
+			It("Should return error", func() {
+				_, err := docPlugin.GetBatch([]*document.Key{})
+				Expect(err).Should(HaveOccurred())
+			})
+		})
+		When("Valid GetBatch", func() {
+			It("Should return each document in the order requested", func() {
+				docPlugin.Set(&UserKey1, UserItem1)
+				docPlugin.Set(&Customer1.Orders[0].Key, Customer1.Orders[0].Content)
+
+				results, err := docPlugin.GetBatch([]*document.Key{&Customer1.Orders[0].Key, &UserKey1})
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(results).To(HaveLen(2))
+				Expect(results[0].Err).ShouldNot(HaveOccurred())
+				Expect(results[0].Document.Key).To(Equal(&Customer1.Orders[0].Key))
+				Expect(results[0].Document.Content).To(BeEquivalentTo(Customer1.Orders[0].Content))
+				Expect(results[1].Err).ShouldNot(HaveOccurred())
+				Expect(results[1].Document.Content["email"]).To(BeEquivalentTo(UserItem1["email"]))
+			})
+		})
+		When("A document doesn't exist", func() {
+			It("Should only fail the missing document", func() {
+				docPlugin.Set(&UserKey1, UserItem1)
+
+				missingKey := document.Key{Collection: &document.Collection{Name: "users"}, Id: "missing@server.com"}
+				results, err := docPlugin.GetBatch([]*document.Key{&missingKey, &UserKey1})
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(results).To(HaveLen(2))
+				Expect(results[0].Document).To(BeNil())
+				Expect(results[0].Err).Should(HaveOccurred())
+				Expect(results[1].Err).ShouldNot(HaveOccurred())
+			})
+		})
+	})
+	Context("DeleteBatch", func() {
+		When("No keys are provided", func() {
+			It("Should return error", func() {
+				_, err := docPlugin.DeleteBatch([]*document.Key{})
+				Expect(err).Should(HaveOccurred())
+			})
+		})
+		When("Valid DeleteBatch", func() {
+			It("Should delete each item successfully", func() {
+				docPlugin.Set(&UserKey1, UserItem1)
+				docPlugin.Set(&UserKey2, UserItem2)
+
+				results, err := docPlugin.DeleteBatch([]*document.Key{&UserKey1, &UserKey2})
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(results).To(HaveLen(2))
+				Expect(results[0].Err).ShouldNot(HaveOccurred())
+				Expect(results[1].Err).ShouldNot(HaveOccurred())
+
+				doc, err := docPlugin.Get(&UserKey1)
+				Expect(doc).To(BeNil())
+				Expect(err).Should(HaveOccurred())
+			})
+		})
+		When("A key is invalid", func() {
+			It("Should only fail the invalid key", func() {
+				docPlugin.Set(&UserKey3, UserItem3)
+
+				results, err := docPlugin.DeleteBatch([]*document.Key{{Id: "1"}, &UserKey3})
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(results).To(HaveLen(2))
+				Expect(results[0].Err).Should(HaveOccurred())
+				Expect(results[1].Err).ShouldNot(HaveOccurred())
+			})
+		})
+	})
+}
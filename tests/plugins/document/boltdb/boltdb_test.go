@@ -16,12 +16,19 @@ package boltdb_service_test
 
 import (
 	"os"
+	"path/filepath"
+	"time"
 
+	"github.com/nitrictech/nitric/pkg/logger"
+	"github.com/nitrictech/nitric/pkg/plugins/document"
 	boltdb_service "github.com/nitrictech/nitric/pkg/plugins/document/boltdb"
 	"github.com/nitrictech/nitric/pkg/utils"
 
+	"github.com/asdine/storm"
 	test "github.com/nitrictech/nitric/tests/plugins/document"
 	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
 )
 
 var _ = Describe("Bolt", func() {
@@ -46,7 +53,74 @@ var _ = Describe("Bolt", func() {
 
 	test.GetTests(docPlugin)
 	test.SetTests(docPlugin)
+	test.ConditionalSetTests(docPlugin)
+	test.MergeTests(docPlugin)
 	test.DeleteTests(docPlugin)
 	test.QueryTests(docPlugin)
 	test.QueryStreamTests(docPlugin)
+	test.BatchTests(docPlugin)
+
+	Context("When a document is written by another tool", func() {
+		It("Should read the document without recreating the plugin", func() {
+			key := &document.Key{
+				Collection: &document.Collection{Name: "seeded"},
+				Id:         "1",
+			}
+			Expect(docPlugin.Set(key, map[string]interface{}{"name": "original"})).To(Succeed())
+
+			By("Updating the collection file directly")
+			dbPath := filepath.Join(utils.GetRelativeDevPath(boltdb_service.DEV_SUB_DIRECTORY), "seeded.db")
+			db, err := storm.Open(dbPath)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(db.Save(&boltdb_service.BoltDoc{
+				Id:           "1",
+				PartitionKey: "1",
+				SortKey:      "seeded#",
+				Value:        map[string]interface{}{"name": "seeded"},
+			})).To(Succeed())
+			Expect(db.Close()).To(Succeed())
+
+			doc, err := docPlugin.Get(key)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(doc.Content["name"]).To(Equal("seeded"))
+		})
+	})
+
+	Context("When the collection files are watched", func() {
+		It("Should log a reload of files written by another tool, but not of its own writes", func() {
+			dbDir := filepath.Join(utils.GetRelativeDevPath(boltdb_service.DEV_SUB_DIRECTORY), "watched")
+			log := gbytes.NewBuffer()
+			watchedPlugin, err := boltdb_service.NewWithOptions(
+				boltdb_service.WithDbDir(dbDir),
+				boltdb_service.WithWatch(10*time.Millisecond),
+				boltdb_service.WithLogger(logger.NewJSONLogger(log, logger.Level_Info)),
+			)
+			Expect(err).ShouldNot(HaveOccurred())
+			defer watchedPlugin.Close()
+
+			key := &document.Key{
+				Collection: &document.Collection{Name: "seeded"},
+				Id:         "1",
+			}
+			Expect(watchedPlugin.Set(key, map[string]interface{}{"name": "original"})).To(Succeed())
+			Consistently(log, "100ms").ShouldNot(gbytes.Say("reloaded"))
+
+			By("Updating the collection file directly")
+			db, err := storm.Open(filepath.Join(dbDir, "seeded.db"))
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(db.Save(&boltdb_service.BoltDoc{
+				Id:           "1",
+				PartitionKey: "1",
+				SortKey:      "seeded#",
+				Value:        map[string]interface{}{"name": "seeded"},
+			})).To(Succeed())
+			Expect(db.Close()).To(Succeed())
+
+			Eventually(log).Should(gbytes.Say("reloaded file modified by another tool"))
+
+			doc, err := watchedPlugin.Get(key)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(doc.Content["name"]).To(Equal("seeded"))
+		})
+	})
 })
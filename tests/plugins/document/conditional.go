@@ -0,0 +1,110 @@
+// Copyright 2021 Nitric Pty Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package document_suite
+
+import (
+	"github.com/nitrictech/nitric/pkg/plugins/document"
+	"github.com/nitrictech/nitric/pkg/plugins/errors"
+	"github.com/nitrictech/nitric/pkg/plugins/errors/codes"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var conditionalKey1 = document.Key{
+	Collection: &document.Collection{Name: "conditional"},
+	Id:         "1",
+}
+
+var conditionalKey2 = document.Key{
+	Collection: &document.Collection{Name: "conditional"},
+	Id:         "2",
+}
+
+func ConditionalSetTests(docPlugin document.DocumentService) {
+	Context("SetWithCondition", func() {
+		conditionalPlugin, ok := docPlugin.(document.ConditionalDocumentService)
+		if !ok {
+			panic("document plugin does not implement ConditionalDocumentService")
+		}
+
+		When("Nil condition", func() {
+			It("Should return error", func() {
+				err := conditionalPlugin.SetWithCondition(&conditionalKey1, UserItem1, nil)
+				Expect(err).Should(HaveOccurred())
+			})
+		})
+		When("Both IfNotExists and IfVersion", func() {
+			It("Should return error", func() {
+				err := conditionalPlugin.SetWithCondition(&conditionalKey1, UserItem1, &document.SetCondition{IfNotExists: true, IfVersion: 1})
+				Expect(err).Should(HaveOccurred())
+			})
+		})
+		When("IfNotExists on a new document", func() {
+			It("Should store the document", func() {
+				err := conditionalPlugin.SetWithCondition(&conditionalKey1, UserItem1, &document.SetCondition{IfNotExists: true})
+				Expect(err).ShouldNot(HaveOccurred())
+
+				doc, err := docPlugin.Get(&conditionalKey1)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(doc.Content["email"]).To(BeEquivalentTo(UserItem1["email"]))
+				Expect(doc.Version).ToNot(BeZero())
+			})
+		})
+		When("IfNotExists on an existing document", func() {
+			It("Should fail with FailedPrecondition", func() {
+				err := conditionalPlugin.SetWithCondition(&conditionalKey1, UserItem2, &document.SetCondition{IfNotExists: true})
+				Expect(err).Should(HaveOccurred())
+				Expect(errors.Code(err)).To(Equal(codes.FailedPrecondition))
+
+				doc, err := docPlugin.Get(&conditionalKey1)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(doc.Content["email"]).To(BeEquivalentTo(UserItem1["email"]))
+			})
+		})
+		When("IfVersion matches the current version", func() {
+			It("Should update the document and change its version", func() {
+				err := docPlugin.Set(&conditionalKey2, UserItem1)
+				Expect(err).ShouldNot(HaveOccurred())
+				doc, err := docPlugin.Get(&conditionalKey2)
+				Expect(err).ShouldNot(HaveOccurred())
+
+				err = conditionalPlugin.SetWithCondition(&conditionalKey2, UserItem2, &document.SetCondition{IfVersion: doc.Version})
+				Expect(err).ShouldNot(HaveOccurred())
+
+				updated, err := docPlugin.Get(&conditionalKey2)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(updated.Content["email"]).To(BeEquivalentTo(UserItem2["email"]))
+				Expect(updated.Version).ToNot(Equal(doc.Version))
+			})
+		})
+		When("IfVersion is stale", func() {
+			It("Should fail with FailedPrecondition", func() {
+				doc, err := docPlugin.Get(&conditionalKey2)
+				Expect(err).ShouldNot(HaveOccurred())
+
+				err = docPlugin.Set(&conditionalKey2, UserItem3)
+				Expect(err).ShouldNot(HaveOccurred())
+
+				err = conditionalPlugin.SetWithCondition(&conditionalKey2, UserItem1, &document.SetCondition{IfVersion: doc.Version})
+				Expect(err).Should(HaveOccurred())
+				Expect(errors.Code(err)).To(Equal(codes.FailedPrecondition))
+
+				current, err := docPlugin.Get(&conditionalKey2)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(current.Content["email"]).To(BeEquivalentTo(UserItem3["email"]))
+			})
+		})
+	})
+}
@@ -85,9 +85,12 @@ var _ = Describe("DynamoDb", func() {
 
 	test.GetTests(docPlugin)
 	test.SetTests(docPlugin)
+	test.ConditionalSetTests(docPlugin)
+	test.MergeTests(docPlugin)
 	test.DeleteTests(docPlugin)
 	test.QueryTests(docPlugin)
 	test.QueryStreamTests(docPlugin)
+	test.BatchTests(docPlugin)
 })
 
 func createDynamoClient() *dynamodb.DynamoDB {
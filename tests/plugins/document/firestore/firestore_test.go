@@ -73,7 +73,10 @@ var _ = Describe("Firestore", func() {
 
 	test.GetTests(docPlugin)
 	test.SetTests(docPlugin)
+	test.ConditionalSetTests(docPlugin)
+	test.MergeTests(docPlugin)
 	test.DeleteTests(docPlugin)
 	test.QueryTests(docPlugin)
 	test.QueryStreamTests(docPlugin)
+	test.BatchTests(docPlugin)
 })
@@ -0,0 +1,113 @@
+// Copyright 2021 Nitric Pty Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package document_suite
+
+import (
+	"github.com/nitrictech/nitric/pkg/plugins/document"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var mergeKey1 = document.Key{
+	Collection: &document.Collection{Name: "merge"},
+	Id:         "1",
+}
+
+var mergeKey2 = document.Key{
+	Collection: &document.Collection{Name: "merge"},
+	Id:         "2",
+}
+
+var mergeKey3 = document.Key{
+	Collection: &document.Collection{Name: "merge"},
+	Id:         "3",
+}
+
+var mergeItem = map[string]interface{}{
+	"name": "John",
+	"address": map[string]interface{}{
+		"city":     "Sydney",
+		"postcode": "2000",
+	},
+}
+
+var mergePartial = map[string]interface{}{
+	"email": "john@example.com",
+	"address": map[string]interface{}{
+		"city": "Melbourne",
+	},
+}
+
+func MergeTests(docPlugin document.DocumentService) {
+	Context("Merge", func() {
+		When("Blank key.Id", func() {
+			It("Should return error", func() {
+				key := document.Key{Collection: &document.Collection{Name: "merge"}}
+				err := docPlugin.Merge(&key, mergePartial, document.MergeShallow)
+				Expect(err).Should(HaveOccurred())
+			})
+		})
+		When("Nil partial map", func() {
+			It("Should return error", func() {
+				err := docPlugin.Merge(&mergeKey1, nil, document.MergeShallow)
+				Expect(err).Should(HaveOccurred())
+			})
+		})
+		When("The document doesn't exist", func() {
+			It("Should create it from the partial content", func() {
+				err := docPlugin.Merge(&mergeKey1, mergePartial, document.MergeDeep)
+				Expect(err).ShouldNot(HaveOccurred())
+
+				doc, err := docPlugin.Get(&mergeKey1)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(doc.Content["email"]).To(BeEquivalentTo("john@example.com"))
+				Expect(doc.Content["address"]).To(BeEquivalentTo(map[string]interface{}{"city": "Melbourne"}))
+			})
+		})
+		When("Shallow merging into an existing document", func() {
+			It("Should replace only the top-level fields provided", func() {
+				err := docPlugin.Set(&mergeKey2, mergeItem)
+				Expect(err).ShouldNot(HaveOccurred())
+
+				err = docPlugin.Merge(&mergeKey2, mergePartial, document.MergeShallow)
+				Expect(err).ShouldNot(HaveOccurred())
+
+				doc, err := docPlugin.Get(&mergeKey2)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(doc.Content["name"]).To(BeEquivalentTo("John"))
+				Expect(doc.Content["email"]).To(BeEquivalentTo("john@example.com"))
+				Expect(doc.Content["address"]).To(BeEquivalentTo(map[string]interface{}{"city": "Melbourne"}))
+			})
+		})
+		When("Deep merging into an existing document", func() {
+			It("Should merge nested fields", func() {
+				err := docPlugin.Set(&mergeKey3, mergeItem)
+				Expect(err).ShouldNot(HaveOccurred())
+
+				err = docPlugin.Merge(&mergeKey3, mergePartial, document.MergeDeep)
+				Expect(err).ShouldNot(HaveOccurred())
+
+				doc, err := docPlugin.Get(&mergeKey3)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(doc.Content["name"]).To(BeEquivalentTo("John"))
+				Expect(doc.Content["email"]).To(BeEquivalentTo("john@example.com"))
+				Expect(doc.Content["address"]).To(BeEquivalentTo(map[string]interface{}{
+					"city":     "Melbourne",
+					"postcode": "2000",
+				}))
+			})
+		})
+	})
+}
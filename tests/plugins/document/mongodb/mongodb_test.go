@@ -89,4 +89,5 @@ var _ = Describe("MongoDB", func() {
 	test.DeleteTests(docPlugin)
 	test.QueryTests(docPlugin)
 	test.QueryStreamTests(docPlugin)
+	test.BatchTests(docPlugin)
 })
@@ -0,0 +1,120 @@
+// Copyright 2021 Nitric Pty Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis_service_test
+
+import (
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/nitrictech/nitric/pkg/plugins/document"
+	redis_service "github.com/nitrictech/nitric/pkg/plugins/document/redis"
+	test "github.com/nitrictech/nitric/tests/plugins/document"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Redis", func() {
+	server, err := miniredis.Run()
+	if err != nil {
+		panic(err)
+	}
+
+	client := redis.NewClient(&redis.Options{
+		Addr: server.Addr(),
+	})
+
+	docPlugin, err := redis_service.NewWithClient(client)
+	if err != nil {
+		panic(err)
+	}
+
+	BeforeSuite(func() {
+		test.LoadItemsData(docPlugin)
+	})
+
+	AfterSuite(func() {
+		client.Close()
+		server.Close()
+	})
+
+	test.GetTests(docPlugin)
+	test.SetTests(docPlugin)
+	test.DeleteTests(docPlugin)
+	test.QueryTests(docPlugin)
+	test.QueryStreamTests(docPlugin)
+	test.BatchTests(docPlugin)
+
+	Context("SetWithTtl", func() {
+		expiringPlugin, ok := docPlugin.(document.ExpiringDocumentService)
+
+		It("Should support expiring documents", func() {
+			Expect(ok).To(BeTrue())
+		})
+
+		When("Setting a document with a ttl", func() {
+			It("Should remove the document once the ttl elapses", func() {
+				key := document.Key{
+					Collection: &document.Collection{Name: "sessions"},
+					Id:         "session1",
+				}
+
+				err := expiringPlugin.SetWithTtl(&key, map[string]interface{}{"user": "test"}, time.Minute)
+				Expect(err).ShouldNot(HaveOccurred())
+
+				doc, err := docPlugin.Get(&key)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(doc.Content["user"]).To(Equal("test"))
+
+				server.FastForward(2 * time.Minute)
+
+				_, err = docPlugin.Get(&key)
+				Expect(err).Should(HaveOccurred())
+			})
+		})
+
+		When("Setting a document with a negative ttl", func() {
+			It("Should return an error", func() {
+				key := document.Key{
+					Collection: &document.Collection{Name: "sessions"},
+					Id:         "session2",
+				}
+
+				err := expiringPlugin.SetWithTtl(&key, map[string]interface{}{"user": "test"}, -time.Minute)
+				Expect(err).Should(HaveOccurred())
+			})
+		})
+	})
+
+	Context("Key namespacing", func() {
+		When("Using a key prefix", func() {
+			It("Should store documents under the prefix", func() {
+				prefixed, _ := redis_service.NewWithClient(client, redis_service.WithKeyPrefix("app1"))
+				key := document.Key{
+					Collection: &document.Collection{Name: "widgets"},
+					Id:         "a/b#c",
+				}
+
+				Expect(prefixed.Set(&key, map[string]interface{}{"name": "widget"})).ShouldNot(HaveOccurred())
+				Expect(server.Exists("app1:widgets#a%2Fb%23c")).To(BeTrue())
+
+				result, err := prefixed.Query(key.Collection, []document.QueryExpression{}, 0, nil)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(result.Documents).To(HaveLen(1))
+				Expect(result.Documents[0].Key.Id).To(Equal("a/b#c"))
+			})
+		})
+	})
+})